@@ -0,0 +1,224 @@
+package json
+
+import (
+	stdlib "encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// aggregated holds the numbers of an array for Sum, Min, Max, and Avg
+type aggregated struct {
+	elements []*ctx
+	values   []*big.Rat
+	// floats holds the values as float64 if any of them is NaN or
+	// infinite, in which case values is nil
+	floats []float64
+}
+
+// numbers collects the elements of the array held by c, which must all
+// be numbers. op names the operation, for error reporting
+func (c *ctx) numbers(op string) (*aggregated, error) {
+	switch c.value.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return nil, c.typeError(ArrayValue, `cannot compute %s`, op)
+	}
+
+	n := c.value.Len()
+	agg := &aggregated{
+		elements: make([]*ctx, 0, n),
+		values:   make([]*big.Rat, 0, n),
+	}
+	for i := 0; i < n; i++ {
+		elem, err := c.indexChild(i)
+		if err != nil {
+			return nil, err
+		}
+		v := elem.interfaceValue()
+		if kindOf(v) != NumberValue {
+			return nil, elem.typeError(NumberValue, `cannot compute %s`, op)
+		}
+		agg.elements = append(agg.elements, elem)
+		if agg.floats != nil {
+			f, err := elem.float()
+			if err != nil {
+				elem.annotate(&err)
+				return nil, err
+			}
+			agg.floats = append(agg.floats, f)
+			continue
+		}
+		if r, ok := numberRat(v); ok {
+			agg.values = append(agg.values, r)
+			continue
+		}
+
+		// non-finite numbers cannot be computed exactly
+		agg.floats = make([]float64, 0, n)
+		for _, prev := range agg.elements {
+			f, err := prev.float()
+			if err != nil {
+				prev.annotate(&err)
+				return nil, err
+			}
+			agg.floats = append(agg.floats, f)
+		}
+		agg.values = nil
+	}
+	return agg, nil
+}
+
+// sum returns the sum of the numbers, as a value that can be held by a
+// Context
+func (agg *aggregated) sum() (interface{}, *big.Rat) {
+	if agg.floats != nil {
+		var sum float64
+		for _, f := range agg.floats {
+			sum += f
+		}
+		return sum, nil
+	}
+	sum := new(big.Rat)
+	for _, r := range agg.values {
+		sum.Add(sum, r)
+	}
+	return ratNumber(sum), sum
+}
+
+// extremum returns the element holding the smallest number if sign is
+// -1, and the largest one if sign is 1. NaN takes precedence over
+// every other number
+func (agg *aggregated) extremum(sign int) *ctx {
+	best := 0
+	for i := 1; i < len(agg.elements); i++ {
+		if agg.floats != nil {
+			if math.IsNaN(agg.floats[best]) {
+				break
+			}
+			f := agg.floats[i]
+			if math.IsNaN(f) || (sign < 0 && f < agg.floats[best]) || (sign > 0 && f > agg.floats[best]) {
+				best = i
+			}
+			continue
+		}
+		if agg.values[i].Cmp(agg.values[best]) == sign {
+			best = i
+		}
+	}
+	return agg.elements[best]
+}
+
+// ratNumber returns r as a json.Number. The literal is exact if r can
+// be written as a decimal number, and is otherwise the shortest one
+// that identifies the nearest float64
+func ratNumber(r *big.Rat) stdlib.Number {
+	if r.IsInt() {
+		return stdlib.Number(r.Num().String())
+	}
+
+	// fractions have a finite decimal representation if their
+	// denominator has no prime factors other than 2 and 5
+	denom := new(big.Int).Set(r.Denom())
+	var twos, fives int
+	two, five := big.NewInt(2), big.NewInt(5)
+	mod := new(big.Int)
+	for {
+		q, m := new(big.Int).QuoRem(denom, two, mod)
+		if m.Sign() != 0 {
+			break
+		}
+		denom = q
+		twos++
+	}
+	for {
+		q, m := new(big.Int).QuoRem(denom, five, mod)
+		if m.Sign() != 0 {
+			break
+		}
+		denom = q
+		fives++
+	}
+	if denom.Cmp(big.NewInt(1)) == 0 {
+		if fives > twos {
+			twos = fives
+		}
+		return stdlib.Number(r.FloatString(twos))
+	}
+
+	f, _ := r.Float64()
+	return stdlib.Number(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+func (c *ctx) Sum() Context {
+	agg, err := c.numbers(`sum`)
+	if err != nil {
+		return newErrCtx(err)
+	}
+	sum, _ := agg.sum()
+	return c.detached(sum)
+}
+
+func (c *ctx) Min() Context {
+	agg, err := c.numbers(`minimum`)
+	if err != nil {
+		return newErrCtx(err)
+	}
+	if len(agg.elements) == 0 {
+		return newErrCtx(c.accessError(ErrIndexOutOfRange, `cannot compute minimum of an empty array`))
+	}
+	return agg.extremum(-1)
+}
+
+func (c *ctx) Max() Context {
+	agg, err := c.numbers(`maximum`)
+	if err != nil {
+		return newErrCtx(err)
+	}
+	if len(agg.elements) == 0 {
+		return newErrCtx(c.accessError(ErrIndexOutOfRange, `cannot compute maximum of an empty array`))
+	}
+	return agg.extremum(1)
+}
+
+func (c *ctx) Avg() Context {
+	agg, err := c.numbers(`average`)
+	if err != nil {
+		return newErrCtx(err)
+	}
+	n := len(agg.elements)
+	if n == 0 {
+		return newErrCtx(c.accessError(ErrIndexOutOfRange, `cannot compute average of an empty array`))
+	}
+
+	sum, exact := agg.sum()
+	if exact == nil {
+		return c.detached(sum.(float64) / float64(n))
+	}
+	return c.detached(ratNumber(exact.Quo(exact, new(big.Rat).SetInt64(int64(n)))))
+}
+
+func (c *ctx) Count(fn func(Context) bool) (int, error) {
+	switch c.value.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return 0, c.typeError(ArrayValue, `cannot count elements`)
+	}
+
+	n := c.value.Len()
+	if fn == nil {
+		return n, nil
+	}
+	var count int
+	for i := 0; i < n; i++ {
+		elem, err := c.indexChild(i)
+		if err != nil {
+			return 0, err
+		}
+		if fn(elem) {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,108 @@
+package json
+
+import (
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+const (
+	// arenaBytesSize and arenaValuesSize are the sizes of the chunks
+	// of memory that an arena allocates strings and arrays from
+	arenaBytesSize  = 16 * 1024
+	arenaValuesSize = 1024
+)
+
+var arenaBytesPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, arenaBytesSize)
+		return &b
+	},
+}
+
+var arenaValuesPool = sync.Pool{
+	New: func() interface{} {
+		v := make([]interface{}, 0, arenaValuesSize)
+		return &v
+	},
+}
+
+// arena allocates the strings, numbers, and arrays of a document
+// parsed with WithArena from a few large chunks of memory, which are
+// reused for other documents once the Context is released.
+//
+// Values that are too large to share a chunk with others are
+// allocated normally, and are left to the garbage collector
+type arena struct {
+	bytes  []*[]byte
+	values []*[]interface{}
+}
+
+// string returns a string holding a copy of b
+func (a *arena) string(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	if len(b) > arenaBytesSize/8 {
+		return string(b)
+	}
+
+	var chunk *[]byte
+	if n := len(a.bytes); n > 0 && cap(*a.bytes[n-1])-len(*a.bytes[n-1]) >= len(b) {
+		chunk = a.bytes[n-1]
+	} else {
+		chunk = arenaBytesPool.Get().(*[]byte)
+		a.bytes = append(a.bytes, chunk)
+	}
+
+	start := len(*chunk)
+	*chunk = append(*chunk, b...)
+	return unsafe.String(&(*chunk)[start], len(b))
+}
+
+// slice returns a slice holding a copy of l. The capacity of the
+// slice is the same as its length, so that appending to it never
+// overwrites the values that follow it in the chunk
+func (a *arena) slice(l []interface{}) []interface{} {
+	if len(l) > arenaValuesSize/8 {
+		return append(make([]interface{}, 0, len(l)), l...)
+	}
+
+	var chunk *[]interface{}
+	if n := len(a.values); n > 0 && cap(*a.values[n-1])-len(*a.values[n-1]) >= len(l) {
+		chunk = a.values[n-1]
+	} else {
+		chunk = arenaValuesPool.Get().(*[]interface{})
+		a.values = append(a.values, chunk)
+	}
+
+	start := len(*chunk)
+	*chunk = append(*chunk, l...)
+	return (*chunk)[start:len(*chunk):len(*chunk)]
+}
+
+// release makes the memory held by the arena available for reuse
+func (a *arena) release() {
+	for _, chunk := range a.bytes {
+		*chunk = (*chunk)[:0]
+		arenaBytesPool.Put(chunk)
+	}
+	for _, chunk := range a.values {
+		// drop the references to the values, so that they can be
+		// garbage collected while the chunk sits in the pool
+		clear(*chunk)
+		*chunk = (*chunk)[:0]
+		arenaValuesPool.Put(chunk)
+	}
+	a.bytes = nil
+	a.values = nil
+}
+
+func (c *ctx) Release() {
+	if c.arena == nil {
+		return
+	}
+	c.arena.release()
+	c.arena = nil
+	c.value = reflect.Value{}
+}
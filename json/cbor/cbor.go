@@ -0,0 +1,52 @@
+// Package cbor converts CBOR (RFC 8949) data items to and from
+// json.Context trees, so that the same documents can be exchanged in
+// a compact binary form.
+//
+// CBOR data items are mapped onto the JSON model as follows:
+//
+//   - maps become JSON objects, whose fields keep the order in which
+//     they were encoded. Only text strings and integers (which are
+//     converted to their decimal representation) are supported as keys
+//   - integers, floats, and bignums (tags 2 and 3) become json.Number
+//     values. NaN and infinities become the non-finite numbers described
+//     in json.WithNonFiniteNumbers
+//   - byte strings become []byte values, which can be retrieved using
+//     the Bytes method of json.Context
+//   - date/time strings and epoch-based date/times (tags 0 and 1)
+//     become time.Time values
+//   - undefined becomes null, and all other tags are ignored
+//
+// When encoding, the same mapping is applied in reverse. Integers are
+// encoded in the shortest form, and floats as single precision if no
+// precision is lost
+package cbor
+
+import (
+	"fmt"
+	"github.com/lestrrat-go/json"
+)
+
+// Parse decodes the CBOR data item in data, and returns a Context
+// pointing to it. Data following the first data item is an error
+func Parse(data []byte) (json.Context, error) {
+	d := decoder{data: data}
+	v, err := d.value()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse CBOR: %w`, err)
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf(`failed to parse CBOR: unexpected data after the data item at offset %d`, d.pos)
+	}
+	return build(v), nil
+}
+
+// Marshal returns the CBOR encoding of the value pointed by c. Fields
+// of JSON objects are encoded in the same order in which the Context
+// iterates over them
+func Marshal(c json.Context) ([]byte, error) {
+	var e encoder
+	if err := e.context(c); err != nil {
+		return nil, fmt.Errorf(`failed to marshal CBOR: %w`, err)
+	}
+	return e.buf.Bytes(), nil
+}
@@ -0,0 +1,235 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/json"
+	"github.com/lestrrat-go/json/cbor"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestParse(t *testing.T) {
+	t.Run("examples", func(t *testing.T) {
+		// taken from Appendix A of RFC 8949
+		testcases := []struct {
+			Input    string
+			Expected string
+		}{
+			{Input: "00", Expected: `0`},
+			{Input: "17", Expected: `23`},
+			{Input: "1818", Expected: `24`},
+			{Input: "1903e8", Expected: `1000`},
+			{Input: "1a000f4240", Expected: `1000000`},
+			{Input: "1bffffffffffffffff", Expected: `18446744073709551615`},
+			{Input: "c249010000000000000000", Expected: `18446744073709551616`},
+			{Input: "3bffffffffffffffff", Expected: `-18446744073709551616`},
+			{Input: "c349010000000000000000", Expected: `-18446744073709551617`},
+			{Input: "20", Expected: `-1`},
+			{Input: "3903e7", Expected: `-1000`},
+			{Input: "f90000", Expected: `0.0`},
+			{Input: "f93c00", Expected: `1.0`},
+			{Input: "f93e00", Expected: `1.5`},
+			{Input: "f97bff", Expected: `65504.0`},
+			{Input: "f90001", Expected: `5.9604645e-08`},
+			{Input: "f9c400", Expected: `-4.0`},
+			{Input: "fa47c35000", Expected: `100000.0`},
+			{Input: "fb3ff199999999999a", Expected: `1.1`},
+			{Input: "fbc010666666666666", Expected: `-4.1`},
+			{Input: "f97c00", Expected: `Infinity`},
+			{Input: "f97e00", Expected: `NaN`},
+			{Input: "fbfff0000000000000", Expected: `-Infinity`},
+			{Input: "f4", Expected: `false`},
+			{Input: "f5", Expected: `true`},
+			{Input: "f6", Expected: `null`},
+			{Input: "f7", Expected: `null`},
+			{Input: "c074323031332d30332d32315432303a30343a30305a", Expected: `"2013-03-21T20:04:00Z"`},
+			{Input: "c11a514b67b0", Expected: `"2013-03-21T20:04:00Z"`},
+			{Input: "c1fb41d452d9ec200000", Expected: `"2013-03-21T20:04:00.5Z"`},
+			{Input: "d74401020304", Expected: `"AQIDBA=="`},
+			{Input: "d818456449455446", Expected: `"ZElFVEY="`},
+			{Input: "60", Expected: `""`},
+			{Input: "6449455446", Expected: `"IETF"`},
+			{Input: "62225c", Expected: `"\"\\"`},
+			{Input: "63e6b0b4", Expected: `"水"`},
+			{Input: "80", Expected: `[]`},
+			{Input: "83010203", Expected: `[1,2,3]`},
+			{Input: "8301820203820405", Expected: `[1,[2,3],[4,5]]`},
+			{Input: "a0", Expected: `{}`},
+			{Input: "a201020304", Expected: `{"1":2,"3":4}`},
+			{Input: "a26161016162820203", Expected: `{"a":1,"b":[2,3]}`},
+			{Input: "826161a161626163", Expected: `["a",{"b":"c"}]`},
+			{Input: "5f42010243030405ff", Expected: `"AQIDBAU="`},
+			{Input: "7f657374726561646d696e67ff", Expected: `"streaming"`},
+			{Input: "9f018202039f0405ffff", Expected: `[1,[2,3],[4,5]]`},
+			{Input: "bf61610161629f0203ffff", Expected: `{"a":1,"b":[2,3]}`},
+			{Input: "bf6346756ef563416d7421ff", Expected: `{"Fun":true,"Amt":-2}`},
+			{Input: "d9d9f7a0", Expected: `{}`},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Input, func(t *testing.T) {
+				j, err := cbor.Parse(mustDecodeHex(tc.Input))
+				if !assert.NoError(t, err, `cbor.Parse should succeed`) {
+					return
+				}
+				buf, err := j.MarshalJSON()
+				if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+					return
+				}
+				if !assert.Equal(t, tc.Expected, string(buf), `output should match`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("accessors", func(t *testing.T) {
+		// {"data": h'0102', "when": 0("2013-03-21T20:04:00Z"), "n": -1000}
+		j, err := cbor.Parse(mustDecodeHex("a3646461746142010264776865" + "6ec074323031332d30332d32315432303a30343a30305a" + "616e3903e7"))
+		if !assert.NoError(t, err, `cbor.Parse should succeed`) {
+			return
+		}
+
+		var b []byte
+		if !assert.NoError(t, j.MapIndex("data").Bytes(&b), `Bytes should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []byte{1, 2}, b, `byte string should match`) {
+			return
+		}
+
+		var m map[string]interface{}
+		if !assert.NoError(t, j.Map(&m), `Map should succeed`) {
+			return
+		}
+		tm, ok := m["when"].(time.Time)
+		if !assert.True(t, ok, `date/times should be time.Time values`) {
+			return
+		}
+		if !assert.True(t, time.Date(2013, time.March, 21, 20, 4, 0, 0, time.UTC).Equal(tm), `time should match`) {
+			return
+		}
+
+		var n int64
+		if !assert.NoError(t, j.MapIndex("n").Int(&n), `Int should succeed`) {
+			return
+		}
+		if !assert.Equal(t, int64(-1000), n, `integer should match`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		testcases := []struct {
+			Name  string
+			Input string
+		}{
+			{Name: "empty", Input: ""},
+			{Name: "truncated integer", Input: "19e8"},
+			{Name: "truncated string", Input: "644945"},
+			{Name: "truncated array", Input: "830102"},
+			{Name: "unterminated indefinite array", Input: "9f0102"},
+			{Name: "trailing data", Input: "0001"},
+			{Name: "duplicate key", Input: "a2616101616102"},
+			{Name: "unsupported key", Input: "a1f401"},
+			{Name: "invalid UTF-8", Input: "62c328"},
+			{Name: "huge length", Input: "5bffffffffffffffff"},
+			{Name: "huge map", Input: "bbffffffffffffffff"},
+			{Name: "invalid chunk", Input: "5f6161ff"},
+			{Name: "reserved additional information", Input: "1c"},
+			{Name: "unexpected break", Input: "ff"},
+			{Name: "invalid date/time", Input: "c06161"},
+			{Name: "invalid bignum", Input: "c201"},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				_, err := cbor.Parse(mustDecodeHex(tc.Input))
+				if !assert.Error(t, err, `cbor.Parse should fail`) {
+					return
+				}
+			})
+		}
+	})
+}
+
+func TestMarshal(t *testing.T) {
+	t.Run("encoding", func(t *testing.T) {
+		testcases := []struct {
+			Input    string
+			Expected string
+		}{
+			{Input: `0`, Expected: "00"},
+			{Input: `1000000`, Expected: "1a000f4240"},
+			{Input: `18446744073709551615`, Expected: "1bffffffffffffffff"},
+			{Input: `18446744073709551616`, Expected: "c249010000000000000000"},
+			{Input: `-18446744073709551616`, Expected: "3bffffffffffffffff"},
+			{Input: `-18446744073709551617`, Expected: "c349010000000000000000"},
+			{Input: `-1000`, Expected: "3903e7"},
+			{Input: `1.5`, Expected: "fa3fc00000"},
+			{Input: `1.1`, Expected: "fb3ff199999999999a"},
+			{Input: `1e400`, Expected: "f97c00"},
+			{Input: `"IETF"`, Expected: "6449455446"},
+			{Input: `[1,[2,3],{"a":true,"b":null}]`, Expected: "8301820203a26161f56162f6"},
+			{Input: `{"z":1,"a":2}`, Expected: "a2617a01616102"},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Input, func(t *testing.T) {
+				j, err := json.ParseString(tc.Input, json.WithPreserveKeyOrder(true))
+				if !assert.NoError(t, err, `json.ParseString should succeed`) {
+					return
+				}
+				buf, err := cbor.Marshal(j)
+				if !assert.NoError(t, err, `cbor.Marshal should succeed`) {
+					return
+				}
+				if !assert.Equal(t, tc.Expected, hex.EncodeToString(buf), `output should match`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("Go values", func(t *testing.T) {
+		j := json.New(map[string]interface{}{
+			"bytes": []byte{1, 2},
+			"int":   -24,
+			"uint":  uint8(200),
+			"time":  time.Date(2013, time.March, 21, 20, 4, 0, 0, time.UTC),
+		})
+		buf, err := cbor.Marshal(j)
+		if !assert.NoError(t, err, `cbor.Marshal should succeed`) {
+			return
+		}
+		// keys of maps that do not preserve their order are sorted
+		const expected = "a4" + "656279746573420102" + "63696e7437" +
+			"6474696d65c074323031332d30332d32315432303a30343a30305a" + "6475696e7418c8"
+		if !assert.Equal(t, expected, hex.EncodeToString(buf), `output should match`) {
+			return
+		}
+	})
+	t.Run("round trip", func(t *testing.T) {
+		const src = "bf6346756ef563416d7421616282f97e00c249010000000000000000" + "6464617461420102ff"
+		j, err := cbor.Parse(mustDecodeHex(src))
+		if !assert.NoError(t, err, `cbor.Parse should succeed`) {
+			return
+		}
+		buf, err := cbor.Marshal(j)
+		if !assert.NoError(t, err, `cbor.Marshal should succeed`) {
+			return
+		}
+		// indefinite lengths are replaced by definite ones
+		const expected = "a46346756ef563416d7421616282f97e00c249010000000000000000" + "6464617461420102"
+		if !assert.Equal(t, expected, hex.EncodeToString(buf), `output should match`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,457 @@
+package cbor
+
+import (
+	"encoding/binary"
+	stdlib "encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lestrrat-go/json"
+)
+
+// CBOR major types
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+// CBOR tags that are mapped onto the JSON model
+const (
+	tagDateTime     = 0
+	tagEpoch        = 1
+	tagPosBignum    = 2
+	tagNegBignum    = 3
+	tagSelfDescribe = 55799
+)
+
+// additional information values with special meaning
+const (
+	infoUint8      = 24
+	infoUint16     = 25
+	infoUint32     = 26
+	infoUint64     = 27
+	infoIndefinite = 31
+)
+
+// maxDepth is the maximum nesting depth of arrays, maps, and tags
+const maxDepth = 1000
+
+// object is a decoded CBOR map. keys holds the keys in the order in
+// which they were encoded
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// build creates a Context for the decoded value v
+func build(v interface{}) json.Context {
+	c := json.New(placeholder(v), json.WithPreserveKeyOrder(true), json.WithNonFiniteNumbers(true))
+	populate(c, v)
+	return c
+}
+
+// placeholder returns the value that should be stored for v before
+// calling populate. Objects are represented by empty maps, so that
+// their keys can be added through the Context in order
+func placeholder(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *object:
+		return map[string]interface{}{}
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, elem := range v {
+			l[i] = placeholder(elem)
+		}
+		return l
+	}
+	return v
+}
+
+// populate adds the contents of v to c, which holds placeholder(v)
+func populate(c json.Context, v interface{}) {
+	switch v := v.(type) {
+	case *object:
+		for _, key := range v.keys {
+			elem := v.values[key]
+			c.SetMapIndex(key, placeholder(elem))
+			populate(c.MapIndex(key), elem)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			populate(c.Index(i), elem)
+		}
+	}
+}
+
+// decoder reads CBOR data items
+type decoder struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+func (d *decoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf(format+` at offset %d`, append(args, d.pos)...)
+}
+
+// head reads the initial byte of a data item and its argument. For
+// indefinite-length items, indefinite is true and arg is zero
+func (d *decoder) head() (major byte, info byte, arg uint64, err error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, 0, d.errorf(`unexpected end of data`)
+	}
+	b := d.data[d.pos]
+	major, info = b>>5, b&0x1f
+	d.pos++
+
+	var n int
+	switch {
+	case info < infoUint8:
+		return major, info, uint64(info), nil
+	case info == infoUint8:
+		n = 1
+	case info == infoUint16:
+		n = 2
+	case info == infoUint32:
+		n = 4
+	case info == infoUint64:
+		n = 8
+	case info == infoIndefinite:
+		switch major {
+		case majorBytes, majorText, majorArray, majorMap, majorSimple:
+			return major, info, 0, nil
+		}
+		fallthrough
+	default:
+		d.pos--
+		return 0, 0, 0, d.errorf(`invalid additional information %d for major type %d`, info, major)
+	}
+
+	if len(d.data)-d.pos < n {
+		return 0, 0, 0, d.errorf(`unexpected end of data`)
+	}
+	buf := d.data[d.pos : d.pos+n]
+	d.pos += n
+	switch n {
+	case 1:
+		arg = uint64(buf[0])
+	case 2:
+		arg = uint64(binary.BigEndian.Uint16(buf))
+	case 4:
+		arg = uint64(binary.BigEndian.Uint32(buf))
+	default:
+		arg = binary.BigEndian.Uint64(buf)
+	}
+	return major, info, arg, nil
+}
+
+// isBreak reports whether the next byte is the break stop code, and
+// consumes it if so
+func (d *decoder) isBreak() bool {
+	if d.pos < len(d.data) && d.data[d.pos] == 0xff {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+// length checks that n elements of at least one byte each can still
+// be read, so that corrupt lengths do not cause huge allocations
+func (d *decoder) length(n uint64) (int, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return 0, d.errorf(`length %d exceeds the remaining data`, n)
+	}
+	return int(n), nil
+}
+
+func (d *decoder) value() (interface{}, error) {
+	start := d.pos
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case majorUint:
+		return stdlib.Number(strconv.FormatUint(arg, 10)), nil
+	case majorNegInt:
+		// the value is -1 - arg, which may not fit in an int64
+		var n big.Int
+		n.SetUint64(arg)
+		n.Add(&n, big.NewInt(1))
+		n.Neg(&n)
+		return stdlib.Number(n.String()), nil
+	case majorBytes, majorText:
+		b, err := d.str(major, info, arg)
+		if err != nil {
+			return nil, err
+		}
+		if major == majorBytes {
+			return b, nil
+		}
+		if !utf8.Valid(b) {
+			d.pos = start
+			return nil, d.errorf(`invalid UTF-8 in text string`)
+		}
+		return string(b), nil
+	case majorArray:
+		return d.array(info, arg)
+	case majorMap:
+		return d.object(info, arg)
+	case majorTag:
+		return d.tag(arg)
+	}
+	return d.simple(start, info, arg)
+}
+
+// str reads the contents of a byte or text string. Indefinite-length
+// strings are concatenated from their chunks
+func (d *decoder) str(major, info byte, arg uint64) ([]byte, error) {
+	if info != infoIndefinite {
+		n, err := d.length(arg)
+		if err != nil {
+			return nil, err
+		}
+		b := d.data[d.pos : d.pos+n]
+		d.pos += n
+		return b, nil
+	}
+
+	b := []byte{}
+	for !d.isBreak() {
+		chunkMajor, chunkInfo, chunkArg, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || chunkInfo == infoIndefinite {
+			return nil, d.errorf(`invalid chunk in indefinite-length string`)
+		}
+		chunk, err := d.str(chunkMajor, chunkInfo, chunkArg)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, chunk...)
+	}
+	return b, nil
+}
+
+func (d *decoder) enter() error {
+	d.depth++
+	if d.depth > maxDepth {
+		return d.errorf(`nesting depth exceeds maximum of %d`, maxDepth)
+	}
+	return nil
+}
+
+func (d *decoder) array(info byte, arg uint64) (interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { d.depth-- }()
+
+	if info == infoIndefinite {
+		l := []interface{}{}
+		for !d.isBreak() {
+			v, err := d.value()
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+		}
+		return l, nil
+	}
+
+	n, err := d.length(arg)
+	if err != nil {
+		return nil, err
+	}
+	l := make([]interface{}, n)
+	for i := range l {
+		if l[i], err = d.value(); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (d *decoder) object(info byte, arg uint64) (interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { d.depth-- }()
+
+	o := &object{values: make(map[string]interface{})}
+	next := func() bool {
+		if info == infoIndefinite {
+			return !d.isBreak()
+		}
+		return uint64(len(o.keys)) < arg
+	}
+	if info != infoIndefinite {
+		// each entry takes at least two bytes
+		if _, err := d.length(arg * 2); err != nil || arg > math.MaxInt32 {
+			return nil, d.errorf(`length %d exceeds the remaining data`, arg)
+		}
+	}
+
+	for next() {
+		start := d.pos
+		k, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		var key string
+		switch k := k.(type) {
+		case string:
+			key = k
+		case stdlib.Number:
+			key = string(k)
+		default:
+			d.pos = start
+			return nil, d.errorf(`unsupported map key of type %T`, k)
+		}
+		if _, ok := o.values[key]; ok {
+			d.pos = start
+			return nil, d.errorf(`duplicate map key %q`, key)
+		}
+
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		o.keys = append(o.keys, key)
+		o.values[key] = v
+	}
+	return o, nil
+}
+
+func (d *decoder) tag(tag uint64) (interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { d.depth-- }()
+
+	start := d.pos
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tag {
+	case tagDateTime:
+		s, ok := v.(string)
+		if !ok {
+			break
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			d.pos = start
+			return nil, d.errorf(`invalid date/time string %q`, s)
+		}
+		return t, nil
+	case tagEpoch:
+		n, ok := v.(stdlib.Number)
+		if !ok {
+			break
+		}
+		f, err := n.Float64()
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			d.pos = start
+			return nil, d.errorf(`invalid epoch-based date/time %s`, n)
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+	case tagPosBignum, tagNegBignum:
+		b, ok := v.([]byte)
+		if !ok {
+			break
+		}
+		var n big.Int
+		n.SetBytes(b)
+		if tag == tagNegBignum {
+			n.Add(&n, big.NewInt(1))
+			n.Neg(&n)
+		}
+		return stdlib.Number(n.String()), nil
+	default:
+		// the content of unknown tags is used as is
+		return v, nil
+	}
+
+	d.pos = start
+	return nil, d.errorf(`invalid content of type %T for tag %d`, v, tag)
+}
+
+func (d *decoder) simple(start int, info byte, arg uint64) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		// both null and undefined become null
+		return nil, nil
+	case infoUint16:
+		return floatNumber(float16ToFloat64(uint16(arg)), 32), nil
+	case infoUint32:
+		return floatNumber(float64(math.Float32frombits(uint32(arg))), 32), nil
+	case infoUint64:
+		return floatNumber(math.Float64frombits(arg), 64), nil
+	case infoIndefinite:
+		d.pos = start
+		return nil, d.errorf(`unexpected break stop code`)
+	}
+	d.pos = start
+	return nil, d.errorf(`unsupported simple value %d`, arg)
+}
+
+// floatNumber returns f as a json.Number, using the shortest
+// representation that is exact for the given precision
+func floatNumber(f float64, bitSize int) stdlib.Number {
+	switch {
+	case math.IsNaN(f):
+		return stdlib.Number("NaN")
+	case math.IsInf(f, 1):
+		return stdlib.Number("Infinity")
+	case math.IsInf(f, -1):
+		return stdlib.Number("-Infinity")
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if !strings.ContainsAny(s, ".e") {
+		// keep the number recognizable as a float, so that it is
+		// encoded as one again
+		s += ".0"
+	}
+	return stdlib.Number(s)
+}
+
+// float16ToFloat64 converts an IEEE 754 half-precision float
+func float16ToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant != 0 {
+			return math.NaN()
+		}
+		return math.Inf(int(sign))
+	}
+	return sign * math.Ldexp(mant+1024, exp-25)
+}
@@ -0,0 +1,256 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	stdlib "encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/json"
+)
+
+// encoder writes the CBOR encoding of Contexts. Containers are visited
+// through Contexts, so that the order of the fields is honored, while
+// the Go values held by them are used to determine their types
+type encoder struct {
+	buf bytes.Buffer
+}
+
+// context writes the value pointed by c
+func (e *encoder) context(c json.Context) error {
+	var m map[string]interface{}
+	if err := c.Map(&m); err == nil {
+		return e.value(c, m)
+	}
+	var l []interface{}
+	if err := c.Slice(&l); err == nil {
+		return e.value(c, l)
+	}
+
+	// Contexts do not expose scalar values of arbitrary types, so they
+	// are encoded from their JSON representation
+	b, err := c.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf(`failed to marshal JSON: %w`, err)
+	}
+	return e.fromJSON(b)
+}
+
+// head writes the initial byte of a data item with the given argument,
+// in the shortest form
+func (e *encoder) head(major byte, arg uint64) {
+	major <<= 5
+	switch {
+	case arg < infoUint8:
+		e.buf.WriteByte(major | byte(arg))
+	case arg <= math.MaxUint8:
+		e.buf.Write([]byte{major | infoUint8, byte(arg)})
+	case arg <= math.MaxUint16:
+		e.buf.WriteByte(major | infoUint16)
+		e.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(arg)))
+	case arg <= math.MaxUint32:
+		e.buf.WriteByte(major | infoUint32)
+		e.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(arg)))
+	default:
+		e.buf.WriteByte(major | infoUint64)
+		e.buf.Write(binary.BigEndian.AppendUint64(nil, arg))
+	}
+}
+
+// value writes v, which is pointed by c
+func (e *encoder) value(c json.Context, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf.WriteByte(majorSimple<<5 | 22)
+	case bool:
+		if v {
+			e.buf.WriteByte(majorSimple<<5 | 21)
+		} else {
+			e.buf.WriteByte(majorSimple<<5 | 20)
+		}
+	case map[string]interface{}:
+		e.head(majorMap, uint64(len(v)))
+		for key, child := range c.Entries() {
+			e.head(majorText, uint64(len(key)))
+			e.buf.WriteString(key)
+			if err := e.value(child, v[key]); err != nil {
+				return fmt.Errorf(`failed to encode key %q: %w`, key, err)
+			}
+		}
+	case []interface{}:
+		e.head(majorArray, uint64(len(v)))
+		for i, child := range c.Elements() {
+			if err := e.value(child, v[i]); err != nil {
+				return fmt.Errorf(`failed to encode element %d: %w`, i, err)
+			}
+		}
+	case string:
+		e.head(majorText, uint64(len(v)))
+		e.buf.WriteString(v)
+	case []byte:
+		e.head(majorBytes, uint64(len(v)))
+		e.buf.Write(v)
+	case stdlib.Number:
+		return e.number(string(v))
+	case float64:
+		e.float(v)
+	case float32:
+		e.float(float64(v))
+	case time.Time:
+		e.head(majorTag, tagDateTime)
+		s := v.Format(time.RFC3339Nano)
+		e.head(majorText, uint64(len(s)))
+		e.buf.WriteString(s)
+	case stdlib.Marshaler:
+		// custom number types created via json.WithNumberHook, and
+		// any other value that knows how to represent itself
+		b, err := v.MarshalJSON()
+		if err != nil {
+			return fmt.Errorf(`failed to marshal value of type %T: %w`, v, err)
+		}
+		return e.fromJSON(b)
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			e.int(rv.Int())
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			e.head(majorUint, rv.Uint())
+			return nil
+		}
+
+		b, err := stdlib.Marshal(v)
+		if err != nil {
+			return fmt.Errorf(`failed to marshal value of type %T: %w`, v, err)
+		}
+		return e.fromJSON(b)
+	}
+	return nil
+}
+
+// fromJSON writes the value encoded as JSON in b, which is used for
+// values that are not part of the document tree
+func (e *encoder) fromJSON(b []byte) error {
+	j, err := json.Parse(b, json.WithPreserveKeyOrder(true), json.WithNonFiniteNumbers(true))
+	if err != nil {
+		return fmt.Errorf(`failed to parse JSON: %w`, err)
+	}
+
+	var v interface{}
+	b = bytes.TrimSpace(b)
+	switch {
+	case len(b) > 0 && b[0] == '{':
+		var m map[string]interface{}
+		if err := j.Map(&m); err != nil {
+			return err
+		}
+		v = m
+	case len(b) > 0 && b[0] == '[':
+		var l []interface{}
+		if err := j.Slice(&l); err != nil {
+			return err
+		}
+		v = l
+	default:
+		// scalars can be decoded without losing information
+		d := stdlib.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		if err := d.Decode(&v); err != nil {
+			return fmt.Errorf(`failed to decode JSON: %w`, err)
+		}
+	}
+	return e.value(j, v)
+}
+
+func (e *encoder) int(n int64) {
+	if n >= 0 {
+		e.head(majorUint, uint64(n))
+		return
+	}
+	e.head(majorNegInt, uint64(-(n + 1)))
+}
+
+// number writes the JSON number n, as an integer if it is a whole
+// number, and as a float otherwise
+func (e *encoder) number(n string) error {
+	switch n {
+	case "NaN":
+		e.float(math.NaN())
+		return nil
+	case "Infinity":
+		e.float(math.Inf(1))
+		return nil
+	case "-Infinity":
+		e.float(math.Inf(-1))
+		return nil
+	}
+
+	if strings.ContainsAny(n, ".eE") {
+		f, err := strconv.ParseFloat(n, 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			return fmt.Errorf(`invalid number %q`, n)
+		}
+		e.float(f)
+		return nil
+	}
+
+	var i big.Int
+	if _, ok := i.SetString(n, 10); !ok {
+		return fmt.Errorf(`invalid number %q`, n)
+	}
+	if i.Sign() >= 0 {
+		if i.IsUint64() {
+			e.head(majorUint, i.Uint64())
+			return nil
+		}
+		e.bignum(tagPosBignum, &i)
+		return nil
+	}
+
+	// negative integers hold -1 - n
+	i.Neg(&i)
+	i.Sub(&i, big.NewInt(1))
+	if i.IsUint64() {
+		e.head(majorNegInt, i.Uint64())
+		return nil
+	}
+	e.bignum(tagNegBignum, &i)
+	return nil
+}
+
+// bignum writes the integer i as a byte string with the given tag, for
+// integers that do not fit in 64 bits
+func (e *encoder) bignum(tag uint64, i *big.Int) {
+	b := i.Bytes()
+	e.head(majorTag, tag)
+	e.head(majorBytes, uint64(len(b)))
+	e.buf.Write(b)
+}
+
+// float writes f as a single precision float if that is exact, and
+// as a double precision float otherwise. Non-finite values are
+// written as half precision floats
+func (e *encoder) float(f float64) {
+	switch {
+	case math.IsNaN(f):
+		e.buf.Write([]byte{majorSimple<<5 | infoUint16, 0x7e, 0x00})
+	case math.IsInf(f, 1):
+		e.buf.Write([]byte{majorSimple<<5 | infoUint16, 0x7c, 0x00})
+	case math.IsInf(f, -1):
+		e.buf.Write([]byte{majorSimple<<5 | infoUint16, 0xfc, 0x00})
+	case float64(float32(f)) == f:
+		e.buf.WriteByte(majorSimple<<5 | infoUint32)
+		e.buf.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(f))))
+	default:
+		e.buf.WriteByte(majorSimple<<5 | infoUint64)
+		e.buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+	}
+}
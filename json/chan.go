@@ -0,0 +1,81 @@
+package json
+
+import (
+	"context"
+	"io"
+	"reflect"
+)
+
+// streamElements runs next in a goroutine until it returns io.EOF,
+// sending each Context it returns through the first channel.
+// The first error encountered (including one caused by ctx being
+// canceled) is sent through the second channel. Both channels are
+// closed when the goroutine exits
+func streamElements(ctx context.Context, next func() (Context, error)) (<-chan Context, <-chan error) {
+	ch := make(chan Context)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(ch)
+
+		for {
+			if err := ctx.Err(); err != nil {
+				errc <- err
+				return
+			}
+
+			c, err := next()
+			if err != nil {
+				if err != io.EOF {
+					errc <- err
+				}
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			case ch <- c:
+			}
+		}
+	}()
+	return ch, errc
+}
+
+func errorStream(err error) (<-chan Context, <-chan error) {
+	ch := make(chan Context)
+	errc := make(chan error, 1)
+	errc <- err
+	close(ch)
+	close(errc)
+	return ch, errc
+}
+
+func (c *ctx) StreamElements(ctx context.Context) (<-chan Context, <-chan error) {
+	switch c.value.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return errorStream(c.typeError(ArrayValue, `cannot stream elements`))
+	}
+
+	var i int
+	return streamElements(ctx, func() (Context, error) {
+		if i >= c.value.Len() {
+			return nil, io.EOF
+		}
+		child, err := c.indexChild(i)
+		if err != nil {
+			return nil, err
+		}
+		i++
+		return child, nil
+	})
+}
+
+// StreamElements reads the remaining elements of the array in a
+// separate goroutine, and sends them through the returned channel.
+// See Context.StreamElements for details
+func (s *ArrayStream) StreamElements(ctx context.Context) (<-chan Context, <-chan error) {
+	return streamElements(ctx, s.Next)
+}
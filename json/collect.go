@@ -0,0 +1,43 @@
+package json
+
+import "errors"
+
+// Collector accumulates the errors returned by a series of accessors,
+// so that all the problems with a document can be reported at once
+// instead of stopping at the first one:
+//
+//	var c json.Collector
+//	c.Collect(j.MapIndex("name").String(&name))
+//	c.Collect(j.MapIndex("age").Int(&age))
+//	if err := c.Err(); err != nil {
+//	  ...
+//	}
+//
+// The errors returned by navigation and accessors record the path of
+// the offending value (see AccessError), which is included in the
+// message of the joined error. The zero value is ready to use
+type Collector struct {
+	errs []error
+}
+
+// Collect records err if it is not nil, and reports whether it was nil,
+// so that dependent accessors can be skipped
+func (c *Collector) Collect(err error) bool {
+	if err == nil {
+		return true
+	}
+	c.errs = append(c.errs, err)
+	return false
+}
+
+// Errors returns the errors recorded so far, in the order in which
+// they were collected
+func (c *Collector) Errors() []error {
+	return c.errs
+}
+
+// Err returns an error joining all the errors recorded so far (see
+// errors.Join), or nil if there are none
+func (c *Collector) Err() error {
+	return errors.Join(c.errs...)
+}
@@ -1,22 +1,104 @@
 package json
 
-import "reflect"
+import (
+	"log/slog"
+	"reflect"
+)
 
 // a errCtx exists solely to propagate an error that occurred
 // during a chained execution.
 type errCtx struct {
 	err error
+	// tracer is non-nil if the operations skipped because of err
+	// should be logged. See Trace
+	tracer *slog.Logger
 }
 
 type ctx struct {
-	set func(reflect.Value)
+	// value holds the JSON value as a plain Go value, such as the
+	// map[string]interface{} and []interface{} built by the parser.
+	// There is no separate typed node representation: the accessors
+	// and navigation methods type-assert the common types, and use
+	// reflection for the others
 	value reflect.Value
+	// parent is the Context pointing to the container holding this
+	// value, if this Context was obtained via MapIndex, Index, etc.
+	// key (if inMap is true) or index locate the value within the
+	// container, so that Set can update it
+	parent *ctx
+	key    string
+	index  int
+	inMap  bool
+	// lazy is non-nil if the value may contain nested values whose
+	// decoding has been deferred. It holds the settings used to
+	// decode them
+	lazy *parseConfig
+	// mapping holds the memory-mapped file that the deferred values
+	// refer to, so that it is kept alive as long as they are reachable.
+	// See ParseFile
+	mapping *mappedFile
+	// nonFinite is true if non-finite numbers should be marshaled
+	// as `NaN`, `Infinity`, and `-Infinity`. See WithNonFiniteNumbers
+	nonFinite bool
+	// strictNumbers is true if accessors should fail instead of
+	// changing the value of numbers that do not fit their destination.
+	// See WithStrictNumbers
+	strictNumbers bool
+	// locations holds the locations of the values in the original
+	// input, keyed by their paths, and path is the path of this value.
+	// They are only available if WithLocations was specified
+	locations map[string]Location
+	path      string
+	// order is non-nil if the order of the keys of JSON objects should
+	// be preserved. See WithPreserveKeyOrder
+	order *keyOrder
+	// timeFormat is non-nil if time.Time values should be marshaled in
+	// a format other than RFC 3339. See WithTimeLayout
+	timeFormat *timeFormat
+	// marshalers holds the functions used to marshal values of
+	// specific types. See WithMarshalFunc
+	marshalers marshalFuncs
+	// metrics receives the errors of the operations applied to this
+	// Context. See WithMetrics
+	metrics Metrics
+	// tracer is non-nil if the operations applied to this Context
+	// should be logged. See Trace
+	tracer *slog.Logger
+	// readOnly is true if the document must not be modified, because
+	// its values are shared by several versions. See Version
+	readOnly bool
+	// arena is non-nil if the Context was returned from Parse with
+	// WithArena, and holds the memory that the document was allocated
+	// from. It is not inherited by the Contexts derived from this one,
+	// so that only the Context for the entire document can be released
+	arena *arena
+	// indexes holds the indexes built by BuildIndex, keyed by field.
+	// They are not inherited by the Contexts derived from this one
+	indexes map[string]*fieldIndex
+	// generation is shared by the Contexts pointing into the same
+	// document, and is incremented whenever the document is modified
+	// through one of them, so that indexes can tell whether they are
+	// out of date
+	generation *uint64
+	// observers holds the functions registered by OnChange. They are
+	// not inherited by the Contexts derived from this one, which notify
+	// them through parent instead
+	observers []*changeObserver
 }
 
 func newCtx(v interface{}) *ctx {
-	return &ctx{value: reflect.ValueOf(v)}
+	return &ctx{value: reflect.ValueOf(v), generation: new(uint64)}
 }
 
 func newErrCtx(e error) *errCtx {
 	return &errCtx{err: e}
 }
+
+// interfaceValue returns the underlying value as an interface{}.
+// If the Context does not hold a valid value, nil is returned
+func (c *ctx) interfaceValue() interface{} {
+	if !c.value.IsValid() {
+		return nil
+	}
+	return c.value.Interface()
+}
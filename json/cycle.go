@@ -0,0 +1,146 @@
+package json
+
+import (
+	stdlib "encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// refKey identifies a map, slice, or pointer that may be part of a
+// reference cycle. Slices are identified by their length as well as
+// their data pointer, as slices sharing the same backing array do not
+// necessarily refer to each other
+type refKey struct {
+	ptr uintptr
+	len int
+}
+
+func refKeyOf(rv reflect.Value) (refKey, bool) {
+	switch rv.Kind() {
+	case reflect.Map, reflect.Ptr:
+		if rv.IsNil() {
+			return refKey{}, false
+		}
+		return refKey{ptr: rv.Pointer(), len: -1}, true
+	case reflect.Slice:
+		if rv.IsNil() || rv.Len() == 0 {
+			return refKey{}, false
+		}
+		return refKey{ptr: rv.Pointer(), len: rv.Len()}, true
+	}
+	return refKey{}, false
+}
+
+// checkCycle returns an error if v contains a reference cycle, or if
+// it refers to any of the containers that will hold it, which are
+// given in ancestors
+func checkCycle(v interface{}, ancestors []refKey) error {
+	active := make(map[refKey]struct{}, len(ancestors))
+	for _, key := range ancestors {
+		active[key] = struct{}{}
+	}
+	if !visitParsed(v, active) {
+		return fmt.Errorf(`value of type %T contains a reference cycle`, v)
+	}
+	return nil
+}
+
+// visitParsed is the same as visitCycle for v. The values held in parsed
+// documents are visited without reflection
+func visitParsed(v interface{}, active map[refKey]struct{}) bool {
+	switch v := v.(type) {
+	case nil, string, bool, float64, int64, stdlib.Number:
+		return true
+	case map[string]interface{}:
+		key, ok := refKeyOf(reflect.ValueOf(v))
+		if !ok {
+			return true
+		}
+		if _, ok := active[key]; ok {
+			return false
+		}
+		active[key] = struct{}{}
+		for _, elem := range v {
+			if !visitParsed(elem, active) {
+				return false
+			}
+		}
+		delete(active, key)
+		return true
+	case []interface{}:
+		key, ok := refKeyOf(reflect.ValueOf(v))
+		if !ok {
+			return true
+		}
+		if _, ok := active[key]; ok {
+			return false
+		}
+		active[key] = struct{}{}
+		for _, elem := range v {
+			if !visitParsed(elem, active) {
+				return false
+			}
+		}
+		delete(active, key)
+		return true
+	}
+	return visitCycle(reflect.ValueOf(v), active)
+}
+
+// visitCycle returns false if a reference cycle is found under rv.
+// active holds the references that are currently being visited
+func visitCycle(rv reflect.Value, active map[refKey]struct{}) bool {
+	if !rv.IsValid() {
+		return true
+	}
+
+	if key, ok := refKeyOf(rv); ok {
+		if _, ok := active[key]; ok {
+			return false
+		}
+		active[key] = struct{}{}
+		defer delete(active, key)
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		return visitCycle(rv.Elem(), active)
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			if !visitCycle(iter.Value(), active) {
+				return false
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if !visitCycle(rv.Index(i), active) {
+				return false
+			}
+		}
+	case reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < rv.NumField(); i++ {
+			// unexported fields are never marshaled
+			if !t.Field(i).IsExported() || t.Field(i).Tag.Get("json") == "-" {
+				continue
+			}
+			if !visitCycle(rv.Field(i), active) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ancestorRefs returns the references of the containers holding the
+// value pointed by c, including c itself. c may be nil
+func (c *ctx) ancestorRefs() []refKey {
+	var refs []refKey
+	for cur := c; cur != nil; cur = cur.parent {
+		if key, ok := refKeyOf(cur.value); ok {
+			refs = append(refs, key)
+		}
+	}
+	return refs
+}
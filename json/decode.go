@@ -0,0 +1,635 @@
+package json
+
+import (
+	"bytes"
+	stdlib "encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// rawValue holds the raw bytes of a JSON object or array whose
+// decoding has been deferred until it is accessed. See WithLazy
+type rawValue []byte
+
+func (v rawValue) MarshalJSON() ([]byte, error) {
+	return []byte(v), nil
+}
+
+// parseConfig holds the settings specified by ParseOptions
+type parseConfig struct {
+	arena                bool
+	comments             bool
+	decompressors        []decompressor
+	disallowTrailingData bool
+	duplicateKeys        DuplicateKeyPolicy
+	intDecoding          IntDecoding
+	internKeys           bool
+	json5                bool
+	keyTable             *KeyTable
+	lazy                 bool
+	locations            bool
+	preserveKeyOrder     bool
+	projection           []string
+	maxArrayLength       int
+	maxDepth             int
+	maxObjectKeys        int
+	maxSize              int64
+	maxStringLength      int
+	metrics              Metrics
+	nonFinite            bool
+	strictNumbers        bool
+	numberHook           NumberHook
+	timeFormat           *timeFormat
+	marshalers           marshalFuncs
+	trailingCommas       bool
+	unsafeStrings        bool
+	useNumber            bool
+}
+
+func newParseConfig(options []ParseOption) *parseConfig {
+	cfg := &parseConfig{
+		maxSize:   -1,
+		useNumber: true,
+	}
+	for _, option := range options {
+		switch option.Name() {
+		case optKeyArena:
+			cfg.arena = option.Value().(bool)
+		case optKeyComments:
+			cfg.comments = option.Value().(bool)
+		case optKeyDecompression:
+			if option.Value().(bool) {
+				cfg.decompressors = append(cfg.decompressors, decompressor{magic: gzipMagic, fn: gunzip})
+			}
+		case optKeyDecompressor:
+			cfg.decompressors = append(cfg.decompressors, option.Value().(decompressor))
+		case optKeyDisallowTrailingData:
+			cfg.disallowTrailingData = option.Value().(bool)
+		case optKeyDuplicateKeys:
+			cfg.duplicateKeys = option.Value().(DuplicateKeyPolicy)
+		case optKeyIntDecoding:
+			cfg.intDecoding = option.Value().(IntDecoding)
+		case optKeyInternKeys:
+			cfg.internKeys = option.Value().(bool)
+		case optKeyKeyTable:
+			cfg.keyTable = option.Value().(*KeyTable)
+		case optKeyJSON5:
+			cfg.json5 = option.Value().(bool)
+		case optKeyLazy:
+			cfg.lazy = option.Value().(bool)
+		case optKeyLocations:
+			cfg.locations = option.Value().(bool)
+		case optKeyPreserveKeyOrder:
+			cfg.preserveKeyOrder = option.Value().(bool)
+		case optKeyProjection:
+			cfg.projection = append(cfg.projection, option.Value().([]string)...)
+		case optKeyMaxArrayLength:
+			cfg.maxArrayLength = option.Value().(int)
+		case optKeyMaxDepth:
+			cfg.maxDepth = option.Value().(int)
+		case optKeyMaxObjectKeys:
+			cfg.maxObjectKeys = option.Value().(int)
+		case optKeyMaxStringLength:
+			cfg.maxStringLength = option.Value().(int)
+		case optKeyMaxSize:
+			cfg.maxSize = option.Value().(int64)
+		case optKeyMetrics:
+			cfg.metrics = option.Value().(Metrics)
+		case optKeyNumberHook:
+			cfg.numberHook = option.Value().(NumberHook)
+		case optKeyNonFiniteNumbers:
+			cfg.nonFinite = option.Value().(bool)
+		case optKeyStrictNumbers:
+			cfg.strictNumbers = option.Value().(bool)
+		case optKeyTimeLayout, optKeyTimeEpoch:
+			if f, ok := timeFormatOption(option); ok {
+				cfg.timeFormat = f
+			}
+		case optKeyMarshalFunc:
+			cfg.marshalers = cfg.marshalers.with(option.Value().(marshalFunc))
+		case optKeyTrailingCommas:
+			cfg.trailingCommas = option.Value().(bool)
+		case optKeyUnsafeStrings:
+			cfg.unsafeStrings = option.Value().(bool)
+		case optKeyUseNumber:
+			cfg.useNumber = option.Value().(bool)
+		}
+	}
+
+	// deferred values are decoded separately from the rest of the
+	// input, so their locations and key order would not be known
+	if cfg.locations || cfg.preserveKeyOrder {
+		cfg.lazy = false
+		cfg.projection = nil
+	}
+	return cfg
+}
+
+// deferred reports whether the decoding of some values may be deferred
+// until they are accessed, which requires the raw input to be retained
+func (cfg *parseConfig) deferred() bool {
+	return cfg.lazy || cfg.projection != nil
+}
+
+// extended reports whether the input may hold syntax that is not valid
+// JSON, such as comments, which deferred values keep in their raw bytes
+// and which must then be decoded before they are encoded
+func (cfg *parseConfig) extended() bool {
+	return cfg.comments || cfg.trailingCommas || cfg.json5 || cfg.nonFinite
+}
+
+// retainsInput reports whether the parsed values may refer to the
+// input, which must then not be modified while they are in use
+func (cfg *parseConfig) retainsInput() bool {
+	return cfg.deferred() || cfg.unsafeStrings
+}
+
+// DuplicateKeyPolicy specifies how duplicate keys in a JSON object
+// are handled while parsing. See WithDuplicateKeys
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeyLastWins keeps the value of the last occurrence of
+	// the key. This is the default
+	DuplicateKeyLastWins DuplicateKeyPolicy = iota
+	// DuplicateKeyFirstWins keeps the value of the first occurrence of the key
+	DuplicateKeyFirstWins
+	// DuplicateKeyError aborts parsing with an error wrapping ErrDuplicateKey
+	DuplicateKeyError
+)
+
+// IntDecoding specifies how JSON numbers that are whole numbers are
+// decoded while parsing. See WithIntDecoding
+type IntDecoding int
+
+const (
+	// IntDecodingNumber decodes whole numbers in the same manner as
+	// any other number, as specified by WithUseNumber. This is the default
+	IntDecodingNumber IntDecoding = iota
+	// IntDecodingInt64 decodes whole numbers as int64. Numbers that
+	// do not fit in an int64 are decoded as if IntDecodingNumber was
+	// specified
+	IntDecodingInt64
+	// IntDecodingFloat64 decodes whole numbers as float64
+	IntDecodingFloat64
+)
+
+// isWholeNumber reports whether s is a JSON number that has neither
+// a fraction nor an exponent
+func isWholeNumber(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '.', 'e', 'E', 'N', 'I':
+			return false
+		}
+	}
+	return true
+}
+
+// ErrDuplicateKey is wrapped by the error returned when a duplicate
+// key is found while parsing with DuplicateKeyError
+var ErrDuplicateKey = errors.New(`duplicate key`)
+
+func duplicateKeyError(tok Token) error {
+	return fmt.Errorf(`key %q at offset %d (line %d, column %d): %w`, tok.Value, tok.Offset, tok.Line, tok.Column, ErrDuplicateKey)
+}
+
+// decoder builds Go values from the tokens read from a Tokenizer
+type decoder struct {
+	t   *Tokenizer
+	cfg *parseConfig
+	// path is the path of the value being decoded, and locations
+	// holds the locations of the values decoded so far. They are only
+	// maintained if WithLocations is specified
+	path      string
+	locations map[string]Location
+	// order holds the order of the keys of the objects decoded so far.
+	// It is only maintained if WithPreserveKeyOrder is specified
+	order *keyOrder
+	// proj is the projection of the value being decoded, which is nil
+	// if it must be decoded entirely. See WithProjection
+	proj *projection
+
+	// data holds the entire input, and is only required for lazy decoding
+	data []byte
+
+	// arena is the arena that the document being decoded is allocated
+	// from, and elems holds the elements of the arrays being decoded.
+	// They are only used if WithArena is specified
+	arena *arena
+	elems []interface{}
+}
+
+func newDecoder(t *Tokenizer, cfg *parseConfig) *decoder {
+	t.SetAllowComments(cfg.comments)
+	t.SetAllowTrailingCommas(cfg.trailingCommas)
+	t.SetAllowNonFiniteNumbers(cfg.nonFinite)
+	t.SetJSON5(cfg.json5)
+	t.SetMaxDepth(cfg.maxDepth)
+	t.SetMaxStringLength(cfg.maxStringLength)
+	t.SetMaxArrayLength(cfg.maxArrayLength)
+	t.SetMaxObjectKeys(cfg.maxObjectKeys)
+	d := &decoder{
+		t:    t,
+		cfg:  cfg,
+		path: rootPath,
+	}
+	t.keyTable = cfg.keyTable
+	if cfg.arena {
+		d.newArena()
+	}
+	d.resetKeys()
+	return d
+}
+
+// resetKeys discards the keys interned so far, if WithInternKeys was
+// specified. They may have been allocated from the arena of the
+// previous document, so they must not be shared with the next one
+func (d *decoder) resetKeys() {
+	if d.cfg.internKeys && d.cfg.keyTable == nil {
+		d.t.keys = make(map[string]string)
+	}
+}
+
+// setInput records that data holds the entire input, so that lazily
+// decoded values and, if WithUnsafeStrings is specified, strings and
+// numbers can refer to it
+func (d *decoder) setInput(data []byte) {
+	d.data = data
+	if d.cfg.unsafeStrings {
+		d.t.input = data
+	}
+}
+
+// newArena starts allocating the values decoded from now on from a
+// new arena
+func (d *decoder) newArena() {
+	d.arena = &arena{}
+	d.t.arena = d.arena
+}
+
+// context creates the Context for the value v, which has just been
+// decoded as a top-level value
+func (d *decoder) context(v interface{}) *ctx {
+	c := newCtx(v)
+	if d.cfg.deferred() {
+		c.lazy = d.cfg
+	}
+	c.nonFinite = d.cfg.nonFinite || d.cfg.json5
+	c.strictNumbers = d.cfg.strictNumbers
+	c.timeFormat = d.cfg.timeFormat
+	c.marshalers = d.cfg.marshalers
+	c.metrics = d.cfg.metrics
+	if d.cfg.locations {
+		c.locations = d.locations
+		c.path = rootPath
+		d.locations = nil
+	}
+	if d.cfg.preserveKeyOrder {
+		c.order = d.order
+		if c.order == nil {
+			c.order = newKeyOrder()
+		}
+		c.order.attach(c)
+		d.order = nil
+	}
+	if d.arena != nil {
+		c.arena = d.arena
+		d.newArena()
+	}
+	d.resetKeys()
+	return c
+}
+
+// recordLocation records the location of the value that started with
+// tok, and has just been decoded
+func (d *decoder) recordLocation(tok Token) {
+	if d.locations == nil {
+		d.locations = make(map[string]Location)
+	}
+	if d.cfg.duplicateKeys == DuplicateKeyFirstWins {
+		if _, ok := d.locations[d.path]; ok {
+			return
+		}
+	}
+	d.locations[d.path] = Location{
+		Start: Position{Offset: tok.Offset, Line: tok.Line, Column: tok.Column},
+		End:   Position{Offset: d.t.offset(), Line: d.t.line, Column: d.t.col},
+	}
+}
+
+// decodeValue reads the next complete value from the tokenizer, and
+// returns it as a Go value: map[string]interface{} for objects,
+// []interface{} for arrays, json.Number (from encoding/json) for
+// numbers, and string, bool, or nil for the rest
+func (d *decoder) decodeValue() (interface{}, error) {
+	tok, err := d.t.Next()
+	if err != nil {
+		return nil, err
+	}
+	return d.decodeToken(tok)
+}
+
+func (d *decoder) decodeToken(tok Token) (interface{}, error) {
+	v, err := d.decodeTokenValue(tok)
+	if err != nil {
+		return nil, err
+	}
+	if d.cfg.locations {
+		d.recordLocation(tok)
+	}
+	return v, nil
+}
+
+func (d *decoder) decodeTokenValue(tok Token) (interface{}, error) {
+	switch tok.Kind {
+	case ObjectStartToken:
+		m := make(map[string]interface{})
+		var keys []string
+		for {
+			tok, err := d.t.Next()
+			if err != nil {
+				return nil, err
+			}
+			if tok.Kind == ObjectEndToken {
+				if d.cfg.preserveKeyOrder {
+					if d.order == nil {
+						d.order = newKeyOrder()
+					}
+					d.order.record(m, keys)
+				}
+				return m, nil
+			}
+
+			key := tok.Value.(string)
+			parent, proj := d.path, d.proj
+			if d.cfg.locations {
+				d.path = keyPath(parent, key)
+			}
+			if proj != nil {
+				d.proj = proj.field(key)
+			}
+			v, err := d.decodeElement()
+			d.path, d.proj = parent, proj
+			if err != nil {
+				return nil, err
+			}
+
+			if d.cfg.duplicateKeys != DuplicateKeyLastWins {
+				if _, ok := m[key]; ok {
+					if d.cfg.duplicateKeys == DuplicateKeyError {
+						return nil, duplicateKeyError(tok)
+					}
+					if d.order != nil {
+						d.order.forget(v)
+					}
+					continue
+				}
+			}
+			if d.cfg.preserveKeyOrder {
+				if prev, ok := m[key]; !ok {
+					keys = append(keys, key)
+				} else if d.order != nil {
+					d.order.forget(prev)
+				}
+			}
+			m[key] = v
+		}
+	case ArrayStartToken:
+		if d.arena != nil {
+			return d.decodeArenaArray()
+		}
+		l := []interface{}{}
+		for {
+			tok, err := d.t.Next()
+			if err != nil {
+				return nil, err
+			}
+			if tok.Kind == ArrayEndToken {
+				return l, nil
+			}
+
+			parent, proj := d.path, d.proj
+			if d.cfg.locations {
+				d.path = indexPath(parent, len(l))
+			}
+			if proj != nil {
+				d.proj = proj.element(len(l))
+			}
+			v, err := d.decodeElementToken(tok)
+			d.path, d.proj = parent, proj
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+		}
+	case NumberToken:
+		return d.decodeNumber(tok)
+	case StringToken, BoolToken, NullToken:
+		return tok.Value, nil
+	}
+	return nil, fmt.Errorf(`unexpected token %s`, tok.Kind)
+}
+
+// decodeArenaArray decodes the elements of an array that has just been
+// opened, and returns them in a slice allocated from the arena. The
+// elements are accumulated in d.elems, which is shared with the arrays
+// nested in this one, so that the slice is allocated only once
+func (d *decoder) decodeArenaArray() (interface{}, error) {
+	start := len(d.elems)
+	defer func() {
+		clear(d.elems[start:])
+		d.elems = d.elems[:start]
+	}()
+
+	for {
+		tok, err := d.t.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tok.Kind == ArrayEndToken {
+			return d.arena.slice(d.elems[start:]), nil
+		}
+
+		parent, proj := d.path, d.proj
+		if d.cfg.locations {
+			d.path = indexPath(parent, len(d.elems)-start)
+		}
+		if proj != nil {
+			d.proj = proj.element(len(d.elems) - start)
+		}
+		v, err := d.decodeElementToken(tok)
+		d.path, d.proj = parent, proj
+		if err != nil {
+			return nil, err
+		}
+		d.elems = append(d.elems, v)
+	}
+}
+
+func (d *decoder) decodeNumber(tok Token) (interface{}, error) {
+	n := tok.Value.(stdlib.Number)
+	if d.cfg.numberHook != nil {
+		v, err := d.cfg.numberHook(string(n))
+		if err != nil {
+			return nil, fmt.Errorf(`failed to create number from %s at offset %d: %w`, n, tok.Offset, err)
+		}
+		return v, nil
+	}
+
+	if d.cfg.intDecoding != IntDecodingNumber && isWholeNumber(string(n)) {
+		switch d.cfg.intDecoding {
+		case IntDecodingInt64:
+			if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+				return i, nil
+			}
+		case IntDecodingFloat64:
+			return d.decodeFloat(tok)
+		}
+	}
+
+	if !d.cfg.useNumber {
+		return d.decodeFloat(tok)
+	}
+	// returning tok.Value instead of n avoids boxing n again
+	return tok.Value, nil
+}
+
+func (d *decoder) decodeFloat(tok Token) (interface{}, error) {
+	f, err := tok.Value.(stdlib.Number).Float64()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to convert number %s to float64 at offset %d`, tok.Value, tok.Offset)
+	}
+	return f, nil
+}
+
+// decodeElement decodes a value nested inside an object or an array
+func (d *decoder) decodeElement() (interface{}, error) {
+	tok, err := d.t.Next()
+	if err != nil {
+		return nil, err
+	}
+	return d.decodeElementToken(tok)
+}
+
+func (d *decoder) decodeElementToken(tok Token) (interface{}, error) {
+	if (d.cfg.lazy || d.proj == unprojected) && (tok.Kind == ObjectStartToken || tok.Kind == ArrayStartToken) {
+		// numbers that cannot be converted must be rejected now, rather
+		// than when the deferred value is decoded
+		end, err := d.skip(d.numbersMayFail())
+		if err != nil {
+			return nil, err
+		}
+		return rawValue(d.data[tok.Offset:end]), nil
+	}
+	return d.decodeToken(tok)
+}
+
+// skip validates and discards the tokens up to the end of the
+// container that has just been opened, and returns the offset
+// immediately after it. If checkNumbers is true, numbers are also
+// converted as they would be by decodeNumber, so that conversion
+// errors are reported before the values are deferred
+func (d *decoder) skip(checkNumbers bool) (int64, error) {
+	// duplicate keys must be detected before the values are deferred,
+	// so the keys need to be materialized in that case
+	var keys []map[string]struct{}
+	checkKeys := d.cfg.duplicateKeys == DuplicateKeyError
+	if checkKeys {
+		keys = append(keys, make(map[string]struct{}))
+	} else {
+		d.t.discard = true
+		defer func() { d.t.discard = false }()
+	}
+	if checkNumbers {
+		d.t.keepNumbers = true
+		defer func() { d.t.keepNumbers = false }()
+	}
+
+	depth := d.t.Depth()
+	for {
+		tok, err := d.t.Next()
+		if err != nil {
+			return 0, err
+		}
+		if d.t.Depth() < depth {
+			return tok.End, nil
+		}
+
+		if checkNumbers && tok.Kind == NumberToken {
+			if _, err := d.decodeNumber(tok); err != nil {
+				return 0, err
+			}
+		}
+		if !checkKeys {
+			continue
+		}
+		switch tok.Kind {
+		case ObjectStartToken:
+			keys = append(keys, make(map[string]struct{}))
+		case ArrayStartToken:
+			keys = append(keys, nil)
+		case ObjectEndToken, ArrayEndToken:
+			keys = keys[:len(keys)-1]
+		case KeyToken:
+			seen := keys[len(keys)-1]
+			key := tok.Value.(string)
+			if _, ok := seen[key]; ok {
+				return 0, duplicateKeyError(tok)
+			}
+			seen[key] = struct{}{}
+		}
+	}
+}
+
+// numbersMayFail reports whether decodeNumber may fail to convert
+// numbers, depending on the configuration. NumberHooks are not called
+// for the values that are deferred, which are only checked once they
+// are decoded
+func (d *decoder) numbersMayFail() bool {
+	return d.cfg.numberHook == nil && (!d.cfg.useNumber || d.cfg.intDecoding == IntDecodingFloat64)
+}
+
+// decodeLazy decodes the outermost level of a rawValue if WithLazy was
+// specified, keeping nested objects and arrays as rawValues. Values
+// deferred by WithProjection are decoded entirely.
+//
+// rawValues are only created from input that has been validated by
+// skip, including the conversion of numbers, but a NumberHook is only
+// called once the value is decoded, and may still fail
+func decodeLazy(raw rawValue, cfg *parseConfig) (interface{}, error) {
+	d := newDecoder(NewTokenizer(bytes.NewReader(raw)), cfg)
+	d.setInput(raw)
+	return d.decodeValue()
+}
+
+// resolveAll replaces all rawValues contained in v with their decoded
+// values, and returns the result
+func resolveAll(v interface{}, cfg *parseConfig) (interface{}, error) {
+	switch x := v.(type) {
+	case rawValue:
+		decoded, err := decodeLazy(x, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return resolveAll(decoded, cfg)
+	case map[string]interface{}:
+		for key, elem := range x {
+			resolved, err := resolveAll(elem, cfg)
+			if err != nil {
+				return nil, err
+			}
+			x[key] = resolved
+		}
+	case []interface{}:
+		for i, elem := range x {
+			resolved, err := resolveAll(elem, cfg)
+			if err != nil {
+				return nil, err
+			}
+			x[i] = resolved
+		}
+	}
+	return v, nil
+}
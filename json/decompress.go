@@ -0,0 +1,49 @@
+package json
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// DecompressFunc returns a reader that decompresses the data read from r.
+// See WithDecompressor
+type DecompressFunc func(r io.Reader) (io.Reader, error)
+
+// decompressor associates the magic bytes at the beginning of
+// compressed data with the function used to decompress it
+type decompressor struct {
+	magic []byte
+	fn    DecompressFunc
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+func gunzip(r io.Reader) (io.Reader, error) {
+	return gzip.NewReader(r)
+}
+
+// decompress sniffs the beginning of the data in r, and if it matches
+// the magic bytes of one of the decompressors, returns a reader that
+// decompresses it. Otherwise the data is returned as is
+func decompress(r io.Reader, decompressors []decompressor) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	for _, d := range decompressors {
+		magic, err := br.Peek(len(d.magic))
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf(`failed to read input: %w`, err)
+		}
+		if !bytes.Equal(magic, d.magic) {
+			continue
+		}
+
+		dr, err := d.fn(br)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to decompress input: %w`, err)
+		}
+		return dr, nil
+	}
+	return br, nil
+}
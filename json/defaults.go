@@ -0,0 +1,259 @@
+package json
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func (c *ctx) ApplyDefaults(schema Context) Context {
+	schema, unlock := unwrapSync(schema)
+	defer unlock()
+
+	if err := schema.Err(); err != nil {
+		return newErrCtx(fmt.Errorf(`invalid schema: %w`, err))
+	}
+	sc, ok := schema.(*ctx)
+	if !ok {
+		return newErrCtx(fmt.Errorf(`unsupported schema (%T)`, schema))
+	}
+
+	// the copy made by rewrite is filled in place
+	copied := c.rewrite(func(s string, _ *keyOrder) (interface{}, error) {
+		return s, nil
+	})
+	if err := copied.Err(); err != nil {
+		return copied
+	}
+	c2 := copied.(*ctx)
+
+	root, err := sc.modelValue()
+	if err != nil {
+		return newErrCtx(fmt.Errorf(`invalid schema: %w`, err))
+	}
+	f := &defaultFiller{schema: sc, root: root, order: c2.order}
+	v, err := f.apply(root, c2.interfaceValue(), rootPath, nil)
+	if err != nil {
+		return newErrCtx(err)
+	}
+	c2.value = reflect.ValueOf(v)
+	return c2
+}
+
+// defaultFiller fills the fields of a document that are missing from
+// the values of the default keywords of a JSON Schema
+type defaultFiller struct {
+	schema *ctx
+	root   interface{}
+	// order records the order of the keys of the document, and is nil
+	// unless WithPreserveKeyOrder was specified
+	order *keyOrder
+}
+
+// apply applies the schema node to v, found at path. refs holds the
+// references that were followed to reach node without descending into
+// v, in order to detect loops
+func (f *defaultFiller) apply(node, v interface{}, path string, refs []string) (interface{}, error) {
+	schema, ok := node.(map[string]interface{})
+	if !ok {
+		// boolean schemas have no defaults
+		return v, nil
+	}
+
+	if ref, ok := schema[`$ref`].(string); ok {
+		for _, seen := range refs {
+			if seen == ref {
+				return nil, fmt.Errorf(`failed to apply defaults at %s: reference %q loops`, path, ref)
+			}
+		}
+		target, err := f.resolve(ref)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to apply defaults at %s: %w`, path, err)
+		}
+		if v, err = f.apply(target, v, path, append(refs, ref)); err != nil {
+			return nil, err
+		}
+	}
+	if list, ok := schema[`allOf`].([]interface{}); ok {
+		for _, sub := range list {
+			var err error
+			if v, err = f.apply(sub, v, path, refs); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if err := f.applyObject(schema, v, path); err != nil {
+			return nil, err
+		}
+	case []interface{}:
+		prefix, _ := schema[`prefixItems`].([]interface{})
+		for i := range v {
+			sub := schema[`items`]
+			if i < len(prefix) {
+				sub = prefix[i]
+			}
+			elem, err := f.apply(sub, v[i], indexPath(path, i), nil)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = elem
+		}
+	}
+	return v, nil
+}
+
+func (f *defaultFiller) applyObject(schema, m map[string]interface{}, path string) error {
+	props, _ := schema[`properties`].(map[string]interface{})
+	rv := reflect.ValueOf(props)
+	var keys []reflect.Value
+	if f.schema.order != nil {
+		keys = f.schema.order.mapKeys(rv)
+	} else {
+		keys = sortedMapKeys(rv)
+	}
+
+	for _, keyV := range keys {
+		key := keyV.String()
+		sub := props[key]
+		if _, ok := m[key]; !ok {
+			def, ok, err := f.lookupDefault(sub, keyPath(path, key), nil)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			// the default is copied, so that modifying the document
+			// does not affect the schema
+			value, err := f.schema.rewriteValue(f.order, keyPath(path, key), def, func(s string, _ *keyOrder) (interface{}, error) {
+				return s, nil
+			})
+			if err != nil {
+				return err
+			}
+			m[key] = value
+			if f.order != nil {
+				f.order.add(reflect.ValueOf(m), key)
+			}
+		}
+		value, err := f.apply(sub, m[key], keyPath(path, key), nil)
+		if err != nil {
+			return err
+		}
+		m[key] = value
+	}
+
+	patterns, _ := schema[`patternProperties`].(map[string]interface{})
+	additional, hasAdditional := schema[`additionalProperties`]
+	if len(patterns) == 0 && !hasAdditional {
+		return nil
+	}
+	type patternProperty struct {
+		re     *regexp.Regexp
+		schema interface{}
+	}
+	var compiled []patternProperty
+	for pattern, sub := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf(`failed to apply defaults at %s: invalid pattern %q: %w`, path, pattern, err)
+		}
+		compiled = append(compiled, patternProperty{re: re, schema: sub})
+	}
+
+	for _, keyV := range sortedMapKeys(reflect.ValueOf(m)) {
+		key := keyV.String()
+		var matched bool
+		for _, pp := range compiled {
+			if !pp.re.MatchString(key) {
+				continue
+			}
+			matched = true
+			value, err := f.apply(pp.schema, m[key], keyPath(path, key), nil)
+			if err != nil {
+				return err
+			}
+			m[key] = value
+		}
+		if _, ok := props[key]; ok || matched || !hasAdditional {
+			continue
+		}
+		value, err := f.apply(additional, m[key], keyPath(path, key), nil)
+		if err != nil {
+			return err
+		}
+		m[key] = value
+	}
+	return nil
+}
+
+// lookupDefault returns the default value of the schema node, which
+// may be declared by the schema that it refers to
+func (f *defaultFiller) lookupDefault(node interface{}, path string, refs []string) (interface{}, bool, error) {
+	schema, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, false, nil
+	}
+	if def, ok := schema[`default`]; ok {
+		return def, true, nil
+	}
+	ref, ok := schema[`$ref`].(string)
+	if !ok {
+		return nil, false, nil
+	}
+	for _, seen := range refs {
+		if seen == ref {
+			return nil, false, fmt.Errorf(`failed to apply defaults at %s: reference %q loops`, path, ref)
+		}
+	}
+	target, err := f.resolve(ref)
+	if err != nil {
+		return nil, false, fmt.Errorf(`failed to apply defaults at %s: %w`, path, err)
+	}
+	return f.lookupDefault(target, path, append(refs, ref))
+}
+
+// resolve returns the schema node referred to by ref, which must be a
+// JSON pointer within the schema, such as `#/$defs/foo`
+func (f *defaultFiller) resolve(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, `#`) {
+		return nil, fmt.Errorf(`unsupported reference %q: only references within the schema are supported`, ref)
+	}
+	pointer, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil, fmt.Errorf(`invalid reference %q: %w`, ref, err)
+	}
+
+	node := f.root
+	if pointer == "" {
+		return node, nil
+	}
+	if !strings.HasPrefix(pointer, `/`) {
+		return nil, fmt.Errorf(`unsupported reference %q: anchors are not supported`, ref)
+	}
+	for _, token := range strings.Split(pointer[1:], `/`) {
+		token = strings.NewReplacer(`~1`, `/`, `~0`, `~`).Replace(token)
+		switch x := node.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if node, ok = x[token]; !ok {
+				return nil, fmt.Errorf(`invalid reference %q: not found`, ref)
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil, fmt.Errorf(`invalid reference %q: not found`, ref)
+			}
+			node = x[i]
+		default:
+			return nil, fmt.Errorf(`invalid reference %q: not found`, ref)
+		}
+	}
+	return node, nil
+}
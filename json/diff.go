@@ -0,0 +1,274 @@
+package json
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ChangeKind describes how a value differs between two documents
+type ChangeKind int
+
+const (
+	// ChangeAdded is the kind of the values only found in the second
+	// document
+	ChangeAdded ChangeKind = iota + 1
+	// ChangeRemoved is the kind of the values only found in the first
+	// document
+	ChangeRemoved
+	// ChangeModified is the kind of the values found in both documents
+	// that are not equal
+	ChangeModified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	default:
+		return "invalid"
+	}
+}
+
+// Change describes a value that differs between two documents, found
+// at Path, in the notation used by Walk. Before holds the value in the
+// first document, and is nil if the value was added. After holds the
+// value in the second document, and is nil if the value was removed.
+// Before and After refer to the values held by the documents, as the
+// Contexts returned by MapIndex and Index do
+type Change struct {
+	Kind   ChangeKind
+	Path   string
+	Before Context
+	After  Context
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf(`+ %s: %s`, c.Path, reportValue(c.After))
+	case ChangeRemoved:
+		return fmt.Sprintf(`- %s: %s`, c.Path, reportValue(c.Before))
+	}
+	return fmt.Sprintf(`~ %s: %s -> %s`, c.Path, reportValue(c.Before), reportValue(c.After))
+}
+
+// reportValue returns the compact JSON encoding of the value held by c
+func reportValue(c Context) string {
+	buf, err := c.MarshalJSON()
+	if err != nil {
+		return fmt.Sprintf(`<%s>`, err)
+	}
+	return string(buf)
+}
+
+// Diff returns the values that differ between the documents held by a
+// and b, or nil if they are equal as reported by Equal, which accepts
+// the same options. Objects and arrays found in both documents are
+// compared value by value, and the fields of objects are visited in
+// sorted order. When WithIgnoreArrayOrder is specified, the elements
+// of an array that cannot be matched with an equal element of the other
+// array are reported as removed from the first array and added to the
+// second one.
+//
+// An error is returned if either Context is invalid, or if the options
+// are invalid
+func Diff(a, b Context, options ...EqualOption) ([]Change, error) {
+	a, unlockA := unwrapSync(a)
+	defer unlockA()
+	b, unlockB := unwrapSync(b, syncMutex(a))
+	defer unlockB()
+
+	if err := a.Err(); err != nil {
+		return nil, fmt.Errorf(`invalid first document: %w`, err)
+	}
+	if err := b.Err(); err != nil {
+		return nil, fmt.Errorf(`invalid second document: %w`, err)
+	}
+	ca, ok := a.(*ctx)
+	if !ok {
+		return nil, fmt.Errorf(`unsupported first document (%T)`, a)
+	}
+	cb, ok := b.(*ctx)
+	if !ok {
+		return nil, fmt.Errorf(`unsupported second document (%T)`, b)
+	}
+
+	cmp, ignore, err := newEqualizer(options)
+	if err != nil {
+		return nil, err
+	}
+	if ignore == nil {
+		// the whole document is ignored
+		return nil, nil
+	}
+
+	va, err := ca.modelValue()
+	if err != nil {
+		return nil, fmt.Errorf(`invalid first document: %w`, err)
+	}
+	vb, err := cb.modelValue()
+	if err != nil {
+		return nil, fmt.Errorf(`invalid second document: %w`, err)
+	}
+	d := &differ{equalizer: cmp, a: ca, b: cb}
+	d.diff(rootPath, va, vb, ignore)
+	return d.changes, nil
+}
+
+// DiffReport returns a human readable report of the values that differ
+// between the documents held by a and b, as returned by Diff, with one
+// line per value. Added values are prefixed with `+`, removed values
+// with `-`, and modified values with `~`, followed by their path and
+// their compact JSON encoding:
+//
+//	~ $.server.port: 8080 -> 9090
+//	+ $.server.tls: true
+//	- $.debug: false
+//
+// The report is empty if the documents are equal. If they cannot be
+// compared, the report describes the error instead
+func DiffReport(a, b Context, options ...EqualOption) string {
+	changes, err := Diff(a, b, options...)
+	if err != nil {
+		return fmt.Sprintf("failed to compare documents: %s\n", err)
+	}
+
+	var sb strings.Builder
+	for _, change := range changes {
+		sb.WriteString(change.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// differ collects the changes between two documents for Diff
+type differ struct {
+	*equalizer
+	a, b    *ctx
+	changes []Change
+}
+
+func (d *differ) report(kind ChangeKind, path string, before, after interface{}) {
+	change := Change{Kind: kind, Path: path}
+	if kind != ChangeAdded {
+		change.Before = d.a.detached(before)
+	}
+	if kind != ChangeRemoved {
+		change.After = d.b.detached(after)
+	}
+	d.changes = append(d.changes, change)
+}
+
+// diff records the changes between the values a and b found at path
+func (d *differ) diff(path string, a, b interface{}, ignore *projection) {
+	ma, oka := toModel(a)
+	mb, okb := toModel(b)
+	if !oka || !okb {
+		// values that cannot be encoded are never equal
+		d.report(ChangeModified, path, a, b)
+		return
+	}
+
+	switch ma := ma.(type) {
+	case map[string]interface{}:
+		mb, ok := mb.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make(map[string]struct{}, len(ma)+len(mb))
+		for key := range ma {
+			keys[key] = struct{}{}
+		}
+		for key := range mb {
+			keys[key] = struct{}{}
+		}
+		for _, keyV := range sortedMapKeys(reflect.ValueOf(keys)) {
+			key := keyV.String()
+			child := ignore.field(key)
+			if child == nil {
+				continue
+			}
+			va, inA := ma[key]
+			vb, inB := mb[key]
+			switch {
+			case !inA:
+				d.report(ChangeAdded, keyPath(path, key), nil, vb)
+			case !inB:
+				d.report(ChangeRemoved, keyPath(path, key), va, nil)
+			default:
+				d.diff(keyPath(path, key), va, vb, child)
+			}
+		}
+		return
+	case []interface{}:
+		mb, ok := mb.([]interface{})
+		if !ok {
+			break
+		}
+		if d.ignoreArrayOrder {
+			d.diffUnordered(path, ma, mb, ignore)
+			return
+		}
+		for i := 0; i < len(ma) || i < len(mb); i++ {
+			child := ignore.element(i)
+			if child == nil {
+				continue
+			}
+			switch {
+			case i >= len(ma):
+				d.report(ChangeAdded, indexPath(path, i), nil, mb[i])
+			case i >= len(mb):
+				d.report(ChangeRemoved, indexPath(path, i), ma[i], nil)
+			default:
+				d.diff(indexPath(path, i), ma[i], mb[i], child)
+			}
+		}
+		return
+	}
+
+	if !d.equal(ma, mb, ignore) {
+		d.report(ChangeModified, path, a, b)
+	}
+}
+
+// diffUnordered records the elements of a and b, found at path, that
+// cannot be matched with an equal element of the other array
+func (d *differ) diffUnordered(path string, a, b []interface{}, ignore *projection) {
+	child := unprojected
+	if ignore.hasEvery {
+		if ignore.every == nil {
+			return
+		}
+		child = ignore.every
+	}
+
+	used := make([]bool, len(b))
+	var removed []int
+	for i, av := range a {
+		found := false
+		for j, bv := range b {
+			if used[j] || !d.equal(av, bv, child) {
+				continue
+			}
+			used[j] = true
+			found = true
+			break
+		}
+		if !found {
+			removed = append(removed, i)
+		}
+	}
+	for _, i := range removed {
+		d.report(ChangeRemoved, indexPath(path, i), a[i], nil)
+	}
+	for j, bv := range b {
+		if !used[j] {
+			d.report(ChangeAdded, indexPath(path, j), nil, bv)
+		}
+	}
+}
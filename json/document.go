@@ -0,0 +1,49 @@
+package json
+
+import (
+	"fmt"
+)
+
+// Document holds a Context, and can be used as the type of struct
+// fields that capture arbitrary JSON values when unmarshaling with
+// encoding/json:
+//
+//	type Event struct {
+//	  Type    string        `json:"type"`
+//	  Payload json.Document `json:"payload"`
+//	}
+//
+// A Document can be used wherever a Context is expected. The zero
+// Document holds no Context, and calling the methods of Context on it
+// panics, so it must be checked with IsZero (or by comparing the
+// embedded Context with nil) before use
+type Document struct {
+	Context
+}
+
+// IsZero reports whether d holds no Context, which is the case if the
+// field was absent from the input. It makes Document fields compatible
+// with the omitzero option of encoding/json
+func (d Document) IsZero() bool {
+	return d.Context == nil
+}
+
+// MarshalJSON returns the JSON encoding of the value pointed by the
+// Context, or `null` if d holds no Context
+func (d Document) MarshalJSON() ([]byte, error) {
+	if d.Context == nil {
+		return []byte(`null`), nil
+	}
+	return d.Context.MarshalJSON()
+}
+
+// UnmarshalJSON parses data with the default options, and stores the
+// resulting Context in d
+func (d *Document) UnmarshalJSON(data []byte) error {
+	c, err := Parse(data)
+	if err != nil {
+		return fmt.Errorf(`failed to unmarshal Document: %w`, err)
+	}
+	d.Context = c
+	return nil
+}
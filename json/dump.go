@@ -0,0 +1,201 @@
+package json
+
+import (
+	stdlib "encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ANSI escape sequences used by Dump when WithColor is specified
+const (
+	colorReset   = "\x1b[0m"
+	colorKey     = "\x1b[34m"
+	colorString  = "\x1b[32m"
+	colorNumber  = "\x1b[36m"
+	colorBool    = "\x1b[33m"
+	colorNull    = "\x1b[1;35m"
+	colorComment = "\x1b[90m"
+)
+
+// dumper writes the human readable representation of a value. See
+// Context.Dump
+type dumper struct {
+	e        encodeState
+	color    bool
+	annotate bool
+	// lazy is used to decode the deferred values of the document
+	lazy *parseConfig
+}
+
+func newDumper(w io.Writer, c *ctx, options []DumpOption) *dumper {
+	d := &dumper{
+		e: encodeState{
+			// the output is meant for humans, so values that cannot be
+			// represented in JSON are not an error
+			nonFinite:  true,
+			order:      c.order,
+			timeFormat: c.timeFormat,
+			marshalers: c.marshalers,
+			indented:   true,
+			indent:     "  ",
+			w:          w,
+		},
+		lazy: c.lazy,
+	}
+	for _, option := range options {
+		switch option.Name() {
+		case optKeyColor:
+			d.color = option.Value().(bool)
+		case optKeyTypeAnnotations:
+			d.annotate = option.Value().(bool)
+		}
+	}
+	return d
+}
+
+func (d *dumper) setColor(color string) {
+	if d.color {
+		d.e.buf.WriteString(color)
+	}
+}
+
+func (d *dumper) resetColor() {
+	if d.color {
+		d.e.buf.WriteString(colorReset)
+	}
+}
+
+// comment writes the type annotation for a value, if requested
+func (d *dumper) comment(format string, args ...interface{}) {
+	if !d.annotate {
+		return
+	}
+	d.e.buf.WriteByte(' ')
+	d.setColor(colorComment)
+	d.e.buf.WriteString(`// `)
+	fmt.Fprintf(&d.e.buf, format, args...)
+	d.resetColor()
+}
+
+// separator writes the comma following a value, unless it is the
+// last one in its container
+func (d *dumper) separator(last bool) {
+	if !last {
+		d.e.buf.WriteByte(',')
+	}
+}
+
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+// dump writes v, followed by a comma unless last is true. Annotations
+// are written after the comma, so that the output remains valid JSONC
+// when colors are not enabled
+func (d *dumper) dump(v interface{}, last bool) error {
+	if raw, ok := v.(rawValue); ok && d.lazy != nil {
+		decoded, err := resolveAll(raw, d.lazy)
+		if err != nil {
+			return fmt.Errorf(`failed to decode deferred value: %w`, err)
+		}
+		v = decoded
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := d.e.mapKeys(v)
+		d.e.buf.WriteByte('{')
+		if len(keys) == 0 {
+			d.e.buf.WriteByte('}')
+			d.separator(last)
+			d.comment(`object, 0 fields`)
+			return nil
+		}
+		d.comment(`object, %d %s`, len(keys), plural(len(keys), `field`, `fields`))
+		d.e.depth++
+		for i, key := range keys {
+			d.e.newline()
+			d.setColor(colorKey)
+			d.e.writeString(key)
+			d.resetColor()
+			d.e.buf.WriteString(`: `)
+			if err := d.dump(v[key], i == len(keys)-1); err != nil {
+				return err
+			}
+			if err := d.e.flush(false); err != nil {
+				return err
+			}
+		}
+		d.e.depth--
+		d.e.newline()
+		d.e.buf.WriteByte('}')
+		d.separator(last)
+		return nil
+	case []interface{}:
+		d.e.buf.WriteByte('[')
+		if len(v) == 0 {
+			d.e.buf.WriteByte(']')
+			d.separator(last)
+			d.comment(`array, 0 elements`)
+			return nil
+		}
+		d.comment(`array, %d %s`, len(v), plural(len(v), `element`, `elements`))
+		d.e.depth++
+		for i, elem := range v {
+			d.e.newline()
+			if err := d.dump(elem, i == len(v)-1); err != nil {
+				return err
+			}
+			if err := d.e.flush(false); err != nil {
+				return err
+			}
+		}
+		d.e.depth--
+		d.e.newline()
+		d.e.buf.WriteByte(']')
+		d.separator(last)
+		return nil
+	}
+
+	var color, kind string
+	switch v.(type) {
+	case nil:
+		color, kind = colorNull, `null`
+	case string:
+		color, kind = colorString, `string`
+	case bool:
+		color, kind = colorBool, `bool`
+	case stdlib.Number, Number, float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		color, kind = colorNumber, `number`
+	default:
+		// values that are not part of the document tree are annotated
+		// with their Go type
+		kind = reflect.TypeOf(v).String()
+	}
+
+	if color != "" {
+		d.setColor(color)
+	}
+	if err := d.e.encode(v); err != nil {
+		return err
+	}
+	if color != "" {
+		d.resetColor()
+	}
+	d.separator(last)
+	d.comment(`%s`, kind)
+	return nil
+}
+
+func (c *ctx) Dump(w io.Writer, options ...DumpOption) error {
+	d := newDumper(w, c, options)
+	if err := d.dump(c.interfaceValue(), true); err != nil {
+		return err
+	}
+	d.e.buf.WriteByte('\n')
+	return d.e.flush(true)
+}
@@ -0,0 +1,304 @@
+package json
+
+import (
+	stdlib "encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// Equal reports whether the documents held by a and b are semantically
+// equal: the order of the fields of objects is irrelevant, and numbers
+// are compared by value regardless of their types and literal forms,
+// so that 1, 1.0, and json.Number("1") are equal. Values held by types
+// other than those of the JSON model, such as structs, are compared by
+// their JSON encoding.
+//
+// The comparison can be relaxed with WithFloatTolerance,
+// WithIgnoreArrayOrder, and WithIgnoredPaths. Invalid Contexts are not
+// equal to anything, including each other
+func Equal(a, b Context, options ...EqualOption) bool {
+	a, unlockA := unwrapSync(a)
+	defer unlockA()
+	b, unlockB := unwrapSync(b, syncMutex(a))
+	defer unlockB()
+
+	ca, ok := a.(*ctx)
+	if !ok {
+		return false
+	}
+	cb, ok := b.(*ctx)
+	if !ok {
+		return false
+	}
+
+	cmp, ignore, err := newEqualizer(options)
+	if err != nil {
+		return false
+	}
+	if ignore == nil {
+		// the whole document is ignored
+		return true
+	}
+	va, err := ca.modelValue()
+	if err != nil {
+		return false
+	}
+	vb, err := cb.modelValue()
+	if err != nil {
+		return false
+	}
+	return cmp.equal(va, vb, ignore)
+}
+
+// modelValue returns the value held by c, where deferred values have
+// been decoded. An error is returned if they fail to decode
+func (c *ctx) modelValue() (interface{}, error) {
+	v := c.interfaceValue()
+	if c.lazy == nil {
+		return v, nil
+	}
+	v, err := resolveAll(v, c.lazy)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to decode deferred values in %s: %w`, c.location(), err)
+	}
+	return v, nil
+}
+
+// equalizer compares documents for Equal
+type equalizer struct {
+	tolerance        float64
+	ignoreArrayOrder bool
+}
+
+// newEqualizer creates an equalizer configured by options, and returns
+// it along with the projection of the ignored values, which is nil if
+// the whole document is ignored
+func newEqualizer(options []EqualOption) (*equalizer, *projection, error) {
+	cmp := &equalizer{}
+	var ignored []string
+	for _, option := range options {
+		switch option.Name() {
+		case optKeyFloatTolerance:
+			cmp.tolerance = option.Value().(float64)
+		case optKeyIgnoreArrayOrder:
+			cmp.ignoreArrayOrder = option.Value().(bool)
+		case optKeyIgnoredPaths:
+			ignored = append(ignored, option.Value().([]string)...)
+		}
+	}
+
+	if len(ignored) == 0 {
+		return cmp, unprojected, nil
+	}
+	ignore, err := newProjection(ignored)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`invalid ignored paths: %w`, err)
+	}
+	return cmp, ignore, nil
+}
+
+// equal reports whether a and b are equal, ignoring the values found
+// at the paths of ignore. ignore is unprojected if no values are ignored
+func (cmp *equalizer) equal(a, b interface{}, ignore *projection) bool {
+	a, ok := toModel(a)
+	if !ok {
+		return false
+	}
+	b, ok = toModel(b)
+	if !ok {
+		return false
+	}
+
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, av := range a {
+			child := ignore.field(key)
+			if child == nil {
+				continue
+			}
+			bv, ok := b[key]
+			if !ok || !cmp.equal(av, bv, child) {
+				return false
+			}
+		}
+		for key := range b {
+			if _, ok := a[key]; !ok && ignore.field(key) != nil {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok {
+			return false
+		}
+		if cmp.ignoreArrayOrder {
+			return cmp.equalUnordered(a, b, ignore)
+		}
+		for i := 0; i < len(a) || i < len(b); i++ {
+			child := ignore.element(i)
+			if child == nil {
+				continue
+			}
+			if i >= len(a) || i >= len(b) || !cmp.equal(a[i], b[i], child) {
+				return false
+			}
+		}
+		return true
+	case string:
+		b, ok := b.(string)
+		return ok && a == b
+	case bool:
+		b, ok := b.(bool)
+		return ok && a == b
+	case nil:
+		return b == nil
+	}
+	return cmp.numbersEqual(a, b)
+}
+
+// equalUnordered reports whether each element of a can be matched with
+// an equal element of b. Since elements are not compared by position,
+// only the values ignored by `[*]` are ignored
+func (cmp *equalizer) equalUnordered(a, b []interface{}, ignore *projection) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	child := unprojected
+	if ignore.hasEvery {
+		if ignore.every == nil {
+			return true
+		}
+		child = ignore.every
+	}
+
+	used := make([]bool, len(b))
+	for _, av := range a {
+		found := false
+		for j, bv := range b {
+			if used[j] || !cmp.equal(av, bv, child) {
+				continue
+			}
+			used[j] = true
+			found = true
+			break
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (cmp *equalizer) numbersEqual(a, b interface{}) bool {
+	ra, oka := numberRat(a)
+	rb, okb := numberRat(b)
+	if !oka || !okb {
+		// non-finite numbers are only equal to themselves
+		la, oka := numberLiteral(a)
+		lb, okb := numberLiteral(b)
+		return oka && okb && la == lb
+	}
+	if ra.Cmp(rb) == 0 {
+		return true
+	}
+	if cmp.tolerance <= 0 {
+		return false
+	}
+	fa, _ := ra.Float64()
+	fb, _ := rb.Float64()
+	return math.Abs(fa-fb) <= cmp.tolerance
+}
+
+// toModel returns v if it is one of the values of the JSON model, and
+// converts it by encoding and parsing it again otherwise
+func toModel(v interface{}) (interface{}, bool) {
+	switch v.(type) {
+	case nil, bool, string, stdlib.Number, Number, float64, int64, map[string]interface{}, []interface{}:
+		return v, true
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32:
+		return v, true
+	}
+
+	buf, err := New(v).MarshalJSON()
+	if err != nil {
+		return nil, false
+	}
+	c, err := Parse(buf, WithNonFiniteNumbers(true))
+	if err != nil {
+		return nil, false
+	}
+	return c.(*ctx).interfaceValue(), true
+}
+
+// numberLiteral returns the literal form of the number v
+func numberLiteral(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case stdlib.Number:
+		return string(v), true
+	case Number:
+		b, err := v.MarshalJSON()
+		if err != nil {
+			return "", false
+		}
+		return string(b), true
+	}
+	switch f, ok := numberFloat(v); {
+	case !ok:
+		return "", false
+	case math.IsNaN(f):
+		return `NaN`, true
+	case math.IsInf(f, 1):
+		return `Infinity`, true
+	case math.IsInf(f, -1):
+		return `-Infinity`, true
+	}
+	return "", false
+}
+
+// numberFloat returns v as a float64 if it holds a floating point number
+func numberFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// numberRat returns the value of the finite number v
+func numberRat(v interface{}) (*big.Rat, bool) {
+	if s, ok := numberLiteral(v); ok {
+		if _, isFloat := numberFloat(v); !isFloat {
+			return new(big.Rat).SetString(s)
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Rat).SetUint64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		// floating point numbers stand for the shortest decimal number
+		// that identifies them, which is how they are encoded
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, rv.Type().Bits()))
+	}
+	return nil, false
+}
@@ -1,29 +1,382 @@
 package json
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"reflect"
+	"unicode/utf8"
+)
+
+var (
+	// ErrKeyNotFound is wrapped by the errors returned when a field
+	// that does not exist is accessed, such as by MapIndex
+	ErrKeyNotFound = errors.New(`key not found`)
+	// ErrIndexOutOfRange is wrapped by the errors returned when an
+	// element beyond the bounds of an array is accessed, such as by Index
+	ErrIndexOutOfRange = errors.New(`index out of range`)
+	// ErrTypeMismatch is wrapped by the errors returned when a value is
+	// not of the type required by the operation, such as when MapIndex
+	// is called on an array, or String on a number
+	ErrTypeMismatch = errors.New(`type mismatch`)
+	// ErrNotAssignable is wrapped by the errors returned when a value
+	// cannot be assigned to the destination given to an accessor, such
+	// as when String is given a pointer to an int
+	ErrNotAssignable = errors.New(`value not assignable`)
+	// ErrInvalidDestination is wrapped by the errors returned when the
+	// destination given to an accessor is nil, or is not a pointer
+	ErrInvalidDestination = errors.New(`invalid destination`)
+	// ErrReadOnly is wrapped by the errors returned when Set or
+	// SetMapIndex is applied to a Context pointing to a Version
+	ErrReadOnly = errors.New(`read-only value`)
+)
+
+// AccessError is the type of the errors returned by navigation and
+// accessors, such as MapIndex and String. It records the location of
+// the value that the failed operation was applied to, and wraps one of
+// the errors above, which describes the kind of the failure
+type AccessError struct {
+	path string
+	kind error
+	// err holds the message, and wraps the error that caused the
+	// failure, if any
+	err error
+}
+
+func newAccessError(kind error, format string, args ...interface{}) error {
+	return newPathError("", kind, format, args...)
+}
+
+func newPathError(path string, kind error, format string, args ...interface{}) error {
+	return &AccessError{path: path, kind: kind, err: fmt.Errorf(format, args...)}
+}
+
+// Path returns the path of the value that the failed operation was
+// applied to, in the same notation as Walk, such as `$.items[3]`
+func (e *AccessError) Path() string {
+	return e.path
+}
+
+// Kind returns the error describing the kind of the failure, which is
+// one of ErrKeyNotFound, ErrIndexOutOfRange, ErrTypeMismatch,
+// ErrNotAssignable, and ErrInvalidDestination
+func (e *AccessError) Kind() error {
+	return e.kind
+}
+
+func (e *AccessError) Error() string {
+	if e.path == "" {
+		return e.err.Error()
+	}
+	return `at ` + e.path + `: ` + e.err.Error()
+}
+
+func (e *AccessError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// TypeError describes a value that is not of the kind required by the
+// operation applied to it, such as String applied to an object. It is
+// wrapped by the AccessError returned by the operation, and can be
+// retrieved using errors.As
+type TypeError struct {
+	// Expected is the kind of value required by the operation. It is
+	// InvalidValue if the operation accepts several kinds, or if the
+	// destination given to an accessor requires a specific Go type
+	// rather than a specific kind
+	Expected ValueKind
+	// Actual is the kind of the value
+	Actual ValueKind
+	// Path is the path of the value. It may differ from the path of
+	// the AccessError, such as when an element of an array cannot be
+	// assigned to the destination given to Slice
+	Path string
+	// Excerpt holds the beginning of the JSON encoding of the value.
+	// It is empty if the value is not available, such as for objects
+	// and arrays found by GetBytes
+	Excerpt string
+}
+
+func newTypeError(expected ValueKind, v interface{}) *TypeError {
+	return &TypeError{Expected: expected, Actual: kindOf(v), Excerpt: excerpt(v)}
+}
+
+func (e *TypeError) Error() string {
+	found := e.Actual.String()
+	if e.Excerpt != "" {
+		found += ` ` + e.Excerpt
+	}
+	if e.Expected == InvalidValue {
+		return `unexpected ` + found
+	}
+	return `expected ` + e.Expected.String() + `, found ` + found
+}
+
+// maxExcerptLength is the maximum length of TypeError.Excerpt
+const maxExcerptLength = 2 * excerptContext
+
+// errExcerptComplete stops the encoding of the value being excerpted
+var errExcerptComplete = errors.New(`excerpt complete`)
+
+// excerptWriter keeps the beginning of the output of an encodeState,
+// and fails afterwards so that the encoding of large values stops early
+type excerptWriter struct {
+	buf []byte
+}
+
+func (w *excerptWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p[:min(len(p), maxExcerptLength+1)]...)
+	return 0, errExcerptComplete
+}
+
+// excerpt returns the beginning of the JSON encoding of v
+func excerpt(v interface{}) string {
+	var w excerptWriter
+	e := getEncodeState()
+	defer releaseEncodeState(e)
+	e.nonFinite = true
+	e.w = &w
+	if err := e.encode(v); err != nil && !errors.Is(err, errExcerptComplete) {
+		return fmt.Sprintf(`%v`, v)
+	}
+
+	b := w.buf
+	if b == nil {
+		b = e.buf.Bytes()
+	}
+	if len(b) <= maxExcerptLength {
+		return string(b)
+	}
+	n := maxExcerptLength
+	for n > 0 && !utf8.RuneStart(b[n]) {
+		n--
+	}
+	return string(b[:n]) + `...`
+}
+
+// typeError creates an AccessError for an operation that requires a
+// value of the expected kind, applied to the value pointed by c
+func (c *ctx) typeError(expected ValueKind, format string, args ...interface{}) error {
+	te := newTypeError(expected, c.interfaceValue())
+	te.Path = c.location()
+	return c.observeError(newPathError(te.Path, ErrTypeMismatch, format+`: %w`, append(args, te)...))
+}
+
+// valueTypeError is the same as typeError, for a value v that does not
+// belong to a Context. The path of the TypeError is relative to the
+// value that the accessor returning the error is applied to
+func valueTypeError(expected ValueKind, path string, v interface{}, format string, args ...interface{}) error {
+	te := newTypeError(expected, v)
+	te.Path = path
+	return newAccessError(ErrTypeMismatch, format+`: %w`, append(args, te)...)
+}
+
+// assignError creates the error returned when v cannot be assigned to
+// a destination of type t. The kind of value that t requires is only
+// reported if it differs from that of v
+func assignError(t reflect.Type, path string, v interface{}, format string, args ...interface{}) error {
+	expected := kindOfType(t)
+	if expected == kindOf(v) {
+		expected = InvalidValue
+	}
+	return valueTypeError(expected, path, v, format, args...)
+}
+
+// checkDestination returns an error if dst, the destination given to
+// an accessor, cannot receive a value because it is nil or is not a
+// pointer. want describes the types that dst may point to
+func checkDestination(dst interface{}, want string) error {
+	if dst == nil {
+		return newAccessError(ErrInvalidDestination, `destination is nil: pass a pointer to %s, such as &v`, want)
+	}
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer {
+		return newAccessError(ErrInvalidDestination, `destination must be a pointer to %s, but %T was given: pass &v instead of v`, want, dst)
+	}
+	if rv.IsNil() {
+		return newAccessError(ErrInvalidDestination, `destination is a nil %T: pass a pointer to a variable, such as &v or new(%s)`, dst, rv.Type().Elem())
+	}
+	return nil
+}
+
+// accessError creates an AccessError for an operation applied to the
+// value pointed by c
+func (c *ctx) accessError(kind error, format string, args ...interface{}) error {
+	return c.observeError(newPathError(c.location(), kind, format, args...))
+}
+
+// annotate records the path of the value pointed by c in *err, if it
+// is an AccessError created without one. It is meant to be deferred
+// by accessors, whose errors may originate from functions that do not
+// know about c
+func (c *ctx) annotate(err *error) {
+	e, ok := (*err).(*AccessError)
+	if !ok || e.path != "" {
+		return
+	}
+	e.path = c.location()
+
+	var te *TypeError
+	if errors.As(e.err, &te) {
+		te.Path = e.path + te.Path
+	}
+	c.observeError(e)
+}
+
+// location returns the path of the value pointed by c, relative to the
+// Context that it was derived from via MapIndex, Index, etc.
+func (c *ctx) location() string {
+	switch {
+	case c.locations != nil:
+		return c.path
+	case c.parent == nil:
+		return rootPath
+	case c.inMap:
+		return keyPath(c.parent.location(), c.key)
+	}
+	return indexPath(c.parent.location(), c.index)
+}
+
+func (c errCtx) ApplyDefaults(_ Context) Context {
+	return c
+}
+
+func (c errCtx) Avg() Context {
+	return c
+}
+
 func (c errCtx) Bool(_ interface{}) error {
+	c.traceSkipped(`Bool`)
+	return c.err
+}
+
+func (c errCtx) BuildIndex(_ string) error {
+	return c.err
+}
+
+func (c errCtx) Bytes(_ interface{}) error {
+	c.traceSkipped(`Bytes`)
+	return c.err
+}
+
+func (c errCtx) Count(_ func(Context) bool) (int, error) {
+	return 0, c.err
+}
+
+func (c errCtx) Dump(_ io.Writer, _ ...DumpOption) error {
+	return c.err
+}
+
+func (c errCtx) Elements() iter.Seq2[int, Context] {
+	return func(func(int, Context) bool) {}
+}
+
+func (c errCtx) Entries() iter.Seq2[string, Context] {
+	return func(func(string, Context) bool) {}
+}
+
+func (c errCtx) Err() error {
 	return c.err
 }
 
+func (c errCtx) ExpandEnv(_ func(string) (string, bool), _ ...ExpandOption) Context {
+	return c
+}
+
+func (c errCtx) FindBy(_ string, _ interface{}) Context {
+	return c
+}
+
+func (c errCtx) FilterElems(_ func(Context) bool) Context {
+	return c
+}
+
 func (c errCtx) Float(_ interface{}) error {
+	c.traceSkipped(`Float`)
 	return c.err
 }
 
+func (c errCtx) ForEach(_ func(string, int, Context) bool) error {
+	return c.err
+}
+
+func (c errCtx) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		io.WriteString(f, c.GoString())
+		return
+	}
+	formatError(f, c.err)
+}
+
+func (c errCtx) GoString() string {
+	return fmt.Sprintf("json.Context(error: %q)", c.err.Error())
+}
+
 func (c errCtx) Index(_ int) Context {
+	c.traceSkipped(`Index`)
 	return c
 }
 
+func (c errCtx) GroupBy(_ string, _ ...GroupOption) (map[string]Context, error) {
+	return nil, c.err
+}
+
 func (c errCtx) Int(_ interface{}) error {
+	c.traceSkipped(`Int`)
 	return c.err
 }
 
+func (c errCtx) Location() (Location, error) {
+	return Location{}, c.err
+}
+
+func (c errCtx) MarshalAppend(buf []byte) ([]byte, error) {
+	return buf, c.err
+}
+
+func (c errCtx) MarshalIndent(_, _ string) ([]byte, error) {
+	return nil, c.err
+}
+
 func (c errCtx) Map(_ interface{}) error {
+	c.traceSkipped(`Map`)
 	return c.err
 }
 
+func (c errCtx) MapElems(_ func(Context) (interface{}, error)) Context {
+	return c
+}
+
+func (c errCtx) Max() Context {
+	return c
+}
+
+func (c errCtx) Min() Context {
+	return c
+}
+
 func (c errCtx) MapIndex(_ string) Context {
+	c.traceSkipped(`MapIndex`)
 	return c
 }
 
+func (c errCtx) OnChange(_ ChangeFunc) func() {
+	return func() {}
+}
+
+func (c errCtx) Pretty() ([]byte, error) {
+	return nil, c.err
+}
+
+func (c errCtx) Reduce(_ func(acc, elem Context) (interface{}, error), _ interface{}) Context {
+	return c
+}
+
+func (c errCtx) Release() {}
+
 func (c errCtx) Set(_ interface{}) Context {
 	return c
 }
@@ -32,14 +385,45 @@ func (c errCtx) SetMapIndex(_ string, _ interface{}) Context {
 	return c
 }
 
+func (c errCtx) SizeEstimate() int64 {
+	return 0
+}
+
 func (c errCtx) Slice(_ interface{}) error {
+	c.traceSkipped(`Slice`)
 	return c.err
 }
 
+func (c errCtx) StreamElements(_ context.Context) (<-chan Context, <-chan error) {
+	return errorStream(c.err)
+}
+
 func (c errCtx) String(_ interface{}) error {
+	c.traceSkipped(`String`)
+	return c.err
+}
+
+func (c errCtx) Substitute(_ interface{}, _ ...ExpandOption) Context {
+	return c
+}
+
+func (c errCtx) Sum() Context {
+	return c
+}
+
+func (c errCtx) Trace(logger *slog.Logger) Context {
+	c.tracer = logger
+	return c
+}
+
+func (c errCtx) Walk(_ WalkFunc) error {
 	return c.err
 }
 
+func (c errCtx) WriteTo(_ io.Writer) (int64, error) {
+	return 0, c.err
+}
+
 func (c errCtx) MarshalJSON() ([]byte, error) {
 	return nil, c.err
 }
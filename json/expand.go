@@ -0,0 +1,174 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MissingVariablePolicy specifies how placeholders referring to
+// undefined variables are handled by ExpandEnv and Substitute.
+// See WithMissingVariables
+type MissingVariablePolicy int
+
+const (
+	// MissingVariableError makes ExpandEnv and Substitute return an
+	// invalid Context whose error wraps ErrMissingVariable. This is the default
+	MissingVariableError MissingVariablePolicy = iota
+	// MissingVariableEmpty replaces the placeholder with an empty string
+	MissingVariableEmpty
+	// MissingVariableKeep leaves the placeholder as is
+	MissingVariableKeep
+)
+
+// ErrMissingVariable is wrapped by the error returned when ExpandEnv
+// or Substitute finds a placeholder referring to an undefined variable
+var ErrMissingVariable = errors.New(`missing variable`)
+
+func (c *ctx) ExpandEnv(lookup func(string) (string, bool), options ...ExpandOption) Context {
+	policy := MissingVariableError
+	for _, option := range options {
+		switch option.Name() {
+		case optKeyMissingVariables:
+			policy = option.Value().(MissingVariablePolicy)
+		}
+	}
+
+	return c.rewrite(func(s string, _ *keyOrder) (interface{}, error) {
+		return expandString(s, lookup, policy)
+	})
+}
+
+// expandString substitutes the placeholders in s
+func expandString(s string, lookup func(string) (string, bool), policy MissingVariablePolicy) (string, error) {
+	i := strings.IndexByte(s, '$')
+	if i < 0 {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	for i >= 0 {
+		sb.WriteString(s[:i])
+		s = s[i:]
+		switch {
+		case strings.HasPrefix(s, `$${`):
+			sb.WriteString(`${`)
+			s = s[3:]
+		case strings.HasPrefix(s, `${`):
+			end := strings.IndexByte(s, '}')
+			if end < 0 {
+				return "", fmt.Errorf(`unterminated placeholder in %q`, s)
+			}
+			placeholder := s[:end+1]
+			name, fallback, hasFallback := strings.Cut(s[2:end], `:-`)
+			if !isIdentifier(name) {
+				return "", fmt.Errorf(`invalid variable name in placeholder %q`, placeholder)
+			}
+			s = s[end+1:]
+
+			value, ok := lookup(name)
+			if hasFallback && (!ok || value == "") {
+				value, ok = fallback, true
+			}
+			if !ok {
+				switch policy {
+				case MissingVariableEmpty:
+				case MissingVariableKeep:
+					value = placeholder
+				default:
+					return "", fmt.Errorf(`%q: %w`, name, ErrMissingVariable)
+				}
+			}
+			sb.WriteString(value)
+		default:
+			sb.WriteByte('$')
+			s = s[1:]
+		}
+		i = strings.IndexByte(s, '$')
+	}
+	sb.WriteString(s)
+	return sb.String(), nil
+}
+
+// rewriteFunc returns the value that replaces s. If the value contains
+// JSON objects, their keys must be recorded in order, if it is non-nil
+type rewriteFunc func(s string, order *keyOrder) (interface{}, error)
+
+// rewrite returns a new Context pointing to a copy of the value held
+// by c, in which each string has been replaced by the value returned
+// by fn. Only JSON objects and arrays are copied, and other values are
+// shared with c. If fn fails, the returned Context is invalid
+func (c *ctx) rewrite(fn rewriteFunc) Context {
+	v, err := c.modelValue()
+	if err != nil {
+		return newErrCtx(err)
+	}
+
+	c2 := &ctx{
+		nonFinite:     c.nonFinite,
+		strictNumbers: c.strictNumbers,
+		timeFormat:    c.timeFormat,
+		marshalers:    c.marshalers,
+		generation:    new(uint64),
+	}
+	if c.order != nil {
+		c2.order = newKeyOrder()
+	}
+
+	v, err = c.rewriteValue(c2.order, rootPath, v, fn)
+	if err != nil {
+		return newErrCtx(err)
+	}
+	c2.value = reflect.ValueOf(v)
+	if c2.order != nil {
+		c2.order.attach(c2)
+	}
+	return c2
+}
+
+func (c *ctx) rewriteValue(order *keyOrder, path string, v interface{}, fn rewriteFunc) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		v2, err := fn(v, order)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to rewrite value at %s: %w`, path, err)
+		}
+		return v2, nil
+	case map[string]interface{}:
+		rv := reflect.ValueOf(v)
+		var keys []reflect.Value
+		if c.order != nil {
+			keys = c.order.mapKeys(rv)
+		} else {
+			keys = sortedMapKeys(rv)
+		}
+
+		m := make(map[string]interface{}, len(v))
+		names := make([]string, len(keys))
+		for i, keyV := range keys {
+			key := keyV.String()
+			elem, err := c.rewriteValue(order, keyPath(path, key), v[key], fn)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = elem
+			names[i] = key
+		}
+		if order != nil {
+			order.record(m, names)
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, elem := range v {
+			elem, err := c.rewriteValue(order, indexPath(path, i), elem, fn)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = elem
+		}
+		return l, nil
+	}
+	return v, nil
+}
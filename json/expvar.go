@@ -0,0 +1,55 @@
+package json
+
+import (
+	"strconv"
+	"sync"
+)
+
+// Var exposes a Context as an expvar.Var, so that the document can be
+// published with expvar.Publish and inspected at /debug/vars:
+//
+//	expvar.Publish("config", json.NewVar(cfg))
+//
+// The document is marshaled each time the variable is read, so changes
+// made to the Context are reflected in the output. Since Contexts are
+// not safe for concurrent use, Store should be used to replace the
+// document instead of modifying it while it is published
+type Var struct {
+	mu sync.RWMutex
+	c  Context
+}
+
+// NewVar creates a Var exposing c
+func NewVar(c Context) *Var {
+	return &Var{c: c}
+}
+
+// Load returns the Context exposed by v
+func (v *Var) Load() Context {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.c
+}
+
+// Store replaces the Context exposed by v
+func (v *Var) Store(c Context) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.c = c
+}
+
+// String returns the JSON encoding of the document, which implements
+// expvar.Var. If the document cannot be marshaled, the error message
+// is returned as a JSON string, since the output must be valid JSON
+func (v *Var) String() string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.c == nil {
+		return `null`
+	}
+	buf, err := v.c.MarshalJSON()
+	if err != nil {
+		return strconv.Quote(`error: ` + err.Error())
+	}
+	return string(buf)
+}
@@ -0,0 +1,221 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoDocument is returned by Feeder.Document when there are no
+// complete documents available
+var ErrNoDocument = errors.New(`no complete JSON document available`)
+
+// Feeder is a push-style parser. Chunks of input are fed via Write,
+// and complete top-level JSON values become available via Document
+// as soon as their last byte has been written.
+//
+// The Feeder only tracks the boundaries of the values: syntax errors
+// are reported when the value is parsed by Document.
+//
+// Top-level values other than objects, arrays, and strings (such as
+// numbers) can only be determined to be complete when a delimiter
+// (e.g. whitespace) follows them, or when Close is called
+type Feeder struct {
+	buf       []byte
+	pos       int // next byte to scan in buf
+	start     int // start of the current value in buf, or -1
+	depth     int
+	inString  bool
+	escaped   bool
+	scalar    bool
+	documents [][]byte
+	options   []ParseOption
+	maxDepth  int
+	maxSize   int64
+	// err is the error that stopped the Feeder, returned by all
+	// subsequent calls to Write
+	err error
+}
+
+// NewFeeder creates a new Feeder. The options are used to parse the
+// documents returned by Document.
+//
+// WithMaxSize and WithMaxDepth are also enforced while the input is
+// buffered, so that a value exceeding them is rejected by Write before
+// it is complete. WithMaxSize then limits the size of each document,
+// rather than that of the entire input
+func NewFeeder(options ...ParseOption) *Feeder {
+	cfg := newParseConfig(options)
+	return &Feeder{
+		start:    -1,
+		options:  options,
+		maxDepth: cfg.maxDepth,
+		maxSize:  cfg.maxSize,
+	}
+}
+
+// Write feeds a chunk of input to the Feeder. If the value being
+// buffered exceeds the limits specified by WithMaxSize or WithMaxDepth,
+// an error wrapping ErrLimitExceeded is returned, and the rest of the
+// input is discarded: the documents completed before the error remain
+// available via Document, but subsequent calls to Write fail
+func (f *Feeder) Write(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.buf = append(f.buf, p...)
+	if err := f.scan(); err != nil {
+		f.err = err
+		f.buf = nil
+		f.pos = 0
+		f.start = -1
+		f.scalar = false
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close signals the end of the input. If a top-level value that
+// is only terminated by the end of input (such as a number) is pending,
+// it becomes available via Document
+func (f *Feeder) Close() error {
+	if f.scalar {
+		f.complete(f.pos)
+		f.compact()
+	}
+	return nil
+}
+
+// Ready reports whether a complete document is available
+func (f *Feeder) Ready() bool {
+	return len(f.documents) > 0
+}
+
+// Document parses and returns the next complete document. If no
+// complete documents are available, ErrNoDocument is returned
+func (f *Feeder) Document() (Context, error) {
+	if len(f.documents) == 0 {
+		return nil, ErrNoDocument
+	}
+
+	data := f.documents[0]
+	f.documents[0] = nil
+	f.documents = f.documents[1:]
+	return Parse(data, f.options...)
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+func (f *Feeder) complete(end int) {
+	doc := make([]byte, end-f.start)
+	copy(doc, f.buf[f.start:end])
+	f.documents = append(f.documents, doc)
+	f.start = -1
+	f.scalar = false
+}
+
+func (f *Feeder) scan() error {
+	for ; f.pos < len(f.buf); f.pos++ {
+		b := f.buf[f.pos]
+
+		if f.start < 0 {
+			if isSpace(b) {
+				continue
+			}
+			f.start = f.pos
+			if err := f.checkSize(); err != nil {
+				return err
+			}
+			switch b {
+			case '{', '[':
+				f.depth = 1
+			case '"':
+				f.inString = true
+			default:
+				f.scalar = true
+			}
+			continue
+		}
+
+		if !f.scalar || !isDelimiter(b) {
+			if err := f.checkSize(); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case f.inString:
+			switch {
+			case f.escaped:
+				f.escaped = false
+			case b == '\\':
+				f.escaped = true
+			case b == '"':
+				f.inString = false
+				if f.depth == 0 {
+					f.complete(f.pos + 1)
+				}
+			}
+		case f.scalar:
+			if isDelimiter(b) {
+				f.complete(f.pos)
+				if !isSpace(b) {
+					// this byte starts or belongs to the next value,
+					// so it needs to be scanned again
+					f.pos--
+				}
+			}
+		default:
+			switch b {
+			case '"':
+				f.inString = true
+			case '{', '[':
+				f.depth++
+				if f.maxDepth > 0 && f.depth > f.maxDepth {
+					return fmt.Errorf(`document exceeds maximum nesting depth of %d: %w`, f.maxDepth, ErrLimitExceeded)
+				}
+			case '}', ']':
+				f.depth--
+				if f.depth == 0 {
+					f.complete(f.pos + 1)
+				}
+			}
+		}
+	}
+	f.compact()
+	return nil
+}
+
+// checkSize checks that the current value, including the byte at the
+// current position, does not exceed the maximum size
+func (f *Feeder) checkSize() error {
+	if f.maxSize >= 0 && int64(f.pos-f.start+1) > f.maxSize {
+		return fmt.Errorf(`document exceeds maximum size of %d bytes: %w`, f.maxSize, ErrLimitExceeded)
+	}
+	return nil
+}
+
+// isDelimiter reports whether b terminates a top-level value other
+// than an object, array, or string
+func isDelimiter(b byte) bool {
+	switch b {
+	case '{', '}', '[', ']', '"', ',', ':':
+		return true
+	}
+	return isSpace(b)
+}
+
+// compact discards the bytes that have already been processed
+func (f *Feeder) compact() {
+	if f.start < 0 {
+		f.buf = f.buf[:0]
+		f.pos = 0
+		return
+	}
+
+	n := copy(f.buf, f.buf[f.start:])
+	f.buf = f.buf[:n]
+	f.pos -= f.start
+	f.start = 0
+}
@@ -0,0 +1,47 @@
+package json
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// ParseFile parses the JSON value in the file at path. On platforms
+// that support it, the file is memory-mapped instead of being read
+// onto the heap, and the nested objects and arrays are decoded lazily
+// from the mapped memory as they are accessed (see WithLazy).
+//
+// The mapping is released once the returned Context and all Contexts
+// derived from it are no longer reachable. Specifying WithLazy(false)
+// decodes the entire document up front, and releases the mapping
+// before ParseFile returns
+func ParseFile(path string, options ...ParseOption) (Context, error) {
+	options = append([]ParseOption{WithLazy(true)}, options...)
+	cfg := newParseConfig(options)
+	// the strings would refer to the mapping, which may be released
+	// while they are in use
+	cfg.unsafeStrings = false
+
+	m, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.maxSize >= 0 && int64(len(m.data)) > cfg.maxSize {
+		m.close()
+		return nil, fmt.Errorf(`input exceeds maximum size of %d bytes: %w`, cfg.maxSize, ErrLimitExceeded)
+	}
+
+	r := getReader()
+	defer releaseReader(r)
+	r.Reset(m.data)
+
+	c, err := parse(r, m.data, cfg)
+	if err != nil || !cfg.deferred() {
+		m.close()
+		return c, err
+	}
+
+	c.(*ctx).mapping = m
+	runtime.SetFinalizer(m, (*mappedFile).close)
+	return c, nil
+}
@@ -0,0 +1,47 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package json
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mappedFile holds the contents of a file mapped into memory
+type mappedFile struct {
+	data   []byte
+	mapped bool
+}
+
+func mapFile(path string) (*mappedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to open %s: %w`, path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to stat %s: %w`, path, err)
+	}
+
+	// empty files cannot be mapped
+	if info.Size() == 0 {
+		return &mappedFile{}, nil
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to map %s into memory: %w`, path, err)
+	}
+	return &mappedFile{data: data, mapped: true}, nil
+}
+
+func (m *mappedFile) close() error {
+	if !m.mapped {
+		return nil
+	}
+	m.mapped = false
+	return syscall.Munmap(m.data)
+}
@@ -0,0 +1,26 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package json
+
+import (
+	"fmt"
+	"os"
+)
+
+// mappedFile holds the contents of a file. Memory-mapping is not
+// supported on this platform, so the file is read onto the heap
+type mappedFile struct {
+	data []byte
+}
+
+func mapFile(path string) (*mappedFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read %s: %w`, path, err)
+	}
+	return &mappedFile{data: data}, nil
+}
+
+func (m *mappedFile) close() error {
+	return nil
+}
@@ -0,0 +1,80 @@
+package json_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(t *testing.T, name, data string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if !assert.NoError(t, os.WriteFile(path, []byte(data), 0644), `os.WriteFile should succeed`) {
+			t.FailNow()
+		}
+		return path
+	}
+
+	src := `{"items":[` + strings.Repeat(`{"n":1,"tags":["a","b"]},`, 1000) + `{"n":2,"tags":["z"]}],"name":"dump"}`
+	path := writeFile(t, "dump.json", src)
+
+	t.Run("Lazy", func(t *testing.T) {
+		j, err := json.ParseFile(path)
+		if !assert.NoError(t, err, `json.ParseFile should succeed`) {
+			return
+		}
+		// the deferred values must remain accessible after a GC cycle
+		runtime.GC()
+
+		var s string
+		if !assert.NoError(t, j.MapIndex("items").Index(1000).MapIndex("tags").Index(0).String(&s), `String should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "z", s, `value should match`) {
+			return
+		}
+
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, src, string(buf), `json string should match`) {
+			return
+		}
+	})
+	t.Run("Eager", func(t *testing.T) {
+		j, err := json.ParseFile(path, json.WithLazy(false))
+		if !assert.NoError(t, err, `json.ParseFile should succeed`) {
+			return
+		}
+		var n int
+		if !assert.NoError(t, j.MapIndex("items").Index(1000).MapIndex("n").Int(&n), `Int should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 2, n, `value should match`) {
+			return
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := json.ParseFile(filepath.Join(dir, "missing.json")); !assert.Error(t, err, `json.ParseFile should fail for missing files`) {
+			return
+		}
+		if _, err := json.ParseFile(writeFile(t, "empty.json", "")); !assert.Error(t, err, `json.ParseFile should fail for empty files`) {
+			return
+		}
+		if _, err := json.ParseFile(writeFile(t, "broken.json", `{"a":`)); !assert.Error(t, err, `json.ParseFile should fail for invalid JSON`) {
+			return
+		}
+		if _, err := json.ParseFile(path, json.WithMaxSize(16)); !assert.True(t, errors.Is(err, json.ErrLimitExceeded), `json.ParseFile should honor WithMaxSize`) {
+			return
+		}
+	})
+}
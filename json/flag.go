@@ -0,0 +1,75 @@
+package json
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Flag is a flag.Value that parses a JSON document given on the command
+// line, either inline or, if prefixed with `@`, from the named file:
+//
+//	var cfg json.Flag
+//	flag.Var(&cfg, "config", "configuration as JSON, or @file")
+//
+//	$ cmd -config '{"debug":true}'
+//	$ cmd -config @config.json
+//
+// When the flag is specified more than once, the last value wins.
+// The zero Flag is ready to use, and parses with the default options
+type Flag struct {
+	c       Context
+	options []ParseOption
+}
+
+// NewFlag creates a Flag that parses documents with the given options
+func NewFlag(options ...ParseOption) *Flag {
+	return &Flag{options: options}
+}
+
+// Context returns the parsed document, or nil if the flag was not set
+func (f *Flag) Context() Context {
+	return f.c
+}
+
+// Get returns the parsed document, which implements flag.Getter
+func (f *Flag) Get() interface{} {
+	return f.c
+}
+
+// Set parses s, which implements flag.Value
+func (f *Flag) Set(s string) error {
+	var c Context
+	var err error
+	if path, ok := strings.CutPrefix(s, `@`); ok {
+		// the file is read rather than mapped by ParseFile, so that the
+		// document does not depend on the file staying unmodified for
+		// the lifetime of the program
+		var data []byte
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf(`failed to read JSON flag: %w`, err)
+		}
+		c, err = Parse(data, f.options...)
+	} else {
+		c, err = ParseString(s, f.options...)
+	}
+	if err != nil {
+		return fmt.Errorf(`failed to parse JSON flag: %w`, err)
+	}
+	f.c = c
+	return nil
+}
+
+// String returns the compact JSON encoding of the parsed document, or
+// an empty string if the flag was not set, which implements flag.Value
+func (f *Flag) String() string {
+	if f == nil || f.c == nil {
+		return ""
+	}
+	buf, err := f.c.MarshalJSON()
+	if err != nil {
+		return ""
+	}
+	return string(buf)
+}
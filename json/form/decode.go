@@ -0,0 +1,246 @@
+package form
+
+import (
+	stdlib "encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lestrrat-go/json"
+)
+
+// maxDepth is the maximum number of bracketed segments in a key
+const maxDepth = 100
+
+// object is a converted object. keys holds the field names in the
+// order in which they were first assigned
+type object struct {
+	keys   []string
+	values map[string]interface{}
+	// next is the index assigned by the next `[]` segment
+	next int
+}
+
+func newObject() *object {
+	return &object{values: make(map[string]interface{})}
+}
+
+func (o *object) set(key string, v interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+	if i, ok := index(key); ok && i >= o.next && i < math.MaxInt {
+		o.next = i + 1
+	}
+}
+
+// index reports whether key is an array index, and returns its value
+func index(key string) (int, bool) {
+	if key == "" || (len(key) > 1 && key[0] == '0') {
+		return 0, false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < '0' || key[i] > '9' {
+			return 0, false
+		}
+	}
+	i, err := strconv.Atoi(key)
+	if err != nil {
+		return 0, false
+	}
+	return i, true
+}
+
+// splitKey splits key into its segments, such that `a[b][]` becomes
+// "a", "b", and "". Keys that are not well-formed are returned as a
+// single segment
+func splitKey(key string) []string {
+	i := strings.IndexByte(key, '[')
+	if i <= 0 {
+		return []string{key}
+	}
+
+	segments := []string{key[:i]}
+	for rest := key[i:]; rest != ""; {
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 0 || strings.IndexByte(rest[1:end], '[') >= 0 {
+			return []string{key}
+		}
+		segments = append(segments, rest[1:end])
+		rest = rest[end+1:]
+	}
+	return segments
+}
+
+func decode(values url.Values, options []Option) (*object, error) {
+	var infer bool
+	for _, option := range options {
+		switch option.Name() {
+		case optKeyInferTypes:
+			infer = option.Value().(bool)
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	root := newObject()
+	for _, key := range keys {
+		segments := splitKey(key)
+		if len(segments) > maxDepth+1 {
+			return nil, fmt.Errorf(`key %q exceeds the maximum depth of %d`, key, maxDepth)
+		}
+		for _, value := range values[key] {
+			var v interface{} = value
+			if infer {
+				v = inferType(value)
+			}
+			if err := assign(root, segments, v); err != nil {
+				return nil, fmt.Errorf(`failed to assign key %q: %w`, key, err)
+			}
+		}
+	}
+	// the root is always an object, even if its keys are indices
+	for key, child := range root.values {
+		root.values[key] = finalize(child)
+	}
+	return root, nil
+}
+
+// assign stores value in o at the path described by segments
+func assign(o *object, segments []string, value interface{}) error {
+	for i, segment := range segments {
+		if segment == "" && i > 0 {
+			segment = strconv.Itoa(o.next)
+		}
+
+		existing, ok := o.values[segment]
+		if i == len(segments)-1 {
+			switch existing := existing.(type) {
+			case *object:
+				existing.set(strconv.Itoa(existing.next), value)
+			default:
+				if !ok {
+					o.set(segment, value)
+					break
+				}
+				// repeated keys are collected into an array
+				l := newObject()
+				l.set(`0`, existing)
+				l.set(`1`, value)
+				o.set(segment, l)
+			}
+			return nil
+		}
+
+		if !ok {
+			child := newObject()
+			o.set(segment, child)
+			o = child
+			continue
+		}
+		child, ok := existing.(*object)
+		if !ok {
+			return fmt.Errorf(`%q already holds a value`, segments[i])
+		}
+		o = child
+	}
+	return nil
+}
+
+// numberRx matches JSON numbers
+var numberRx = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// inferType returns value as a boolean or a number if it looks like
+// one, and as is otherwise
+func inferType(value string) interface{} {
+	switch {
+	case value == `true`:
+		return true
+	case value == `false`:
+		return false
+	case numberRx.MatchString(value):
+		return stdlib.Number(value)
+	}
+	return value
+}
+
+// finalize turns objects whose keys are all indices into arrays
+func finalize(v interface{}) interface{} {
+	o, ok := v.(*object)
+	if !ok {
+		return v
+	}
+	for key, child := range o.values {
+		o.values[key] = finalize(child)
+	}
+
+	if len(o.keys) == 0 {
+		return o
+	}
+	for _, key := range o.keys {
+		if _, ok := index(key); !ok {
+			return o
+		}
+	}
+
+	keys := append([]string(nil), o.keys...)
+	sort.Slice(keys, func(i, j int) bool {
+		a, _ := index(keys[i])
+		b, _ := index(keys[j])
+		return a < b
+	})
+	l := make([]interface{}, len(keys))
+	for i, key := range keys {
+		l[i] = o.values[key]
+	}
+	return l
+}
+
+// build creates a Context for the converted value v
+func build(v interface{}) json.Context {
+	c := json.New(placeholder(v), json.WithPreserveKeyOrder(true))
+	populate(c, v)
+	return c
+}
+
+// placeholder returns the value that should be stored for v before
+// calling populate. Objects are represented by empty maps, so that
+// their keys can be added through the Context in order
+func placeholder(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *object:
+		return map[string]interface{}{}
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, elem := range v {
+			l[i] = placeholder(elem)
+		}
+		return l
+	}
+	return v
+}
+
+// populate adds the contents of v to c, which holds placeholder(v)
+func populate(c json.Context, v interface{}) {
+	switch v := v.(type) {
+	case *object:
+		for _, key := range v.keys {
+			elem := v.values[key]
+			c.SetMapIndex(key, placeholder(elem))
+			populate(c.MapIndex(key), elem)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			populate(c.Index(i), elem)
+		}
+	}
+}
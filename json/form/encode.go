@@ -0,0 +1,72 @@
+package form
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/lestrrat-go/json"
+)
+
+func encode(values url.Values, c json.Context) error {
+	var m map[string]interface{}
+	if err := c.Map(&m); err != nil {
+		return fmt.Errorf(`form values must be JSON objects: %w`, err)
+	}
+	for key, child := range c.Entries() {
+		if strings.ContainsAny(key, `[]`) {
+			return fmt.Errorf(`key %q must not contain brackets`, key)
+		}
+		if err := encodeValue(values, key, child, m[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeValue adds v, which is pointed by c, to values under the key
+// prefix, or under keys derived from prefix for objects and arrays
+func encodeValue(values url.Values, prefix string, c json.Context, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		values.Add(prefix, ``)
+	case map[string]interface{}:
+		for key, child := range c.Entries() {
+			if strings.ContainsAny(key, `[]`) || key == `` {
+				return fmt.Errorf(`key %q of %q cannot be represented`, key, prefix)
+			}
+			if err := encodeValue(values, prefix+`[`+key+`]`, child, v[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, child := range c.Elements() {
+			if err := encodeValue(values, prefix+`[`+strconv.Itoa(i)+`]`, child, v[i]); err != nil {
+				return err
+			}
+		}
+	case string:
+		values.Add(prefix, v)
+	default:
+		buf, err := c.MarshalJSON()
+		if err != nil {
+			return fmt.Errorf(`failed to encode %q: %w`, prefix, err)
+		}
+		if len(buf) > 0 && buf[0] == '"' {
+			// values such as time.Time are represented as JSON strings
+			j, err := json.Parse(buf)
+			if err != nil {
+				return fmt.Errorf(`failed to encode %q: %w`, prefix, err)
+			}
+			var s string
+			if err := j.String(&s); err != nil {
+				return fmt.Errorf(`failed to encode %q: %w`, prefix, err)
+			}
+			values.Add(prefix, s)
+			return nil
+		}
+		values.Add(prefix, string(buf))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// Package form converts form-encoded values, such as url.Values, to and
+// from json.Context trees, so that form posts and JSON posts can share
+// the same downstream representation.
+//
+// Keys may use brackets to describe nested structures, with the same
+// conventions used by many web frameworks:
+//
+//   - `a=x` becomes {"a":"x"}, and repeated keys such as `a=x&a=y`
+//     become {"a":["x","y"]}
+//   - `a[b]=x` becomes {"a":{"b":"x"}}
+//   - `a[0]=x&a[1]=y` and `a[]=x&a[]=y` become {"a":["x","y"]}. Objects
+//     whose keys are all indices become arrays ordered by index, with
+//     missing indices removed
+//   - brackets can be nested, as in `a[b][0][c]=x`
+//
+// Keys that do not follow this syntax, such as `a[b`, are used as is.
+// Since forms have no types, all values are strings, unless
+// WithInferTypes is specified.
+//
+// EncodeQuery and ParseQuery do the same for query strings, so that
+// APIs accepting filters either as URL parameters or as a JSON body can
+// handle both in the same manner.
+package form
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/lestrrat-go/json"
+)
+
+// FromValues converts values into a JSON object. Keys are processed in
+// lexical order, so that the result does not depend on the iteration
+// order of values
+func FromValues(values url.Values, options ...Option) (json.Context, error) {
+	v, err := decode(values, options)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to convert form values: %w`, err)
+	}
+	return build(v), nil
+}
+
+// ToValues converts the JSON object pointed by c into form values,
+// using bracketed keys for nested objects and arrays. Scalars other than
+// strings are represented by their JSON encoding, and null becomes an
+// empty string. Empty objects and arrays cannot be represented, and are
+// omitted
+func ToValues(c json.Context) (url.Values, error) {
+	values := make(url.Values)
+	if err := encode(values, c); err != nil {
+		return nil, fmt.Errorf(`failed to convert to form values: %w`, err)
+	}
+	return values, nil
+}
+
+// ParseQuery parses the query string s, and converts its values into a
+// JSON object in the same manner as FromValues
+func ParseQuery(s string, options ...Option) (json.Context, error) {
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse query string: %w`, err)
+	}
+	return FromValues(values, options...)
+}
+
+// EncodeQuery returns the JSON object pointed by c as a query string,
+// using the same keys as ToValues. The output is canonical: keys are
+// sorted, so that equal documents always produce the same string
+func EncodeQuery(c json.Context) (string, error) {
+	values, err := ToValues(c)
+	if err != nil {
+		return "", err
+	}
+	return values.Encode(), nil
+}
@@ -0,0 +1,196 @@
+package form_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/json"
+	"github.com/lestrrat-go/json/form"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromValues(t *testing.T) {
+	t.Run("sanity", func(t *testing.T) {
+		testcases := []struct {
+			Input    string
+			Expected string
+		}{
+			{Input: `a=x`, Expected: `{"a":"x"}`},
+			{Input: `a=x&a=y`, Expected: `{"a":["x","y"]}`},
+			{Input: `a[b]=x&a[c]=y`, Expected: `{"a":{"b":"x","c":"y"}}`},
+			{Input: `a[1]=y&a[0]=x`, Expected: `{"a":["x","y"]}`},
+			{Input: `a[]=x&a[]=y`, Expected: `{"a":["x","y"]}`},
+			{Input: `a[5]=x&a[10]=y`, Expected: `{"a":["x","y"]}`},
+			{Input: `a[0]=x&a[b]=y`, Expected: `{"a":{"0":"x","b":"y"}}`},
+			{Input: `a[b][0][c]=x&a[b][0][d]=y&a[b][1][c]=z`, Expected: `{"a":{"b":[{"c":"x","d":"y"},{"c":"z"}]}}`},
+			{Input: `a[0][]=x&a[0][]=y`, Expected: `{"a":[["x","y"]]}`},
+			{Input: `0=x&1=y`, Expected: `{"0":"x","1":"y"}`},
+			{Input: `a[01]=x`, Expected: `{"a":{"01":"x"}}`},
+			{Input: `a[b=x&[c]=y&d[e]f=z`, Expected: `{"[c]":"y","a[b":"x","d[e]f":"z"}`},
+			{Input: `a=`, Expected: `{"a":""}`},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Input, func(t *testing.T) {
+				values, err := url.ParseQuery(tc.Input)
+				if !assert.NoError(t, err, `url.ParseQuery should succeed`) {
+					return
+				}
+				j, err := form.FromValues(values)
+				if !assert.NoError(t, err, `form.FromValues should succeed`) {
+					return
+				}
+				buf, err := j.MarshalJSON()
+				if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+					return
+				}
+				if !assert.Equal(t, tc.Expected, string(buf), `output should match`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		testcases := []string{
+			`a=x&a[b]=y`,
+			`a[b]=x&a[b][c]=y`,
+		}
+		for _, tc := range testcases {
+			values, err := url.ParseQuery(tc)
+			if !assert.NoError(t, err, `url.ParseQuery should succeed`) {
+				return
+			}
+			if _, err := form.FromValues(values); !assert.Error(t, err, `form.FromValues should fail for %s`, tc) {
+				return
+			}
+		}
+	})
+}
+
+func TestToValues(t *testing.T) {
+	t.Run("sanity", func(t *testing.T) {
+		j, err := json.ParseString(`{"name":"foo","tags":["a","b"],"filter":{"min":1,"max":2.5,"on":true,"none":null,"items":[{"id":1}]},"empty":{}}`)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		j.SetMapIndex("when", time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC))
+
+		values, err := form.ToValues(j)
+		if !assert.NoError(t, err, `form.ToValues should succeed`) {
+			return
+		}
+		expected := url.Values{
+			"name":                 {"foo"},
+			"tags[0]":              {"a"},
+			"tags[1]":              {"b"},
+			"filter[min]":          {"1"},
+			"filter[max]":          {"2.5"},
+			"filter[on]":           {"true"},
+			"filter[none]":         {""},
+			"filter[items][0][id]": {"1"},
+			"when":                 {"2024-03-01T12:00:00Z"},
+		}
+		if !assert.Equal(t, expected, values, `values should match`) {
+			return
+		}
+	})
+	t.Run("round trip", func(t *testing.T) {
+		values := url.Values{
+			"a[b][0][c]": {"x"},
+			"a[b][1][c]": {"y"},
+			"d":          {"1", "2"},
+		}
+		j, err := form.FromValues(values)
+		if !assert.NoError(t, err, `form.FromValues should succeed`) {
+			return
+		}
+		values2, err := form.ToValues(j)
+		if !assert.NoError(t, err, `form.ToValues should succeed`) {
+			return
+		}
+		j2, err := form.FromValues(values2)
+		if !assert.NoError(t, err, `form.FromValues should succeed`) {
+			return
+		}
+		json1, _ := j.MarshalJSON()
+		json2, _ := j2.MarshalJSON()
+		if !assert.Equal(t, string(json1), string(json2), `documents should be equivalent`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		testcases := []string{
+			`[1,2]`,
+			`{"a[b]":1}`,
+			`{"a":{"b]":1}}`,
+		}
+		for _, tc := range testcases {
+			j, err := json.ParseString(tc)
+			if !assert.NoError(t, err, `json.ParseString should succeed`) {
+				return
+			}
+			if _, err := form.ToValues(j); !assert.Error(t, err, `form.ToValues should fail for %s`, tc) {
+				return
+			}
+		}
+	})
+}
+
+func TestQuery(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		j, err := json.ParseString(`{"status":"open","limit":20,"tags":["a","b"],"range":{"min":1.5,"max":10},"active":true}`)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+
+		s, err := form.EncodeQuery(j)
+		if !assert.NoError(t, err, `form.EncodeQuery should succeed`) {
+			return
+		}
+		const expected = `active=true&limit=20&range%5Bmax%5D=10&range%5Bmin%5D=1.5&status=open&tags%5B0%5D=a&tags%5B1%5D=b`
+		if !assert.Equal(t, expected, s, `output should match`) {
+			return
+		}
+
+		j2, err := form.ParseQuery(s, form.WithInferTypes(true))
+		if !assert.NoError(t, err, `form.ParseQuery should succeed`) {
+			return
+		}
+		json1, _ := j.MarshalJSON()
+		json2, _ := j2.MarshalJSON()
+		if !assert.Equal(t, string(json1), string(json2), `documents should be equivalent`) {
+			return
+		}
+	})
+	t.Run("without type inference", func(t *testing.T) {
+		j, err := form.ParseQuery(`limit=20&active=true&name=0123&tags[]=1&tags[]=x`)
+		if !assert.NoError(t, err, `form.ParseQuery should succeed`) {
+			return
+		}
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"active":"true","limit":"20","name":"0123","tags":["1","x"]}`, string(buf), `output should match`) {
+			return
+		}
+
+		j, err = form.ParseQuery(`limit=20&active=true&name=0123&tags[]=1&tags[]=x`, form.WithInferTypes(true))
+		if !assert.NoError(t, err, `form.ParseQuery should succeed`) {
+			return
+		}
+		buf, err = j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"active":true,"limit":20,"name":"0123","tags":[1,"x"]}`, string(buf), `output should match`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		if _, err := form.ParseQuery(`a=%zz`); !assert.Error(t, err, `form.ParseQuery should fail for invalid escapes`) {
+			return
+		}
+	})
+}
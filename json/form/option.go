@@ -0,0 +1,33 @@
+package form
+
+const (
+	optKeyInferTypes = `optkey-infer-types`
+)
+
+// Option configures how form values are converted into JSON objects
+type Option interface {
+	Name() string
+	Value() interface{}
+}
+
+type option struct {
+	name  string
+	value interface{}
+}
+
+func (o option) Name() string {
+	return o.name
+}
+
+func (o option) Value() interface{} {
+	return o.value
+}
+
+// WithInferTypes specifies whether values that look like booleans
+// (`true` and `false`) or JSON numbers should be converted into
+// booleans and json.Number values, so that the result matches the
+// equivalent JSON document. Note that strings such as "123" can then
+// no longer be told apart from numbers. The default is false
+func WithInferTypes(b bool) Option {
+	return &option{name: optKeyInferTypes, value: b}
+}
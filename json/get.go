@@ -0,0 +1,136 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// GetBytes returns a Context pointing to the value found at path in the
+// JSON document in data, without building the rest of the document.
+// The input is scanned only up to the end of the requested value: the
+// values that precede it are validated and discarded, and the input
+// that follows it is not read at all. This makes GetBytes much cheaper
+// than Parse when only a few fields of a large document are needed.
+//
+// path uses the same notation as Walk, such as `$.items[0].name` or
+// `$["not an identifier"]`, and the leading `$` may be omitted. If an
+// object holds the same key more than once, the first occurrence is
+// used, regardless of WithDuplicateKeys.
+//
+// The ParseOptions of Parse are accepted, except WithDecompression and
+// WithDecompressor. WithDisallowTrailingData is ignored, since the
+// input following the value is not examined
+func GetBytes(data []byte, path string, options ...ParseOption) (Context, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := newParseConfig(options)
+	if len(cfg.decompressors) > 0 {
+		return nil, errors.New(`decompression is not supported by GetBytes`)
+	}
+	if cfg.maxSize >= 0 && int64(len(data)) > cfg.maxSize {
+		return nil, fmt.Errorf(`input exceeds maximum size of %d bytes: %w`, cfg.maxSize, ErrLimitExceeded)
+	}
+
+	r := getReader()
+	defer releaseReader(r)
+	r.Reset(data)
+
+	d := newDecoder(NewTokenizer(r), cfg)
+	d.setInput(data)
+
+	tok, err := d.find(segments)
+	if err == nil {
+		var v interface{}
+		if v, err = d.decodeToken(tok); err == nil {
+			return d.context(v), nil
+		}
+	}
+	if err == io.EOF {
+		err = &SyntaxError{msg: `unexpected end of JSON input`}
+	}
+	return nil, fmt.Errorf(`failed to get %s: %w`, formatPath(segments), err)
+}
+
+// find advances the tokenizer to the value at the location described
+// by segments, and returns its first token
+func (d *decoder) find(segments []pathSegment) (Token, error) {
+	tok, err := d.t.Next()
+	if err != nil {
+		return Token{}, err
+	}
+
+	for i, seg := range segments {
+		if seg.isIndex {
+			if tok.Kind != ArrayStartToken {
+				return Token{}, tokenTypeError(ArrayValue, formatPath(segments[:i]), tok, `cannot access index %d`, seg.index)
+			}
+			for n := 0; ; n++ {
+				if tok, err = d.t.Next(); err != nil {
+					return Token{}, err
+				}
+				if tok.Kind == ArrayEndToken {
+					return Token{}, newPathError(formatPath(segments[:i]), ErrIndexOutOfRange, `index %d is out of bounds (len=%d)`, seg.index, n)
+				}
+				if n == seg.index {
+					break
+				}
+				if err := d.skipToken(tok); err != nil {
+					return Token{}, err
+				}
+			}
+			continue
+		}
+
+		if tok.Kind != ObjectStartToken {
+			return Token{}, tokenTypeError(ObjectValue, formatPath(segments[:i]), tok, `cannot access field %#v`, seg.key)
+		}
+		for {
+			if tok, err = d.t.Next(); err != nil {
+				return Token{}, err
+			}
+			if tok.Kind == ObjectEndToken {
+				return Token{}, newPathError(formatPath(segments[:i]), ErrKeyNotFound, `field %#v not found`, seg.key)
+			}
+			found := tok.Value.(string) == seg.key
+			if tok, err = d.t.Next(); err != nil {
+				return Token{}, err
+			}
+			if found {
+				break
+			}
+			if err := d.skipToken(tok); err != nil {
+				return Token{}, err
+			}
+		}
+	}
+	return tok, nil
+}
+
+// skipToken discards the value that started with tok
+func (d *decoder) skipToken(tok Token) error {
+	if tok.Kind == ObjectStartToken || tok.Kind == ArrayStartToken {
+		_, err := d.skip(false)
+		return err
+	}
+	return nil
+}
+
+// tokenTypeError is the same as typeError, for the value at path that
+// starts with tok. The excerpt of objects and arrays is not available
+func tokenTypeError(expected ValueKind, path string, tok Token, format string, args ...interface{}) error {
+	te := &TypeError{Expected: expected, Path: path}
+	switch tok.Kind {
+	case ObjectStartToken:
+		te.Actual = ObjectValue
+	case ArrayStartToken:
+		te.Actual = ArrayValue
+	default:
+		te.Actual = kindOf(tok.Value)
+		te.Excerpt = excerpt(tok.Value)
+	}
+	return newPathError(path, ErrTypeMismatch, format+`: %w`, append(args, te)...)
+}
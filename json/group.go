@@ -0,0 +1,147 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// MissingGroupKeyPolicy specifies how GroupBy handles the elements
+// that do not hold a value at the path of the key. See
+// WithMissingGroupKeys
+type MissingGroupKeyPolicy int
+
+const (
+	// MissingGroupKeyError makes GroupBy return an error wrapping
+	// ErrKeyNotFound. This is the default
+	MissingGroupKeyError MissingGroupKeyPolicy = iota
+	// MissingGroupKeySkip leaves the element out of the groups
+	MissingGroupKeySkip
+)
+
+// DuplicateGroupKeyPolicy specifies how GroupBy handles elements that
+// hold the same key. See WithDuplicateGroupKeys
+type DuplicateGroupKeyPolicy int
+
+const (
+	// DuplicateGroupKeyCollect groups the elements holding the same
+	// key in an array, in their original order, so that every group is
+	// an array. This is the default
+	DuplicateGroupKeyCollect DuplicateGroupKeyPolicy = iota
+	// DuplicateGroupKeyFirstWins keeps the first element holding the key
+	DuplicateGroupKeyFirstWins
+	// DuplicateGroupKeyLastWins keeps the last element holding the key
+	DuplicateGroupKeyLastWins
+	// DuplicateGroupKeyError makes GroupBy return an error wrapping
+	// ErrDuplicateKey
+	DuplicateGroupKeyError
+)
+
+func (c *ctx) GroupBy(path string, options ...GroupOption) (map[string]Context, error) {
+	missing := MissingGroupKeyError
+	duplicates := DuplicateGroupKeyCollect
+	for _, option := range options {
+		switch option.Name() {
+		case optKeyMissingGroupKeys:
+			missing = option.Value().(MissingGroupKeyPolicy)
+		case optKeyDuplicateGroupKeys:
+			duplicates = option.Value().(DuplicateGroupKeyPolicy)
+		}
+	}
+
+	switch c.value.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return nil, c.typeError(ArrayValue, `cannot group elements`)
+	}
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to group elements: %w`, err)
+	}
+
+	groups := make(map[string]Context)
+	collected := make(map[string][]interface{})
+	var order []string
+	for i := 0; i < c.value.Len(); i++ {
+		elem, err := c.indexChild(i)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to group elements: %w`, err)
+		}
+		key, err := elem.groupKey(segments)
+		if err != nil {
+			if missing == MissingGroupKeySkip && (errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrIndexOutOfRange)) {
+				continue
+			}
+			return nil, fmt.Errorf(`failed to group elements: %w`, err)
+		}
+
+		switch duplicates {
+		case DuplicateGroupKeyCollect:
+			if _, ok := collected[key]; !ok {
+				order = append(order, key)
+			}
+			collected[key] = append(collected[key], elem.interfaceValue())
+			continue
+		case DuplicateGroupKeyFirstWins:
+			if _, ok := groups[key]; ok {
+				continue
+			}
+		case DuplicateGroupKeyError:
+			if _, ok := groups[key]; ok {
+				return nil, fmt.Errorf(`failed to group elements: key %q found at %s: %w`, key, elem.location(), ErrDuplicateKey)
+			}
+		}
+		groups[key] = elem
+	}
+
+	for _, key := range order {
+		groups[key] = c.detached(collected[key])
+	}
+	return groups, nil
+}
+
+// groupKey returns the key of the group of the element pointed by c,
+// which is the string representation of the value found at the path
+// described by segments
+func (c *ctx) groupKey(segments []pathSegment) (string, error) {
+	var target Context = c
+	for _, seg := range segments {
+		if seg.isIndex {
+			target = target.Index(seg.index)
+		} else {
+			target = target.MapIndex(seg.key)
+		}
+	}
+	if err := target.Err(); err != nil {
+		return "", err
+	}
+
+	tc := target.(*ctx)
+	v := tc.interfaceValue()
+	switch kindOf(v) {
+	case StringValue:
+		var s string
+		if err := tc.String(&s); err != nil {
+			return "", err
+		}
+		return s, nil
+	case NumberValue:
+		// numbers are grouped by value, so that 1 and 1.0 share a group
+		if r, ok := numberRat(v); ok {
+			return string(ratNumber(r)), nil
+		}
+		if s, ok := numberLiteral(v); ok {
+			return s, nil
+		}
+	case BoolValue:
+		var b bool
+		if err := tc.Bool(&b); err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(b), nil
+	case NullValue:
+		return `null`, nil
+	}
+	return "", tc.typeError(InvalidValue, `cannot use value as a group key`)
+}
@@ -0,0 +1,225 @@
+package json
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxRequestSize is the maximum number of bytes accepted by
+// ParseRequest unless WithMaxSize is specified
+const DefaultMaxRequestSize = 1 << 20
+
+// RequestError is returned by ParseRequest when the request cannot be
+// processed. StatusCode holds the HTTP status code that should be sent
+// in response, and the error message is suitable for including in the
+// response body
+type RequestError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *RequestError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+func newRequestError(status int, err error) *RequestError {
+	return &RequestError{StatusCode: status, Err: err}
+}
+
+// ParseRequest parses the JSON document in the body of r.
+//
+// The request must have a JSON media type (`application/json`, or any
+// type with a `+json` suffix), encoded in UTF-8, and may be compressed
+// using gzip, as indicated by its Content-Encoding header. The body must
+// hold a single JSON value, and must not exceed DefaultMaxRequestSize
+// bytes after decompression, unless a different limit is specified using
+// WithMaxSize.
+//
+// All errors are of type *RequestError, with a status code of 415 for
+// unsupported media types and encodings, 413 for bodies exceeding the
+// size limit or any other limit specified for the parser (such as
+// WithMaxDepth), and 400 for everything else
+func ParseRequest(r *http.Request, options ...ParseOption) (Context, error) {
+	if err := checkContentType(r.Header.Get(`Content-Type`)); err != nil {
+		return nil, newRequestError(http.StatusUnsupportedMediaType, err)
+	}
+
+	options = append([]ParseOption{WithMaxSize(DefaultMaxRequestSize), WithDisallowTrailingData(true)}, options...)
+	cfg := newParseConfig(options)
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, newRequestError(http.StatusBadRequest, errors.New(`request body is empty`))
+	}
+	defer r.Body.Close()
+
+	var body io.Reader = r.Body
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get(`Content-Encoding`))); encoding {
+	case ``, `identity`:
+		if cfg.maxSize >= 0 && r.ContentLength > cfg.maxSize {
+			return nil, newRequestError(http.StatusRequestEntityTooLarge,
+				fmt.Errorf(`request body exceeds maximum size of %d bytes: %w`, cfg.maxSize, ErrLimitExceeded))
+		}
+	case `gzip`, `x-gzip`:
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, newRequestError(http.StatusBadRequest, fmt.Errorf(`failed to decompress request body: %w`, err))
+		}
+		defer zr.Close()
+		body = zr
+	default:
+		return nil, newRequestError(http.StatusUnsupportedMediaType, fmt.Errorf(`unsupported content encoding %q`, encoding))
+	}
+
+	c, err := parse(body, nil, cfg)
+	if err != nil {
+		if errors.Is(err, ErrLimitExceeded) {
+			return nil, newRequestError(http.StatusRequestEntityTooLarge, err)
+		}
+		return nil, newRequestError(http.StatusBadRequest, err)
+	}
+	return c, nil
+}
+
+type requestContextKey struct{}
+
+// Middleware returns an HTTP middleware that parses the body of each
+// request using ParseRequest with the given options, so that handlers
+// can retrieve the document via FromRequest without reading the body
+// again. Requests without a body are passed through as is.
+//
+// If the body cannot be parsed, the middleware responds with the status
+// code of the RequestError, and a JSON object holding the error message
+// in its "error" field, without calling the next handler
+func Middleware(options ...ParseOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			c, err := ParseRequest(r, options...)
+			if err != nil {
+				status := http.StatusBadRequest
+				var reqErr *RequestError
+				if errors.As(err, &reqErr) {
+					status = reqErr.StatusCode
+				}
+				_ = Write(w, status, New(map[string]interface{}{`error`: err.Error()}))
+				return
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), requestContextKey{}, c))
+			r.Body = http.NoBody
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// FromRequest returns the document parsed from the body of r by the
+// middleware returned by Middleware. It reports false if the body has
+// not been parsed
+func FromRequest(r *http.Request) (Context, bool) {
+	c, ok := r.Context().Value(requestContextKey{}).(Context)
+	return c, ok
+}
+
+// Write writes the JSON encoding of the value pointed by c as the
+// response to an HTTP request, with the given status code. The
+// Content-Type header is set to `application/json; charset=utf-8`
+// unless it has already been set, and the output is terminated by a
+// newline. The document is streamed to w as it is encoded (see
+// WriteTo), honoring the given options. Use WithRequest to allow
+// clients to request indented output via `?pretty=1`.
+//
+// The status code is only sent along with the first chunk of output,
+// so if c is invalid, or encoding fails before anything is written, the
+// error is returned and the caller is free to send a different response.
+// Otherwise, part of the body may already have been sent
+func Write(w http.ResponseWriter, status int, c Context, options ...MarshalOption) error {
+	rw := &responseWriter{w: w, status: status}
+	e := encodeState{escapeHTML: true, w: rw}
+	v, err := e.init(c, options)
+	if err != nil {
+		return err
+	}
+	for _, option := range options {
+		switch option.Name() {
+		case optKeyRequest:
+			r := option.Value().(*http.Request)
+			if r == nil {
+				continue
+			}
+			if pretty, err := strconv.ParseBool(r.URL.Query().Get(`pretty`)); err == nil && pretty {
+				e.indented = true
+				e.prefix = ``
+				e.indent = `  `
+			}
+		}
+	}
+
+	h := w.Header()
+	if h.Get(`Content-Type`) == "" {
+		h.Set(`Content-Type`, `application/json; charset=utf-8`)
+	}
+	h.Set(`X-Content-Type-Options`, `nosniff`)
+
+	// these responses must not include a body
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		w.WriteHeader(status)
+		return nil
+	}
+
+	if err := e.encode(v); err != nil {
+		return fmt.Errorf(`failed to write response: %w`, err)
+	}
+	e.buf.WriteByte('\n')
+	if err := e.flush(true); err != nil {
+		return fmt.Errorf(`failed to write response: %w`, err)
+	}
+	return nil
+}
+
+// responseWriter sends the status code before the first write
+type responseWriter struct {
+	w       http.ResponseWriter
+	status  int
+	started bool
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.w.WriteHeader(w.status)
+	}
+	return w.w.Write(p)
+}
+
+// checkContentType checks that the media type v describes JSON
+func checkContentType(v string) error {
+	if v == "" {
+		return errors.New(`missing content type`)
+	}
+	mediaType, params, err := mime.ParseMediaType(v)
+	if err != nil {
+		return fmt.Errorf(`invalid content type %q: %w`, v, err)
+	}
+	if mediaType != `application/json` && !strings.HasSuffix(mediaType, `+json`) {
+		return fmt.Errorf(`unsupported content type %q`, mediaType)
+	}
+	if charset, ok := params[`charset`]; ok && !strings.EqualFold(charset, `utf-8`) {
+		return fmt.Errorf(`unsupported charset %q`, charset)
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package json_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRequest(t *testing.T) {
+	newRequest := func(body, contentType string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		return r
+	}
+
+	t.Run("sanity", func(t *testing.T) {
+		for _, contentType := range []string{"application/json", "application/json; charset=UTF-8", "application/merge-patch+json"} {
+			j, err := json.ParseRequest(newRequest(`{"name":"foo"}`, contentType))
+			if !assert.NoError(t, err, `json.ParseRequest should succeed for %s`, contentType) {
+				return
+			}
+			var s string
+			if !assert.NoError(t, j.MapIndex("name").String(&s), `String should succeed`) {
+				return
+			}
+			if !assert.Equal(t, "foo", s, `value should match`) {
+				return
+			}
+		}
+	})
+	t.Run("gzip", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(`{"name":"foo"}`))
+		zw.Close()
+
+		r := newRequest(buf.String(), "application/json")
+		r.Header.Set("Content-Encoding", "gzip")
+		j, err := json.ParseRequest(r)
+		if !assert.NoError(t, err, `json.ParseRequest should succeed`) {
+			return
+		}
+		var s string
+		if !assert.NoError(t, j.MapIndex("name").String(&s), `String should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "foo", s, `value should match`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		var bomb bytes.Buffer
+		zw := gzip.NewWriter(&bomb)
+		zw.Write([]byte(`"` + strings.Repeat("a", 2<<20) + `"`))
+		zw.Close()
+
+		testcases := []struct {
+			Name     string
+			Request  func() *http.Request
+			Options  []json.ParseOption
+			Expected int
+		}{
+			{
+				Name:     "missing content type",
+				Request:  func() *http.Request { return newRequest(`{}`, "") },
+				Expected: http.StatusUnsupportedMediaType,
+			},
+			{
+				Name:     "unsupported content type",
+				Request:  func() *http.Request { return newRequest(`{}`, "text/plain") },
+				Expected: http.StatusUnsupportedMediaType,
+			},
+			{
+				Name:     "unsupported charset",
+				Request:  func() *http.Request { return newRequest(`{}`, "application/json; charset=latin1") },
+				Expected: http.StatusUnsupportedMediaType,
+			},
+			{
+				Name: "unsupported encoding",
+				Request: func() *http.Request {
+					r := newRequest(`{}`, "application/json")
+					r.Header.Set("Content-Encoding", "br")
+					return r
+				},
+				Expected: http.StatusUnsupportedMediaType,
+			},
+			{
+				Name:     "syntax error",
+				Request:  func() *http.Request { return newRequest(`{"name":`, "application/json") },
+				Expected: http.StatusBadRequest,
+			},
+			{
+				Name:     "trailing data",
+				Request:  func() *http.Request { return newRequest(`{}{}`, "application/json") },
+				Expected: http.StatusBadRequest,
+			},
+			{
+				Name:     "empty body",
+				Request:  func() *http.Request { return newRequest(``, "application/json") },
+				Expected: http.StatusBadRequest,
+			},
+			{
+				Name:     "too large",
+				Request:  func() *http.Request { return newRequest(`[1,2,3,4,5,6,7,8,9]`, "application/json") },
+				Options:  []json.ParseOption{json.WithMaxSize(8)},
+				Expected: http.StatusRequestEntityTooLarge,
+			},
+			{
+				Name: "too large after decompression",
+				Request: func() *http.Request {
+					r := newRequest(bomb.String(), "application/json")
+					r.Header.Set("Content-Encoding", "gzip")
+					return r
+				},
+				Expected: http.StatusRequestEntityTooLarge,
+			},
+			{
+				Name: "invalid gzip",
+				Request: func() *http.Request {
+					r := newRequest(`{}`, "application/json")
+					r.Header.Set("Content-Encoding", "gzip")
+					return r
+				},
+				Expected: http.StatusBadRequest,
+			},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				_, err := json.ParseRequest(tc.Request(), tc.Options...)
+				var reqErr *json.RequestError
+				if !assert.True(t, errors.As(err, &reqErr), `error should be a RequestError (%v)`, err) {
+					return
+				}
+				if !assert.Equal(t, tc.Expected, reqErr.StatusCode, `status code should match (%v)`, err) {
+					return
+				}
+			})
+		}
+	})
+}
+
+func TestWrite(t *testing.T) {
+	j, err := json.ParseString(`{"name":"foo","tags":["a"]}`, json.WithPreserveKeyOrder(true))
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+
+	t.Run("sanity", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		if !assert.NoError(t, json.Write(rec, http.StatusCreated, j), `json.Write should succeed`) {
+			return
+		}
+		if !assert.Equal(t, http.StatusCreated, rec.Code, `status code should match`) {
+			return
+		}
+		if !assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"), `content type should match`) {
+			return
+		}
+		if !assert.Equal(t, "{\"name\":\"foo\",\"tags\":[\"a\"]}\n", rec.Body.String(), `body should match`) {
+			return
+		}
+	})
+	t.Run("pretty", func(t *testing.T) {
+		for query, expected := range map[string]string{
+			"/?pretty=1":     "{\n  \"name\": \"foo\",\n  \"tags\": [\n    \"a\"\n  ]\n}\n",
+			"/?pretty=false": "{\"name\":\"foo\",\"tags\":[\"a\"]}\n",
+			"/":              "{\"name\":\"foo\",\"tags\":[\"a\"]}\n",
+		} {
+			rec := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, query, nil)
+			if !assert.NoError(t, json.Write(rec, http.StatusOK, j, json.WithRequest(r)), `json.Write should succeed`) {
+				return
+			}
+			if !assert.Equal(t, expected, rec.Body.String(), `body should match for %s`, query) {
+				return
+			}
+		}
+	})
+	t.Run("content type", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		rec.Header().Set("Content-Type", "application/problem+json")
+		if !assert.NoError(t, json.Write(rec, http.StatusBadRequest, j), `json.Write should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"), `content type should be kept`) {
+			return
+		}
+	})
+	t.Run("no content", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		if !assert.NoError(t, json.Write(rec, http.StatusNoContent, j), `json.Write should succeed`) {
+			return
+		}
+		if !assert.Equal(t, http.StatusNoContent, rec.Code, `status code should match`) {
+			return
+		}
+		if !assert.Equal(t, 0, rec.Body.Len(), `body should be empty`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		for _, c := range []json.Context{j.MapIndex("missing"), json.New(map[string]interface{}{"n": math.NaN()})} {
+			rec := httptest.NewRecorder()
+			if !assert.Error(t, json.Write(rec, http.StatusOK, c), `json.Write should fail`) {
+				return
+			}
+			if !assert.False(t, rec.Flushed || rec.Body.Len() > 0, `nothing should be written`) {
+				return
+			}
+			// the caller can still respond with an error
+			http.Error(rec, "internal error", http.StatusInternalServerError)
+			if !assert.Equal(t, http.StatusInternalServerError, rec.Code, `status code should match`) {
+				return
+			}
+		}
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	handler := json.Middleware(json.WithMaxSize(64))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := json.FromRequest(r)
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.Write(w, http.StatusOK, json.New(map[string]interface{}{"received": c}))
+	}))
+
+	testcases := []struct {
+		Name     string
+		Body     string
+		Expected int
+		Response string
+	}{
+		{Name: "sanity", Body: `{"name":"foo"}`, Expected: http.StatusOK, Response: "{\"received\":{\"name\":\"foo\"}}\n"},
+		{Name: "no body", Expected: http.StatusNoContent},
+		{Name: "syntax error", Body: `{"name":`, Expected: http.StatusBadRequest},
+		{Name: "too large", Body: `"` + strings.Repeat("a", 100) + `"`, Expected: http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			var r *http.Request
+			if tc.Body == "" {
+				r = httptest.NewRequest(http.MethodGet, "/", nil)
+			} else {
+				r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.Body))
+				r.Header.Set("Content-Type", "application/json")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+			if !assert.Equal(t, tc.Expected, rec.Code, `status code should match`) {
+				return
+			}
+			if tc.Response != "" {
+				if !assert.Equal(t, tc.Response, rec.Body.String(), `response should match`) {
+					return
+				}
+				return
+			}
+			if tc.Expected >= 400 {
+				j, err := json.ParseString(rec.Body.String())
+				if !assert.NoError(t, err, `error response should be JSON`) {
+					return
+				}
+				var msg string
+				if !assert.NoError(t, j.MapIndex("error").String(&msg), `error message should be present`) {
+					return
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package json
+
+import (
+	stdlib "encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// fieldIndex maps the values of a field of the objects held in an
+// array to the position of the first object holding each value. See
+// BuildIndex
+type fieldIndex struct {
+	// length is the length of the array when the index was built, and
+	// generation is the generation of the document at that time
+	length     int
+	generation uint64
+	positions  map[interface{}]int
+}
+
+func (c *ctx) BuildIndex(field string) error {
+	switch c.value.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return c.typeError(ArrayValue, `cannot build index`)
+	}
+	c.buildIndex(field)
+	return nil
+}
+
+func (c *ctx) buildIndex(field string) *fieldIndex {
+	idx := &fieldIndex{
+		length:     c.value.Len(),
+		generation: c.currentGeneration(),
+		positions:  make(map[interface{}]int),
+	}
+	for i := 0; i < idx.length; i++ {
+		key, ok := c.elementKey(i, field)
+		if !ok {
+			continue
+		}
+		if _, ok := idx.positions[key]; !ok {
+			idx.positions[key] = i
+		}
+	}
+
+	if c.indexes == nil {
+		c.indexes = make(map[string]*fieldIndex)
+	}
+	c.indexes[field] = idx
+	return idx
+}
+
+func (c *ctx) FindBy(field string, value interface{}) Context {
+	switch c.value.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return newErrCtx(c.typeError(ArrayValue, `cannot find element`))
+	}
+
+	key, ok := indexKey(value)
+	if !ok {
+		return newErrCtx(fmt.Errorf(`cannot find element by value of type %T`, value))
+	}
+
+	idx, ok := c.indexes[field]
+	if !ok {
+		i, ok := c.findElement(field, key)
+		if !ok {
+			return newErrCtx(c.accessError(ErrKeyNotFound, `element with field %#v equal to %v not found`, field, value))
+		}
+		return orErrCtx(c.indexChild(i))
+	}
+
+	// rebuild the index if the document has been modified since it
+	// was built, or if it is obviously out of date
+	if idx.generation != c.currentGeneration() || idx.length != c.value.Len() {
+		idx = c.buildIndex(field)
+	} else if i, ok := idx.positions[key]; ok {
+		if k, ok := c.elementKey(i, field); !ok || k != key {
+			idx = c.buildIndex(field)
+		}
+	}
+
+	i, ok := idx.positions[key]
+	if !ok {
+		return newErrCtx(c.accessError(ErrKeyNotFound, `element with field %#v equal to %v not found`, field, value))
+	}
+	return orErrCtx(c.indexChild(i))
+}
+
+// currentGeneration returns the generation of the document that c
+// points into
+func (c *ctx) currentGeneration() uint64 {
+	if c.generation == nil {
+		return 0
+	}
+	return *c.generation
+}
+
+// modified records that the document that c points into has been
+// modified, which invalidates the indexes built on it
+func (c *ctx) modified() {
+	if c.generation != nil {
+		*c.generation++
+	}
+}
+
+// findElement returns the position of the first element of the array
+// held by c whose field is indexed under key, scanning the array
+func (c *ctx) findElement(field string, key interface{}) (int, bool) {
+	for i := 0; i < c.value.Len(); i++ {
+		if k, ok := c.elementKey(i, field); ok && k == key {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// elementKey returns the key under which the value of field in the
+// i-th element of the array held by c is indexed. If the element is
+// not an object, the field is missing, or its value cannot be indexed,
+// false is returned, as it is if the element fails to decode
+func (c *ctx) elementKey(i int, field string) (interface{}, bool) {
+	child, err := c.indexChild(i)
+	if err != nil {
+		return nil, false
+	}
+	elem := child.value
+	if m, ok := elem.Interface().(map[string]interface{}); ok {
+		v, ok := m[field]
+		if !ok {
+			return nil, false
+		}
+		return indexKey(v)
+	}
+
+	if elem.Kind() != reflect.Map || elem.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	v := elem.MapIndex(reflect.ValueOf(field).Convert(elem.Type().Key()))
+	if !v.IsValid() {
+		return nil, false
+	}
+	return indexKey(v.Interface())
+}
+
+// numberKey is the key under which numbers are indexed. It holds the
+// canonical form of the number, so that equal numbers share the same
+// key regardless of their types and literal forms
+type numberKey string
+
+// indexKey returns the key under which v is indexed. Only numbers,
+// strings, booleans, and null can be indexed
+func indexKey(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case nil, bool, string:
+		return v, true
+	case stdlib.Number:
+		return numberKeyOf(string(v))
+	case Number:
+		b, err := v.MarshalJSON()
+		if err != nil {
+			return nil, false
+		}
+		return numberKeyOf(string(b))
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), true
+	case reflect.String:
+		return rv.String(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return numberKey(strconv.FormatInt(rv.Int(), 10)), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return numberKey(strconv.FormatUint(rv.Uint(), 10)), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, false
+		}
+		return numberKeyOf(strconv.FormatFloat(f, 'g', -1, rv.Type().Bits()))
+	}
+	return nil, false
+}
+
+// numberKeyOf returns the key for the number whose literal form is s
+func numberKeyOf(s string) (interface{}, bool) {
+	if isWholeNumber(s) {
+		if s == "-0" {
+			s = "0"
+		}
+		return numberKey(s), true
+	}
+
+	var r big.Rat
+	if _, ok := r.SetString(s); !ok {
+		return nil, false
+	}
+	if r.IsInt() {
+		return numberKey(r.Num().String()), true
+	}
+	return numberKey(r.RatString()), true
+}
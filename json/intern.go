@@ -0,0 +1,72 @@
+package json
+
+import "sync"
+
+// KeyTable holds the object keys found in the documents parsed with
+// WithKeyTable, so that each distinct key is stored only once no matter
+// how many objects or documents it appears in. A KeyTable can be used
+// by multiple goroutines simultaneously
+type KeyTable struct {
+	mu   sync.RWMutex
+	keys map[string]string
+	size int
+}
+
+// NewKeyTable creates a new KeyTable that holds at most size keys.
+// Once the table is full, keys that are not in it yet are allocated
+// for each occurrence as usual. If size is 0 or less, the table grows
+// without limit, which should only be done if the set of keys found in
+// the documents is known to be small
+func NewKeyTable(size int) *KeyTable {
+	return &KeyTable{
+		keys: make(map[string]string),
+		size: size,
+	}
+}
+
+// Len returns the number of keys held in the table
+func (t *KeyTable) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.keys)
+}
+
+// intern returns the string held in the table that is equal to b,
+// adding it to the table if necessary
+func (t *KeyTable) intern(b []byte) string {
+	t.mu.RLock()
+	s, ok := t.keys[string(b)]
+	t.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if s, ok := t.keys[string(b)]; ok {
+		return s
+	}
+	s = string(b)
+	if t.size <= 0 || len(t.keys) < t.size {
+		t.keys[s] = s
+	}
+	return s
+}
+
+// key returns a string holding a copy of b, which is an object key.
+// If WithInternKeys or WithKeyTable was specified, the same string is
+// returned for every occurrence of the key
+func (t *Tokenizer) key(b []byte) string {
+	switch {
+	case t.keyTable != nil:
+		return t.keyTable.intern(b)
+	case t.keys != nil:
+		if s, ok := t.keys[string(b)]; ok {
+			return s
+		}
+		s := t.string(b)
+		t.keys[s] = s
+		return s
+	}
+	return t.string(b)
+}
@@ -0,0 +1,73 @@
+package json
+
+import (
+	"iter"
+	"reflect"
+	"sort"
+)
+
+// sortedMapKeys returns the keys of the map held in rv, sorted lexically
+func sortedMapKeys(rv reflect.Value) []reflect.Value {
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
+func (c *ctx) ForEach(fn func(string, int, Context) bool) error {
+	switch c.value.Kind() {
+	case reflect.Map:
+		for i, key := range c.keys() {
+			child, err := c.field(key)
+			if err != nil {
+				return err
+			}
+			if !fn(key, i, child) {
+				return nil
+			}
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < c.value.Len(); i++ {
+			child, err := c.indexChild(i)
+			if err != nil {
+				return err
+			}
+			if !fn("", i, child) {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	return c.typeError(InvalidValue, `cannot iterate`)
+}
+
+func (c *ctx) Entries() iter.Seq2[string, Context] {
+	return func(yield func(string, Context) bool) {
+		if c.value.Kind() != reflect.Map {
+			return
+		}
+		for _, key := range c.keys() {
+			if !yield(key, orErrCtx(c.field(key))) {
+				return
+			}
+		}
+	}
+}
+
+func (c *ctx) Elements() iter.Seq2[int, Context] {
+	return func(yield func(int, Context) bool) {
+		switch c.value.Kind() {
+		case reflect.Slice, reflect.Array:
+		default:
+			return
+		}
+		for i := 0; i < c.value.Len(); i++ {
+			if !yield(i, orErrCtx(c.indexChild(i))) {
+				return
+			}
+		}
+	}
+}
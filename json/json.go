@@ -2,32 +2,157 @@ package json
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	stdlib "encoding/json"
 	"fmt"
+	"io"
+	"iter"
+	"log/slog"
 	"reflect"
+	"strings"
 	"sync"
-
-	"github.com/pkg/errors"
 )
 
 var zeroval reflect.Value
 
 type Context interface {
+	// ApplyDefaults returns a new Context pointing to a copy of the
+	// value pointed by the Context, in which the missing fields have
+	// been filled with the default values declared by the JSON Schema
+	// held by schema, either in properties or in the schemas that they
+	// refer to. Defaults are applied recursively through properties,
+	// patternProperties, additionalProperties, prefixItems, items,
+	// allOf, and $ref, which must be a JSON pointer within the schema
+	// (such as `#/$defs/foo`), including within the values that have
+	// just been filled in. Missing objects without a default are not
+	// created, and values held by types other than those of the JSON
+	// model are left as is
+	ApplyDefaults(schema Context) Context
+
+	// Avg returns a Context pointing to the average of the numbers held
+	// in the underlying JSON array, computed as Sum does. The result is
+	// exact if it can be written as a decimal number, and is rounded to
+	// the precision of a float64 otherwise. If the array is empty, an
+	// invalid Context wrapping ErrIndexOutOfRange is returned
+	Avg() Context
+
 	// Bool assigns the value pointed by the Context to the specified
 	// destination, which must be a pointer to a variable compatible
-	// with bool.
+	// with bool, or a pointer to an empty interface.
 	// If the underlying value is not a boolean, an error will be returned
 	Bool(interface{}) error
 
+	// BuildIndex builds an index of the objects held in the underlying
+	// JSON array by the value of the named field, so that FindBy can
+	// look them up without scanning the array. Fields holding numbers,
+	// strings, booleans, or null are indexed, while objects lacking the
+	// field and other values are skipped.
+	//
+	// The index is held by this Context, and is not shared with other
+	// Contexts pointing to the same array, such as those returned again
+	// by MapIndex or Index: the caller must keep this Context, and call
+	// FindBy on it. FindBy rebuilds the index if the document has been
+	// modified through Set or SetMapIndex since it was built, on any
+	// Context pointing into it. Modifications made by other means, such
+	// as through maps or slices obtained from the document, are only
+	// detected if they add or remove elements, or change the element
+	// found, so BuildIndex must be called again after them.
+	// If the underlying value is not a JSON array, an error is returned
+	BuildIndex(field string) error
+
+	// Bytes assigns the value pointed by the Context to the specified
+	// destination, which must be a pointer to []byte. Byte strings,
+	// such as those decoded from CBOR, are assigned as is, and strings
+	// are decoded as base64, which is how encoding/json represents []byte.
+	// Otherwise an error is returned
+	Bytes(interface{}) error
+
+	// Count returns the number of elements of the underlying JSON array
+	// for which fn returns true, or the length of the array if fn is nil.
+	// If the underlying value is not a JSON array, an error is returned
+	Count(fn func(Context) bool) (int, error)
+
+	// Dump writes a human readable representation of the value pointed
+	// by the Context to w, for debugging. The value is indented, and
+	// may optionally be highlighted and annotated with types (see
+	// WithColor and WithTypeAnnotations). Unlike MarshalJSON, non-finite
+	// numbers and values that would otherwise be HTML-escaped are
+	// printed as is. The output format may change between versions
+	Dump(io.Writer, ...DumpOption) error
+
+	// Elements returns an iterator over the elements of the underlying
+	// JSON array, yielding the index and a Context pointing to each element.
+	// If the underlying value is not a JSON array, the iterator yields nothing
+	Elements() iter.Seq2[int, Context]
+
+	// Entries returns an iterator over the fields of the underlying
+	// JSON object in lexical order of the keys (or in their original
+	// order, see WithPreserveKeyOrder), yielding the key and
+	// a Context pointing to each value.
+	// If the underlying value is not a JSON object, the iterator yields nothing
+	Entries() iter.Seq2[string, Context]
+
+	// Err returns the error that made the Context invalid, such as a
+	// missing field in a chain of MapIndex and Index calls, so that it
+	// can be checked without calling an accessor. It returns nil if the
+	// Context points to a value
+	Err() error
+
+	// ExpandEnv returns a new Context pointing to a copy of the value
+	// pointed by the Context, in which `${VAR}` placeholders in strings
+	// have been replaced by the values returned by lookup, such as
+	// os.LookupEnv. `${VAR:-default}` uses default if VAR is undefined
+	// or empty, and `$${` produces a literal `${`. Placeholders referring
+	// to undefined variables are handled as specified by
+	// WithMissingVariables. Strings held by types other than those of
+	// the JSON model are not expanded
+	ExpandEnv(lookup func(string) (string, bool), options ...ExpandOption) Context
+
+	// FindBy returns a Context pointing to the first object in the
+	// underlying JSON array whose named field is equal to value. Numbers
+	// are compared by value, so 1, 1.0, and json.Number("1") are equal.
+	// If BuildIndex was called for the field, the index is used instead
+	// of scanning the array.
+	// If no element is found, or the underlying value is not a JSON
+	// array, an invalid Context is returned
+	FindBy(field string, value interface{}) Context
+
+	// FilterElems returns a new Context pointing to an array of the
+	// elements of the underlying JSON array for which fn returns true,
+	// in their original order. The elements are not copied. If the
+	// underlying value is not a JSON array, an invalid Context is
+	// returned
+	FilterElems(fn func(Context) bool) Context
+
 	// Float assigns the value pointed by the Context to the specified
 	// destination, which must be a pointer to a variable compatible
-	// with float64.
+	// with float64, or a pointer to an empty interface, which receives
+	// a float64.
 	// If the underlying value is not a floating point number, an error will be returned
 	Float(interface{}) error
 
+	// GroupBy groups the elements of the underlying JSON array by the
+	// value found at path in each of them, which uses the notation of
+	// Walk relative to the element, such as `$.user.id` (the leading `$`
+	// may be omitted). The groups are keyed by the string representation
+	// of the value: strings as is, numbers in their shortest exact
+	// decimal form (so that 1 and 1.0 share a group), booleans, and
+	// `null`. Keys holding objects or arrays are rejected with an error
+	// wrapping ErrTypeMismatch.
+	//
+	// By default, each group is a Context pointing to an array of the
+	// elements holding the key, in their original order, and an error
+	// wrapping ErrKeyNotFound is returned if an element does not hold a
+	// value at path. See WithDuplicateGroupKeys and WithMissingGroupKeys.
+	// The groups refer to the elements of the array rather than copies.
+	// If the underlying value is not a JSON array, an error is returned
+	GroupBy(path string, options ...GroupOption) (map[string]Context, error)
+
 	// Int assigns the value pointed by the Context to the specified
 	// destination, which must be a pointer to a variable compatible
-	// with int64.
+	// with int64, or a pointer to an empty interface, which receives
+	// an int64.
 	// If the underlying value is not an integer, an error will be returned
 	Int(interface{}) error
 
@@ -40,11 +165,65 @@ type Context interface {
 	// and calling methods on it will only return the original error
 	Index(int) Context
 
+	// ForEach calls fn for each element of the underlying container.
+	// If the value is a JSON object, fn is called for each field in
+	// lexical order of the keys (or in their original order, see
+	// WithPreserveKeyOrder), with the key and its ordinal position.
+	// If the value is a JSON array, fn is called for each element
+	// with an empty key and the element's index.
+	//
+	// Iteration stops when fn returns false. If the underlying value
+	// is not a container, an error is returned
+	ForEach(func(string, int, Context) bool) error
+
+	// Format implements fmt.Formatter. The %v, %s, and %q verbs print
+	// the compact JSON encoding of the value (%q quotes it as a Go
+	// string), and %#v prints the result of GoString. Because String
+	// is used to assign strings, Context does not implement fmt.Stringer.
+	// Invalid Contexts print a placeholder containing the error
+	fmt.Formatter
+
+	// GoString returns a representation of the underlying Go value
+	// that includes its types, for debugging
+	fmt.GoStringer
+
+	// Location returns the location of the value in the original
+	// input. Locations are only recorded when the input is parsed with
+	// WithLocations, and are not available for values that have been
+	// added or replaced after parsing
+	Location() (Location, error)
+
+	// MarshalAppend appends the JSON encoding of the value pointed by
+	// the Context to buf, in the same form as MarshalJSON, and returns
+	// the extended buffer. Reusing the buffer across calls avoids most
+	// of the allocations made by MarshalJSON. If an error occurs, buf
+	// is returned as is
+	MarshalAppend(buf []byte) ([]byte, error)
+
+	// MarshalIndent is like MarshalJSON, but formats the output in the
+	// same manner as encoding/json.MarshalIndent. The options that
+	// affect MarshalJSON (such as WithPreserveKeyOrder) are honored
+	MarshalIndent(prefix, indent string) ([]byte, error)
+
 	// Map returns the value as a Go map. If the underlying
 	// value is not a JSON object, then an error along with
 	// a nil value is returned.
 	Map(interface{}) error
 
+	// MapElems returns a new Context pointing to an array holding the
+	// results of fn applied to each element of the underlying JSON
+	// array. fn may return any value that can be held by a Context,
+	// including a Context, in which case the value that it points to is
+	// used. MapElems, FilterElems, and Reduce can be chained:
+	//
+	//	total := events.FilterElems(isPurchase).
+	//		MapElems(amount).
+	//		Sum()
+	//
+	// If fn returns an error, or if the underlying value is not a JSON
+	// array, an invalid Context is returned
+	MapElems(fn func(Context) (interface{}, error)) Context
+
 	// MapIndex returns a new JSON Context pointing to the value
 	// of the named field in the map
 	// For example, given a JSON object `{"foo": "bar"}`, you can
@@ -54,11 +233,72 @@ type Context interface {
 	// and calling methods on it will only return the original error
 	MapIndex(string) Context
 
+	// Max returns a Context pointing to the largest of the numbers held
+	// in the underlying JSON array, which are compared exactly. If
+	// several elements hold the largest number, the first one is
+	// returned. See Min for the errors returned
+	Max() Context
+
+	// Min returns a Context pointing to the smallest of the numbers held
+	// in the underlying JSON array, which are compared exactly. If
+	// several elements hold the smallest number, the first one is
+	// returned. NaN is smaller and larger than every other number.
+	// If the underlying value is not a JSON array, or holds values other
+	// than numbers, an invalid Context wrapping ErrTypeMismatch is
+	// returned, and if the array is empty, an invalid Context wrapping
+	// ErrIndexOutOfRange is returned
+	Min() Context
+
+	// OnChange registers fn to be called after a value is modified by
+	// Set or SetMapIndex, through this Context or through the Contexts
+	// derived from it via MapIndex, Index, etc. Modifications made
+	// through other Contexts pointing to the same values, or by
+	// modifying the values directly, are not observed. fn is called
+	// synchronously by the goroutine making the modification, and the
+	// Contexts that it receives are not attached to the document. The
+	// returned function unregisters fn
+	OnChange(fn ChangeFunc) func()
+
 	stdlib.Marshaler
 
+	// Pretty is a shorthand for MarshalIndent("", "  ")
+	Pretty() ([]byte, error)
+
+	// Reduce applies fn to each element of the underlying JSON array
+	// along with an accumulator, which initially points to init and then
+	// to the value returned by the previous call, and returns a Context
+	// pointing to the value returned by the last call (or to init if the
+	// array is empty). As with MapElems, fn may return a Context. If fn
+	// returns an error, or if the underlying value is not a JSON array,
+	// an invalid Context is returned
+	Reduce(fn func(acc, elem Context) (interface{}, error), init interface{}) Context
+
+	// Release makes the memory that the document was allocated from
+	// available for parsing other documents, if the Context was
+	// returned from Parse with WithArena. Otherwise it does nothing.
+	//
+	// After calling Release, neither the Context nor any value obtained
+	// from it, including strings and the Contexts derived from it, may
+	// be used any longer: their contents may be overwritten at any time
+	Release()
+
+	// Set replaces the value pointed by the Context, and SetMapIndex
+	// sets the named field of the underlying JSON object.
+	// If the new value contains a reference cycle, or refers to one of
+	// the containers holding it, the value is not set and an invalid
+	// Context is returned. Contexts pointing to a Version cannot be
+	// modified, and return an invalid Context wrapping ErrReadOnly
 	Set(interface{}) Context
 	SetMapIndex(string, interface{}) Context
 
+	// SizeEstimate returns the approximate number of bytes of memory
+	// held by the value pointed by the Context, including its strings,
+	// arrays, and objects. For the Context returned from Parse, the
+	// locations and key order recorded for the document are included.
+	// Memory that is shared, such as interned keys, is counted each time
+	// it is referred to, so the estimate tends to err on the high side
+	SizeEstimate() int64
+
 	// Slice assigns the value pointed by the Context to the specified
 	// destinatio, which must be a pointer to a slice variable
 	// compatible with the original slice.
@@ -72,11 +312,72 @@ type Context interface {
 	// If the values cannot be assigned, an error is returned
 	Slice(interface{}) error
 
+	// StreamElements sends a Context for each element of the underlying
+	// JSON array through the first returned channel, from a separate
+	// goroutine. The channel is unbuffered, so elements are only produced
+	// as fast as they are consumed.
+	//
+	// If an error occurs, or the given context.Context is canceled,
+	// the error is sent through the second channel. Both channels are
+	// closed once all elements have been sent, or an error has occurred
+	StreamElements(context.Context) (<-chan Context, <-chan error)
+
 	// String assigns the value pointed by the Context to the specified
 	// destination, which must be a pointer to a variable compatible
-	// with string
+	// with string, or a pointer to an empty interface.
 	// If the underlying value is not a JSON string, then an error is returned
 	String(interface{}) error
+
+	// Substitute returns a new Context pointing to a copy of the value
+	// pointed by the Context, in which placeholders such as `{{ .port }}`
+	// in strings have been replaced by the values found in vars, which
+	// may be a Context or a value that can be passed to New. Paths are
+	// dot-separated lists of keys and array indices, such as `.db.hosts.0`,
+	// and `{{ . }}` refers to vars itself.
+	//
+	// A string consisting of a single placeholder is replaced by the
+	// value itself, so that numbers, booleans, objects, and arrays keep
+	// their types. Placeholders surrounded by other text are replaced by
+	// the string value, or by the JSON encoding of other values.
+	// Placeholders referring to undefined paths are handled as specified
+	// by WithMissingVariables
+	Substitute(vars interface{}, options ...ExpandOption) Context
+
+	// Sum returns a Context pointing to a json.Number holding the sum of
+	// the numbers held in the underlying JSON array, which is 0 if the
+	// array is empty. Numbers are added exactly, whatever their Go type,
+	// so that adding json.Number values such as "0.1" and "0.2" yields
+	// "0.3". If any of the numbers is NaN or infinite, they are added as
+	// float64 values instead. If the underlying value is not a JSON
+	// array, or holds values other than numbers, an invalid Context
+	// wrapping ErrTypeMismatch is returned
+	Sum() Context
+
+	// Trace specifies the logger that records the navigation with
+	// MapIndex and Index and the accessors (such as String and Int)
+	// applied to this Context and to the Contexts derived from it
+	// afterwards, along with the path of the value and the outcome of
+	// the operation: the kind of the value, or the error. When a chain of
+	// operations fails, the operations that are skipped because of the
+	// failure are recorded as well. Records are logged at the debug level.
+	// Passing nil disables tracing. Trace returns the Context itself
+	Trace(logger *slog.Logger) Context
+
+	// Walk traverses the value pointed by the Context and all of its
+	// descendants depth-first, calling fn for each of them. Fields of
+	// JSON objects are visited in lexical order of the keys, or in
+	// their original order if WithPreserveKeyOrder was specified.
+	//
+	// The traversal can be controlled by the WalkAction returned from fn.
+	// If fn returns an error, the traversal stops and the error is returned
+	Walk(WalkFunc) error
+
+	// WriteTo writes the JSON encoding of the value pointed by the
+	// Context to w, in the same form as MarshalJSON. The output is
+	// written in chunks as it is generated, so the entire encoding is
+	// never held in memory. If an error occurs, part of the output may
+	// already have been written
+	io.WriterTo
 }
 
 var rdrPool = sync.Pool{
@@ -94,22 +395,43 @@ func releaseReader(b *bytes.Reader) {
 	rdrPool.Put(b)
 }
 
+var strRdrPool = sync.Pool{
+	New: func() interface{} {
+		return &strings.Reader{}
+	},
+}
+
+func getStringReader() *strings.Reader {
+	return strRdrPool.Get().(*strings.Reader)
+}
+
+func releaseStringReader(r *strings.Reader) {
+	r.Reset("")
+	strRdrPool.Put(r)
+}
+
 var emptyInterfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+var bytesType = reflect.TypeOf([]byte(nil))
 
-func assignIfCompatible(dst, src reflect.Value) error {
+func assignIfCompatible(dst, src reflect.Value, strict bool) error {
 	if dst.Kind() == reflect.Ptr {
 		dst = dst.Elem()
 	}
 
-	dstT := dst.Type()
-	srcT := src.Type()
-
 	if !dst.IsValid() {
-		return errors.New(`destination variable is not valid`)
+		return newAccessError(ErrNotAssignable, `destination variable is not valid`)
 	}
 
+	// null values held in containers have no type
+	if !src.IsValid() {
+		return assignError(dst.Type(), "", nil, `cannot assign to %s`, dst.Type())
+	}
+
+	dstT := dst.Type()
+	srcT := src.Type()
+
 	if !dst.CanSet() {
-		return errors.New(`destination variable is not assignable`)
+		return newAccessError(ErrNotAssignable, `destination variable is not assignable`)
 	}
 
 	// If it's an empty interface, just assign.
@@ -126,7 +448,11 @@ func assignIfCompatible(dst, src reflect.Value) error {
 
 	// If it's convertible, assign after conversion
 	if srcT.ConvertibleTo(dstT) {
-		dst.Set(src.Convert(dstT))
+		v, err := convertValue(src, dstT, strict)
+		if err != nil {
+			return err
+		}
+		dst.Set(v)
 		return nil
 	}
 
@@ -134,7 +460,7 @@ func assignIfCompatible(dst, src reflect.Value) error {
 	// have a container whose element types may differ. In that case
 	// the kind of dst and src must match
 	if dst.Kind() != src.Kind() {
-		return fmt.Errorf(`destination variable kind (%s) and source variable kind (%s) do not match`, dstT.Kind(), srcT.Kind())
+		return assignError(dstT, "", src.Interface(), `cannot assign to %s`, dstT)
 	}
 
 	// If it's a container that needs conversion... (array/slice or map)
@@ -150,26 +476,33 @@ func assignIfCompatible(dst, src reflect.Value) error {
 			// Otherwise we should have a slice/array type.
 			// If the destination has less capacity than source length, then we bail
 			if dst.Cap() < src.Len() {
-				return fmt.Errorf(`destination variable does not hold enough capacity (%d) to assign source (%d)`, dst.Cap(), src.Len())
+				return newAccessError(ErrNotAssignable, `destination variable does not hold enough capacity (%d) to assign source (%d)`, dst.Cap(), src.Len())
 			}
 
 			// We now know we have enough capacity. If the length don't match,
 			// make sure the destination slice has the same length as the source
-			for dst.Len() < src.Len() {
-				dst = reflect.AppendSlice(dst, reflect.Zero(dstElemT))
+			if dst.Len() < src.Len() {
+				dst.SetLen(src.Len())
 			}
 		}
 
 		// []interface{} is a special case, because we're going to need
 		// to get the actual type using Elem()
 		if srcElemT.Kind() == reflect.Interface {
+			if l, ok := src.Interface().([]interface{}); ok && assignInterfaceSlice(dst, l) {
+				return nil
+			}
 			for i := 0; i < src.Len(); i++ {
 				if src.Index(i).Elem().Type().AssignableTo(dstElemT) {
 					dst.Index(i).Set(src.Index(i).Elem())
 				} else if src.Index(i).Elem().Type().ConvertibleTo(dstElemT) {
-					dst.Index(i).Set(src.Index(i).Elem().Convert(dstElemT))
+					v, err := convertValue(src.Index(i).Elem(), dstElemT, strict)
+					if err != nil {
+						return newAccessError(ErrTypeMismatch, `failed to assign position %d of slice: %w`, i, err)
+					}
+					dst.Index(i).Set(v)
 				} else {
-					return fmt.Errorf(`cannot convert from %T to %T at position %d of slice`, src.Index(i).Elem(), dstElemT, i)
+					return assignError(dstElemT, indexPath("", i), src.Index(i).Elem().Interface(), `cannot assign position %d of slice to %s`, i, dstElemT)
 				}
 			}
 			return nil
@@ -178,79 +511,334 @@ func assignIfCompatible(dst, src reflect.Value) error {
 		// If dst and src element types match, then we only need to
 		// assign directly.
 		if srcElemT.AssignableTo(dstElemT) {
-			for i := 0; i < src.Len(); i++ {
-				dst.Index(i).Set(src.Index(i))
-			}
+			reflect.Copy(dst, src)
 			return nil
 		}
 
 		// If conversion is necessary, do that
 		if srcElemT.ConvertibleTo(dstElemT) {
 			for i := 0; i < src.Len(); i++ {
-				dst.Index(i).Set(src.Index(i).Convert(dstElemT))
+				v, err := convertValue(src.Index(i), dstElemT, strict)
+				if err != nil {
+					return newAccessError(ErrTypeMismatch, `failed to assign position %d of slice: %w`, i, err)
+				}
+				dst.Index(i).Set(v)
 			}
 			return nil
 		}
 
 		// Sometime a good old type conversion is all we need
 
-		return errors.New(`ARGH`)
+		return assignError(dstT, "", src.Interface(), `cannot assign to %s`, dstT)
 	case reflect.Map:
 		dstElemT := dstT.Elem()
 
 		if dst.IsNil() {
 			// If the destination is nil, initialize it as a map specified
 			// by dst's Type
-			dst.Set(reflect.MakeMapWithSize(dstT, len(src.MapKeys())))
+			dst.Set(reflect.MakeMapWithSize(dstT, src.Len()))
 		}
 
 		// map[*]interface{} is a special case, because we're going to need
 		// to get the actual type using Elem()
 		if src.Type().Elem().Kind() == reflect.Interface {
-			for _, key := range src.MapKeys() {
-				srcv := src.MapIndex(key).Elem() // Elem() to get the value underneath the interface{}
+			if m, ok := src.Interface().(map[string]interface{}); ok && assignInterfaceMap(dst, m) {
+				return nil
+			}
+			iter := src.MapRange()
+			for iter.Next() {
+				key := iter.Key()
+				srcv := iter.Value().Elem() // Elem() to get the value underneath the interface{}
 				if srcv.Type().AssignableTo(dstElemT) {
 					dst.SetMapIndex(key, srcv)
 				} else if srcv.Type().ConvertibleTo(dstElemT) {
-					dst.SetMapIndex(key, srcv.Convert(dstElemT))
+					v, err := convertValue(srcv, dstElemT, strict)
+					if err != nil {
+						return newAccessError(ErrTypeMismatch, `failed to assign key %#v of map: %w`, key.Interface(), err)
+					}
+					dst.SetMapIndex(key, v)
 				} else {
-					return fmt.Errorf(`cannot convert from %T to %T from key %#v of map`, srcv, dst.MapIndex(key), key.Interface())
+					return assignError(dstElemT, keyPath("", key.String()), srcv.Interface(), `cannot assign key %#v of map to %s`, key.Interface(), dstElemT)
 				}
 			}
 			return nil
 		}
 
 		// TODO I'm obviously getting tired of writing code. punting
-		for _, key := range src.MapKeys() {
-			dst.SetMapIndex(key, src.MapIndex(key))
+		iter := src.MapRange()
+		for iter.Next() {
+			dst.SetMapIndex(iter.Key(), iter.Value())
+		}
+		return nil
+	}
+
+	return assignError(dstT, "", src.Interface(), `cannot assign to %s`, dstT)
+}
+
+// assignInterfaceSlice assigns the elements of src to those of dst
+// without reflection, if dst is a slice of one of the types that
+// parsed documents hold, and all elements of src are of that exact
+// type. Otherwise false is returned, and some elements may have been
+// assigned. dst must be at least as long as src
+func assignInterfaceSlice(dst reflect.Value, src []interface{}) bool {
+	if !dst.CanAddr() {
+		return false
+	}
+
+	// the pointer is taken so that the slice header is not copied
+	switch d := dst.Addr().Interface().(type) {
+	case *[]interface{}:
+		copy(*d, src)
+		return true
+	case *[]string:
+		return assignElements(*d, src)
+	case *[]bool:
+		return assignElements(*d, src)
+	case *[]float64:
+		return assignElements(*d, src)
+	case *[]int64:
+		return assignElements(*d, src)
+	case *[]stdlib.Number:
+		return assignElements(*d, src)
+	}
+	return false
+}
+
+func assignElements[T any](dst []T, src []interface{}) bool {
+	for i, v := range src {
+		t, ok := v.(T)
+		if !ok {
+			return false
 		}
+		dst[i] = t
 	}
+	return true
+}
 
-	return errors.New(`invalid type`)
+// assignInterfaceMap is the same as assignInterfaceSlice, for maps
+func assignInterfaceMap(dst reflect.Value, src map[string]interface{}) bool {
+	switch d := dst.Interface().(type) {
+	case map[string]interface{}:
+		for k, v := range src {
+			d[k] = v
+		}
+		return true
+	case map[string]string:
+		return assignEntries(d, src)
+	case map[string]bool:
+		return assignEntries(d, src)
+	case map[string]float64:
+		return assignEntries(d, src)
+	case map[string]int64:
+		return assignEntries(d, src)
+	case map[string]stdlib.Number:
+		return assignEntries(d, src)
+	}
+	return false
 }
 
-func New(v interface{}) Context {
-	return newCtx(v)
+func assignEntries[T any](dst map[string]T, src map[string]interface{}) bool {
+	for k, v := range src {
+		t, ok := v.(T)
+		if !ok {
+			return false
+		}
+		dst[k] = t
+	}
+	return true
 }
 
-func Parse(data []byte) (Context, error) {
-	var v interface{}
+// New creates a new Context pointing to v, which can be used to build
+// a JSON document. If v contains a reference cycle, the returned
+// Context is invalid, and calling methods on it will only return the error.
+//
+// If WithPreserveKeyOrder is specified, the fields added to JSON
+// objects via SetMapIndex are marshaled and iterated over in the order
+// in which they were added
+func New(v interface{}, options ...Option) Context {
+	if err := checkCycle(v, nil); err != nil {
+		return newErrCtx(err)
+	}
+
+	c := newCtx(v)
+	for _, option := range options {
+		switch option.Name() {
+		case optKeyPreserveKeyOrder:
+			if option.Value().(bool) {
+				c.order = newKeyOrder()
+				c.order.attach(c)
+			}
+		case optKeyTimeLayout, optKeyTimeEpoch:
+			if f, ok := timeFormatOption(option); ok {
+				c.timeFormat = f
+			}
+		case optKeyMarshalFunc:
+			c.marshalers = c.marshalers.with(option.Value().(marshalFunc))
+		case optKeyNonFiniteNumbers:
+			c.nonFinite = option.Value().(bool)
+		case optKeyStrictNumbers:
+			c.strictNumbers = option.Value().(bool)
+		case optKeyMetrics:
+			c.metrics = option.Value().(Metrics)
+		}
+	}
+	return c
+}
+
+// Parse parses the JSON value in data.
+//
+// The behavior of the parser may be configured by passing ParseOptions,
+// such as WithLazy, WithUseNumber, and WithMaxSize
+func Parse(data []byte, options ...ParseOption) (Context, error) {
+	cfg := newParseConfig(options)
+	if cfg.maxSize >= 0 && int64(len(data)) > cfg.maxSize {
+		return nil, fmt.Errorf(`input exceeds maximum size of %d bytes: %w`, cfg.maxSize, ErrLimitExceeded)
+	}
 
 	r := getReader()
 	defer releaseReader(r)
 
 	r.Reset(data)
-	dec := stdlib.NewDecoder(r)
-	dec.UseNumber()
+	return parse(r, data, cfg)
+}
 
-	if err := dec.Decode(&v); err != nil {
-		return nil, errors.Wrap(err, `failed to unmarshal JSON`)
+// ParseString parses the JSON value in s. It is equivalent to
+// Parse([]byte(s)), but avoids copying s into a byte slice
+func ParseString(s string, options ...ParseOption) (Context, error) {
+	r := getStringReader()
+	defer releaseStringReader(r)
+
+	r.Reset(s)
+	return parse(r, nil, newParseConfig(options))
+}
+
+// ParseReader parses the JSON value read from r. The input is read
+// until the end of the first JSON value.
+//
+// The WithMaxSize option may be used to limit the number of bytes
+// read from r. If the input is larger than the limit, an error is returned
+func ParseReader(r io.Reader, options ...ParseOption) (Context, error) {
+	return parse(r, nil, newParseConfig(options))
+}
+
+// parse parses the first JSON value read from r. If data is non-nil,
+// it must hold the entire contents of r
+func parse(r io.Reader, data []byte, cfg *parseConfig) (c Context, err error) {
+	if cfg.metrics != nil {
+		var done func(error)
+		r, done = observeParse(r, cfg.metrics)
+		defer func() { done(err) }()
+	}
+
+	if len(cfg.decompressors) > 0 {
+		dr, err := decompress(r, cfg.decompressors)
+		if err != nil {
+			return nil, err
+		}
+		// data holds the compressed input, which is of no use
+		r = dr
+		data = nil
+	}
+
+	if cfg.maxSize >= 0 {
+		r = &sizeLimitedReader{src: r, remaining: cfg.maxSize, max: cfg.maxSize}
+	}
+
+	// lazy decoding requires the raw input to be available
+	if cfg.deferred() && data == nil {
+		buf, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to read input: %w`, err)
+		}
+		data = buf
+		r = bytes.NewReader(buf)
+	}
+
+	d := newDecoder(NewTokenizer(r), cfg)
+	d.setInput(data)
+	if cfg.projection != nil {
+		proj, err := newProjection(cfg.projection)
+		if err != nil {
+			return nil, err
+		}
+		d.proj = proj
+	}
+
+	v, err := d.decodeValue()
+	if err != nil {
+		if err == io.EOF {
+			err = &SyntaxError{msg: `unexpected end of JSON input`}
+		}
+		return nil, fmt.Errorf(`failed to unmarshal JSON: %w`, err)
 	}
 
-	return newCtx(v), nil
+	if cfg.disallowTrailingData {
+		if err := d.t.ensureEOF(); err != nil {
+			return nil, fmt.Errorf(`failed to unmarshal JSON: %w`, err)
+		}
+	}
+
+	return d.context(v), nil
+}
+
+// sizeLimitedReader reads from src, and fails if more than max
+// bytes are available
+type sizeLimitedReader struct {
+	src       io.Reader
+	remaining int64
+	max       int64
 }
 
-func (c *ctx) Slice(dst interface{}) error {
+func (r *sizeLimitedReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		// check if there is more data available in the source
+		var b [1]byte
+		n, err := r.src.Read(b[:])
+		if n > 0 {
+			return 0, fmt.Errorf(`input exceeds maximum size of %d bytes: %w`, r.max, ErrLimitExceeded)
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.src.Read(p)
+	r.remaining -= int64(n)
+	return n, err
+}
+
+// ParseAll parses all of the concatenated top-level JSON values in
+// data (e.g. `{...}{...}[...]`), and returns a Context for each of them
+func ParseAll(data []byte, options ...ParseOption) ([]Context, error) {
+	r := getReader()
+	defer releaseReader(r)
+
+	r.Reset(data)
+	dec := NewDecoder(r, options...)
+
+	var list []Context
+	for {
+		c, err := dec.Decode()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf(`failed to parse value #%d: %w`, len(list)+1, err)
+		}
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+func (c *ctx) Slice(dst interface{}) (err error) {
+	defer c.traceAccess(`Slice`, &err)
+	defer c.annotate(&err)
+
+	if err := checkDestination(dst, `a slice or array`); err != nil {
+		return err
+	}
+
 	rv := reflect.ValueOf(dst)
 	// rv must be a pointer to a slice or array
 	switch {
@@ -260,16 +848,34 @@ func (c *ctx) Slice(dst interface{}) error {
 		switch rv.Type().Elem().Kind() {
 		case reflect.Slice, reflect.Array:
 		default:
-			return fmt.Errorf(`destination must be a pointer to a slice/array (%T)`, dst)
+			return newAccessError(ErrNotAssignable, `destination must be a pointer to a slice/array (%T)`, dst)
 		}
-	default:
-		return fmt.Errorf(`destination must be a pointer to a slice/array (%T)`, dst)
 	}
 
-	return assignIfCompatible(rv, c.value)
+	if _, err := c.modelValue(); err != nil {
+		return err
+	}
+	return assignIfCompatible(rv, c.assignedValue(), c.strictNumbers)
+}
+
+// assignedValue returns the value assigned by Map and Slice. The
+// containers of read-only documents are shared by several versions, so
+// a copy is assigned instead
+func (c *ctx) assignedValue() reflect.Value {
+	if c.readOnly {
+		return reflect.ValueOf(copyContainers(c.interfaceValue()))
+	}
+	return c.value
 }
 
-func (c *ctx) Map(dst interface{}) error {
+func (c *ctx) Map(dst interface{}) (err error) {
+	defer c.traceAccess(`Map`, &err)
+	defer c.annotate(&err)
+
+	if err := checkDestination(dst, `a map`); err != nil {
+		return err
+	}
+
 	rv := reflect.ValueOf(dst)
 	// rv must be a pointer to a map
 	switch {
@@ -278,36 +884,116 @@ func (c *ctx) Map(dst interface{}) error {
 	case rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface:
 		// var m map[...]...
 		if rv.Type().Elem().Kind() != reflect.Map {
-			return fmt.Errorf(`destination must be a pointer to a map (%T)`, dst)
+			return newAccessError(ErrNotAssignable, `destination must be a pointer to a map (%T)`, dst)
 		}
 		// We also only support string keys
 		if rv.Type().Elem().Key().Kind() != reflect.String {
-			return fmt.Errorf(`destination map must use a string key`)
+			return newAccessError(ErrNotAssignable, `destination map must use a string key`)
 		}
-	default:
-		return fmt.Errorf(`destination must be a pointer to a map (%T)`, dst)
 	}
 
-	return assignIfCompatible(rv, c.value)
+	if _, err := c.modelValue(); err != nil {
+		return err
+	}
+	return assignIfCompatible(rv, c.assignedValue(), c.strictNumbers)
 }
 
-func (c *ctx) Bool(dst interface{}) error {
+func (c *ctx) Bool(dst interface{}) (err error) {
+	defer c.traceAccess(`Bool`, &err)
+	defer c.annotate(&err)
+
+	// fast paths for the most common destinations, which avoid reflection
+	switch dst := dst.(type) {
+	case *bool:
+		if b, ok := c.interfaceValue().(bool); ok && dst != nil {
+			*dst = b
+			return nil
+		}
+	case *interface{}:
+		if dst != nil {
+			if c.value.Kind() != reflect.Bool {
+				return c.typeError(BoolValue, `cannot assign to %T`, dst)
+			}
+			*dst = c.value.Bool()
+			return nil
+		}
+	}
+
+	if err := checkDestination(dst, `bool`); err != nil {
+		return err
+	}
+
 	rv := reflect.ValueOf(dst)
 
 	switch {
 	case rv.Type() == emptyInterfaceType:
 	case rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface:
 		if rv.Type().Elem().Kind() != reflect.Bool {
-			return fmt.Errorf(`destination must be a pointer to bool (%T)`, dst)
+			return newAccessError(ErrNotAssignable, `destination must be a pointer to bool (%T)`, dst)
 		}
-	default:
-		return fmt.Errorf(`destination must be a pointer to bool (%T)`, dst)
 	}
 
-	return assignIfCompatible(rv, c.value)
+	return assignIfCompatible(rv, c.value, c.strictNumbers)
+}
+
+func (c *ctx) Bytes(dst interface{}) (err error) {
+	defer c.traceAccess(`Bytes`, &err)
+	defer c.annotate(&err)
+
+	if err := checkDestination(dst, `[]byte`); err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(dst)
+	if rv.Type().Elem() != bytesType {
+		return newAccessError(ErrNotAssignable, `destination must be a pointer to []byte (%T)`, dst)
+	}
+
+	switch v := c.interfaceValue().(type) {
+	case []byte:
+		rv.Elem().SetBytes(v)
+	case string:
+		b, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return fmt.Errorf(`failed to decode base64 string: %w`, err)
+		}
+		rv.Elem().SetBytes(b)
+	default:
+		return valueTypeError(StringValue, "", v, `cannot assign to %T`, dst)
+	}
+	return nil
 }
 
-func (c *ctx) Float(dst interface{}) error {
+func (c *ctx) Float(dst interface{}) (err error) {
+	defer c.traceAccess(`Float`, &err)
+	defer c.annotate(&err)
+
+	// fast paths for the most common destinations, which avoid reflection
+	switch dst := dst.(type) {
+	case *float64:
+		if dst != nil {
+			f, err := c.float()
+			if err != nil {
+				return err
+			}
+			*dst = f
+			return nil
+		}
+	case *interface{}:
+		if dst != nil {
+			f, err := c.float()
+			if err != nil {
+				return err
+			}
+			*dst = f
+			return nil
+		}
+	}
+
+	if err := checkDestination(dst, `float32 or float64`); err != nil {
+		return err
+	}
+
 	rv := reflect.ValueOf(dst)
 
 	switch {
@@ -316,26 +1002,60 @@ func (c *ctx) Float(dst interface{}) error {
 		switch rv.Type().Elem().Kind() {
 		case reflect.Float32, reflect.Float64:
 		default:
-			return fmt.Errorf(`destination must be a pointer to float32/float64 (%T)`, dst)
+			return newAccessError(ErrNotAssignable, `destination must be a pointer to float32/float64 (%T)`, dst)
 		}
-	default:
-		return fmt.Errorf(`destination must be a pointer to float32/float64 (%T)`, dst)
 	}
 
-	n, ok := c.value.Interface().(stdlib.Number)
-	if !ok {
-		return fmt.Errorf(`failed to assert %T into a json.Number type`, c.value.Interface())
-	}
-
-	f, err := n.Float64()
+	f, err := c.float()
 	if err != nil {
-		return fmt.Errorf(`failed to convert json.Number into float64: %s`, err)
+		return err
 	}
 
-	return assignIfCompatible(rv, reflect.ValueOf(f))
+	return assignIfCompatible(rv, reflect.ValueOf(f), c.strictNumbers)
 }
 
-func (c *ctx) Int(dst interface{}) error {
+func (c *ctx) Int(dst interface{}) (err error) {
+	defer c.traceAccess(`Int`, &err)
+	defer c.annotate(&err)
+
+	// fast paths for the most common destinations, which avoid reflection
+	switch dst := dst.(type) {
+	case *int:
+		if dst != nil {
+			i, err := toInt64(c.interfaceValue())
+			if err != nil {
+				return err
+			}
+			if c.strictNumbers && int64(int(i)) != i {
+				return newAccessError(ErrTypeMismatch, `cannot convert %d to int without changing its value`, i)
+			}
+			*dst = int(i)
+			return nil
+		}
+	case *int64:
+		if dst != nil {
+			i, err := toInt64(c.interfaceValue())
+			if err != nil {
+				return err
+			}
+			*dst = i
+			return nil
+		}
+	case *interface{}:
+		if dst != nil {
+			i, err := toInt64(c.interfaceValue())
+			if err != nil {
+				return err
+			}
+			*dst = i
+			return nil
+		}
+	}
+
+	if err := checkDestination(dst, `an integer type`); err != nil {
+		return err
+	}
+
 	rv := reflect.ValueOf(dst)
 
 	switch {
@@ -344,109 +1064,387 @@ func (c *ctx) Int(dst interface{}) error {
 		switch rv.Type().Elem().Kind() {
 		case reflect.Int, reflect.Int8, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint32, reflect.Uint64:
 		default:
-			return fmt.Errorf(`destination must be a pointer to int/int8/int32/int64/uint/uint8/uint16/uint32/uint64 (%T)`, dst)
+			return newAccessError(ErrNotAssignable, `destination must be a pointer to int/int8/int32/int64/uint/uint8/uint16/uint32/uint64 (%T)`, dst)
 		}
-	default:
-		return fmt.Errorf(`destination must be a pointer to int/int8/int32/int64/uint/uint8/uint16/uint32/uint64 (%T)`, dst)
 	}
 
-	n, ok := c.value.Interface().(stdlib.Number)
-	if !ok {
-		return fmt.Errorf(`failed to assert %T into a json.Number type`, c.value.Interface())
-	}
-
-	i, err := n.Int64()
+	i, err := toInt64(c.interfaceValue())
 	if err != nil {
-		return fmt.Errorf(`failed to convert json.Number into int: %s`, err)
+		return err
 	}
 
-	return assignIfCompatible(rv, reflect.ValueOf(i))
+	return assignIfCompatible(rv, reflect.ValueOf(i), c.strictNumbers)
 }
 
-func (c *ctx) String(dst interface{}) error {
+func (c *ctx) String(dst interface{}) (err error) {
+	defer c.traceAccess(`String`, &err)
+	defer c.annotate(&err)
+
+	// fast paths for the most common destinations, which avoid reflection
+	switch dst := dst.(type) {
+	case *string:
+		if s, ok := c.interfaceValue().(string); ok && dst != nil {
+			*dst = s
+			return nil
+		}
+	case *interface{}:
+		if dst != nil {
+			if c.value.Kind() != reflect.String {
+				return c.typeError(StringValue, `cannot assign to %T`, dst)
+			}
+			*dst = c.value.String()
+			return nil
+		}
+	}
+
+	if err := checkDestination(dst, `string`); err != nil {
+		return err
+	}
+
 	rv := reflect.ValueOf(dst)
 
 	switch {
 	case rv.Type() == emptyInterfaceType:
 	case rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface:
 		if rv.Type().Elem().Kind() != reflect.String {
-			return fmt.Errorf(`destination must be a pointer to string (%T)`, dst)
+			return newAccessError(ErrNotAssignable, `destination must be a pointer to string (%T)`, dst)
 		}
-	default:
-		return fmt.Errorf(`destination must be a pointer to string (%T)`, dst)
 	}
 
-	return assignIfCompatible(rv, c.value)
+	return assignIfCompatible(rv, c.value, c.strictNumbers)
+}
+
+func (c *ctx) Err() error {
+	return nil
 }
 
 func (c *ctx) MapIndex(n string) Context {
+	if c.tracer != nil {
+		return c.traceNavigation(`MapIndex`, keyPath(c.location(), n), c.lookupKey(n))
+	}
+	return c.lookupKey(n)
+}
+
+func (c *ctx) lookupKey(n string) Context {
 	if c.value.Kind() != reflect.Map {
-		return newErrCtx(fmt.Errorf(`cannot access field %#v of non-map type (%T)`, n, c.value.Interface()))
+		return newErrCtx(c.typeError(ObjectValue, `cannot access field %#v`, n))
 	}
 
-	keyV := reflect.ValueOf(n)
-	v := c.value.MapIndex(keyV)
+	// parsed documents hold map[string]interface{} values, which can be
+	// accessed without reflection
+	if m, ok := c.value.Interface().(map[string]interface{}); ok {
+		v, ok := m[n]
+		if !ok {
+			return newErrCtx(c.accessError(ErrKeyNotFound, `field %#v not found`, n))
+		}
+		return orErrCtx(c.mapChild(n, v))
+	}
+
+	v := c.value.MapIndex(reflect.ValueOf(n))
 	if v == zeroval {
-		return newErrCtx(fmt.Errorf(`field %#v not found`, n))
+		return newErrCtx(c.accessError(ErrKeyNotFound, `field %#v not found`, n))
 	}
 
-	c2 := newCtx(v.Interface())
+	return orErrCtx(c.mapChild(n, v.Interface()))
+}
 
-	parent := c.value
-	c2.set = func(v reflect.Value) {
-		parent.SetMapIndex(keyV, v)
+// orErrCtx returns c, or an invalid Context wrapping err if it is non-nil
+func orErrCtx(c *ctx, err error) Context {
+	if err != nil {
+		return newErrCtx(err)
+	}
+	return c
+}
+
+// field returns a Context for the field key of the map held by c,
+// which must exist
+func (c *ctx) field(key string) (*ctx, error) {
+	if m, ok := c.value.Interface().(map[string]interface{}); ok {
+		return c.mapChild(key, m[key])
+	}
+	return c.mapChild(key, c.value.MapIndex(reflect.ValueOf(key)).Interface())
+}
+
+// mapChild creates a new Context for the value v stored under key
+// in the map held by c. Calling Set() on the child updates the map.
+// An error is returned if v was deferred, and fails to decode
+func (c *ctx) mapChild(key string, v interface{}) (*ctx, error) {
+	if c.lazy != nil {
+		if raw, ok := v.(rawValue); ok {
+			decoded, err := decodeLazy(raw, c.lazy)
+			if err != nil {
+				return nil, lazyError(keyPath(c.location(), key), err)
+			}
+			v = decoded
+			c.value.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(v))
+		}
 	}
+	c2 := c.child(v)
+	c2.key = key
+	c2.inMap = true
+	if c.locations != nil {
+		c2.path = keyPath(c.path, key)
+	}
+	return c2, nil
+}
+
+// lazyError returns the error reported when the deferred value found
+// at path fails to decode
+func lazyError(path string, err error) error {
+	return fmt.Errorf(`failed to decode deferred value at %s: %w`, path, err)
+}
+
+// child creates a new Context for the value v held by the container
+// pointed by c, which inherits the settings of c
+func (c *ctx) child(v interface{}) *ctx {
+	return &ctx{
+		value:         reflect.ValueOf(v),
+		parent:        c,
+		lazy:          c.lazy,
+		mapping:       c.mapping,
+		nonFinite:     c.nonFinite,
+		strictNumbers: c.strictNumbers,
+		locations:     c.locations,
+		order:         c.order,
+		timeFormat:    c.timeFormat,
+		marshalers:    c.marshalers,
+		metrics:       c.metrics,
+		tracer:        c.tracer,
+		readOnly:      c.readOnly,
+		generation:    c.generation,
+	}
+}
+
+// detached creates a new Context for the value v found in the document
+// held by c, which inherits the settings of c but is not attached to a
+// container, so that calling Set() on it does not modify the document
+func (c *ctx) detached(v interface{}) *ctx {
+	c2 := c.child(v)
+	c2.parent = nil
+	c2.locations = nil
 	return c2
 }
 
+// setChild stores v in the container held by c, at the location of
+// child. The zero Value stores null
+func (c *ctx) setChild(child *ctx, v reflect.Value) {
+	v = orZero(v, c.value.Type().Elem())
+	if child.inMap {
+		c.value.SetMapIndex(reflect.ValueOf(child.key), v)
+		return
+	}
+	c.value.Index(child.index).Set(v)
+}
+
 func (c *ctx) Index(i int) Context {
+	if c.tracer != nil {
+		return c.traceNavigation(`Index`, indexPath(c.location(), i), c.lookupIndex(i))
+	}
+	return c.lookupIndex(i)
+}
+
+func (c *ctx) lookupIndex(i int) Context {
 	switch c.value.Kind() {
 	case reflect.Slice, reflect.Array:
 	default:
-		return newErrCtx(fmt.Errorf(`cannot access index %d of non-slice/array type (%T)`, i, c.value.Interface()))
+		return newErrCtx(c.typeError(ArrayValue, `cannot access index %d`, i))
 	}
 
 	if i < 0 || c.value.Len() <= i {
 		// note: this particular error needs no stack, using fmt
-		return newErrCtx(fmt.Errorf(`index %d is out of bounds (len=%d)`, i, c.value.Len()))
+		return newErrCtx(c.accessError(ErrIndexOutOfRange, `index %d is out of bounds (len=%d)`, i, c.value.Len()))
 	}
 
-	v := c.value.Index(i)
-	c2 := newCtx(v.Interface())
+	return orErrCtx(c.indexChild(i))
+}
+
+// indexChild creates a new Context for the i-th element of the
+// slice/array held by c. Calling Set() on the child updates the element.
+// An error is returned if the element was deferred, and fails to decode
+func (c *ctx) indexChild(i int) (*ctx, error) {
+	// parsed documents hold []interface{} values, which can be accessed
+	// without reflection
+	if l, ok := c.value.Interface().([]interface{}); ok {
+		if c.lazy != nil {
+			if raw, ok := l[i].(rawValue); ok {
+				decoded, err := decodeLazy(raw, c.lazy)
+				if err != nil {
+					return nil, lazyError(indexPath(c.location(), i), err)
+				}
+				l[i] = decoded
+			}
+		}
+		c2 := c.child(l[i])
+		c2.index = i
+		if c.locations != nil {
+			c2.path = indexPath(c.path, i)
+		}
+		return c2, nil
+	}
 
-	parent := c.value
-	c2.set = func(v reflect.Value) {
-		parent.Index(i).Set(v)
+	v := c.value.Index(i)
+	if c.lazy != nil {
+		if raw, ok := v.Interface().(rawValue); ok {
+			decoded, err := decodeLazy(raw, c.lazy)
+			if err != nil {
+				return nil, lazyError(indexPath(c.location(), i), err)
+			}
+			v.Set(reflect.ValueOf(decoded))
+		}
 	}
-	return c2
+	c2 := c.child(v.Interface())
+	c2.index = i
+	if c.locations != nil {
+		c2.path = indexPath(c.path, i)
+	}
+	return c2, nil
 }
 
 func (c *ctx) Set(v interface{}) Context {
+	if c.readOnly {
+		return newErrCtx(c.accessError(ErrReadOnly, `cannot set value`))
+	}
+	if err := checkCycle(v, c.parent.ancestorRefs()); err != nil {
+		return newErrCtx(err)
+	}
+
+	var old *ctx
+	if c.observed() {
+		old = c.detached(c.currentValue())
+	}
+	c.modified()
+
 	if c.value == zeroval {
 		c.value = reflect.ValueOf(v)
 	} else {
-		if set := c.set; set != nil {
-			set(reflect.ValueOf(v))
+		if c.parent != nil {
+			c.parent.setChild(c, reflect.ValueOf(v))
 		} else {
 			if !c.value.CanSet() {
 				panic(fmt.Sprintf("%#v", c.value.Interface()))
 			}
-			c.value.Set(reflect.ValueOf(v))
+			c.value.Set(orZero(reflect.ValueOf(v), c.value.Type()))
 		}
 	}
+
+	if old != nil {
+		c.notifyChange(nil, old, c.detached(v))
+	}
 	return c
 }
 
 func (c *ctx) SetMapIndex(key string, value interface{}) Context {
 	if c.value.Kind() != reflect.Map {
-		return newErrCtx(fmt.Errorf(`cannot set field %#v of non-map type (%T)`, key, c.value.Interface()))
+		return newErrCtx(c.typeError(ObjectValue, `cannot set field %#v`, key))
+	}
+	if c.readOnly {
+		return newErrCtx(c.accessError(ErrReadOnly, `cannot set field %#v`, key))
+	}
+	if err := checkCycle(value, c.ancestorRefs()); err != nil {
+		return newErrCtx(err)
 	}
 
-	c.value.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+	keyV := reflect.ValueOf(key)
+	prev := c.value.MapIndex(keyV)
+	if c.order != nil && !prev.IsValid() {
+		c.order.add(c.value, key)
+	}
+	c.value.SetMapIndex(keyV, orZero(reflect.ValueOf(value), c.value.Type().Elem()))
+	c.modified()
 
+	if c.observed() {
+		var old *ctx
+		if prev.IsValid() {
+			old = c.detached(prev.Interface())
+		}
+		c.notifyChange([]pathSegment{{key: key}}, old, c.detached(value))
+	}
 	return c
 }
 
+// orZero returns v, or the zero value of t if v is the zero Value,
+// which is what reflect.ValueOf returns for nil. Storing the zero
+// Value would delete map fields instead of setting them to null
+func orZero(v reflect.Value, t reflect.Type) reflect.Value {
+	if !v.IsValid() {
+		return reflect.Zero(t)
+	}
+	return v
+}
+
 func (c *ctx) MarshalJSON() ([]byte, error) {
-	return stdlib.Marshal(c.value.Interface())
+	if c.customEncoding() {
+		e := c.getEncodeState()
+		defer releaseEncodeState(e)
+		if err := e.encode(c.interfaceValue()); err != nil {
+			return nil, err
+		}
+		return e.bytes(), nil
+	}
+	return stdlib.Marshal(c.interfaceValue())
+}
+
+func (c *ctx) MarshalAppend(buf []byte) ([]byte, error) {
+	e := c.newEncodeState()
+	e.buf = *bytes.NewBuffer(buf)
+	if err := e.encode(c.interfaceValue()); err != nil {
+		return buf, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+func (c *ctx) MarshalIndent(prefix, indent string) ([]byte, error) {
+	e := c.getEncodeState()
+	defer releaseEncodeState(e)
+	e.indented = true
+	e.prefix = prefix
+	e.indent = indent
+	if err := e.encode(c.interfaceValue()); err != nil {
+		return nil, err
+	}
+	return e.bytes(), nil
+}
+
+func (c *ctx) WriteTo(w io.Writer) (int64, error) {
+	e := c.getEncodeState()
+	defer releaseEncodeState(e)
+	e.w = w
+	if err := e.encode(c.interfaceValue()); err != nil {
+		return e.written, err
+	}
+	err := e.flush(true)
+	return e.written, err
+}
+
+func (c *ctx) Pretty() ([]byte, error) {
+	return c.MarshalIndent("", "  ")
+}
+
+func (c *ctx) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		io.WriteString(f, c.GoString())
+		return
+	}
+
+	buf, err := c.MarshalAppend(nil)
+	if err != nil {
+		formatError(f, err)
+		return
+	}
+	switch verb {
+	case 'v', 's', 'q':
+		fmt.Fprintf(f, fmt.FormatString(f, verb), string(buf))
+	default:
+		fmt.Fprintf(f, "%%!%c(json.Context=%s)", verb, buf)
+	}
+}
+
+func (c *ctx) GoString() string {
+	return fmt.Sprintf("json.Context(%#v)", c.interfaceValue())
+}
+
+// formatError prints the placeholder for Contexts that cannot be
+// encoded
+func formatError(f fmt.State, err error) {
+	fmt.Fprintf(f, "<invalid json.Context: %s>", err)
 }
@@ -0,0 +1,207 @@
+package json
+
+import (
+	stdlib "encoding/json"
+	"math/big"
+	"unicode/utf8"
+)
+
+// This file contains the parts of the Tokenizer that implement the
+// JSON5 syntax extensions. See Tokenizer.SetJSON5
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentifierPart(c byte) bool {
+	return isIdentifierStart(c) || isDigit(c)
+}
+
+// readIdentifier reads an unquoted object key. Only ASCII identifiers
+// are supported
+func (t *Tokenizer) readIdentifier() (Token, error) {
+	tok := t.startToken(KeyToken)
+	t.scratch = t.scratch[:0]
+	for {
+		c, ok := t.peekByte()
+		if !ok || !isIdentifierPart(c) {
+			break
+		}
+		if t.maxStringLength > 0 && len(t.scratch) >= t.maxStringLength {
+			return Token{}, t.limitError(`string length exceeds maximum of %d`, t.maxStringLength)
+		}
+		t.scratch = append(t.scratch, c)
+		t.advance()
+	}
+	tok.End = t.offset()
+	if !t.discard {
+		tok.Value = t.key(t.scratch)
+	}
+	return tok, nil
+}
+
+// readEscape5 reads the escape sequences that are only allowed in JSON5.
+// The current byte must be the one following the backslash
+func (t *Tokenizer) readEscape5(c byte) error {
+	switch {
+	case c == 'v':
+		t.scratch = append(t.scratch, '\v')
+	case c == '0':
+		t.advance()
+		if c, ok := t.peekByte(); ok && isDigit(c) {
+			return t.syntaxError(`invalid character %s in string escape code`, quoteChar(c))
+		}
+		t.scratch = append(t.scratch, 0)
+		return nil
+	case c == 'x':
+		t.advance()
+		var r rune
+		for i := 0; i < 2; i++ {
+			c, ok := t.peekByte()
+			if !ok {
+				return t.eofError()
+			}
+			v := hexValue(c)
+			if v < 0 {
+				return t.syntaxError(`invalid character %s in \x hexadecimal character escape`, quoteChar(c))
+			}
+			r = r*16 + v
+			t.advance()
+		}
+		t.scratch = utf8.AppendRune(t.scratch, r)
+		return nil
+	case c == '\n':
+		// line continuation: both the backslash and the newline are removed
+	case c == '\r':
+		t.advance()
+		if c, ok := t.peekByte(); ok && c == '\n' {
+			t.advance()
+		}
+		return nil
+	case isDigit(c):
+		return t.syntaxError(`invalid character %s in string escape code`, quoteChar(c))
+	default:
+		// any other character (including quotes) represents itself
+		t.scratch = append(t.scratch, c)
+	}
+	t.advance()
+	return nil
+}
+
+// readNumber5 reads a JSON5 number, and normalizes it to a valid
+// JSON number
+func (t *Tokenizer) readNumber5() (Token, error) {
+	tok := t.startToken(NumberToken)
+	t.scratch = t.scratch[:0]
+
+	// digits reads a run of zero or more digits, and reports
+	// whether any were read
+	digits := func() bool {
+		n := len(t.scratch)
+		for {
+			c, ok := t.peekByte()
+			if !ok || !isDigit(c) {
+				return len(t.scratch) > n
+			}
+			t.scratch = append(t.scratch, c)
+			t.advance()
+		}
+	}
+	invalid := func() error {
+		c, ok := t.peekByte()
+		if !ok {
+			return t.eofError()
+		}
+		return t.syntaxError(`invalid character %s in numeric literal`, quoteChar(c))
+	}
+
+	if c, _ := t.peekByte(); c == '+' || c == '-' {
+		if c == '-' {
+			t.scratch = append(t.scratch, c)
+		}
+		t.advance()
+	}
+
+	c, ok := t.peekByte()
+	if !ok {
+		return Token{}, t.eofError()
+	}
+	if c == 'I' && t.allowNonFinite {
+		return t.readSignedInfinity(tok)
+	}
+	hasInt := isDigit(c)
+	if c == '0' {
+		t.advance()
+		if c, ok := t.peekByte(); ok && (c == 'x' || c == 'X') {
+			t.advance()
+			return t.readHexNumber(tok)
+		}
+		t.scratch = append(t.scratch, '0')
+	} else if !digits() && c != '.' {
+		return Token{}, invalid()
+	}
+
+	if c, ok := t.peekByte(); ok && c == '.' {
+		t.advance()
+		if !hasInt {
+			t.scratch = append(t.scratch, '0')
+		}
+		point := len(t.scratch)
+		t.scratch = append(t.scratch, '.')
+		if !digits() {
+			if !hasInt {
+				return Token{}, invalid()
+			}
+			// a trailing decimal point is dropped
+			t.scratch = t.scratch[:point]
+		}
+	}
+
+	if c, ok := t.peekByte(); ok && (c == 'e' || c == 'E') {
+		t.scratch = append(t.scratch, c)
+		t.advance()
+		if c, ok := t.peekByte(); ok && (c == '+' || c == '-') {
+			t.scratch = append(t.scratch, c)
+			t.advance()
+		}
+		if !digits() {
+			return Token{}, invalid()
+		}
+	}
+
+	tok.End = t.offset()
+	if !t.discard {
+		tok.Value = stdlib.Number(t.scratch)
+	}
+	return tok, nil
+}
+
+// readHexNumber reads the digits of a hexadecimal number following
+// `0x`, and reports it as a decimal number. t.scratch must hold the
+// sign of the number, if any
+func (t *Tokenizer) readHexNumber(tok Token) (Token, error) {
+	sign := len(t.scratch)
+	for {
+		c, ok := t.peekByte()
+		if !ok || hexValue(c) < 0 {
+			break
+		}
+		t.scratch = append(t.scratch, c)
+		t.advance()
+	}
+	if len(t.scratch) == sign {
+		c, ok := t.peekByte()
+		if !ok {
+			return Token{}, t.eofError()
+		}
+		return Token{}, t.syntaxError(`invalid character %s in hexadecimal numeric literal`, quoteChar(c))
+	}
+
+	tok.End = t.offset()
+	if !t.discard {
+		var n big.Int
+		n.SetString(string(t.scratch[sign:]), 16)
+		tok.Value = stdlib.Number(string(t.scratch[:sign]) + n.String())
+	}
+	return tok, nil
+}
@@ -1,9 +1,28 @@
 package json_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
 	stdlib "encoding/json"
+	"errors"
+	"expvar"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unsafe"
 
 	"github.com/lestrrat-go/json"
 	"github.com/stretchr/testify/assert"
@@ -38,7 +57,7 @@ func ExampleParse() {
 	//OUTPUT:
 }
 
-func ExampleBuild() {
+func ExampleNew() {
 	j := json.New(map[string]interface{}{}).
 		SetMapIndex("foo", "bar").
 		SetMapIndex("number_int", 1).
@@ -109,6 +128,183 @@ func Benchmark(b *testing.B) {
 			_ = m
 		}
 	})
+	b.Run("Extract primitives", func(b *testing.B) {
+		j, err := json.ParseString(`{"s": "hello", "i": 1, "f": 1.5, "b": true}`)
+		if err != nil {
+			b.Errorf(`json.ParseString failed: %s`, err)
+			return
+		}
+		s, i, f, bl := j.MapIndex("s"), j.MapIndex("i"), j.MapIndex("f"), j.MapIndex("b")
+
+		var sv string
+		var iv int
+		var fv float64
+		var bv bool
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			if err := s.String(&sv); err != nil {
+				b.Errorf(`String failed: %s`, err)
+				return
+			}
+			if err := i.Int(&iv); err != nil {
+				b.Errorf(`Int failed: %s`, err)
+				return
+			}
+			if err := f.Float(&fv); err != nil {
+				b.Errorf(`Float failed: %s`, err)
+				return
+			}
+			if err := bl.Bool(&bv); err != nil {
+				b.Errorf(`Bool failed: %s`, err)
+				return
+			}
+		}
+	})
+	b.Run("Iterate over a document", func(b *testing.B) {
+		var sb strings.Builder
+		sb.WriteString(`[`)
+		for i := 0; i < 100; i++ {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprintf(&sb, `{"id":%d,"name":"item %d","price":%d.5,"tags":["a","b"]}`, i, i, i)
+		}
+		sb.WriteString(`]`)
+		j, err := json.ParseString(sb.String())
+		if err != nil {
+			b.Errorf(`json.ParseString failed: %s`, err)
+			return
+		}
+
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			var total float64
+			for _, item := range j.Elements() {
+				for key, field := range item.Entries() {
+					if key != "price" {
+						continue
+					}
+					var f float64
+					if err := field.Float(&f); err != nil {
+						b.Errorf(`Float failed: %s`, err)
+						return
+					}
+					total += f
+				}
+			}
+			_ = total
+		}
+	})
+	b.Run("Get a single field", func(b *testing.B) {
+		var sb strings.Builder
+		sb.WriteString(`{"items":[`)
+		for i := 0; i < 1000; i++ {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprintf(&sb, `{"id":%d,"name":"item %d","tags":["a","b","c"]}`, i, i)
+		}
+		sb.WriteString(`],"total":1000}`)
+		data := []byte(sb.String())
+
+		b.Run("Parse", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				j, err := json.Parse(data)
+				if err != nil {
+					b.Errorf(`json.Parse failed: %s`, err)
+					return
+				}
+				_ = j.MapIndex("items").Index(10).MapIndex("name")
+			}
+		})
+		b.Run("GetBytes", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := json.GetBytes(data, `$.items[10].name`); err != nil {
+					b.Errorf(`json.GetBytes failed: %s`, err)
+					return
+				}
+			}
+		})
+	})
+	b.Run("Marshal a document", func(b *testing.B) {
+		var sb strings.Builder
+		sb.WriteString(`[`)
+		for i := 0; i < 100; i++ {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprintf(&sb, `{"id":%d,"name":"item %d","tags":["a","b"]}`, i, i)
+		}
+		sb.WriteString(`]`)
+		j, err := json.ParseString(sb.String(), json.WithPreserveKeyOrder(true))
+		if err != nil {
+			b.Errorf(`json.ParseString failed: %s`, err)
+			return
+		}
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := j.MarshalJSON(); err != nil {
+				b.Errorf(`MarshalJSON failed: %s`, err)
+				return
+			}
+		}
+	})
+	b.Run("Marshal a large array", func(b *testing.B) {
+		l := make([]interface{}, 100000)
+		for i := range l {
+			l[i] = map[string]interface{}{"id": stdlib.Number(strconv.Itoa(i)), "name": fmt.Sprintf("item %d", i), "tags": []interface{}{"a", "b"}}
+		}
+		j := json.New(l)
+
+		b.Run("sequential", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := json.Marshal(j); err != nil {
+					b.Errorf(`json.Marshal failed: %s`, err)
+					return
+				}
+			}
+		})
+		b.Run("WithParallelism", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := json.Marshal(j, json.WithParallelism(0)); err != nil {
+					b.Errorf(`json.Marshal failed: %s`, err)
+					return
+				}
+			}
+		})
+	})
+	b.Run("Find an element", func(b *testing.B) {
+		var sb strings.Builder
+		sb.WriteString(`[`)
+		for i := 0; i < 10000; i++ {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprintf(&sb, `{"id":%d,"name":"item %d"}`, i, i)
+		}
+		sb.WriteString(`]`)
+		j, err := json.ParseString(sb.String())
+		if err != nil {
+			b.Errorf(`json.ParseString failed: %s`, err)
+			return
+		}
+
+		b.Run("FindBy", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = j.FindBy("id", i%10000)
+			}
+		})
+		b.Run("BuildIndex and FindBy", func(b *testing.B) {
+			if err := j.BuildIndex("id"); err != nil {
+				b.Errorf(`BuildIndex failed: %s`, err)
+				return
+			}
+			for i := 0; i < b.N; i++ {
+				_ = j.FindBy("id", i%10000)
+			}
+		})
+	})
 }
 
 func TestMap(t *testing.T) {
@@ -171,6 +367,28 @@ func TestMap(t *testing.T) {
 			return
 		}
 	})
+	t.Run("assign between typed maps", func(t *testing.T) {
+		j := json.New(map[string]string{"foo": "bar"})
+
+		var m map[string]string
+		if !assert.NoError(t, j.Map(&m), `j.Map should succeed`) {
+			return
+		}
+		if !assert.Equal(t, map[string]string{"foo": "bar"}, m, `values should match`) {
+			return
+		}
+	})
+	t.Run("assign to a map with mismatching types", func(t *testing.T) {
+		j, err := json.Parse([]byte(`{"foo": "bar", "baz": true}`))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+
+		var m map[string]string
+		if !assert.Error(t, j.Map(&m), `j.Map should fail`) {
+			return
+		}
+	})
 
 	t.Run("invalid data for Map", func(t *testing.T) {
 		const arraysrc = `["hello", 1, true, null, 1.234]`
@@ -196,6 +414,13 @@ func TestMap(t *testing.T) {
 			})
 		}
 	})
+	t.Run("null elements", func(t *testing.T) {
+		j := json.New([]interface{}{nil})
+		var m map[string]interface{}
+		if !assert.Error(t, j.Index(0).Map(&m), `j.Index.Map should fail`) {
+			return
+		}
+	})
 }
 
 func TestArray(t *testing.T) {
@@ -244,6 +469,45 @@ func TestArray(t *testing.T) {
 			return
 		}
 	})
+	t.Run("assigning to slices of other types", func(t *testing.T) {
+		j, err := json.Parse([]byte(`{"bools":[true,false],"numbers":[1,2.5],"mixed":["a",1]}`), json.WithUseNumber(false))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+
+		var bools []bool
+		if !assert.NoError(t, j.MapIndex("bools").Slice(&bools), `Slice should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []bool{true, false}, bools, `values should match`) {
+			return
+		}
+
+		// the destination is reused if it has enough capacity
+		numbers := make([]float64, 1, 4)
+		if !assert.NoError(t, j.MapIndex("numbers").Slice(&numbers), `Slice should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []float64{1, 2.5}, numbers, `values should match`) {
+			return
+		}
+		if !assert.Equal(t, 4, cap(numbers), `destination should be reused`) {
+			return
+		}
+
+		var converted []float32
+		if !assert.NoError(t, j.MapIndex("numbers").Slice(&converted), `Slice should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []float32{1, 2.5}, converted, `values should match`) {
+			return
+		}
+
+		var mixed []string
+		if !assert.Error(t, j.MapIndex("mixed").Slice(&mixed), `Slice should fail`) {
+			return
+		}
+	})
 }
 
 func TestBuild(t *testing.T) {
@@ -286,4 +550,4708 @@ func TestBuild(t *testing.T) {
 			return
 		}
 	})
+	t.Run("Set null values", func(t *testing.T) {
+		j := json.New(map[string]interface{}{"hello": "world", "list": []interface{}{1, 2}})
+
+		j.SetMapIndex("foo", nil)
+		j.MapIndex("hello").Set(nil)
+		j.MapIndex("list").Index(1).Set(nil)
+
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `j.MarshalJSON should succeed`) {
+			return
+		}
+
+		if !assert.Equal(t, `{"foo":null,"hello":null,"list":[1,null]}`, string(buf), `json string should match`) {
+			return
+		}
+	})
+}
+
+func TestForEach(t *testing.T) {
+	t.Run("object", func(t *testing.T) {
+		j, err := json.Parse([]byte(`{"foo": 1, "bar": 2, "baz": 3}`))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+
+		var keys []string
+		var values []int
+		err = j.ForEach(func(key string, idx int, v json.Context) bool {
+			if !assert.Equal(t, len(keys), idx, `idx should match`) {
+				return false
+			}
+			var i int
+			if !assert.NoError(t, v.Int(&i), `v.Int should succeed`) {
+				return false
+			}
+			keys = append(keys, key)
+			values = append(values, i)
+			return true
+		})
+		if !assert.NoError(t, err, `j.ForEach should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []string{"bar", "baz", "foo"}, keys, `keys should be sorted`) {
+			return
+		}
+		if !assert.Equal(t, []int{2, 3, 1}, values, `values should match`) {
+			return
+		}
+	})
+	t.Run("array", func(t *testing.T) {
+		j := json.New([]interface{}{"one", "two", "three"})
+
+		var values []string
+		err := j.ForEach(func(key string, idx int, v json.Context) bool {
+			if !assert.Equal(t, "", key, `key should be empty`) {
+				return false
+			}
+			var s string
+			if !assert.NoError(t, v.String(&s), `v.String should succeed`) {
+				return false
+			}
+			values = append(values, s)
+			return idx < 1
+		})
+		if !assert.NoError(t, err, `j.ForEach should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []string{"one", "two"}, values, `iteration should stop after returning false`) {
+			return
+		}
+	})
+	t.Run("set through child", func(t *testing.T) {
+		j := json.New(map[string]interface{}{"foo": "bar"})
+		_ = j.ForEach(func(_ string, _ int, v json.Context) bool {
+			v.Set("baz")
+			return true
+		})
+
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `j.MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"foo":"baz"}`, string(buf), `json string should match`) {
+			return
+		}
+	})
+	t.Run("non-container", func(t *testing.T) {
+		j := json.New("foo")
+		if !assert.Error(t, j.ForEach(func(string, int, json.Context) bool { return true }), `j.ForEach should fail`) {
+			return
+		}
+		if !assert.Error(t, j.MapIndex("foo").ForEach(func(string, int, json.Context) bool { return true }), `j.MapIndex.ForEach should fail`) {
+			return
+		}
+	})
+}
+
+func TestIterators(t *testing.T) {
+	t.Run("Entries", func(t *testing.T) {
+		j, err := json.Parse([]byte(`{"foo": "a", "bar": "b", "baz": "c"}`))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+
+		var keys, values []string
+		for k, v := range j.Entries() {
+			var s string
+			if !assert.NoError(t, v.String(&s), `v.String should succeed`) {
+				return
+			}
+			keys = append(keys, k)
+			values = append(values, s)
+		}
+		if !assert.Equal(t, []string{"bar", "baz", "foo"}, keys, `keys should be sorted`) {
+			return
+		}
+		if !assert.Equal(t, []string{"b", "c", "a"}, values, `values should match`) {
+			return
+		}
+	})
+	t.Run("Elements", func(t *testing.T) {
+		j, err := json.Parse([]byte(`[1, 2, 3, 4]`))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+
+		var values []int
+		for i, v := range j.Elements() {
+			if i > 2 {
+				break
+			}
+			var n int
+			if !assert.NoError(t, v.Int(&n), `v.Int should succeed`) {
+				return
+			}
+			values = append(values, n)
+		}
+		if !assert.Equal(t, []int{1, 2, 3}, values, `values should match`) {
+			return
+		}
+	})
+	t.Run("mismatched types yield nothing", func(t *testing.T) {
+		var count int
+		for range json.New([]interface{}{1}).Entries() {
+			count++
+		}
+		for range json.New(map[string]interface{}{"foo": 1}).Elements() {
+			count++
+		}
+		for range json.New("foo").MapIndex("foo").Entries() {
+			count++
+		}
+		if !assert.Equal(t, 0, count, `nothing should be yielded`) {
+			return
+		}
+	})
+}
+
+func TestWalk(t *testing.T) {
+	const src = `{"foo": [1, {"bar": true}], "hello world": "x", "skipped": {"child": 1}, "zzz": null}`
+	t.Run("visit all", func(t *testing.T) {
+		j, err := json.Parse([]byte(src))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+
+		var paths []string
+		err = j.Walk(func(path string, _ json.Context) (json.WalkAction, error) {
+			paths = append(paths, path)
+			return json.WalkContinue, nil
+		})
+		if !assert.NoError(t, err, `j.Walk should succeed`) {
+			return
+		}
+
+		expected := []string{
+			`$`,
+			`$.foo`,
+			`$.foo[0]`,
+			`$.foo[1]`,
+			`$.foo[1].bar`,
+			`$["hello world"]`,
+			`$.skipped`,
+			`$.skipped.child`,
+			`$.zzz`,
+		}
+		if !assert.Equal(t, expected, paths, `paths should match`) {
+			return
+		}
+	})
+	t.Run("skip and stop", func(t *testing.T) {
+		j, err := json.Parse([]byte(src))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+
+		var paths []string
+		err = j.Walk(func(path string, _ json.Context) (json.WalkAction, error) {
+			paths = append(paths, path)
+			switch path {
+			case `$.foo`:
+				return json.WalkSkip, nil
+			case `$.skipped.child`:
+				return json.WalkStop, nil
+			}
+			return json.WalkContinue, nil
+		})
+		if !assert.NoError(t, err, `j.Walk should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []string{`$`, `$.foo`, `$["hello world"]`, `$.skipped`, `$.skipped.child`}, paths, `paths should match`) {
+			return
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		j, err := json.Parse([]byte(src))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+
+		var count int
+		err = j.Walk(func(path string, _ json.Context) (json.WalkAction, error) {
+			count++
+			if path == `$.foo[0]` {
+				return json.WalkContinue, fmt.Errorf(`boom`)
+			}
+			return json.WalkContinue, nil
+		})
+		if !assert.Error(t, err, `j.Walk should fail`) {
+			return
+		}
+		if !assert.Equal(t, 3, count, `traversal should stop at the error`) {
+			return
+		}
+	})
+}
+
+func TestParseAll(t *testing.T) {
+	t.Run("sanity", func(t *testing.T) {
+		list, err := json.ParseAll([]byte(`{"seq":1}{"seq":2} [3]
+"four"`))
+		if !assert.NoError(t, err, `json.ParseAll should succeed`) {
+			return
+		}
+		if !assert.Len(t, list, 4, `there should be 4 values`) {
+			return
+		}
+
+		var seq int
+		if !assert.NoError(t, list[1].MapIndex("seq").Int(&seq), `list[1].MapIndex.Int should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 2, seq, `values should match`) {
+			return
+		}
+	})
+	t.Run("empty input", func(t *testing.T) {
+		list, err := json.ParseAll([]byte(` `))
+		if !assert.NoError(t, err, `json.ParseAll should succeed`) {
+			return
+		}
+		if !assert.Len(t, list, 0, `there should be no values`) {
+			return
+		}
+	})
+	t.Run("malformed input", func(t *testing.T) {
+		_, err := json.ParseAll([]byte(`{"seq":1}{"seq":`))
+		if !assert.Error(t, err, `json.ParseAll should fail`) {
+			return
+		}
+	})
+}
+
+func TestParseReader(t *testing.T) {
+	const src = `{"foo": "bar"}`
+	t.Run("sanity", func(t *testing.T) {
+		j, err := json.ParseReader(strings.NewReader(src))
+		if !assert.NoError(t, err, `json.ParseReader should succeed`) {
+			return
+		}
+
+		var s string
+		if !assert.NoError(t, j.MapIndex("foo").String(&s), `j.MapIndex.String should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "bar", s, `values should match`) {
+			return
+		}
+	})
+	t.Run("within size limit", func(t *testing.T) {
+		_, err := json.ParseReader(strings.NewReader(src), json.WithMaxSize(int64(len(src))))
+		if !assert.NoError(t, err, `json.ParseReader should succeed`) {
+			return
+		}
+	})
+	t.Run("exceeds size limit", func(t *testing.T) {
+		_, err := json.ParseReader(strings.NewReader(src), json.WithMaxSize(int64(len(src)-1)))
+		if !assert.Error(t, err, `json.ParseReader should fail`) {
+			return
+		}
+	})
+	t.Run("malformed input", func(t *testing.T) {
+		_, err := json.ParseReader(strings.NewReader(`{"foo": `))
+		if !assert.Error(t, err, `json.ParseReader should fail`) {
+			return
+		}
+	})
+}
+
+func TestParseString(t *testing.T) {
+	j, err := json.ParseString(`{"foo": ["bar", "baz"]}`)
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+
+	var s string
+	if !assert.NoError(t, j.MapIndex("foo").Index(1).String(&s), `j.MapIndex.Index.String should succeed`) {
+		return
+	}
+	if !assert.Equal(t, "baz", s, `values should match`) {
+		return
+	}
+
+	if _, err := json.ParseString(`{"foo": `); !assert.Error(t, err, `json.ParseString should fail`) {
+		return
+	}
+}
+
+func TestLazy(t *testing.T) {
+	const src = `{"id": 1, "profile": {"name": "John", "tags": ["a", "b"]}, "history": [{"seq": 1}, {"seq": 2}]}`
+	t.Run("navigation", func(t *testing.T) {
+		j, err := json.Parse([]byte(src), json.WithLazy(true))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+
+		var s string
+		if !assert.NoError(t, j.MapIndex("profile").MapIndex("tags").Index(1).String(&s), `j.MapIndex.MapIndex.Index.String should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "b", s, `values should match`) {
+			return
+		}
+
+		var seq int
+		if !assert.NoError(t, j.MapIndex("history").Index(1).MapIndex("seq").Int(&seq), `j.MapIndex.Index.MapIndex.Int should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 2, seq, `values should match`) {
+			return
+		}
+	})
+	t.Run("extraction", func(t *testing.T) {
+		j, err := json.Parse([]byte(src), json.WithLazy(true))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+
+		var m map[string]interface{}
+		if !assert.NoError(t, j.Map(&m), `j.Map should succeed`) {
+			return
+		}
+		profile, ok := m["profile"].(map[string]interface{})
+		if !assert.True(t, ok, `profile should be a map (%T)`, m["profile"]) {
+			return
+		}
+		if !assert.Equal(t, []interface{}{"a", "b"}, profile["tags"], `values should match`) {
+			return
+		}
+	})
+	t.Run("modification and marshaling", func(t *testing.T) {
+		j, err := json.Parse([]byte(src), json.WithLazy(true))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+
+		j.MapIndex("profile").SetMapIndex("name", "Jane")
+
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `j.MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"history":[{"seq":1},{"seq":2}],"id":1,"profile":{"name":"Jane","tags":["a","b"]}}`, string(buf), `json string should match`) {
+			return
+		}
+	})
+	t.Run("nested syntax errors are reported on parse", func(t *testing.T) {
+		_, err := json.Parse([]byte(`{"id": 1, "profile": {"name": "John",}}`), json.WithLazy(true))
+		if !assert.Error(t, err, `json.Parse should fail`) {
+			return
+		}
+	})
+	t.Run("nested numbers that cannot be converted are reported on parse", func(t *testing.T) {
+		for _, tc := range []struct {
+			Name    string
+			Src     string
+			Options []json.ParseOption
+		}{
+			{Name: "WithUseNumber(false)", Src: `{"a":{"b":1e400}}`, Options: []json.ParseOption{json.WithUseNumber(false)}},
+			{Name: "IntDecodingFloat64", Src: `{"a":[1` + strings.Repeat("0", 400) + `]}`, Options: []json.ParseOption{json.WithIntDecoding(json.IntDecodingFloat64)}},
+		} {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				_, err := json.Parse([]byte(tc.Src), tc.Options...)
+				if !assert.Error(t, err, `json.Parse should fail`) {
+					return
+				}
+				_, err = json.Parse([]byte(tc.Src), append(tc.Options, json.WithLazy(true))...)
+				if !assert.Error(t, err, `json.Parse should fail with WithLazy`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("nested number hook errors are reported on access", func(t *testing.T) {
+		errHook := errors.New(`unsupported number`)
+		hook := func(literal string) (json.Number, error) {
+			if literal == "13" {
+				return nil, errHook
+			}
+			rat, _ := new(big.Rat).SetString(literal)
+			return &decimal{rat: rat, literal: literal}, nil
+		}
+		const src = `{"a":{"b":13},"c":[{"d":13}]}`
+		parse := func(t *testing.T) json.Context {
+			t.Helper()
+			j, err := json.Parse([]byte(src), json.WithNumberHook(hook), json.WithLazy(true))
+			if !assert.NoError(t, err, `json.Parse should succeed`) {
+				t.FailNow()
+			}
+			return j
+		}
+
+		if !assert.True(t, errors.Is(parse(t).MapIndex("a").Err(), errHook), `MapIndex should fail`) {
+			return
+		}
+		if !assert.True(t, errors.Is(parse(t).MapIndex("c").Index(0).Err(), errHook), `Index should fail`) {
+			return
+		}
+		var m map[string]interface{}
+		if !assert.True(t, errors.Is(parse(t).Map(&m), errHook), `Map should fail`) {
+			return
+		}
+		if !assert.True(t, errors.Is(parse(t).ForEach(func(string, int, json.Context) bool { return true }), errHook), `ForEach should fail`) {
+			return
+		}
+		if !assert.True(t, errors.Is(parse(t).ExpandEnv(os.LookupEnv).Err(), errHook), `ExpandEnv should fail`) {
+			return
+		}
+		if !assert.False(t, json.Equal(parse(t), parse(t)), `Equal should report documents that cannot be decoded as different`) {
+			return
+		}
+		if _, err := json.Diff(parse(t), parse(t)); !assert.True(t, errors.Is(err, errHook), `Diff should fail`) {
+			return
+		}
+	})
+	t.Run("syntax extensions are encoded", func(t *testing.T) {
+		testcases := []struct {
+			Name     string
+			Src      string
+			Options  []json.ParseOption
+			Expected string
+		}{
+			{Name: "comments", Src: `{"a":{"x":1 /* c */}, "b": [1 // c
+]}`, Options: []json.ParseOption{json.WithComments(true)}, Expected: `{"a":{"x":1},"b":[1]}`},
+			{Name: "trailing commas", Src: `{"a":{"x":1,},"b":[1,2,],}`, Options: []json.ParseOption{json.WithTrailingCommas(true)}, Expected: `{"a":{"x":1},"b":[1,2]}`},
+			{Name: "JSON5", Src: `{a:{x:'y'},b:[0x10,+1,.5]}`, Options: []json.ParseOption{json.WithJSON5(true)}, Expected: `{"a":{"x":"y"},"b":[16,1,0.5]}`},
+			{Name: "non-finite numbers", Src: `{"a":{"x":NaN},"b":[Infinity,-Infinity]}`, Options: []json.ParseOption{json.WithNonFiniteNumbers(true)}, Expected: `{"a":{"x":NaN},"b":[Infinity,-Infinity]}`},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				for _, lazy := range []json.ParseOption{json.WithLazy(true), json.WithProjection(`$.a`)} {
+					j, err := json.ParseString(tc.Src, append([]json.ParseOption{lazy}, tc.Options...)...)
+					if !assert.NoError(t, err, `json.ParseString should succeed`) {
+						return
+					}
+
+					buf, err := j.MarshalJSON()
+					if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+						return
+					}
+					if !assert.Equal(t, tc.Expected, string(buf), `MarshalJSON output should match`) {
+						return
+					}
+
+					var sb strings.Builder
+					if _, err := j.WriteTo(&sb); !assert.NoError(t, err, `WriteTo should succeed`) {
+						return
+					}
+					if !assert.Equal(t, tc.Expected, sb.String(), `WriteTo output should match`) {
+						return
+					}
+
+					sb.Reset()
+					if !assert.NoError(t, json.NewEncoder(&sb).Encode(j), `Encode should succeed`) {
+						return
+					}
+					if !assert.Equal(t, tc.Expected+"\n", sb.String(), `Encode output should match`) {
+						return
+					}
+
+					sb.Reset()
+					if !assert.NoError(t, j.Dump(&sb), `Dump should succeed`) {
+						return
+					}
+					if !assert.NotContains(t, sb.String(), `rawValue`, `Dump should decode deferred values`) {
+						return
+					}
+				}
+			})
+		}
+	})
+}
+
+func TestProjection(t *testing.T) {
+	const src = `{"id":1,"meta":{"host":"a","pid":2},"events":[{"id":3,"payload":{"size":4}},{"id":5,"payload":{"size":6}}],"metrics":{"cpu":[7,8]}}`
+
+	// decoded records the numbers that were decoded while parsing
+	var decoded []string
+	hook := func(literal string) (json.Number, error) {
+		decoded = append(decoded, literal)
+		rat, _ := new(big.Rat).SetString(literal)
+		return &decimal{rat: rat, literal: literal}, nil
+	}
+
+	t.Run("skipped values are decoded on access", func(t *testing.T) {
+		decoded = nil
+		j, err := json.Parse([]byte(src), json.WithNumberHook(hook), json.WithProjection(`$.meta`, `$.events[*].id`))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []string{"1", "2", "3", "5"}, decoded, `only the projected values should be decoded`) {
+			return
+		}
+
+		var size int
+		if !assert.NoError(t, j.MapIndex("events").Index(1).MapIndex("payload").MapIndex("size").Int(&size), `values outside of the projection should be accessible`) {
+			return
+		}
+		if !assert.Equal(t, 6, size, `values should match`) {
+			return
+		}
+
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `j.MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.JSONEq(t, src, string(buf), `json string should match`) {
+			return
+		}
+	})
+	t.Run("indices and wildcards", func(t *testing.T) {
+		decoded = nil
+		_, err := json.Parse([]byte(src), json.WithNumberHook(hook), json.WithProjection(`events[0].payload`), json.WithProjection(`$.metrics.cpu[*]`))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []string{"1", "3", "4", "7", "8"}, decoded, `only the projected values should be decoded`) {
+			return
+		}
+	})
+	t.Run("root", func(t *testing.T) {
+		decoded = nil
+		_, err := json.Parse([]byte(src), json.WithNumberHook(hook), json.WithProjection(`$.meta`, `$`))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+		if !assert.Len(t, decoded, 8, `all values should be decoded`) {
+			return
+		}
+	})
+	t.Run("invalid path", func(t *testing.T) {
+		_, err := json.Parse([]byte(src), json.WithProjection(`$.events[`))
+		if !assert.Error(t, err, `json.Parse should fail`) {
+			return
+		}
+	})
+}
+
+type metricsRecorder struct {
+	json.NopMetrics
+	parses []json.ParseStats
+	errors []*json.AccessError
+}
+
+func (m *metricsRecorder) ObserveParse(stats json.ParseStats) {
+	m.parses = append(m.parses, stats)
+}
+
+func (m *metricsRecorder) ObserveAccessError(err *json.AccessError) {
+	m.errors = append(m.errors, err)
+}
+
+func TestMetrics(t *testing.T) {
+	const src = `{"name":"svc","ports":[80,443]}`
+
+	t.Run("parse", func(t *testing.T) {
+		var m metricsRecorder
+		if _, err := json.ParseString(src, json.WithMetrics(&m)); !assert.NoError(t, err, `ParseString should succeed`) {
+			return
+		}
+		if _, err := json.Parse([]byte(`{"broken":`), json.WithMetrics(&m)); !assert.Error(t, err, `Parse should fail`) {
+			return
+		}
+		if !assert.Len(t, m.parses, 2, `both documents should be measured`) {
+			return
+		}
+		if !assert.Equal(t, int64(len(src)), m.parses[0].Size, `size should match`) {
+			return
+		}
+		if !assert.NoError(t, m.parses[0].Err, `first document should be valid`) {
+			return
+		}
+		if !assert.Error(t, m.parses[1].Err, `second document should be reported as invalid`) {
+			return
+		}
+	})
+	t.Run("access errors", func(t *testing.T) {
+		var m metricsRecorder
+		j, err := json.ParseString(src, json.WithMetrics(&m))
+		if !assert.NoError(t, err, `ParseString should succeed`) {
+			return
+		}
+
+		var s string
+		var n int
+		_ = j.MapIndex("missing").MapIndex("deeper").String(&s)
+		_ = j.MapIndex("ports").Index(5).Int(&n)
+		_ = j.MapIndex("name").Int(&n)
+		_ = j.MapIndex("name").String(&s)
+		if !assert.Len(t, m.errors, 3, `each failure should be reported once`) {
+			return
+		}
+		if !assert.True(t, errors.Is(m.errors[0], json.ErrKeyNotFound), `first error should match`) {
+			return
+		}
+		if !assert.True(t, errors.Is(m.errors[1], json.ErrIndexOutOfRange), `second error should match`) {
+			return
+		}
+		if !assert.Equal(t, `$.name`, m.errors[2].Path(), `third error should point to the field`) {
+			return
+		}
+
+		var built metricsRecorder
+		_ = json.New(map[string]interface{}{}, json.WithMetrics(&built)).MapIndex("x")
+		if !assert.Len(t, built.errors, 1, `New should accept WithMetrics`) {
+			return
+		}
+	})
+}
+
+func TestParseOptions(t *testing.T) {
+	const src = `{"int": 1, "float": 1.5, "nested": {"list": [2, 2.5]}}`
+	t.Run("WithUseNumber(false)", func(t *testing.T) {
+		j, err := json.Parse([]byte(src), json.WithUseNumber(false))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+
+		var m map[string]interface{}
+		if !assert.NoError(t, j.Map(&m), `j.Map should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 1.5, m["float"], `values should be float64`) {
+			return
+		}
+
+		var i int
+		if !assert.NoError(t, j.MapIndex("int").Int(&i), `j.MapIndex.Int should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 1, i, `values should match`) {
+			return
+		}
+		if !assert.Error(t, j.MapIndex("float").Int(&i), `j.MapIndex.Int should fail for non-integers`) {
+			return
+		}
+	})
+	t.Run("boolean options can be disabled", func(t *testing.T) {
+		for _, tc := range []struct {
+			Name   string
+			Src    string
+			Option func(bool) json.ParseOption
+		}{
+			{Name: "WithComments", Src: `{"a": 1 /* comment */}`, Option: json.WithComments},
+			{Name: "WithTrailingCommas", Src: `{"a": 1,}`, Option: json.WithTrailingCommas},
+			{Name: "WithJSON5", Src: `{a: 1}`, Option: json.WithJSON5},
+			{Name: "WithDisallowTrailingData", Src: `{"a": 1} {}`, Option: json.WithDisallowTrailingData},
+		} {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				_, errTrue := json.ParseString(tc.Src, tc.Option(true))
+				_, errFalse := json.ParseString(tc.Src, tc.Option(true), tc.Option(false))
+				_, errDefault := json.ParseString(tc.Src)
+				if !assert.NotEqual(t, errTrue == nil, errFalse == nil, `the option should make a difference`) {
+					return
+				}
+				if !assert.Equal(t, errDefault == nil, errFalse == nil, `disabling the option should restore the default`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("WithUseNumber(false) with WithLazy", func(t *testing.T) {
+		j, err := json.ParseString(src, json.WithUseNumber(false), json.WithLazy(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+
+		var l []interface{}
+		if !assert.NoError(t, j.MapIndex("nested").MapIndex("list").Slice(&l), `j.MapIndex.MapIndex.Slice should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []interface{}{2.0, 2.5}, l, `values should be float64`) {
+			return
+		}
+	})
+	t.Run("WithMaxSize", func(t *testing.T) {
+		if _, err := json.Parse([]byte(src), json.WithMaxSize(int64(len(src)))); !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+		if _, err := json.Parse([]byte(src), json.WithMaxSize(10)); !assert.Error(t, err, `json.Parse should fail`) {
+			return
+		}
+		dec := json.NewDecoder(strings.NewReader(src+src), json.WithMaxSize(int64(len(src)+1)))
+		if _, err := dec.Decode(); !assert.NoError(t, err, `dec.Decode should succeed`) {
+			return
+		}
+		if _, err := dec.Decode(); !assert.Error(t, err, `dec.Decode should fail`) {
+			return
+		}
+	})
+	t.Run("accessors on values set by the user", func(t *testing.T) {
+		j := json.New(map[string]interface{}{"int": 1, "uint8": uint8(2), "float": float32(1.5)})
+
+		var i int64
+		if !assert.NoError(t, j.MapIndex("uint8").Int(&i), `j.MapIndex.Int should succeed`) {
+			return
+		}
+		if !assert.Equal(t, int64(2), i, `values should match`) {
+			return
+		}
+
+		var f float64
+		if !assert.NoError(t, j.MapIndex("int").Float(&f), `j.MapIndex.Float should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 1.0, f, `values should match`) {
+			return
+		}
+	})
+}
+
+func TestMaxDepth(t *testing.T) {
+	const src = `{"a": [{"b": [1]}]}`
+	tests := []struct {
+		Name     string
+		MaxDepth int
+		Lazy     bool
+		Error    bool
+	}{
+		{Name: "within limit", MaxDepth: 4},
+		{Name: "exceeds limit", MaxDepth: 3, Error: true},
+		{Name: "exceeds limit (lazy)", MaxDepth: 3, Lazy: true, Error: true},
+		{Name: "no limit", MaxDepth: 0},
+	}
+
+	for _, data := range tests {
+		data := data
+		t.Run(data.Name, func(t *testing.T) {
+			_, err := json.Parse([]byte(src), json.WithMaxDepth(data.MaxDepth), json.WithLazy(data.Lazy))
+			if data.Error {
+				if !assert.Error(t, err, `json.Parse should fail`) {
+					return
+				}
+				if !assert.Contains(t, err.Error(), `maximum nesting depth`, `error should mention the depth limit`) {
+					return
+				}
+				return
+			}
+			if !assert.NoError(t, err, `json.Parse should succeed`) {
+				return
+			}
+		})
+	}
+
+	t.Run("deeply nested input", func(t *testing.T) {
+		src := strings.Repeat("[", 1000000) + strings.Repeat("]", 1000000)
+		if _, err := json.ParseString(src, json.WithMaxDepth(100)); !assert.Error(t, err, `json.ParseString should fail`) {
+			return
+		}
+	})
+}
+
+func TestLimits(t *testing.T) {
+	const src = `{"name": "John Doe", "tags": ["a", "b", "c"], "address": {"city": "Tokyo", "zip": "100"}}`
+	tests := []struct {
+		Name   string
+		Option json.ParseOption
+		Error  bool
+	}{
+		{Name: "size within limit", Option: json.WithMaxSize(int64(len(src)))},
+		{Name: "size exceeds limit", Option: json.WithMaxSize(int64(len(src) - 1)), Error: true},
+		{Name: "depth exceeds limit", Option: json.WithMaxDepth(1), Error: true},
+		{Name: "string length within limit", Option: json.WithMaxStringLength(8)},
+		{Name: "string length exceeds limit", Option: json.WithMaxStringLength(7), Error: true},
+		{Name: "array length within limit", Option: json.WithMaxArrayLength(3)},
+		{Name: "array length exceeds limit", Option: json.WithMaxArrayLength(2), Error: true},
+		{Name: "object keys within limit", Option: json.WithMaxObjectKeys(3)},
+		{Name: "object keys exceeds limit", Option: json.WithMaxObjectKeys(2), Error: true},
+	}
+
+	for _, data := range tests {
+		data := data
+		t.Run(data.Name, func(t *testing.T) {
+			for _, lazy := range []bool{false, true} {
+				_, err := json.ParseReader(strings.NewReader(src), data.Option, json.WithLazy(lazy))
+				if !data.Error {
+					if !assert.NoError(t, err, `json.ParseReader should succeed (lazy = %t)`, lazy) {
+						return
+					}
+					continue
+				}
+
+				if !assert.Error(t, err, `json.ParseReader should fail (lazy = %t)`, lazy) {
+					return
+				}
+				if !assert.True(t, errors.Is(err, json.ErrLimitExceeded), `error should be ErrLimitExceeded (lazy = %t): %s`, lazy, err) {
+					return
+				}
+			}
+		})
+	}
+
+	t.Run("escaped strings", func(t *testing.T) {
+		_, err := json.ParseString(`"あいう"`, json.WithMaxStringLength(8))
+		if !assert.True(t, errors.Is(err, json.ErrLimitExceeded), `error should be ErrLimitExceeded`) {
+			return
+		}
+	})
+}
+
+func TestDisallowTrailingData(t *testing.T) {
+	tests := []struct {
+		Src   string
+		Error bool
+	}{
+		{Src: `{"a":1}`},
+		{Src: "{\"a\":1} \n\t "},
+		{Src: `{"a":1} garbage`, Error: true},
+		{Src: `{"a":1}{"b":2}`, Error: true},
+		{Src: `1 2`, Error: true},
+	}
+
+	for _, data := range tests {
+		_, err := json.ParseString(data.Src)
+		if !assert.NoError(t, err, `json.ParseString should succeed without the option for %q`, data.Src) {
+			return
+		}
+
+		_, err = json.ParseReader(strings.NewReader(data.Src), json.WithDisallowTrailingData(true))
+		if data.Error {
+			if !assert.Error(t, err, `json.ParseReader should fail for %q`, data.Src) {
+				return
+			}
+			continue
+		}
+		if !assert.NoError(t, err, `json.ParseReader should succeed for %q`, data.Src) {
+			return
+		}
+	}
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	const src = `{"a": 1, "nested": {"b": 1, "b": 2}, "a": 2}`
+
+	t.Run("last wins", func(t *testing.T) {
+		j, err := json.ParseString(src)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		buf, _ := j.MarshalJSON()
+		if !assert.Equal(t, `{"a":2,"nested":{"b":2}}`, string(buf), `json string should match`) {
+			return
+		}
+	})
+	t.Run("first wins", func(t *testing.T) {
+		for _, lazy := range []bool{false, true} {
+			j, err := json.ParseString(src, json.WithDuplicateKeys(json.DuplicateKeyFirstWins), json.WithLazy(lazy))
+			if !assert.NoError(t, err, `json.ParseString should succeed`) {
+				return
+			}
+			var b int
+			if !assert.NoError(t, j.MapIndex("nested").MapIndex("b").Int(&b), `j.MapIndex.MapIndex.Int should succeed`) {
+				return
+			}
+			buf, _ := j.MarshalJSON()
+			if !assert.Equal(t, `{"a":1,"nested":{"b":1}}`, string(buf), `json string should match (lazy = %t)`, lazy) {
+				return
+			}
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		for _, lazy := range []bool{false, true} {
+			_, err := json.ParseString(src, json.WithDuplicateKeys(json.DuplicateKeyError), json.WithLazy(lazy))
+			if !assert.True(t, errors.Is(err, json.ErrDuplicateKey), `error should be ErrDuplicateKey (lazy = %t)`, lazy) {
+				return
+			}
+			if !assert.Contains(t, err.Error(), `key "b" at offset 28`, `error should contain the key and its location`) {
+				return
+			}
+		}
+	})
+}
+
+func TestComments(t *testing.T) {
+	const src = `// leading comment
+{
+  /* block
+     comment */
+  "url": "http://example.com/*not a comment*/", // trailing comment
+  "list": [1, /* inline */ 2]
+}
+// end`
+
+	t.Run("disallowed by default", func(t *testing.T) {
+		if _, err := json.ParseString(src); !assert.Error(t, err, `json.ParseString should fail`) {
+			return
+		}
+	})
+	t.Run("WithComments", func(t *testing.T) {
+		for _, lazy := range []bool{false, true} {
+			j, err := json.ParseString(src, json.WithComments(true), json.WithLazy(lazy), json.WithDisallowTrailingData(true))
+			if !assert.NoError(t, err, `json.ParseString should succeed`) {
+				return
+			}
+			var l []interface{}
+			if !assert.NoError(t, j.MapIndex("list").Slice(&l), `j.MapIndex.Slice should succeed`) {
+				return
+			}
+			buf, _ := j.MarshalJSON()
+			if !assert.Equal(t, `{"list":[1,2],"url":"http://example.com/*not a comment*/"}`, string(buf), `json string should match`) {
+				return
+			}
+		}
+	})
+	t.Run("malformed comments", func(t *testing.T) {
+		for _, src := range []string{`{"a": 1 /* unterminated`, `{"a": 1 / 2}`} {
+			if _, err := json.ParseString(src, json.WithComments(true)); !assert.Error(t, err, `json.ParseString should fail for %q`, src) {
+				return
+			}
+		}
+	})
+}
+
+func TestTrailingCommas(t *testing.T) {
+	tests := []struct {
+		Src      string
+		Expected string
+		Error    bool
+	}{
+		{Src: `[1,2,3,]`, Expected: `[1,2,3]`},
+		{Src: `{"a":1,}`, Expected: `{"a":1}`},
+		{Src: `{"a":[{"b":[],},],}`, Expected: `{"a":[{"b":[]}]}`},
+		{Src: `[1,,]`, Error: true},
+		{Src: `[,]`, Error: true},
+		{Src: `{,}`, Error: true},
+	}
+
+	for _, data := range tests {
+		if _, err := json.ParseString(data.Src); !assert.Error(t, err, `json.ParseString should fail without the option for %s`, data.Src) {
+			return
+		}
+
+		j, err := json.ParseString(data.Src, json.WithTrailingCommas(true))
+		if data.Error {
+			if !assert.Error(t, err, `json.ParseString should fail for %s`, data.Src) {
+				return
+			}
+			continue
+		}
+		if !assert.NoError(t, err, `json.ParseString should succeed for %s`, data.Src) {
+			return
+		}
+		buf, _ := j.MarshalJSON()
+		if !assert.Equal(t, data.Expected, string(buf), `json string should match`) {
+			return
+		}
+	}
+}
+
+func TestJSON5(t *testing.T) {
+	tests := []struct {
+		Src      string
+		Expected string
+		Error    bool
+	}{
+		{Src: `{unquoted: 1, $dollar_2: 2}`, Expected: `{"$dollar_2":2,"unquoted":1}`},
+		{Src: `{'single': 'it\'s "quoted"'}`, Expected: `{"single":"it's \"quoted\""}`},
+		{Src: `[0x1F, -0XFF, +1, .5, 5., -.25e1]`, Expected: `[31,-255,1,0.5,5,-0.25e1]`},
+		{Src: `[0x123456789abcdef0123]`, Expected: `[5373003642731685151011]`},
+		{Src: "['line \\\nbreak', 'crlf \\\r\nbreak']", Expected: `["line break","crlf break"]`},
+		{Src: `['\x41\v\0', "\q"]`, Expected: `["A\u000b\u0000","q"]`},
+		{Src: "{\n  // comment\n  a: [1, 2,],\n}", Expected: `{"a":[1,2]}`},
+		{Src: `[0x]`, Error: true},
+		{Src: `[.]`, Error: true},
+		{Src: `['\01']`, Error: true},
+		{Src: `{1a: 1}`, Error: true},
+	}
+
+	for _, data := range tests {
+		if _, err := json.ParseString(data.Src); !assert.Error(t, err, `json.ParseString should fail without the option for %s`, data.Src) {
+			return
+		}
+
+		j, err := json.ParseString(data.Src, json.WithJSON5(true))
+		if data.Error {
+			if !assert.Error(t, err, `json.ParseString should fail for %s`, data.Src) {
+				return
+			}
+			continue
+		}
+		if !assert.NoError(t, err, `json.ParseString should succeed for %s`, data.Src) {
+			return
+		}
+		buf, _ := j.MarshalJSON()
+		if !assert.Equal(t, data.Expected, string(buf), `json string should match`) {
+			return
+		}
+	}
+}
+
+func TestNonFiniteNumbers(t *testing.T) {
+	const src = `{"nan":NaN,"inf":[Infinity,-Infinity,1.5]}`
+
+	t.Run("Disabled", func(t *testing.T) {
+		for _, s := range []string{`NaN`, `Infinity`, `-Infinity`, `[NaN]`} {
+			if _, err := json.ParseString(s); !assert.Error(t, err, `json.ParseString should fail for %s`, s) {
+				return
+			}
+		}
+	})
+	t.Run("RoundTrip", func(t *testing.T) {
+		j, err := json.ParseString(src, json.WithNonFiniteNumbers(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+
+		var f float64
+		if !assert.NoError(t, j.MapIndex("inf").Index(1).Float(&f), `Float should succeed`) {
+			return
+		}
+		if !assert.True(t, math.IsInf(f, -1), `value should be -Inf`) {
+			return
+		}
+
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"inf":[Infinity,-Infinity,1.5],"nan":NaN}`, string(buf), `json string should match`) {
+			return
+		}
+
+		buf, err = j.MapIndex("inf").MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `[Infinity,-Infinity,1.5]`, string(buf), `json string should match`) {
+			return
+		}
+	})
+	t.Run("Float64", func(t *testing.T) {
+		j, err := json.ParseString(src, json.WithNonFiniteNumbers(true), json.WithUseNumber(false))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		var f float64
+		if !assert.NoError(t, j.MapIndex("nan").Float(&f), `Float should succeed`) {
+			return
+		}
+		if !assert.True(t, math.IsNaN(f), `value should be NaN`) {
+			return
+		}
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"inf":[Infinity,-Infinity,1.5],"nan":NaN}`, string(buf), `json string should match`) {
+			return
+		}
+	})
+	t.Run("JSON5", func(t *testing.T) {
+		j, err := json.ParseString(`[+Infinity, -Infinity, NaN]`, json.WithJSON5(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		buf, _ := j.MarshalJSON()
+		if !assert.Equal(t, `[Infinity,-Infinity,NaN]`, string(buf), `json string should match`) {
+			return
+		}
+	})
+}
+
+func TestSyntaxErrorLocation(t *testing.T) {
+	t.Run("Multiline", func(t *testing.T) {
+		_, err := json.ParseString("{\n  \"a\": 1,\n  \"b\": tru\n}")
+		if !assert.Error(t, err, `json.ParseString should fail`) {
+			return
+		}
+		if !assert.Contains(t, err.Error(), `at offset 22 (line 3, column 11) near "  \"b\": tru"`, `error should contain the location and excerpt`) {
+			return
+		}
+
+		var serr *json.SyntaxError
+		if !assert.True(t, errors.As(err, &serr), `error should be a *json.SyntaxError`) {
+			return
+		}
+		if !assert.Equal(t, 3, serr.Line, `line should match`) {
+			return
+		}
+	})
+	t.Run("BufferBoundary", func(t *testing.T) {
+		// the error is located past the first refill of the read buffer
+		src := "[" + strings.Repeat(`"abcdefgh",`, 400) + "x]"
+		_, err := json.ParseReader(strings.NewReader(src))
+		if !assert.Error(t, err, `json.ParseReader should fail`) {
+			return
+		}
+		if !assert.Contains(t, err.Error(), `(line 1, column 4402) near "\",\"abcdefgh\",\"abcdefgh\",x]"`, `error should contain the location and excerpt`) {
+			return
+		}
+	})
+}
+
+func TestLocations(t *testing.T) {
+	const src = "{\n  \"a\": [1, {\"b\": true}],\n  \"c d\": \"x\"\n}"
+
+	j, err := json.ParseString(src, json.WithLocations(true))
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+
+	tests := []struct {
+		Path     string
+		Context  json.Context
+		Expected json.Location
+	}{
+		{
+			Path:     `$`,
+			Context:  j,
+			Expected: json.Location{Start: json.Position{Offset: 0, Line: 1, Column: 1}, End: json.Position{Offset: 41, Line: 4, Column: 2}},
+		},
+		{
+			Path:     `$.a`,
+			Context:  j.MapIndex("a"),
+			Expected: json.Location{Start: json.Position{Offset: 9, Line: 2, Column: 8}, End: json.Position{Offset: 25, Line: 2, Column: 24}},
+		},
+		{
+			Path:     `$.a[1].b`,
+			Context:  j.MapIndex("a").Index(1).MapIndex("b"),
+			Expected: json.Location{Start: json.Position{Offset: 19, Line: 2, Column: 18}, End: json.Position{Offset: 23, Line: 2, Column: 22}},
+		},
+		{
+			Path:     `$["c d"]`,
+			Context:  j.MapIndex("c d"),
+			Expected: json.Location{Start: json.Position{Offset: 36, Line: 3, Column: 10}, End: json.Position{Offset: 39, Line: 3, Column: 13}},
+		},
+	}
+
+	for _, test := range tests {
+		loc, err := test.Context.Location()
+		if !assert.NoError(t, err, `Location should succeed for %s`, test.Path) {
+			return
+		}
+		if !assert.Equal(t, test.Expected, loc, `location should match for %s`, test.Path) {
+			return
+		}
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		j, err := json.ParseString(src)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		if _, err := j.MapIndex("a").Location(); !assert.Error(t, err, `Location should fail`) {
+			return
+		}
+	})
+	t.Run("Modified", func(t *testing.T) {
+		j.SetMapIndex("e", 1)
+		if _, err := j.MapIndex("e").Location(); !assert.Error(t, err, `Location should fail for new values`) {
+			return
+		}
+	})
+}
+
+func TestPreserveKeyOrder(t *testing.T) {
+	const src = `{"zebra":1,"apple":{"y":true,"x":false},"mango":[{"b":1,"a":2}]}`
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		j, err := json.ParseString(src, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, src, string(buf), `json string should match`) {
+			return
+		}
+
+		var keys []string
+		for key := range j.Entries() {
+			keys = append(keys, key)
+		}
+		if !assert.Equal(t, []string{"zebra", "apple", "mango"}, keys, `keys should be in original order`) {
+			return
+		}
+	})
+	t.Run("Modified", func(t *testing.T) {
+		j, err := json.ParseString(src, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		j.SetMapIndex("banana", 2).SetMapIndex("zebra", 3)
+		j.MapIndex("apple").SetMapIndex("a", "new")
+
+		buf, _ := j.MarshalJSON()
+		if !assert.Equal(t, `{"zebra":3,"apple":{"y":true,"x":false,"a":"new"},"mango":[{"b":1,"a":2}],"banana":2}`, string(buf), `json string should match`) {
+			return
+		}
+	})
+	t.Run("Build", func(t *testing.T) {
+		j := json.New(map[string]interface{}{}, json.WithPreserveKeyOrder(true)).
+			SetMapIndex("c", 1).
+			SetMapIndex("b", 2).
+			SetMapIndex("a", 3)
+		buf, _ := j.MarshalJSON()
+		if !assert.Equal(t, `{"c":1,"b":2,"a":3}`, string(buf), `json string should match`) {
+			return
+		}
+	})
+	t.Run("Replaced", func(t *testing.T) {
+		j, err := json.ParseString(src, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+
+		var estimate int64
+		for i := 0; i < 100; i++ {
+			j.SetMapIndex("apple", map[string]interface{}{})
+			j.MapIndex("apple").SetMapIndex("z", i).SetMapIndex("a", i)
+			j.MapIndex("mango").Index(0).Set(map[string]interface{}{"b": i, "a": i})
+			if i == 0 {
+				estimate = j.SizeEstimate()
+			}
+		}
+		if !assert.Equal(t, estimate, j.SizeEstimate(), `the orders of replaced objects should not be counted`) {
+			return
+		}
+
+		buf, _ := j.MarshalJSON()
+		if !assert.Equal(t, `{"zebra":1,"apple":{"z":99,"a":99},"mango":[{"a":99,"b":99}]}`, string(buf), `json string should match`) {
+			return
+		}
+	})
+	t.Run("Aliased", func(t *testing.T) {
+		j, err := json.ParseString(src, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		var apple map[string]interface{}
+		if !assert.NoError(t, j.MapIndex("apple").Map(&apple), `Map should succeed`) {
+			return
+		}
+		j.SetMapIndex("alias", apple)
+		j.SetMapIndex("apple", 1)
+		for i := 0; i < 100; i++ {
+			j.SetMapIndex("tmp", map[string]interface{}{})
+			j.MapIndex("tmp").SetMapIndex("z", i).SetMapIndex("a", i)
+		}
+
+		buf, _ := j.MarshalJSON()
+		if !assert.Equal(t, `{"zebra":1,"apple":1,"mango":[{"b":1,"a":2}],"alias":{"y":true,"x":false},"tmp":{"z":99,"a":99}}`, string(buf), `objects still in the document should keep their order`) {
+			return
+		}
+	})
+	t.Run("Disabled", func(t *testing.T) {
+		j, err := json.ParseString(src)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		buf, _ := j.MarshalJSON()
+		if !assert.Equal(t, `{"apple":{"x":false,"y":true},"mango":[{"a":2,"b":1}],"zebra":1}`, string(buf), `json string should match`) {
+			return
+		}
+	})
+}
+
+func TestNumberRoundTrip(t *testing.T) {
+	const src = `[1.50,1e3,0.000001,-0,1E-7,12345678901234567890123,0.10000000000000000001]`
+
+	for _, options := range [][]json.ParseOption{
+		nil,
+		{json.WithPreserveKeyOrder(true)},
+		{json.WithNonFiniteNumbers(true)},
+	} {
+		j, err := json.ParseString(src, options...)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, src, string(buf), `numbers should be marshaled as they appeared in the input`) {
+			return
+		}
+
+		var sb strings.Builder
+		if !assert.NoError(t, json.NewEncoder(&sb).Encode(j), `Encode should succeed`) {
+			return
+		}
+		if !assert.Equal(t, src+"\n", sb.String(), `numbers should be encoded as they appeared in the input`) {
+			return
+		}
+	}
+
+	t.Run("Object", func(t *testing.T) {
+		const src = `{"b":1.50,"a":{"d":1e3,"c":-0.0}}`
+		j, err := json.ParseString(src, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		var sb strings.Builder
+		if !assert.NoError(t, json.NewEncoder(&sb).Encode(j), `Encode should succeed`) {
+			return
+		}
+		if !assert.Equal(t, src+"\n", sb.String(), `encoded object should match the input`) {
+			return
+		}
+	})
+	t.Run("Float64", func(t *testing.T) {
+		j, err := json.ParseString(`[1.50,1e3]`, json.WithUseNumber(false))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		buf, _ := j.MarshalJSON()
+		if !assert.Equal(t, `[1.5,1000]`, string(buf), `numbers should be marshaled in canonical form`) {
+			return
+		}
+	})
+}
+
+func TestIntDecoding(t *testing.T) {
+	const src = `[42,-7,1.5,1e2,12345678901234567890]`
+
+	tests := []struct {
+		Name     string
+		Options  []json.ParseOption
+		Expected []interface{}
+	}{
+		{
+			Name:     "Default",
+			Expected: []interface{}{stdlib.Number("42"), stdlib.Number("-7"), stdlib.Number("1.5"), stdlib.Number("1e2"), stdlib.Number("12345678901234567890")},
+		},
+		{
+			Name:     "Int64",
+			Options:  []json.ParseOption{json.WithIntDecoding(json.IntDecodingInt64)},
+			Expected: []interface{}{int64(42), int64(-7), stdlib.Number("1.5"), stdlib.Number("1e2"), stdlib.Number("12345678901234567890")},
+		},
+		{
+			Name:     "Int64WithoutNumber",
+			Options:  []json.ParseOption{json.WithIntDecoding(json.IntDecodingInt64), json.WithUseNumber(false)},
+			Expected: []interface{}{int64(42), int64(-7), 1.5, 100.0, 12345678901234567890.0},
+		},
+		{
+			Name:     "Float64",
+			Options:  []json.ParseOption{json.WithIntDecoding(json.IntDecodingFloat64)},
+			Expected: []interface{}{42.0, -7.0, stdlib.Number("1.5"), stdlib.Number("1e2"), 12345678901234567890.0},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			j, err := json.ParseString(src, test.Options...)
+			if !assert.NoError(t, err, `json.ParseString should succeed`) {
+				return
+			}
+			var l []interface{}
+			if !assert.NoError(t, j.Slice(&l), `Slice should succeed`) {
+				return
+			}
+			if !assert.Equal(t, test.Expected, l, `values should match`) {
+				return
+			}
+
+			var i int
+			if !assert.NoError(t, j.Index(0).Int(&i), `Int should succeed`) {
+				return
+			}
+			if !assert.Equal(t, 42, i, `value should match`) {
+				return
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		Src     string
+		Valid   bool
+		Options []json.ParseOption
+	}{
+		{Src: `{"a":[1,2,{"b":null}],"c":"d"}`, Valid: true},
+		{Src: ` "string" `, Valid: true},
+		{Src: `42`, Valid: true},
+		{Src: ``},
+		{Src: `{"a":1`},
+		{Src: `{"a":1} {"b":2}`},
+		{Src: `[1,]`},
+		{Src: `[1,]`, Valid: true, Options: []json.ParseOption{json.WithTrailingCommas(true)}},
+		{Src: `{"a":1,"a":2}`, Valid: true},
+		{Src: `{"a":1,"a":2}`, Options: []json.ParseOption{json.WithDuplicateKeys(json.DuplicateKeyError)}},
+		{Src: `[[[1]]]`, Options: []json.ParseOption{json.WithMaxDepth(2)}},
+	}
+
+	for _, test := range tests {
+		err := json.Validate([]byte(test.Src), test.Options...)
+		if test.Valid {
+			if !assert.NoError(t, err, `json.Validate should succeed for %s`, test.Src) {
+				return
+			}
+		} else {
+			if !assert.Error(t, err, `json.Validate should fail for %s`, test.Src) {
+				return
+			}
+		}
+
+		if len(test.Options) == 0 {
+			if !assert.Equal(t, stdlib.Valid([]byte(test.Src)), json.Valid([]byte(test.Src)), `json.Valid should match encoding/json for %s`, test.Src) {
+				return
+			}
+		}
+	}
+
+	t.Run("Location", func(t *testing.T) {
+		err := json.Validate([]byte("[\n  1,\n  x\n]"))
+		if !assert.Error(t, err, `json.Validate should fail`) {
+			return
+		}
+		if !assert.Contains(t, err.Error(), `(line 3, column 3)`, `error should contain the location`) {
+			return
+		}
+	})
+}
+
+func TestCycleDetection(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+	}
+
+	t.Run("New", func(t *testing.T) {
+		n := &node{Name: "a"}
+		n.Next = &node{Name: "b", Next: n}
+		if _, err := json.New(n).MarshalJSON(); !assert.Error(t, err, `New should detect the cycle`) {
+			return
+		}
+
+		m := map[string]interface{}{}
+		m["self"] = []interface{}{m}
+		if _, err := json.New(m).MarshalJSON(); !assert.Error(t, err, `New should detect the cycle`) {
+			return
+		}
+
+		// shared, but acyclic references are fine
+		shared := map[string]interface{}{"x": 1}
+		buf, err := json.New([]interface{}{shared, shared}).MarshalJSON()
+		if !assert.NoError(t, err, `New should accept shared references`) {
+			return
+		}
+		if !assert.Equal(t, `[{"x":1},{"x":1}]`, string(buf), `json string should match`) {
+			return
+		}
+	})
+	t.Run("SetMapIndex", func(t *testing.T) {
+		m := map[string]interface{}{"a": map[string]interface{}{}}
+		j := json.New(m)
+		if _, err := j.SetMapIndex("self", m).MarshalJSON(); !assert.Error(t, err, `SetMapIndex should detect the cycle`) {
+			return
+		}
+		if _, err := j.MapIndex("a").SetMapIndex("root", m).MarshalJSON(); !assert.Error(t, err, `SetMapIndex should detect the cycle`) {
+			return
+		}
+
+		buf, _ := j.MarshalJSON()
+		if !assert.Equal(t, `{"a":{}}`, string(buf), `document should not be modified`) {
+			return
+		}
+	})
+	t.Run("Set", func(t *testing.T) {
+		l := []interface{}{1, 2}
+		m := map[string]interface{}{"list": l}
+		j := json.New(m)
+		if _, err := j.MapIndex("list").Index(0).Set(m).MarshalJSON(); !assert.Error(t, err, `Set should detect the cycle`) {
+			return
+		}
+		if _, err := j.MapIndex("list").Index(0).Set("ok").MarshalJSON(); !assert.NoError(t, err, `Set should succeed`) {
+			return
+		}
+	})
+}
+
+// decimal is a minimal exact-precision number used to test WithNumberHook
+type decimal struct {
+	rat     *big.Rat
+	literal string
+}
+
+func (d *decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.literal), nil
+}
+
+func (d *decimal) Float64() (float64, error) {
+	f, _ := d.rat.Float64()
+	return f, nil
+}
+
+func (d *decimal) Int64() (int64, error) {
+	if !d.rat.IsInt() || !d.rat.Num().IsInt64() {
+		return 0, fmt.Errorf(`%s is not an int64`, d.literal)
+	}
+	return d.rat.Num().Int64(), nil
+}
+
+func TestNumberHook(t *testing.T) {
+	hook := func(literal string) (json.Number, error) {
+		rat, ok := new(big.Rat).SetString(literal)
+		if !ok {
+			return nil, fmt.Errorf(`invalid decimal %s`, literal)
+		}
+		return &decimal{rat: rat, literal: literal}, nil
+	}
+
+	const src = `{"price":19.990000000000000001,"qty":3}`
+	j, err := json.ParseString(src, json.WithNumberHook(hook))
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+
+	var m map[string]interface{}
+	if !assert.NoError(t, j.Map(&m), `Map should succeed`) {
+		return
+	}
+	d, ok := m["price"].(*decimal)
+	if !assert.True(t, ok, `value should be a *decimal (%T)`, m["price"]) {
+		return
+	}
+	if !assert.Equal(t, "19990000000000000001/1000000000000000000", d.rat.String(), `value should be exact`) {
+		return
+	}
+
+	var qty int
+	if !assert.NoError(t, j.MapIndex("qty").Int(&qty), `Int should succeed`) {
+		return
+	}
+	if !assert.Equal(t, 3, qty, `value should match`) {
+		return
+	}
+	var price float64
+	if !assert.NoError(t, j.MapIndex("price").Float(&price), `Float should succeed`) {
+		return
+	}
+	if !assert.Equal(t, 19.99, price, `value should match`) {
+		return
+	}
+
+	buf, err := j.MarshalJSON()
+	if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+		return
+	}
+	if !assert.Equal(t, src, string(buf), `json string should match`) {
+		return
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		failing := func(literal string) (json.Number, error) {
+			return nil, fmt.Errorf(`rejected`)
+		}
+		if _, err := json.ParseString(`[1]`, json.WithNumberHook(failing)); !assert.Error(t, err, `json.ParseString should fail`) {
+			return
+		}
+	})
+}
+
+func TestDecompression(t *testing.T) {
+	const src = `{"compressed":[1,2,3]}`
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(src))
+	w.Close()
+
+	t.Run("Gzip", func(t *testing.T) {
+		for _, lazy := range []bool{false, true} {
+			j, err := json.ParseReader(bytes.NewReader(gz.Bytes()), json.WithDecompression(true), json.WithLazy(lazy))
+			if !assert.NoError(t, err, `json.ParseReader should succeed`) {
+				return
+			}
+			buf, _ := j.MarshalJSON()
+			if !assert.Equal(t, src, string(buf), `json string should match`) {
+				return
+			}
+
+			j, err = json.Parse(gz.Bytes(), json.WithDecompression(true), json.WithLazy(lazy))
+			if !assert.NoError(t, err, `json.Parse should succeed`) {
+				return
+			}
+			buf, _ = j.MarshalJSON()
+			if !assert.Equal(t, src, string(buf), `json string should match`) {
+				return
+			}
+		}
+
+		if _, err := json.ParseReader(bytes.NewReader(gz.Bytes())); !assert.Error(t, err, `json.ParseReader should fail without the option`) {
+			return
+		}
+	})
+	t.Run("Uncompressed", func(t *testing.T) {
+		j, err := json.ParseString(src, json.WithDecompression(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		buf, _ := j.MarshalJSON()
+		if !assert.Equal(t, src, string(buf), `json string should match`) {
+			return
+		}
+	})
+	t.Run("MaxSize", func(t *testing.T) {
+		_, err := json.ParseReader(bytes.NewReader(gz.Bytes()), json.WithDecompression(true), json.WithMaxSize(10))
+		if !assert.True(t, errors.Is(err, json.ErrLimitExceeded), `the limit should apply to the decompressed input`) {
+			return
+		}
+	})
+	t.Run("Custom", func(t *testing.T) {
+		// a toy format: the magic bytes followed by hex encoded data
+		unhex := func(r io.Reader) (io.Reader, error) {
+			if _, err := io.CopyN(io.Discard, r, 2); err != nil {
+				return nil, err
+			}
+			return hex.NewDecoder(r), nil
+		}
+		input := "HX" + hex.EncodeToString([]byte(src))
+
+		j, err := json.ParseString(input, json.WithDecompressor([]byte("HX"), unhex))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		buf, _ := j.MarshalJSON()
+		if !assert.Equal(t, src, string(buf), `json string should match`) {
+			return
+		}
+	})
+}
+
+func TestMarshalIndent(t *testing.T) {
+	const src = `{"b":[1,{"c":"<x>"},[]],"a":{},"d":null}`
+
+	t.Run("Default", func(t *testing.T) {
+		j, err := json.ParseString(src)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+
+		var v interface{}
+		if !assert.NoError(t, stdlib.Unmarshal([]byte(src), &v), `stdlib.Unmarshal should succeed`) {
+			return
+		}
+		expected, _ := stdlib.MarshalIndent(v, "> ", "\t")
+
+		buf, err := j.MarshalIndent("> ", "\t")
+		if !assert.NoError(t, err, `MarshalIndent should succeed`) {
+			return
+		}
+		if !assert.Equal(t, string(expected), string(buf), `output should match encoding/json`) {
+			return
+		}
+	})
+	t.Run("Pretty", func(t *testing.T) {
+		j, err := json.ParseString(src, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		buf, err := j.Pretty()
+		if !assert.NoError(t, err, `Pretty should succeed`) {
+			return
+		}
+		const expected = `{
+  "b": [
+    1,
+    {
+      "c": "\u003cx\u003e"
+    },
+    []
+  ],
+  "a": {},
+  "d": null
+}`
+		if !assert.Equal(t, expected, string(buf), `output should preserve the key order`) {
+			return
+		}
+	})
+	t.Run("NestedGoValue", func(t *testing.T) {
+		j := json.New(map[string]interface{}{"s": struct{ X []int }{X: []int{1}}})
+		buf, err := j.Pretty()
+		if !assert.NoError(t, err, `Pretty should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "{\n  \"s\": {\n    \"X\": [\n      1\n    ]\n  }\n}", string(buf), `nested values should be indented`) {
+			return
+		}
+	})
+}
+
+func TestMarshalKeyOrder(t *testing.T) {
+	const src = `{"b":1,"c":{"z":true,"a":false},"a":2}`
+
+	j, err := json.ParseString(src, json.WithPreserveKeyOrder(true))
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+	byLength := func(a, b string) int {
+		return len(a) - len(b)
+	}
+
+	tests := []struct {
+		Name     string
+		Value    interface{}
+		Options  []json.MarshalOption
+		Expected string
+	}{
+		{Name: "Default", Value: j, Expected: src},
+		{Name: "Insertion", Value: j, Options: []json.MarshalOption{json.WithKeyOrder(json.KeyOrderInsertion)}, Expected: src},
+		{Name: "Lexical", Value: j, Options: []json.MarshalOption{json.WithKeyOrder(json.KeyOrderLexical)}, Expected: `{"a":2,"b":1,"c":{"a":false,"z":true}}`},
+		{
+			Name:     "Comparator",
+			Value:    map[string]interface{}{"ccc": 1, "a": 2, "bb": 3},
+			Options:  []json.MarshalOption{json.WithKeyComparator(byLength)},
+			Expected: `{"a":2,"bb":3,"ccc":1}`,
+		},
+		{
+			Name:     "ReverseComparator",
+			Value:    j,
+			Options:  []json.MarshalOption{json.WithKeyComparator(func(a, b string) int { return strings.Compare(b, a) })},
+			Expected: `{"c":{"z":true,"a":false},"b":1,"a":2}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			buf, err := json.Marshal(test.Value, test.Options...)
+			if !assert.NoError(t, err, `json.Marshal should succeed`) {
+				return
+			}
+			if !assert.Equal(t, test.Expected, string(buf), `json string should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("ErrorContext", func(t *testing.T) {
+		if _, err := json.Marshal(j.MapIndex("missing")); !assert.Error(t, err, `json.Marshal should fail`) {
+			return
+		}
+	})
+}
+
+func TestMarshalEscapeHTML(t *testing.T) {
+	const src = `{"url":"https://example.com/?a=1&b=<2>","nested":[{"tmpl":"<p>{{.}}</p>"}]}`
+
+	j, err := json.ParseString(src, json.WithPreserveKeyOrder(true))
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+
+	buf, err := json.Marshal(j, json.WithEscapeHTML(false))
+	if !assert.NoError(t, err, `json.Marshal should succeed`) {
+		return
+	}
+	if !assert.Equal(t, src, string(buf), `json string should round-trip`) {
+		return
+	}
+
+	buf, err = json.Marshal(j)
+	if !assert.NoError(t, err, `json.Marshal should succeed`) {
+		return
+	}
+	if !assert.Equal(t, `{"url":"https://example.com/?a=1\u0026b=\u003c2\u003e","nested":[{"tmpl":"\u003cp\u003e{{.}}\u003c/p\u003e"}]}`, string(buf), `HTML characters should be escaped by default`) {
+		return
+	}
+}
+
+func TestCompactIndent(t *testing.T) {
+	const src = "{ \"b\" : [ 1.50 , 1e3 ] ,\n \"a\" : \"<tag>\" }"
+
+	t.Run("Compact", func(t *testing.T) {
+		var dst bytes.Buffer
+		dst.WriteString("prefix:")
+		if !assert.NoError(t, json.Compact(&dst, []byte(src)), `json.Compact should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `prefix:{"b":[1.50,1e3],"a":"\u003ctag\u003e"}`, dst.String(), `key order and numbers should be preserved`) {
+			return
+		}
+
+		dst.Reset()
+		if !assert.NoError(t, json.Compact(&dst, []byte(src), json.WithKeyOrder(json.KeyOrderLexical), json.WithEscapeHTML(false)), `json.Compact should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"a":"<tag>","b":[1.50,1e3]}`, dst.String(), `options should be honored`) {
+			return
+		}
+
+		dst.Reset()
+		if !assert.NoError(t, json.Compact(&dst, []byte(`{a: 0x10, /* c */ b: [1,],}`), json.WithJSON5(true)), `json.Compact should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"a":16,"b":[1]}`, dst.String(), `parse options should be honored`) {
+			return
+		}
+	})
+	t.Run("Indent", func(t *testing.T) {
+		var dst bytes.Buffer
+		if !assert.NoError(t, json.Indent(&dst, []byte(src), "", "  "), `json.Indent should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "{\n  \"b\": [\n    1.50,\n    1e3\n  ],\n  \"a\": \"\\u003ctag\\u003e\"\n}", dst.String(), `output should be indented`) {
+			return
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		var dst bytes.Buffer
+		if !assert.Error(t, json.Compact(&dst, []byte(`{"a":`)), `json.Compact should fail`) {
+			return
+		}
+		if !assert.Equal(t, 0, dst.Len(), `dst should not be modified`) {
+			return
+		}
+	})
+}
+
+// chunkRecorder records the size of each call to Write
+type chunkRecorder struct {
+	bytes.Buffer
+	chunks []int
+}
+
+func (w *chunkRecorder) Write(p []byte) (int, error) {
+	w.chunks = append(w.chunks, len(p))
+	return w.Buffer.Write(p)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New(`write failed`)
+}
+
+func TestWriteTo(t *testing.T) {
+	l := make([]interface{}, 10000)
+	for i := range l {
+		l[i] = map[string]interface{}{"index": i, "name": fmt.Sprintf("item-%d", i)}
+	}
+	j := json.New(map[string]interface{}{"items": l})
+
+	expected, err := j.MarshalJSON()
+	if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+		return
+	}
+
+	var w chunkRecorder
+	n, err := j.WriteTo(&w)
+	if !assert.NoError(t, err, `WriteTo should succeed`) {
+		return
+	}
+	if !assert.Equal(t, int64(len(expected)), n, `number of bytes written should match`) {
+		return
+	}
+	if !assert.Equal(t, string(expected), w.String(), `output should match MarshalJSON`) {
+		return
+	}
+	if !assert.True(t, len(w.chunks) > 1, `output should be written in chunks`) {
+		return
+	}
+
+	if _, err := j.WriteTo(failingWriter{}); !assert.Error(t, err, `WriteTo should fail`) {
+		return
+	}
+	if _, err := j.MapIndex("missing").WriteTo(&w); !assert.Error(t, err, `WriteTo should fail for invalid Contexts`) {
+		return
+	}
+}
+
+func TestMarshalAppend(t *testing.T) {
+	const src = `{"list":[1,-2.5e10,true,false,null,"a\"b\\c"],"escape":"<&>\u0001\b\f\n\r\t\u007f  ","utf8":"日本語"}`
+	j, err := json.ParseString(src)
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+
+	t.Run("Same output as MarshalJSON", func(t *testing.T) {
+		expected, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+
+		buf, err := j.MarshalAppend([]byte(`prefix:`))
+		if !assert.NoError(t, err, `MarshalAppend should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `prefix:`+string(expected), string(buf), `output should be appended`) {
+			return
+		}
+	})
+	t.Run("Strings are escaped as encoding/json does", func(t *testing.T) {
+		strs := []string{
+			"plain",
+			"quote\" and backslash\\",
+			"\x00\x1f\x7f",
+			"<script>&amp;</script>",
+			"  ",
+			"invalid \xff utf8 \xe6\x97",
+		}
+		for _, s := range strs {
+			expected, err := stdlib.Marshal(s)
+			if !assert.NoError(t, err, `stdlib.Marshal should succeed`) {
+				return
+			}
+			buf, err := json.New(s).MarshalAppend(nil)
+			if !assert.NoError(t, err, `MarshalAppend should succeed`) {
+				return
+			}
+			if !assert.Equal(t, string(expected), string(buf), `output should match encoding/json for %q`, s) {
+				return
+			}
+
+			var out bytes.Buffer
+			enc := stdlib.NewEncoder(&out)
+			enc.SetEscapeHTML(false)
+			if !assert.NoError(t, enc.Encode(s), `Encode should succeed`) {
+				return
+			}
+			buf, err = json.Marshal(s, json.WithEscapeHTML(false))
+			if !assert.NoError(t, err, `Marshal should succeed`) {
+				return
+			}
+			if !assert.Equal(t, strings.TrimSuffix(out.String(), "\n"), string(buf), `output should match encoding/json for %q`, s) {
+				return
+			}
+		}
+	})
+	t.Run("Invalid numbers", func(t *testing.T) {
+		buf := []byte(`prefix`)
+		out, err := json.New(stdlib.Number("1.")).MarshalAppend(buf)
+		if !assert.Error(t, err, `MarshalAppend should fail`) {
+			return
+		}
+		if !assert.Equal(t, `prefix`, string(out), `buffer should be returned as is`) {
+			return
+		}
+	})
+	t.Run("Invalid Context", func(t *testing.T) {
+		out, err := j.MapIndex("missing").MarshalAppend([]byte(`prefix`))
+		if !assert.Error(t, err, `MarshalAppend should fail`) {
+			return
+		}
+		if !assert.Equal(t, `prefix`, string(out), `buffer should be returned as is`) {
+			return
+		}
+	})
+	t.Run("Reusing the buffer", func(t *testing.T) {
+		j, err := json.ParseString(`["foo","bar",1,2,true,null]`)
+		if !assert.NoError(t, err, `ParseString should succeed`) {
+			return
+		}
+		buf := make([]byte, 0, 1024)
+		allocs := testing.AllocsPerRun(100, func() {
+			buf, _ = j.MarshalAppend(buf[:0])
+		})
+		if !assert.True(t, allocs <= 2, `MarshalAppend should not allocate per value (got %v allocations)`, allocs) {
+			return
+		}
+	})
+}
+
+func TestFormat(t *testing.T) {
+	j, err := json.ParseString(`{"foo":[1,"bar",null]}`)
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		Format   string
+		Value    interface{}
+		Expected string
+	}{
+		{Format: `%v`, Value: j, Expected: `{"foo":[1,"bar",null]}`},
+		{Format: `%s`, Value: j.MapIndex("foo"), Expected: `[1,"bar",null]`},
+		{Format: `%q`, Value: j.MapIndex("foo").Index(1), Expected: `"\"bar\""`},
+		{Format: `%8s|`, Value: j.MapIndex("foo").Index(0), Expected: `       1|`},
+		{Format: `%d`, Value: j.MapIndex("foo").Index(0), Expected: `%!d(json.Context=1)`},
+		{Format: `%#v`, Value: j.MapIndex("foo"), Expected: `json.Context([]interface {}{"1", "bar", interface {}(nil)})`},
+		{Format: `%v`, Value: j.MapIndex("missing"), Expected: `<invalid json.Context: ` + j.MapIndex("missing").Bool(nil).Error() + `>`},
+		{Format: `%#v`, Value: json.New(map[string]interface{}{"a": true}).MapIndex("b"), Expected: fmt.Sprintf(`json.Context(error: %q)`, json.New(map[string]interface{}{"a": true}).MapIndex("b").Bool(nil).Error())},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Format, func(t *testing.T) {
+			if !assert.Equal(t, tc.Expected, fmt.Sprintf(tc.Format, tc.Value), `output should match`) {
+				return
+			}
+		})
+	}
+}
+
+func TestDump(t *testing.T) {
+	const src = `{"b":[1,"<x>",null,true,{}],"a":{"k":[]}}`
+	j, err := json.ParseString(src, json.WithPreserveKeyOrder(true))
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+
+	t.Run("Plain", func(t *testing.T) {
+		var buf bytes.Buffer
+		if !assert.NoError(t, j.Dump(&buf), `Dump should succeed`) {
+			return
+		}
+		const expected = "{\n  \"b\": [\n    1,\n    \"<x>\",\n    null,\n    true,\n    {}\n  ],\n  \"a\": {\n    \"k\": []\n  }\n}\n"
+		if !assert.Equal(t, expected, buf.String(), `output should match`) {
+			return
+		}
+	})
+	t.Run("Type annotations", func(t *testing.T) {
+		var buf bytes.Buffer
+		if !assert.NoError(t, j.Dump(&buf, json.WithTypeAnnotations(true)), `Dump should succeed`) {
+			return
+		}
+		const expected = `{ // object, 2 fields
+  "b": [ // array, 5 elements
+    1, // number
+    "<x>", // string
+    null, // null
+    true, // bool
+    {} // object, 0 fields
+  ],
+  "a": { // object, 1 field
+    "k": [] // array, 0 elements
+  }
+}
+`
+		if !assert.Equal(t, expected, buf.String(), `output should match`) {
+			return
+		}
+
+		// the annotated output can be parsed back
+		j2, err := json.Parse(buf.Bytes(), json.WithComments(true), json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `Parse should succeed`) {
+			return
+		}
+		buf2, err := j2.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"b":[1,"\u003cx\u003e",null,true,{}],"a":{"k":[]}}`, string(buf2), `round trip should succeed`) {
+			return
+		}
+	})
+	t.Run("Color", func(t *testing.T) {
+		var buf bytes.Buffer
+		if !assert.NoError(t, j.MapIndex("b").Dump(&buf, json.WithColor(true)), `Dump should succeed`) {
+			return
+		}
+		const expected = "[\n  \x1b[36m1\x1b[0m,\n  \x1b[32m\"<x>\"\x1b[0m,\n  \x1b[1;35mnull\x1b[0m,\n  \x1b[33mtrue\x1b[0m,\n  {}\n]\n"
+		if !assert.Equal(t, expected, buf.String(), `output should match`) {
+			return
+		}
+	})
+	t.Run("Go values", func(t *testing.T) {
+		var buf bytes.Buffer
+		v := struct {
+			Foo int `json:"foo"`
+		}{Foo: 1}
+		if !assert.NoError(t, json.New([]interface{}{v, math.NaN()}).Dump(&buf, json.WithTypeAnnotations(true)), `Dump should succeed`) {
+			return
+		}
+		const expected = `[ // array, 2 elements
+  {
+    "foo": 1
+  }, // struct { Foo int "json:\"foo\"" }
+  NaN // number
+]
+`
+		if !assert.Equal(t, expected, buf.String(), `output should match`) {
+			return
+		}
+	})
+	t.Run("Invalid Context", func(t *testing.T) {
+		var buf bytes.Buffer
+		if !assert.Error(t, j.MapIndex("missing").Dump(&buf), `Dump should fail`) {
+			return
+		}
+	})
+}
+
+func TestTimeFormat(t *testing.T) {
+	ts := time.Date(2024, time.March, 1, 12, 30, 45, 123456789, time.UTC)
+	doc := func() map[string]interface{} {
+		return map[string]interface{}{"ts": ts, "ptr": &ts}
+	}
+
+	testcases := []struct {
+		Name     string
+		Options  []json.Option
+		Expected string
+	}{
+		{Name: "Default", Expected: `{"ptr":"2024-03-01T12:30:45.123456789Z","ts":"2024-03-01T12:30:45.123456789Z"}`},
+		{Name: "Layout", Options: []json.Option{json.WithTimeLayout(time.DateOnly)}, Expected: `{"ptr":"2024-03-01","ts":"2024-03-01"}`},
+		{Name: "Epoch seconds", Options: []json.Option{json.WithTimeEpoch(time.Second)}, Expected: `{"ptr":1709296245,"ts":1709296245}`},
+		{Name: "Epoch milliseconds", Options: []json.Option{json.WithTimeEpoch(time.Millisecond)}, Expected: `{"ptr":1709296245123,"ts":1709296245123}`},
+		{Name: "Last one wins", Options: []json.Option{json.WithTimeEpoch(time.Second), json.WithTimeLayout(time.Kitchen)}, Expected: `{"ptr":"12:30PM","ts":"12:30PM"}`},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			buf, err := json.New(doc(), tc.Options...).MarshalJSON()
+			if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Expected, string(buf), `output should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("Parsed documents", func(t *testing.T) {
+		j, err := json.ParseString(`{"events":[{"name":"start"}]}`, json.WithTimeEpoch(time.Millisecond))
+		if !assert.NoError(t, err, `ParseString should succeed`) {
+			return
+		}
+		j.MapIndex("events").Index(0).SetMapIndex("at", ts)
+
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"events":[{"at":1709296245123,"name":"start"}]}`, string(buf), `output should match`) {
+			return
+		}
+	})
+}
+
+func TestMarshalOmitEmpty(t *testing.T) {
+	const src = `{"a":null,"b":"","c":[],"d":{},"e":{"f":null,"g":{"h":""}},"i":[null,"",{}],"j":0,"k":false,"l":"x"}`
+	j, err := json.ParseString(src)
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+
+	buf, err := json.Marshal(j, json.WithOmitEmpty(true))
+	if !assert.NoError(t, err, `Marshal should succeed`) {
+		return
+	}
+	if !assert.Equal(t, `{"i":[null,"",{}],"j":0,"k":false,"l":"x"}`, string(buf), `empty fields should be omitted`) {
+		return
+	}
+
+	buf, err = json.Marshal(j.MapIndex("e"), json.WithOmitEmpty(true), json.WithIndent("", "  "))
+	if !assert.NoError(t, err, `Marshal should succeed`) {
+		return
+	}
+	if !assert.Equal(t, `{}`, string(buf), `objects with only empty fields should be empty`) {
+		return
+	}
+
+	buf, err = j.MarshalJSON()
+	if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+		return
+	}
+	if !assert.Equal(t, `{"a":null,"b":"","c":[],"d":{},"e":{"f":null,"g":{"h":""}},"i":[null,"",{}],"j":0,"k":false,"l":"x"}`, string(buf), `the Context should not be modified`) {
+		return
+	}
+}
+
+func TestMarshalParallel(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString(`{"items":[`)
+	for i := 0; i < 5000; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `{"id":%d,"name":"item <%d>","tags":["a","b"],"empty":null}`, i, i)
+	}
+	sb.WriteString(`]}`)
+	j, err := json.ParseString(sb.String(), json.WithPreserveKeyOrder(true))
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		Name    string
+		Options []json.MarshalOption
+	}{
+		{Name: "compact"},
+		{Name: "indented", Options: []json.MarshalOption{json.WithIndent(">", "\t")}},
+		{Name: "omit empty", Options: []json.MarshalOption{json.WithOmitEmpty(true), json.WithEscapeHTML(false)}},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			expected, err := json.Marshal(j, tc.Options...)
+			if !assert.NoError(t, err, `Marshal should succeed`) {
+				return
+			}
+			for _, n := range []int{0, 3, 16} {
+				actual, err := json.Marshal(j, append(tc.Options, json.WithParallelism(n))...)
+				if !assert.NoError(t, err, `Marshal should succeed`) {
+					return
+				}
+				if !assert.Equal(t, string(expected), string(actual), `output should be the same with %d goroutines`, n) {
+					return
+				}
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		l := make([]interface{}, 2000)
+		for i := range l {
+			l[i] = i
+		}
+		l[1500] = stdlib.Number("not a number")
+		_, err := json.Marshal(json.New(l), json.WithParallelism(4))
+		if !assert.Error(t, err, `Marshal should fail`) {
+			return
+		}
+	})
+}
+
+// uuid is a stand-in for a third-party type that does not implement
+// json.Marshaler
+type uuid [4]byte
+
+func TestMarshalFunc(t *testing.T) {
+	marshalUUID := json.WithMarshalFunc(func(u uuid) ([]byte, error) {
+		return []byte(`"` + hex.EncodeToString(u[:]) + `"`), nil
+	})
+	marshalTime := json.WithMarshalFunc(func(t time.Time) ([]byte, error) {
+		return []byte(strconv.Itoa(t.Year())), nil
+	})
+	id := uuid{0xde, 0xad, 0xbe, 0xef}
+
+	t.Run("New", func(t *testing.T) {
+		j := json.New(map[string]interface{}{
+			"id":   id,
+			"ids":  []interface{}{id},
+			"time": time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC),
+		}, marshalUUID, marshalTime, json.WithTimeLayout(time.Kitchen))
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"id":"deadbeef","ids":["deadbeef"],"time":2024}`, string(buf), `output should match`) {
+			return
+		}
+
+		buf, err = j.MapIndex("ids").MarshalIndent("", "  ")
+		if !assert.NoError(t, err, `MarshalIndent should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "[\n  \"deadbeef\"\n]", string(buf), `output should match`) {
+			return
+		}
+	})
+	t.Run("Parse", func(t *testing.T) {
+		j, err := json.ParseString(`{"name":"foo"}`, marshalUUID)
+		if !assert.NoError(t, err, `ParseString should succeed`) {
+			return
+		}
+		j.SetMapIndex("id", id)
+
+		var sb strings.Builder
+		if !assert.NoError(t, json.NewEncoder(&sb).Encode(j), `Encode should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "{\"id\":\"deadbeef\",\"name\":\"foo\"}\n", sb.String(), `output should match`) {
+			return
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		invalid := json.WithMarshalFunc(func(u uuid) ([]byte, error) {
+			return []byte(`{`), nil
+		})
+		if _, err := json.New(id, invalid).MarshalJSON(); !assert.Error(t, err, `MarshalJSON should fail for invalid JSON`) {
+			return
+		}
+
+		failing := json.WithMarshalFunc(func(u uuid) ([]byte, error) {
+			return nil, errors.New(`failed`)
+		})
+		if _, err := json.New(id, failing).MarshalJSON(); !assert.Error(t, err, `MarshalJSON should fail`) {
+			return
+		}
+	})
+}
+
+func TestBytes(t *testing.T) {
+	j := json.New(map[string]interface{}{
+		"raw":     []byte{0xde, 0xad},
+		"encoded": "3q0=",
+		"invalid": "!!",
+		"number":  1,
+	})
+
+	for _, key := range []string{"raw", "encoded"} {
+		var b []byte
+		if !assert.NoError(t, j.MapIndex(key).Bytes(&b), `Bytes should succeed for %s`, key) {
+			return
+		}
+		if !assert.Equal(t, []byte{0xde, 0xad}, b, `value of %s should match`, key) {
+			return
+		}
+	}
+
+	var b []byte
+	if !assert.Error(t, j.MapIndex("invalid").Bytes(&b), `Bytes should fail for invalid base64`) {
+		return
+	}
+	if !assert.Error(t, j.MapIndex("number").Bytes(&b), `Bytes should fail for numbers`) {
+		return
+	}
+	var s string
+	if !assert.Error(t, j.MapIndex("raw").Bytes(&s), `Bytes should fail for non-[]byte destinations`) {
+		return
+	}
+	if !assert.Error(t, j.MapIndex("missing").Bytes(&b), `Bytes should fail for missing keys`) {
+		return
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	j, err := json.ParseString(`{"amounts":[0.1,0.2,19.99,3],"ints":[3,-7,12,12],"mixed":[1,"2"],"empty":[],"events":[{"ok":true},{"ok":false},{"ok":true}]}`)
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+
+	number := func(t *testing.T, c json.Context) string {
+		t.Helper()
+		buf, err := c.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			t.FailNow()
+		}
+		return string(buf)
+	}
+
+	t.Run("Sum", func(t *testing.T) {
+		if !assert.Equal(t, `23.29`, number(t, j.MapIndex("amounts").Sum()), `sum should be exact`) {
+			return
+		}
+		if !assert.Equal(t, `20`, number(t, j.MapIndex("ints").Sum()), `sum should match`) {
+			return
+		}
+		if !assert.Equal(t, `0`, number(t, j.MapIndex("empty").Sum()), `sum of an empty array should be 0`) {
+			return
+		}
+
+		var sum float64
+		if !assert.NoError(t, json.New([]interface{}{1, int8(2), 0.5, uint64(4)}).Sum().Float(&sum), `Float should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 7.5, sum, `sum of Go values should match`) {
+			return
+		}
+		if !assert.Equal(t, `0.3`, number(t, json.New([]float64{0.1, 0.2}).Sum()), `floating point numbers should be added as decimals`) {
+			return
+		}
+	})
+	t.Run("Avg", func(t *testing.T) {
+		if !assert.Equal(t, `5`, number(t, j.MapIndex("ints").Avg()), `average should match`) {
+			return
+		}
+		if !assert.Equal(t, `5.8225`, number(t, j.MapIndex("amounts").Avg()), `average should be exact`) {
+			return
+		}
+		if !assert.Equal(t, `0.3333333333333333`, number(t, json.New([]int{0, 0, 1}).Avg()), `average should be rounded`) {
+			return
+		}
+		if !assert.True(t, errors.Is(j.MapIndex("empty").Avg().Err(), json.ErrIndexOutOfRange), `average of an empty array should fail`) {
+			return
+		}
+	})
+	t.Run("Min and Max", func(t *testing.T) {
+		if !assert.Equal(t, `0.1`, number(t, j.MapIndex("amounts").Min()), `minimum should match`) {
+			return
+		}
+		if !assert.Equal(t, `-7`, number(t, j.MapIndex("ints").Min()), `minimum should match`) {
+			return
+		}
+
+		max := j.MapIndex("ints").Max()
+		if !assert.Equal(t, `12`, number(t, max), `maximum should match`) {
+			return
+		}
+		// the first element holding the maximum is returned
+		var found int
+		max.Set(100)
+		if !assert.NoError(t, j.MapIndex("ints").Index(2).Int(&found), `Int should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 100, found, `Max should return the first element holding the maximum`) {
+			return
+		}
+
+		if !assert.True(t, errors.Is(j.MapIndex("empty").Min().Err(), json.ErrIndexOutOfRange), `minimum of an empty array should fail`) {
+			return
+		}
+	})
+	t.Run("non-finite numbers", func(t *testing.T) {
+		nf, err := json.ParseString(`[1, Infinity, 2]`, json.WithNonFiniteNumbers(true))
+		if !assert.NoError(t, err, `ParseString should succeed`) {
+			return
+		}
+		var f float64
+		if !assert.NoError(t, nf.Sum().Float(&f), `Float should succeed`) {
+			return
+		}
+		if !assert.True(t, math.IsInf(f, 1), `sum should be infinite`) {
+			return
+		}
+		if !assert.NoError(t, nf.Min().Float(&f), `Float should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 1.0, f, `minimum should match`) {
+			return
+		}
+	})
+	t.Run("Count", func(t *testing.T) {
+		n, err := j.MapIndex("events").Count(func(c json.Context) bool {
+			var ok bool
+			return c.MapIndex("ok").Bool(&ok) == nil && ok
+		})
+		if !assert.NoError(t, err, `Count should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 2, n, `count should match`) {
+			return
+		}
+
+		n, err = j.MapIndex("events").Count(nil)
+		if !assert.NoError(t, err, `Count should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 3, n, `count should match`) {
+			return
+		}
+
+		_, err = j.Count(nil)
+		if !assert.True(t, errors.Is(err, json.ErrTypeMismatch), `Count should fail on objects`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		err := j.MapIndex("mixed").Sum().Err()
+		if !assert.True(t, errors.Is(err, json.ErrTypeMismatch), `Sum should fail on non-numbers`) {
+			return
+		}
+		var ae *json.AccessError
+		if !assert.True(t, errors.As(err, &ae), `error should be an AccessError`) {
+			return
+		}
+		if !assert.Equal(t, `$.mixed[1]`, ae.Path(), `path should point to the offending element`) {
+			return
+		}
+		if !assert.True(t, errors.Is(j.Max().Err(), json.ErrTypeMismatch), `Max should fail on objects`) {
+			return
+		}
+		if !assert.Error(t, j.MapIndex("missing").Sum().Err(), `Sum should propagate errors`) {
+			return
+		}
+	})
+}
+
+func TestGroupBy(t *testing.T) {
+	j, err := json.ParseString(`[
+		{"id":1,"user":{"name":"alice"},"kind":"login"},
+		{"id":2,"user":{"name":"bob"},"kind":"logout"},
+		{"id":3,"user":{"name":"alice"},"kind":"logout"},
+		{"id":4,"kind":"login"}
+	]`)
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+
+	ids := func(t *testing.T, c json.Context) []int {
+		t.Helper()
+		var list []int
+		for _, elem := range c.Elements() {
+			var id int
+			if !assert.NoError(t, elem.MapIndex("id").Int(&id), `Int should succeed`) {
+				t.FailNow()
+			}
+			list = append(list, id)
+		}
+		return list
+	}
+
+	t.Run("collect", func(t *testing.T) {
+		groups, err := j.GroupBy(`$.kind`)
+		if !assert.NoError(t, err, `GroupBy should succeed`) {
+			return
+		}
+		if !assert.Len(t, groups, 2, `there should be 2 groups`) {
+			return
+		}
+		if !assert.Equal(t, []int{1, 4}, ids(t, groups["login"]), `group should match`) {
+			return
+		}
+		if !assert.Equal(t, []int{2, 3}, ids(t, groups["logout"]), `group should match`) {
+			return
+		}
+	})
+	t.Run("missing keys", func(t *testing.T) {
+		_, err := j.GroupBy(`user.name`)
+		if !assert.True(t, errors.Is(err, json.ErrKeyNotFound), `GroupBy should fail with ErrKeyNotFound`) {
+			return
+		}
+
+		groups, err := j.GroupBy(`user.name`, json.WithMissingGroupKeys(json.MissingGroupKeySkip))
+		if !assert.NoError(t, err, `GroupBy should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []int{1, 3}, ids(t, groups["alice"]), `group should match`) {
+			return
+		}
+		if !assert.Equal(t, []int{2}, ids(t, groups["bob"]), `group should match`) {
+			return
+		}
+	})
+	t.Run("duplicates", func(t *testing.T) {
+		testcases := []struct {
+			Policy json.DuplicateGroupKeyPolicy
+			Login  int
+		}{
+			{Policy: json.DuplicateGroupKeyFirstWins, Login: 1},
+			{Policy: json.DuplicateGroupKeyLastWins, Login: 4},
+		}
+		for _, tc := range testcases {
+			groups, err := j.GroupBy(`kind`, json.WithDuplicateGroupKeys(tc.Policy))
+			if !assert.NoError(t, err, `GroupBy should succeed`) {
+				return
+			}
+			var id int
+			if !assert.NoError(t, groups["login"].MapIndex("id").Int(&id), `Int should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Login, id, `element should match`) {
+				return
+			}
+		}
+
+		_, err := j.GroupBy(`kind`, json.WithDuplicateGroupKeys(json.DuplicateGroupKeyError))
+		if !assert.True(t, errors.Is(err, json.ErrDuplicateKey), `GroupBy should fail with ErrDuplicateKey`) {
+			return
+		}
+		byID, err := j.GroupBy(`id`, json.WithDuplicateGroupKeys(json.DuplicateGroupKeyError))
+		if !assert.NoError(t, err, `GroupBy should succeed with unique keys`) {
+			return
+		}
+		if !assert.Len(t, byID, 4, `there should be 4 groups`) {
+			return
+		}
+	})
+	t.Run("key representation", func(t *testing.T) {
+		values := json.New([]interface{}{
+			map[string]interface{}{"k": 1},
+			map[string]interface{}{"k": stdlib.Number("1.0")},
+			map[string]interface{}{"k": true},
+			map[string]interface{}{"k": nil},
+			map[string]interface{}{"k": 2.5},
+		})
+		groups, err := values.GroupBy(`k`)
+		if !assert.NoError(t, err, `GroupBy should succeed`) {
+			return
+		}
+		var keys []string
+		for key := range groups {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if !assert.Equal(t, []string{"1", "2.5", "null", "true"}, keys, `keys should match`) {
+			return
+		}
+		n, err := groups["1"].Count(nil)
+		if !assert.NoError(t, err, `Count should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 2, n, `1 and 1.0 should share a group`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		_, err := j.GroupBy(`user`)
+		if !assert.True(t, errors.Is(err, json.ErrTypeMismatch), `GroupBy should reject objects as keys`) {
+			return
+		}
+		_, err = j.Index(0).GroupBy(`id`)
+		if !assert.True(t, errors.Is(err, json.ErrTypeMismatch), `GroupBy should fail on objects`) {
+			return
+		}
+		_, err = j.GroupBy(`$.`)
+		if !assert.Error(t, err, `GroupBy should reject invalid paths`) {
+			return
+		}
+	})
+}
+
+func TestPipeline(t *testing.T) {
+	j, err := json.ParseString(`[
+		{"kind":"purchase","amount":"19.99"},
+		{"kind":"refund","amount":"5.00"},
+		{"kind":"purchase","amount":"0.01"}
+	]`)
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+
+	isPurchase := func(c json.Context) bool {
+		var kind string
+		return c.MapIndex("kind").String(&kind) == nil && kind == "purchase"
+	}
+	amount := func(c json.Context) (interface{}, error) {
+		var s string
+		if err := c.MapIndex("amount").String(&s); err != nil {
+			return nil, err
+		}
+		return stdlib.Number(s), nil
+	}
+
+	t.Run("chain", func(t *testing.T) {
+		total := j.FilterElems(isPurchase).MapElems(amount).Sum()
+		buf, err := total.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `20`, string(buf), `total should match`) {
+			return
+		}
+	})
+	t.Run("MapElems", func(t *testing.T) {
+		kinds := j.MapElems(func(c json.Context) (interface{}, error) {
+			return c.MapIndex("kind"), nil
+		})
+		buf, err := kinds.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `["purchase","refund","purchase"]`, string(buf), `output should match`) {
+			return
+		}
+
+		failed := j.MapElems(func(c json.Context) (interface{}, error) {
+			return c.MapIndex("missing"), nil
+		})
+		if !assert.True(t, errors.Is(failed.Err(), json.ErrKeyNotFound), `MapElems should report invalid Contexts`) {
+			return
+		}
+	})
+	t.Run("FilterElems", func(t *testing.T) {
+		none := j.FilterElems(func(json.Context) bool { return false })
+		buf, err := none.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `[]`, string(buf), `output should be an empty array`) {
+			return
+		}
+
+		n, err := j.FilterElems(isPurchase).Count(nil)
+		if !assert.NoError(t, err, `Count should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 2, n, `count should match`) {
+			return
+		}
+	})
+	t.Run("Reduce", func(t *testing.T) {
+		joined := j.Reduce(func(acc, elem json.Context) (interface{}, error) {
+			var s, kind string
+			if err := acc.String(&s); err != nil {
+				return nil, err
+			}
+			if err := elem.MapIndex("kind").String(&kind); err != nil {
+				return nil, err
+			}
+			if s != "" {
+				s += ","
+			}
+			return s + kind, nil
+		}, "")
+		var s string
+		if !assert.NoError(t, joined.String(&s), `String should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `purchase,refund,purchase`, s, `result should match`) {
+			return
+		}
+
+		empty := json.New([]interface{}{}).Reduce(func(acc, elem json.Context) (interface{}, error) {
+			return nil, errors.New(`should not be called`)
+		}, 42)
+		var n int
+		if !assert.NoError(t, empty.Int(&n), `Int should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 42, n, `result should be the initial value`) {
+			return
+		}
+
+		failed := j.Reduce(func(acc, elem json.Context) (interface{}, error) {
+			return nil, errors.New(`boom`)
+		}, nil)
+		if !assert.Error(t, failed.Err(), `Reduce should report errors`) {
+			return
+		}
+	})
+	t.Run("non-arrays", func(t *testing.T) {
+		obj := json.New(map[string]interface{}{})
+		for _, c := range []json.Context{
+			obj.MapElems(amount),
+			obj.FilterElems(isPurchase),
+			obj.Reduce(func(acc, elem json.Context) (interface{}, error) { return nil, nil }, nil),
+		} {
+			if !assert.True(t, errors.Is(c.Err(), json.ErrTypeMismatch), `operations should fail on objects`) {
+				return
+			}
+		}
+	})
+}
+
+func TestOnChange(t *testing.T) {
+	type change struct {
+		Path     string
+		Old, New string
+	}
+	encode := func(c json.Context) string {
+		if c == nil {
+			return ""
+		}
+		buf, err := c.MarshalJSON()
+		if err != nil {
+			return err.Error()
+		}
+		return string(buf)
+	}
+	record := func(list *[]change) json.ChangeFunc {
+		return func(path string, old, new json.Context) {
+			*list = append(*list, change{Path: path, Old: encode(old), New: encode(new)})
+		}
+	}
+
+	j, err := json.ParseString(`{"server":{"port":8080,"hosts":["a","b"]}}`)
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+
+	var root, server []change
+	cancel := j.OnChange(record(&root))
+	serverCtx := j.MapIndex("server")
+	serverCtx.OnChange(record(&server))
+
+	serverCtx.SetMapIndex("port", 9090)
+	serverCtx.MapIndex("hosts").Index(1).Set("c")
+	j.SetMapIndex("debug", true)
+	port := serverCtx.MapIndex("port")
+	port.Set(1)
+	port.Set(2)
+
+	expected := []change{
+		{Path: `$.server.port`, Old: `8080`, New: `9090`},
+		{Path: `$.server.hosts[1]`, Old: `"b"`, New: `"c"`},
+		{Path: `$.debug`, Old: ``, New: `true`},
+		{Path: `$.server.port`, Old: `9090`, New: `1`},
+		{Path: `$.server.port`, Old: `1`, New: `2`},
+	}
+	if !assert.Equal(t, expected, root, `changes observed from the root should match`) {
+		return
+	}
+	expected = []change{
+		{Path: `$.port`, Old: `8080`, New: `9090`},
+		{Path: `$.hosts[1]`, Old: `"b"`, New: `"c"`},
+		{Path: `$.port`, Old: `9090`, New: `1`},
+		{Path: `$.port`, Old: `1`, New: `2`},
+	}
+	if !assert.Equal(t, expected, server, `changes observed from a child should be relative to it`) {
+		return
+	}
+
+	cancel()
+	j.SetMapIndex("debug", false)
+	if !assert.Len(t, root, 5, `unregistered functions should not be called`) {
+		return
+	}
+	if !assert.Len(t, server, 4, `changes outside of the child should not be observed`) {
+		return
+	}
+
+	t.Run("invalid operations", func(t *testing.T) {
+		var list []change
+		j.OnChange(record(&list))
+		j.MapIndex("server").MapIndex("port").SetMapIndex("x", 1)
+		if !assert.Empty(t, list, `failed modifications should not be observed`) {
+			return
+		}
+		j.MapIndex("missing").OnChange(record(&list))()
+	})
+}
+
+func TestTrace(t *testing.T) {
+	j, err := json.ParseString(`{"user":{"name":"alice","tags":["a"]}}`)
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	var s string
+	j.Trace(logger)
+	_ = j.MapIndex("user").MapIndex("name").String(&s)
+	_ = j.MapIndex("user").MapIndex("tags").Index(3).String(&s)
+	_ = j.MapIndex("user").MapIndex("nickname").MapIndex("first").String(&s)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		`level=DEBUG msg="json: MapIndex" path=$.user kind=object`,
+		`level=DEBUG msg="json: MapIndex" path=$.user.name kind=string`,
+		`level=DEBUG msg="json: String" path=$.user.name kind=string`,
+		`level=DEBUG msg="json: MapIndex" path=$.user kind=object`,
+		`level=DEBUG msg="json: MapIndex" path=$.user.tags kind=array`,
+		`level=DEBUG msg="json: Index failed" path=$.user.tags[3]`,
+		`level=DEBUG msg="json: String skipped on invalid Context"`,
+		`level=DEBUG msg="json: MapIndex" path=$.user kind=object`,
+		`level=DEBUG msg="json: MapIndex failed" path=$.user.nickname`,
+		`level=DEBUG msg="json: MapIndex skipped on invalid Context"`,
+		`level=DEBUG msg="json: String skipped on invalid Context"`,
+	}
+	if !assert.Len(t, lines, len(expected), `number of records should match`) {
+		return
+	}
+	for i, prefix := range expected {
+		if !assert.True(t, strings.HasPrefix(lines[i], prefix), `record %d should start with %q, got %q`, i, prefix, lines[i]) {
+			return
+		}
+	}
+	if !assert.Contains(t, lines[5], `index 3 is out of bounds`, `failures should record the error`) {
+		return
+	}
+
+	buf.Reset()
+	j.Trace(nil)
+	_ = j.MapIndex("user").MapIndex("name").String(&s)
+	if !assert.Equal(t, 0, buf.Len(), `tracing should be disabled`) {
+		return
+	}
+}
+
+func TestSynchronized(t *testing.T) {
+	t.Run("concurrent use", func(t *testing.T) {
+		j, err := json.ParseString(`{"counters":{"hits":0},"servers":[{"port":1},{"port":2}]}`, json.WithLazy(true))
+		if !assert.NoError(t, err, `ParseString should succeed`) {
+			return
+		}
+		s := json.Synchronized(j)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for n := 0; n < 100; n++ {
+					counters := s.MapIndex("counters")
+					counters.SetMapIndex(fmt.Sprintf("worker%d", i), n)
+					var port int
+					_ = s.MapIndex("servers").Index(n % 2).MapIndex("port").Int(&port)
+					_, _ = s.MarshalJSON()
+					for _, server := range s.MapIndex("servers").Elements() {
+						_ = server.MapIndex("port").Int(&port)
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < 8; i++ {
+			var n int
+			if !assert.NoError(t, s.MapIndex("counters").MapIndex(fmt.Sprintf("worker%d", i)).Int(&n), `Int should succeed`) {
+				return
+			}
+			if !assert.Equal(t, 99, n, `last value should match`) {
+				return
+			}
+		}
+	})
+	t.Run("derived Contexts", func(t *testing.T) {
+		s := json.Synchronized(json.New(map[string]interface{}{"list": []interface{}{1, 2, 3}}))
+		if !assert.True(t, json.Synchronized(s) == s, `synchronized Contexts should be returned as is`) {
+			return
+		}
+		if !assert.True(t, json.Equal(s, s), `Equal should accept synchronized Contexts`) {
+			return
+		}
+		changes, err := json.Diff(s, json.New(map[string]interface{}{"list": []interface{}{1, 2}}))
+		if !assert.NoError(t, err, `Diff should succeed`) {
+			return
+		}
+		if !assert.Len(t, changes, 1, `there should be 1 change`) {
+			return
+		}
+
+		doubled := s.MapIndex("list").MapElems(func(c json.Context) (interface{}, error) {
+			var n int
+			if err := c.Int(&n); err != nil {
+				return nil, err
+			}
+			return n * 2, nil
+		})
+		var sum int
+		if !assert.NoError(t, doubled.Sum().Int(&sum), `Int should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 12, sum, `sum should match`) {
+			return
+		}
+
+		// the body of the loop may use the synchronized Contexts
+		for i, elem := range s.MapIndex("list").Elements() {
+			elem.Set(i * 10)
+		}
+		buf, err := s.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"list":[0,10,20]}`, string(buf), `output should match`) {
+			return
+		}
+
+		if !assert.True(t, errors.Is(s.MapIndex("missing").Err(), json.ErrKeyNotFound), `errors should be propagated`) {
+			return
+		}
+	})
+}
+
+func TestVersion(t *testing.T) {
+	j, err := json.ParseString(`{"server":{"host":"localhost","ports":[80,443]},"debug":false}`, json.WithLazy(true))
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+	v1, err := json.NewVersion(j)
+	if !assert.NoError(t, err, `NewVersion should succeed`) {
+		return
+	}
+	// the version does not follow the original document
+	j.SetMapIndex("debug", true)
+
+	v2, err := v1.Set(`$.server.ports[1]`, 8443)
+	if !assert.NoError(t, err, `Set should succeed`) {
+		return
+	}
+	tls := map[string]interface{}{"enabled": true}
+	v3, err := v2.Set(`$.server.tls`, tls)
+	if !assert.NoError(t, err, `Set should succeed`) {
+		return
+	}
+	tls["enabled"] = false
+	v4, err := v3.Delete(`$.debug`)
+	if !assert.NoError(t, err, `Delete should succeed`) {
+		return
+	}
+
+	for _, tc := range []struct {
+		Name     string
+		Version  *json.Version
+		Expected string
+	}{
+		{Name: "original", Version: v1, Expected: `{"debug":false,"server":{"host":"localhost","ports":[80,443]}}`},
+		{Name: "element replaced", Version: v2, Expected: `{"debug":false,"server":{"host":"localhost","ports":[80,8443]}}`},
+		{Name: "field added", Version: v3, Expected: `{"debug":false,"server":{"host":"localhost","ports":[80,8443],"tls":{"enabled":true}}}`},
+		{Name: "field deleted", Version: v4, Expected: `{"server":{"host":"localhost","ports":[80,8443],"tls":{"enabled":true}}}`},
+	} {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			buf, err := tc.Version.MarshalJSON()
+			if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Expected, string(buf), `output should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("read-only Contexts", func(t *testing.T) {
+		c := v1.Context()
+		if !assert.True(t, errors.Is(c.MapIndex("server").SetMapIndex("host", "example.com").Err(), json.ErrReadOnly), `SetMapIndex should fail`) {
+			return
+		}
+		if !assert.True(t, errors.Is(c.MapIndex("debug").Set(true).Err(), json.ErrReadOnly), `Set should fail`) {
+			return
+		}
+		var host string
+		if !assert.NoError(t, c.MapIndex("server").MapIndex("host").String(&host), `String should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "localhost", host, `value should match`) {
+			return
+		}
+		if !assert.True(t, json.Equal(c, json.New(map[string]interface{}{"debug": false, "server": map[string]interface{}{"host": "localhost", "ports": []interface{}{80, 443}}})), `Equal should accept versions`) {
+			return
+		}
+	})
+	t.Run("accessors assign copies", func(t *testing.T) {
+		c := v2.Context()
+		var m map[string]interface{}
+		if !assert.NoError(t, c.Map(&m), `Map should succeed`) {
+			return
+		}
+		m["debug"] = true
+		m["server"].(map[string]interface{})["host"] = "example.com"
+		var ports []interface{}
+		if !assert.NoError(t, c.MapIndex("server").MapIndex("ports").Slice(&ports), `Slice should succeed`) {
+			return
+		}
+		ports[0] = 8080
+
+		v, err := v2.Set(`$.list`, []int{1, 2})
+		if !assert.NoError(t, err, `Set should succeed`) {
+			return
+		}
+		var list []int
+		if !assert.NoError(t, v.Context().MapIndex("list").Slice(&list), `Slice should succeed`) {
+			return
+		}
+		list[0] = 3
+
+		for _, tc := range []struct {
+			Version  *json.Version
+			Expected string
+		}{
+			{Version: v2, Expected: `{"debug":false,"server":{"host":"localhost","ports":[80,8443]}}`},
+			{Version: v, Expected: `{"debug":false,"list":[1,2],"server":{"host":"localhost","ports":[80,8443]}}`},
+		} {
+			buf, err := tc.Version.MarshalJSON()
+			if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Expected, string(buf), `versions should be left unchanged`) {
+				return
+			}
+		}
+	})
+	t.Run("replace document", func(t *testing.T) {
+		v, err := v1.Set(`$`, json.New([]interface{}{1, 2}))
+		if !assert.NoError(t, err, `Set should succeed`) {
+			return
+		}
+		buf, err := v.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `[1,2]`, string(buf), `output should match`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			Name     string
+			Delete   bool
+			Path     string
+			Expected error
+		}{
+			{Name: "missing container", Path: `$.client.host`, Expected: json.ErrKeyNotFound},
+			{Name: "index out of range", Path: `$.server.ports[2]`, Expected: json.ErrIndexOutOfRange},
+			{Name: "field of array", Path: `$.server.ports.first`, Expected: json.ErrTypeMismatch},
+			{Name: "delete missing field", Delete: true, Path: `$.server.tls`, Expected: json.ErrKeyNotFound},
+			{Name: "delete element of object", Delete: true, Path: `$.server[0]`, Expected: json.ErrTypeMismatch},
+		} {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				var err error
+				if tc.Delete {
+					_, err = v1.Delete(tc.Path)
+				} else {
+					_, err = v1.Set(tc.Path, 1)
+				}
+				if !assert.True(t, errors.Is(err, tc.Expected), `error should match (got %v)`, err) {
+					return
+				}
+			})
+		}
+		if _, err := v1.Delete(`$`); !assert.Error(t, err, `deleting the document should fail`) {
+			return
+		}
+		if _, err := json.NewVersion(j.MapIndex("missing")); !assert.Error(t, err, `NewVersion should fail`) {
+			return
+		}
+	})
+	t.Run("concurrent readers", func(t *testing.T) {
+		var wg sync.WaitGroup
+		versions := make(chan *json.Version, 100)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(versions)
+			v := v1
+			for i := 0; i < 100; i++ {
+				var err error
+				if v, err = v.Set(`$.server.ports[0]`, i); err != nil {
+					return
+				}
+				versions <- v
+			}
+		}()
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for v := range versions {
+					var port int
+					_ = v.Context().MapIndex("server").MapIndex("ports").Index(0).Int(&port)
+					_, _ = v1.MarshalJSON()
+				}
+			}()
+		}
+		wg.Wait()
+
+		var port int
+		if !assert.NoError(t, v1.Context().MapIndex("server").MapIndex("ports").Index(0).Int(&port), `Int should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 80, port, `original version should be unchanged`) {
+			return
+		}
+	})
+}
+
+func TestApplyDefaults(t *testing.T) {
+	const schemaSrc = `{
+		"$defs": {"port": {"type": "integer", "default": 8080}},
+		"properties": {
+			"name": {"default": "app"},
+			"server": {
+				"default": {},
+				"properties": {
+					"host": {"default": "localhost"},
+					"port": {"$ref": "#/$defs/port"},
+					"tls": {"properties": {"enabled": {"default": false}}}
+				}
+			},
+			"workers": {"items": {"properties": {"retries": {"default": 3}}}},
+			"limits": {"additionalProperties": {"properties": {"max": {"default": 10}}}}
+		},
+		"allOf": [{"properties": {"debug": {"default": false}}}]
+	}`
+	schema, err := json.ParseString(schemaSrc, json.WithPreserveKeyOrder(true))
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+
+	t.Run("sanity", func(t *testing.T) {
+		j, err := json.ParseString(`{"name":"svc","workers":[{},{"retries":1}],"limits":{"cpu":{},"mem":{"max":2}}}`, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `ParseString should succeed`) {
+			return
+		}
+
+		filled := j.ApplyDefaults(schema)
+		buf, err := filled.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"name":"svc","workers":[{"retries":3},{"retries":1}],"limits":{"cpu":{"max":10},"mem":{"max":2}},"debug":false,"server":{"host":"localhost","port":8080}}`, string(buf), `output should match`) {
+			return
+		}
+
+		if !assert.True(t, errors.Is(j.MapIndex("server").Err(), json.ErrKeyNotFound), `original document should be left as is`) {
+			return
+		}
+	})
+	t.Run("defaults are copied", func(t *testing.T) {
+		first := json.New(map[string]interface{}{}).ApplyDefaults(schema)
+		first.MapIndex("server").SetMapIndex("host", "example.com")
+
+		second := json.New(map[string]interface{}{}).ApplyDefaults(schema)
+		var host string
+		if !assert.NoError(t, second.MapIndex("server").MapIndex("host").String(&host), `String should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "localhost", host, `defaults should not be modified`) {
+			return
+		}
+	})
+	t.Run("invalid references", func(t *testing.T) {
+		for _, src := range []string{
+			`{"properties": {"a": {"$ref": "#/$defs/missing"}}}`,
+			`{"properties": {"a": {"$ref": "other.json"}}}`,
+			`{"$defs": {"a": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`,
+		} {
+			schema, err := json.ParseString(src)
+			if !assert.NoError(t, err, `ParseString should succeed`) {
+				return
+			}
+			filled := json.New(map[string]interface{}{"a": 1}).ApplyDefaults(schema)
+			if !assert.Error(t, filled.Err(), `ApplyDefaults should fail for %s`, src) {
+				return
+			}
+		}
+	})
+}
+
+func TestEqual(t *testing.T) {
+	parse := func(t *testing.T, src string, options ...json.ParseOption) json.Context {
+		t.Helper()
+		j, err := json.ParseString(src, options...)
+		if !assert.NoError(t, err, `ParseString should succeed`) {
+			t.FailNow()
+		}
+		return j
+	}
+
+	testcases := []struct {
+		Name    string
+		A, B    string
+		Options []json.EqualOption
+		Equal   bool
+	}{
+		{Name: "identical", A: `{"a":[1,"x",true,null]}`, B: `{"a":[1,"x",true,null]}`, Equal: true},
+		{Name: "key order", A: `{"a":1,"b":2}`, B: `{"b":2,"a":1}`, Equal: true},
+		{Name: "number forms", A: `{"a":1,"b":100}`, B: `{"a":1.0,"b":1e2}`, Equal: true},
+		{Name: "different numbers", A: `{"a":0.1}`, B: `{"a":0.10000001}`, Equal: false},
+		{Name: "different types", A: `{"a":"1"}`, B: `{"a":1}`, Equal: false},
+		{Name: "missing key", A: `{"a":1}`, B: `{"a":1,"b":null}`, Equal: false},
+		{Name: "array order", A: `[1,2,3]`, B: `[3,1,2]`, Equal: false},
+		{Name: "float tolerance", A: `{"a":0.1}`, B: `{"a":0.10000001}`, Options: []json.EqualOption{json.WithFloatTolerance(1e-6)}, Equal: true},
+		{Name: "beyond tolerance", A: `{"a":0.1}`, B: `{"a":0.2}`, Options: []json.EqualOption{json.WithFloatTolerance(1e-6)}, Equal: false},
+		{Name: "ignore array order", A: `[1,[2,3],{"a":1}]`, B: `[{"a":1},[3,2],1]`, Options: []json.EqualOption{json.WithIgnoreArrayOrder(true)}, Equal: true},
+		{Name: "ignore array order with duplicates", A: `[1,1,2]`, B: `[1,2,2]`, Options: []json.EqualOption{json.WithIgnoreArrayOrder(true)}, Equal: false},
+		{Name: "ignored paths", A: `{"id":1,"meta":{"at":"x","v":1}}`, B: `{"id":2,"meta":{"v":1}}`, Options: []json.EqualOption{json.WithIgnoredPaths(`$.id`, `$.meta.at`)}, Equal: true},
+		{Name: "ignored paths do not hide other differences", A: `{"id":1,"v":1}`, B: `{"id":2,"v":2}`, Options: []json.EqualOption{json.WithIgnoredPaths(`$.id`)}, Equal: false},
+		{Name: "ignored wildcard", A: `{"items":[{"id":1,"at":"x"},{"id":2,"at":"y"}]}`, B: `{"items":[{"id":1,"at":"z"},{"id":2}]}`, Options: []json.EqualOption{json.WithIgnoredPaths(`$.items[*].at`)}, Equal: true},
+		{Name: "ignored index", A: `[1,2,3]`, B: `[1,5,3]`, Options: []json.EqualOption{json.WithIgnoredPaths(`$[1]`)}, Equal: true},
+		{Name: "ignored wildcard without order", A: `[{"id":1,"at":"x"},{"id":2,"at":"y"}]`, B: `[{"id":2,"at":"z"},{"id":1}]`, Options: []json.EqualOption{json.WithIgnoreArrayOrder(true), json.WithIgnoredPaths(`$[*].at`)}, Equal: true},
+		{Name: "ignored root", A: `1`, B: `2`, Options: []json.EqualOption{json.WithIgnoredPaths(`$`)}, Equal: true},
+		{Name: "invalid path", A: `1`, B: `1`, Options: []json.EqualOption{json.WithIgnoredPaths(`$.`)}, Equal: false},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			a := parse(t, tc.A)
+			b := parse(t, tc.B)
+			if !assert.Equal(t, tc.Equal, json.Equal(a, b, tc.Options...), `Equal should return %t`, tc.Equal) {
+				return
+			}
+			if !assert.Equal(t, tc.Equal, json.Equal(b, a, tc.Options...), `Equal should be symmetric`) {
+				return
+			}
+		})
+	}
+
+	t.Run("values of different origins", func(t *testing.T) {
+		type point struct {
+			X int     `json:"x"`
+			Y float32 `json:"y"`
+		}
+		parsed := parse(t, `{"p":{"x":1,"y":1.5},"n":[1,2]}`, json.WithUseNumber(true))
+		built := json.New(map[string]interface{}{
+			"p": point{X: 1, Y: 1.5},
+			"n": []int{1, 2},
+		})
+		if !assert.True(t, json.Equal(parsed, built), `Equal should compare values by their encoding`) {
+			return
+		}
+		if !assert.True(t, json.Equal(parse(t, `[0.1]`, json.WithUseNumber(true)), json.New([]float32{0.1})), `Equal should compare floating point numbers by their encoding`) {
+			return
+		}
+
+		lazy := parse(t, `{"n":[1,2],"p":{"y":1.5,"x":1}}`, json.WithLazy(true))
+		if !assert.True(t, json.Equal(parsed, lazy), `Equal should decode deferred values`) {
+			return
+		}
+	})
+	t.Run("non-finite numbers", func(t *testing.T) {
+		a := parse(t, `[NaN, Infinity]`, json.WithNonFiniteNumbers(true))
+		b := json.New([]interface{}{math.NaN(), math.Inf(1)})
+		if !assert.True(t, json.Equal(a, b), `non-finite numbers should be equal to themselves`) {
+			return
+		}
+		if !assert.False(t, json.Equal(a, parse(t, `[1, 2]`)), `non-finite numbers should not be equal to finite numbers`) {
+			return
+		}
+	})
+	t.Run("invalid contexts", func(t *testing.T) {
+		j := parse(t, `{}`)
+		if !assert.False(t, json.Equal(j.MapIndex("missing"), j.MapIndex("missing")), `invalid Contexts should not be equal`) {
+			return
+		}
+	})
+}
+
+func TestDiff(t *testing.T) {
+	a, err := json.ParseString(`{"name":"svc","server":{"host":"localhost","port":8080},"debug":false,"tags":["a","b"],"id":1}`)
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+	b, err := json.ParseString(`{"name":"svc","server":{"host":"localhost","port":9090,"tls":true},"tags":["a","c","d"],"id":2.0}`)
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+
+	t.Run("report", func(t *testing.T) {
+		const expected = "- $.debug: false\n" +
+			"~ $.id: 1 -> 2.0\n" +
+			"~ $.server.port: 8080 -> 9090\n" +
+			"+ $.server.tls: true\n" +
+			"~ $.tags[1]: \"b\" -> \"c\"\n" +
+			"+ $.tags[2]: \"d\"\n"
+		if !assert.Equal(t, expected, json.DiffReport(a, b), `report should match`) {
+			return
+		}
+		if !assert.Equal(t, "~ $.server.port: 8080 -> 9090\n+ $.server.tls: true\n", json.DiffReport(a, b, json.WithIgnoredPaths(`$.debug`, `$.id`, `$.tags`)), `report should match`) {
+			return
+		}
+		if !assert.Equal(t, "", json.DiffReport(a, a), `report should be empty`) {
+			return
+		}
+		if !assert.True(t, strings.HasPrefix(json.DiffReport(a, b.MapIndex("missing")), `failed to compare documents: `), `report should describe the error`) {
+			return
+		}
+	})
+	t.Run("changes", func(t *testing.T) {
+		changes, err := json.Diff(a, b, json.WithIgnoredPaths(`$.debug`, `$.tags`))
+		if !assert.NoError(t, err, `Diff should succeed`) {
+			return
+		}
+		if !assert.Len(t, changes, 3, `there should be 3 changes`) {
+			return
+		}
+
+		port := changes[1]
+		if !assert.Equal(t, json.ChangeModified, port.Kind, `kind should match`) {
+			return
+		}
+		if !assert.Equal(t, `$.server.port`, port.Path, `path should match`) {
+			return
+		}
+		var before, after int
+		if !assert.NoError(t, port.Before.Int(&before), `Int should succeed`) {
+			return
+		}
+		if !assert.NoError(t, port.After.Int(&after), `Int should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []int{8080, 9090}, []int{before, after}, `values should match`) {
+			return
+		}
+
+		tls := changes[2]
+		if !assert.Equal(t, json.ChangeAdded, tls.Kind, `kind should match`) {
+			return
+		}
+		if !assert.Nil(t, tls.Before, `Before should be nil for added values`) {
+			return
+		}
+		var enabled bool
+		if !assert.NoError(t, tls.After.Bool(&enabled), `Bool should succeed`) {
+			return
+		}
+		if !assert.True(t, enabled, `After should hold the added value`) {
+			return
+		}
+	})
+	t.Run("ignore array order", func(t *testing.T) {
+		x, err := json.ParseString(`[1,2,3,3]`)
+		if !assert.NoError(t, err, `ParseString should succeed`) {
+			return
+		}
+		y, err := json.ParseString(`[3,4,1,2]`)
+		if !assert.NoError(t, err, `ParseString should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "- $[3]: 3\n+ $[1]: 4\n", json.DiffReport(x, y, json.WithIgnoreArrayOrder(true)), `report should match`) {
+			return
+		}
+	})
+	t.Run("type changes", func(t *testing.T) {
+		x := json.New(map[string]interface{}{"a": map[string]interface{}{"b": 1}})
+		y := json.New(map[string]interface{}{"a": []interface{}{1}})
+		if !assert.Equal(t, "~ $.a: {\"b\":1} -> [1]\n", json.DiffReport(x, y), `report should match`) {
+			return
+		}
+	})
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := json.Diff(a, b, json.WithIgnoredPaths(`$.`))
+		if !assert.Error(t, err, `Diff should fail`) {
+			return
+		}
+	})
+}
+
+func TestExpandEnv(t *testing.T) {
+	env := map[string]string{
+		"HOST":  "db.example.com",
+		"PORT":  "5432",
+		"EMPTY": "",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	t.Run("sanity", func(t *testing.T) {
+		j, err := json.ParseString(`{"url":"postgres://${HOST}:${PORT}/app","port":5432,"list":["${HOST}","$${HOST}","$5"],"fallback":"${MISSING:-none}/${EMPTY:-empty}"}`, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `ParseString should succeed`) {
+			return
+		}
+
+		expanded := j.ExpandEnv(lookup)
+		buf, err := expanded.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"url":"postgres://db.example.com:5432/app","port":5432,"list":["db.example.com","${HOST}","$5"],"fallback":"none/empty"}`, string(buf), `output should match`) {
+			return
+		}
+
+		var s string
+		if !assert.NoError(t, j.MapIndex("url").String(&s), `String should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "postgres://${HOST}:${PORT}/app", s, `original document should be left as is`) {
+			return
+		}
+	})
+	t.Run("missing variables", func(t *testing.T) {
+		j := json.New(map[string]interface{}{"a": "x${MISSING}y"})
+
+		var s string
+		err := j.ExpandEnv(lookup).MapIndex("a").String(&s)
+		if !assert.True(t, errors.Is(err, json.ErrMissingVariable), `error should be ErrMissingVariable`) {
+			return
+		}
+
+		policies := map[json.MissingVariablePolicy]string{
+			json.MissingVariableEmpty: "xy",
+			json.MissingVariableKeep:  "x${MISSING}y",
+		}
+		for policy, expected := range policies {
+			if !assert.NoError(t, j.ExpandEnv(lookup, json.WithMissingVariables(policy)).MapIndex("a").String(&s), `String should succeed`) {
+				return
+			}
+			if !assert.Equal(t, expected, s, `value should match`) {
+				return
+			}
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		for _, src := range []string{`"${HOST"`, `"${}"`, `"${NOT-VALID}"`} {
+			j, err := json.ParseString(src)
+			if !assert.NoError(t, err, `ParseString should succeed`) {
+				return
+			}
+			if _, err := j.ExpandEnv(lookup).MarshalJSON(); !assert.Error(t, err, `ExpandEnv should fail for %s`, src) {
+				return
+			}
+		}
+	})
+}
+
+func TestSubstitute(t *testing.T) {
+	vars, err := json.ParseString(`{"port":8080,"debug":true,"db":{"host":"db.example.com","replicas":["r1","r2"]},"labels":{"b":"2","a":"1"}}`, json.WithPreserveKeyOrder(true))
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+
+	t.Run("sanity", func(t *testing.T) {
+		j, err := json.ParseString(`{"port":"{{ .port }}","debug":"{{.debug}}","url":"http://{{ .db.host }}:{{ .port }}/","replica":"{{ .db.replicas.1 }}","labels":"{{ .labels }}","static":"{{ not a placeholder }}"}`, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `ParseString should succeed`) {
+			return
+		}
+
+		buf, err := j.Substitute(vars).MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		const expected = `{"port":8080,"debug":true,"url":"http://db.example.com:8080/","replica":"r2","labels":{"b":"2","a":"1"},"static":"{{ not a placeholder }}"}`
+		if !assert.Equal(t, expected, string(buf), `output should match`) {
+			return
+		}
+	})
+	t.Run("map", func(t *testing.T) {
+		j := json.New([]interface{}{"{{ . }}", "{{ .list }} items"})
+		buf, err := j.Index(1).Substitute(map[string]interface{}{"list": []interface{}{1, 2}}).MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `"[1,2] items"`, string(buf), `output should match`) {
+			return
+		}
+	})
+	t.Run("copies", func(t *testing.T) {
+		j := json.New(map[string]interface{}{"db": "{{ .db }}"})
+		substituted := j.Substitute(vars)
+		substituted.MapIndex("db").SetMapIndex("host", "changed")
+
+		var s string
+		if !assert.NoError(t, vars.MapIndex("db").MapIndex("host").String(&s), `String should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "db.example.com", s, `variables should not be modified`) {
+			return
+		}
+	})
+	t.Run("missing variables", func(t *testing.T) {
+		j := json.New(map[string]interface{}{"a": "{{ .missing }}", "b": "x{{ .port.0 }}"})
+		_, err := j.Substitute(vars).MarshalJSON()
+		if !assert.True(t, errors.Is(err, json.ErrMissingVariable), `error should be ErrMissingVariable`) {
+			return
+		}
+
+		buf, err := j.Substitute(vars, json.WithMissingVariables(json.MissingVariableKeep)).MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"a":"{{ .missing }}","b":"x{{ .port.0 }}"}`, string(buf), `output should match`) {
+			return
+		}
+	})
+}
+
+func TestDocument(t *testing.T) {
+	type event struct {
+		Type    string        `json:"type"`
+		Payload json.Document `json:"payload"`
+		Extra   json.Document `json:"extra,omitzero"`
+	}
+
+	t.Run("unmarshal", func(t *testing.T) {
+		var ev event
+		if !assert.NoError(t, stdlib.Unmarshal([]byte(`{"type":"click","payload":{"x":10,"tags":["a"]}}`), &ev), `Unmarshal should succeed`) {
+			return
+		}
+		if !assert.False(t, ev.Payload.IsZero(), `payload should be set`) {
+			return
+		}
+		if !assert.True(t, ev.Extra.IsZero(), `extra should not be set`) {
+			return
+		}
+
+		var x int64
+		if !assert.NoError(t, ev.Payload.MapIndex("x").Int(&x), `Int should succeed`) {
+			return
+		}
+		if !assert.Equal(t, int64(10), x, `value should match`) {
+			return
+		}
+
+		buf, err := stdlib.Marshal(ev)
+		if !assert.NoError(t, err, `Marshal should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"type":"click","payload":{"tags":["a"],"x":10}}`, string(buf), `output should match`) {
+			return
+		}
+	})
+	t.Run("null", func(t *testing.T) {
+		var ev event
+		if !assert.NoError(t, stdlib.Unmarshal([]byte(`{"type":"click","payload":null}`), &ev), `Unmarshal should succeed`) {
+			return
+		}
+		buf, err := stdlib.Marshal(ev)
+		if !assert.NoError(t, err, `Marshal should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"type":"click","payload":null}`, string(buf), `output should match`) {
+			return
+		}
+	})
+}
+
+func TestVar(t *testing.T) {
+	j, err := json.ParseString(`{"debug":true}`)
+	if !assert.NoError(t, err, `ParseString should succeed`) {
+		return
+	}
+
+	v := json.NewVar(j)
+	expvar.Publish("json-test-var", v)
+	if !assert.Equal(t, `{"debug":true}`, expvar.Get("json-test-var").String(), `output should match`) {
+		return
+	}
+
+	j.SetMapIndex("level", 3)
+	if !assert.Equal(t, `{"debug":true,"level":3}`, v.String(), `changes should be reflected`) {
+		return
+	}
+
+	v.Store(json.New([]interface{}{1}))
+	if !assert.Equal(t, `[1]`, v.String(), `stored document should be exposed`) {
+		return
+	}
+	if !assert.Equal(t, `null`, json.NewVar(nil).String(), `missing documents should be null`) {
+		return
+	}
+	if !assert.True(t, stdlib.Valid([]byte(json.NewVar(json.New(math.NaN())).String())), `output should be valid JSON on errors`) {
+		return
+	}
+}
+
+func TestFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if !assert.NoError(t, os.WriteFile(path, []byte(`{"n":1.50}`), 0644), `os.WriteFile should succeed`) {
+		return
+	}
+
+	var inline json.Flag
+	file := json.NewFlag(json.WithUseNumber(false))
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(&inline, "inline", "inline JSON")
+	fs.Var(file, "file", "JSON file")
+	if !assert.NoError(t, fs.Parse([]string{"-inline", `{"debug":true}`, "-file", "@" + path}), `Parse should succeed`) {
+		return
+	}
+
+	var b bool
+	if !assert.NoError(t, inline.Context().MapIndex("debug").Bool(&b), `Bool should succeed`) {
+		return
+	}
+	if !assert.True(t, b, `value should match`) {
+		return
+	}
+	if !assert.Equal(t, `{"n":1.5}`, file.String(), `options should be honored`) {
+		return
+	}
+	if !assert.Equal(t, "", json.NewFlag().String(), `unset flags should be empty`) {
+		return
+	}
+
+	malformed := filepath.Join(t.TempDir(), "malformed.json")
+	if !assert.NoError(t, os.WriteFile(malformed, []byte(`{"debug":`), 0644), `os.WriteFile should succeed`) {
+		return
+	}
+	for _, arg := range []string{`{"debug":`, `@` + filepath.Join(t.TempDir(), "missing.json"), `@` + malformed} {
+		if !assert.Error(t, fs.Parse([]string{"-inline", arg}), `Parse should fail for %s`, arg) {
+			return
+		}
+	}
+
+	t.Run("file modified after parsing", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.jsonc")
+		if !assert.NoError(t, os.WriteFile(path, []byte("{\n  // comment\n  \"server\": {\"port\": 8080},\n}\n"), 0644), `os.WriteFile should succeed`) {
+			return
+		}
+		f := json.NewFlag(json.WithComments(true), json.WithTrailingCommas(true), json.WithLazy(true))
+		if !assert.NoError(t, f.Set("@"+path), `Set should succeed`) {
+			return
+		}
+		// the document must not refer to the contents of the file
+		if !assert.NoError(t, os.Truncate(path, 0), `os.Truncate should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"server":{"port":8080}}`, f.String(), `value should match`) {
+			return
+		}
+	})
+}
+
+func TestGetBytes(t *testing.T) {
+	const src = `{"id":1,"meta":{"skip":[1,{"x":"y"}],"name":"foo"},"items":[{"price":1.50},{"price":2,"tags":["a","b"]}],"key with spaces":true,"dup":1,"dup":2}`
+
+	t.Run("values", func(t *testing.T) {
+		testcases := []struct {
+			Path     string
+			Expected string
+		}{
+			{Path: `$`, Expected: `{"dup":2,"id":1,"items":[{"price":1.50},{"price":2,"tags":["a","b"]}],"key with spaces":true,"meta":{"name":"foo","skip":[1,{"x":"y"}]}}`},
+			{Path: `$.id`, Expected: `1`},
+			{Path: `meta.name`, Expected: `"foo"`},
+			{Path: `$.meta.skip[1]`, Expected: `{"x":"y"}`},
+			{Path: `$.items[0].price`, Expected: `1.50`},
+			{Path: `$.items[1].tags`, Expected: `["a","b"]`},
+			{Path: `items[1]["tags"][1]`, Expected: `"b"`},
+			{Path: `$["key with spaces"]`, Expected: `true`},
+			{Path: `$.dup`, Expected: `1`},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Path, func(t *testing.T) {
+				j, err := json.GetBytes([]byte(src), tc.Path)
+				if !assert.NoError(t, err, `json.GetBytes should succeed`) {
+					return
+				}
+				buf, err := j.MarshalJSON()
+				if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+					return
+				}
+				if !assert.Equal(t, tc.Expected, string(buf), `value should match`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("options", func(t *testing.T) {
+		j, err := json.GetBytes([]byte(src), `$.meta`, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `json.GetBytes should succeed`) {
+			return
+		}
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"skip":[1,{"x":"y"}],"name":"foo"}`, string(buf), `key order should be preserved`) {
+			return
+		}
+
+		if _, err := json.GetBytes([]byte(src), `$.id`, json.WithMaxSize(10)); !assert.True(t, errors.Is(err, json.ErrLimitExceeded), `limits should be honored`) {
+			return
+		}
+	})
+	t.Run("trailing data is not read", func(t *testing.T) {
+		j, err := json.GetBytes([]byte(`{"a":[1,2],"b": garbage`), `$.a[1]`)
+		if !assert.NoError(t, err, `json.GetBytes should succeed`) {
+			return
+		}
+		var n int
+		if !assert.NoError(t, j.Int(&n), `Int should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 2, n, `value should match`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		testcases := []struct {
+			Name  string
+			Input string
+			Path  string
+		}{
+			{Name: "missing key", Input: src, Path: `$.meta.missing`},
+			{Name: "index out of bounds", Input: src, Path: `$.items[2]`},
+			{Name: "index of object", Input: src, Path: `$.meta[0]`},
+			{Name: "key of array", Input: src, Path: `$.items.price`},
+			{Name: "key of scalar", Input: src, Path: `$.id.value`},
+			{Name: "invalid JSON before the value", Input: `{"a":[1,}, "b":1}`, Path: `$.b`},
+			{Name: "truncated input", Input: `{"a":{"b":`, Path: `$.a.b`},
+			{Name: "empty key", Input: src, Path: `$..id`},
+			{Name: "invalid index", Input: src, Path: `$.items[-1]`},
+			{Name: "unterminated bracket", Input: src, Path: `$.items[0`},
+			{Name: "unterminated quoted key", Input: src, Path: `$["id]`},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				if _, err := json.GetBytes([]byte(tc.Input), tc.Path); !assert.Error(t, err, `json.GetBytes should fail`) {
+					return
+				}
+			})
+		}
+	})
+}
+
+func TestPrimitiveDestinations(t *testing.T) {
+	type myString string
+	type myInt int16
+
+	j, err := json.ParseString(`{"s": "hello", "i": 42, "f": 1.5, "b": true}`)
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+
+	t.Run("typed pointers", func(t *testing.T) {
+		var s string
+		var ms myString
+		var i int
+		var i64 int64
+		var i8 int8
+		var f float64
+		var f32 float32
+		var b bool
+		for _, err := range []error{
+			j.MapIndex("s").String(&s),
+			j.MapIndex("s").String(&ms),
+			j.MapIndex("i").Int(&i),
+			j.MapIndex("i").Int(&i64),
+			j.MapIndex("i").Int(&i8),
+			j.MapIndex("f").Float(&f),
+			j.MapIndex("f").Float(&f32),
+			j.MapIndex("b").Bool(&b),
+		} {
+			if !assert.NoError(t, err, `accessor should succeed`) {
+				return
+			}
+		}
+		if !assert.Equal(t, []interface{}{"hello", myString("hello"), 42, int64(42), int8(42), 1.5, float32(1.5), true}, []interface{}{s, ms, i, i64, i8, f, f32, b}, `values should match`) {
+			return
+		}
+	})
+	t.Run("empty interfaces", func(t *testing.T) {
+		var s, i, f, b interface{}
+		for _, err := range []error{
+			j.MapIndex("s").String(&s),
+			j.MapIndex("i").Int(&i),
+			j.MapIndex("f").Float(&f),
+			j.MapIndex("b").Bool(&b),
+		} {
+			if !assert.NoError(t, err, `accessor should succeed`) {
+				return
+			}
+		}
+		if !assert.Equal(t, []interface{}{"hello", int64(42), 1.5, true}, []interface{}{s, i, f, b}, `values should match`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		var s string
+		var i int
+		var v interface{}
+		var mi myInt
+		testcases := []struct {
+			Name string
+			Err  error
+		}{
+			{Name: "string from bool", Err: j.MapIndex("b").String(&s)},
+			{Name: "int from string", Err: j.MapIndex("s").Int(&i)},
+			{Name: "bool from string into interface", Err: j.MapIndex("s").Bool(&v)},
+			{Name: "string from bool into interface", Err: j.MapIndex("b").String(&v)},
+			{Name: "int from float into interface", Err: j.MapIndex("f").Int(&v)},
+			{Name: "nil pointer", Err: j.MapIndex("s").String((*string)(nil))},
+			{Name: "unsupported kind", Err: j.MapIndex("i").Int(&mi)},
+		}
+		for _, tc := range testcases {
+			if !assert.Error(t, tc.Err, `%s should fail`, tc.Name) {
+				return
+			}
+		}
+	})
+}
+
+func TestNavigationAllocations(t *testing.T) {
+	j, err := json.ParseString(`{"items":[{"id":1},{"id":2}]}`)
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+	items := j.MapIndex("items")
+
+	var id int
+	testcases := []struct {
+		Name     string
+		Fn       func()
+		Expected float64
+	}{
+		{Name: "MapIndex", Fn: func() { _ = j.MapIndex("items") }, Expected: 1},
+		{Name: "Index", Fn: func() { _ = items.Index(1) }, Expected: 1},
+		{Name: "Index.MapIndex.Int", Fn: func() { _ = items.Index(1).MapIndex("id").Int(&id) }, Expected: 2},
+	}
+	for _, tc := range testcases {
+		if !assert.LessOrEqual(t, testing.AllocsPerRun(100, tc.Fn), tc.Expected, `%s should allocate a single Context per step`, tc.Name) {
+			return
+		}
+	}
+
+	// children created without closures must still update their parents
+	items.Index(1).MapIndex("id").Set(3)
+	items.Index(0).Set(nil)
+	buf, err := j.MarshalJSON()
+	if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+		return
+	}
+	if !assert.Equal(t, `{"items":[null,{"id":3}]}`, string(buf), `Set should update the parents`) {
+		return
+	}
+}
+
+func TestArena(t *testing.T) {
+	const src = `{"list":[1,"two",[3.5,"four"],[]],"name":"arena é","nested":{"empty":""}}`
+
+	t.Run("Parse", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			j, err := json.ParseString(src, json.WithArena(true))
+			if !assert.NoError(t, err, `json.ParseString should succeed`) {
+				return
+			}
+			buf, err := j.MarshalJSON()
+			if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+				return
+			}
+			if !assert.Equal(t, `{"list":[1,"two",[3.5,"four"],[]],"name":"arena é","nested":{"empty":""}}`, string(buf), `values should match`) {
+				return
+			}
+
+			// appending to an array must not overwrite its neighbors
+			var list []interface{}
+			if !assert.NoError(t, j.MapIndex("list").Index(2).Slice(&list), `Slice should succeed`) {
+				return
+			}
+			_ = append(list, "five")
+			var s string
+			if !assert.NoError(t, j.MapIndex("list").Index(3).Slice(&list), `Slice should succeed`) {
+				return
+			}
+			if !assert.Len(t, list, 0, `list should be empty`) {
+				return
+			}
+			if !assert.NoError(t, j.MapIndex("name").String(&s), `String should succeed`) {
+				return
+			}
+			if !assert.Equal(t, "arena é", s, `String should match`) {
+				return
+			}
+			j.Release()
+			j.Release()
+		}
+	})
+	t.Run("Release without arena", func(t *testing.T) {
+		j, err := json.ParseString(src)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		j.Release()
+		var s string
+		if !assert.NoError(t, j.MapIndex("name").String(&s), `String should succeed`) {
+			return
+		}
+	})
+	t.Run("Allocations", func(t *testing.T) {
+		src := `[` + strings.Repeat(`["a","b",1,2],`, 100) + `[]]`
+		allocs := func(options ...json.ParseOption) float64 {
+			return testing.AllocsPerRun(10, func() {
+				j, err := json.ParseString(src, options...)
+				if err == nil {
+					j.Release()
+				}
+			})
+		}
+		if !assert.Less(t, allocs(json.WithArena(true)), allocs(), `WithArena should reduce allocations`) {
+			return
+		}
+	})
+}
+
+func TestInternKeys(t *testing.T) {
+	// keyData returns the address of the bytes of each key in the
+	// objects held in the array
+	keyData := func(t *testing.T, j json.Context) []*byte {
+		var ptrs []*byte
+		for _, elem := range j.Elements() {
+			for key := range elem.Entries() {
+				ptrs = append(ptrs, unsafe.StringData(key))
+			}
+		}
+		return ptrs
+	}
+
+	const src = `[{"name":"a"},{"name":"b"},{"name":"c"}]`
+	testcases := []struct {
+		Name    string
+		Options []json.ParseOption
+		Shared  bool
+	}{
+		{Name: "default", Shared: false},
+		{Name: "WithInternKeys", Options: []json.ParseOption{json.WithInternKeys(true)}, Shared: true},
+		{Name: "WithInternKeys and WithArena", Options: []json.ParseOption{json.WithInternKeys(true), json.WithArena(true)}, Shared: true},
+		{Name: "WithKeyTable", Options: []json.ParseOption{json.WithKeyTable(json.NewKeyTable(0))}, Shared: true},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			j, err := json.ParseString(src, tc.Options...)
+			if !assert.NoError(t, err, `json.ParseString should succeed`) {
+				return
+			}
+			ptrs := keyData(t, j)
+			if !assert.Len(t, ptrs, 3, `there should be 3 keys`) {
+				return
+			}
+			if !assert.Equal(t, tc.Shared, ptrs[0] == ptrs[1] && ptrs[1] == ptrs[2], `keys should be shared if interned`) {
+				return
+			}
+		})
+	}
+
+	t.Run("shared across documents", func(t *testing.T) {
+		table := json.NewKeyTable(2)
+		first, err := json.ParseString(`[{"alpha":1,"beta":2,"gamma":3}]`, json.WithKeyTable(table))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		second, err := json.ParseString(`[{"alpha":1,"beta":2,"gamma":3}]`, json.WithKeyTable(table))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 2, table.Len(), `table should hold no more keys than its size`) {
+			return
+		}
+
+		ptrs := append(keyData(t, first), keyData(t, second)...)
+		if !assert.Equal(t, []bool{true, true, false}, []bool{ptrs[0] == ptrs[3], ptrs[1] == ptrs[4], ptrs[2] == ptrs[5]}, `keys in the table should be shared`) {
+			return
+		}
+	})
+}
+
+func TestFindBy(t *testing.T) {
+	const src = `{"items":[{"id":1,"name":"one"},{"id":"2","name":"two"},{"id":2.0,"name":"two as a number"},{"name":"no id"},3,{"id":1,"name":"duplicate"}]}`
+
+	testcases := []struct {
+		Name     string
+		Field    string
+		Value    interface{}
+		Expected string
+		Error    bool
+	}{
+		{Name: "int", Field: "id", Value: 1, Expected: "one"},
+		{Name: "number", Field: "id", Value: stdlib.Number("2e0"), Expected: "two as a number"},
+		{Name: "float", Field: "id", Value: 2.0, Expected: "two as a number"},
+		{Name: "string", Field: "id", Value: "2", Expected: "two"},
+		{Name: "by name", Field: "name", Value: "no id", Expected: "no id"},
+		{Name: "missing value", Field: "id", Value: 3, Error: true},
+		{Name: "missing field", Field: "nope", Value: 1, Error: true},
+		{Name: "unindexable value", Field: "id", Value: []int{1}, Error: true},
+	}
+	for _, indexed := range []bool{false, true} {
+		indexed := indexed
+		t.Run(fmt.Sprintf("indexed=%t", indexed), func(t *testing.T) {
+			j, err := json.ParseString(src)
+			if !assert.NoError(t, err, `json.ParseString should succeed`) {
+				return
+			}
+			items := j.MapIndex("items")
+			if indexed {
+				if !assert.NoError(t, items.BuildIndex("id"), `BuildIndex should succeed`) {
+					return
+				}
+				if !assert.NoError(t, items.BuildIndex("name"), `BuildIndex should succeed`) {
+					return
+				}
+			}
+
+			for _, tc := range testcases {
+				tc := tc
+				t.Run(tc.Name, func(t *testing.T) {
+					var name string
+					err := items.FindBy(tc.Field, tc.Value).MapIndex("name").String(&name)
+					if tc.Error {
+						if !assert.Error(t, err, `FindBy should fail`) {
+							return
+						}
+						return
+					}
+					if !assert.NoError(t, err, `FindBy should succeed`) {
+						return
+					}
+					if !assert.Equal(t, tc.Expected, name, `FindBy should find the first matching element`) {
+						return
+					}
+				})
+			}
+		})
+	}
+
+	t.Run("modified array", func(t *testing.T) {
+		j, err := json.ParseString(`[{"id":1},{"id":2}]`)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		if !assert.NoError(t, j.BuildIndex("id"), `BuildIndex should succeed`) {
+			return
+		}
+		j.Index(0).SetMapIndex("id", 3)
+		if !assert.Error(t, j.FindBy("id", 1).MapIndex("id").Int(new(int)), `stale entries should not be found`) {
+			return
+		}
+		var i int
+		if !assert.NoError(t, j.FindBy("id", 3).MapIndex("id").Int(&i), `FindBy should succeed after rebuilding the index`) {
+			return
+		}
+	})
+	t.Run("modified elements", func(t *testing.T) {
+		j, err := json.ParseString(`[{"id":1},{"id":2}]`)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		if !assert.NoError(t, j.BuildIndex("id"), `BuildIndex should succeed`) {
+			return
+		}
+		j.Index(0).SetMapIndex("id", 3)
+		j.Index(1).Set(map[string]interface{}{"id": 4})
+		for _, id := range []int{3, 4} {
+			var i int
+			if !assert.NoError(t, j.FindBy("id", id).MapIndex("id").Int(&i), `FindBy should find keys missing from the index`) {
+				return
+			}
+			if !assert.Equal(t, id, i, `values should match`) {
+				return
+			}
+		}
+		if !assert.Error(t, j.FindBy("id", 2).Err(), `stale entries should not be found`) {
+			return
+		}
+	})
+	t.Run("modified through another Context", func(t *testing.T) {
+		j, err := json.ParseString(`{"items":[{"id":1},{"id":2}]}`)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		items := j.MapIndex("items")
+		if !assert.NoError(t, items.BuildIndex("id"), `BuildIndex should succeed`) {
+			return
+		}
+		if !assert.Error(t, items.FindBy("id", 3).Err(), `FindBy should fail`) {
+			return
+		}
+		j.MapIndex("items").Index(1).MapIndex("id").Set(3)
+		var i int
+		if !assert.NoError(t, items.FindBy("id", 3).MapIndex("id").Int(&i), `FindBy should see the modification`) {
+			return
+		}
+		if !assert.Equal(t, 3, i, `values should match`) {
+			return
+		}
+	})
+	t.Run("non-array", func(t *testing.T) {
+		j, err := json.ParseString(`{"id":1}`)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		if !assert.Error(t, j.BuildIndex("id"), `BuildIndex should fail`) {
+			return
+		}
+		if !assert.Error(t, j.FindBy("id", 1).Int(new(int)), `FindBy should fail`) {
+			return
+		}
+	})
+}
+
+func TestUnsafeStrings(t *testing.T) {
+	// aliases reports whether s refers to the memory of data
+	aliases := func(s string, data []byte) bool {
+		p := uintptr(unsafe.Pointer(unsafe.StringData(s)))
+		start := uintptr(unsafe.Pointer(&data[0]))
+		return p >= start && p < start+uintptr(len(data))
+	}
+
+	data := []byte(`{"plain":"hello world","escaped":"hello\nworld","number":12345}`)
+	j, err := json.Parse(data, json.WithUnsafeStrings(true))
+	if !assert.NoError(t, err, `json.Parse should succeed`) {
+		return
+	}
+
+	var plain, escaped string
+	var m map[string]interface{}
+	if !assert.NoError(t, j.MapIndex("plain").String(&plain), `String should succeed`) {
+		return
+	}
+	if !assert.NoError(t, j.MapIndex("escaped").String(&escaped), `String should succeed`) {
+		return
+	}
+	if !assert.NoError(t, j.Map(&m), `Map should succeed`) {
+		return
+	}
+	number := m["number"]
+	if !assert.Equal(t, "hello world", plain, `plain string should match`) {
+		return
+	}
+	if !assert.Equal(t, "hello\nworld", escaped, `escaped string should match`) {
+		return
+	}
+	if !assert.Equal(t, stdlib.Number("12345"), number, `number should match`) {
+		return
+	}
+
+	if !assert.True(t, aliases(plain, data), `plain string should refer to the input`) {
+		return
+	}
+	if !assert.False(t, aliases(escaped, data), `escaped string should be copied`) {
+		return
+	}
+	if !assert.True(t, aliases(string(number.(stdlib.Number)), data), `number should refer to the input`) {
+		return
+	}
+	for key := range j.Entries() {
+		if !assert.True(t, aliases(key, data), `key %q should refer to the input`, key) {
+			return
+		}
+	}
+
+	j, err = json.ParseString(`{"plain":"hello world"}`, json.WithUnsafeStrings(true))
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+	if !assert.NoError(t, j.MapIndex("plain").String(&plain), `String should succeed`) {
+		return
+	}
+	if !assert.Equal(t, "hello world", plain, `plain string should match`) {
+		return
+	}
+}
+
+func TestSizeEstimate(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString(`[`)
+	for i := 0; i < 10000; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `{"id":%d,"name":"item number %d","price":%d.5,"tags":["first tag","second tag"],"active":true}`, i, i, i)
+	}
+	sb.WriteString(`]`)
+	src := sb.String()
+
+	t.Run("parsed document", func(t *testing.T) {
+		var before, after runtime.MemStats
+		// values held in pools by earlier tests are only freed by the
+		// second collection, and must not be counted as freed below
+		runtime.GC()
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		j, err := json.ParseString(src)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		actual := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+
+		estimate := j.SizeEstimate()
+		runtime.KeepAlive(j)
+		if !assert.InDelta(t, 1, float64(estimate)/float64(actual), 0.5, `estimate (%d) should be close to the heap usage (%d)`, estimate, actual) {
+			return
+		}
+		if !assert.Less(t, j.Index(0).SizeEstimate(), estimate/1000, `estimate of an element should be a fraction of the document`) {
+			return
+		}
+	})
+	t.Run("Go values", func(t *testing.T) {
+		type item struct {
+			Name string
+			Tags []string
+		}
+		small := json.New(map[string]item{"a": {Name: "x"}}).SizeEstimate()
+		large := json.New(map[string]item{"a": {Name: strings.Repeat("x", 1000), Tags: []string{"one", "two"}}}).SizeEstimate()
+		if !assert.Greater(t, small, int64(0), `estimate should be positive`) {
+			return
+		}
+		if !assert.Greater(t, large-small, int64(1000), `estimate should include strings in structs`) {
+			return
+		}
+	})
+	t.Run("invalid Context", func(t *testing.T) {
+		if !assert.Equal(t, int64(0), json.New(nil).MapIndex("foo").SizeEstimate(), `estimate should be 0`) {
+			return
+		}
+	})
+}
+
+func TestSentinelErrors(t *testing.T) {
+	j, err := json.ParseString(`{"list":[1,"two"],"object":{"key":"value"},"number":1.5}`)
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		Name     string
+		Fn       func() error
+		Expected error
+	}{
+		{Name: "missing key", Fn: func() error { return j.MapIndex("nope").Int(new(int)) }, Expected: json.ErrKeyNotFound},
+		{Name: "index out of range", Fn: func() error { return j.MapIndex("list").Index(2).Int(new(int)) }, Expected: json.ErrIndexOutOfRange},
+		{Name: "MapIndex on an array", Fn: func() error { return j.MapIndex("list").MapIndex("key").Int(new(int)) }, Expected: json.ErrTypeMismatch},
+		{Name: "Index on an object", Fn: func() error { return j.MapIndex("object").Index(0).Int(new(int)) }, Expected: json.ErrTypeMismatch},
+		{Name: "Bool on a number", Fn: func() error { return j.MapIndex("list").Index(0).Bool(new(bool)) }, Expected: json.ErrTypeMismatch},
+		{Name: "Int on a fraction", Fn: func() error { return j.MapIndex("number").Int(new(int8)) }, Expected: json.ErrTypeMismatch},
+		{Name: "Slice with mixed elements", Fn: func() error { return j.MapIndex("list").Slice(new([]int)) }, Expected: json.ErrTypeMismatch},
+		{Name: "String into an int", Fn: func() error { return j.MapIndex("object").MapIndex("key").String(new(int)) }, Expected: json.ErrNotAssignable},
+		{Name: "Map into a slice", Fn: func() error { return j.MapIndex("object").Map(new([]string)) }, Expected: json.ErrNotAssignable},
+		{Name: "GetBytes with a missing key", Fn: func() error {
+			_, err := json.GetBytes([]byte(`{"a":{}}`), `$.a.b`)
+			return err
+		}, Expected: json.ErrKeyNotFound},
+		{Name: "GetBytes with an index out of range", Fn: func() error {
+			_, err := json.GetBytes([]byte(`{"a":[]}`), `$.a[0]`)
+			return err
+		}, Expected: json.ErrIndexOutOfRange},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Fn()
+			if !assert.Error(t, err, `operation should fail`) {
+				return
+			}
+			if !assert.True(t, errors.Is(err, tc.Expected), `error (%s) should wrap %s`, err, tc.Expected) {
+				return
+			}
+		})
+	}
+}
+
+func TestAccessErrorPath(t *testing.T) {
+	j, err := json.ParseString(`{"items":[{"price":"1.5"},{"price":true}]}`)
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		Name     string
+		Fn       func() error
+		Path     string
+		Expected error
+	}{
+		{Name: "missing key", Fn: func() error { return j.MapIndex("items").Index(0).MapIndex("name").String(new(string)) }, Path: `$.items[0]`, Expected: json.ErrKeyNotFound},
+		{Name: "index out of range", Fn: func() error { return j.MapIndex("items").Index(5).MapIndex("price").String(new(string)) }, Path: `$.items`, Expected: json.ErrIndexOutOfRange},
+		{Name: "accessor type mismatch", Fn: func() error { return j.MapIndex("items").Index(1).MapIndex("price").String(new(string)) }, Path: `$.items[1].price`, Expected: json.ErrTypeMismatch},
+		{Name: "accessor conversion", Fn: func() error { return j.MapIndex("items").Index(1).MapIndex("price").Float(new(float64)) }, Path: `$.items[1].price`, Expected: json.ErrTypeMismatch},
+		{Name: "GetBytes with a missing key", Fn: func() error {
+			_, err := json.GetBytes([]byte(`{"a":[{}]}`), `$.a[0].b`)
+			return err
+		}, Path: `$.a[0]`, Expected: json.ErrKeyNotFound},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Fn()
+			var aerr *json.AccessError
+			if !assert.True(t, errors.As(err, &aerr), `error (%v) should be a *json.AccessError`, err) {
+				return
+			}
+			if !assert.Equal(t, tc.Path, aerr.Path(), `path should match`) {
+				return
+			}
+			if !assert.Equal(t, tc.Expected, aerr.Kind(), `kind should match`) {
+				return
+			}
+			if !assert.Contains(t, err.Error(), `at `+tc.Path+`: `, `message should include the path`) {
+				return
+			}
+		})
+	}
+
+	t.Run("location recorded by the parser", func(t *testing.T) {
+		j, err := json.ParseString(`{"a":[1,"x"]}`, json.WithLocations(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		err = j.MapIndex("a").Index(1).Int(new(int))
+		var aerr *json.AccessError
+		if !assert.True(t, errors.As(err, &aerr), `error should be a *json.AccessError`) {
+			return
+		}
+		if !assert.Equal(t, `$.a[1]`, aerr.Path(), `path should match`) {
+			return
+		}
+	})
+}
+
+func TestErr(t *testing.T) {
+	j, err := json.ParseString(`{"a":[{"b":1}]}`)
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+
+	t.Run("valid chain", func(t *testing.T) {
+		if !assert.NoError(t, j.MapIndex("a").Index(0).MapIndex("b").Err(), `Err should return nil`) {
+			return
+		}
+	})
+	t.Run("broken chain", func(t *testing.T) {
+		err := j.MapIndex("a").Index(1).MapIndex("b").Err()
+		if !assert.Error(t, err, `Err should return an error`) {
+			return
+		}
+		if !assert.True(t, errors.Is(err, json.ErrIndexOutOfRange), `error (%s) should wrap json.ErrIndexOutOfRange`, err) {
+			return
+		}
+		if !assert.Equal(t, err, j.MapIndex("a").Index(1).MapIndex("b").Int(new(int)), `Err should return the same error as accessors`) {
+			return
+		}
+	})
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r failingReader) Read(_ []byte) (int, error) {
+	return 0, r.err
+}
+
+func TestErrorChains(t *testing.T) {
+	t.Run("syntax error", func(t *testing.T) {
+		_, err := json.ParseString(`{"a": [1, 2,]}`)
+		var serr *json.SyntaxError
+		if !assert.True(t, errors.As(err, &serr), `error (%v) should wrap a *json.SyntaxError`, err) {
+			return
+		}
+		if !assert.Equal(t, int64(12), serr.Offset, `offset should match`) {
+			return
+		}
+	})
+	t.Run("number hook error", func(t *testing.T) {
+		errHook := errors.New(`hook failed`)
+		_, err := json.ParseString(`[1]`, json.WithNumberHook(func(string) (json.Number, error) {
+			return nil, errHook
+		}))
+		if !assert.True(t, errors.Is(err, errHook), `error (%v) should wrap the error returned by the hook`, err) {
+			return
+		}
+	})
+	t.Run("reader error", func(t *testing.T) {
+		errRead := errors.New(`read failed`)
+		_, err := json.ParseReader(failingReader{err: errRead})
+		if !assert.True(t, errors.Is(err, errRead), `error (%v) should wrap the error returned by the reader`, err) {
+			return
+		}
+	})
+	t.Run("number conversion error", func(t *testing.T) {
+		j, err := json.ParseString(`123456789012345678901234567890`, json.WithUseNumber(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		err = j.Int(new(int64))
+		var nerr *strconv.NumError
+		if !assert.True(t, errors.As(err, &nerr), `error (%v) should wrap a *strconv.NumError`, err) {
+			return
+		}
+		if !assert.True(t, errors.Is(err, strconv.ErrRange), `error (%v) should wrap strconv.ErrRange`, err) {
+			return
+		}
+		if !assert.True(t, errors.Is(err, json.ErrTypeMismatch), `error (%v) should wrap json.ErrTypeMismatch`, err) {
+			return
+		}
+	})
+}
+
+func TestCollector(t *testing.T) {
+	j, err := json.ParseString(`{"name":true,"age":"old","email":"me@example.com"}`)
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+
+	t.Run("no errors", func(t *testing.T) {
+		var c json.Collector
+		var email string
+		if !assert.True(t, c.Collect(j.MapIndex("email").String(&email)), `Collect should report success`) {
+			return
+		}
+		if !assert.NoError(t, c.Err(), `Err should return nil`) {
+			return
+		}
+		if !assert.Equal(t, "me@example.com", email, `value should be assigned`) {
+			return
+		}
+	})
+	t.Run("multiple errors", func(t *testing.T) {
+		var c json.Collector
+		var name, email, phone string
+		var age int
+		c.Collect(j.MapIndex("name").String(&name))
+		c.Collect(j.MapIndex("age").Int(&age))
+		c.Collect(j.MapIndex("email").String(&email))
+		if !assert.False(t, c.Collect(j.MapIndex("phone").String(&phone)), `Collect should report failure`) {
+			return
+		}
+
+		if !assert.Len(t, c.Errors(), 3, `three errors should be collected`) {
+			return
+		}
+		err := c.Err()
+		if !assert.True(t, errors.Is(err, json.ErrTypeMismatch), `error should wrap json.ErrTypeMismatch`) {
+			return
+		}
+		if !assert.True(t, errors.Is(err, json.ErrKeyNotFound), `error should wrap json.ErrKeyNotFound`) {
+			return
+		}
+		for _, path := range []string{`at $.name: `, `at $.age: `, `at $: field "phone" not found`} {
+			if !assert.Contains(t, err.Error(), path, `message should mention each failure`) {
+				return
+			}
+		}
+		if !assert.Equal(t, "me@example.com", email, `successful accessors should assign values`) {
+			return
+		}
+	})
+}
+
+func TestStrictNumbers(t *testing.T) {
+	const src = `{"small":100,"large":300,"negative":-1,"fraction":1.5,"huge":1e300,"exact":9007199254740993,"list":[1,2.5],"map":{"a":1,"b":-2}}`
+	// numbers are decoded as float64, so that they can be converted
+	// into the elements of typed slices and maps
+	lax, err := json.ParseString(src, json.WithUseNumber(false))
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+	strict, err := json.ParseString(src, json.WithUseNumber(false), json.WithStrictNumbers(true))
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		Name   string
+		Fn     func(json.Context) error
+		Strict bool // true if the operation only fails in strict mode
+	}{
+		{Name: "int8 in range", Fn: func(j json.Context) error { return j.MapIndex("small").Int(new(int8)) }},
+		{Name: "int32", Fn: func(j json.Context) error { return j.MapIndex("large").Int(new(int32)) }},
+		{Name: "int8 overflow", Fn: func(j json.Context) error { return j.MapIndex("large").Int(new(int8)) }, Strict: true},
+		{Name: "negative into uint", Fn: func(j json.Context) error { return j.MapIndex("negative").Int(new(uint)) }, Strict: true},
+		{Name: "float32 overflow", Fn: func(j json.Context) error { return j.MapIndex("huge").Float(new(float32)) }, Strict: true},
+		{Name: "fraction in slice", Fn: func(j json.Context) error { return j.MapIndex("list").Slice(new([]int)) }, Strict: true},
+		{Name: "negative in map", Fn: func(j json.Context) error { return j.MapIndex("map").Map(new(map[string]uint8)) }, Strict: true},
+		{Name: "float32 rounding", Fn: func(j json.Context) error { return j.MapIndex("fraction").Float(new(float32)) }},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			if !assert.NoError(t, tc.Fn(lax), `operation should succeed without WithStrictNumbers`) {
+				return
+			}
+			err := tc.Fn(strict)
+			if !tc.Strict {
+				if !assert.NoError(t, err, `operation should succeed with WithStrictNumbers`) {
+					return
+				}
+				return
+			}
+			if !assert.True(t, errors.Is(err, json.ErrTypeMismatch), `error (%v) should wrap json.ErrTypeMismatch`, err) {
+				return
+			}
+		})
+	}
+
+	t.Run("inexact float", func(t *testing.T) {
+		j, err := json.ParseString(src, json.WithStrictNumbers(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		var f float64
+		if !assert.True(t, errors.Is(j.MapIndex("exact").Float(&f), json.ErrTypeMismatch), `Float should fail with json.ErrTypeMismatch`) {
+			return
+		}
+		if !assert.NoError(t, j.MapIndex("small").Float(&f), `Float should succeed`) {
+			return
+		}
+	})
+	t.Run("fraction into int", func(t *testing.T) {
+		// fractions are rejected by Int regardless of the option
+		var i int
+		if !assert.Error(t, lax.MapIndex("fraction").Int(&i), `Int should fail`) {
+			return
+		}
+	})
+	t.Run("New", func(t *testing.T) {
+		j := json.New(map[string]interface{}{"v": int64(256)}, json.WithStrictNumbers(true))
+		if !assert.Error(t, j.MapIndex("v").Int(new(uint8)), `Int should fail`) {
+			return
+		}
+	})
+}
+
+func TestTypeError(t *testing.T) {
+	j, err := json.ParseString(`{"name":"alice","tags":["a",2],"nested":{"list":[1,2,3]},"long":"` + strings.Repeat("x", 100) + `"}`)
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		Name     string
+		Fn       func() error
+		Expected json.ValueKind
+		Actual   json.ValueKind
+		Path     string
+		Excerpt  string
+	}{
+		{Name: "Int on a string", Fn: func() error { return j.MapIndex("name").Int(new(int)) }, Expected: json.NumberValue, Actual: json.StringValue, Path: `$.name`, Excerpt: `"alice"`},
+		{Name: "Bool on an array", Fn: func() error { return j.MapIndex("tags").Bool(new(bool)) }, Expected: json.BoolValue, Actual: json.ArrayValue, Path: `$.tags`, Excerpt: `["a",2]`},
+		{Name: "String on an object", Fn: func() error { return j.MapIndex("nested").String(new(string)) }, Expected: json.StringValue, Actual: json.ObjectValue, Path: `$.nested`, Excerpt: `{"list":[1,2,3]}`},
+		{Name: "MapIndex on an array", Fn: func() error { return j.MapIndex("nested").MapIndex("list").MapIndex("x").Err() }, Expected: json.ObjectValue, Actual: json.ArrayValue, Path: `$.nested.list`, Excerpt: `[1,2,3]`},
+		{Name: "Index on a string", Fn: func() error { return j.MapIndex("name").Index(0).Err() }, Expected: json.ArrayValue, Actual: json.StringValue, Path: `$.name`, Excerpt: `"alice"`},
+		{Name: "Slice element", Fn: func() error { return j.MapIndex("tags").Slice(new([]bool)) }, Expected: json.BoolValue, Actual: json.StringValue, Path: `$.tags[0]`, Excerpt: `"a"`},
+		{Name: "Slice on an object", Fn: func() error { return j.MapIndex("nested").Slice(new([]int)) }, Expected: json.ArrayValue, Actual: json.ObjectValue, Path: `$.nested`, Excerpt: `{"list":[1,2,3]}`},
+		{Name: "long excerpt", Fn: func() error { return j.MapIndex("long").Bool(new(bool)) }, Expected: json.BoolValue, Actual: json.StringValue, Path: `$.long`, Excerpt: `"` + strings.Repeat("x", 47) + `...`},
+		{Name: "GetBytes", Fn: func() error {
+			_, err := json.GetBytes([]byte(`{"a":"b"}`), `$.a.c`)
+			return err
+		}, Expected: json.ObjectValue, Actual: json.StringValue, Path: `$.a`, Excerpt: `"b"`},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Fn()
+			var terr *json.TypeError
+			if !assert.True(t, errors.As(err, &terr), `error (%v) should wrap a *json.TypeError`, err) {
+				return
+			}
+			if !assert.Equal(t, tc.Expected, terr.Expected, `expected kind should match`) {
+				return
+			}
+			if !assert.Equal(t, tc.Actual, terr.Actual, `actual kind should match`) {
+				return
+			}
+			if !assert.Equal(t, tc.Path, terr.Path, `path should match`) {
+				return
+			}
+			if !assert.Equal(t, tc.Excerpt, terr.Excerpt, `excerpt should match`) {
+				return
+			}
+			if !assert.True(t, errors.Is(err, json.ErrTypeMismatch), `error should wrap json.ErrTypeMismatch`) {
+				return
+			}
+		})
+	}
+
+	t.Run("message", func(t *testing.T) {
+		err := j.MapIndex("name").Int(new(int))
+		if !assert.EqualError(t, err, `at $.name: cannot convert to int: expected number, found string "alice"`, `message should match`) {
+			return
+		}
+	})
+}
+
+func TestInvalidDestination(t *testing.T) {
+	j, err := json.ParseString(`{"s":"hello","i":1,"f":1.5,"b":true,"l":[1],"m":{"a":1},"bytes":"aGVsbG8="}`)
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+
+	accessors := []struct {
+		Name string
+		Fn   func(interface{}) error
+		Dst  interface{} // a destination that is not a pointer
+		Nil  interface{} // a nil pointer destination
+	}{
+		{Name: "String", Fn: j.MapIndex("s").String, Dst: "", Nil: (*string)(nil)},
+		{Name: "Int", Fn: j.MapIndex("i").Int, Dst: 0, Nil: (*int)(nil)},
+		{Name: "Float", Fn: j.MapIndex("f").Float, Dst: 0.0, Nil: (*float64)(nil)},
+		{Name: "Bool", Fn: j.MapIndex("b").Bool, Dst: false, Nil: (*bool)(nil)},
+		{Name: "Slice", Fn: j.MapIndex("l").Slice, Dst: []int(nil), Nil: (*[]int)(nil)},
+		{Name: "Map", Fn: j.MapIndex("m").Map, Dst: map[string]int(nil), Nil: (*map[string]int)(nil)},
+		{Name: "Bytes", Fn: j.MapIndex("bytes").Bytes, Dst: []byte(nil), Nil: (*[]byte)(nil)},
+	}
+	for _, accessor := range accessors {
+		accessor := accessor
+		t.Run(accessor.Name, func(t *testing.T) {
+			testcases := []struct {
+				Name    string
+				Dst     interface{}
+				Message string
+			}{
+				{Name: "nil", Dst: nil, Message: `destination is nil: pass a pointer to `},
+				{Name: "non-pointer", Dst: accessor.Dst, Message: `pass &v instead of v`},
+				{Name: "nil pointer", Dst: accessor.Nil, Message: `destination is a nil *`},
+			}
+			for _, tc := range testcases {
+				tc := tc
+				t.Run(tc.Name, func(t *testing.T) {
+					var err error
+					if !assert.NotPanics(t, func() { err = accessor.Fn(tc.Dst) }, `accessor should not panic`) {
+						return
+					}
+					if !assert.True(t, errors.Is(err, json.ErrInvalidDestination), `error (%v) should wrap json.ErrInvalidDestination`, err) {
+						return
+					}
+					if !assert.Contains(t, err.Error(), tc.Message, `message should explain the problem`) {
+						return
+					}
+				})
+			}
+		})
+	}
 }
@@ -0,0 +1,228 @@
+// Package jsongen generates random JSON documents, for fuzzing code
+// that consumes JSON and for property-based tests of invariants such
+// as round trips between encodings.
+//
+// The shape of the documents, including their depth, the size of their
+// containers, the keys of their objects, and the range of their
+// numbers, can be configured via Options, and the sequence of
+// documents can be reproduced by specifying a seed. Documents can also
+// be generated as instances of a JSON Schema (see CompileSchema).
+package jsongen
+
+import (
+	stdlib "encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+
+	"github.com/lestrrat-go/json"
+)
+
+// stringAlphabet holds the characters used to generate strings, which
+// include characters that need to be escaped in JSON
+var stringAlphabet = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 _-./\"\\\n\t\u0001<>&é日本😀")
+
+// maxStringLength is the maximum number of characters in generated
+// strings
+const maxStringLength = 16
+
+// maxKeyAttempts is the number of times a key is regenerated when it
+// collides with an existing key of the same object
+const maxKeyAttempts = 8
+
+// Generator produces random JSON documents. A Generator is not safe for
+// concurrent use
+type Generator struct {
+	seed         uint64
+	rnd          *rand.Rand
+	keyAlphabet  []rune
+	maxDepth     int
+	maxKeyLength int
+	maxSize      int
+	numbers      numberRange
+}
+
+// New creates a new Generator
+func New(options ...Option) *Generator {
+	g := &Generator{
+		seed:         rand.Uint64(),
+		keyAlphabet:  []rune("abcdefghijklmnopqrstuvwxyz"),
+		maxDepth:     5,
+		maxKeyLength: 8,
+		maxSize:      8,
+		numbers:      numberRange{min: -1e6, max: 1e6},
+	}
+	for _, option := range options {
+		switch option.Name() {
+		case optKeyKeyAlphabet:
+			if alphabet := []rune(option.Value().(string)); len(alphabet) > 0 {
+				g.keyAlphabet = alphabet
+			}
+		case optKeyMaxDepth:
+			g.maxDepth = max(option.Value().(int), 0)
+		case optKeyMaxKeyLength:
+			g.maxKeyLength = max(option.Value().(int), 1)
+		case optKeyMaxSize:
+			g.maxSize = max(option.Value().(int), 0)
+		case optKeyNumberRange:
+			r := option.Value().(numberRange)
+			if r.min > r.max {
+				r.min, r.max = r.max, r.min
+			}
+			g.numbers = r
+		case optKeySeed:
+			g.seed = option.Value().(uint64)
+		}
+	}
+	g.rnd = rand.New(rand.NewPCG(g.seed, g.seed))
+	return g
+}
+
+// Seed returns the seed of the Generator, which can be passed to
+// WithSeed to reproduce the documents it produced
+func (g *Generator) Seed() uint64 {
+	return g.seed
+}
+
+// Context returns a new random document. Unless the maximum depth is 0,
+// the root of the document is an object or an array. The fields of
+// objects are kept in the order in which they were generated
+func (g *Generator) Context() json.Context {
+	var v interface{}
+	switch {
+	case g.maxDepth == 0:
+		v = g.scalar()
+	case g.rnd.IntN(2) == 0:
+		v = g.object(1)
+	default:
+		v = g.array(1)
+	}
+	return build(v)
+}
+
+// Bytes returns the compact encoding of a new random document
+func (g *Generator) Bytes() ([]byte, error) {
+	buf, err := g.Context().MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to marshal generated document: %w`, err)
+	}
+	return buf, nil
+}
+
+// value returns a random value at the given depth, which is a
+// container only if the maximum depth has not been reached
+func (g *Generator) value(depth int) interface{} {
+	if depth < g.maxDepth {
+		switch g.rnd.IntN(6) {
+		case 0:
+			return g.object(depth + 1)
+		case 1:
+			return g.array(depth + 1)
+		}
+	}
+	return g.scalar()
+}
+
+func (g *Generator) scalar() interface{} {
+	switch g.rnd.IntN(4) {
+	case 0:
+		return nil
+	case 1:
+		return g.rnd.IntN(2) == 0
+	case 2:
+		return g.number()
+	}
+	return g.string(stringAlphabet, g.rnd.IntN(maxStringLength+1))
+}
+
+func (g *Generator) object(depth int) *object {
+	n := g.rnd.IntN(g.maxSize + 1)
+	o := &object{values: make(map[string]interface{}, n)}
+	for range n {
+		for range maxKeyAttempts {
+			key := g.string(g.keyAlphabet, 1+g.rnd.IntN(g.maxKeyLength))
+			if _, ok := o.values[key]; ok {
+				continue
+			}
+			o.keys = append(o.keys, key)
+			o.values[key] = g.value(depth)
+			break
+		}
+	}
+	return o
+}
+
+func (g *Generator) array(depth int) []interface{} {
+	l := make([]interface{}, g.rnd.IntN(g.maxSize+1))
+	for i := range l {
+		l[i] = g.value(depth)
+	}
+	return l
+}
+
+// number returns a random integer or float within the number range
+func (g *Generator) number() stdlib.Number {
+	lo, hi := math.Ceil(g.numbers.min), math.Floor(g.numbers.max)
+	if lo <= hi && g.rnd.IntN(2) == 0 {
+		f := math.Floor(lo + g.rnd.Float64()*(hi-lo+1))
+		return stdlib.Number(strconv.FormatFloat(min(f, hi), 'f', 0, 64))
+	}
+	f := g.numbers.min + g.rnd.Float64()*(g.numbers.max-g.numbers.min)
+	return stdlib.Number(strconv.FormatFloat(min(max(f, g.numbers.min), g.numbers.max), 'g', -1, 64))
+}
+
+func (g *Generator) string(alphabet []rune, n int) string {
+	s := make([]rune, n)
+	for i := range s {
+		s[i] = alphabet[g.rnd.IntN(len(alphabet))]
+	}
+	return string(s)
+}
+
+// object is a generated JSON object. keys holds the keys in the order in
+// which they were generated
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// build creates a Context for the generated value v
+func build(v interface{}) json.Context {
+	c := json.New(placeholder(v), json.WithPreserveKeyOrder(true))
+	populate(c, v)
+	return c
+}
+
+// placeholder returns the value that should be stored for v before
+// calling populate. Objects are represented by empty maps, so that
+// their keys can be added through the Context in order
+func placeholder(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *object:
+		return map[string]interface{}{}
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, elem := range v {
+			l[i] = placeholder(elem)
+		}
+		return l
+	}
+	return v
+}
+
+// populate adds the contents of v to c, which holds placeholder(v)
+func populate(c json.Context, v interface{}) {
+	switch v := v.(type) {
+	case *object:
+		for _, key := range v.keys {
+			elem := v.values[key]
+			c.SetMapIndex(key, placeholder(elem))
+			populate(c.MapIndex(key), elem)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			populate(c.Index(i), elem)
+		}
+	}
+}
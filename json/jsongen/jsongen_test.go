@@ -0,0 +1,335 @@
+package jsongen_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lestrrat-go/json"
+	"github.com/lestrrat-go/json/jsongen"
+	"github.com/lestrrat-go/json/jsontest"
+	"github.com/stretchr/testify/assert"
+)
+
+// depth returns the nesting depth of the document held by c
+func depth(c json.Context) int {
+	var d int
+	for _, child := range c.Entries() {
+		d = max(d, depth(child))
+	}
+	for _, child := range c.Elements() {
+		d = max(d, depth(child))
+	}
+	var m map[string]interface{}
+	var l []interface{}
+	if c.Map(&m) == nil || c.Slice(&l) == nil {
+		d++
+	}
+	return d
+}
+
+func TestGenerator(t *testing.T) {
+	t.Run("seed", func(t *testing.T) {
+		g1 := jsongen.New(jsongen.WithSeed(42))
+		g2 := jsongen.New(jsongen.WithSeed(g1.Seed()))
+		for i := 0; i < 10; i++ {
+			buf1, err := g1.Bytes()
+			if !assert.NoError(t, err, `Bytes should succeed`) {
+				return
+			}
+			buf2, err := g2.Bytes()
+			if !assert.NoError(t, err, `Bytes should succeed`) {
+				return
+			}
+			if !assert.Equal(t, string(buf1), string(buf2), `documents should be reproducible`) {
+				return
+			}
+		}
+	})
+	t.Run("round trip", func(t *testing.T) {
+		g := jsongen.New()
+		for i := 0; i < 100; i++ {
+			buf, err := g.Bytes()
+			if !assert.NoError(t, err, `Bytes should succeed (seed %d)`, g.Seed()) {
+				return
+			}
+			j, err := json.Parse(buf, json.WithPreserveKeyOrder(true))
+			if !assert.NoError(t, err, `json.Parse should succeed for %s (seed %d)`, buf, g.Seed()) {
+				return
+			}
+			buf2, err := j.MarshalJSON()
+			if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+				return
+			}
+			if !assert.Equal(t, string(buf), string(buf2), `output should match (seed %d)`, g.Seed()) {
+				return
+			}
+		}
+	})
+	t.Run("limits", func(t *testing.T) {
+		g := jsongen.New(
+			jsongen.WithMaxDepth(3),
+			jsongen.WithMaxSize(4),
+			jsongen.WithKeyAlphabet("xy"),
+			jsongen.WithMaxKeyLength(2),
+			jsongen.WithNumberRange(10, 20),
+		)
+		for i := 0; i < 100; i++ {
+			j := g.Context()
+			if !assert.LessOrEqual(t, depth(j), 3, `depth should be limited (seed %d)`, g.Seed()) {
+				return
+			}
+			err := j.Walk(func(path string, c json.Context) (json.WalkAction, error) {
+				var m map[string]interface{}
+				var l []interface{}
+				switch {
+				case c.Map(&m) == nil:
+					assert.LessOrEqual(t, len(m), 4, `objects at %s should be limited`, path)
+					for key := range m {
+						assert.True(t, len(key) >= 1 && len(key) <= 2 && strings.Trim(key, "xy") == "", `key %q should use the alphabet`, key)
+					}
+				case c.Slice(&l) == nil:
+					assert.LessOrEqual(t, len(l), 4, `arrays at %s should be limited`, path)
+				default:
+					buf, err := c.MarshalJSON()
+					if err != nil {
+						return json.WalkContinue, err
+					}
+					if f, err := strconv.ParseFloat(string(buf), 64); err == nil {
+						assert.True(t, f >= 10 && f <= 20, `number %s at %s should be in range`, buf, path)
+					}
+				}
+				return json.WalkContinue, nil
+			})
+			if !assert.NoError(t, err, `Walk should succeed`) {
+				return
+			}
+		}
+	})
+	t.Run("scalars", func(t *testing.T) {
+		g := jsongen.New(jsongen.WithMaxDepth(0))
+		for i := 0; i < 20; i++ {
+			if !assert.Equal(t, 0, depth(g.Context()), `documents should be scalars`) {
+				return
+			}
+		}
+	})
+	t.Run("key order", func(t *testing.T) {
+		g := jsongen.New(jsongen.WithSeed(1))
+		for i := 0; i < 20; i++ {
+			j := g.Context()
+			parsed, err := json.ParseString(mustMarshal(t, j), json.WithUseNumber(true))
+			if !assert.NoError(t, err, `json.ParseString should succeed`) {
+				return
+			}
+			if !jsontest.AssertEqual(t, j, parsed) {
+				return
+			}
+		}
+	})
+}
+
+func mustMarshal(t *testing.T, c json.Context) string {
+	t.Helper()
+	buf, err := c.MarshalJSON()
+	if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+		t.FailNow()
+	}
+	return string(buf)
+}
+
+const userSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "User",
+  "type": "object",
+  "required": ["id", "name", "role", "created", "score", "tags"],
+  "properties": {
+    "id": {"type": "string", "format": "uuid"},
+    "name": {"type": "string", "minLength": 3, "maxLength": 5},
+    "email": {"type": "string", "format": "email"},
+    "role": {"enum": ["admin", "member"]},
+    "created": {"type": "string", "format": "date-time"},
+    "age": {"type": "integer", "minimum": 18, "exclusiveMaximum": 21},
+    "score": {"type": "number", "minimum": 0, "maximum": 1},
+    "level": {"type": "integer", "multipleOf": 5, "minimum": 1, "maximum": 12},
+    "tags": {"type": "array", "items": {"type": "string", "maxLength": 2}, "minItems": 1, "maxItems": 3, "uniqueItems": true},
+    "manager": {"$ref": "#"},
+    "address": {"$ref": "#/$defs/address"},
+    "nickname": {"type": ["string", "null"]},
+    "kind": {"const": "user"}
+  },
+  "additionalProperties": false,
+  "$defs": {
+    "address": {"type": "object", "required": ["city"], "properties": {"city": {"type": "string"}}}
+  }
+}`
+
+func TestInstance(t *testing.T) {
+	t.Run("user", func(t *testing.T) {
+		s, err := jsongen.ParseSchema([]byte(userSchema))
+		if !assert.NoError(t, err, `jsongen.ParseSchema should succeed`) {
+			return
+		}
+
+		allowed := map[string]struct{}{}
+		for _, key := range []string{"id", "name", "email", "role", "created", "age", "score", "level", "tags", "manager", "address", "nickname", "kind"} {
+			allowed[key] = struct{}{}
+		}
+
+		var check func(t *testing.T, j json.Context) bool
+		check = func(t *testing.T, j json.Context) bool {
+			var m map[string]interface{}
+			if !assert.NoError(t, j.Map(&m), `instance should be an object`) {
+				return false
+			}
+			for _, key := range []string{"id", "name", "role", "created", "score", "tags"} {
+				if !assert.Contains(t, m, key, `required property %s should be present`, key) {
+					return false
+				}
+			}
+			for key := range m {
+				if !assert.Contains(t, allowed, key, `property %s should be allowed`, key) {
+					return false
+				}
+			}
+
+			var s string
+			_ = j.MapIndex("id").String(&s)
+			if !assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, s, `id should be a UUID`) {
+				return false
+			}
+			_ = j.MapIndex("name").String(&s)
+			if n := utf8.RuneCountInString(s); !assert.True(t, n >= 3 && n <= 5, `name %q should have 3 to 5 characters`, s) {
+				return false
+			}
+			_ = j.MapIndex("role").String(&s)
+			if !assert.Contains(t, []string{"admin", "member"}, s, `role should be one of the enum values`) {
+				return false
+			}
+			_ = j.MapIndex("created").String(&s)
+			if _, err := time.Parse(time.RFC3339, s); !assert.NoError(t, err, `created should be a date-time`) {
+				return false
+			}
+			if _, ok := m["email"]; ok {
+				_ = j.MapIndex("email").String(&s)
+				if !assert.Regexp(t, `^[a-z]+@[a-z]+\.example$`, s, `email should be an address`) {
+					return false
+				}
+			}
+
+			var f float64
+			if !assert.NoError(t, j.MapIndex("score").Float(&f), `score should be a number`) || !assert.True(t, f >= 0 && f <= 1, `score %v should be in range`, f) {
+				return false
+			}
+			var i int
+			if _, ok := m["age"]; ok {
+				if !assert.NoError(t, j.MapIndex("age").Int(&i), `age should be an integer`) || !assert.True(t, i >= 18 && i < 21, `age %d should be in range`, i) {
+					return false
+				}
+			}
+			if _, ok := m["level"]; ok {
+				if !assert.NoError(t, j.MapIndex("level").Int(&i), `level should be an integer`) || !assert.Contains(t, []int{5, 10}, i, `level should be a multiple of 5`) {
+					return false
+				}
+			}
+
+			var tags []interface{}
+			if !assert.NoError(t, j.MapIndex("tags").Slice(&tags), `tags should be an array`) || !assert.True(t, len(tags) >= 1 && len(tags) <= 3, `tags should have 1 to 3 elements`) {
+				return false
+			}
+			seen := map[interface{}]struct{}{}
+			for _, tag := range tags {
+				if !assert.NotContains(t, seen, tag, `tags should be unique`) {
+					return false
+				}
+				seen[tag] = struct{}{}
+			}
+
+			if _, ok := m["address"]; ok {
+				if !assert.NoError(t, j.MapIndex("address").MapIndex("city").String(&s), `address should have a city`) {
+					return false
+				}
+			}
+			if _, ok := m["kind"]; ok {
+				_ = j.MapIndex("kind").String(&s)
+				if !assert.Equal(t, "user", s, `kind should be the constant`) {
+					return false
+				}
+			}
+			if _, ok := m["manager"]; ok {
+				return check(t, j.MapIndex("manager"))
+			}
+			return true
+		}
+
+		g := jsongen.New()
+		for i := 0; i < 200; i++ {
+			j, err := g.Instance(s)
+			if !assert.NoError(t, err, `Instance should succeed (seed %d)`, g.Seed()) {
+				return
+			}
+			if !check(t, j) {
+				t.Logf(`seed %d: %s`, g.Seed(), mustMarshal(t, j))
+				return
+			}
+		}
+	})
+	t.Run("seed", func(t *testing.T) {
+		s, err := jsongen.ParseSchema([]byte(userSchema))
+		if !assert.NoError(t, err, `jsongen.ParseSchema should succeed`) {
+			return
+		}
+		g1 := jsongen.New(jsongen.WithSeed(7))
+		g2 := jsongen.New(jsongen.WithSeed(7))
+		for i := 0; i < 10; i++ {
+			buf1, err := g1.InstanceBytes(s)
+			if !assert.NoError(t, err, `InstanceBytes should succeed`) {
+				return
+			}
+			buf2, err := g2.InstanceBytes(s)
+			if !assert.NoError(t, err, `InstanceBytes should succeed`) {
+				return
+			}
+			if !assert.Equal(t, string(buf1), string(buf2), `instances should be reproducible`) {
+				return
+			}
+		}
+	})
+	t.Run("unsatisfiable instances", func(t *testing.T) {
+		s, err := jsongen.ParseSchema([]byte(`{"type": "object", "required": ["next"], "properties": {"next": {"$ref": "#"}}}`))
+		if !assert.NoError(t, err, `jsongen.ParseSchema should succeed`) {
+			return
+		}
+		if _, err := jsongen.New().Instance(s); !assert.Error(t, err, `infinitely recursive schemas should fail`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		testcases := []struct {
+			Name   string
+			Schema string
+		}{
+			{Name: "not a schema", Schema: `1`},
+			{Name: "pattern", Schema: `{"type": "string", "pattern": "^a+$"}`},
+			{Name: "allOf", Schema: `{"allOf": [{"type": "string"}]}`},
+			{Name: "unknown type", Schema: `{"type": "date"}`},
+			{Name: "unknown format", Schema: `{"format": "color"}`},
+			{Name: "external reference", Schema: `{"$ref": "https://example.com/schema.json"}`},
+			{Name: "missing reference", Schema: `{"$ref": "#/$defs/missing"}`},
+			{Name: "empty enum", Schema: `{"enum": []}`},
+			{Name: "invalid range", Schema: `{"minimum": 2, "maximum": 1}`},
+			{Name: "invalid length", Schema: `{"minLength": -1}`},
+			{Name: "forbidden required property", Schema: `{"required": ["a"], "additionalProperties": false}`},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				if _, err := jsongen.ParseSchema([]byte(tc.Schema)); !assert.Error(t, err, `jsongen.ParseSchema should fail`) {
+					return
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package jsongen
+
+const (
+	optKeyKeyAlphabet  = `optkey-key-alphabet`
+	optKeyMaxDepth     = `optkey-max-depth`
+	optKeyMaxKeyLength = `optkey-max-key-length`
+	optKeyMaxSize      = `optkey-max-size`
+	optKeyNumberRange  = `optkey-number-range`
+	optKeySeed         = `optkey-seed`
+)
+
+// Option configures the documents produced by a Generator
+type Option interface {
+	Name() string
+	Value() interface{}
+}
+
+type option struct {
+	name  string
+	value interface{}
+}
+
+func (o option) Name() string {
+	return o.name
+}
+
+func (o option) Value() interface{} {
+	return o.value
+}
+
+type numberRange struct {
+	min float64
+	max float64
+}
+
+// WithKeyAlphabet specifies the characters used to generate the keys
+// of objects. The default is the lowercase ASCII letters
+func WithKeyAlphabet(alphabet string) Option {
+	return &option{name: optKeyKeyAlphabet, value: alphabet}
+}
+
+// WithMaxDepth specifies the maximum nesting depth of the generated
+// documents, where the root container has a depth of 1. A depth of 0
+// produces scalar values only. The default is 5
+func WithMaxDepth(n int) Option {
+	return &option{name: optKeyMaxDepth, value: n}
+}
+
+// WithMaxKeyLength specifies the maximum number of characters in the
+// keys of objects. Keys are at least one character long.
+// The default is 8
+func WithMaxKeyLength(n int) Option {
+	return &option{name: optKeyMaxKeyLength, value: n}
+}
+
+// WithMaxSize specifies the maximum number of elements of arrays and
+// fields of objects. The default is 8
+func WithMaxSize(n int) Option {
+	return &option{name: optKeyMaxSize, value: n}
+}
+
+// WithNumberRange specifies the range of the generated numbers, which
+// are integers or floats with min <= n <= max. The default range is
+// -1e6 to 1e6
+func WithNumberRange(min, max float64) Option {
+	return &option{name: optKeyNumberRange, value: numberRange{min: min, max: max}}
+}
+
+// WithSeed specifies the seed of the random number generator, so that
+// the same sequence of documents is produced on every run. By default,
+// a random seed is used, which can be retrieved via Generator.Seed
+func WithSeed(seed uint64) Option {
+	return &option{name: optKeySeed, value: seed}
+}
@@ -0,0 +1,693 @@
+package jsongen
+
+import (
+	"bytes"
+	stdlib "encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/json"
+)
+
+// maxSchemaDepth is the maximum nesting depth of instances generated
+// from schemas. Recursive schemas that require deeper instances cannot
+// be satisfied
+const maxSchemaDepth = 100
+
+// annotations lists the keywords that do not constrain instances, and
+// are therefore ignored
+var annotations = map[string]struct{}{
+	`$schema`:     {},
+	`$id`:         {},
+	`$comment`:    {},
+	`$defs`:       {},
+	`definitions`: {},
+	`title`:       {},
+	`description`: {},
+	`default`:     {},
+	`examples`:    {},
+	`readOnly`:    {},
+	`writeOnly`:   {},
+	`deprecated`:  {},
+}
+
+var formats = map[string]struct{}{
+	`date-time`: {},
+	`date`:      {},
+	`time`:      {},
+	`email`:     {},
+	`hostname`:  {},
+	`ipv4`:      {},
+	`uri`:       {},
+	`uuid`:      {},
+}
+
+// Schema is a compiled JSON Schema, from which a Generator can produce
+// valid instances. See CompileSchema for the supported keywords
+type Schema struct {
+	root json.Context
+	refs map[string]*schemaNode
+	node *schemaNode
+}
+
+type property struct {
+	name   string
+	schema *schemaNode
+}
+
+// schemaNode holds the constraints of a (sub)schema
+type schemaNode struct {
+	// boolean schemas
+	never bool
+
+	ref    string
+	target *schemaNode
+
+	types  []string
+	values []interface{} // const and enum
+	anyOf  []*schemaNode // anyOf and oneOf
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum bool
+	exclusiveMaximum bool
+	multipleOf       float64
+
+	minLength int
+	maxLength int // -1 if unspecified
+	format    string
+
+	items       *schemaNode
+	minItems    int
+	maxItems    int // -1 if unspecified
+	uniqueItems bool
+
+	properties []property
+	required   map[string]struct{}
+	additional *schemaNode
+}
+
+// CompileSchema compiles the JSON Schema held by c. The keywords of
+// JSON Schema draft 2020-12 that can be used to generate instances are
+// supported:
+//
+//   - type, const, enum, anyOf, and oneOf, where instances of oneOf are
+//     generated from one of the subschemas, without checking that the
+//     other subschemas do not match
+//   - minimum, maximum, exclusiveMinimum, exclusiveMaximum, and multipleOf
+//   - minLength, maxLength, and format, for the date-time, date, time,
+//     email, hostname, ipv4, uri, and uuid formats
+//   - items, minItems, maxItems, and uniqueItems
+//   - properties, required, and additionalProperties
+//   - $ref, for references within the document, such as `#/$defs/foo`
+//
+// Annotations such as title and description are ignored, while all
+// other keywords, including pattern and allOf, are rejected so that
+// the generated instances are never silently invalid
+func CompileSchema(c json.Context) (*Schema, error) {
+	s := &Schema{root: c, refs: make(map[string]*schemaNode)}
+	node, err := s.compile(`#`, c)
+	if err != nil {
+		return nil, err
+	}
+	s.node = node
+	return s, nil
+}
+
+// ParseSchema parses and compiles the JSON Schema in data
+func ParseSchema(data []byte) (*Schema, error) {
+	c, err := json.Parse(data, json.WithPreserveKeyOrder(true), json.WithUseNumber(true))
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse schema: %w`, err)
+	}
+	return CompileSchema(c)
+}
+
+// compile compiles the schema c, found at the JSON pointer path
+func (s *Schema) compile(path string, c json.Context) (*schemaNode, error) {
+	var b bool
+	if err := c.Bool(&b); err == nil {
+		return &schemaNode{never: !b, maxLength: -1, maxItems: -1}, nil
+	}
+
+	var m map[string]interface{}
+	if err := c.Map(&m); err != nil {
+		return nil, fmt.Errorf(`schema at %s must be an object or a boolean`, path)
+	}
+
+	node := &schemaNode{maxLength: -1, maxItems: -1}
+	for kw := range c.Entries() {
+		child := c.MapIndex(kw)
+		kwPath := path + "/" + escapePointer(kw)
+
+		var err error
+		switch kw {
+		case `$ref`:
+			err = child.String(&node.ref)
+			if err == nil {
+				node.target, err = s.resolve(node.ref)
+			}
+		case `type`:
+			node.types, err = compileTypes(child)
+		case `const`:
+			node.values = []interface{}{fromContext(child)}
+		case `enum`:
+			for _, elem := range child.Elements() {
+				node.values = append(node.values, fromContext(elem))
+			}
+			if len(node.values) == 0 {
+				err = errors.New(`enum must be a non-empty array`)
+			}
+		case `anyOf`, `oneOf`:
+			for i, elem := range child.Elements() {
+				sub, err := s.compile(fmt.Sprintf(`%s/%d`, kwPath, i), elem)
+				if err != nil {
+					return nil, err
+				}
+				node.anyOf = append(node.anyOf, sub)
+			}
+			if len(node.anyOf) == 0 {
+				err = fmt.Errorf(`%s must be a non-empty array`, kw)
+			}
+		case `minimum`:
+			node.minimum, err = compileNumber(child)
+		case `maximum`:
+			node.maximum, err = compileNumber(child)
+		case `exclusiveMinimum`:
+			node.minimum, err = compileNumber(child)
+			node.exclusiveMinimum = true
+		case `exclusiveMaximum`:
+			node.maximum, err = compileNumber(child)
+			node.exclusiveMaximum = true
+		case `multipleOf`:
+			var f *float64
+			if f, err = compileNumber(child); err == nil {
+				if *f <= 0 {
+					err = errors.New(`multipleOf must be greater than 0`)
+				}
+				node.multipleOf = *f
+			}
+		case `minLength`:
+			node.minLength, err = compileCount(child)
+		case `maxLength`:
+			node.maxLength, err = compileCount(child)
+		case `format`:
+			if err = child.String(&node.format); err == nil {
+				if _, ok := formats[node.format]; !ok {
+					err = fmt.Errorf(`unsupported format %q`, node.format)
+				}
+			}
+		case `items`:
+			node.items, err = s.compile(kwPath, child)
+		case `minItems`:
+			node.minItems, err = compileCount(child)
+		case `maxItems`:
+			node.maxItems, err = compileCount(child)
+		case `uniqueItems`:
+			err = child.Bool(&node.uniqueItems)
+		case `properties`:
+			for name, elem := range child.Entries() {
+				sub, err := s.compile(kwPath+"/"+escapePointer(name), elem)
+				if err != nil {
+					return nil, err
+				}
+				node.properties = append(node.properties, property{name: name, schema: sub})
+			}
+		case `required`:
+			node.required = make(map[string]struct{})
+			for _, elem := range child.Elements() {
+				var name string
+				if err = elem.String(&name); err != nil {
+					break
+				}
+				node.required[name] = struct{}{}
+			}
+		case `additionalProperties`:
+			node.additional, err = s.compile(kwPath, child)
+		default:
+			if _, ok := annotations[kw]; ok {
+				continue
+			}
+			return nil, fmt.Errorf(`unsupported keyword %q at %s`, kw, path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf(`invalid keyword %q at %s: %w`, kw, path, err)
+		}
+	}
+
+	switch {
+	case node.minimum != nil && node.maximum != nil && *node.minimum > *node.maximum:
+		return nil, fmt.Errorf(`minimum is greater than maximum at %s`, path)
+	case node.maxLength >= 0 && node.minLength > node.maxLength:
+		return nil, fmt.Errorf(`minLength is greater than maxLength at %s`, path)
+	case node.maxItems >= 0 && node.minItems > node.maxItems:
+		return nil, fmt.Errorf(`minItems is greater than maxItems at %s`, path)
+	}
+	for name := range node.required {
+		if !node.hasProperty(name) && node.additional != nil && node.additional.never {
+			return nil, fmt.Errorf(`required property %q is not allowed at %s`, name, path)
+		}
+	}
+	return node, nil
+}
+
+// resolve returns the schema referenced by ref, which must be a JSON
+// pointer within the document, such as `#/$defs/foo`
+func (s *Schema) resolve(ref string) (*schemaNode, error) {
+	if node, ok := s.refs[ref]; ok {
+		return node, nil
+	}
+	if !strings.HasPrefix(ref, `#`) {
+		return nil, fmt.Errorf(`unsupported reference %q: only references within the document are supported`, ref)
+	}
+	pointer, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil, fmt.Errorf(`invalid reference %q: %w`, ref, err)
+	}
+
+	c := s.root
+	if pointer != "" {
+		if !strings.HasPrefix(pointer, `/`) {
+			return nil, fmt.Errorf(`invalid reference %q`, ref)
+		}
+		for _, token := range strings.Split(pointer[1:], `/`) {
+			token = strings.NewReplacer(`~1`, `/`, `~0`, `~`).Replace(token)
+			if i, err := strconv.Atoi(token); err == nil {
+				var l []interface{}
+				if c.Slice(&l) == nil {
+					c = c.Index(i)
+					continue
+				}
+			}
+			c = c.MapIndex(token)
+		}
+	}
+
+	// register the node before compiling it, so that recursive
+	// references resolve to it
+	node := &schemaNode{}
+	s.refs[ref] = node
+	compiled, err := s.compile(ref, c)
+	if err != nil {
+		delete(s.refs, ref)
+		return nil, fmt.Errorf(`failed to resolve reference %q: %w`, ref, err)
+	}
+	*node = *compiled
+	return node, nil
+}
+
+func escapePointer(s string) string {
+	return strings.NewReplacer(`~`, `~0`, `/`, `~1`).Replace(s)
+}
+
+var schemaTypes = map[string]struct{}{
+	`null`:    {},
+	`boolean`: {},
+	`integer`: {},
+	`number`:  {},
+	`string`:  {},
+	`array`:   {},
+	`object`:  {},
+}
+
+func compileTypes(c json.Context) ([]string, error) {
+	var types []string
+	var s string
+	if err := c.String(&s); err == nil {
+		types = append(types, s)
+	} else {
+		for _, elem := range c.Elements() {
+			if err := elem.String(&s); err != nil {
+				return nil, errors.New(`type must be a string or an array of strings`)
+			}
+			types = append(types, s)
+		}
+	}
+	if len(types) == 0 {
+		return nil, errors.New(`type must not be empty`)
+	}
+	for _, typ := range types {
+		if _, ok := schemaTypes[typ]; !ok {
+			return nil, fmt.Errorf(`unknown type %q`, typ)
+		}
+	}
+	return types, nil
+}
+
+func compileNumber(c json.Context) (*float64, error) {
+	buf, err := c.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	f, err := strconv.ParseFloat(string(buf), 64)
+	if err != nil {
+		return nil, fmt.Errorf(`expected a number, got %s`, buf)
+	}
+	return &f, nil
+}
+
+func compileCount(c json.Context) (int, error) {
+	f, err := compileNumber(c)
+	if err != nil {
+		return 0, err
+	}
+	if *f < 0 || *f != math.Trunc(*f) || *f > math.MaxInt32 {
+		return 0, fmt.Errorf(`expected a non-negative integer, got %v`, *f)
+	}
+	return int(*f), nil
+}
+
+// fromContext converts the value held by c into a generated value,
+// keeping the order of the fields of objects
+func fromContext(c json.Context) interface{} {
+	var m map[string]interface{}
+	if c.Map(&m) == nil {
+		o := &object{values: make(map[string]interface{}, len(m))}
+		for key, child := range c.Entries() {
+			o.keys = append(o.keys, key)
+			o.values[key] = fromContext(child)
+		}
+		return o
+	}
+	var l []interface{}
+	if c.Slice(&l) == nil {
+		values := make([]interface{}, 0, len(l))
+		for _, child := range c.Elements() {
+			values = append(values, fromContext(child))
+		}
+		return values
+	}
+
+	buf, err := c.MarshalJSON()
+	if err != nil {
+		return nil
+	}
+	var v interface{}
+	d := stdlib.NewDecoder(bytes.NewReader(buf))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil
+	}
+	return v
+}
+
+// Instance returns a new random instance of the schema s
+func (g *Generator) Instance(s *Schema) (json.Context, error) {
+	v, err := g.instance(s.node, 0)
+	if err != nil {
+		return nil, err
+	}
+	return build(v), nil
+}
+
+// InstanceBytes returns the compact encoding of a new random instance
+// of the schema s
+func (g *Generator) InstanceBytes(s *Schema) ([]byte, error) {
+	c, err := g.Instance(s)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := c.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to marshal generated instance: %w`, err)
+	}
+	return buf, nil
+}
+
+// instance generates a value for node at the given depth. Beyond the
+// maximum depth of the Generator, optional properties and elements are
+// omitted, so that recursive schemas terminate
+func (g *Generator) instance(node *schemaNode, depth int) (interface{}, error) {
+	if depth > maxSchemaDepth {
+		return nil, fmt.Errorf(`schema requires instances deeper than %d levels`, maxSchemaDepth)
+	}
+	if node.target != nil {
+		node = node.target
+	}
+	switch {
+	case node.never:
+		return nil, errors.New(`schema "false" has no valid instances`)
+	case len(node.values) > 0:
+		return node.values[g.rnd.IntN(len(node.values))], nil
+	case len(node.anyOf) > 0:
+		return g.instance(node.anyOf[g.rnd.IntN(len(node.anyOf))], depth)
+	}
+
+	types := node.types
+	if len(types) == 0 {
+		types = node.impliedTypes()
+	}
+	if len(types) == 0 {
+		if depth >= g.maxDepth {
+			return g.scalar(), nil
+		}
+		return g.value(depth), nil
+	}
+
+	switch typ := types[g.rnd.IntN(len(types))]; typ {
+	case `null`:
+		return nil, nil
+	case `boolean`:
+		return g.rnd.IntN(2) == 0, nil
+	case `integer`, `number`:
+		return g.schemaNumber(node, typ == `integer`)
+	case `string`:
+		return g.schemaString(node), nil
+	case `array`:
+		return g.schemaArray(node, depth)
+	}
+	return g.schemaObject(node, depth)
+}
+
+// impliedTypes returns the types implied by the keywords of node, for
+// schemas that do not specify any
+func (node *schemaNode) impliedTypes() []string {
+	switch {
+	case node.minimum != nil || node.maximum != nil || node.multipleOf > 0:
+		return []string{`number`}
+	case node.minLength > 0 || node.maxLength >= 0 || node.format != "":
+		return []string{`string`}
+	case node.items != nil || node.minItems > 0 || node.maxItems >= 0 || node.uniqueItems:
+		return []string{`array`}
+	case node.properties != nil || node.required != nil || node.additional != nil:
+		return []string{`object`}
+	}
+	return nil
+}
+
+func (g *Generator) schemaNumber(node *schemaNode, integer bool) (stdlib.Number, error) {
+	width := g.numbers.max - g.numbers.min
+	lo, hi := g.numbers.min, g.numbers.max
+	switch {
+	case node.minimum != nil && node.maximum != nil:
+		lo, hi = *node.minimum, *node.maximum
+	case node.minimum != nil:
+		lo, hi = *node.minimum, max(*node.minimum+width, g.numbers.max)
+	case node.maximum != nil:
+		lo, hi = min(*node.maximum-width, g.numbers.min), *node.maximum
+	}
+
+	step := node.multipleOf
+	if integer {
+		if step == 0 {
+			step = 1
+		} else if step != math.Trunc(step) {
+			// integers that are a multiple of a fraction p/q are
+			// multiples of p, which is not computed here
+			return "", fmt.Errorf(`multipleOf %v is not supported for integers`, step)
+		}
+	}
+
+	if step == 0 {
+		f := lo + g.rnd.Float64()*(hi-lo)
+		if (node.exclusiveMinimum && f <= lo) || (node.exclusiveMaximum && f >= hi) {
+			f = lo + (hi-lo)/2
+		}
+		if (node.exclusiveMinimum && f <= lo) || (node.exclusiveMaximum && f >= hi) {
+			return "", fmt.Errorf(`no number satisfies the range %v to %v`, lo, hi)
+		}
+		return stdlib.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+	}
+
+	kmin, kmax := math.Ceil(lo/step), math.Floor(hi/step)
+	if node.exclusiveMinimum && kmin*step <= lo {
+		kmin++
+	}
+	if node.exclusiveMaximum && kmax*step >= hi {
+		kmax--
+	}
+	if kmin > kmax {
+		return "", fmt.Errorf(`no multiple of %v satisfies the range %v to %v`, step, lo, hi)
+	}
+	k := math.Min(math.Floor(kmin+g.rnd.Float64()*(kmax-kmin+1)), kmax)
+	if integer {
+		return stdlib.Number(strconv.FormatFloat(k*step, 'f', 0, 64)), nil
+	}
+	return stdlib.Number(strconv.FormatFloat(k*step, 'g', -1, 64)), nil
+}
+
+func (g *Generator) schemaString(node *schemaNode) string {
+	switch node.format {
+	case `date-time`:
+		return g.time().Format(time.RFC3339)
+	case `date`:
+		return g.time().Format(time.DateOnly)
+	case `time`:
+		return g.time().Format(`15:04:05Z07:00`)
+	case `email`:
+		return g.string(g.keyAlphabet, 1+g.rnd.IntN(g.maxKeyLength)) + `@` + g.hostname()
+	case `hostname`:
+		return g.hostname()
+	case `ipv4`:
+		return fmt.Sprintf(`%d.%d.%d.%d`, g.rnd.IntN(256), g.rnd.IntN(256), g.rnd.IntN(256), g.rnd.IntN(256))
+	case `uri`:
+		return `https://` + g.hostname() + `/` + g.string([]rune(lowercase), g.rnd.IntN(maxStringLength+1))
+	case `uuid`:
+		b := make([]byte, 16)
+		for i := range b {
+			b[i] = byte(g.rnd.IntN(256))
+		}
+		b[6] = b[6]&0x0f | 0x40 // version 4
+		b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+		return fmt.Sprintf(`%x-%x-%x-%x-%x`, b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	}
+
+	maxLength := node.maxLength
+	if maxLength < 0 {
+		maxLength = max(node.minLength, maxStringLength)
+	}
+	return g.string(stringAlphabet, node.minLength+g.rnd.IntN(maxLength-node.minLength+1))
+}
+
+const lowercase = `abcdefghijklmnopqrstuvwxyz`
+
+func (g *Generator) hostname() string {
+	return g.string([]rune(lowercase), 1+g.rnd.IntN(maxStringLength)) + `.example`
+}
+
+// time returns a random time between 1970 and 2100, in UTC
+func (g *Generator) time() time.Time {
+	const end = 4102444800 // 2100-01-01T00:00:00Z
+	return time.Unix(g.rnd.Int64N(end), 0).UTC()
+}
+
+func (g *Generator) schemaArray(node *schemaNode, depth int) ([]interface{}, error) {
+	n := node.minItems
+	if depth < g.maxDepth {
+		maxItems := node.maxItems
+		if maxItems < 0 {
+			maxItems = max(node.minItems, g.maxSize)
+		}
+		n += g.rnd.IntN(maxItems - node.minItems + 1)
+	}
+
+	items := node.items
+	if items == nil {
+		items = &schemaNode{maxLength: -1, maxItems: -1}
+	}
+
+	l := make([]interface{}, 0, n)
+	seen := make(map[string]struct{}, n)
+	for len(l) < n {
+		var elem interface{}
+		for attempt := 0; ; attempt++ {
+			v, err := g.instance(items, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf(`failed to generate element %d: %w`, len(l), err)
+			}
+			if !node.uniqueItems {
+				elem = v
+				break
+			}
+			buf, err := build(v).MarshalJSON()
+			if err != nil {
+				return nil, fmt.Errorf(`failed to generate element %d: %w`, len(l), err)
+			}
+			if _, ok := seen[string(buf)]; !ok {
+				seen[string(buf)] = struct{}{}
+				elem = v
+				break
+			}
+			if attempt >= maxKeyAttempts {
+				if len(l) >= node.minItems {
+					return l, nil
+				}
+				return nil, fmt.Errorf(`failed to generate %d unique elements`, node.minItems)
+			}
+		}
+		l = append(l, elem)
+	}
+	return l, nil
+}
+
+func (g *Generator) schemaObject(node *schemaNode, depth int) (*object, error) {
+	o := &object{values: make(map[string]interface{})}
+	add := func(name string, schema *schemaNode) error {
+		v, err := g.instance(schema, depth+1)
+		if err != nil {
+			return fmt.Errorf(`failed to generate property %q: %w`, name, err)
+		}
+		o.keys = append(o.keys, name)
+		o.values[name] = v
+		return nil
+	}
+
+	for _, p := range node.properties {
+		_, required := node.required[p.name]
+		if !required && (depth >= g.maxDepth || g.rnd.IntN(2) == 0 || p.schema.isNever()) {
+			continue
+		}
+		if err := add(p.name, p.schema); err != nil {
+			return nil, err
+		}
+	}
+
+	// required properties without a schema of their own, which are
+	// added in a stable order
+	var missing []string
+	for name := range node.required {
+		if !node.hasProperty(name) {
+			missing = append(missing, name)
+		}
+	}
+	slices.Sort(missing)
+	for _, name := range missing {
+		additional := node.additional
+		if additional == nil {
+			additional = &schemaNode{maxLength: -1, maxItems: -1}
+		}
+		if err := add(name, additional); err != nil {
+			return nil, err
+		}
+	}
+
+	if node.additional != nil && !node.additional.never && depth < g.maxDepth {
+		for range g.rnd.IntN(g.maxSize/2 + 1) {
+			name := g.string(g.keyAlphabet, 1+g.rnd.IntN(g.maxKeyLength))
+			if _, ok := o.values[name]; ok || node.hasProperty(name) {
+				continue
+			}
+			if err := add(name, node.additional); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return o, nil
+}
+
+func (node *schemaNode) isNever() bool {
+	if node.target != nil {
+		return node.target.never
+	}
+	return node.never
+}
+
+func (node *schemaNode) hasProperty(name string) bool {
+	return slices.ContainsFunc(node.properties, func(p property) bool { return p.name == name })
+}
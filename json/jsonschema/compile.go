@@ -0,0 +1,481 @@
+package jsonschema
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lestrrat-go/json"
+)
+
+// node is a compiled schema. Boolean schemas are represented by nodes
+// with only never set, or nothing set at all
+type node struct {
+	location string // JSON pointer of the schema, such as `#/$defs/foo`
+	never    bool
+
+	ref    *node
+	refURI string
+
+	types  []string
+	enum   []interface{}
+	consts []interface{} // holds a single value if const was specified
+
+	multipleOf       *big.Rat
+	minimum          *big.Rat
+	maximum          *big.Rat
+	exclusiveMinimum *big.Rat
+	exclusiveMaximum *big.Rat
+
+	minLength int
+	maxLength int
+	pattern   *regexp.Regexp
+	format    string
+
+	allOf            []*node
+	anyOf            []*node
+	oneOf            []*node
+	not              *node
+	ifSchema         *node
+	thenSchema       *node
+	elseSchema       *node
+	dependentSchemas []dependent
+
+	prefixItems      []*node
+	items            *node
+	contains         *node
+	minContains      int
+	maxContains      int
+	minItems         int
+	maxItems         int
+	uniqueItems      bool
+	unevaluatedItems *node
+
+	properties            []property
+	patternProperties     []patternProperty
+	additionalProperties  *node
+	propertyNames         *node
+	unevaluatedProperties *node
+	required              []string
+	dependentRequired     []dependentRequired
+	minProperties         int
+	maxProperties         int
+}
+
+type property struct {
+	name   string
+	schema *node
+}
+
+type patternProperty struct {
+	pattern *regexp.Regexp
+	schema  *node
+}
+
+type dependent struct {
+	name   string
+	schema *node
+}
+
+type dependentRequired struct {
+	name     string
+	required []string
+}
+
+func newNode(location string) *node {
+	return &node{
+		location:      location,
+		minContains:   1,
+		maxContains:   -1,
+		maxLength:     -1,
+		maxItems:      -1,
+		maxProperties: -1,
+	}
+}
+
+// resource is a schema that can be referred to by its URI
+type resource struct {
+	c        json.Context
+	location string
+	base     *url.URL
+}
+
+type compiler struct {
+	root        json.Context
+	nodes       map[string]*node    // compiled schemas by location
+	resources   map[string]resource // schemas by the absolute URI of $id
+	anchors     map[string]resource // schemas by absolute URI of $anchor
+	pending     []*node             // schemas whose $ref has not been resolved
+	annotations bool
+}
+
+func newCompiler(root json.Context) *compiler {
+	return &compiler{
+		root:      root,
+		nodes:     make(map[string]*node),
+		resources: make(map[string]resource),
+		anchors:   make(map[string]resource),
+	}
+}
+
+// compileRoot compiles the whole document, then resolves the
+// references, which may refer to schemas declared anywhere in it
+func (comp *compiler) compileRoot() (*node, error) {
+	root, err := comp.compile(comp.root, `#`, &url.URL{})
+	if err != nil {
+		return nil, err
+	}
+
+	for len(comp.pending) > 0 {
+		n := comp.pending[0]
+		comp.pending = comp.pending[1:]
+		target, err := comp.resolve(n.refURI)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid keyword "$ref" at %s: %w`, n.location, err)
+		}
+		n.ref = target
+	}
+	return root, nil
+}
+
+// compile compiles the schema c, found at the JSON pointer location.
+// Relative URIs in the schema are resolved against base
+func (comp *compiler) compile(c json.Context, location string, base *url.URL) (*node, error) {
+	if n, ok := comp.nodes[location]; ok {
+		return n, nil
+	}
+
+	n := newNode(location)
+	comp.nodes[location] = n
+
+	var b bool
+	if err := c.Bool(&b); err == nil {
+		n.never = !b
+		return n, nil
+	}
+
+	var m map[string]interface{}
+	if err := c.Map(&m); err != nil {
+		return nil, fmt.Errorf(`schema at %s must be an object or a boolean`, location)
+	}
+
+	// $id changes the base URI of the schema, and must be handled
+	// before any other keyword
+	if idc := c.MapIndex(`$id`); idc.Err() == nil {
+		var id string
+		if err := idc.String(&id); err != nil {
+			return nil, fmt.Errorf(`invalid keyword "$id" at %s: %w`, location, err)
+		}
+		u, err := base.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid keyword "$id" at %s: %w`, location, err)
+		}
+		u.Fragment = ""
+		u.RawFragment = ""
+		base = u
+		comp.resources[u.String()] = resource{c: c, location: location, base: u}
+	} else if location == `#` {
+		comp.resources[``] = resource{c: c, location: location, base: base}
+	}
+
+	for kw, child := range c.Entries() {
+		kwPath := location + "/" + escapePointer(kw)
+		var err error
+		switch kw {
+		case `$anchor`, `$dynamicAnchor`:
+			var anchor string
+			if err = child.String(&anchor); err == nil {
+				u := *base
+				u.Fragment = anchor
+				comp.anchors[u.String()] = resource{c: c, location: location, base: base}
+			}
+		case `$ref`, `$dynamicRef`:
+			var ref string
+			if err = child.String(&ref); err == nil {
+				var u *url.URL
+				if u, err = base.Parse(ref); err == nil {
+					n.refURI = u.String()
+					comp.pending = append(comp.pending, n)
+				}
+			}
+		case `type`:
+			n.types, err = compileTypes(child)
+		case `enum`:
+			for _, elem := range child.Elements() {
+				v, err := normalize(elem)
+				if err != nil {
+					return nil, fmt.Errorf(`invalid keyword %q at %s: %w`, kw, location, err)
+				}
+				n.enum = append(n.enum, v)
+			}
+			if n.enum == nil {
+				err = errors.New(`enum must be a non-empty array`)
+			}
+		case `const`:
+			var v interface{}
+			if v, err = normalize(child); err == nil {
+				n.consts = []interface{}{v}
+			}
+		case `multipleOf`:
+			if n.multipleOf, err = compileNumber(child); err == nil && n.multipleOf.Sign() <= 0 {
+				err = errors.New(`multipleOf must be greater than 0`)
+			}
+		case `minimum`:
+			n.minimum, err = compileNumber(child)
+		case `maximum`:
+			n.maximum, err = compileNumber(child)
+		case `exclusiveMinimum`:
+			n.exclusiveMinimum, err = compileNumber(child)
+		case `exclusiveMaximum`:
+			n.exclusiveMaximum, err = compileNumber(child)
+		case `minLength`:
+			n.minLength, err = compileCount(child)
+		case `maxLength`:
+			n.maxLength, err = compileCount(child)
+		case `pattern`:
+			n.pattern, err = compilePattern(child)
+		case `format`:
+			err = child.String(&n.format)
+		case `allOf`, `anyOf`, `oneOf`, `prefixItems`:
+			var list []*node
+			for i, elem := range child.Elements() {
+				sub, err := comp.compile(elem, kwPath+"/"+strconv.Itoa(i), base)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, sub)
+			}
+			if list == nil {
+				err = fmt.Errorf(`%s must be a non-empty array`, kw)
+			}
+			switch kw {
+			case `allOf`:
+				n.allOf = list
+			case `anyOf`:
+				n.anyOf = list
+			case `oneOf`:
+				n.oneOf = list
+			case `prefixItems`:
+				n.prefixItems = list
+			}
+		case `not`:
+			n.not, err = comp.compile(child, kwPath, base)
+		case `if`:
+			n.ifSchema, err = comp.compile(child, kwPath, base)
+		case `then`:
+			n.thenSchema, err = comp.compile(child, kwPath, base)
+		case `else`:
+			n.elseSchema, err = comp.compile(child, kwPath, base)
+		case `dependentSchemas`:
+			for name, elem := range child.Entries() {
+				sub, err := comp.compile(elem, kwPath+"/"+escapePointer(name), base)
+				if err != nil {
+					return nil, err
+				}
+				n.dependentSchemas = append(n.dependentSchemas, dependent{name: name, schema: sub})
+			}
+		case `items`:
+			n.items, err = comp.compile(child, kwPath, base)
+		case `contains`:
+			n.contains, err = comp.compile(child, kwPath, base)
+		case `minContains`:
+			n.minContains, err = compileCount(child)
+		case `maxContains`:
+			n.maxContains, err = compileCount(child)
+		case `minItems`:
+			n.minItems, err = compileCount(child)
+		case `maxItems`:
+			n.maxItems, err = compileCount(child)
+		case `uniqueItems`:
+			err = child.Bool(&n.uniqueItems)
+		case `unevaluatedItems`:
+			n.unevaluatedItems, err = comp.compile(child, kwPath, base)
+			comp.annotations = true
+		case `properties`:
+			for name, elem := range child.Entries() {
+				sub, err := comp.compile(elem, kwPath+"/"+escapePointer(name), base)
+				if err != nil {
+					return nil, err
+				}
+				n.properties = append(n.properties, property{name: name, schema: sub})
+			}
+		case `patternProperties`:
+			for pattern, elem := range child.Entries() {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					return nil, fmt.Errorf(`invalid keyword %q at %s: unsupported pattern %q: %w`, kw, location, pattern, err)
+				}
+				sub, err := comp.compile(elem, kwPath+"/"+escapePointer(pattern), base)
+				if err != nil {
+					return nil, err
+				}
+				n.patternProperties = append(n.patternProperties, patternProperty{pattern: re, schema: sub})
+			}
+		case `additionalProperties`:
+			n.additionalProperties, err = comp.compile(child, kwPath, base)
+		case `propertyNames`:
+			n.propertyNames, err = comp.compile(child, kwPath, base)
+		case `unevaluatedProperties`:
+			n.unevaluatedProperties, err = comp.compile(child, kwPath, base)
+			comp.annotations = true
+		case `required`:
+			n.required, err = compileNames(child)
+		case `dependentRequired`:
+			for name, elem := range child.Entries() {
+				names, err := compileNames(elem)
+				if err != nil {
+					return nil, fmt.Errorf(`invalid keyword %q at %s: %w`, kw, location, err)
+				}
+				n.dependentRequired = append(n.dependentRequired, dependentRequired{name: name, required: names})
+			}
+		case `minProperties`:
+			n.minProperties, err = compileCount(child)
+		case `maxProperties`:
+			n.maxProperties, err = compileCount(child)
+		case `$defs`, `definitions`:
+			// compiled so that the identifiers declared in them are
+			// registered, even if they are not referred to by location
+			for name, elem := range child.Entries() {
+				if _, err := comp.compile(elem, kwPath+"/"+escapePointer(name), base); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf(`invalid keyword %q at %s: %w`, kw, location, err)
+		}
+	}
+	return n, nil
+}
+
+// resolve returns the schema referred to by the absolute URI ref. The
+// fragment of ref is either a JSON pointer, such as `#/$defs/foo`, or
+// the name of an anchor
+func (comp *compiler) resolve(ref string) (*node, error) {
+	if r, ok := comp.anchors[ref]; ok {
+		return comp.compile(r.c, r.location, r.base)
+	}
+
+	uri, fragment, _ := strings.Cut(ref, `#`)
+	r, ok := comp.resources[uri]
+	if !ok {
+		return nil, fmt.Errorf(`unsupported reference %q: only references within the document are supported`, ref)
+	}
+	pointer, err := url.PathUnescape(fragment)
+	if err != nil {
+		return nil, fmt.Errorf(`invalid reference %q: %w`, ref, err)
+	}
+	if pointer == "" {
+		return comp.compile(r.c, r.location, r.base)
+	}
+	if !strings.HasPrefix(pointer, `/`) {
+		return nil, fmt.Errorf(`unknown anchor in reference %q`, ref)
+	}
+
+	c := r.c
+	for _, token := range strings.Split(pointer[1:], `/`) {
+		token = strings.NewReplacer(`~1`, `/`, `~0`, `~`).Replace(token)
+		if i, err := strconv.Atoi(token); err == nil {
+			var l []interface{}
+			if c.Slice(&l) == nil {
+				c = c.Index(i)
+				continue
+			}
+		}
+		c = c.MapIndex(token)
+	}
+	if err := c.Err(); err != nil {
+		return nil, fmt.Errorf(`failed to resolve reference %q: %w`, ref, err)
+	}
+	return comp.compile(c, r.location+pointer, r.base)
+}
+
+func escapePointer(s string) string {
+	return strings.NewReplacer(`~`, `~0`, `/`, `~1`).Replace(s)
+}
+
+var schemaTypes = map[string]struct{}{
+	`null`:    {},
+	`boolean`: {},
+	`integer`: {},
+	`number`:  {},
+	`string`:  {},
+	`array`:   {},
+	`object`:  {},
+}
+
+func compileTypes(c json.Context) ([]string, error) {
+	var types []string
+	var s string
+	if err := c.String(&s); err == nil {
+		types = append(types, s)
+	} else {
+		for _, elem := range c.Elements() {
+			if err := elem.String(&s); err != nil {
+				return nil, errors.New(`type must be a string or an array of strings`)
+			}
+			types = append(types, s)
+		}
+	}
+	if len(types) == 0 {
+		return nil, errors.New(`type must not be empty`)
+	}
+	for _, typ := range types {
+		if _, ok := schemaTypes[typ]; !ok {
+			return nil, fmt.Errorf(`unknown type %q`, typ)
+		}
+	}
+	return types, nil
+}
+
+// compileNumber returns the number held by c, without losing precision
+func compileNumber(c json.Context) (*big.Rat, error) {
+	buf, err := c.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	r, ok := new(big.Rat).SetString(string(buf))
+	if !ok {
+		return nil, fmt.Errorf(`expected a number, got %s`, buf)
+	}
+	return r, nil
+}
+
+func compileCount(c json.Context) (int, error) {
+	r, err := compileNumber(c)
+	if err != nil {
+		return 0, err
+	}
+	if r.Sign() < 0 || !r.IsInt() || !r.Num().IsInt64() || r.Num().Int64() > int64(^uint32(0)>>1) {
+		return 0, fmt.Errorf(`expected a non-negative integer, got %s`, r.RatString())
+	}
+	return int(r.Num().Int64()), nil
+}
+
+func compilePattern(c json.Context) (*regexp.Regexp, error) {
+	var pattern string
+	if err := c.String(&pattern); err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf(`unsupported pattern %q: %w`, pattern, err)
+	}
+	return re, nil
+}
+
+func compileNames(c json.Context) ([]string, error) {
+	names := []string{}
+	for _, elem := range c.Elements() {
+		var name string
+		if err := elem.String(&name); err != nil {
+			return nil, errors.New(`expected an array of strings`)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
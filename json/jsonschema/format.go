@@ -0,0 +1,84 @@
+package jsonschema
+
+import (
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	hostnameLabel   = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+	uuidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	durationPattern = regexp.MustCompile(`^P(?:\d+W|(?:\d+Y)?(?:\d+M)?(?:\d+D)?(?:T(?:\d+H)?(?:\d+M)?(?:\d+S)?)?)$`)
+)
+
+// formatCheckers holds the formats that are checked if
+// WithFormatAssertion is specified
+var formatCheckers = map[string]func(string) bool{
+	`date-time`: func(s string) bool {
+		_, err := time.Parse(time.RFC3339Nano, strings.ToUpper(s))
+		return err == nil
+	},
+	`date`: func(s string) bool {
+		_, err := time.Parse(time.DateOnly, s)
+		return err == nil
+	},
+	`time`: func(s string) bool {
+		_, err := time.Parse(`15:04:05.999999999Z07:00`, strings.ToUpper(s))
+		return err == nil
+	},
+	`duration`: func(s string) bool {
+		return durationPattern.MatchString(s) && !strings.HasSuffix(s, `T`) && s != `P`
+	},
+	`email`: func(s string) bool {
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	},
+	`hostname`: func(s string) bool {
+		s = strings.TrimSuffix(s, `.`)
+		if s == "" || len(s) > 253 {
+			return false
+		}
+		for _, label := range strings.Split(s, `.`) {
+			if !hostnameLabel.MatchString(label) {
+				return false
+			}
+		}
+		return true
+	},
+	`ipv4`: func(s string) bool {
+		addr, err := netip.ParseAddr(s)
+		return err == nil && addr.Is4()
+	},
+	`ipv6`: func(s string) bool {
+		addr, err := netip.ParseAddr(s)
+		return err == nil && addr.Is6() && addr.Zone() == ""
+	},
+	`uri`: func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.IsAbs()
+	},
+	`uri-reference`: func(s string) bool {
+		_, err := url.Parse(s)
+		return err == nil
+	},
+	`uuid`: uuidPattern.MatchString,
+	`json-pointer`: func(s string) bool {
+		if s != "" && !strings.HasPrefix(s, `/`) {
+			return false
+		}
+		for i := 0; i < len(s); i++ {
+			if s[i] == '~' && (i+1 >= len(s) || (s[i+1] != '0' && s[i+1] != '1')) {
+				return false
+			}
+		}
+		return true
+	},
+	`regex`: func(s string) bool {
+		_, err := regexp.Compile(s)
+		return err == nil
+	},
+}
@@ -0,0 +1,194 @@
+package jsonschema
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/lestrrat-go/json"
+)
+
+// maxEnumValues is the maximum number of distinct strings observed at
+// a location for them to be listed as an enum
+const maxEnumValues = 10
+
+// schemaTypeOrder is the order in which types are listed by InferSchema
+var schemaTypeOrder = []string{`null`, `boolean`, `integer`, `number`, `string`, `array`, `object`}
+
+// shape accumulates the values observed at a location of the samples
+type shape struct {
+	types      map[string]struct{}
+	strings    map[string]struct{} // nil once more than maxEnumValues are observed
+	nstrings   int
+	objects    int
+	properties map[string]*shape
+	present    map[string]int // the number of objects each property was found in
+	items      *shape
+}
+
+func newShape() *shape {
+	return &shape{
+		types:   make(map[string]struct{}),
+		strings: make(map[string]struct{}),
+	}
+}
+
+// InferSchema returns a JSON Schema describing the documents held by
+// docs, which can be compiled by Compile. The schema lists the types
+// observed at each location, and the properties that were present in
+// every object found at a location are required. Strings are listed
+// as an enum if no more than 10 distinct values were observed at a
+// location, and at least one of them was observed more than once.
+// Numbers are described as integers if all the observed values were
+// integers.
+//
+// Every document in docs is valid against the resulting schema
+func InferSchema(docs ...json.Context) (json.Context, error) {
+	if len(docs) == 0 {
+		return nil, errors.New(`at least one document is required`)
+	}
+
+	root := newShape()
+	for i, doc := range docs {
+		v, err := normalize(doc)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to read document %d: %w`, i, err)
+		}
+		if err := root.observe(v); err != nil {
+			return nil, fmt.Errorf(`failed to read document %d: %w`, i, err)
+		}
+	}
+
+	c := json.New(map[string]interface{}{}, json.WithPreserveKeyOrder(true))
+	c.SetMapIndex(`$schema`, `https://json-schema.org/draft/2020-12/schema`)
+	root.describe(c)
+	return c, nil
+}
+
+// observe records the value v
+func (s *shape) observe(v interface{}) error {
+	kind := kindOf(v)
+	if kind == "" {
+		var err error
+		if v, err = plain(v); err != nil {
+			return err
+		}
+		kind = kindOf(v)
+	}
+
+	switch kind {
+	case `number`:
+		if x, ok := toRat(v); ok && x.IsInt() {
+			kind = `integer`
+		}
+	case `string`:
+		s.nstrings++
+		if s.strings != nil {
+			s.strings[v.(string)] = struct{}{}
+			if len(s.strings) > maxEnumValues {
+				s.strings = nil
+			}
+		}
+	case `array`:
+		if s.items == nil {
+			s.items = newShape()
+		}
+		for _, elem := range v.([]interface{}) {
+			if err := s.items.observe(elem); err != nil {
+				return err
+			}
+		}
+	case `object`:
+		if s.properties == nil {
+			s.properties = make(map[string]*shape)
+			s.present = make(map[string]int)
+		}
+		s.objects++
+		for name, value := range v.(map[string]interface{}) {
+			prop, ok := s.properties[name]
+			if !ok {
+				prop = newShape()
+				s.properties[name] = prop
+			}
+			s.present[name]++
+			if err := prop.observe(value); err != nil {
+				return err
+			}
+		}
+	}
+	s.types[kind] = struct{}{}
+	return nil
+}
+
+// describe adds the keywords describing the observed values to c,
+// which holds an empty object
+func (s *shape) describe(c json.Context) {
+	if _, ok := s.types[`number`]; ok {
+		// integers are numbers too
+		delete(s.types, `integer`)
+	}
+	var types []interface{}
+	for _, typ := range schemaTypeOrder {
+		if _, ok := s.types[typ]; ok {
+			types = append(types, typ)
+		}
+	}
+	switch len(types) {
+	case 0:
+		// only found in empty arrays, so anything goes
+		return
+	case 1:
+		c.SetMapIndex(`type`, types[0])
+	default:
+		c.SetMapIndex(`type`, types)
+	}
+
+	if s.strings != nil && len(s.strings) < s.nstrings {
+		values := make([]string, 0, len(s.strings))
+		for value := range s.strings {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		enum := make([]interface{}, 0, len(values))
+		for _, value := range values {
+			enum = append(enum, value)
+		}
+		if len(types) > 1 {
+			// enum applies to values of every type, so it is
+			// restricted to strings
+			c.SetMapIndex(`anyOf`, []interface{}{
+				map[string]interface{}{`not`: map[string]interface{}{`type`: `string`}},
+				map[string]interface{}{`enum`: enum},
+			})
+		} else {
+			c.SetMapIndex(`enum`, enum)
+		}
+	}
+
+	if s.items != nil && len(s.items.types) > 0 {
+		c.SetMapIndex(`items`, map[string]interface{}{})
+		s.items.describe(c.MapIndex(`items`))
+	}
+
+	if s.properties != nil {
+		names := make([]string, 0, len(s.properties))
+		for name := range s.properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		c.SetMapIndex(`properties`, map[string]interface{}{})
+		props := c.MapIndex(`properties`)
+		var required []interface{}
+		for _, name := range names {
+			props.SetMapIndex(name, map[string]interface{}{})
+			s.properties[name].describe(props.MapIndex(name))
+			if s.present[name] == s.objects {
+				required = append(required, name)
+			}
+		}
+		if required != nil {
+			c.SetMapIndex(`required`, required)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package jsonschema validates JSON documents against JSON Schemas.
+//
+// The core, applicator, unevaluated, and validation vocabularies of
+// JSON Schema draft 2020-12 are supported. Formats are annotations by
+// default, and are only checked if WithFormatAssertion is specified.
+// Violations are reported as a list of ValidationErrors, each holding
+// the path of the offending value in the same notation as
+// json.Context.Walk (such as `$.items[2].name`), and the location of
+// the keyword that it violates in the schema (such as
+// `#/properties/items/items/required`).
+//
+// InferSchema creates a schema from sample documents, which can be used
+// as a starting point for documenting their structure.
+//
+// The following features are not supported:
+//
+//   - references to other documents: $ref must refer to the schema
+//     itself, or to a subschema identified by $id or $anchor
+//   - dynamic scopes: $dynamicRef is resolved in the same manner as
+//     $ref, using the anchors declared by $dynamicAnchor
+//   - regular expressions that are not supported by the regexp
+//     package, such as those using lookarounds, which are rejected
+//     when the schema is compiled
+package jsonschema
+
+import (
+	"fmt"
+
+	"github.com/lestrrat-go/json"
+)
+
+// ValidationError describes a value that violates a keyword of the
+// schema
+type ValidationError struct {
+	// InstanceLocation is the path of the value, such as `$.items[2]`
+	InstanceLocation string
+	// KeywordLocation is the location of the keyword in the schema,
+	// as a JSON pointer such as `#/properties/items/minItems`. Keywords
+	// reached via $ref are located in the subschema that they belong to
+	KeywordLocation string
+	// Message describes the violation
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf(`%s: %s (%s)`, e.InstanceLocation, e.Message, e.KeywordLocation)
+}
+
+// Schema is a compiled JSON Schema, which can be used by multiple
+// goroutines simultaneously
+type Schema struct {
+	root        *node
+	formats     bool
+	annotations bool // true if the schema uses unevaluatedItems or unevaluatedProperties
+}
+
+// Compile compiles the JSON Schema held by c
+func Compile(c json.Context, options ...Option) (*Schema, error) {
+	s := &Schema{}
+	for _, option := range options {
+		switch option.Name() {
+		case optKeyFormatAssertion:
+			s.formats = option.Value().(bool)
+		}
+	}
+
+	comp := newCompiler(c)
+	root, err := comp.compileRoot()
+	if err != nil {
+		return nil, err
+	}
+	s.root = root
+	s.annotations = comp.annotations
+	return s, nil
+}
+
+// Parse parses and compiles the JSON Schema in data
+func Parse(data []byte, options ...Option) (*Schema, error) {
+	c, err := json.Parse(data, json.WithPreserveKeyOrder(true), json.WithUseNumber(true))
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse schema: %w`, err)
+	}
+	return Compile(c, options...)
+}
+
+// Validate validates the document held by c against the schema, and
+// returns the violations that were found, or nil if the document is
+// valid. An error is returned if the document cannot be read, such as
+// when c is an invalid Context
+func (s *Schema) Validate(c json.Context) ([]ValidationError, error) {
+	v, err := normalize(c)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read document: %w`, err)
+	}
+
+	st := state{schema: s}
+	r := st.validate(s.root, &instancePath{}, v, 0)
+	if st.err != nil {
+		return nil, st.err
+	}
+	return r.errs, nil
+}
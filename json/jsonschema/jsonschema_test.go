@@ -0,0 +1,364 @@
+package jsonschema_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lestrrat-go/json"
+	"github.com/lestrrat-go/json/jsonschema"
+	"github.com/stretchr/testify/assert"
+)
+
+// locations returns the instance and keyword locations of errs, in the
+// form `$.a[0] #/properties/a/items/type`
+func locations(errs []jsonschema.ValidationError) []string {
+	var list []string
+	for _, err := range errs {
+		list = append(list, err.InstanceLocation+" "+err.KeywordLocation)
+	}
+	return list
+}
+
+func TestValidate(t *testing.T) {
+	testcases := []struct {
+		Name     string
+		Schema   string
+		Document string
+		Options  []jsonschema.Option
+		Expected []string
+	}{
+		{
+			Name:     "type",
+			Schema:   `{"type": "object", "properties": {"name": {"type": "string"}, "age": {"type": "integer"}}}`,
+			Document: `{"name": 1, "age": 1.0}`,
+			Expected: []string{`$.name #/properties/name/type`},
+		},
+		{
+			Name:     "integer with a fraction",
+			Schema:   `{"type": ["integer", "null"]}`,
+			Document: `1.5`,
+			Expected: []string{`$ #/type`},
+		},
+		{
+			Name:     "boolean schemas",
+			Schema:   `{"properties": {"a": true, "b": false}}`,
+			Document: `{"a": 1, "b": 2}`,
+			Expected: []string{`$.b #/properties/b`},
+		},
+		{
+			Name:     "enum and const",
+			Schema:   `{"properties": {"a": {"enum": [1, "x", {"k": [true]}]}, "b": {"const": 10}}}`,
+			Document: `{"a": {"k": [false]}, "b": 10.0}`,
+			Expected: []string{`$.a #/properties/a/enum`},
+		},
+		{
+			Name:     "numbers",
+			Schema:   `{"items": {"minimum": 0, "exclusiveMaximum": 10, "multipleOf": 0.5}}`,
+			Document: `[0, 9.5, -1, 10, 0.3]`,
+			Expected: []string{`$[2] #/items/minimum`, `$[3] #/items/exclusiveMaximum`, `$[4] #/items/multipleOf`},
+		},
+		{
+			Name:     "large integers",
+			Schema:   `{"maximum": 9007199254740992}`,
+			Document: `9007199254740993`,
+			Expected: []string{`$ #/maximum`},
+		},
+		{
+			Name:     "strings",
+			Schema:   `{"items": {"minLength": 2, "maxLength": 3, "pattern": "^[a-zé]+$"}}`,
+			Document: `["été", "a", "abcd", "AB"]`,
+			Expected: []string{`$[1] #/items/minLength`, `$[2] #/items/maxLength`, `$[3] #/items/pattern`},
+		},
+		{
+			Name:     "arrays",
+			Schema:   `{"prefixItems": [{"type": "string"}], "items": {"type": "number"}, "minItems": 2, "uniqueItems": true}`,
+			Document: `["a", 1, "b", 1]`,
+			Expected: []string{`$ #/uniqueItems`, `$[2] #/items/type`},
+		},
+		{
+			Name:     "items false",
+			Schema:   `{"prefixItems": [true], "items": false}`,
+			Document: `[1, 2, 3]`,
+			Expected: []string{`$[1] #/items`},
+		},
+		{
+			Name:     "contains",
+			Schema:   `{"contains": {"type": "string"}, "maxContains": 1}`,
+			Document: `["a", "b", 1]`,
+			Expected: []string{`$ #/maxContains`},
+		},
+		{
+			Name:     "objects",
+			Schema:   `{"required": ["id", "name"], "properties": {"id": true}, "patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": false, "maxProperties": 2}`,
+			Document: `{"id": 1, "x-a": 1, "other": 1}`,
+			Expected: []string{`$ #/maxProperties`, `$ #/required`, `$.other #/additionalProperties`, `$["x-a"] #/patternProperties/^x-/type`},
+		},
+		{
+			Name:     "propertyNames and dependencies",
+			Schema:   `{"propertyNames": {"maxLength": 3}, "dependentRequired": {"a": ["b"]}, "dependentSchemas": {"b": {"required": ["c"]}}}`,
+			Document: `{"a": 1, "long": 1}`,
+			Expected: []string{`$ #/dependentRequired/a`, `$.long #/propertyNames/maxLength`},
+		},
+		{
+			Name:     "combinators",
+			Schema:   `{"properties": {"a": {"anyOf": [{"type": "string"}, {"type": "null"}]}, "b": {"oneOf": [{"minimum": 0}, {"maximum": 10}]}, "c": {"not": {"type": "number"}}, "d": {"allOf": [{"type": "number"}, {"minimum": 5}]}}}`,
+			Document: `{"a": 1, "b": 5, "c": 1, "d": 1}`,
+			Expected: []string{`$.a #/properties/a/anyOf`, `$.b #/properties/b/oneOf`, `$.c #/properties/c/not`, `$.d #/properties/d/allOf/1/minimum`},
+		},
+		{
+			Name:     "conditionals",
+			Schema:   `{"items": {"if": {"properties": {"kind": {"const": "a"}}}, "then": {"required": ["a"]}, "else": {"required": ["b"]}}}`,
+			Document: `[{"kind": "a"}, {"kind": "b"}, {"kind": "a", "a": 1}]`,
+			Expected: []string{`$[0] #/items/then/required`, `$[1] #/items/else/required`},
+		},
+		{
+			Name:     "references",
+			Schema:   `{"$defs": {"positive": {"exclusiveMinimum": 0}, "node": {"properties": {"value": {"$ref": "#/$defs/positive"}, "next": {"$ref": "#/$defs/node"}}}}, "$ref": "#/$defs/node"}`,
+			Document: `{"value": 1, "next": {"value": 2, "next": {"value": 0}}}`,
+			Expected: []string{`$.next.next.value #/$defs/positive/exclusiveMinimum`},
+		},
+		{
+			Name:     "identifiers and anchors",
+			Schema:   `{"$id": "https://example.com/root.json", "properties": {"a": {"$ref": "item.json"}, "b": {"$ref": "#name"}}, "$defs": {"item": {"$id": "item.json", "type": "integer"}, "name": {"$anchor": "name", "type": "string"}}}`,
+			Document: `{"a": "x", "b": 1}`,
+			Expected: []string{`$.a #/$defs/item/type`, `$.b #/$defs/name/type`},
+		},
+		{
+			Name:     "unevaluatedProperties",
+			Schema:   `{"allOf": [{"properties": {"a": true}}], "anyOf": [{"properties": {"b": true}}, {"required": ["c"]}], "unevaluatedProperties": false}`,
+			Document: `{"a": 1, "b": 2, "c": 3}`,
+			Expected: []string{`$.c #/unevaluatedProperties`},
+		},
+		{
+			Name:     "unevaluatedItems",
+			Schema:   `{"prefixItems": [true], "contains": {"type": "string"}, "unevaluatedItems": {"type": "null"}}`,
+			Document: `[1, "a", null, 2]`,
+			Expected: []string{`$[3] #/unevaluatedItems/type`},
+		},
+		{
+			Name:     "formats are annotations",
+			Schema:   `{"format": "email"}`,
+			Document: `"not an address"`,
+		},
+		{
+			Name:     "format assertion",
+			Schema:   `{"prefixItems": [{"format": "email"}, {"format": "date-time"}, {"format": "ipv4"}, {"format": "uuid"}, {"format": "unknown"}]}`,
+			Document: `["someone@example.com", "2024-01-02T03:04:05Z", "256.0.0.1", "x", "x"]`,
+			Options:  []jsonschema.Option{jsonschema.WithFormatAssertion(true)},
+			Expected: []string{`$[2] #/prefixItems/2/format`, `$[3] #/prefixItems/3/format`},
+		},
+		{
+			Name:     "paths",
+			Schema:   `{"additionalProperties": {"items": {"type": "string"}}}`,
+			Document: `{"a b": [1]}`,
+			Expected: []string{`$["a b"][0] #/additionalProperties/items/type`},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			s, err := jsonschema.Parse([]byte(tc.Schema), tc.Options...)
+			if !assert.NoError(t, err, `jsonschema.Parse should succeed`) {
+				return
+			}
+			doc, err := json.Parse([]byte(tc.Document))
+			if !assert.NoError(t, err, `json.Parse should succeed`) {
+				return
+			}
+			errs, err := s.Validate(doc)
+			if !assert.NoError(t, err, `Validate should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Expected, locations(errs), `violations should match`) {
+				return
+			}
+		})
+	}
+}
+
+func TestValidateContexts(t *testing.T) {
+	s, err := jsonschema.Parse([]byte(`{"type": "object", "properties": {"count": {"type": "integer", "maximum": 10}, "tags": {"items": {"type": "string"}}}}`))
+	if !assert.NoError(t, err, `jsonschema.Parse should succeed`) {
+		return
+	}
+
+	t.Run("go values", func(t *testing.T) {
+		type document struct {
+			Count int      `json:"count"`
+			Tags  []string `json:"tags"`
+		}
+		errs, err := s.Validate(json.New(document{Count: 11, Tags: []string{"a"}}))
+		if !assert.NoError(t, err, `Validate should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []string{`$.count #/properties/count/maximum`}, locations(errs), `violations should match`) {
+			return
+		}
+	})
+	t.Run("parse options", func(t *testing.T) {
+		for _, options := range [][]json.ParseOption{
+			{json.WithUseNumber(false)},
+			{json.WithLazy(true)},
+		} {
+			doc, err := json.Parse([]byte(`{"count": 2.5, "tags": ["a", {"b": 1}]}`), options...)
+			if !assert.NoError(t, err, `json.Parse should succeed`) {
+				return
+			}
+			errs, err := s.Validate(doc)
+			if !assert.NoError(t, err, `Validate should succeed`) {
+				return
+			}
+			if !assert.Equal(t, []string{`$.count #/properties/count/type`, `$.tags[1] #/properties/tags/items/type`}, locations(errs), `violations should match`) {
+				return
+			}
+		}
+	})
+	t.Run("subtree", func(t *testing.T) {
+		doc, err := json.Parse([]byte(`{"data": {"count": "1"}}`))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+		errs, err := s.Validate(doc.MapIndex("data"))
+		if !assert.NoError(t, err, `Validate should succeed`) {
+			return
+		}
+		if !assert.Len(t, errs, 1, `there should be one violation`) {
+			return
+		}
+		if !assert.Equal(t, `$.count: expected integer, found string (#/properties/count/type)`, errs[0].Error(), `message should match`) {
+			return
+		}
+	})
+	t.Run("invalid context", func(t *testing.T) {
+		doc, err := json.Parse([]byte(`{}`))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+		_, err = s.Validate(doc.MapIndex("missing"))
+		if !assert.True(t, errors.Is(err, json.ErrKeyNotFound), `Validate should fail with ErrKeyNotFound`) {
+			return
+		}
+	})
+}
+
+func TestCompile(t *testing.T) {
+	testcases := []struct {
+		Name   string
+		Schema string
+		Error  string
+	}{
+		{
+			Name:   "invalid schema",
+			Schema: `{"properties": {"a": 1}}`,
+			Error:  `schema at #/properties/a must be an object or a boolean`,
+		},
+		{
+			Name:   "unknown type",
+			Schema: `{"type": "int"}`,
+			Error:  `invalid keyword "type" at #: unknown type "int"`,
+		},
+		{
+			Name:   "unsupported pattern",
+			Schema: `{"pattern": "(?=a)"}`,
+			Error:  `invalid keyword "pattern" at #: unsupported pattern`,
+		},
+		{
+			Name:   "remote reference",
+			Schema: `{"$ref": "https://example.com/schema.json"}`,
+			Error:  `invalid keyword "$ref" at #: unsupported reference`,
+		},
+		{
+			Name:   "missing definition",
+			Schema: `{"$ref": "#/$defs/missing"}`,
+			Error:  `invalid keyword "$ref" at #: failed to resolve reference`,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := jsonschema.Parse([]byte(tc.Schema))
+			if !assert.Error(t, err, `jsonschema.Parse should fail`) {
+				return
+			}
+			if !assert.Contains(t, err.Error(), tc.Error, `error should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("recursion", func(t *testing.T) {
+		s, err := jsonschema.Parse([]byte(`{"$ref": "#"}`))
+		if !assert.NoError(t, err, `jsonschema.Parse should succeed`) {
+			return
+		}
+		errs, err := s.Validate(json.New(1))
+		if !assert.NoError(t, err, `Validate should succeed`) {
+			return
+		}
+		if !assert.NotEmpty(t, errs, `looping references should be reported`) {
+			return
+		}
+	})
+}
+
+func TestInferSchema(t *testing.T) {
+	samples := []string{
+		`{"id": 1, "status": "active", "tags": ["a"], "owner": {"name": "x"}, "score": 1}`,
+		`{"id": 2, "status": "inactive", "tags": [], "owner": null, "score": 2.5}`,
+		`{"id": 3, "status": "active", "tags": ["b", 1], "note": "first"}`,
+	}
+	var docs []json.Context
+	for _, sample := range samples {
+		doc, err := json.Parse([]byte(sample))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+		docs = append(docs, doc)
+	}
+
+	c, err := jsonschema.InferSchema(docs...)
+	if !assert.NoError(t, err, `InferSchema should succeed`) {
+		return
+	}
+	buf, err := c.MarshalJSON()
+	if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+		return
+	}
+	expected := `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{` +
+		`"id":{"type":"integer"},` +
+		`"note":{"type":"string"},` +
+		`"owner":{"type":["null","object"],"properties":{"name":{"type":"string"}},"required":["name"]},` +
+		`"score":{"type":"number"},` +
+		`"status":{"type":"string","enum":["active","inactive"]},` +
+		`"tags":{"type":"array","items":{"type":["integer","string"]}}},` +
+		`"required":["id","status","tags"]}`
+	if !assert.JSONEq(t, expected, string(buf), `schema should match`) {
+		return
+	}
+	if !assert.Equal(t, expected, string(buf), `keywords should be ordered`) {
+		return
+	}
+
+	s, err := jsonschema.Compile(c)
+	if !assert.NoError(t, err, `Compile should succeed`) {
+		return
+	}
+	for i, doc := range docs {
+		errs, err := s.Validate(doc)
+		if !assert.NoError(t, err, `Validate should succeed`) {
+			return
+		}
+		if !assert.Empty(t, errs, `sample %d should be valid`, i) {
+			return
+		}
+	}
+
+	t.Run("no documents", func(t *testing.T) {
+		_, err := jsonschema.InferSchema()
+		if !assert.Error(t, err, `InferSchema should fail`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package jsonschema
+
+const (
+	optKeyFormatAssertion = `optkey-format-assertion`
+)
+
+// Option configures the compilation of a Schema
+type Option interface {
+	Name() string
+	Value() interface{}
+}
+
+type option struct {
+	name  string
+	value interface{}
+}
+
+func (o option) Name() string {
+	return o.name
+}
+
+func (o option) Value() interface{} {
+	return o.value
+}
+
+// WithFormatAssertion specifies whether the format keyword is checked,
+// instead of being treated as an annotation. The date-time, date, time,
+// duration, email, hostname, ipv4, ipv6, uri, uri-reference, uuid,
+// json-pointer, and regex formats are checked, while other formats are
+// accepted as is
+func WithFormatAssertion(b bool) Option {
+	return &option{name: optKeyFormatAssertion, value: b}
+}
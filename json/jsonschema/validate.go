@@ -0,0 +1,667 @@
+package jsonschema
+
+import (
+	stdlib "encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lestrrat-go/json"
+)
+
+// maxDepth limits the number of schemas that are applied to a single
+// value, which guards against references that loop without descending
+// into the document, such as `{"$ref": "#"}`
+const maxDepth = 512
+
+// instancePath is the path of a value in the document. It is only
+// formatted when a violation is reported
+type instancePath struct {
+	parent *instancePath
+	key    string
+	index  int // -1 for object fields
+}
+
+func (p *instancePath) field(key string) instancePath {
+	return instancePath{parent: p, key: key, index: -1}
+}
+
+func (p *instancePath) element(i int) instancePath {
+	return instancePath{parent: p, index: i}
+}
+
+func (p *instancePath) String() string {
+	if p.parent == nil {
+		return `$`
+	}
+	if p.index >= 0 {
+		return indexPath(p.parent.String(), p.index)
+	}
+	return keyPath(p.parent.String(), p.key)
+}
+
+// result is the outcome of applying a schema to a value. Besides the
+// violations, it records the fields and elements of the value that
+// were evaluated, which are needed by unevaluatedProperties and
+// unevaluatedItems
+type result struct {
+	errs      []ValidationError
+	props     map[string]struct{}
+	items     int // the number of leading elements that were evaluated
+	allItems  bool
+	contained map[int]struct{}
+}
+
+func (r *result) fail(path *instancePath, location, format string, args ...interface{}) {
+	r.errs = append(r.errs, ValidationError{
+		InstanceLocation: path.String(),
+		KeywordLocation:  location,
+		Message:          fmt.Sprintf(format, args...),
+	})
+}
+
+// merge adds the outcome of applying a subschema to the same value.
+// Annotations are discarded if the subschema failed
+func (r *result) merge(sub result) {
+	r.errs = append(r.errs, sub.errs...)
+	if len(sub.errs) == 0 {
+		r.annotate(sub)
+	}
+}
+
+func (r *result) annotate(sub result) {
+	for name := range sub.props {
+		r.evaluated(name)
+	}
+	if sub.items > r.items {
+		r.items = sub.items
+	}
+	r.allItems = r.allItems || sub.allItems
+	for i := range sub.contained {
+		if r.contained == nil {
+			r.contained = make(map[int]struct{})
+		}
+		r.contained[i] = struct{}{}
+	}
+}
+
+func (r *result) evaluated(name string) {
+	if r.props == nil {
+		r.props = make(map[string]struct{})
+	}
+	r.props[name] = struct{}{}
+}
+
+type state struct {
+	schema *Schema
+	err    error // set if a value in the document could not be read
+}
+
+func (st *state) validate(n *node, path *instancePath, v interface{}, depth int) result {
+	var r result
+	if depth > maxDepth {
+		r.fail(path, n.location, `too many schemas were applied to the value`)
+		return r
+	}
+	if n.never {
+		r.fail(path, n.location, `no value is allowed`)
+		return r
+	}
+
+	kind := kindOf(v)
+	if kind == "" {
+		var err error
+		if v, err = plain(v); err != nil {
+			if st.err == nil {
+				st.err = fmt.Errorf(`failed to read value at %s: %w`, path, err)
+			}
+			return r
+		}
+		kind = kindOf(v)
+	}
+
+	if n.ref != nil {
+		r.merge(st.validate(n.ref, path, v, depth+1))
+	}
+
+	if n.types != nil && !hasType(n.types, kind, v) {
+		r.fail(path, n.location+`/type`, `expected %s, found %s`, strings.Join(n.types, ` or `), kind)
+	}
+	if n.enum != nil && !contains(n.enum, v) {
+		r.fail(path, n.location+`/enum`, `value is not one of the allowed values`)
+	}
+	if n.consts != nil && !equal(n.consts[0], v) {
+		r.fail(path, n.location+`/const`, `value does not match the constant value`)
+	}
+
+	switch kind {
+	case `number`:
+		st.validateNumber(n, path, v, &r)
+	case `string`:
+		st.validateString(n, path, v.(string), &r)
+	case `array`:
+		st.validateArray(n, path, v.([]interface{}), depth, &r)
+	case `object`:
+		st.validateObject(n, path, v.(map[string]interface{}), depth, &r)
+	}
+
+	for _, sub := range n.allOf {
+		r.merge(st.validate(sub, path, v, depth+1))
+	}
+	if n.anyOf != nil {
+		var valid bool
+		for _, sub := range n.anyOf {
+			if sr := st.validate(sub, path, v, depth+1); len(sr.errs) == 0 {
+				valid = true
+				r.annotate(sr)
+				if !st.schema.annotations {
+					// the remaining subschemas may only add annotations
+					break
+				}
+			}
+		}
+		if !valid {
+			r.fail(path, n.location+`/anyOf`, `value does not match any of the subschemas`)
+		}
+	}
+	if n.oneOf != nil {
+		var matches []int
+		for i, sub := range n.oneOf {
+			if sr := st.validate(sub, path, v, depth+1); len(sr.errs) == 0 {
+				matches = append(matches, i)
+				r.annotate(sr)
+			}
+		}
+		switch len(matches) {
+		case 1:
+		case 0:
+			r.fail(path, n.location+`/oneOf`, `value does not match any of the subschemas`)
+		default:
+			r.fail(path, n.location+`/oneOf`, `value matches more than one subschema (%d and %d)`, matches[0], matches[1])
+		}
+	}
+	if n.not != nil {
+		if sr := st.validate(n.not, path, v, depth+1); len(sr.errs) == 0 {
+			r.fail(path, n.location+`/not`, `value must not match the subschema`)
+		}
+	}
+	if n.ifSchema != nil {
+		if sr := st.validate(n.ifSchema, path, v, depth+1); len(sr.errs) == 0 {
+			r.annotate(sr)
+			if n.thenSchema != nil {
+				r.merge(st.validate(n.thenSchema, path, v, depth+1))
+			}
+		} else if n.elseSchema != nil {
+			r.merge(st.validate(n.elseSchema, path, v, depth+1))
+		}
+	}
+
+	// unevaluatedItems and unevaluatedProperties depend on the
+	// annotations of all the other keywords
+	switch kind {
+	case `array`:
+		if n.unevaluatedItems != nil {
+			l := v.([]interface{})
+			for i := r.items; i < len(l) && !r.allItems; i++ {
+				if _, ok := r.contained[i]; ok {
+					continue
+				}
+				elemPath := path.element(i)
+				if n.unevaluatedItems.never {
+					r.fail(&elemPath, n.unevaluatedItems.location, `unevaluated item is not allowed`)
+					continue
+				}
+				r.errs = append(r.errs, st.validate(n.unevaluatedItems, &elemPath, l[i], depth+1).errs...)
+			}
+			r.allItems = true
+		}
+	case `object`:
+		if n.unevaluatedProperties != nil {
+			m := v.(map[string]interface{})
+			for _, name := range sortedKeys(m) {
+				if _, ok := r.props[name]; ok {
+					continue
+				}
+				fieldPath := path.field(name)
+				if n.unevaluatedProperties.never {
+					r.fail(&fieldPath, n.unevaluatedProperties.location, `unevaluated property %q is not allowed`, name)
+				} else {
+					r.errs = append(r.errs, st.validate(n.unevaluatedProperties, &fieldPath, m[name], depth+1).errs...)
+				}
+				r.evaluated(name)
+			}
+		}
+	}
+	return r
+}
+
+func (st *state) validateNumber(n *node, path *instancePath, v interface{}, r *result) {
+	if n.multipleOf == nil && n.minimum == nil && n.maximum == nil && n.exclusiveMinimum == nil && n.exclusiveMaximum == nil {
+		return
+	}
+	x, ok := toRat(v)
+	if !ok {
+		r.fail(path, n.location, `%v is not a finite number`, v)
+		return
+	}
+
+	if n.multipleOf != nil {
+		if !new(big.Rat).Quo(x, n.multipleOf).IsInt() {
+			r.fail(path, n.location+`/multipleOf`, `%s is not a multiple of %s`, ratString(x), ratString(n.multipleOf))
+		}
+	}
+	if n.minimum != nil && x.Cmp(n.minimum) < 0 {
+		r.fail(path, n.location+`/minimum`, `%s is less than the minimum of %s`, ratString(x), ratString(n.minimum))
+	}
+	if n.maximum != nil && x.Cmp(n.maximum) > 0 {
+		r.fail(path, n.location+`/maximum`, `%s is greater than the maximum of %s`, ratString(x), ratString(n.maximum))
+	}
+	if n.exclusiveMinimum != nil && x.Cmp(n.exclusiveMinimum) <= 0 {
+		r.fail(path, n.location+`/exclusiveMinimum`, `%s must be greater than %s`, ratString(x), ratString(n.exclusiveMinimum))
+	}
+	if n.exclusiveMaximum != nil && x.Cmp(n.exclusiveMaximum) >= 0 {
+		r.fail(path, n.location+`/exclusiveMaximum`, `%s must be less than %s`, ratString(x), ratString(n.exclusiveMaximum))
+	}
+}
+
+func (st *state) validateString(n *node, path *instancePath, s string, r *result) {
+	if n.minLength > 0 || n.maxLength >= 0 {
+		// lengths are measured in code points
+		length := utf8.RuneCountInString(s)
+		if length < n.minLength {
+			r.fail(path, n.location+`/minLength`, `string has %d characters, fewer than the minimum of %d`, length, n.minLength)
+		}
+		if n.maxLength >= 0 && length > n.maxLength {
+			r.fail(path, n.location+`/maxLength`, `string has %d characters, more than the maximum of %d`, length, n.maxLength)
+		}
+	}
+	if n.pattern != nil && !n.pattern.MatchString(s) {
+		r.fail(path, n.location+`/pattern`, `string does not match the pattern %q`, n.pattern)
+	}
+	if st.schema.formats && n.format != "" {
+		if check, ok := formatCheckers[n.format]; ok && !check(s) {
+			r.fail(path, n.location+`/format`, `string is not a valid %s`, n.format)
+		}
+	}
+}
+
+func (st *state) validateArray(n *node, path *instancePath, l []interface{}, depth int, r *result) {
+	if len(l) < n.minItems {
+		r.fail(path, n.location+`/minItems`, `array has %d items, fewer than the minimum of %d`, len(l), n.minItems)
+	}
+	if n.maxItems >= 0 && len(l) > n.maxItems {
+		r.fail(path, n.location+`/maxItems`, `array has %d items, more than the maximum of %d`, len(l), n.maxItems)
+	}
+	if n.uniqueItems {
+	unique:
+		for i := 1; i < len(l); i++ {
+			for j := 0; j < i; j++ {
+				if equal(l[i], l[j]) {
+					r.fail(path, n.location+`/uniqueItems`, `items %d and %d are equal`, j, i)
+					break unique
+				}
+			}
+		}
+	}
+
+	for i, sub := range n.prefixItems {
+		if i >= len(l) {
+			break
+		}
+		elemPath := path.element(i)
+		r.errs = append(r.errs, st.validate(sub, &elemPath, l[i], depth+1).errs...)
+	}
+	if len(n.prefixItems) > r.items {
+		r.items = min(len(n.prefixItems), len(l))
+	}
+
+	if n.items != nil {
+		for i := len(n.prefixItems); i < len(l); i++ {
+			elemPath := path.element(i)
+			if n.items.never {
+				r.fail(&elemPath, n.items.location, `array must not have more than %d items`, len(n.prefixItems))
+				break
+			}
+			r.errs = append(r.errs, st.validate(n.items, &elemPath, l[i], depth+1).errs...)
+		}
+		r.allItems = true
+	}
+
+	if n.contains != nil {
+		var matches int
+		for i, elem := range l {
+			elemPath := path.element(i)
+			if len(st.validate(n.contains, &elemPath, elem, depth+1).errs) == 0 {
+				matches++
+				if st.schema.annotations {
+					if r.contained == nil {
+						r.contained = make(map[int]struct{})
+					}
+					r.contained[i] = struct{}{}
+				}
+			}
+		}
+		switch {
+		case matches < n.minContains:
+			location := n.location + `/minContains`
+			if matches == 0 {
+				location = n.location + `/contains`
+			}
+			r.fail(path, location, `array has %d items matching contains, fewer than the minimum of %d`, matches, n.minContains)
+		case n.maxContains >= 0 && matches > n.maxContains:
+			r.fail(path, n.location+`/maxContains`, `array has %d items matching contains, more than the maximum of %d`, matches, n.maxContains)
+		}
+	}
+}
+
+func (st *state) validateObject(n *node, path *instancePath, m map[string]interface{}, depth int, r *result) {
+	if len(m) < n.minProperties {
+		r.fail(path, n.location+`/minProperties`, `object has %d properties, fewer than the minimum of %d`, len(m), n.minProperties)
+	}
+	if n.maxProperties >= 0 && len(m) > n.maxProperties {
+		r.fail(path, n.location+`/maxProperties`, `object has %d properties, more than the maximum of %d`, len(m), n.maxProperties)
+	}
+	for _, name := range n.required {
+		if _, ok := m[name]; !ok {
+			r.fail(path, n.location+`/required`, `required property %q is missing`, name)
+		}
+	}
+	for _, dep := range n.dependentRequired {
+		if _, ok := m[dep.name]; !ok {
+			continue
+		}
+		for _, name := range dep.required {
+			if _, ok := m[name]; !ok {
+				r.fail(path, n.location+`/dependentRequired/`+escapePointer(dep.name), `property %q is required when %q is present`, name, dep.name)
+			}
+		}
+	}
+	for _, dep := range n.dependentSchemas {
+		if _, ok := m[dep.name]; ok {
+			r.merge(st.validate(dep.schema, path, m, depth+1))
+		}
+	}
+
+	// evaluated records the properties that were evaluated by
+	// properties and patternProperties, which additionalProperties
+	// does not apply to
+	var evaluated map[string]struct{}
+	needEvaluated := n.additionalProperties != nil
+	for _, prop := range n.properties {
+		v, ok := m[prop.name]
+		if !ok {
+			continue
+		}
+		fieldPath := path.field(prop.name)
+		r.errs = append(r.errs, st.validate(prop.schema, &fieldPath, v, depth+1).errs...)
+		if st.schema.annotations {
+			r.evaluated(prop.name)
+		}
+	}
+	if n.patternProperties == nil && n.additionalProperties == nil && n.propertyNames == nil {
+		return
+	}
+
+	keys := sortedKeys(m)
+	if needEvaluated {
+		evaluated = make(map[string]struct{}, len(n.properties))
+		for _, prop := range n.properties {
+			evaluated[prop.name] = struct{}{}
+		}
+	}
+	for _, name := range keys {
+		fieldPath := path.field(name)
+		if n.propertyNames != nil {
+			for _, err := range st.validate(n.propertyNames, &fieldPath, name, depth+1).errs {
+				err.Message = fmt.Sprintf(`invalid property name %q: %s`, name, err.Message)
+				r.errs = append(r.errs, err)
+			}
+		}
+		for _, pp := range n.patternProperties {
+			if !pp.pattern.MatchString(name) {
+				continue
+			}
+			r.errs = append(r.errs, st.validate(pp.schema, &fieldPath, m[name], depth+1).errs...)
+			if needEvaluated {
+				evaluated[name] = struct{}{}
+			}
+			if st.schema.annotations {
+				r.evaluated(name)
+			}
+		}
+		if n.additionalProperties == nil {
+			continue
+		}
+		if _, ok := evaluated[name]; ok {
+			continue
+		}
+		if n.additionalProperties.never {
+			r.fail(&fieldPath, n.additionalProperties.location, `additional property %q is not allowed`, name)
+		} else {
+			r.errs = append(r.errs, st.validate(n.additionalProperties, &fieldPath, m[name], depth+1).errs...)
+		}
+		if st.schema.annotations {
+			r.evaluated(name)
+		}
+	}
+}
+
+// kindOf returns the JSON Schema type of v, or an empty string if v
+// is not one of the values that documents are made of
+func kindOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return `null`
+	case bool:
+		return `boolean`
+	case stdlib.Number, json.Number, float64, int64:
+		return `number`
+	case string:
+		return `string`
+	case []interface{}:
+		return `array`
+	case map[string]interface{}:
+		return `object`
+	}
+	return ""
+}
+
+func hasType(types []string, kind string, v interface{}) bool {
+	for _, typ := range types {
+		switch {
+		case typ == kind:
+			return true
+		case typ == `integer` && kind == `number`:
+			if x, ok := toRat(v); ok && x.IsInt() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// toRat converts a number into a big.Rat, so that numbers are compared
+// without losing precision. Non-finite numbers cannot be converted
+func toRat(v interface{}) (*big.Rat, bool) {
+	switch v := v.(type) {
+	case stdlib.Number:
+		return new(big.Rat).SetString(string(v))
+	case json.Number:
+		buf, err := v.MarshalJSON()
+		if err != nil {
+			return nil, false
+		}
+		return new(big.Rat).SetString(string(buf))
+	case float64:
+		x := new(big.Rat).SetFloat64(v)
+		return x, x != nil
+	case int64:
+		return new(big.Rat).SetInt64(v), true
+	}
+	return nil, false
+}
+
+func ratString(x *big.Rat) string {
+	if x.IsInt() {
+		return x.Num().String()
+	}
+	if f, exact := x.Float64(); exact {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return x.RatString()
+}
+
+// equal reports whether a and b hold the same JSON value. Numbers are
+// equal if they have the same value, regardless of their representation
+func equal(a, b interface{}) bool {
+	ka, kb := kindOf(a), kindOf(b)
+	if ka != kb {
+		return false
+	}
+	switch ka {
+	case `null`:
+		return true
+	case `boolean`:
+		return a.(bool) == b.(bool)
+	case `string`:
+		return a.(string) == b.(string)
+	case `number`:
+		x, okx := toRat(a)
+		y, oky := toRat(b)
+		if !okx || !oky {
+			return okx == oky && fmt.Sprint(a) == fmt.Sprint(b)
+		}
+		return x.Cmp(y) == 0
+	case `array`:
+		la, lb := a.([]interface{}), b.([]interface{})
+		if len(la) != len(lb) {
+			return false
+		}
+		for i := range la {
+			if !equal(la[i], lb[i]) {
+				return false
+			}
+		}
+		return true
+	case `object`:
+		ma, mb := a.(map[string]interface{}), b.(map[string]interface{})
+		if len(ma) != len(mb) {
+			return false
+		}
+		for k, va := range ma {
+			vb, ok := mb[k]
+			if !ok || !equal(va, vb) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func contains(values []interface{}, v interface{}) bool {
+	for _, value := range values {
+		if equal(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// normalize returns the value held by c. Objects and arrays are shared
+// with c rather than copied, and values other than those produced by
+// the parser are converted when they are validated (see plain)
+func normalize(c json.Context) (interface{}, error) {
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := c.Map(&m); err == nil {
+		return m, nil
+	}
+	var l []interface{}
+	if err := c.Slice(&l); err == nil {
+		return l, nil
+	}
+	buf, err := c.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return decode(buf)
+}
+
+// plain converts a value that is not one of those produced by the
+// parser, such as a struct held by a Context created by json.New, by
+// encoding and parsing it again
+func plain(v interface{}) (interface{}, error) {
+	buf, err := json.New(v).MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return decode(buf)
+}
+
+func decode(buf []byte) (interface{}, error) {
+	c, err := json.Parse(buf, json.WithUseNumber(true), json.WithNonFiniteNumbers(true))
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := c.Map(&m); err == nil {
+		return m, nil
+	}
+	var l []interface{}
+	if err := c.Slice(&l); err == nil {
+		return l, nil
+	}
+
+	var s string
+	var b bool
+	switch {
+	case c.String(&s) == nil && len(buf) > 0 && buf[0] == '"':
+		return s, nil
+	case c.Bool(&b) == nil:
+		return b, nil
+	case string(buf) == `null`:
+		return nil, nil
+	}
+	return stdlib.Number(buf), nil
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func keyPath(parent, key string) string {
+	if isIdentifier(key) {
+		return parent + "." + key
+	}
+	return parent + "[" + strconv.Quote(key) + "]"
+}
+
+func indexPath(parent string, i int) string {
+	return parent + "[" + strconv.Itoa(i) + "]"
+}
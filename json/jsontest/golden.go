@@ -0,0 +1,162 @@
+package jsontest
+
+import (
+	"bytes"
+	stdlib "encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/lestrrat-go/json"
+)
+
+// update is registered in the test binaries of the packages that import
+// jsontest, so that golden files can be rewritten with `go test -update`
+var update = flag.Bool("update", false, `rewrite golden files with the actual output`)
+
+// AssertGolden checks that actual is semantically equal to the JSON
+// document stored in the golden file at path, reporting the differences
+// via t.Errorf if it is not. When the test binary is run with the
+// -update flag, the file is instead rewritten with the indented
+// encoding of actual, creating its directory if necessary.
+// actual may be any value accepted by Diff. The options are used both
+// for comparing documents and for writing golden files
+func AssertGolden(t testing.TB, path string, actual interface{}, options ...Option) bool {
+	t.Helper()
+
+	if *update {
+		buf, err := golden(actual, newConfig(options))
+		if err != nil {
+			t.Errorf(`failed to encode golden file %s: %s`, path, err)
+			return false
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Errorf(`failed to create directory for golden file %s: %s`, path, err)
+			return false
+		}
+		if err := os.WriteFile(path, buf, 0644); err != nil {
+			t.Errorf(`failed to write golden file %s: %s`, path, err)
+			return false
+		}
+		t.Logf(`updated golden file %s`, path)
+		return true
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			t.Errorf(`golden file %s does not exist, run the test with -update to create it`, path)
+			return false
+		}
+		t.Errorf(`failed to read golden file %s: %s`, path, err)
+		return false
+	}
+	return AssertEqual(t, expected, actual, options...)
+}
+
+// golden returns the contents of a golden file holding v, indented by
+// two spaces and terminated by a newline
+func golden(v interface{}, cfg *config) ([]byte, error) {
+	var c json.Context
+	switch v := v.(type) {
+	case string:
+		j, err := json.ParseString(v, json.WithPreserveKeyOrder(true), json.WithNonFiniteNumbers(true))
+		if err != nil {
+			return nil, err
+		}
+		c = j
+	case []byte:
+		j, err := json.Parse(v, json.WithPreserveKeyOrder(true), json.WithNonFiniteNumbers(true))
+		if err != nil {
+			return nil, err
+		}
+		c = j
+	case json.Context:
+		c = v
+	default:
+		c = json.New(v)
+	}
+
+	w := goldenWriter{cfg: cfg}
+	if err := w.write(`$`, c, ``); err != nil {
+		return nil, err
+	}
+	w.buf.WriteByte('\n')
+	return w.buf.Bytes(), nil
+}
+
+type goldenWriter struct {
+	buf bytes.Buffer
+	cfg *config
+}
+
+// write writes the value pointed by c, found at path, with the
+// nested lines prefixed by indent
+func (w *goldenWriter) write(path string, c json.Context, indent string) error {
+	var m map[string]interface{}
+	if err := c.Map(&m); err == nil {
+		var keys []string
+		for key := range c.Entries() {
+			if !w.cfg.isStripped(keyPath(path, key), key) {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			w.buf.WriteString(`{}`)
+			return nil
+		}
+		if w.cfg.sortedKeys {
+			sort.Strings(keys)
+		}
+
+		w.buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.buf.WriteString("\n" + indent + "  ")
+			b, err := stdlib.Marshal(key)
+			if err != nil {
+				return err
+			}
+			w.buf.Write(b)
+			w.buf.WriteString(`: `)
+			if err := w.write(keyPath(path, key), c.MapIndex(key), indent+"  "); err != nil {
+				return err
+			}
+		}
+		w.buf.WriteString("\n" + indent + "}")
+		return nil
+	}
+
+	var l []interface{}
+	if err := c.Slice(&l); err == nil {
+		if len(l) == 0 {
+			w.buf.WriteString(`[]`)
+			return nil
+		}
+		w.buf.WriteByte('[')
+		for i, child := range c.Elements() {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.buf.WriteString("\n" + indent + "  ")
+			if err := w.write(indexPath(path, i), child, indent+"  "); err != nil {
+				return err
+			}
+		}
+		w.buf.WriteString("\n" + indent + "]")
+		return nil
+	}
+
+	b, err := c.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf(`failed to marshal value at %s: %w`, path, err)
+	}
+	w.buf.Write(b)
+	return nil
+}
@@ -0,0 +1,382 @@
+// Package jsontest provides helpers for comparing JSON documents in
+// tests. Documents are compared semantically: the order of the fields
+// of JSON objects is ignored, numbers are compared by value, and
+// failures are reported as a list of differences along with their
+// paths, such as `$.items[2].name`. Documents can also be compared
+// against golden files, which are rewritten when the tests are run with
+// the -update flag.
+package jsontest
+
+import (
+	"bytes"
+	stdlib "encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/json"
+)
+
+// Difference describes a value that differs between two documents.
+// Expected and Actual hold the compact JSON encoding of the values,
+// and are empty if the value is missing from the respective document
+type Difference struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (d Difference) String() string {
+	switch {
+	case d.Expected == "":
+		return fmt.Sprintf(`%s: unexpected value %s`, d.Path, d.Actual)
+	case d.Actual == "":
+		return fmt.Sprintf(`%s: missing value, expected %s`, d.Path, d.Expected)
+	}
+	return fmt.Sprintf(`%s: expected %s, got %s`, d.Path, d.Expected, d.Actual)
+}
+
+// AssertEqual checks that the documents expected and actual are
+// semantically equal, reporting the differences via t.Errorf if they
+// are not. See Diff for the values that can be compared. It returns
+// true if the documents are equal
+func AssertEqual(t testing.TB, expected, actual interface{}, options ...Option) bool {
+	t.Helper()
+
+	diffs, err := Diff(expected, actual, options...)
+	if err != nil {
+		t.Errorf(`failed to compare JSON documents: %s`, err)
+		return false
+	}
+	if len(diffs) == 0 {
+		return true
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`JSON documents differ:`)
+	for _, d := range diffs {
+		sb.WriteString("\n\t")
+		sb.WriteString(d.String())
+	}
+	t.Errorf(`%s`, sb.String())
+	return false
+}
+
+// Diff returns the differences between the documents expected and
+// actual, in the order in which they were found, or nil if they are
+// semantically equal. Each document may be a json.Context, a string or
+// []byte holding JSON text, or any other value that can be passed to
+// json.New
+func Diff(expected, actual interface{}, options ...Option) ([]Difference, error) {
+	cfg := newConfig(options)
+	e, err := normalize(expected)
+	if err != nil {
+		return nil, fmt.Errorf(`invalid expected document: %w`, err)
+	}
+	a, err := normalize(actual)
+	if err != nil {
+		return nil, fmt.Errorf(`invalid actual document: %w`, err)
+	}
+
+	e = cfg.strip(`$`, e)
+	a = cfg.strip(`$`, a)
+	cmp := comparer{tolerance: cfg.tolerance, ignoreArrayOrder: cfg.ignoreArrayOrder}
+	cmp.compare(`$`, e, a)
+	return cmp.diffs, nil
+}
+
+type config struct {
+	tolerance        float64
+	ignoreArrayOrder bool
+	sortedKeys       bool
+	stripped         map[string]struct{}
+}
+
+func newConfig(options []Option) *config {
+	cfg := &config{stripped: make(map[string]struct{})}
+	for _, option := range options {
+		switch option.Name() {
+		case optKeyFloatTolerance:
+			cfg.tolerance = option.Value().(float64)
+		case optKeyIgnoreArrayOrder:
+			cfg.ignoreArrayOrder = option.Value().(bool)
+		case optKeySortedKeys:
+			cfg.sortedKeys = option.Value().(bool)
+		case optKeyStrippedFields:
+			for _, field := range option.Value().([]string) {
+				cfg.stripped[field] = struct{}{}
+			}
+		}
+	}
+	return cfg
+}
+
+// isStripped reports whether the field key, found at path, should be
+// removed from the documents
+func (cfg *config) isStripped(path, key string) bool {
+	if _, ok := cfg.stripped[key]; ok {
+		return true
+	}
+	_, ok := cfg.stripped[path]
+	return ok
+}
+
+// strip removes the stripped fields from the normalized value v, found
+// at path. Objects are modified in place
+func (cfg *config) strip(path string, v interface{}) interface{} {
+	if len(cfg.stripped) == 0 {
+		return v
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			p := keyPath(path, key)
+			if cfg.isStripped(p, key) {
+				delete(v, key)
+				continue
+			}
+			v[key] = cfg.strip(p, child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = cfg.strip(indexPath(path, i), child)
+		}
+	}
+	return v
+}
+
+// normalize converts v into a tree of map[string]interface{},
+// []interface{}, json.Number, string, bool, and nil values
+func normalize(v interface{}) (interface{}, error) {
+	var buf []byte
+	switch v := v.(type) {
+	case string:
+		buf = []byte(v)
+	case []byte:
+		buf = v
+	default:
+		c, ok := v.(json.Context)
+		if !ok {
+			c = json.New(v)
+		}
+		b, err := c.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		buf = b
+	}
+
+	c, err := json.Parse(buf, json.WithUseNumber(true), json.WithNonFiniteNumbers(true), json.WithDisallowTrailingData(true))
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := c.Map(&m); err == nil {
+		return m, nil
+	}
+	var l []interface{}
+	if err := c.Slice(&l); err == nil {
+		return l, nil
+	}
+
+	// scalars, which can be decoded without losing information, except
+	// for non-finite numbers
+	b, err := c.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	switch s := string(b); s {
+	case `NaN`, `Infinity`, `-Infinity`:
+		return stdlib.Number(s), nil
+	}
+	var x interface{}
+	d := stdlib.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type comparer struct {
+	tolerance        float64
+	ignoreArrayOrder bool
+	diffs            []Difference
+}
+
+func (cmp *comparer) report(path string, expected, actual interface{}, hasExpected, hasActual bool) {
+	d := Difference{Path: path}
+	if hasExpected {
+		d.Expected = encode(expected)
+	}
+	if hasActual {
+		d.Actual = encode(actual)
+	}
+	cmp.diffs = append(cmp.diffs, d)
+}
+
+// compare records the differences between e and a, found at path
+func (cmp *comparer) compare(path string, e, a interface{}) {
+	switch e := e.(type) {
+	case map[string]interface{}:
+		a, ok := a.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, key := range sortedKeys(e) {
+			av, ok := a[key]
+			if !ok {
+				cmp.report(keyPath(path, key), e[key], nil, true, false)
+				continue
+			}
+			cmp.compare(keyPath(path, key), e[key], av)
+		}
+		for _, key := range sortedKeys(a) {
+			if _, ok := e[key]; !ok {
+				cmp.report(keyPath(path, key), nil, a[key], false, true)
+			}
+		}
+		return
+	case []interface{}:
+		a, ok := a.([]interface{})
+		if !ok {
+			break
+		}
+		if cmp.ignoreArrayOrder {
+			cmp.compareUnordered(path, e, a)
+			return
+		}
+		for i := 0; i < len(e) || i < len(a); i++ {
+			switch {
+			case i >= len(a):
+				cmp.report(indexPath(path, i), e[i], nil, true, false)
+			case i >= len(e):
+				cmp.report(indexPath(path, i), nil, a[i], false, true)
+			default:
+				cmp.compare(indexPath(path, i), e[i], a[i])
+			}
+		}
+		return
+	case stdlib.Number:
+		if a, ok := a.(stdlib.Number); ok && cmp.numbersEqual(e, a) {
+			return
+		}
+	default:
+		if e == a {
+			return
+		}
+	}
+	cmp.report(path, e, a, true, true)
+}
+
+// compareUnordered matches each element of e with an equal element of
+// a, reporting the elements of either array that cannot be matched
+func (cmp *comparer) compareUnordered(path string, e, a []interface{}) {
+	used := make([]bool, len(a))
+	var unmatched []int
+	for i, ev := range e {
+		found := false
+		for j, av := range a {
+			if used[j] || !cmp.equal(ev, av) {
+				continue
+			}
+			used[j] = true
+			found = true
+			break
+		}
+		if !found {
+			unmatched = append(unmatched, i)
+		}
+	}
+	for _, i := range unmatched {
+		cmp.report(indexPath(path, i), e[i], nil, true, false)
+	}
+	for j, av := range a {
+		if !used[j] {
+			cmp.report(indexPath(path, j), nil, av, false, true)
+		}
+	}
+}
+
+// equal reports whether e and a are equal, without recording differences
+func (cmp *comparer) equal(e, a interface{}) bool {
+	sub := comparer{tolerance: cmp.tolerance, ignoreArrayOrder: cmp.ignoreArrayOrder}
+	sub.compare(`$`, e, a)
+	return len(sub.diffs) == 0
+}
+
+func (cmp *comparer) numbersEqual(e, a stdlib.Number) bool {
+	if e == a {
+		return true
+	}
+	var er, ar big.Rat
+	if _, ok := er.SetString(string(e)); ok {
+		if _, ok := ar.SetString(string(a)); ok && er.Cmp(&ar) == 0 {
+			return true
+		}
+	}
+	if cmp.tolerance <= 0 {
+		return false
+	}
+	ef, err1 := strconv.ParseFloat(string(e), 64)
+	af, err2 := strconv.ParseFloat(string(a), 64)
+	if err1 != nil || err2 != nil || math.IsInf(ef, 0) || math.IsInf(af, 0) {
+		return false
+	}
+	return math.Abs(ef-af) <= cmp.tolerance
+}
+
+// maxValueLength is the maximum length of the values printed in
+// differences, beyond which they are truncated
+const maxValueLength = 80
+
+func encode(v interface{}) string {
+	buf, err := json.New(v, json.WithNonFiniteNumbers(true)).MarshalJSON()
+	if err != nil {
+		return fmt.Sprintf(`%v`, v)
+	}
+	if len(buf) > maxValueLength {
+		return string(buf[:maxValueLength]) + `...`
+	}
+	return string(buf)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// keyPath and indexPath build the same paths as json.Context.Walk
+func keyPath(parent, key string) string {
+	if isIdentifier(key) {
+		return parent + "." + key
+	}
+	return parent + "[" + strconv.Quote(key) + "]"
+}
+
+func indexPath(parent string, i int) string {
+	return parent + "[" + strconv.Itoa(i) + "]"
+}
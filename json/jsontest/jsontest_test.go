@@ -0,0 +1,197 @@
+package jsontest_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/lestrrat-go/json"
+	"github.com/lestrrat-go/json/jsontest"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder captures the failures reported by AssertEqual
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertEqual(t *testing.T) {
+	t.Run("equal documents", func(t *testing.T) {
+		j, err := json.ParseString(`{"b":[1,2.0,{"c":null}],"a":"x"}`, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+
+		testcases := []struct {
+			Name     string
+			Expected interface{}
+		}{
+			{Name: "string", Expected: `{"a":"x","b":[1,2,{"c":null}]}`},
+			{Name: "bytes", Expected: []byte(`{"a": "x", "b": [1e0, 2, {"c": null}]}`)},
+			{Name: "Go value", Expected: map[string]interface{}{"a": "x", "b": []interface{}{1, 2.0, map[string]interface{}{"c": nil}}}},
+			{Name: "Context", Expected: json.New(map[string]interface{}{"b": []interface{}{1, 2, map[string]interface{}{"c": nil}}}).SetMapIndex("a", "x")},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				r := &recorder{TB: t}
+				if !assert.True(t, jsontest.AssertEqual(r, tc.Expected, j), `AssertEqual should succeed`) {
+					t.Log(r.errors)
+					return
+				}
+			})
+		}
+	})
+	t.Run("differences", func(t *testing.T) {
+		diffs, err := jsontest.Diff(`{"a":1,"b":{"c":[1,2,3]},"d":"x","key with spaces":true}`, `{"a":2,"b":{"c":[1,2]},"e":null,"key with spaces":"true"}`)
+		if !assert.NoError(t, err, `Diff should succeed`) {
+			return
+		}
+		expected := []string{
+			`$.a: expected 1, got 2`,
+			`$.b.c[2]: missing value, expected 3`,
+			`$.d: missing value, expected "x"`,
+			`$["key with spaces"]: expected true, got "true"`,
+			`$.e: unexpected value null`,
+		}
+		var actual []string
+		for _, d := range diffs {
+			actual = append(actual, d.String())
+		}
+		if !assert.Equal(t, expected, actual, `differences should match`) {
+			return
+		}
+
+		r := &recorder{TB: t}
+		if !assert.False(t, jsontest.AssertEqual(r, `[1]`, `[2]`), `AssertEqual should fail`) {
+			return
+		}
+		if !assert.Equal(t, []string{"JSON documents differ:\n\t$[0]: expected 1, got 2"}, r.errors, `failure should be reported`) {
+			return
+		}
+	})
+	t.Run("float tolerance", func(t *testing.T) {
+		r := &recorder{TB: t}
+		if !assert.False(t, jsontest.AssertEqual(r, `{"pi":3.14159}`, `{"pi":3.1416}`), `numbers should be compared exactly by default`) {
+			return
+		}
+		if !assert.True(t, jsontest.AssertEqual(r, `{"pi":3.14159}`, `{"pi":3.1416}`, jsontest.WithFloatTolerance(1e-4)), `numbers within the tolerance should be equal`) {
+			return
+		}
+		if !assert.False(t, jsontest.AssertEqual(r, `{"pi":3.14159}`, `{"pi":3.15}`, jsontest.WithFloatTolerance(1e-4)), `numbers beyond the tolerance should differ`) {
+			return
+		}
+	})
+	t.Run("array order", func(t *testing.T) {
+		r := &recorder{TB: t}
+		if !assert.False(t, jsontest.AssertEqual(r, `[1,{"a":[2,3]},4]`, `[4,1,{"a":[3,2]}]`), `order should matter by default`) {
+			return
+		}
+		if !assert.True(t, jsontest.AssertEqual(r, `[1,{"a":[2,3]},4]`, `[4,1,{"a":[3,2]}]`, jsontest.WithIgnoreArrayOrder(true)), `order should be ignored`) {
+			return
+		}
+
+		diffs, err := jsontest.Diff(`[1,1,2]`, `[1,2,2]`, jsontest.WithIgnoreArrayOrder(true))
+		if !assert.NoError(t, err, `Diff should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []jsontest.Difference{{Path: `$[1]`, Expected: `1`}, {Path: `$[2]`, Actual: `2`}}, diffs, `duplicates should be matched once`) {
+			return
+		}
+	})
+	t.Run("invalid documents", func(t *testing.T) {
+		if _, err := jsontest.Diff(`{`, `{}`); !assert.Error(t, err, `invalid expected documents should be rejected`) {
+			return
+		}
+		if _, err := jsontest.Diff(`{}`, `{} {}`); !assert.Error(t, err, `invalid actual documents should be rejected`) {
+			return
+		}
+	})
+}
+
+func TestAssertGolden(t *testing.T) {
+	setUpdate := func(t *testing.T, v bool) {
+		t.Helper()
+		if !assert.NoError(t, flag.Set("update", strconv.FormatBool(v)), `flag.Set should succeed`) {
+			t.FailNow()
+		}
+		t.Cleanup(func() { flag.Set("update", "false") })
+	}
+
+	j, err := json.ParseString(`{"name":"foo","id":"8f1c","meta":{"created":"2024-03-01T12:00:00Z","id":1},"tags":["b","a"]}`, json.WithPreserveKeyOrder(true))
+	if !assert.NoError(t, err, `json.ParseString should succeed`) {
+		return
+	}
+
+	t.Run("update", func(t *testing.T) {
+		testcases := []struct {
+			Name     string
+			Options  []jsontest.Option
+			Expected string
+		}{
+			{
+				Name:     "key order",
+				Expected: "{\n  \"name\": \"foo\",\n  \"id\": \"8f1c\",\n  \"meta\": {\n    \"created\": \"2024-03-01T12:00:00Z\",\n    \"id\": 1\n  },\n  \"tags\": [\n    \"b\",\n    \"a\"\n  ]\n}\n",
+			},
+			{
+				Name:     "sorted keys and stripped fields",
+				Options:  []jsontest.Option{jsontest.WithSortedKeys(true), jsontest.WithStrippedFields(`$.meta.created`, `id`)},
+				Expected: "{\n  \"meta\": {},\n  \"name\": \"foo\",\n  \"tags\": [\n    \"b\",\n    \"a\"\n  ]\n}\n",
+			},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				setUpdate(t, true)
+				path := filepath.Join(t.TempDir(), "testdata", "golden.json")
+				if !assert.True(t, jsontest.AssertGolden(t, path, j, tc.Options...), `AssertGolden should succeed`) {
+					return
+				}
+				buf, err := os.ReadFile(path)
+				if !assert.NoError(t, err, `os.ReadFile should succeed`) {
+					return
+				}
+				if !assert.Equal(t, tc.Expected, string(buf), `golden file should match`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("compare", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "golden.json")
+		const content = `{"tags":["b","a"],"meta":{"created":"2000-01-01T00:00:00Z"},"name":"foo"}`
+		if !assert.NoError(t, os.WriteFile(path, []byte(content), 0644), `os.WriteFile should succeed`) {
+			return
+		}
+
+		r := &recorder{TB: t}
+		if !assert.True(t, jsontest.AssertGolden(r, path, j, jsontest.WithStrippedFields(`$.meta.created`, `id`)), `AssertGolden should succeed`) {
+			t.Log(r.errors)
+			return
+		}
+		if !assert.False(t, jsontest.AssertGolden(r, path, j), `volatile fields should be compared by default`) {
+			return
+		}
+		if !assert.Len(t, r.errors, 1, `failure should be reported`) {
+			return
+		}
+
+		r = &recorder{TB: t}
+		if !assert.False(t, jsontest.AssertGolden(r, filepath.Join(t.TempDir(), "missing.json"), j), `missing golden files should fail`) {
+			return
+		}
+		if !assert.Contains(t, r.errors[0], `-update`, `failure should mention -update`) {
+			return
+		}
+	})
+}
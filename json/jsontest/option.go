@@ -0,0 +1,58 @@
+package jsontest
+
+const (
+	optKeyFloatTolerance   = `optkey-float-tolerance`
+	optKeyIgnoreArrayOrder = `optkey-ignore-array-order`
+	optKeySortedKeys       = `optkey-sorted-keys`
+	optKeyStrippedFields   = `optkey-stripped-fields`
+)
+
+// Option configures how documents are compared
+type Option interface {
+	Name() string
+	Value() interface{}
+}
+
+type option struct {
+	name  string
+	value interface{}
+}
+
+func (o option) Name() string {
+	return o.name
+}
+
+func (o option) Value() interface{} {
+	return o.value
+}
+
+// WithFloatTolerance specifies that numbers should be considered equal
+// if they differ by no more than tolerance. By default, numbers must be
+// numerically equal, so that `1.0` and `1` are equal, but `0.1` and
+// `0.1000001` are not
+func WithFloatTolerance(tolerance float64) Option {
+	return &option{name: optKeyFloatTolerance, value: tolerance}
+}
+
+// WithIgnoreArrayOrder specifies that arrays should be considered equal
+// if they contain the same elements, regardless of their order
+func WithIgnoreArrayOrder(b bool) Option {
+	return &option{name: optKeyIgnoreArrayOrder, value: b}
+}
+
+// WithSortedKeys specifies that AssertGolden should write the fields of
+// objects sorted by key when updating golden files. By default, fields
+// are written in the order in which the Context visits them
+func WithSortedKeys(b bool) Option {
+	return &option{name: optKeySortedKeys, value: b}
+}
+
+// WithStrippedFields specifies fields that should be removed from both
+// documents before they are compared or written to golden files, such
+// as timestamps and generated identifiers. Fields given as paths, such
+// as `$.meta.id`, are removed from that location only, while plain key
+// names, such as `id`, are removed from objects at any depth.
+// This option can be specified multiple times
+func WithStrippedFields(fields ...string) Option {
+	return &option{name: optKeyStrippedFields, value: fields}
+}
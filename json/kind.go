@@ -0,0 +1,108 @@
+package json
+
+import (
+	stdlib "encoding/json"
+	"reflect"
+)
+
+// ValueKind describes the kind of a JSON value
+type ValueKind int
+
+const (
+	InvalidValue ValueKind = iota
+	NullValue
+	BoolValue
+	NumberValue
+	StringValue
+	ArrayValue
+	ObjectValue
+)
+
+// String returns the name of the kind, as used by JSON Schema
+func (k ValueKind) String() string {
+	switch k {
+	case NullValue:
+		return "null"
+	case BoolValue:
+		return "boolean"
+	case NumberValue:
+		return "number"
+	case StringValue:
+		return "string"
+	case ArrayValue:
+		return "array"
+	case ObjectValue:
+		return "object"
+	default:
+		return "invalid"
+	}
+}
+
+var (
+	numberType    = reflect.TypeOf(stdlib.Number(""))
+	marshalerType = reflect.TypeOf((*stdlib.Marshaler)(nil)).Elem()
+)
+
+// kindOf returns the kind of the JSON value that v is encoded as
+func kindOf(v interface{}) ValueKind {
+	switch v := v.(type) {
+	case nil:
+		return NullValue
+	case bool:
+		return BoolValue
+	case string:
+		return StringValue
+	case stdlib.Number, Number, float64, int64:
+		return NumberValue
+	case []interface{}:
+		return ArrayValue
+	case map[string]interface{}:
+		return ObjectValue
+	case rawValue:
+		if len(v) > 0 && v[0] == '[' {
+			return ArrayValue
+		}
+		return ObjectValue
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return NullValue
+		}
+		rv = rv.Elem()
+	}
+	return kindOfType(rv.Type())
+}
+
+// kindOfType returns the kind of the JSON values that values of type t
+// are encoded as, or InvalidValue if it cannot be determined from the
+// type alone
+func kindOfType(t reflect.Type) ValueKind {
+	switch {
+	case t == bytesType:
+		return StringValue
+	case t == numberType:
+		return NumberValue
+	case t.Implements(marshalerType):
+		return InvalidValue
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return BoolValue
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return NumberValue
+	case reflect.String:
+		return StringValue
+	case reflect.Slice, reflect.Array:
+		return ArrayValue
+	case reflect.Map, reflect.Struct:
+		return ObjectValue
+	case reflect.Pointer:
+		return kindOfType(t.Elem())
+	}
+	return InvalidValue
+}
@@ -0,0 +1,136 @@
+package json
+
+import (
+	"bufio"
+	"bytes"
+	stdlib "encoding/json"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// DefaultMaxLineSize is the default maximum size of a single line
+// when reading newline-delimited JSON
+const DefaultMaxLineSize = 1024 * 1024
+
+// ParseLines returns an iterator that reads newline-delimited JSON
+// (a.k.a. NDJSON or JSON Lines) from r, yielding a Context for each record.
+// Blank lines are skipped.
+//
+// If a record fails to parse, the error is yielded along with a nil Context,
+// and the iteration proceeds to the next line. If reading from r fails,
+// or a line exceeds the maximum line size (see WithMaxLineSize), the
+// error is yielded and the iteration stops.
+//
+// ParseOptions passed in options are used to parse each record
+func ParseLines(r io.Reader, options ...LinesOption) iter.Seq2[Context, error] {
+	maxLineSize := DefaultMaxLineSize
+	var parseOptions []ParseOption
+	for _, option := range options {
+		if po, ok := option.(ParseOption); ok {
+			parseOptions = append(parseOptions, po)
+			continue
+		}
+		switch option.Name() {
+		case optKeyMaxLineSize:
+			maxLineSize = option.Value().(int)
+		}
+	}
+
+	// the scanner reuses its buffer, so the lines are copied if the
+	// parsed values may refer to them
+	retainsInput := newParseConfig(parseOptions).retainsInput()
+
+	return func(yield func(Context, error) bool) {
+		// the initial buffer must not be larger than the maximum, as
+		// the scanner allows tokens up to the buffer's capacity
+		initialSize := 4096
+		if initialSize > maxLineSize {
+			initialSize = maxLineSize
+		}
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, initialSize), maxLineSize)
+
+		var lineno int
+		for scanner.Scan() {
+			lineno++
+			line := scanner.Bytes()
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+
+			if retainsInput {
+				line = bytes.Clone(line)
+			}
+			c, err := Parse(line, parseOptions...)
+			if err != nil {
+				err = fmt.Errorf(`failed to parse line %d: %w`, lineno, err)
+			}
+			if !yield(c, err) {
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			yield(nil, fmt.Errorf(`failed to read line %d: %w`, lineno+1, err))
+		}
+	}
+}
+
+// LinesWriter writes values as newline-delimited JSON. Output is
+// buffered, so Flush must be called after the last value is written
+type LinesWriter struct {
+	buf        bytes.Buffer
+	enc        *stdlib.Encoder
+	escapeHTML bool
+	dst        *bufio.Writer
+}
+
+// NewLinesWriter creates a new LinesWriter that writes to w
+func NewLinesWriter(w io.Writer) *LinesWriter {
+	lw := &LinesWriter{dst: bufio.NewWriter(w), escapeHTML: true}
+	lw.enc = stdlib.NewEncoder(&lw.buf)
+	return lw
+}
+
+// WriteValue writes v as a single line of compact JSON.
+// v may either be a Context, or any value that can be encoded
+// by encoding/json. If v cannot be encoded, nothing is written
+func (lw *LinesWriter) WriteValue(v interface{}) error {
+	v, err := encodableValue(v)
+	if err != nil {
+		return err
+	}
+
+	// Encode into the intermediate buffer first so that a failure
+	// does not leave a partial line in the output
+	lw.buf.Reset()
+	if x, ok := v.(*ctx); ok {
+		buf, err := Marshal(x, WithEscapeHTML(lw.escapeHTML))
+		if err != nil {
+			return fmt.Errorf(`failed to encode JSON: %w`, err)
+		}
+		lw.buf.Write(buf)
+		lw.buf.WriteByte('\n')
+	} else if err := lw.enc.Encode(v); err != nil {
+		return fmt.Errorf(`failed to encode JSON: %w`, err)
+	}
+
+	if _, err := lw.dst.Write(lw.buf.Bytes()); err != nil {
+		return fmt.Errorf(`failed to write line: %w`, err)
+	}
+	return nil
+}
+
+// SetEscapeHTML specifies whether problematic HTML characters
+// (`<`, `>`, and `&`) should be escaped inside JSON strings.
+// The default is true
+func (lw *LinesWriter) SetEscapeHTML(on bool) {
+	lw.enc.SetEscapeHTML(on)
+	lw.escapeHTML = on
+}
+
+// Flush writes any buffered data to the underlying writer
+func (lw *LinesWriter) Flush() error {
+	return lw.dst.Flush()
+}
@@ -0,0 +1,179 @@
+package json_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLines(t *testing.T) {
+	t.Run("sanity", func(t *testing.T) {
+		const src = "{\"seq\": 1}\n\n  \n{\"seq\": 2}\r\n{\"seq\": 3}"
+
+		var seqs []int
+		for j, err := range json.ParseLines(strings.NewReader(src)) {
+			if !assert.NoError(t, err, `json.ParseLines should succeed`) {
+				return
+			}
+			var seq int
+			if !assert.NoError(t, j.MapIndex("seq").Int(&seq), `j.MapIndex.Int should succeed`) {
+				return
+			}
+			seqs = append(seqs, seq)
+		}
+		if !assert.Equal(t, []int{1, 2, 3}, seqs, `values should match`) {
+			return
+		}
+	})
+	t.Run("malformed record", func(t *testing.T) {
+		const src = "{\"seq\": 1}\n{\"seq\": \n{\"seq\": 3}\n"
+
+		var count int
+		var errs []error
+		for _, err := range json.ParseLines(strings.NewReader(src)) {
+			count++
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if !assert.Equal(t, 3, count, `all records should be yielded`) {
+			return
+		}
+		if !assert.Len(t, errs, 1, `there should be one error`) {
+			return
+		}
+		if !assert.Contains(t, errs[0].Error(), `line 2`, `error should contain the line number`) {
+			return
+		}
+	})
+	t.Run("oversized line", func(t *testing.T) {
+		src := "{\"seq\": 1}\n" + `{"data": "` + strings.Repeat("x", 128) + `"}` + "\n{\"seq\": 3}\n"
+
+		var count int
+		var lastErr error
+		for _, err := range json.ParseLines(strings.NewReader(src), json.WithMaxLineSize(64)) {
+			count++
+			lastErr = err
+		}
+		if !assert.Equal(t, 2, count, `iteration should stop after the oversized line`) {
+			return
+		}
+		if !assert.Error(t, lastErr, `last yielded value should be an error`) {
+			return
+		}
+	})
+	t.Run("records retained after the iteration moved on", func(t *testing.T) {
+		var sb strings.Builder
+		for i := 0; i < 500; i++ {
+			fmt.Fprintf(&sb, "{\"id\":\"record-%04d\",\"nested\":{\"seq\":%d}}\n", i, i)
+		}
+
+		for _, option := range []json.ParseOption{json.WithLazy(true), json.WithUnsafeStrings(true), json.WithProjection("id")} {
+			var records []json.Context
+			for j, err := range json.ParseLines(strings.NewReader(sb.String()), option) {
+				if !assert.NoError(t, err, `json.ParseLines should succeed`) {
+					return
+				}
+				records = append(records, j)
+			}
+			if !assert.Len(t, records, 500, `all records should be yielded`) {
+				return
+			}
+			for i, j := range records {
+				var id string
+				var seq int
+				if !assert.NoError(t, j.MapIndex("id").String(&id), `j.MapIndex.String should succeed`) {
+					return
+				}
+				if !assert.NoError(t, j.MapIndex("nested").MapIndex("seq").Int(&seq), `j.MapIndex.Int should succeed`) {
+					return
+				}
+				if !assert.Equal(t, fmt.Sprintf("record-%04d", i), id, `values should match`) {
+					return
+				}
+				if !assert.Equal(t, i, seq, `values should match`) {
+					return
+				}
+			}
+		}
+	})
+}
+
+func TestLinesWriter(t *testing.T) {
+	t.Run("sanity", func(t *testing.T) {
+		var buf strings.Builder
+		lw := json.NewLinesWriter(&buf)
+
+		j, err := json.Parse([]byte(`{
+  "seq": 1,
+  "list": [1, 2, 3]
+}`))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+		if !assert.NoError(t, lw.WriteValue(j), `lw.WriteValue should succeed`) {
+			return
+		}
+		if !assert.NoError(t, lw.WriteValue(map[string]interface{}{"seq": 2}), `lw.WriteValue should succeed`) {
+			return
+		}
+		if !assert.Error(t, lw.WriteValue(make(chan int)), `lw.WriteValue should fail`) {
+			return
+		}
+		if !assert.Error(t, lw.WriteValue(j.MapIndex("nonexistent")), `lw.WriteValue should fail`) {
+			return
+		}
+		if !assert.NoError(t, lw.WriteValue("<3>"), `lw.WriteValue should succeed`) {
+			return
+		}
+		if !assert.NoError(t, lw.Flush(), `lw.Flush should succeed`) {
+			return
+		}
+
+		if !assert.Equal(t, "{\"list\":[1,2,3],\"seq\":1}\n{\"seq\":2}\n\"\\u003c3\\u003e\"\n", buf.String(), `output should match`) {
+			return
+		}
+	})
+	t.Run("round trip", func(t *testing.T) {
+		const src = "{\"a\":1}\n{\"b\":[true,null]}\n"
+
+		var buf strings.Builder
+		lw := json.NewLinesWriter(&buf)
+		for j, err := range json.ParseLines(strings.NewReader(src)) {
+			if !assert.NoError(t, err, `json.ParseLines should succeed`) {
+				return
+			}
+			if !assert.NoError(t, lw.WriteValue(j), `lw.WriteValue should succeed`) {
+				return
+			}
+		}
+		if !assert.NoError(t, lw.Flush(), `lw.Flush should succeed`) {
+			return
+		}
+		if !assert.Equal(t, src, buf.String(), `output should match input`) {
+			return
+		}
+	})
+	t.Run("HTML escaping with preserved key order", func(t *testing.T) {
+		j, err := json.ParseString(`{"b":"<x>","a":1}`, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+
+		var buf strings.Builder
+		lw := json.NewLinesWriter(&buf)
+		lw.SetEscapeHTML(false)
+		if !assert.NoError(t, lw.WriteValue(j), `lw.WriteValue should succeed`) {
+			return
+		}
+		if !assert.NoError(t, lw.Flush(), `lw.Flush should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "{\"b\":\"<x>\",\"a\":1}\n", buf.String(), `output should match`) {
+			return
+		}
+	})
+}
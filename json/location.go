@@ -0,0 +1,34 @@
+package json
+
+import (
+	"fmt"
+)
+
+// Position describes a position in the input
+type Position struct {
+	// Offset is the byte offset in the input
+	Offset int64
+	// Line and Column are 1-based, and Column is counted in bytes
+	Line   int
+	Column int
+}
+
+// Location describes the span of a value in the input. Start is the
+// position of the first byte of the value, and End is the position
+// immediately after its last byte
+type Location struct {
+	Start Position
+	End   Position
+}
+
+func (c *ctx) Location() (Location, error) {
+	if c.locations == nil {
+		return Location{}, fmt.Errorf(`location information is not available (parse with WithLocations to enable it)`)
+	}
+
+	loc, ok := c.locations[c.path]
+	if !ok {
+		return Location{}, fmt.Errorf(`location of %s is not known`, c.path)
+	}
+	return loc, nil
+}
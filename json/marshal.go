@@ -0,0 +1,711 @@
+package json
+
+import (
+	"bytes"
+	stdlib "encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// KeyOrder specifies the order in which the fields of JSON objects
+// are emitted. See WithKeyOrder
+type KeyOrder int
+
+const (
+	// KeyOrderLexical emits the fields in lexical order of the keys
+	KeyOrderLexical KeyOrder = iota + 1
+	// KeyOrderInsertion emits the fields in the order in which they
+	// appeared in the input, or were added. This requires the Context
+	// to be created with WithPreserveKeyOrder: otherwise the fields
+	// are emitted in lexical order
+	KeyOrderInsertion
+)
+
+// Marshal returns the JSON encoding of v, which may either be a
+// Context or any value that can be encoded by encoding/json.
+// Without any options, the result is the same as v.MarshalJSON()
+// for Contexts
+func Marshal(v interface{}, options ...MarshalOption) ([]byte, error) {
+	e := getEncodeState()
+	defer releaseEncodeState(e)
+	e.escapeHTML = true
+	value, err := e.init(v, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.encode(value); err != nil {
+		return nil, err
+	}
+	return e.bytes(), nil
+}
+
+// Compact appends to dst the compacted form of the JSON value in data.
+// Unlike encoding/json.Compact, data is parsed into a Context and
+// marshaled again, so that the ParseOptions and MarshalOptions given in
+// options are honored. The order of the keys is preserved, unless
+// specified otherwise via WithKeyOrder or WithKeyComparator.
+// If data is not valid, dst is not modified
+func Compact(dst *bytes.Buffer, data []byte, options ...Option) error {
+	return reformat(dst, data, options, nil)
+}
+
+// Indent appends to dst the indented form of the JSON value in data,
+// in the same manner as encoding/json.Indent. See Compact for how
+// data is processed
+func Indent(dst *bytes.Buffer, data []byte, prefix, indent string, options ...Option) error {
+	return reformat(dst, data, options, []MarshalOption{WithIndent(prefix, indent)})
+}
+
+func reformat(dst *bytes.Buffer, data []byte, options []Option, marshalOptions []MarshalOption) error {
+	parseOptions := []ParseOption{WithPreserveKeyOrder(true)}
+	for _, option := range options {
+		switch option := option.(type) {
+		case ParseOption:
+			parseOptions = append(parseOptions, option)
+		case MarshalOption:
+			marshalOptions = append(marshalOptions, option)
+		}
+	}
+
+	c, err := Parse(data, parseOptions...)
+	if err != nil {
+		return err
+	}
+	buf, err := Marshal(c, marshalOptions...)
+	if err != nil {
+		return err
+	}
+	dst.Write(buf)
+	return nil
+}
+
+// encodeState writes the JSON encoding of values in the same manner
+// as encoding/json, except for the extensions that encoding/json
+// does not support: non-finite numbers are written as `NaN`,
+// `Infinity`, and `-Infinity` if nonFinite is true (see
+// WithNonFiniteNumbers), and the keys of objects are written in the
+// order recorded in order, if it is non-nil (see WithPreserveKeyOrder).
+// If indented is true, the output is formatted in the same manner as
+// encoding/json.MarshalIndent
+type encodeState struct {
+	buf        bytes.Buffer
+	nonFinite  bool
+	order      *keyOrder
+	timeFormat *timeFormat
+	marshalers marshalFuncs
+	// lazy, if non-nil, is used to decode the deferred values, whose
+	// raw bytes may not be valid JSON. See rawConfig
+	lazy *parseConfig
+
+	// compare, if non-nil, determines the order of the keys of objects
+	compare    func(a, b string) int
+	escapeHTML bool
+	scratch    bytes.Buffer
+	// omitEmpty is true if fields with empty values should be omitted
+	omitEmpty bool
+
+	indented bool
+	prefix   string
+	indent   string
+	depth    int
+
+	// parallelism is the number of goroutines used to encode large
+	// arrays, or 0 if they are encoded sequentially
+	parallelism int
+
+	// if w is non-nil, the contents of buf are written to w whenever
+	// they exceed flushThreshold. written is the number of bytes
+	// written to w so far
+	w       io.Writer
+	written int64
+}
+
+const flushThreshold = 32 * 1024
+
+// maxPooledBufferSize is the maximum capacity of the buffers of the
+// encodeStates that are kept for reuse, so that a single large value
+// does not keep a large amount of memory alive
+const maxPooledBufferSize = 1024 * 1024
+
+var encodeStatePool sync.Pool
+
+// recentSize is a moving average of the sizes of the values encoded
+// by pooled encodeStates, which is used as the initial capacity of the
+// buffers of new ones
+var recentSize atomic.Int64
+
+// getEncodeState returns an encodeState with the default settings,
+// whose buffer is likely to have enough capacity for the value being
+// encoded. It must be released by calling releaseEncodeState
+func getEncodeState() *encodeState {
+	if e, ok := encodeStatePool.Get().(*encodeState); ok {
+		return e
+	}
+	e := &encodeState{}
+	e.buf.Grow(int(recentSize.Load()))
+	return e
+}
+
+func releaseEncodeState(e *encodeState) {
+	// the average is taken over roughly the last 8 values
+	n := int64(e.buf.Len())
+	for {
+		avg := recentSize.Load()
+		if recentSize.CompareAndSwap(avg, min(avg+(n-avg)/8, maxPooledBufferSize)) {
+			break
+		}
+	}
+
+	if e.buf.Cap() > maxPooledBufferSize || e.scratch.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf, scratch := e.buf, e.scratch
+	buf.Reset()
+	scratch.Reset()
+	*e = encodeState{buf: buf, scratch: scratch}
+	encodeStatePool.Put(e)
+}
+
+// bytes returns a copy of the encoded value, which remains valid after
+// e is released
+func (e *encodeState) bytes() []byte {
+	return append(make([]byte, 0, e.buf.Len()), e.buf.Bytes()...)
+}
+
+// flush writes the contents of buf to w. Unless force is true, nothing
+// is written until enough data has accumulated
+func (e *encodeState) flush(force bool) error {
+	if e.w == nil || (!force && e.buf.Len() < flushThreshold) {
+		return nil
+	}
+	n, err := e.w.Write(e.buf.Bytes())
+	e.written += int64(n)
+	e.buf.Reset()
+	if err != nil {
+		return fmt.Errorf(`failed to write JSON: %w`, err)
+	}
+	return nil
+}
+
+// isEmpty reports whether v should be omitted when omitEmpty is true
+func (e *encodeState) isEmpty(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		for _, elem := range v {
+			if !e.isEmpty(elem) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// values that are not part of the document tree
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// customEncoding reports whether the value held by c must be encoded
+// using encodeState, as encoding/json would encode it differently
+func (c *ctx) customEncoding() bool {
+	return c.nonFinite || c.order != nil || c.timeFormat != nil || c.marshalers != nil || c.rawConfig() != nil
+}
+
+// rawConfig returns the configuration used to decode the deferred
+// values held by c before they are encoded, or nil if their raw bytes
+// can be written as they are
+func (c *ctx) rawConfig() *parseConfig {
+	if c.lazy != nil && c.lazy.extended() {
+		return c.lazy
+	}
+	return nil
+}
+
+// newEncodeState creates an encodeState that encodes the value held
+// by c in the same manner as MarshalJSON
+func (c *ctx) newEncodeState() encodeState {
+	return encodeState{
+		nonFinite:  c.nonFinite,
+		order:      c.order,
+		timeFormat: c.timeFormat,
+		marshalers: c.marshalers,
+		lazy:       c.rawConfig(),
+		escapeHTML: true,
+	}
+}
+
+// getEncodeState is the same as newEncodeState, except that the
+// encodeState is taken from the pool. See getEncodeState
+func (c *ctx) getEncodeState() *encodeState {
+	e := getEncodeState()
+	e.nonFinite = c.nonFinite
+	e.order = c.order
+	e.timeFormat = c.timeFormat
+	e.marshalers = c.marshalers
+	e.lazy = c.rawConfig()
+	e.escapeHTML = true
+	return e
+}
+
+// init configures e to encode v according to options, and returns
+// the value that should be passed to encode
+func (e *encodeState) init(v interface{}, options []MarshalOption) (interface{}, error) {
+	switch x := v.(type) {
+	case *ctx:
+		e.nonFinite = x.nonFinite
+		e.order = x.order
+		e.timeFormat = x.timeFormat
+		e.marshalers = x.marshalers
+		e.lazy = x.rawConfig()
+		v = x.interfaceValue()
+	case *errCtx:
+		return nil, x.err
+	case errCtx:
+		return nil, x.err
+	}
+
+	for _, option := range options {
+		switch option.Name() {
+		case optKeyKeyOrder:
+			if option.Value().(KeyOrder) == KeyOrderLexical {
+				e.order = nil
+			}
+		case optKeyKeyComparator:
+			e.compare = option.Value().(func(a, b string) int)
+		case optKeyOmitEmpty:
+			e.omitEmpty = option.Value().(bool)
+		case optKeyEscapeHTML:
+			e.escapeHTML = option.Value().(bool)
+		case optKeyIndent:
+			v := option.Value().([2]string)
+			e.indented = true
+			e.prefix = v[0]
+			e.indent = v[1]
+		case optKeyParallelism:
+			e.parallelism = option.Value().(int)
+			if e.parallelism <= 0 {
+				e.parallelism = runtime.GOMAXPROCS(0)
+			}
+		}
+	}
+	return v, nil
+}
+
+// mapKeys returns the keys of m in the order in which they should be written
+func (e *encodeState) mapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	if e.order != nil && e.compare == nil {
+		for _, key := range e.order.mapKeys(reflect.ValueOf(m)) {
+			keys = append(keys, key.String())
+		}
+		return keys
+	}
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+	if e.compare != nil {
+		sort.SliceStable(keys, func(i, j int) bool {
+			return e.compare(keys[i], keys[j]) < 0
+		})
+	} else {
+		sort.Strings(keys)
+	}
+	return keys
+}
+
+// newline starts a new line at the current depth, if indenting
+func (e *encodeState) newline() {
+	if !e.indented {
+		return
+	}
+	e.buf.WriteByte('\n')
+	e.buf.WriteString(e.prefix)
+	for i := 0; i < e.depth; i++ {
+		e.buf.WriteString(e.indent)
+	}
+}
+
+func (e *encodeState) encode(v interface{}) error {
+	if e.marshalers != nil {
+		if fn, ok := e.marshalers[reflect.TypeOf(v)]; ok {
+			b, err := e.marshalWith(fn, v)
+			if err != nil {
+				return fmt.Errorf(`failed to marshal JSON: %w`, err)
+			}
+			return e.writeEncoded(b)
+		}
+	}
+
+	switch v := v.(type) {
+	case rawValue:
+		if e.lazy != nil {
+			decoded, err := resolveAll(v, e.lazy)
+			if err != nil {
+				return fmt.Errorf(`failed to decode deferred value: %w`, err)
+			}
+			return e.encode(decoded)
+		}
+	case map[string]interface{}:
+		keys := e.mapKeys(v)
+		e.buf.WriteByte('{')
+		e.depth++
+		var n int
+		for _, key := range keys {
+			if e.omitEmpty && e.isEmpty(v[key]) {
+				continue
+			}
+			if n > 0 {
+				e.buf.WriteByte(',')
+			}
+			n++
+			e.newline()
+			if err := e.encode(key); err != nil {
+				return err
+			}
+			e.buf.WriteByte(':')
+			if e.indented {
+				e.buf.WriteByte(' ')
+			}
+			if err := e.encode(v[key]); err != nil {
+				return err
+			}
+			if err := e.flush(false); err != nil {
+				return err
+			}
+		}
+		e.depth--
+		if n > 0 {
+			e.newline()
+		}
+		e.buf.WriteByte('}')
+		return nil
+	case []interface{}:
+		e.buf.WriteByte('[')
+		e.depth++
+		if e.parallelism > 1 && e.w == nil && len(v) >= parallelThreshold {
+			if err := e.encodeParallel(v); err != nil {
+				return err
+			}
+		} else if err := e.encodeElements(v, 0); err != nil {
+			return err
+		}
+		e.depth--
+		if len(v) > 0 {
+			e.newline()
+		}
+		e.buf.WriteByte(']')
+		return nil
+	case string:
+		e.writeString(v)
+		return nil
+	case time.Time:
+		if e.timeFormat != nil {
+			e.writeTime(v, e.timeFormat)
+			return nil
+		}
+	case *time.Time:
+		if e.timeFormat != nil && v != nil {
+			e.writeTime(*v, e.timeFormat)
+			return nil
+		}
+	case bool:
+		if v {
+			e.buf.WriteString("true")
+		} else {
+			e.buf.WriteString("false")
+		}
+		return nil
+	case nil:
+		e.buf.WriteString("null")
+		return nil
+	case stdlib.Number:
+		if e.nonFinite {
+			switch v {
+			case "NaN", "Infinity", "-Infinity":
+				e.buf.WriteString(string(v))
+				return nil
+			}
+		}
+		if v == "" {
+			v = "0"
+		}
+		if !isValidNumber(string(v)) {
+			return fmt.Errorf(`failed to marshal JSON: invalid number literal %q`, string(v))
+		}
+		e.buf.WriteString(string(v))
+		return nil
+	case float64:
+		if lit, ok := nonFiniteLiteral(v); ok && e.nonFinite {
+			e.buf.WriteString(lit)
+			return nil
+		}
+	case float32:
+		if lit, ok := nonFiniteLiteral(float64(v)); ok && e.nonFinite {
+			e.buf.WriteString(lit)
+			return nil
+		}
+	}
+
+	b, err := e.marshal(v)
+	if err != nil {
+		return fmt.Errorf(`failed to marshal JSON: %w`, err)
+	}
+	return e.writeEncoded(b)
+}
+
+// encodeElements writes the elements of l, which start at the given
+// index of the array, along with the separators preceding them
+func (e *encodeState) encodeElements(l []interface{}, index int) error {
+	for i, elem := range l {
+		if index+i > 0 {
+			e.buf.WriteByte(',')
+		}
+		e.newline()
+		if err := e.encode(elem); err != nil {
+			return err
+		}
+		if err := e.flush(false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parallelThreshold is the minimum number of elements of arrays that
+// are encoded in parallel. See WithParallelism
+const parallelThreshold = 1024
+
+// encodeParallel writes the elements of l in the same manner as
+// encodeElements, by splitting them into chunks that are encoded by
+// separate goroutines into their own buffers
+func (e *encodeState) encodeParallel(l []interface{}) error {
+	// more chunks than goroutines, so that a goroutine that is done
+	// early can take over some of the work of the others
+	chunks := make([]*encodeState, e.parallelism*4)
+	size := (len(l) + len(chunks) - 1) / len(chunks)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(chunks))
+	next := make(chan int, len(chunks))
+	for i := range chunks {
+		next <- i
+	}
+	close(next)
+	for range e.parallelism {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				lo := min(i*size, len(l))
+				hi := min(lo+size, len(l))
+				chunks[i] = e.fork()
+				errs[i] = chunks[i].encodeElements(l[lo:hi], lo)
+			}
+		}()
+	}
+	wg.Wait()
+	defer func() {
+		for _, chunk := range chunks {
+			releaseEncodeState(chunk)
+		}
+	}()
+
+	for i, chunk := range chunks {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		e.buf.Write(chunk.buf.Bytes())
+	}
+	return nil
+}
+
+// fork returns a new encodeState with the same settings as e, which
+// writes to a buffer of its own, and never encodes in parallel
+func (e *encodeState) fork() *encodeState {
+	f := getEncodeState()
+	f.nonFinite = e.nonFinite
+	f.order = e.order
+	f.timeFormat = e.timeFormat
+	f.marshalers = e.marshalers
+	f.lazy = e.lazy
+	f.compare = e.compare
+	f.escapeHTML = e.escapeHTML
+	f.omitEmpty = e.omitEmpty
+	f.indented = e.indented
+	f.prefix = e.prefix
+	f.indent = e.indent
+	f.depth = e.depth
+	return f
+}
+
+// writeEncoded writes b, which holds the encoding of a value produced
+// outside of encodeState, indenting it if necessary
+func (e *encodeState) writeEncoded(b []byte) error {
+	if !e.indented {
+		e.buf.Write(b)
+		return nil
+	}
+
+	// values that are not part of the document tree (such as structs)
+	// are indented as if they were nested at the current depth
+	prefix := e.prefix + strings.Repeat(e.indent, e.depth)
+	if err := stdlib.Indent(&e.buf, b, prefix, e.indent); err != nil {
+		return fmt.Errorf(`failed to indent JSON: %w`, err)
+	}
+	return nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// writeString writes s as a JSON string, escaping it in the same
+// manner as encoding/json
+func (e *encodeState) writeString(s string) {
+	e.buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && (!e.escapeHTML || (c != '<' && c != '>' && c != '&')) {
+				i++
+				continue
+			}
+			e.buf.WriteString(s[start:i])
+			switch c {
+			case '"', '\\':
+				e.buf.WriteByte('\\')
+				e.buf.WriteByte(c)
+			case '\b':
+				e.buf.WriteString(`\b`)
+			case '\f':
+				e.buf.WriteString(`\f`)
+			case '\n':
+				e.buf.WriteString(`\n`)
+			case '\r':
+				e.buf.WriteString(`\r`)
+			case '\t':
+				e.buf.WriteString(`\t`)
+			default:
+				e.buf.WriteString(`\u00`)
+				e.buf.WriteByte(hexDigits[c>>4])
+				e.buf.WriteByte(hexDigits[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			e.buf.WriteString(s[start:i])
+			e.buf.WriteString("\ufffd")
+		case r == '\u2028' || r == '\u2029':
+			// these are valid in JSON, but not in JavaScript
+			e.buf.WriteString(s[start:i])
+			e.buf.WriteString(`\u202`)
+			e.buf.WriteByte(hexDigits[r&0xF])
+		default:
+			i += size
+			continue
+		}
+		i += size
+		start = i
+	}
+	e.buf.WriteString(s[start:])
+	e.buf.WriteByte('"')
+}
+
+// isValidNumber reports whether s is a valid JSON number literal
+func isValidNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	if s[0] == '-' {
+		s = s[1:]
+		if s == "" {
+			return false
+		}
+	}
+
+	switch {
+	case s[0] == '0':
+		s = s[1:]
+	case '1' <= s[0] && s[0] <= '9':
+		s = s[1:]
+		for len(s) > 0 && isDigit(s[0]) {
+			s = s[1:]
+		}
+	default:
+		return false
+	}
+
+	if len(s) >= 2 && s[0] == '.' && isDigit(s[1]) {
+		s = s[2:]
+		for len(s) > 0 && isDigit(s[0]) {
+			s = s[1:]
+		}
+	}
+
+	if len(s) >= 2 && (s[0] == 'e' || s[0] == 'E') {
+		s = s[1:]
+		if s[0] == '+' || s[0] == '-' {
+			s = s[1:]
+			if s == "" {
+				return false
+			}
+		}
+		for len(s) > 0 && isDigit(s[0]) {
+			s = s[1:]
+		}
+	}
+	return s == ""
+}
+
+// marshal encodes v using encoding/json
+func (e *encodeState) marshal(v interface{}) ([]byte, error) {
+	if e.escapeHTML {
+		return stdlib.Marshal(v)
+	}
+
+	e.scratch.Reset()
+	enc := stdlib.NewEncoder(&e.scratch)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	// remove the newline added by Encode
+	return bytes.TrimSuffix(e.scratch.Bytes(), []byte{'\n'}), nil
+}
+
+func nonFiniteLiteral(f float64) (string, bool) {
+	switch {
+	case math.IsNaN(f):
+		return "NaN", true
+	case math.IsInf(f, 1):
+		return "Infinity", true
+	case math.IsInf(f, -1):
+		return "-Infinity", true
+	}
+	return "", false
+}
@@ -0,0 +1,43 @@
+package json
+
+import (
+	stdlib "encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// marshalFunc is a function specified via WithMarshalFunc, which
+// encodes the values of typ
+type marshalFunc struct {
+	typ reflect.Type
+	fn  func(interface{}) ([]byte, error)
+}
+
+// marshalFuncs holds the functions specified via WithMarshalFunc,
+// keyed by the type of the values that they encode
+type marshalFuncs map[reflect.Type]func(interface{}) ([]byte, error)
+
+// with returns a copy of m that also holds f. m itself is not
+// modified, as it may be shared by other Contexts
+func (m marshalFuncs) with(f marshalFunc) marshalFuncs {
+	m2 := make(marshalFuncs, len(m)+1)
+	for typ, fn := range m {
+		m2[typ] = fn
+	}
+	m2[f.typ] = f.fn
+	return m2
+}
+
+// marshalWith encodes v using fn, and validates the result in the
+// same manner as encoding/json does for json.Marshaler
+func (e *encodeState) marshalWith(fn func(interface{}) ([]byte, error), v interface{}) ([]byte, error) {
+	b, err := fn(v)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to marshal value of type %T: %w`, v, err)
+	}
+	e.scratch.Reset()
+	if err := stdlib.Compact(&e.scratch, b); err != nil {
+		return nil, fmt.Errorf(`invalid JSON returned for value of type %T: %w`, v, err)
+	}
+	return e.scratch.Bytes(), nil
+}
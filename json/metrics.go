@@ -0,0 +1,83 @@
+package json
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// Metrics receives measurements of the work done by this package, so
+// that they can be exported to a monitoring system such as
+// OpenTelemetry or Prometheus, for example by recording ParseStats in
+// histograms and counting access errors by kind. See WithMetrics.
+//
+// The methods are called synchronously by the goroutine doing the
+// work, and must be safe for concurrent use if the same Metrics are
+// given to documents used by several goroutines. Implementations may
+// embed NopMetrics, so that they keep compiling if methods are added
+type Metrics interface {
+	// ObserveParse is called once parsing of a document has completed,
+	// whether it succeeded or not
+	ObserveParse(ParseStats)
+	// ObserveAccessError is called when an operation applied to a
+	// Context fails, such as MapIndex on a missing field or String on a
+	// number. Errors are only reported by the Context that the failed
+	// operation was applied to, and not by the invalid Contexts that
+	// propagate them
+	ObserveAccessError(*AccessError)
+}
+
+// ParseStats describes the parsing of a document
+type ParseStats struct {
+	// Size is the number of bytes read from the input, before
+	// decompression. When parsing from an io.Reader, it may include
+	// input beyond the end of the document that was read ahead
+	Size int64
+	// Duration is the time spent parsing the document, excluding the
+	// time spent decoding values that were deferred by WithLazy or
+	// WithProjection
+	Duration time.Duration
+	// Err is the error returned by the parser, if any
+	Err error
+}
+
+// NopMetrics implements Metrics by discarding all measurements
+type NopMetrics struct{}
+
+func (NopMetrics) ObserveParse(ParseStats)         {}
+func (NopMetrics) ObserveAccessError(*AccessError) {}
+
+// countingReader counts the number of bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// observeParse wraps r to measure the parsing of a document, and
+// returns the function reporting the measurements to m once parsing
+// has completed
+func observeParse(r io.Reader, m Metrics) (io.Reader, func(error)) {
+	start := time.Now()
+	cr := &countingReader{r: r}
+	return cr, func(err error) {
+		m.ObserveParse(ParseStats{Size: cr.n, Duration: time.Since(start), Err: err})
+	}
+}
+
+// observeError reports err to the Metrics of c, if any, and returns it
+func (c *ctx) observeError(err error) error {
+	if c.metrics == nil {
+		return err
+	}
+	var ae *AccessError
+	if errors.As(err, &ae) {
+		c.metrics.ObserveAccessError(ae)
+	}
+	return err
+}
@@ -0,0 +1,165 @@
+package json
+
+import (
+	stdlib "encoding/json"
+	"math"
+	"reflect"
+)
+
+// Number is the interface that must be implemented by custom
+// representations of JSON numbers. See WithNumberHook
+type Number interface {
+	stdlib.Marshaler
+	Float64() (float64, error)
+	Int64() (int64, error)
+}
+
+// NumberHook creates the value used to represent a JSON number, given
+// its literal form in the input (e.g. "1.50"). See WithNumberHook
+type NumberHook func(literal string) (Number, error)
+
+// toFloat64 converts a numeric value held by a Context into a float64.
+// Parsed numbers are either json.Number (from encoding/json) or float64
+// depending on the parse options, but values set by the user may be
+// of any numeric type, including custom Number types
+func toFloat64(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case stdlib.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return 0, newAccessError(ErrTypeMismatch, `failed to convert json.Number into float64: %w`, err)
+		}
+		return f, nil
+	case Number:
+		f, err := v.Float64()
+		if err != nil {
+			return 0, newAccessError(ErrTypeMismatch, `failed to convert %T into float64: %w`, v, err)
+		}
+		return f, nil
+	case float64:
+		return v, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	}
+	return 0, valueTypeError(NumberValue, "", v, `cannot convert to float64`)
+}
+
+// toInt64 converts a numeric value held by a Context into an int64.
+// See toFloat64 for the types of values that are accepted
+func toInt64(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case stdlib.Number:
+		i, err := v.Int64()
+		if err != nil {
+			return 0, newAccessError(ErrTypeMismatch, `failed to convert json.Number into int: %w`, err)
+		}
+		return i, nil
+	case Number:
+		i, err := v.Int64()
+		if err != nil {
+			return 0, newAccessError(ErrTypeMismatch, `failed to convert %T into int: %w`, v, err)
+		}
+		return i, nil
+	case int64:
+		return v, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := rv.Uint()
+		if u > math.MaxInt64 {
+			return 0, newAccessError(ErrTypeMismatch, `failed to convert %d into int: value out of range`, u)
+		}
+		return int64(u), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, newAccessError(ErrTypeMismatch, `failed to convert %v into int: not an integer in range`, f)
+		}
+		return int64(f), nil
+	}
+	return 0, valueTypeError(NumberValue, "", v, `cannot convert to int`)
+}
+
+// convertValue converts v into a value of type t, which v must be
+// convertible to. If strict is true, numbers whose value would be
+// changed by the conversion are rejected (see WithStrictNumbers)
+func convertValue(v reflect.Value, t reflect.Type, strict bool) (reflect.Value, error) {
+	cv := v.Convert(t)
+	if strict && !preservesNumber(v, cv) {
+		return reflect.Value{}, newAccessError(ErrTypeMismatch, `cannot convert %v to %s without changing its value`, v.Interface(), t)
+	}
+	return cv, nil
+}
+
+// preservesNumber reports whether cv, which was converted from v,
+// holds the same number as v. Floating point numbers may be rounded,
+// as long as they do not overflow. Values other than numbers are
+// always preserved
+func preservesNumber(v, cv reflect.Value) bool {
+	switch cv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := cv.Int()
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return i == v.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return i >= 0 && uint64(i) == v.Uint()
+		case reflect.Float32, reflect.Float64:
+			return float64(i) == v.Float()
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := cv.Uint()
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return v.Int() >= 0 && u == uint64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return u == v.Uint()
+		case reflect.Float32, reflect.Float64:
+			return float64(u) == v.Float()
+		}
+	case reflect.Float32, reflect.Float64:
+		f := cv.Float()
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return isExactInt(f, v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return f >= 0 && f < math.MaxUint64 && uint64(f) == v.Uint()
+		case reflect.Float32, reflect.Float64:
+			return !math.IsInf(f, 0) || math.IsInf(v.Float(), 0)
+		}
+	}
+	return true
+}
+
+// isExactInt reports whether f holds exactly the integer i
+func isExactInt(f float64, i int64) bool {
+	// float64(math.MaxInt64) is 2^63, which is out of range
+	return f >= math.MinInt64 && f < math.MaxInt64 && int64(f) == i
+}
+
+// float converts the value pointed by c into a float64. If
+// WithStrictNumbers was specified, integers that cannot be represented
+// exactly are rejected
+func (c *ctx) float() (float64, error) {
+	v := c.interfaceValue()
+	f, err := toFloat64(v)
+	if err != nil || !c.strictNumbers {
+		return f, err
+	}
+	if i, err := toInt64(v); err == nil && !isExactInt(f, i) {
+		return 0, newAccessError(ErrTypeMismatch, `cannot convert %v to float64 without changing its value`, v)
+	}
+	return f, nil
+}
@@ -0,0 +1,84 @@
+package json
+
+import "reflect"
+
+// ChangeFunc is the type of the functions registered by OnChange. path
+// is the path of the modified value relative to the Context that the
+// function was registered on, in the notation used by Walk. old points
+// to the previous value, and is nil if a field was added, and new
+// points to the value that replaced it
+type ChangeFunc func(path string, old, new Context)
+
+// changeObserver is a function registered by OnChange, which is
+// identified by its address so that it can be unregistered
+type changeObserver struct {
+	fn ChangeFunc
+}
+
+func (c *ctx) OnChange(fn ChangeFunc) func() {
+	o := &changeObserver{fn: fn}
+	c.observers = append(c.observers, o)
+	return func() {
+		for i, registered := range c.observers {
+			if registered == o {
+				c.observers = append(c.observers[:i:i], c.observers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// notifyChange calls the functions registered by OnChange on c and on
+// the Contexts that it was derived from, after the value found at the
+// path described by segments relative to c was replaced. old is nil
+// if the value was added
+func (c *ctx) notifyChange(segments []pathSegment, old, new *ctx) {
+	for x := c; x != nil; x = x.parent {
+		if len(x.observers) > 0 {
+			path := formatPath(segments)
+			// observers may unregister themselves while being notified
+			for _, o := range append([]*changeObserver(nil), x.observers...) {
+				if old == nil {
+					o.fn(path, nil, new)
+				} else {
+					o.fn(path, old, new)
+				}
+			}
+		}
+		if x.parent == nil {
+			break
+		}
+		seg := pathSegment{index: x.index, isIndex: !x.inMap}
+		if x.inMap {
+			seg = pathSegment{key: x.key}
+		}
+		segments = append([]pathSegment{seg}, segments...)
+	}
+}
+
+// observed reports whether a function registered by OnChange needs to
+// be notified of the changes made via c
+func (c *ctx) observed() bool {
+	for x := c; x != nil; x = x.parent {
+		if len(x.observers) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// currentValue returns the value pointed by c as currently held by its
+// container, which differs from the value of c once Set has been called
+func (c *ctx) currentValue() interface{} {
+	if c.parent == nil {
+		return c.interfaceValue()
+	}
+	if c.inMap {
+		v := c.parent.value.MapIndex(reflect.ValueOf(c.key))
+		if !v.IsValid() {
+			return nil
+		}
+		return v.Interface()
+	}
+	return c.parent.value.Index(c.index).Interface()
+}
@@ -0,0 +1,620 @@
+package json
+
+import (
+	"net/http"
+	"reflect"
+	"time"
+)
+
+const (
+	optKeyMaxLineSize          = `optkey-max-line-size`
+	optKeyMaxSize              = `optkey-max-size`
+	optKeyLazy                 = `optkey-lazy`
+	optKeyPollInterval         = `optkey-poll-interval`
+	optKeyUseNumber            = `optkey-use-number`
+	optKeyMaxDepth             = `optkey-max-depth`
+	optKeyMaxStringLength      = `optkey-max-string-length`
+	optKeyMaxArrayLength       = `optkey-max-array-length`
+	optKeyMaxObjectKeys        = `optkey-max-object-keys`
+	optKeyDisallowTrailingData = `optkey-disallow-trailing-data`
+	optKeyDuplicateKeys        = `optkey-duplicate-keys`
+	optKeyComments             = `optkey-comments`
+	optKeyTrailingCommas       = `optkey-trailing-commas`
+	optKeyJSON5                = `optkey-json5`
+	optKeyNonFiniteNumbers     = `optkey-non-finite-numbers`
+	optKeyLocations            = `optkey-locations`
+	optKeyPreserveKeyOrder     = `optkey-preserve-key-order`
+	optKeyIntDecoding          = `optkey-int-decoding`
+	optKeyNumberHook           = `optkey-number-hook`
+	optKeyDecompression        = `optkey-decompression`
+	optKeyDecompressor         = `optkey-decompressor`
+	optKeyKeyOrder             = `optkey-key-order`
+	optKeyKeyComparator        = `optkey-key-comparator`
+	optKeyEscapeHTML           = `optkey-escape-html`
+	optKeyIndent               = `optkey-indent`
+	optKeyColor                = `optkey-color`
+	optKeyTypeAnnotations      = `optkey-type-annotations`
+	optKeyTimeLayout           = `optkey-time-layout`
+	optKeyTimeEpoch            = `optkey-time-epoch`
+	optKeyOmitEmpty            = `optkey-omit-empty`
+	optKeyMarshalFunc          = `optkey-marshal-func`
+	optKeyMissingVariables     = `optkey-missing-variables`
+	optKeyRequest              = `optkey-request`
+	optKeyArena                = `optkey-arena`
+	optKeyInternKeys           = `optkey-intern-keys`
+	optKeyKeyTable             = `optkey-key-table`
+	optKeyUnsafeStrings        = `optkey-unsafe-strings`
+	optKeyParallelism          = `optkey-parallelism`
+	optKeyStrictNumbers        = `optkey-strict-numbers`
+	optKeyProjection           = `optkey-projection`
+	optKeyFloatTolerance       = `optkey-float-tolerance`
+	optKeyIgnoreArrayOrder     = `optkey-ignore-array-order`
+	optKeyIgnoredPaths         = `optkey-ignored-paths`
+	optKeyMissingGroupKeys     = `optkey-missing-group-keys`
+	optKeyDuplicateGroupKeys   = `optkey-duplicate-group-keys`
+	optKeyMetrics              = `optkey-metrics`
+)
+
+type Option interface {
+	Name() string
+	Value() interface{}
+}
+
+type option struct {
+	name  string
+	value interface{}
+}
+
+func (o option) Name() string {
+	return o.name
+}
+
+func (o option) Value() interface{} {
+	return o.value
+}
+
+// LinesOption is an Option that configures how newline-delimited JSON
+// is read. LinesOptions can be passed to ParseLines and TailFile.
+// ParseOptions are also LinesOptions, and are used to parse each record
+type LinesOption interface {
+	Option
+	linesOption()
+}
+
+type linesOption struct {
+	Option
+}
+
+func (*linesOption) linesOption() {}
+
+func newLinesOption(name string, value interface{}) LinesOption {
+	return &linesOption{Option: &option{name: name, value: value}}
+}
+
+// ParseOption is an Option that configures how JSON is parsed.
+// ParseOptions can be passed to Parse and its variants
+type ParseOption interface {
+	LinesOption
+	parseOption()
+}
+
+type parseOption struct {
+	Option
+}
+
+func (*parseOption) linesOption() {}
+func (*parseOption) parseOption() {}
+
+func newParseOption(name string, value interface{}) ParseOption {
+	return &parseOption{Option: &option{name: name, value: value}}
+}
+
+// MarshalOption is an Option that configures how JSON is generated.
+// MarshalOptions can be passed to Marshal
+type MarshalOption interface {
+	Option
+	marshalOption()
+}
+
+type marshalOption struct {
+	Option
+}
+
+func (*marshalOption) marshalOption() {}
+
+func newMarshalOption(name string, value interface{}) MarshalOption {
+	return &marshalOption{Option: &option{name: name, value: value}}
+}
+
+// DumpOption is an Option that configures the output of Dump
+type DumpOption interface {
+	Option
+	dumpOption()
+}
+
+type dumpOption struct {
+	Option
+}
+
+func (*dumpOption) dumpOption() {}
+
+func newDumpOption(name string, value interface{}) DumpOption {
+	return &dumpOption{Option: &option{name: name, value: value}}
+}
+
+// ExpandOption is an Option that configures ExpandEnv and Substitute
+type ExpandOption interface {
+	Option
+	expandOption()
+}
+
+type expandOption struct {
+	Option
+}
+
+func (*expandOption) expandOption() {}
+
+func newExpandOption(name string, value interface{}) ExpandOption {
+	return &expandOption{Option: &option{name: name, value: value}}
+}
+
+// EqualOption is an Option that configures Equal and Diff
+type EqualOption interface {
+	Option
+	equalOption()
+}
+
+type equalOption struct {
+	Option
+}
+
+func (*equalOption) equalOption() {}
+
+func newEqualOption(name string, value interface{}) EqualOption {
+	return &equalOption{Option: &option{name: name, value: value}}
+}
+
+// GroupOption is an Option that configures GroupBy
+type GroupOption interface {
+	Option
+	groupOption()
+}
+
+type groupOption struct {
+	Option
+}
+
+func (*groupOption) groupOption() {}
+
+func newGroupOption(name string, value interface{}) GroupOption {
+	return &groupOption{Option: &option{name: name, value: value}}
+}
+
+// WithMaxLineSize specifies the maximum number of bytes allowed in
+// a single line when reading newline-delimited JSON
+func WithMaxLineSize(n int) LinesOption {
+	return newLinesOption(optKeyMaxLineSize, n)
+}
+
+// WithMaxSize specifies the maximum number of bytes that may be
+// read from the input when parsing JSON. When the limit is exceeded,
+// parsing is aborted with an error wrapping ErrLimitExceeded
+func WithMaxSize(n int64) ParseOption {
+	return newParseOption(optKeyMaxSize, n)
+}
+
+// WithInternKeys specifies that each distinct object key should be
+// stored only once per document, instead of once per object that holds
+// it. This greatly reduces the memory held by documents such as arrays
+// of objects that share the same keys. See also WithKeyTable
+func WithInternKeys(b bool) ParseOption {
+	return newParseOption(optKeyInternKeys, b)
+}
+
+// WithKeyTable specifies that object keys should be looked up in and
+// added to t, so that documents parsed with the same KeyTable share
+// their keys. It takes precedence over WithInternKeys
+func WithKeyTable(t *KeyTable) ParseOption {
+	return newParseOption(optKeyKeyTable, t)
+}
+
+// WithKeyComparator specifies that the fields of JSON objects should
+// be emitted in the order determined by cmp, which must return a
+// negative number if a sorts before b, a positive number if a sorts
+// after b, and zero otherwise. It takes precedence over WithKeyOrder
+func WithKeyComparator(cmp func(a, b string) int) MarshalOption {
+	return newMarshalOption(optKeyKeyComparator, cmp)
+}
+
+// WithKeyOrder specifies the order in which the fields of JSON
+// objects should be emitted. By default, the fields of Contexts
+// created with WithPreserveKeyOrder are emitted in their original
+// order, and all other fields in lexical order
+func WithKeyOrder(order KeyOrder) MarshalOption {
+	return newMarshalOption(optKeyKeyOrder, order)
+}
+
+// WithArena specifies that the strings, numbers, and arrays of the
+// document should be allocated from a few large chunks of memory,
+// instead of one by one. This reduces the time spent allocating and
+// collecting garbage when parsing large documents.
+//
+// The memory is reclaimed by the garbage collector as usual, unless
+// Release is called on the Context, in which case it is reused for
+// the documents parsed afterwards. See Context.Release for the
+// restrictions that this places on the caller
+func WithArena(b bool) ParseOption {
+	return newParseOption(optKeyArena, b)
+}
+
+// WithLazy specifies that nested objects and arrays should not be
+// decoded until they are accessed through a Context. Until then, their
+// raw bytes reference the original input, which must not be modified
+// while the Context is in use
+func WithLazy(b bool) ParseOption {
+	return newParseOption(optKeyLazy, b)
+}
+
+// WithLocations specifies that the location of each value in the
+// input should be recorded, so that it can be retrieved by calling
+// Location on the Context pointing to the value.
+//
+// WithLazy and WithProjection are ignored when this option is specified
+func WithLocations(b bool) ParseOption {
+	return newParseOption(optKeyLocations, b)
+}
+
+// WithMarshalFunc specifies that values of type T stored in the
+// document (for example via Set) should be marshaled by calling fn,
+// instead of in the manner of encoding/json. This allows types that
+// cannot be modified to implement json.Marshaler, such as those from
+// third-party packages, to be encoded as desired. fn must return valid
+// JSON. It is not called for values nested in other Go values, such as
+// the fields of structs.
+//
+// This option can be specified multiple times for different types,
+// and is also accepted by New
+func WithMarshalFunc[T any](fn func(T) ([]byte, error)) ParseOption {
+	return newParseOption(optKeyMarshalFunc, marshalFunc{
+		typ: reflect.TypeFor[T](),
+		fn: func(v interface{}) ([]byte, error) {
+			return fn(v.(T))
+		},
+	})
+}
+
+// WithMaxDepth specifies the maximum nesting depth of objects and
+// arrays allowed in the input. When the limit is exceeded, parsing
+// is aborted with an error wrapping ErrLimitExceeded.
+// By default there is no limit
+func WithMaxDepth(n int) ParseOption {
+	return newParseOption(optKeyMaxDepth, n)
+}
+
+// WithMaxStringLength specifies the maximum number of bytes allowed
+// in a single string or object key. When the limit is exceeded, parsing
+// is aborted with an error wrapping ErrLimitExceeded.
+// By default there is no limit
+func WithMaxStringLength(n int) ParseOption {
+	return newParseOption(optKeyMaxStringLength, n)
+}
+
+// WithMaxArrayLength specifies the maximum number of elements allowed
+// in a single array. When the limit is exceeded, parsing is aborted
+// with an error wrapping ErrLimitExceeded. By default there is no limit
+func WithMaxArrayLength(n int) ParseOption {
+	return newParseOption(optKeyMaxArrayLength, n)
+}
+
+// WithMaxObjectKeys specifies the maximum number of keys allowed
+// in a single object. When the limit is exceeded, parsing is aborted
+// with an error wrapping ErrLimitExceeded. By default there is no limit
+func WithMaxObjectKeys(n int) ParseOption {
+	return newParseOption(optKeyMaxObjectKeys, n)
+}
+
+// WithMissingVariables specifies how ExpandEnv and Substitute handle
+// placeholders referring to undefined variables. By default, an invalid
+// Context wrapping ErrMissingVariable is returned
+func WithMissingVariables(policy MissingVariablePolicy) ExpandOption {
+	return newExpandOption(optKeyMissingVariables, policy)
+}
+
+// WithMetrics specifies the Metrics that receive the measurements of
+// the parsing of the document, and of the failures of the operations
+// applied to the resulting Context and the Contexts derived from it.
+// Parsing is measured by Parse, ParseString, ParseReader, ParseFile,
+// and ParseRequest. This option is also accepted by New
+func WithMetrics(m Metrics) ParseOption {
+	return newParseOption(optKeyMetrics, m)
+}
+
+// WithMissingGroupKeys specifies how GroupBy handles the elements that
+// do not hold a value at the path of the key. By default, an error
+// wrapping ErrKeyNotFound is returned
+func WithMissingGroupKeys(policy MissingGroupKeyPolicy) GroupOption {
+	return newGroupOption(optKeyMissingGroupKeys, policy)
+}
+
+// WithColor specifies whether Dump should highlight the output
+// using ANSI escape sequences, for display on a terminal.
+// The default is false
+func WithColor(b bool) DumpOption {
+	return newDumpOption(optKeyColor, b)
+}
+
+// WithComments specifies that `//` line comments and `/* */` block
+// comments are allowed in the input, as in JSONC. Comments are discarded
+func WithComments(b bool) ParseOption {
+	return newParseOption(optKeyComments, b)
+}
+
+// WithDecompression specifies that gzip compressed input should be
+// detected by its magic bytes, and transparently decompressed.
+// Input that is not compressed is parsed as usual. When WithMaxSize
+// is also specified, the limit applies to the decompressed input.
+//
+// This option is honored by Parse, ParseString, ParseReader, and ParseFile
+func WithDecompression(b bool) ParseOption {
+	return newParseOption(optKeyDecompression, b)
+}
+
+// WithDecompressor specifies that input starting with magic should be
+// decompressed using fn. It can be used to support compression formats
+// other than gzip, for example zstd (whose magic bytes are
+// "\x28\xb5\x2f\xfd"). Decompressors are tried in the order in which
+// they are specified. See also WithDecompression
+func WithDecompressor(magic []byte, fn DecompressFunc) ParseOption {
+	return newParseOption(optKeyDecompressor, decompressor{magic: magic, fn: fn})
+}
+
+// WithDisallowTrailingData specifies that the input must not contain
+// anything but whitespace after the first JSON value. By default,
+// any data following the first value is ignored.
+//
+// This option is only honored by Parse, ParseString, and ParseReader
+func WithDisallowTrailingData(b bool) ParseOption {
+	return newParseOption(optKeyDisallowTrailingData, b)
+}
+
+// WithDuplicateKeys specifies how duplicate keys in JSON objects are
+// handled. By default, the value of the last occurrence of a key wins
+func WithDuplicateKeys(policy DuplicateKeyPolicy) ParseOption {
+	return newParseOption(optKeyDuplicateKeys, policy)
+}
+
+// WithDuplicateGroupKeys specifies how GroupBy handles elements that
+// hold the same key. By default, they are collected in an array
+func WithDuplicateGroupKeys(policy DuplicateGroupKeyPolicy) GroupOption {
+	return newGroupOption(optKeyDuplicateGroupKeys, policy)
+}
+
+// WithEscapeHTML specifies whether problematic HTML characters
+// (`<`, `>`, and `&`) should be escaped inside JSON strings, as
+// encoding/json does by default. The default is true
+func WithEscapeHTML(b bool) MarshalOption {
+	return newMarshalOption(optKeyEscapeHTML, b)
+}
+
+// WithFloatTolerance specifies that Equal and Diff should consider two
+// numbers equal if they differ by no more than tolerance. By default,
+// numbers must be exactly equal
+func WithFloatTolerance(tolerance float64) EqualOption {
+	return newEqualOption(optKeyFloatTolerance, tolerance)
+}
+
+// WithIgnoreArrayOrder specifies that Equal and Diff should consider
+// two arrays equal if their elements can be matched in any order
+func WithIgnoreArrayOrder(b bool) EqualOption {
+	return newEqualOption(optKeyIgnoreArrayOrder, b)
+}
+
+// WithIgnoredPaths specifies values that Equal and Diff should not
+// compare, as paths in the notation used by Walk, where `[*]` stands
+// for every element of an array (such as `$.items[*].updatedAt`). A
+// value found at one of these paths may differ, or be missing from
+// either document. When combined with WithIgnoreArrayOrder, indices of
+// elements are not meaningful, and only `[*]` applies to arrays. This
+// option may be specified more than once. If a path is invalid, the
+// documents are reported as not equal, and Diff fails
+func WithIgnoredPaths(paths ...string) EqualOption {
+	return newEqualOption(optKeyIgnoredPaths, paths)
+}
+
+// WithIndent specifies that the output should be formatted in the
+// same manner as encoding/json.MarshalIndent, using prefix and indent
+func WithIndent(prefix, indent string) MarshalOption {
+	return newMarshalOption(optKeyIndent, [2]string{prefix, indent})
+}
+
+// WithIntDecoding specifies how JSON numbers that have neither a
+// fraction nor an exponent (such as `42`) are decoded. By default,
+// they are decoded in the same manner as any other number, as
+// specified by WithUseNumber
+func WithIntDecoding(policy IntDecoding) ParseOption {
+	return newParseOption(optKeyIntDecoding, policy)
+}
+
+// WithJSON5 specifies that the input is parsed as JSON5. In addition
+// to comments and trailing commas, JSON5 allows unquoted object keys,
+// single-quoted and multi-line strings, and hexadecimal numbers.
+// The resulting Context is the same as if the equivalent JSON had
+// been parsed: for example, `0x10` becomes the number 16
+func WithJSON5(b bool) ParseOption {
+	return newParseOption(optKeyJSON5, b)
+}
+
+// WithNonFiniteNumbers specifies that the non-standard literals `NaN`,
+// `Infinity`, and `-Infinity` are accepted as numbers, as produced by
+// Python's json module. They are decoded as the json.Number values
+// "NaN", "Infinity", and "-Infinity" (or the corresponding float64
+// values if WithUseNumber(false) is specified).
+//
+// Contexts obtained from Parse with this option also emit the same
+// literals for non-finite numbers from MarshalJSON, instead of failing.
+// This option is also accepted by New, in which case only the latter
+// applies
+func WithNonFiniteNumbers(b bool) ParseOption {
+	return newParseOption(optKeyNonFiniteNumbers, b)
+}
+
+// WithNumberHook specifies a function that creates the values used to
+// represent JSON numbers, instead of json.Number. This allows numbers
+// to be decoded into a custom type, such as an exact decimal type.
+// Float and Int call the Float64 and Int64 methods of the resulting
+// values, and they are marshaled by calling their MarshalJSON method.
+//
+// When this option is specified, WithUseNumber and WithIntDecoding
+// are ignored. With WithLazy or WithProjection, fn is called when the
+// document is parsed and again when deferred values are decoded, so
+// it may be called more than once for the same number
+func WithNumberHook(fn NumberHook) ParseOption {
+	return newParseOption(optKeyNumberHook, fn)
+}
+
+// WithOmitEmpty specifies that the fields of JSON objects whose values
+// are empty should be omitted from the output, in the same manner as
+// the `omitempty` struct tag of encoding/json. Values are empty if they
+// are null, empty strings, empty arrays, or objects whose fields are
+// all empty. Elements of arrays are never omitted, and the Context
+// itself is not modified
+func WithOmitEmpty(b bool) MarshalOption {
+	return newMarshalOption(optKeyOmitEmpty, b)
+}
+
+// WithParallelism specifies that the elements of large JSON arrays
+// should be encoded by n goroutines in parallel, which speeds up the
+// marshaling of documents holding many elements on multi-core
+// machines. If n is 0 or less, runtime.GOMAXPROCS(0) goroutines are
+// used. Arrays nested within an array that is encoded in parallel are
+// encoded sequentially. The output is the same as without this option.
+//
+// This option is ignored by the methods that write the output as it is
+// generated, such as WriteTo
+func WithParallelism(n int) MarshalOption {
+	return newMarshalOption(optKeyParallelism, n)
+}
+
+// WithPreserveKeyOrder specifies that the fields of JSON objects
+// should be marshaled and iterated over in the order in which they
+// appeared in the input, instead of in lexical order. Fields added
+// via SetMapIndex are placed after the existing ones. Objects removed
+// from the document via Set or SetMapIndex may lose their order once
+// they are no longer reachable from it, even if a Context obtained
+// earlier still points to them.
+//
+// This option is also accepted by New. WithLazy and WithProjection are
+// ignored when this option is specified
+func WithPreserveKeyOrder(b bool) ParseOption {
+	return newParseOption(optKeyPreserveKeyOrder, b)
+}
+
+// WithProjection specifies the only values of the document that are
+// needed, as paths in the notation used by Walk, where `[*]` stands for
+// every element of an array (such as `$.events[*].id`). The objects and
+// arrays found outside of these paths are validated but not decoded:
+// they keep referring to their raw bytes in the input, and are decoded
+// when they are accessed, as with WithLazy. Scalar values are always
+// decoded. This option may be specified more than once, in which case
+// all the paths are needed.
+//
+// This option is ignored by GetBytes and by the functions that parse
+// streams of documents. Invalid paths are reported when parsing
+func WithProjection(paths ...string) ParseOption {
+	return newParseOption(optKeyProjection, paths)
+}
+
+// WithRequest specifies the request that Write is responding to. If
+// the request URL has a `pretty` query parameter holding a true value
+// (as understood by strconv.ParseBool), such as `?pretty=1`, the output
+// is indented. It is ignored by functions other than Write
+func WithRequest(r *http.Request) MarshalOption {
+	return newMarshalOption(optKeyRequest, r)
+}
+
+// WithStrictNumbers specifies that Int, Float, Slice, and Map fail
+// with ErrTypeMismatch if a number cannot be stored in the destination
+// without changing its value, instead of converting it silently. This
+// includes integers that overflow the destination type (such as 300
+// into an int8), negative numbers stored into unsigned integers,
+// fractions stored into integers, and integers that cannot be
+// represented exactly by a floating point destination. Floating point
+// numbers may still be rounded when stored into a float32, but must
+// not overflow it.
+//
+// Int rejects fractions regardless of this option. This option is
+// also accepted by New
+func WithStrictNumbers(b bool) ParseOption {
+	return newParseOption(optKeyStrictNumbers, b)
+}
+
+// WithTimeEpoch specifies that time.Time values stored in the document
+// (for example via Set) should be marshaled as the number of units of
+// the given precision elapsed since the Unix epoch, such as
+// time.Millisecond for epoch milliseconds. A precision that is not
+// positive is ignored.
+//
+// This option is also accepted by New. See also WithTimeLayout
+func WithTimeEpoch(precision time.Duration) ParseOption {
+	return newParseOption(optKeyTimeEpoch, precision)
+}
+
+// WithTimeLayout specifies that time.Time values stored in the document
+// (for example via Set) should be marshaled as strings formatted with
+// layout (see time.Time.Format), instead of in RFC 3339 format with
+// sub-second precision. The values are not modified.
+//
+// This option is also accepted by New. When both this option and
+// WithTimeEpoch are specified, the last one wins
+func WithTimeLayout(layout string) ParseOption {
+	return newParseOption(optKeyTimeLayout, layout)
+}
+
+// WithTrailingCommas specifies that a comma is allowed after the last
+// element of an array or the last member of an object, as in `[1,2,3,]`
+// or `{"a":1,}`
+func WithTrailingCommas(b bool) ParseOption {
+	return newParseOption(optKeyTrailingCommas, b)
+}
+
+// WithTypeAnnotations specifies whether Dump should annotate each
+// value with its type, such as `// number` or `// object, 2 fields`.
+// The annotations are written as comments, so that the output can
+// still be parsed using WithComments. The default is false
+func WithTypeAnnotations(b bool) DumpOption {
+	return newDumpOption(optKeyTypeAnnotations, b)
+}
+
+// WithUnsafeStrings specifies that strings and numbers should refer
+// to the memory of the input instead of being copied, if they appear
+// in the input verbatim, i.e. without escape sequences. This avoids
+// most allocations of string values when parsing.
+//
+// This is only safe if the input is never modified afterwards, for
+// as long as the Context or any string obtained from it, including the
+// keys of objects, is in use: modifying it changes the contents of those
+// strings, which Go assumes to be immutable. Only Parse and GetBytes
+// refer to the input. ParseLines and TailFile parse each record from
+// a buffer that they reuse, so they copy the record first when this
+// option is specified. ParseFile ignores it, as the file is unmapped
+// once the Context is no longer reachable, and ParseReader, ParseString,
+// and the other functions that read their input ignore it as well
+func WithUnsafeStrings(b bool) ParseOption {
+	return newParseOption(optKeyUnsafeStrings, b)
+}
+
+// WithUseNumber specifies whether JSON numbers should be decoded as
+// json.Number (from encoding/json), which preserves their original
+// representation. This is the default, and numbers are marshaled
+// exactly as they appeared in the input (e.g. `1.50` or `1e3`).
+// If false is specified, numbers are decoded as float64, and are
+// marshaled in the canonical form of float64 instead
+func WithUseNumber(b bool) ParseOption {
+	return newParseOption(optKeyUseNumber, b)
+}
+
+// WithPollInterval specifies the interval at which a Follower checks
+// the file for new data. It is ignored by ParseLines
+func WithPollInterval(d time.Duration) LinesOption {
+	return newLinesOption(optKeyPollInterval, d)
+}
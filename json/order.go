@@ -0,0 +1,226 @@
+package json
+
+import (
+	"reflect"
+	"sort"
+)
+
+// keyOrder records the order in which the keys of the JSON objects in
+// a document appeared in the input, or were added. Maps are looked up
+// by their pointers. See WithPreserveKeyOrder
+type keyOrder struct {
+	keys map[uintptr]*orderEntry
+	// root is the Context for the entire document, from which the maps
+	// whose entries are kept must be reachable. It is nil while the
+	// document is being built, during which no entries are removed
+	root *ctx
+	// live is the number of entries after the last sweep. The next
+	// sweep happens once the number of entries has doubled since
+	live int
+}
+
+// minOrderSweep is the number of entries below which keyOrder does not
+// bother removing the entries of unreachable maps
+const minOrderSweep = 64
+
+// orderEntry holds the keys recorded for the map m. Holding m keeps it
+// from being collected, so that its address cannot be reused by another
+// map while the entry exists. Entries are removed by sweep once the maps
+// are no longer part of the document
+type orderEntry struct {
+	m    reflect.Value
+	keys []string
+}
+
+func newKeyOrder() *keyOrder {
+	return &keyOrder{keys: make(map[uintptr]*orderEntry)}
+}
+
+// attach sets the Context for the entire document, which determines
+// the entries kept by sweep
+func (o *keyOrder) attach(root *ctx) {
+	o.root = root
+	o.live = len(o.keys)
+}
+
+// record records keys as the keys of m, in that order
+func (o *keyOrder) record(m map[string]interface{}, keys []string) {
+	rv := reflect.ValueOf(m)
+	o.keys[rv.Pointer()] = &orderEntry{m: rv, keys: keys}
+	o.maybeSweep()
+}
+
+// add appends key to the keys of the map held in rv. The caller must
+// make sure that key has not been in the map until now
+func (o *keyOrder) add(rv reflect.Value, key string) {
+	p := rv.Pointer()
+	e, ok := o.keys[p]
+	if !ok {
+		e = &orderEntry{m: rv}
+		o.keys[p] = e
+		o.maybeSweep()
+	}
+	e.keys = append(e.keys, key)
+}
+
+// recorded returns the keys recorded for the map held in rv
+func (o *keyOrder) recorded(rv reflect.Value) []string {
+	if e, ok := o.keys[rv.Pointer()]; ok {
+		return e.keys
+	}
+	return nil
+}
+
+// forget removes the entries of the maps found in old, which has been
+// dropped from the document while it is being built, and cannot be
+// referenced from elsewhere
+func (o *keyOrder) forget(old interface{}) {
+	if len(o.keys) == 0 {
+		return
+	}
+	visitMaps(reflect.ValueOf(old), func(rv reflect.Value) {
+		delete(o.keys, rv.Pointer())
+	})
+}
+
+// maybeSweep calls sweep if the number of entries has doubled since the
+// last sweep, so that the cost of visiting the document is amortized
+// over the entries created in the meantime
+func (o *keyOrder) maybeSweep() {
+	if o.root == nil || len(o.keys) < minOrderSweep || len(o.keys) < 2*o.live {
+		return
+	}
+	o.sweep()
+}
+
+// sweep removes the entries of the maps that are not reachable from
+// the document, such as those replaced by Set and SetMapIndex. Maps
+// that are still held elsewhere in the document keep their entries,
+// but those only held by Contexts obtained before they were removed
+// from the document lose it, and their keys are visited in lexical
+// order from then on
+func (o *keyOrder) sweep() {
+	reachable := make(map[uintptr]*orderEntry, o.live)
+	visitMaps(o.root.value, func(rv reflect.Value) {
+		if e, ok := o.keys[rv.Pointer()]; ok {
+			reachable[rv.Pointer()] = e
+		}
+	})
+	o.keys = reachable
+	o.live = len(reachable)
+}
+
+// size returns the approximate number of bytes allocated for the
+// entries of the maps found in the document held in rv. Entries of
+// maps that are no longer part of the document are not counted
+func (o *keyOrder) size(rv reflect.Value) int64 {
+	var n int64
+	var count int
+	visitMaps(rv, func(rv reflect.Value) {
+		e, ok := o.keys[rv.Pointer()]
+		if !ok {
+			return
+		}
+		count++
+		n += int64(reflect.TypeOf(orderEntry{}).Size()) + int64(cap(e.keys))*stringHeaderSize
+	})
+	return n + mapSize(count, 16)
+}
+
+// visitMaps calls fn for each non-nil map found in rv, including rv
+// itself. rv must not contain reference cycles
+func visitMaps(rv reflect.Value, fn func(reflect.Value)) {
+	switch rv.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if !rv.IsNil() {
+			visitMaps(rv.Elem(), fn)
+		}
+	case reflect.Map:
+		if rv.IsNil() {
+			return
+		}
+		fn(rv)
+		iter := rv.MapRange()
+		for iter.Next() {
+			visitMaps(iter.Value(), fn)
+		}
+	case reflect.Slice, reflect.Array:
+		// byte slices, such as deferred values, cannot hold maps
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		for i := 0; i < rv.Len(); i++ {
+			visitMaps(rv.Index(i), fn)
+		}
+	case reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < rv.NumField(); i++ {
+			// unexported fields cannot be reached from a Context
+			if !t.Field(i).IsExported() || t.Field(i).Tag.Get("json") == "-" {
+				continue
+			}
+			visitMaps(rv.Field(i), fn)
+		}
+	}
+}
+
+// mapKeys returns the keys of the map held in rv in the recorded order.
+// Keys that have not been recorded (for example, because the map was
+// created outside of this package) follow them in lexical order
+func (o *keyOrder) mapKeys(rv reflect.Value) []reflect.Value {
+	recorded := o.recorded(rv)
+	if len(recorded) == 0 {
+		return sortedMapKeys(rv)
+	}
+
+	keys := make([]reflect.Value, 0, rv.Len())
+	seen := make(map[string]struct{}, len(recorded))
+	for _, k := range recorded {
+		keyV := reflect.ValueOf(k)
+		// the key may have been removed from the map since
+		if !rv.MapIndex(keyV).IsValid() {
+			continue
+		}
+		keys = append(keys, keyV)
+		seen[k] = struct{}{}
+	}
+	if len(keys) == rv.Len() {
+		return keys
+	}
+
+	for _, keyV := range sortedMapKeys(rv) {
+		if _, ok := seen[keyV.String()]; !ok {
+			keys = append(keys, keyV)
+		}
+	}
+	return keys
+}
+
+// mapKeys returns the keys of the map held by c, in the order in which
+// they should be visited
+func (c *ctx) mapKeys() []reflect.Value {
+	if c.order != nil {
+		return c.order.mapKeys(c.value)
+	}
+	return sortedMapKeys(c.value)
+}
+
+// keys returns the keys of the map held by c as strings, in the order
+// in which they should be visited
+func (c *ctx) keys() []string {
+	if m, ok := c.value.Interface().(map[string]interface{}); ok && (c.order == nil || len(c.order.recorded(c.value)) == 0) {
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	keyVs := c.mapKeys()
+	keys := make([]string, len(keyVs))
+	for i, keyV := range keyVs {
+		keys[i] = keyV.String()
+	}
+	return keys
+}
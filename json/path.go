@@ -0,0 +1,139 @@
+package json
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// rootPath is the path of the top-level value of a document
+const rootPath = `$`
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// keyPath returns the path of the field named key under parent.
+// Keys that are valid identifiers use the dot notation (`$.foo`),
+// everything else uses the quoted bracket notation (`$["foo bar"]`)
+func keyPath(parent, key string) string {
+	if isIdentifier(key) {
+		return parent + "." + key
+	}
+	return parent + "[" + strconv.Quote(key) + "]"
+}
+
+// indexPath returns the path of the i-th element under parent (`$[0]`)
+func indexPath(parent string, i int) string {
+	return parent + "[" + strconv.Itoa(i) + "]"
+}
+
+// pathSegment is a single step of a path: either the field key of an
+// object, or the element index of an array
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parsePath parses a path in the notation used by Walk, such as
+// `$.items[0].name` or `$["not an identifier"]`. The leading `$` may be
+// omitted (`items[0].name`), and keys using the dot notation may
+// contain any character other than `.` and `[`
+func parsePath(path string) ([]pathSegment, error) {
+	s := path
+	switch {
+	case strings.HasPrefix(s, rootPath):
+		s = s[len(rootPath):]
+	case s != "" && s[0] != '[':
+		s = "." + s
+	}
+
+	var segments []pathSegment
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf(`invalid path %q: empty key`, path)
+			}
+			segments = append(segments, pathSegment{key: s[:end]})
+			s = s[end:]
+		case '[':
+			s = s[1:]
+			if strings.HasPrefix(s, `"`) {
+				end := quotedLength(s)
+				if end < 0 {
+					return nil, fmt.Errorf(`invalid path %q: unterminated quoted key`, path)
+				}
+				key, err := strconv.Unquote(s[:end])
+				if err != nil {
+					return nil, fmt.Errorf(`invalid path %q: invalid quoted key %s`, path, s[:end])
+				}
+				s = s[end:]
+				if !strings.HasPrefix(s, "]") {
+					return nil, fmt.Errorf(`invalid path %q: expected ']' after quoted key`, path)
+				}
+				segments = append(segments, pathSegment{key: key})
+				s = s[1:]
+				continue
+			}
+
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf(`invalid path %q: expected ']'`, path)
+			}
+			i, err := strconv.Atoi(s[:end])
+			if err != nil || i < 0 || strings.HasPrefix(s, "+") {
+				return nil, fmt.Errorf(`invalid path %q: invalid index %q`, path, s[:end])
+			}
+			segments = append(segments, pathSegment{index: i, isIndex: true})
+			s = s[end+1:]
+		default:
+			return nil, fmt.Errorf(`invalid path %q: unexpected character %q`, path, s[0])
+		}
+	}
+	return segments, nil
+}
+
+// quotedLength returns the length of the double-quoted string at the
+// beginning of s, including the quotes, or -1 if it is not terminated
+func quotedLength(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// formatPath returns the canonical notation of segments
+func formatPath(segments []pathSegment) string {
+	path := rootPath
+	for _, seg := range segments {
+		if seg.isIndex {
+			path = indexPath(path, seg.index)
+		} else {
+			path = keyPath(path, seg.key)
+		}
+	}
+	return path
+}
@@ -0,0 +1,104 @@
+package json
+
+import (
+	"fmt"
+	"reflect"
+)
+
+func (c *ctx) MapElems(fn func(Context) (interface{}, error)) Context {
+	switch c.value.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return newErrCtx(c.typeError(ArrayValue, `cannot map elements`))
+	}
+
+	n := c.value.Len()
+	list := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		elem, err := c.indexChild(i)
+		if err != nil {
+			return newErrCtx(err)
+		}
+		v, err := fn(elem)
+		if err == nil {
+			v, err = unwrapContext(v)
+		}
+		if err != nil {
+			return newErrCtx(fmt.Errorf(`failed to map element at %s: %w`, elem.location(), err))
+		}
+		list = append(list, v)
+	}
+	return c.detached(list)
+}
+
+func (c *ctx) FilterElems(fn func(Context) bool) Context {
+	switch c.value.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return newErrCtx(c.typeError(ArrayValue, `cannot filter elements`))
+	}
+
+	var list []interface{}
+	for i := 0; i < c.value.Len(); i++ {
+		elem, err := c.indexChild(i)
+		if err != nil {
+			return newErrCtx(err)
+		}
+		if fn(elem) {
+			list = append(list, elem.interfaceValue())
+		}
+	}
+	if list == nil {
+		// an empty array rather than null
+		list = []interface{}{}
+	}
+	return c.detached(list)
+}
+
+func (c *ctx) Reduce(fn func(acc, elem Context) (interface{}, error), init interface{}) Context {
+	switch c.value.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return newErrCtx(c.typeError(ArrayValue, `cannot reduce elements`))
+	}
+
+	v, err := unwrapContext(init)
+	if err != nil {
+		return newErrCtx(fmt.Errorf(`invalid initial value: %w`, err))
+	}
+	acc := c.detached(v)
+	for i := 0; i < c.value.Len(); i++ {
+		elem, err := c.indexChild(i)
+		if err != nil {
+			return newErrCtx(err)
+		}
+		v, err := fn(acc, elem)
+		if err == nil {
+			v, err = unwrapContext(v)
+		}
+		if err != nil {
+			return newErrCtx(fmt.Errorf(`failed to reduce element at %s: %w`, elem.location(), err))
+		}
+		acc = c.detached(v)
+	}
+	return acc
+}
+
+// unwrapContext returns the value pointed by v if it is a Context, so
+// that the functions given to MapElems and Reduce may return either
+// values or Contexts
+func unwrapContext(v interface{}) (interface{}, error) {
+	c, ok := v.(Context)
+	if !ok {
+		return v, nil
+	}
+	c, unlock := unwrapSync(c)
+	defer unlock()
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	if c, ok := c.(*ctx); ok {
+		return c.interfaceValue(), nil
+	}
+	return nil, fmt.Errorf(`unsupported Context (%T)`, v)
+}
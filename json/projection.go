@@ -0,0 +1,171 @@
+package json
+
+import (
+	"fmt"
+	"strings"
+)
+
+// projection is the tree of the values requested by WithProjection.
+// A nil *projection stands for a value that is needed in its entirety
+type projection struct {
+	fields   map[string]*projection
+	elements map[int]*projection
+	every    *projection // the elements requested by `[*]`
+	hasEvery bool
+}
+
+// unprojected is the projection of the values that are not needed,
+// which are not decoded until they are accessed
+var unprojected = &projection{}
+
+// wildcardSegment matches every element of an array in a projection
+const wildcardSegment = `[*]`
+
+// newProjection builds the projection of paths, which use the notation
+// of Walk, with the addition of `[*]` for every element of an array
+func newProjection(paths []string) (*projection, error) {
+	p := &projection{}
+	for _, path := range paths {
+		var segments [][]pathSegment
+		for i, s := range strings.Split(path, wildcardSegment) {
+			if i > 0 {
+				s = rootPath + s
+			}
+			segs, err := parsePath(s)
+			if err != nil {
+				return nil, fmt.Errorf(`invalid projection: %w`, err)
+			}
+			segments = append(segments, segs)
+		}
+		if p = p.add(segments); p == nil {
+			// the whole document is needed
+			return nil, nil
+		}
+	}
+	p.propagate()
+	return p, nil
+}
+
+// add adds the path described by segments, which are separated by
+// wildcards, and returns the resulting projection
+func (p *projection) add(segments [][]pathSegment) *projection {
+	if p == nil {
+		return nil
+	}
+	if len(segments[0]) == 0 {
+		if len(segments) == 1 {
+			return nil
+		}
+		if p.every == nil && !p.hasEvery {
+			p.every = &projection{}
+		}
+		p.every = p.every.add(segments[1:])
+		p.hasEvery = true
+		return p
+	}
+
+	seg := segments[0][0]
+	rest := append([][]pathSegment{segments[0][1:]}, segments[1:]...)
+	if seg.isIndex {
+		if p.elements == nil {
+			p.elements = make(map[int]*projection)
+		}
+		child, ok := p.elements[seg.index]
+		if !ok {
+			child = &projection{}
+		}
+		p.elements[seg.index] = child.add(rest)
+		return p
+	}
+	if p.fields == nil {
+		p.fields = make(map[string]*projection)
+	}
+	child, ok := p.fields[seg.key]
+	if !ok {
+		child = &projection{}
+	}
+	p.fields[seg.key] = child.add(rest)
+	return p
+}
+
+// propagate merges the projection of `[*]` into those of the elements
+// that are requested individually, so that each element only needs to
+// be looked up once
+func (p *projection) propagate() {
+	if p == nil {
+		return
+	}
+	for _, child := range p.fields {
+		child.propagate()
+	}
+	p.every.propagate()
+	for i, child := range p.elements {
+		if p.hasEvery {
+			child = child.merge(p.every)
+			p.elements[i] = child
+		}
+		child.propagate()
+	}
+}
+
+// merge returns the union of p and q
+func (p *projection) merge(q *projection) *projection {
+	if p == nil || q == nil {
+		return nil
+	}
+	for key, child := range q.fields {
+		if p.fields == nil {
+			p.fields = make(map[string]*projection)
+		}
+		if existing, ok := p.fields[key]; ok {
+			p.fields[key] = existing.merge(child)
+		} else {
+			p.fields[key] = child.clone()
+		}
+	}
+	for i, child := range q.elements {
+		if p.elements == nil {
+			p.elements = make(map[int]*projection)
+		}
+		if existing, ok := p.elements[i]; ok {
+			p.elements[i] = existing.merge(child)
+		} else {
+			p.elements[i] = child.clone()
+		}
+	}
+	if q.hasEvery {
+		if p.hasEvery {
+			p.every = p.every.merge(q.every)
+		} else {
+			p.every = q.every.clone()
+		}
+		p.hasEvery = true
+	}
+	return p
+}
+
+func (p *projection) clone() *projection {
+	if p == nil {
+		return nil
+	}
+	return (&projection{}).merge(p)
+}
+
+// field returns the projection of the field named key
+func (p *projection) field(key string) *projection {
+	if child, ok := p.fields[key]; ok {
+		return child
+	}
+	return unprojected
+}
+
+// element returns the projection of the i-th element
+func (p *projection) element(i int) *projection {
+	if child, ok := p.elements[i]; ok {
+		return child
+	}
+	if p.hasEvery {
+		return p.every
+	}
+	return unprojected
+}
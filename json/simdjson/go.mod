@@ -0,0 +1,20 @@
+module github.com/lestrrat-go/json/simdjson
+
+go 1.23
+
+require (
+	github.com/lestrrat-go/json v0.0.0
+	github.com/minio/simdjson-go v0.4.5
+	github.com/stretchr/testify v1.6.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/klauspost/compress v1.15.15 // indirect
+	github.com/klauspost/cpuid/v2 v2.2.3 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.0.0-20220704084225-05e143d24a9e // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+)
+
+replace github.com/lestrrat-go/json => ../
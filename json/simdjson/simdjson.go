@@ -0,0 +1,183 @@
+// Package simdjson parses JSON documents into Contexts using
+// github.com/minio/simdjson-go, which uses SIMD instructions to find
+// the structure of the input. Allocating the values of the document
+// still takes most of the time, so that large documents are parsed
+// moderately faster than by json.Parse (see BenchmarkParse).
+//
+// simdjson-go requires a CPU supporting AVX2 and CLMUL. On other CPUs,
+// Parse falls back to json.Parse, so that it can be used everywhere.
+//
+// This package is a separate module, so that the dependency on
+// simdjson-go is only incurred by the programs that use it.
+package simdjson
+
+import (
+	stdlib "encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/json"
+	"github.com/minio/simdjson-go"
+)
+
+// Supported reports whether the CPU supports simdjson-go. If it does
+// not, Parse falls back to json.Parse
+func Supported() bool {
+	return simdjson.SupportedCPU()
+}
+
+// Parse parses the JSON value in data. Because simdjson-go implements
+// strict JSON only, and does not enforce the limits of json.Parse, the
+// document is only parsed by simdjson-go if the ParseOptions merely
+// configure the resulting Context: WithNonFiniteNumbers,
+// WithStrictNumbers, WithMarshalFunc, WithTimeLayout, and
+// WithTimeEpoch. If any other option is given (such as WithMaxDepth,
+// WithDuplicateKeys, or WithPreserveKeyOrder), Parse falls back to
+// json.Parse, so that the options are honored regardless of the CPU.
+//
+// Numbers are stored as json.Number values, as json.Parse does by
+// default. However, simdjson-go decodes floats into float64 values, so
+// that floats are marshaled in their shortest form (`1.50` becomes
+// `1.5`), while integers are marshaled exactly as they appeared
+func Parse(data []byte, options ...json.ParseOption) (json.Context, error) {
+	if !simdjson.SupportedCPU() || !accelerated(options) {
+		return json.Parse(data, options...)
+	}
+
+	// the values are copied out of the tape, which can then be reused
+	reuse, _ := tapes.Get().(*simdjson.ParsedJson)
+	pj, err := simdjson.Parse(data, reuse, simdjson.WithCopyStrings(false))
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse JSON: %w`, err)
+	}
+	defer tapes.Put(pj)
+
+	iter := pj.Iter()
+	if iter.Advance() != simdjson.TypeRoot {
+		return nil, errors.New(`failed to parse JSON: no value found`)
+	}
+	typ, root, err := iter.Root(nil)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse JSON: %w`, err)
+	}
+	v, err := materialize(typ, root)
+	if err != nil {
+		return nil, err
+	}
+	if iter.Advance() == simdjson.TypeRoot {
+		// simdjson-go accepts newline delimited documents
+		return nil, errors.New(`failed to parse JSON: unexpected data after the top-level value`)
+	}
+
+	newOptions := make([]json.Option, len(options))
+	for i, option := range options {
+		newOptions[i] = option
+	}
+	return json.New(v, newOptions...), nil
+}
+
+// tapes holds the parsed tapes of simdjson-go for reuse
+var tapes sync.Pool
+
+// contextOptions holds the names of the ParseOptions that only
+// configure the resulting Context, and are therefore honored by json.New
+var contextOptions = map[string]struct{}{
+	json.WithNonFiniteNumbers(true).Name():                                          {},
+	json.WithStrictNumbers(true).Name():                                             {},
+	json.WithMarshalFunc(func(struct{}) ([]byte, error) { return nil, nil }).Name(): {},
+	json.WithTimeLayout(time.RFC3339).Name():                                        {},
+	json.WithTimeEpoch(time.Second).Name():                                          {},
+}
+
+// accelerated reports whether the document can be parsed by
+// simdjson-go with the given options
+func accelerated(options []json.ParseOption) bool {
+	for _, option := range options {
+		if _, ok := contextOptions[option.Name()]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseString parses the JSON value in s. See Parse
+func ParseString(s string, options ...json.ParseOption) (json.Context, error) {
+	return Parse([]byte(s), options...)
+}
+
+// materialize converts the value of type typ pointed by iter from the
+// tape of simdjson-go into a Go value
+func materialize(typ simdjson.Type, iter *simdjson.Iter) (interface{}, error) {
+	switch typ {
+	case simdjson.TypeNull:
+		return nil, nil
+	case simdjson.TypeBool:
+		return iter.Bool()
+	case simdjson.TypeString:
+		return iter.String()
+	case simdjson.TypeInt:
+		n, err := iter.Int()
+		if err != nil {
+			return nil, err
+		}
+		return stdlib.Number(strconv.FormatInt(n, 10)), nil
+	case simdjson.TypeUint:
+		n, err := iter.Uint()
+		if err != nil {
+			return nil, err
+		}
+		return stdlib.Number(strconv.FormatUint(n, 10)), nil
+	case simdjson.TypeFloat:
+		f, err := iter.Float()
+		if err != nil {
+			return nil, err
+		}
+		return stdlib.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+	case simdjson.TypeArray:
+		arr, err := iter.Array(nil)
+		if err != nil {
+			return nil, err
+		}
+		l := []interface{}{}
+		elems := arr.Iter()
+		for {
+			typ := elems.Advance()
+			if typ == simdjson.TypeNone {
+				break
+			}
+			v, err := materialize(typ, &elems)
+			if err != nil {
+				return nil, fmt.Errorf(`failed to decode element %d: %w`, len(l), err)
+			}
+			l = append(l, v)
+		}
+		return l, nil
+	case simdjson.TypeObject:
+		obj, err := iter.Object(nil)
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{})
+		var elem simdjson.Iter
+		for {
+			name, typ, err := obj.NextElement(&elem)
+			if err != nil {
+				return nil, err
+			}
+			if typ == simdjson.TypeNone {
+				break
+			}
+			v, err := materialize(typ, &elem)
+			if err != nil {
+				return nil, fmt.Errorf(`failed to decode key %q: %w`, name, err)
+			}
+			// the last duplicate key wins, as in json.Parse
+			m[name] = v
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf(`unexpected value of type %s`, typ)
+}
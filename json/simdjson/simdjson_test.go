@@ -0,0 +1,121 @@
+package simdjson_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/json"
+	"github.com/lestrrat-go/json/jsontest"
+	"github.com/lestrrat-go/json/simdjson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Logf(`simdjson-go supported: %t`, simdjson.Supported())
+
+	t.Run("sanity", func(t *testing.T) {
+		const src = `{"z":[1,-2,18446744073709551615,1.5,{"a":null}],"b":true,"s":"héllo\n","nested":{"x":{},"y":[]}}`
+		j, err := simdjson.ParseString(src)
+		if !assert.NoError(t, err, `simdjson.ParseString should succeed`) {
+			return
+		}
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"b":true,"nested":{"x":{},"y":[]},"s":"héllo\n","z":[1,-2,18446744073709551615,1.5,{"a":null}]}`, string(buf), `output should match`) {
+			return
+		}
+
+		expected, err := json.ParseString(src)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		if !jsontest.AssertEqual(t, expected, j) {
+			return
+		}
+
+		var s string
+		if !assert.NoError(t, j.MapIndex("s").String(&s), `String should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "héllo\n", s, `strings should be decoded`) {
+			return
+		}
+	})
+	t.Run("key order", func(t *testing.T) {
+		const src = `{"z":[1,{"b":2,"a":1}],"b":true}`
+		j, err := simdjson.ParseString(src, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `simdjson.ParseString should succeed`) {
+			return
+		}
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, src, string(buf), `output should match`) {
+			return
+		}
+	})
+	t.Run("limits", func(t *testing.T) {
+		deep := strings.Repeat("[", 50) + strings.Repeat("]", 50)
+		if _, err := simdjson.ParseString(deep, json.WithMaxDepth(10)); !assert.True(t, errors.Is(err, json.ErrLimitExceeded), `simdjson.ParseString should honor the option`) {
+			return
+		}
+		if _, err := simdjson.ParseString(`{"a":1,"a":2}`, json.WithDuplicateKeys(json.DuplicateKeyError)); !assert.True(t, errors.Is(err, json.ErrDuplicateKey), `simdjson.ParseString should honor the option`) {
+			return
+		}
+		if _, err := simdjson.ParseString(`[1,2,3]`, json.WithMaxSize(4)); !assert.True(t, errors.Is(err, json.ErrLimitExceeded), `simdjson.ParseString should honor the option`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		testcases := []struct {
+			Name  string
+			Input string
+		}{
+			{Name: "empty", Input: ``},
+			{Name: "unterminated object", Input: `{"a":1`},
+			{Name: "trailing comma", Input: `[1,]`},
+			{Name: "multiple values", Input: "{}\n{}"},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				if _, err := simdjson.ParseString(tc.Input); !assert.Error(t, err, `simdjson.ParseString should fail`) {
+					return
+				}
+			})
+		}
+	})
+}
+
+func BenchmarkParse(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i := 0; i < 20000; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `{"id":%d,"name":"user-%d","tags":["a","b"],"score":%d.5,"active":true}`, i, i, i)
+	}
+	sb.WriteByte(']')
+	data := []byte(sb.String())
+
+	b.Run("simdjson.Parse", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := simdjson.Parse(data); err != nil {
+				b.Errorf(`simdjson.Parse failed: %s`, err)
+			}
+		}
+	})
+	b.Run("json.Parse", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := json.Parse(data); err != nil {
+				b.Errorf(`json.Parse failed: %s`, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package json
+
+import (
+	stdlib "encoding/json"
+	"reflect"
+)
+
+const (
+	// sizes of the headers of strings, slices, and interfaces
+	stringHeaderSize    = 16
+	sliceHeaderSize     = 24
+	interfaceHeaderSize = 16
+	// mapHeaderSize is the approximate size of the header of a map,
+	// and the size of the metadata of each of its slots
+	mapHeaderSize     = 48
+	mapSlotHeaderSize = 1
+)
+
+func (c *ctx) SizeEstimate() int64 {
+	if !c.value.IsValid() {
+		return 0
+	}
+
+	n := valueSize(c.value.Interface())
+	// the metadata of the document is held by the Context for the
+	// entire document, which has no parent
+	if c.parent == nil {
+		for path := range c.locations {
+			n += int64(len(path))
+		}
+		n += mapSize(len(c.locations), stringHeaderSize+int(reflect.TypeOf(Location{}).Size()))
+		if c.order != nil {
+			n += c.order.size(c.value)
+		}
+	}
+	return n
+}
+
+// mapSize returns the approximate size of a map holding n entries,
+// each of which occupies slotSize bytes, excluding the memory that the
+// keys and values refer to. Maps are kept at most 7/8 full
+func mapSize(n, slotSize int) int64 {
+	slots := n * 8 / 7
+	return mapHeaderSize + int64(slots)*int64(slotSize+mapSlotHeaderSize)
+}
+
+// valueSize returns the approximate number of bytes allocated for v,
+// which is stored in an interface, excluding the interface itself.
+// The values held in parsed documents are handled without reflection
+func valueSize(v interface{}) int64 {
+	switch v := v.(type) {
+	case nil, bool:
+		return 0
+	case string:
+		return stringHeaderSize + int64(len(v))
+	case stdlib.Number:
+		return stringHeaderSize + int64(len(v))
+	case int64, float64:
+		return 8
+	case rawValue:
+		return sliceHeaderSize + int64(cap(v))
+	case []interface{}:
+		n := sliceHeaderSize + int64(cap(v))*interfaceHeaderSize
+		for _, elem := range v {
+			n += valueSize(elem)
+		}
+		return n
+	case map[string]interface{}:
+		n := mapSize(len(v), stringHeaderSize+interfaceHeaderSize)
+		for key, elem := range v {
+			n += int64(len(key)) + valueSize(elem)
+		}
+		return n
+	}
+
+	return boxedSize(reflect.ValueOf(v))
+}
+
+// boxedSize is the same as valueSize, for values of arbitrary types
+func boxedSize(rv reflect.Value) int64 {
+	n := indirectSize(rv)
+	// values that are not pointers are copied to the heap when they
+	// are stored in an interface
+	switch rv.Kind() {
+	case reflect.Map, reflect.Pointer, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+	default:
+		n += int64(rv.Type().Size())
+	}
+	return n
+}
+
+// indirectSize returns the approximate number of bytes referred to by
+// rv, excluding the memory occupied by rv itself
+func indirectSize(rv reflect.Value) int64 {
+	switch rv.Kind() {
+	case reflect.String:
+		return int64(rv.Len())
+	case reflect.Interface:
+		if rv.IsNil() {
+			return 0
+		}
+		return boxedSize(rv.Elem())
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return 0
+		}
+		return int64(rv.Type().Elem().Size()) + indirectSize(rv.Elem())
+	case reflect.Slice:
+		n := int64(rv.Cap()) * int64(rv.Type().Elem().Size())
+		for i := 0; i < rv.Len(); i++ {
+			n += indirectSize(rv.Index(i))
+		}
+		return n
+	case reflect.Array:
+		var n int64
+		for i := 0; i < rv.Len(); i++ {
+			n += indirectSize(rv.Index(i))
+		}
+		return n
+	case reflect.Map:
+		if rv.IsNil() {
+			return 0
+		}
+		n := mapSize(rv.Len(), int(rv.Type().Key().Size()+rv.Type().Elem().Size()))
+		iter := rv.MapRange()
+		for iter.Next() {
+			n += indirectSize(iter.Key()) + indirectSize(iter.Value())
+		}
+		return n
+	case reflect.Struct:
+		var n int64
+		for i := 0; i < rv.NumField(); i++ {
+			n += indirectSize(rv.Field(i))
+		}
+		return n
+	}
+	return 0
+}
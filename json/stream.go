@@ -0,0 +1,341 @@
+package json
+
+import (
+	stdlib "encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ArrayStream reads the elements of a top-level JSON array from an
+// io.Reader one at a time, without loading the entire array in memory
+type ArrayStream struct {
+	dec  *decoder
+	done bool
+}
+
+// ParseArrayStream reads the opening bracket of a JSON array from r,
+// and returns an ArrayStream that can be used to read each element.
+// If the input does not start with a JSON array, an error is returned.
+//
+// The WithLazy option is not supported, and is ignored
+func ParseArrayStream(r io.Reader, options ...ParseOption) (*ArrayStream, error) {
+	cfg := newParseConfig(options)
+	cfg.lazy = false
+	if cfg.maxSize >= 0 {
+		r = &sizeLimitedReader{src: r, remaining: cfg.maxSize, max: cfg.maxSize}
+	}
+	t := NewTokenizer(r)
+
+	tok, err := t.Next()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read opening token: %w`, err)
+	}
+
+	if tok.Kind != ArrayStartToken {
+		return nil, fmt.Errorf(`expected a JSON array, got %s`, tok.Kind)
+	}
+
+	return &ArrayStream{dec: newDecoder(t, cfg)}, nil
+}
+
+// Next returns a Context pointing to the next element in the array.
+// When all elements have been read, io.EOF is returned
+func (s *ArrayStream) Next() (Context, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+
+	tok, err := s.dec.t.Next()
+	if err != nil {
+		s.done = true
+		return nil, fmt.Errorf(`failed to unmarshal JSON: %w`, err)
+	}
+
+	if tok.Kind == ArrayEndToken {
+		s.done = true
+		return nil, io.EOF
+	}
+
+	v, err := s.dec.decodeToken(tok)
+	if err != nil {
+		s.done = true
+		return nil, fmt.Errorf(`failed to unmarshal JSON: %w`, err)
+	}
+
+	return s.dec.context(v), nil
+}
+
+// Decoder reads consecutive top-level JSON values from an io.Reader,
+// and returns each of them as a Context
+type Decoder struct {
+	dec *decoder
+}
+
+// NewDecoder creates a new Decoder that reads from r.
+//
+// The WithLazy option is not supported, and is ignored
+func NewDecoder(r io.Reader, options ...ParseOption) *Decoder {
+	cfg := newParseConfig(options)
+	cfg.lazy = false
+	if cfg.maxSize >= 0 {
+		r = &sizeLimitedReader{src: r, remaining: cfg.maxSize, max: cfg.maxSize}
+	}
+	return &Decoder{dec: newDecoder(NewTokenizer(r), cfg)}
+}
+
+// Decode reads the next JSON value from the input, and returns a
+// Context pointing to it. When there are no more values in the
+// input, io.EOF is returned
+func (d *Decoder) Decode() (Context, error) {
+	v, err := d.dec.decodeValue()
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf(`failed to unmarshal JSON: %w`, err)
+	}
+	return d.dec.context(v), nil
+}
+
+// More reports whether there is another value available in the input
+func (d *Decoder) More() bool {
+	return d.dec.t.More()
+}
+
+// Buffered returns a reader of the data remaining in the Decoder's buffer
+func (d *Decoder) Buffered() io.Reader {
+	return d.dec.t.Buffered()
+}
+
+// Encoder writes Contexts to an io.Writer as JSON values, each
+// followed by a newline
+type Encoder struct {
+	w   io.Writer
+	enc *stdlib.Encoder
+
+	// the settings of enc, which are also applied to the Contexts
+	// that enc cannot encode (see encodableValue)
+	escapeHTML bool
+	prefix     string
+	indent     string
+}
+
+// NewEncoder creates a new Encoder that writes to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, enc: stdlib.NewEncoder(w), escapeHTML: true}
+}
+
+// Encode writes the JSON encoding of the value pointed by c to the
+// underlying writer
+func (e *Encoder) Encode(c Context) error {
+	v, err := encodableValue(c)
+	if err != nil {
+		return err
+	}
+
+	if x, ok := v.(*ctx); ok {
+		options := []MarshalOption{WithEscapeHTML(e.escapeHTML)}
+		if e.prefix != "" || e.indent != "" {
+			options = append(options, WithIndent(e.prefix, e.indent))
+		}
+		buf, err := Marshal(x, options...)
+		if err != nil {
+			return fmt.Errorf(`failed to encode JSON: %w`, err)
+		}
+		if _, err := e.w.Write(append(buf, '\n')); err != nil {
+			return fmt.Errorf(`failed to write JSON: %w`, err)
+		}
+		return nil
+	}
+
+	if err := e.enc.Encode(v); err != nil {
+		return fmt.Errorf(`failed to encode JSON: %w`, err)
+	}
+	return nil
+}
+
+// encodableValue returns the value that should be passed to
+// encoding/json.Encoder in order to encode v. Contexts are unwrapped
+// so that the encoder's settings (such as HTML escaping) are honored
+// for the underlying value, unless they need to be encoded differently
+// (for example, if their key order needs to be preserved). Such
+// Contexts are returned as is, and must be encoded by Marshal with
+// the encoder's settings
+func encodableValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case *ctx:
+		if v.customEncoding() {
+			return v, nil
+		}
+		return v.interfaceValue(), nil
+	case *errCtx:
+		return nil, v.err
+	}
+	return v, nil
+}
+
+// SetIndent instructs the Encoder to format each subsequent value as
+// if indented by SetIndent of encoding/json.Encoder
+func (e *Encoder) SetIndent(prefix, indent string) {
+	e.enc.SetIndent(prefix, indent)
+	e.prefix = prefix
+	e.indent = indent
+}
+
+// SetEscapeHTML specifies whether problematic HTML characters
+// (`<`, `>`, and `&`) should be escaped inside JSON strings.
+// The default is true
+func (e *Encoder) SetEscapeHTML(on bool) {
+	e.enc.SetEscapeHTML(on)
+	e.escapeHTML = on
+}
+
+// ArrayWriter writes a top-level JSON array to an io.Writer one
+// element at a time, without holding the entire array in memory.
+// Each element is written to the underlying writer as soon as it
+// is encoded, and Close must be called to terminate the array
+type ArrayWriter struct {
+	sw streamWriter
+}
+
+// NewArrayWriter creates a new ArrayWriter that writes to w. The
+// options are applied to each element
+func NewArrayWriter(w io.Writer, options ...MarshalOption) *ArrayWriter {
+	return &ArrayWriter{sw: newStreamWriter(w, '[', ']', options)}
+}
+
+// Encode writes the JSON encoding of v as the next element of the
+// array. v may either be a Context or any value that can be encoded
+// by encoding/json
+func (a *ArrayWriter) Encode(v interface{}) error {
+	return a.sw.encode(nil, v)
+}
+
+// Close writes the closing bracket of the array. If no elements have
+// been written, an empty array is written. Close does not close the
+// underlying writer
+func (a *ArrayWriter) Close() error {
+	return a.sw.close()
+}
+
+// ObjectWriter writes a top-level JSON object to an io.Writer one
+// field at a time, without holding the entire object in memory.
+// Fields are written in the order in which they are encoded, and
+// duplicate keys are not detected. Close must be called to terminate
+// the object
+type ObjectWriter struct {
+	sw streamWriter
+}
+
+// NewObjectWriter creates a new ObjectWriter that writes to w. The
+// options are applied to the value of each field
+func NewObjectWriter(w io.Writer, options ...MarshalOption) *ObjectWriter {
+	return &ObjectWriter{sw: newStreamWriter(w, '{', '}', options)}
+}
+
+// Encode writes the field named key, whose value is the JSON encoding
+// of v. v may either be a Context or any value that can be encoded
+// by encoding/json
+func (o *ObjectWriter) Encode(key string, v interface{}) error {
+	return o.sw.encode(&key, v)
+}
+
+// Close writes the closing brace of the object. If no fields have
+// been written, an empty object is written. Close does not close the
+// underlying writer
+func (o *ObjectWriter) Close() error {
+	return o.sw.close()
+}
+
+// streamWriter implements ArrayWriter and ObjectWriter. Once the
+// output has been left incomplete by an error, the error is returned
+// from all subsequent calls
+type streamWriter struct {
+	w       io.Writer
+	open    byte
+	end     byte
+	options []MarshalOption
+	n       int
+	closed  bool
+	err     error
+}
+
+func newStreamWriter(w io.Writer, open, end byte, options []MarshalOption) streamWriter {
+	return streamWriter{w: w, open: open, end: end, options: options}
+}
+
+// encode writes v as the next element, or as the value of the field
+// named *key if key is non-nil
+func (s *streamWriter) encode(key *string, v interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.closed {
+		return errors.New(`cannot encode after Close`)
+	}
+
+	e := encodeState{escapeHTML: true, w: s.w}
+	value, err := e.init(v, s.options)
+	if err != nil {
+		return err
+	}
+	if key != nil && e.omitEmpty && e.isEmpty(value) {
+		return nil
+	}
+
+	if s.n == 0 {
+		e.buf.WriteByte(s.open)
+	} else {
+		e.buf.WriteByte(',')
+	}
+	e.depth = 1
+	e.newline()
+	if key != nil {
+		e.writeString(*key)
+		e.buf.WriteByte(':')
+		if e.indented {
+			e.buf.WriteByte(' ')
+		}
+	}
+	if err := e.encode(value); err != nil {
+		// nothing has been written unless the element was large enough
+		// to be flushed, in which case the output cannot be recovered
+		if e.written > 0 {
+			s.err = err
+		}
+		return err
+	}
+	if err := e.flush(true); err != nil {
+		s.err = err
+		return err
+	}
+	s.n++
+	return nil
+}
+
+func (s *streamWriter) close() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	e := encodeState{w: s.w}
+	if _, err := e.init(nil, s.options); err != nil {
+		return err
+	}
+	if s.n == 0 {
+		e.buf.WriteByte(s.open)
+	} else {
+		e.newline()
+	}
+	e.buf.WriteByte(s.end)
+	if err := e.flush(true); err != nil {
+		s.err = err
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,494 @@
+package json_test
+
+import (
+	"bytes"
+	"context"
+	stdlib "encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArrayStream(t *testing.T) {
+	t.Run("sanity", func(t *testing.T) {
+		s, err := json.ParseArrayStream(strings.NewReader(`[{"id": 1}, {"id": 2}, {"id": 3}]`))
+		if !assert.NoError(t, err, `json.ParseArrayStream should succeed`) {
+			return
+		}
+
+		var ids []int
+		for {
+			elem, err := s.Next()
+			if err == io.EOF {
+				break
+			}
+			if !assert.NoError(t, err, `s.Next should succeed`) {
+				return
+			}
+
+			var id int
+			if !assert.NoError(t, elem.MapIndex("id").Int(&id), `elem.MapIndex.Int should succeed`) {
+				return
+			}
+			ids = append(ids, id)
+		}
+		if !assert.Equal(t, []int{1, 2, 3}, ids, `ids should match`) {
+			return
+		}
+
+		_, err = s.Next()
+		if !assert.Equal(t, io.EOF, err, `s.Next should keep returning io.EOF`) {
+			return
+		}
+	})
+	t.Run("empty array", func(t *testing.T) {
+		s, err := json.ParseArrayStream(strings.NewReader(`[]`))
+		if !assert.NoError(t, err, `json.ParseArrayStream should succeed`) {
+			return
+		}
+		_, err = s.Next()
+		if !assert.Equal(t, io.EOF, err, `s.Next should return io.EOF`) {
+			return
+		}
+	})
+	t.Run("not an array", func(t *testing.T) {
+		_, err := json.ParseArrayStream(strings.NewReader(`{"foo": "bar"}`))
+		if !assert.Error(t, err, `json.ParseArrayStream should fail`) {
+			return
+		}
+	})
+	t.Run("malformed element", func(t *testing.T) {
+		s, err := json.ParseArrayStream(strings.NewReader(`[1, {"foo": ]`))
+		if !assert.NoError(t, err, `json.ParseArrayStream should succeed`) {
+			return
+		}
+		if _, err := s.Next(); !assert.NoError(t, err, `first s.Next should succeed`) {
+			return
+		}
+		if _, err := s.Next(); !assert.Error(t, err, `second s.Next should fail`) {
+			return
+		}
+	})
+}
+
+func TestDecoder(t *testing.T) {
+	t.Run("sanity", func(t *testing.T) {
+		dec := json.NewDecoder(strings.NewReader(`{"seq": 1} {"seq": 2}
+[3] "four"`))
+
+		var values []string
+		for {
+			j, err := dec.Decode()
+			if err == io.EOF {
+				break
+			}
+			if !assert.NoError(t, err, `dec.Decode should succeed`) {
+				return
+			}
+
+			buf, err := j.MarshalJSON()
+			if !assert.NoError(t, err, `j.MarshalJSON should succeed`) {
+				return
+			}
+			values = append(values, string(buf))
+		}
+		if !assert.Equal(t, []string{`{"seq":1}`, `{"seq":2}`, `[3]`, `"four"`}, values, `values should match`) {
+			return
+		}
+	})
+	t.Run("malformed input", func(t *testing.T) {
+		dec := json.NewDecoder(strings.NewReader(`{"seq": 1} {"seq": `))
+		if _, err := dec.Decode(); !assert.NoError(t, err, `first dec.Decode should succeed`) {
+			return
+		}
+		_, err := dec.Decode()
+		if !assert.Error(t, err, `second dec.Decode should fail`) {
+			return
+		}
+		if !assert.NotEqual(t, io.EOF, err, `error should not be io.EOF`) {
+			return
+		}
+	})
+}
+
+func TestEncoder(t *testing.T) {
+	t.Run("sanity", func(t *testing.T) {
+		var buf strings.Builder
+		enc := json.NewEncoder(&buf)
+		for i := 1; i <= 2; i++ {
+			if !assert.NoError(t, enc.Encode(json.New(map[string]interface{}{"seq": i})), `enc.Encode should succeed`) {
+				return
+			}
+		}
+		if !assert.Equal(t, "{\"seq\":1}\n{\"seq\":2}\n", buf.String(), `output should match`) {
+			return
+		}
+	})
+	t.Run("indent and HTML escaping", func(t *testing.T) {
+		var buf strings.Builder
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if !assert.NoError(t, enc.Encode(json.New(map[string]interface{}{"url": "http://example.com/?a=1&b=<2>"})), `enc.Encode should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "{\n  \"url\": \"http://example.com/?a=1&b=<2>\"\n}\n", buf.String(), `output should match`) {
+			return
+		}
+	})
+	t.Run("indent and HTML escaping with preserved key order", func(t *testing.T) {
+		j, err := json.ParseString(`{"b":"<x>","a":1}`, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+
+		var buf strings.Builder
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if !assert.NoError(t, enc.Encode(j), `enc.Encode should succeed`) {
+			return
+		}
+		enc.SetIndent("", "  ")
+		if !assert.NoError(t, enc.Encode(j), `enc.Encode should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "{\"b\":\"<x>\",\"a\":1}\n{\n  \"b\": \"<x>\",\n  \"a\": 1\n}\n", buf.String(), `output should match`) {
+			return
+		}
+	})
+	t.Run("error context", func(t *testing.T) {
+		var buf strings.Builder
+		enc := json.NewEncoder(&buf)
+		if !assert.Error(t, enc.Encode(json.New("foo").MapIndex("bar")), `enc.Encode should fail`) {
+			return
+		}
+	})
+}
+
+func TestFeeder(t *testing.T) {
+	t.Run("chunked input", func(t *testing.T) {
+		chunks := []string{
+			`{"msg": "hel`,
+			`lo \"}{\" world"`,
+			`, "list": [1, {"a": "]"}]} [tr`,
+			`ue] "str`,
+			`ing" 12`,
+			`3 `,
+			`45`,
+		}
+
+		f := json.NewFeeder()
+		var docs []string
+		for _, chunk := range chunks {
+			if _, err := f.Write([]byte(chunk)); !assert.NoError(t, err, `f.Write should succeed`) {
+				return
+			}
+			for f.Ready() {
+				j, err := f.Document()
+				if !assert.NoError(t, err, `f.Document should succeed`) {
+					return
+				}
+				buf, err := j.MarshalJSON()
+				if !assert.NoError(t, err, `j.MarshalJSON should succeed`) {
+					return
+				}
+				docs = append(docs, string(buf))
+			}
+		}
+
+		expected := []string{
+			`{"list":[1,{"a":"]"}],"msg":"hello \"}{\" world"}`,
+			`[true]`,
+			`"string"`,
+			`123`,
+		}
+		if !assert.Equal(t, expected, docs, `documents should match`) {
+			return
+		}
+
+		if _, err := f.Document(); !assert.Equal(t, json.ErrNoDocument, err, `f.Document should return ErrNoDocument`) {
+			return
+		}
+
+		if !assert.NoError(t, f.Close(), `f.Close should succeed`) {
+			return
+		}
+		j, err := f.Document()
+		if !assert.NoError(t, err, `f.Document should succeed after f.Close`) {
+			return
+		}
+		var n int
+		if !assert.NoError(t, j.Int(&n), `j.Int should succeed`) {
+			return
+		}
+		if !assert.Equal(t, 45, n, `values should match`) {
+			return
+		}
+	})
+	t.Run("malformed document", func(t *testing.T) {
+		f := json.NewFeeder()
+		_, _ = f.Write([]byte(`{"foo" "bar"}`))
+		if !assert.True(t, f.Ready(), `f.Ready should be true`) {
+			return
+		}
+		if _, err := f.Document(); !assert.Error(t, err, `f.Document should fail`) {
+			return
+		}
+	})
+	t.Run("parse options", func(t *testing.T) {
+		f := json.NewFeeder(json.WithPreserveKeyOrder(true))
+		_, _ = f.Write([]byte(`{"b":1,"a":2} `))
+		j, err := f.Document()
+		if !assert.NoError(t, err, `f.Document should succeed`) {
+			return
+		}
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `j.MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"b":1,"a":2}`, string(buf), `key order should be preserved`) {
+			return
+		}
+	})
+	t.Run("limits", func(t *testing.T) {
+		testcases := []struct {
+			Name    string
+			Option  json.ParseOption
+			Chunks  []string
+			Written int // number of documents completed before the error
+			Error   bool
+		}{
+			{Name: "size within limit", Option: json.WithMaxSize(8), Chunks: []string{`12345678 `, `[1,2,34]`}, Written: 2},
+			{Name: "size exceeded", Option: json.WithMaxSize(8), Chunks: []string{`[1] `, `"aaaa`, `aaaaa"`}, Written: 1, Error: true},
+			{Name: "depth within limit", Option: json.WithMaxDepth(2), Chunks: []string{`[[1],{"a":2}]`}, Written: 1},
+			{Name: "depth exceeded", Option: json.WithMaxDepth(2), Chunks: []string{`[[[`, `1]]]`}, Error: true},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				f := json.NewFeeder(tc.Option)
+				var err error
+				for _, chunk := range tc.Chunks {
+					if _, err = f.Write([]byte(chunk)); err != nil {
+						break
+					}
+				}
+				if tc.Error {
+					if !assert.True(t, errors.Is(err, json.ErrLimitExceeded), `f.Write should fail with ErrLimitExceeded`) {
+						return
+					}
+					if _, err := f.Write([]byte(`1 `)); !assert.Error(t, err, `subsequent f.Write should fail`) {
+						return
+					}
+				} else if !assert.NoError(t, err, `f.Write should succeed`) {
+					return
+				}
+
+				var count int
+				for f.Ready() {
+					if _, err := f.Document(); !assert.NoError(t, err, `f.Document should succeed`) {
+						return
+					}
+					count++
+				}
+				if !assert.Equal(t, tc.Written, count, `number of documents should match`) {
+					return
+				}
+			})
+		}
+	})
+}
+
+func TestStreamElements(t *testing.T) {
+	t.Run("from a Context", func(t *testing.T) {
+		j, err := json.Parse([]byte(`[1, 2, 3]`))
+		if !assert.NoError(t, err, `json.Parse should succeed`) {
+			return
+		}
+
+		ch, errc := j.StreamElements(context.Background())
+		var values []int
+		for elem := range ch {
+			var n int
+			if !assert.NoError(t, elem.Int(&n), `elem.Int should succeed`) {
+				return
+			}
+			values = append(values, n)
+		}
+		if !assert.NoError(t, <-errc, `there should be no error`) {
+			return
+		}
+		if !assert.Equal(t, []int{1, 2, 3}, values, `values should match`) {
+			return
+		}
+	})
+	t.Run("from an ArrayStream", func(t *testing.T) {
+		s, err := json.ParseArrayStream(strings.NewReader(`[{"seq": 1}, {"seq": 2}, {"seq": `))
+		if !assert.NoError(t, err, `json.ParseArrayStream should succeed`) {
+			return
+		}
+
+		ch, errc := s.StreamElements(context.Background())
+		var count int
+		for range ch {
+			count++
+		}
+		if !assert.Equal(t, 2, count, `two elements should be received`) {
+			return
+		}
+		if !assert.Error(t, <-errc, `the syntax error should be reported`) {
+			return
+		}
+	})
+	t.Run("cancellation", func(t *testing.T) {
+		j := json.New([]interface{}{1, 2, 3})
+
+		cctx, cancel := context.WithCancel(context.Background())
+		ch, errc := j.StreamElements(cctx)
+		<-ch
+		cancel()
+		for range ch {
+		}
+		if !assert.Equal(t, context.Canceled, <-errc, `context.Canceled should be reported`) {
+			return
+		}
+	})
+	t.Run("non-array", func(t *testing.T) {
+		ch, errc := json.New("foo").StreamElements(context.Background())
+		for range ch {
+		}
+		if !assert.Error(t, <-errc, `an error should be reported`) {
+			return
+		}
+	})
+}
+
+func TestArrayWriter(t *testing.T) {
+	t.Run("sanity", func(t *testing.T) {
+		var w chunkRecorder
+		aw := json.NewArrayWriter(&w)
+		var expected []interface{}
+		for i := 0; i < 1000; i++ {
+			v := map[string]interface{}{"id": float64(i), "name": fmt.Sprintf("item-%d", i)}
+			var elem interface{} = v
+			if i%2 == 0 {
+				elem = json.New(v)
+			}
+			if !assert.NoError(t, aw.Encode(elem), `Encode should succeed`) {
+				return
+			}
+			expected = append(expected, v)
+		}
+		if !assert.NoError(t, aw.Close(), `Close should succeed`) {
+			return
+		}
+		if !assert.Len(t, w.chunks, 1001, `each element should be written as soon as it is encoded`) {
+			return
+		}
+
+		var v []interface{}
+		if !assert.NoError(t, stdlib.Unmarshal(w.Bytes(), &v), `output should be valid JSON`) {
+			return
+		}
+		if !assert.Equal(t, expected, v, `output should match`) {
+			return
+		}
+	})
+	t.Run("empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		if !assert.NoError(t, json.NewArrayWriter(&buf).Close(), `Close should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `[]`, buf.String(), `output should match`) {
+			return
+		}
+	})
+	t.Run("indent", func(t *testing.T) {
+		var buf bytes.Buffer
+		aw := json.NewArrayWriter(&buf, json.WithIndent("", "  "))
+		for _, v := range []interface{}{1, map[string]interface{}{"foo": []interface{}{"bar"}}} {
+			if !assert.NoError(t, aw.Encode(v), `Encode should succeed`) {
+				return
+			}
+		}
+		if !assert.NoError(t, aw.Close(), `Close should succeed`) {
+			return
+		}
+
+		expected, _ := stdlib.MarshalIndent([]interface{}{1, map[string]interface{}{"foo": []interface{}{"bar"}}}, "", "  ")
+		if !assert.Equal(t, string(expected), buf.String(), `output should match`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		var buf bytes.Buffer
+		aw := json.NewArrayWriter(&buf)
+		if !assert.NoError(t, aw.Encode(1), `Encode should succeed`) {
+			return
+		}
+		if !assert.Error(t, aw.Encode(json.New(1).MapIndex("foo")), `Encode should fail for invalid Contexts`) {
+			return
+		}
+		if !assert.Error(t, aw.Encode(stdlib.Number("1.")), `Encode should fail for invalid values`) {
+			return
+		}
+		if !assert.NoError(t, aw.Encode(2), `Encode should succeed after a recoverable error`) {
+			return
+		}
+		if !assert.NoError(t, aw.Close(), `Close should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `[1,2]`, buf.String(), `failed elements should not be written`) {
+			return
+		}
+		if !assert.Error(t, aw.Encode(3), `Encode should fail after Close`) {
+			return
+		}
+
+		aw = json.NewArrayWriter(failingWriter{})
+		if !assert.Error(t, aw.Encode(1), `Encode should fail`) {
+			return
+		}
+		if !assert.Error(t, aw.Close(), `Close should report the previous error`) {
+			return
+		}
+	})
+}
+
+func TestObjectWriter(t *testing.T) {
+	var buf bytes.Buffer
+	ow := json.NewObjectWriter(&buf, json.WithOmitEmpty(true))
+	fields := []struct {
+		Key   string
+		Value interface{}
+	}{
+		{Key: "z", Value: 1},
+		{Key: "a", Value: json.New([]interface{}{"foo"})},
+		{Key: "empty", Value: ""},
+		{Key: "<b>", Value: true},
+	}
+	for _, f := range fields {
+		if !assert.NoError(t, ow.Encode(f.Key, f.Value), `Encode should succeed`) {
+			return
+		}
+	}
+	if !assert.NoError(t, ow.Close(), `Close should succeed`) {
+		return
+	}
+	if !assert.Equal(t, `{"z":1,"a":["foo"],"\u003cb\u003e":true}`, buf.String(), `fields should be written in order`) {
+		return
+	}
+
+	buf.Reset()
+	if !assert.NoError(t, json.NewObjectWriter(&buf).Close(), `Close should succeed`) {
+		return
+	}
+	if !assert.Equal(t, `{}`, buf.String(), `output should match`) {
+		return
+	}
+}
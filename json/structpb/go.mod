@@ -0,0 +1,17 @@
+module github.com/lestrrat-go/json/structpb
+
+go 1.23
+
+require (
+	github.com/lestrrat-go/json v0.0.0
+	github.com/stretchr/testify v1.6.0
+	google.golang.org/protobuf v1.34.2
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+)
+
+replace github.com/lestrrat-go/json => ../
@@ -0,0 +1,213 @@
+// Package structpb converts google.protobuf.Struct messages to and from
+// json.Context trees, so that gRPC services exchanging arbitrary JSON
+// documents can use the Context API.
+//
+// It is a separate module, so that the main module does not depend on
+// protobuf.
+//
+// google.protobuf.Value represents all numbers as doubles. Numbers are
+// therefore converted to float64 when converting to a Struct, and
+// Contexts created from a Struct hold float64 values. By default,
+// numbers that cannot be represented as a double without losing
+// precision, such as 9007199254740993, are rejected. See
+// WithAllowPrecisionLoss
+package structpb
+
+import (
+	stdlib "encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+
+	"github.com/lestrrat-go/json"
+	pb "google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	optKeyAllowPrecisionLoss = `optkey-allow-precision-loss`
+)
+
+// Option configures how Contexts are converted to protobuf messages
+type Option interface {
+	Name() string
+	Value() interface{}
+}
+
+type option struct {
+	name  string
+	value interface{}
+}
+
+func (o option) Name() string {
+	return o.name
+}
+
+func (o option) Value() interface{} {
+	return o.value
+}
+
+// WithAllowPrecisionLoss specifies whether numbers that cannot be
+// represented exactly as a double should be rounded to the nearest
+// double, instead of being rejected
+func WithAllowPrecisionLoss(b bool) Option {
+	return &option{name: optKeyAllowPrecisionLoss, value: b}
+}
+
+// FromStruct returns a Context pointing to the JSON object represented
+// by s
+func FromStruct(s *pb.Struct) json.Context {
+	return json.New(s.AsMap(), json.WithNonFiniteNumbers(true))
+}
+
+// FromValue returns a Context pointing to the JSON value represented
+// by v
+func FromValue(v *pb.Value) json.Context {
+	return json.New(v.AsInterface(), json.WithNonFiniteNumbers(true))
+}
+
+// ToStruct converts the JSON object pointed by c into a Struct
+func ToStruct(c json.Context, options ...Option) (*pb.Struct, error) {
+	v, err := ToValue(c, options...)
+	if err != nil {
+		return nil, err
+	}
+	s := v.GetStructValue()
+	if s == nil {
+		return nil, errors.New(`failed to convert to Struct: value is not a JSON object`)
+	}
+	return s, nil
+}
+
+// ToValue converts the JSON value pointed by c into a Value
+func ToValue(c json.Context, options ...Option) (*pb.Value, error) {
+	var cv converter
+	for _, option := range options {
+		switch option.Name() {
+		case optKeyAllowPrecisionLoss:
+			cv.lossy = option.Value().(bool)
+		}
+	}
+
+	// the JSON representation is used so that custom types, such as
+	// those handled by json.WithMarshalFunc, are converted consistently
+	buf, err := c.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to marshal JSON: %w`, err)
+	}
+	j, err := json.Parse(buf, json.WithUseNumber(true), json.WithNonFiniteNumbers(true))
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse JSON: %w`, err)
+	}
+	return cv.context(j)
+}
+
+// converter converts the values of a parsed Context
+type converter struct {
+	lossy bool
+}
+
+func (cv *converter) context(c json.Context) (*pb.Value, error) {
+	var m map[string]interface{}
+	if err := c.Map(&m); err == nil {
+		return cv.value(m)
+	}
+	var l []interface{}
+	if err := c.Slice(&l); err == nil {
+		return cv.value(l)
+	}
+
+	buf, err := c.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	switch s := string(buf); {
+	case s == `null`:
+		return pb.NewNullValue(), nil
+	case s == `true` || s == `false`:
+		return pb.NewBoolValue(s == `true`), nil
+	case buf[0] == '"':
+		var str string
+		if err := c.String(&str); err != nil {
+			return nil, err
+		}
+		return pb.NewStringValue(str), nil
+	default:
+		return cv.number(s)
+	}
+}
+
+// value converts v, which was decoded using json.WithUseNumber
+func (cv *converter) value(v interface{}) (*pb.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return pb.NewNullValue(), nil
+	case bool:
+		return pb.NewBoolValue(v), nil
+	case string:
+		return pb.NewStringValue(v), nil
+	case stdlib.Number:
+		return cv.number(string(v))
+	case map[string]interface{}:
+		fields := make(map[string]*pb.Value, len(v))
+		for key, elem := range v {
+			fv, err := cv.value(elem)
+			if err != nil {
+				return nil, fmt.Errorf(`failed to convert key %q: %w`, key, err)
+			}
+			fields[key] = fv
+		}
+		return pb.NewStructValue(&pb.Struct{Fields: fields}), nil
+	case []interface{}:
+		values := make([]*pb.Value, len(v))
+		for i, elem := range v {
+			ev, err := cv.value(elem)
+			if err != nil {
+				return nil, fmt.Errorf(`failed to convert element %d: %w`, i, err)
+			}
+			values[i] = ev
+		}
+		return pb.NewListValue(&pb.ListValue{Values: values}), nil
+	}
+	return nil, fmt.Errorf(`unexpected value of type %T`, v)
+}
+
+// number converts the JSON number n into a double, which fails if
+// precision would be lost, unless the converter is lossy
+func (cv *converter) number(n string) (*pb.Value, error) {
+	switch n {
+	case `NaN`:
+		return pb.NewNumberValue(math.NaN()), nil
+	case `Infinity`:
+		return pb.NewNumberValue(math.Inf(1)), nil
+	case `-Infinity`:
+		return pb.NewNumberValue(math.Inf(-1)), nil
+	}
+
+	f, err := strconv.ParseFloat(n, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return nil, fmt.Errorf(`invalid number %q`, n)
+	}
+	if !cv.lossy && !isExact(n, f) {
+		return nil, fmt.Errorf(`number %s cannot be represented as a double without losing precision`, n)
+	}
+	return pb.NewNumberValue(f), nil
+}
+
+// isExact reports whether the shortest representation of f denotes the
+// same decimal number as n. Numbers such as 0.1 are therefore exact,
+// although they have no exact binary representation
+func isExact(n string, f float64) bool {
+	if math.IsInf(f, 0) {
+		return false
+	}
+	var a, b big.Rat
+	if _, ok := a.SetString(n); !ok {
+		return false
+	}
+	if _, ok := b.SetString(strconv.FormatFloat(f, 'g', -1, 64)); !ok {
+		return false
+	}
+	return a.Cmp(&b) == 0
+}
@@ -0,0 +1,109 @@
+package structpb_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/lestrrat-go/json"
+	"github.com/lestrrat-go/json/structpb"
+	"github.com/stretchr/testify/assert"
+	pb "google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestFromStruct(t *testing.T) {
+	s, err := pb.NewStruct(map[string]interface{}{
+		"name":  "foo",
+		"count": 3,
+		"tags":  []interface{}{"a", true, nil},
+		"inner": map[string]interface{}{"ratio": 0.5},
+	})
+	if !assert.NoError(t, err, `pb.NewStruct should succeed`) {
+		return
+	}
+
+	j := structpb.FromStruct(s)
+	buf, err := j.MarshalJSON()
+	if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+		return
+	}
+	if !assert.Equal(t, `{"count":3,"inner":{"ratio":0.5},"name":"foo","tags":["a",true,null]}`, string(buf), `output should match`) {
+		return
+	}
+
+	var count int64
+	if !assert.NoError(t, j.MapIndex("count").Int(&count), `Int should succeed`) {
+		return
+	}
+	if !assert.Equal(t, int64(3), count, `count should match`) {
+		return
+	}
+}
+
+func TestToStruct(t *testing.T) {
+	t.Run("sanity", func(t *testing.T) {
+		j, err := json.ParseString(`{"name":"foo","count":3,"ratio":0.1,"tags":["a",true,null],"inner":{"big":1e300}}`)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+
+		s, err := structpb.ToStruct(j)
+		if !assert.NoError(t, err, `structpb.ToStruct should succeed`) {
+			return
+		}
+		expected := map[string]interface{}{
+			"name":  "foo",
+			"count": float64(3),
+			"ratio": 0.1,
+			"tags":  []interface{}{"a", true, nil},
+			"inner": map[string]interface{}{"big": 1e300},
+		}
+		if !assert.Equal(t, expected, s.AsMap(), `values should match`) {
+			return
+		}
+	})
+	t.Run("precision", func(t *testing.T) {
+		for _, src := range []string{`9007199254740993`, `1e400`, `0.1000000000000000000001`} {
+			j, err := json.ParseString(src)
+			if !assert.NoError(t, err, `json.ParseString should succeed`) {
+				return
+			}
+			if _, err := structpb.ToValue(j); !assert.Error(t, err, `structpb.ToValue should fail for %s`, src) {
+				return
+			}
+		}
+
+		j, err := json.ParseString(`9007199254740993`)
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		v, err := structpb.ToValue(j, structpb.WithAllowPrecisionLoss(true))
+		if !assert.NoError(t, err, `structpb.ToValue should succeed`) {
+			return
+		}
+		if !assert.Equal(t, float64(9007199254740992), v.GetNumberValue(), `number should be rounded`) {
+			return
+		}
+	})
+	t.Run("non-finite numbers", func(t *testing.T) {
+		j, err := json.ParseString(`[NaN,-Infinity]`, json.WithNonFiniteNumbers(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		v, err := structpb.ToValue(j)
+		if !assert.NoError(t, err, `structpb.ToValue should succeed`) {
+			return
+		}
+		values := v.GetListValue().GetValues()
+		if !assert.True(t, math.IsNaN(values[0].GetNumberValue()), `NaN should be preserved`) {
+			return
+		}
+		if !assert.True(t, math.IsInf(values[1].GetNumberValue(), -1), `-Infinity should be preserved`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		if _, err := structpb.ToStruct(json.New([]interface{}{1})); !assert.Error(t, err, `non-objects should be rejected`) {
+			return
+		}
+	})
+}
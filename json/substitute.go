@@ -0,0 +1,168 @@
+package json
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// placeholderRx matches placeholders such as `{{ .port }}`, `{{ .db.host }}`,
+// `{{ .hosts.0 }}`, and `{{ . }}`
+var placeholderRx = regexp.MustCompile(`\{\{\s*\.([A-Za-z0-9_]+(?:\.[A-Za-z0-9_]+)*)?\s*\}\}`)
+
+func (c *ctx) Substitute(vars interface{}, options ...ExpandOption) Context {
+	var src *ctx
+	switch v := vars.(type) {
+	case *ctx:
+		src = v
+	case Context:
+		// an invalid Context
+		return v
+	default:
+		vc := New(vars)
+		if v, ok := vc.(*ctx); ok {
+			src = v
+		} else {
+			return vc
+		}
+	}
+
+	policy := MissingVariableError
+	for _, option := range options {
+		switch option.Name() {
+		case optKeyMissingVariables:
+			policy = option.Value().(MissingVariablePolicy)
+		}
+	}
+
+	return c.rewrite(func(s string, order *keyOrder) (interface{}, error) {
+		return substituteString(s, src, order, policy)
+	})
+}
+
+// substituteString replaces the placeholders in s with the values of
+// src. If s consists of a single placeholder, the value itself is
+// returned. Otherwise, the values are interpolated as text
+func substituteString(s string, src *ctx, order *keyOrder, policy MissingVariablePolicy) (interface{}, error) {
+	matches := placeholderRx.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		var path string
+		if matches[0][2] >= 0 {
+			path = s[matches[0][2]:matches[0][3]]
+		}
+		v, ok, err := lookupPath(src, path)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return missingValue(s, path, policy)
+		}
+
+		// containers are copied, so that the documents do not share them
+		v, err = src.rewriteValue(order, rootPath, v, func(s string, _ *keyOrder) (interface{}, error) {
+			return s, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+
+	var sb strings.Builder
+	var last int
+	for _, match := range matches {
+		sb.WriteString(s[last:match[0]])
+		last = match[1]
+
+		var path string
+		if match[2] >= 0 {
+			path = s[match[2]:match[3]]
+		}
+		c, ok := lookupContext(src, path)
+		if !ok {
+			v, err := missingValue(s[match[0]:match[1]], path, policy)
+			if err != nil {
+				return nil, err
+			}
+			sb.WriteString(v.(string))
+			continue
+		}
+
+		var str string
+		if err := c.String(&str); err == nil {
+			sb.WriteString(str)
+			continue
+		}
+		buf, err := c.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf(`failed to marshal value of %q: %w`, path, err)
+		}
+		sb.Write(buf)
+	}
+	sb.WriteString(s[last:])
+	return sb.String(), nil
+}
+
+// missingValue returns the value that replaces placeholder, which refers
+// to the undefined path
+func missingValue(placeholder, path string, policy MissingVariablePolicy) (interface{}, error) {
+	switch policy {
+	case MissingVariableEmpty:
+		return "", nil
+	case MissingVariableKeep:
+		return placeholder, nil
+	}
+	return nil, fmt.Errorf(`%q: %w`, path, ErrMissingVariable)
+}
+
+// lookupContext returns the Context pointing to the value at path
+// under src, where path is a dot-separated list of keys and indices.
+// It reports false if there is no such value
+func lookupContext(src *ctx, path string) (*ctx, bool) {
+	c := src
+	if path == "" {
+		return c, true
+	}
+
+	for _, segment := range strings.Split(path, ".") {
+		var next Context
+		switch c.value.Kind() {
+		case reflect.Map:
+			next = c.MapIndex(segment)
+		case reflect.Slice, reflect.Array:
+			i, err := strconv.Atoi(segment)
+			if err != nil {
+				return nil, false
+			}
+			next = c.Index(i)
+		default:
+			return nil, false
+		}
+		nc, ok := next.(*ctx)
+		if !ok {
+			return nil, false
+		}
+		c = nc
+	}
+	return c, true
+}
+
+// lookupPath is like lookupContext, but returns the value at path. An
+// error is returned if its deferred values fail to decode
+func lookupPath(src *ctx, path string) (interface{}, bool, error) {
+	c, ok := lookupContext(src, path)
+	if !ok {
+		return nil, false, nil
+	}
+	v, err := c.modelValue()
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
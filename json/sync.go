@@ -0,0 +1,404 @@
+package json
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"reflect"
+	"sync"
+)
+
+// syncCtx guards the Context c with mu, which is shared by all the
+// Contexts derived from it
+type syncCtx struct {
+	mu *sync.RWMutex
+	// exclusive is true if reading the document may modify it, such
+	// as when deferred values are decoded, so that readers must hold
+	// the write lock
+	exclusive bool
+	c         *ctx
+}
+
+// Synchronized returns a Context that guards the document pointed by c
+// with a sync.RWMutex, so that it can be read and modified by several
+// goroutines. Accessors, navigation, and marshaling hold the read lock,
+// while Set, SetMapIndex, and the other operations that may modify the
+// document hold the write lock. Reading a document parsed with WithLazy
+// or WithProjection decodes deferred values, and holds the write lock.
+//
+// The Contexts returned by the methods of the synchronized Context,
+// such as MapIndex and Index, inherit its lock, and so do the Contexts
+// yielded by Elements, Entries, and StreamElements. The functions given
+// to ForEach, Walk, Count, FilterElems, MapElems, Reduce, and OnChange
+// are called while the write lock is held: the Contexts that they
+// receive may be used during the call only, and they must not call the
+// methods of the synchronized Contexts, which would deadlock. The
+// values returned by Diff are not synchronized.
+//
+// Modifications made through c itself, or through Contexts derived
+// from c rather than from the synchronized Context, are not guarded.
+// Synchronized returns c as is if it is invalid or already synchronized
+func Synchronized(c Context) Context {
+	switch c := c.(type) {
+	case *ctx:
+		return &syncCtx{mu: &sync.RWMutex{}, exclusive: c.lazy != nil, c: c}
+	}
+	return c
+}
+
+func (s *syncCtx) rlock() func() {
+	if s.exclusive {
+		s.mu.Lock()
+		return s.mu.Unlock
+	}
+	s.mu.RLock()
+	return s.mu.RUnlock
+}
+
+func (s *syncCtx) lock() func() {
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// wrap returns a Context guarded by the lock of s for the Context c
+// returned by one of the methods of the Context wrapped by s
+func (s *syncCtx) wrap(c Context) Context {
+	switch x := c.(type) {
+	case *ctx:
+		if x == s.c {
+			return s
+		}
+		return &syncCtx{mu: s.mu, exclusive: s.exclusive || x.lazy != nil, c: x}
+	}
+	return c
+}
+
+// unwrapSync returns the Context wrapped by c if it was returned by
+// Synchronized, in which case it is locked for reading until the
+// returned function is called. locked holds the locks that are already
+// held by the caller, which are not acquired again
+func unwrapSync(c Context, locked ...*sync.RWMutex) (Context, func()) {
+	s, ok := c.(*syncCtx)
+	if !ok {
+		return c, func() {}
+	}
+	for _, mu := range locked {
+		if mu == s.mu {
+			return s.c, func() {}
+		}
+	}
+	return s.c, s.rlock()
+}
+
+// syncMutex returns the lock of c if it was returned by Synchronized
+func syncMutex(c Context) *sync.RWMutex {
+	if s, ok := c.(*syncCtx); ok {
+		return s.mu
+	}
+	return nil
+}
+
+// unwrapResult returns the result v of a function called while the
+// lock of s is held, replacing the synchronized Contexts that share the
+// lock with the values that they point to, so that they can be read
+// without acquiring it again
+func (s *syncCtx) unwrapResult(v interface{}, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := v.(*syncCtx); ok && c.mu == s.mu {
+		return c.c.interfaceValue(), nil
+	}
+	return v, nil
+}
+
+func (s *syncCtx) ApplyDefaults(schema Context) Context {
+	defer s.rlock()()
+	schema, unlock := unwrapSync(schema, s.mu)
+	defer unlock()
+	return s.wrap(s.c.ApplyDefaults(schema))
+}
+
+func (s *syncCtx) Avg() Context {
+	defer s.rlock()()
+	return s.wrap(s.c.Avg())
+}
+
+func (s *syncCtx) Bool(dst interface{}) error {
+	defer s.rlock()()
+	return s.c.Bool(dst)
+}
+
+func (s *syncCtx) BuildIndex(field string) error {
+	defer s.lock()()
+	return s.c.BuildIndex(field)
+}
+
+func (s *syncCtx) Bytes(dst interface{}) error {
+	defer s.rlock()()
+	return s.c.Bytes(dst)
+}
+
+func (s *syncCtx) Count(fn func(Context) bool) (int, error) {
+	defer s.lock()()
+	return s.c.Count(fn)
+}
+
+func (s *syncCtx) Dump(w io.Writer, options ...DumpOption) error {
+	defer s.rlock()()
+	return s.c.Dump(w, options...)
+}
+
+func (s *syncCtx) Elements() iter.Seq2[int, Context] {
+	return func(yield func(int, Context) bool) {
+		// the elements are collected beforehand, so that the loop
+		// body may use the synchronized Contexts
+		var list []Context
+		unlock := s.rlock()
+		for _, elem := range s.c.Elements() {
+			list = append(list, s.wrap(elem))
+		}
+		unlock()
+
+		for i, elem := range list {
+			if !yield(i, elem) {
+				return
+			}
+		}
+	}
+}
+
+func (s *syncCtx) Entries() iter.Seq2[string, Context] {
+	return func(yield func(string, Context) bool) {
+		var keys []string
+		var list []Context
+		unlock := s.rlock()
+		for key, value := range s.c.Entries() {
+			keys = append(keys, key)
+			list = append(list, s.wrap(value))
+		}
+		unlock()
+
+		for i, value := range list {
+			if !yield(keys[i], value) {
+				return
+			}
+		}
+	}
+}
+
+func (s *syncCtx) Err() error {
+	return nil
+}
+
+func (s *syncCtx) ExpandEnv(lookup func(string) (string, bool), options ...ExpandOption) Context {
+	defer s.rlock()()
+	return s.wrap(s.c.ExpandEnv(lookup, options...))
+}
+
+func (s *syncCtx) FindBy(field string, value interface{}) Context {
+	// the index may be rebuilt
+	defer s.lock()()
+	return s.wrap(s.c.FindBy(field, value))
+}
+
+func (s *syncCtx) FilterElems(fn func(Context) bool) Context {
+	defer s.lock()()
+	return s.wrap(s.c.FilterElems(fn))
+}
+
+func (s *syncCtx) Float(dst interface{}) error {
+	defer s.rlock()()
+	return s.c.Float(dst)
+}
+
+func (s *syncCtx) ForEach(fn func(string, int, Context) bool) error {
+	defer s.lock()()
+	return s.c.ForEach(fn)
+}
+
+func (s *syncCtx) Format(f fmt.State, verb rune) {
+	defer s.rlock()()
+	s.c.Format(f, verb)
+}
+
+func (s *syncCtx) GoString() string {
+	defer s.rlock()()
+	return s.c.GoString()
+}
+
+func (s *syncCtx) GroupBy(path string, options ...GroupOption) (map[string]Context, error) {
+	defer s.rlock()()
+	groups, err := s.c.GroupBy(path, options...)
+	if err != nil {
+		return nil, err
+	}
+	for key, group := range groups {
+		groups[key] = s.wrap(group)
+	}
+	return groups, nil
+}
+
+func (s *syncCtx) Index(i int) Context {
+	defer s.rlock()()
+	return s.wrap(s.c.Index(i))
+}
+
+func (s *syncCtx) Int(dst interface{}) error {
+	defer s.rlock()()
+	return s.c.Int(dst)
+}
+
+func (s *syncCtx) Location() (Location, error) {
+	defer s.rlock()()
+	return s.c.Location()
+}
+
+func (s *syncCtx) Map(dst interface{}) error {
+	defer s.rlock()()
+	return s.c.Map(dst)
+}
+
+func (s *syncCtx) MapElems(fn func(Context) (interface{}, error)) Context {
+	defer s.lock()()
+	return s.wrap(s.c.MapElems(func(elem Context) (interface{}, error) {
+		return s.unwrapResult(fn(elem))
+	}))
+}
+
+func (s *syncCtx) MapIndex(key string) Context {
+	defer s.rlock()()
+	return s.wrap(s.c.MapIndex(key))
+}
+
+func (s *syncCtx) MarshalAppend(buf []byte) ([]byte, error) {
+	defer s.rlock()()
+	return s.c.MarshalAppend(buf)
+}
+
+func (s *syncCtx) MarshalIndent(prefix, indent string) ([]byte, error) {
+	defer s.rlock()()
+	return s.c.MarshalIndent(prefix, indent)
+}
+
+func (s *syncCtx) MarshalJSON() ([]byte, error) {
+	defer s.rlock()()
+	return s.c.MarshalJSON()
+}
+
+func (s *syncCtx) Max() Context {
+	defer s.rlock()()
+	return s.wrap(s.c.Max())
+}
+
+func (s *syncCtx) Min() Context {
+	defer s.rlock()()
+	return s.wrap(s.c.Min())
+}
+
+func (s *syncCtx) OnChange(fn ChangeFunc) func() {
+	unlock := s.lock()
+	cancel := s.c.OnChange(fn)
+	unlock()
+	return func() {
+		defer s.lock()()
+		cancel()
+	}
+}
+
+func (s *syncCtx) Pretty() ([]byte, error) {
+	defer s.rlock()()
+	return s.c.Pretty()
+}
+
+func (s *syncCtx) Reduce(fn func(acc, elem Context) (interface{}, error), init interface{}) Context {
+	defer s.lock()()
+	init, _ = s.unwrapResult(init, nil)
+	return s.wrap(s.c.Reduce(func(acc, elem Context) (interface{}, error) {
+		return s.unwrapResult(fn(acc, elem))
+	}, init))
+}
+
+func (s *syncCtx) Release() {
+	defer s.lock()()
+	s.c.Release()
+}
+
+func (s *syncCtx) Set(v interface{}) Context {
+	defer s.lock()()
+	return s.wrap(s.c.Set(v))
+}
+
+func (s *syncCtx) SetMapIndex(key string, value interface{}) Context {
+	defer s.lock()()
+	return s.wrap(s.c.SetMapIndex(key, value))
+}
+
+func (s *syncCtx) SizeEstimate() int64 {
+	defer s.rlock()()
+	return s.c.SizeEstimate()
+}
+
+func (s *syncCtx) Slice(dst interface{}) error {
+	defer s.rlock()()
+	return s.c.Slice(dst)
+}
+
+func (s *syncCtx) StreamElements(ctx context.Context) (<-chan Context, <-chan error) {
+	unlock := s.rlock()
+	switch s.c.value.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		defer unlock()
+		return s.c.StreamElements(ctx)
+	}
+	unlock()
+
+	var i int
+	return streamElements(ctx, func() (Context, error) {
+		defer s.rlock()()
+		// the array may have been modified in the meantime
+		if i >= s.c.value.Len() {
+			return nil, io.EOF
+		}
+		child, err := s.c.indexChild(i)
+		if err != nil {
+			return nil, err
+		}
+		i++
+		return s.wrap(child), nil
+	})
+}
+
+func (s *syncCtx) String(dst interface{}) error {
+	defer s.rlock()()
+	return s.c.String(dst)
+}
+
+func (s *syncCtx) Substitute(vars interface{}, options ...ExpandOption) Context {
+	defer s.rlock()()
+	return s.wrap(s.c.Substitute(vars, options...))
+}
+
+func (s *syncCtx) Sum() Context {
+	defer s.rlock()()
+	return s.wrap(s.c.Sum())
+}
+
+func (s *syncCtx) Trace(logger *slog.Logger) Context {
+	defer s.lock()()
+	s.c.Trace(logger)
+	return s
+}
+
+func (s *syncCtx) Walk(fn WalkFunc) error {
+	defer s.lock()()
+	return s.c.Walk(fn)
+}
+
+func (s *syncCtx) WriteTo(w io.Writer) (int64, error) {
+	defer s.rlock()()
+	return s.c.WriteTo(w)
+}
@@ -0,0 +1,235 @@
+package json
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultPollInterval is the default interval at which a Follower
+// checks for new data once it has reached the end of the file
+const DefaultPollInterval = 250 * time.Millisecond
+
+// Follower reads newline-delimited JSON records from a file that is
+// being appended to, in the manner of `tail -f`.
+//
+// Records are only returned once their terminating newline has been
+// written. If the file is truncated, the Follower starts over from the
+// beginning of the file. If the file is replaced (for example, when it
+// is rotated), the Follower switches to the new file.
+type Follower struct {
+	path         string
+	file         *os.File
+	info         os.FileInfo
+	pollInterval time.Duration
+	maxLineSize  int
+	parseOptions []ParseOption
+	// retainsInput is true if the parsed records may refer to the
+	// lines that they were parsed from, which must then be copied
+	retainsInput bool
+
+	buf     []byte // data read from the file, but not yet consumed
+	offset  int64  // offset immediately following the last consumed line
+	readPos int64  // offset immediately following the data in buf
+	// skipped is the number of bytes discarded from a record exceeding
+	// the maximum line size, whose end has not been read yet, or -1
+	skipped int64
+}
+
+// TailFile opens the file at path, and returns a Follower that reads
+// records starting from the byte offset fromOffset. The offset should
+// either be 0, or a value previously obtained from Follower.Offset.
+//
+// The WithPollInterval and WithMaxLineSize options may be used to
+// configure the Follower. ParseOptions passed in options are used to
+// parse each record
+func TailFile(path string, fromOffset int64, options ...LinesOption) (*Follower, error) {
+	pollInterval := DefaultPollInterval
+	maxLineSize := DefaultMaxLineSize
+	var parseOptions []ParseOption
+	for _, option := range options {
+		if po, ok := option.(ParseOption); ok {
+			parseOptions = append(parseOptions, po)
+			continue
+		}
+		switch option.Name() {
+		case optKeyPollInterval:
+			pollInterval = option.Value().(time.Duration)
+		case optKeyMaxLineSize:
+			maxLineSize = option.Value().(int)
+		}
+	}
+
+	f := &Follower{
+		skipped:      -1,
+		path:         path,
+		pollInterval: pollInterval,
+		maxLineSize:  maxLineSize,
+		parseOptions: parseOptions,
+		retainsInput: newParseConfig(parseOptions).retainsInput(),
+	}
+	if err := f.open(fromOffset); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *Follower) open(offset int64) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return fmt.Errorf(`failed to open %s: %w`, f.path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf(`failed to stat %s: %w`, f.path, err)
+	}
+
+	if offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return fmt.Errorf(`failed to seek to offset %d: %w`, offset, err)
+		}
+	}
+
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.file = file
+	f.info = info
+	f.buf = f.buf[:0]
+	f.skipped = -1
+	f.offset = offset
+	f.readPos = offset
+	return nil
+}
+
+// Offset returns the byte offset immediately following the last
+// record returned by Next. It can be used to resume reading later
+func (f *Follower) Offset() int64 {
+	return f.offset
+}
+
+// Close closes the underlying file
+func (f *Follower) Close() error {
+	return f.file.Close()
+}
+
+// Next returns the next record in the file, waiting for it to be
+// written if necessary. It returns when a record is available, or when
+// ctx is canceled.
+//
+// If a record fails to parse, or exceeds the maximum line size, the
+// error is returned, and the Follower proceeds to the next record in
+// the subsequent call
+func (f *Follower) Next(ctx context.Context) (Context, error) {
+	for {
+		if f.skipped >= 0 {
+			// the rest of the oversized record is discarded as it is read
+			i := bytes.IndexByte(f.buf, '\n')
+			if i < 0 {
+				f.skipped += int64(len(f.buf))
+				f.buf = f.buf[:0]
+			} else {
+				f.offset += f.skipped + int64(i+1)
+				f.buf = f.buf[:copy(f.buf, f.buf[i+1:])]
+				f.skipped = -1
+				continue
+			}
+		} else if i := bytes.IndexByte(f.buf, '\n'); i >= 0 {
+			line := f.buf[:i]
+			lineStart := f.offset
+			f.offset += int64(i + 1)
+
+			if i > f.maxLineSize {
+				f.buf = f.buf[:copy(f.buf, f.buf[i+1:])]
+				return nil, f.lineSizeError(lineStart)
+			}
+
+			var c Context
+			var err error
+			if len(bytes.TrimSpace(line)) > 0 {
+				// the buffer is overwritten by the following records
+				if f.retainsInput {
+					line = bytes.Clone(line)
+				}
+				c, err = Parse(line, f.parseOptions...)
+			}
+			f.buf = f.buf[:copy(f.buf, f.buf[i+1:])]
+
+			if err != nil {
+				return nil, fmt.Errorf(`failed to parse record at offset %d: %w`, lineStart, err)
+			}
+			if c != nil {
+				return c, nil
+			}
+			continue
+		} else if len(f.buf) > f.maxLineSize {
+			// the record is discarded until its end is found, and the
+			// offset is left at its start until then
+			f.skipped = int64(len(f.buf))
+			f.buf = f.buf[:0]
+			return nil, f.lineSizeError(f.offset)
+		}
+
+		n, err := f.read()
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			continue
+		}
+
+		if err := f.checkRotation(); err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(f.pollInterval):
+		}
+	}
+}
+
+func (f *Follower) lineSizeError(offset int64) error {
+	return fmt.Errorf(`record at offset %d exceeds maximum line size of %d bytes`, offset, f.maxLineSize)
+}
+
+func (f *Follower) read() (int, error) {
+	var chunk [4096]byte
+	n, err := f.file.Read(chunk[:])
+	if n > 0 {
+		f.buf = append(f.buf, chunk[:n]...)
+		f.readPos += int64(n)
+	}
+	if err != nil && err != io.EOF {
+		return n, fmt.Errorf(`failed to read from %s: %w`, f.path, err)
+	}
+	return n, nil
+}
+
+// checkRotation reopens the file if it has been replaced or truncated
+func (f *Follower) checkRotation() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		// the file may be missing momentarily while it is being rotated
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf(`failed to stat %s: %w`, f.path, err)
+	}
+
+	switch {
+	case !os.SameFile(info, f.info):
+		// Any incomplete record left in the old file will never be completed
+		return f.open(0)
+	case info.Size() < f.readPos:
+		return f.open(0)
+	}
+	return nil
+}
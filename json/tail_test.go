@@ -0,0 +1,211 @@
+package json_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/json"
+	"github.com/stretchr/testify/assert"
+)
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if !assert.NoError(t, err, `os.OpenFile should succeed`) {
+		t.FailNow()
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); !assert.NoError(t, err, `f.WriteString should succeed`) {
+		t.FailNow()
+	}
+}
+
+func nextSeq(t *testing.T, f *json.Follower) int {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	j, err := f.Next(ctx)
+	if !assert.NoError(t, err, `f.Next should succeed`) {
+		t.FailNow()
+	}
+	var seq int
+	if !assert.NoError(t, j.MapIndex("seq").Int(&seq), `j.MapIndex.Int should succeed`) {
+		t.FailNow()
+	}
+	return seq
+}
+
+func TestTailFile(t *testing.T) {
+	t.Run("partial lines and rotation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log.ndjson")
+		appendFile(t, path, "{\"seq\": 1}\n\n{\"seq\": 2}\n{\"seq\"")
+
+		f, err := json.TailFile(path, 0, json.WithPollInterval(10*time.Millisecond))
+		if !assert.NoError(t, err, `json.TailFile should succeed`) {
+			return
+		}
+		defer f.Close()
+
+		if !assert.Equal(t, 1, nextSeq(t, f), `values should match`) {
+			return
+		}
+		if !assert.Equal(t, 2, nextSeq(t, f), `values should match`) {
+			return
+		}
+
+		// the partial line should not be returned
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := f.Next(ctx); !assert.Equal(t, context.DeadlineExceeded, err, `f.Next should time out`) {
+			return
+		}
+
+		appendFile(t, path, ": 3}\n")
+		if !assert.Equal(t, 3, nextSeq(t, f), `values should match`) {
+			return
+		}
+		offset := f.Offset()
+
+		// rotate the file
+		if !assert.NoError(t, os.Rename(path, path+".1"), `os.Rename should succeed`) {
+			return
+		}
+		appendFile(t, path, "{\"seq\": 4}\n")
+		if !assert.Equal(t, 4, nextSeq(t, f), `values should match`) {
+			return
+		}
+
+		// resume from a previous offset
+		f2, err := json.TailFile(path+".1", offset, json.WithPollInterval(10*time.Millisecond))
+		if !assert.NoError(t, err, `json.TailFile should succeed`) {
+			return
+		}
+		defer f2.Close()
+		appendFile(t, path+".1", "{\"seq\": 5}\n")
+		if !assert.Equal(t, 5, nextSeq(t, f2), `values should match`) {
+			return
+		}
+	})
+	t.Run("truncation and malformed records", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log.ndjson")
+		appendFile(t, path, "{\"seq\": 1}\n{\"seq\": }\n")
+
+		f, err := json.TailFile(path, 0, json.WithPollInterval(10*time.Millisecond))
+		if !assert.NoError(t, err, `json.TailFile should succeed`) {
+			return
+		}
+		defer f.Close()
+
+		if !assert.Equal(t, 1, nextSeq(t, f), `values should match`) {
+			return
+		}
+		if _, err := f.Next(context.Background()); !assert.Error(t, err, `f.Next should fail`) {
+			return
+		}
+
+		if !assert.NoError(t, os.Truncate(path, 0), `os.Truncate should succeed`) {
+			return
+		}
+		appendFile(t, path, "{\"seq\": 2}\n")
+		if !assert.Equal(t, 2, nextSeq(t, f), `values should match`) {
+			return
+		}
+	})
+	t.Run("oversized records", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log.ndjson")
+		long := "{\"pad\": \"" + strings.Repeat("x", 64) + "\"}"
+		appendFile(t, path, "{\"seq\": 1}\n"+long+"\n{\"seq\": 2}\n")
+
+		f, err := json.TailFile(path, 0, json.WithPollInterval(10*time.Millisecond), json.WithMaxLineSize(32))
+		if !assert.NoError(t, err, `json.TailFile should succeed`) {
+			return
+		}
+		defer f.Close()
+
+		if !assert.Equal(t, 1, nextSeq(t, f), `values should match`) {
+			return
+		}
+		// the complete line is rejected, even though it was read at once
+		if _, err := f.Next(context.Background()); !assert.Error(t, err, `f.Next should fail`) {
+			return
+		}
+		if !assert.Equal(t, 2, nextSeq(t, f), `values should match`) {
+			return
+		}
+
+		// an incomplete line is rejected once it exceeds the limit, and
+		// the rest of it is skipped
+		offset := f.Offset()
+		appendFile(t, path, long)
+		if _, err := f.Next(context.Background()); !assert.Error(t, err, `f.Next should fail`) {
+			return
+		}
+		if !assert.Equal(t, offset, f.Offset(), `offset should point to the start of the record`) {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := f.Next(ctx); !assert.Equal(t, context.DeadlineExceeded, err, `f.Next should time out`) {
+			return
+		}
+
+		appendFile(t, path, long+"\n{\"seq\": 3}\n")
+		if !assert.Equal(t, 3, nextSeq(t, f), `values should match`) {
+			return
+		}
+		info, err := os.Stat(path)
+		if !assert.NoError(t, err, `os.Stat should succeed`) {
+			return
+		}
+		if !assert.Equal(t, info.Size(), f.Offset(), `offset should point to the end of the file`) {
+			return
+		}
+	})
+	t.Run("records retained after reading further", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log.ndjson")
+		var sb strings.Builder
+		for i := 0; i < 200; i++ {
+			fmt.Fprintf(&sb, "{\"id\":\"record-%04d\",\"nested\":{\"seq\":%d}}\n", i, i)
+		}
+		appendFile(t, path, sb.String())
+
+		for _, option := range []json.ParseOption{json.WithLazy(true), json.WithUnsafeStrings(true)} {
+			f, err := json.TailFile(path, 0, json.WithPollInterval(10*time.Millisecond), option)
+			if !assert.NoError(t, err, `json.TailFile should succeed`) {
+				return
+			}
+			defer f.Close()
+
+			var records []json.Context
+			for i := 0; i < 200; i++ {
+				j, err := f.Next(context.Background())
+				if !assert.NoError(t, err, `f.Next should succeed`) {
+					return
+				}
+				records = append(records, j)
+			}
+			for i, j := range records {
+				var id string
+				var seq int
+				if !assert.NoError(t, j.MapIndex("id").String(&id), `j.MapIndex.String should succeed`) {
+					return
+				}
+				if !assert.NoError(t, j.MapIndex("nested").MapIndex("seq").Int(&seq), `j.MapIndex.Int should succeed`) {
+					return
+				}
+				if !assert.Equal(t, fmt.Sprintf("record-%04d", i), id, `values should match`) {
+					return
+				}
+				if !assert.Equal(t, i, seq, `values should match`) {
+					return
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package json
+
+import (
+	"strconv"
+	"time"
+)
+
+// timeFormat specifies how time.Time values in a document are
+// marshaled. Either layout or epoch is set. See WithTimeLayout and
+// WithTimeEpoch
+type timeFormat struct {
+	layout string
+	epoch  time.Duration
+}
+
+// timeFormatOption returns the timeFormat specified by option, if any
+func timeFormatOption(option Option) (*timeFormat, bool) {
+	switch option.Name() {
+	case optKeyTimeLayout:
+		return &timeFormat{layout: option.Value().(string)}, true
+	case optKeyTimeEpoch:
+		epoch := option.Value().(time.Duration)
+		if epoch <= 0 {
+			return nil, false
+		}
+		return &timeFormat{epoch: epoch}, true
+	}
+	return nil, false
+}
+
+// writeTime writes t in the format specified by f
+func (e *encodeState) writeTime(t time.Time, f *timeFormat) {
+	if f.epoch == 0 {
+		e.scratch.Reset()
+		e.writeString(string(t.AppendFormat(e.scratch.AvailableBuffer(), f.layout)))
+		return
+	}
+
+	var n int64
+	switch f.epoch {
+	case time.Second:
+		n = t.Unix()
+	case time.Millisecond:
+		n = t.UnixMilli()
+	case time.Microsecond:
+		n = t.UnixMicro()
+	case time.Nanosecond:
+		n = t.UnixNano()
+	default:
+		n = t.UnixNano() / int64(f.epoch)
+	}
+	e.buf.Write(strconv.AppendInt(e.scratch.AvailableBuffer(), n, 10))
+}
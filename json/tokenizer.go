@@ -0,0 +1,997 @@
+package json
+
+import (
+	"bytes"
+	stdlib "encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// TokenKind describes the kind of a Token
+type TokenKind int
+
+const (
+	InvalidToken TokenKind = iota
+	ObjectStartToken
+	ObjectEndToken
+	ArrayStartToken
+	ArrayEndToken
+	KeyToken
+	StringToken
+	NumberToken
+	BoolToken
+	NullToken
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case ObjectStartToken:
+		return "ObjectStart"
+	case ObjectEndToken:
+		return "ObjectEnd"
+	case ArrayStartToken:
+		return "ArrayStart"
+	case ArrayEndToken:
+		return "ArrayEnd"
+	case KeyToken:
+		return "Key"
+	case StringToken:
+		return "String"
+	case NumberToken:
+		return "Number"
+	case BoolToken:
+		return "Bool"
+	case NullToken:
+		return "Null"
+	default:
+		return "Invalid"
+	}
+}
+
+// Token represents a single event emitted by the Tokenizer
+type Token struct {
+	Kind TokenKind
+
+	// Value holds the value of the token: a string for KeyToken and
+	// StringToken, a json.Number (from encoding/json) for NumberToken,
+	// and a bool for BoolToken. For all other kinds it is nil
+	Value interface{}
+
+	// Offset is the byte offset of the first byte of the token
+	Offset int64
+	// End is the byte offset immediately after the last byte of the token
+	End int64
+	// Line and Column are the 1-based line and column (counted in bytes)
+	// of the first byte of the token
+	Line   int
+	Column int
+}
+
+// ErrLimitExceeded is wrapped by the errors returned when the input
+// exceeds one of the limits specified for the parser, such as
+// WithMaxSize or WithMaxDepth
+var ErrLimitExceeded = errors.New(`limit exceeded`)
+
+// SyntaxError describes a violation of the JSON syntax, along with
+// its location in the input
+type SyntaxError struct {
+	msg    string
+	Offset int64
+	Line   int
+	Column int
+	// Excerpt holds the input surrounding the error, taken from the
+	// same line. It may be empty if the input is no longer available
+	Excerpt string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Line == 0 {
+		return e.msg
+	}
+	if e.Excerpt == "" {
+		return fmt.Sprintf(`%s at offset %d (line %d, column %d)`, e.msg, e.Offset, e.Line, e.Column)
+	}
+	return fmt.Sprintf(`%s at offset %d (line %d, column %d) near %q`, e.msg, e.Offset, e.Line, e.Column, e.Excerpt)
+}
+
+type tokenizerState int
+
+const (
+	stateValue tokenizerState = iota
+	stateValueOrArrayEnd
+	stateKeyOrObjectEnd
+	stateKey
+	stateColon
+	stateCommaOrEnd
+)
+
+const tokenizerBufferSize = 4096
+
+// excerptContext is the maximum number of bytes preceding and
+// following the location of a syntax error that is included in
+// SyntaxError.Excerpt. The Tokenizer retains this many consumed bytes
+// when refilling its buffer so that they are available for the excerpt
+const excerptContext = 24
+
+// Tokenizer reads JSON from an io.Reader, and emits a stream of Tokens
+// describing its structure. The Tokenizer validates the syntax of the
+// input as it goes, and reports errors as *SyntaxError.
+//
+// Consecutive top-level values are read one after another, until the
+// end of input is reached
+type Tokenizer struct {
+	src   io.Reader
+	buf   []byte
+	pos   int
+	base  int64 // offset of buf[0] in the input
+	eof   bool
+	rderr error
+
+	line int
+	col  int
+
+	state   tokenizerState
+	stack   []byte
+	counts  []int // number of elements/keys read in each open container
+	scratch []byte
+
+	peeked  bool
+	peekTok Token
+	peekErr error
+
+	// when discard is true, the values of strings and numbers are
+	// validated but not materialized, except for numbers if
+	// keepNumbers is true
+	discard     bool
+	keepNumbers bool
+	// arena is non-nil if strings and numbers should be allocated
+	// from it. See WithArena
+	arena *arena
+	// keyTable and keys are non-nil if object keys should be
+	// interned. See WithKeyTable and WithInternKeys
+	keyTable *KeyTable
+	keys     map[string]string
+	// input is non-nil if strings and numbers that appear verbatim in
+	// the input should refer to it instead of being copied. It holds
+	// the entire input. See WithUnsafeStrings
+	input []byte
+
+	allowComments       bool
+	allowTrailingCommas bool
+	json5               bool
+	allowNonFinite      bool
+
+	maxDepth        int
+	maxStringLength int
+	maxArrayLength  int
+	maxObjectKeys   int
+}
+
+// NewTokenizer creates a new Tokenizer that reads from r
+func NewTokenizer(r io.Reader) *Tokenizer {
+	return &Tokenizer{
+		src:  r,
+		buf:  make([]byte, 0, tokenizerBufferSize),
+		line: 1,
+		col:  1,
+	}
+}
+
+// Depth returns the number of objects and arrays that are currently open
+func (t *Tokenizer) Depth() int {
+	return len(t.stack)
+}
+
+// SetAllowComments specifies whether `//` line comments and `/* */`
+// block comments are allowed wherever whitespace is allowed
+func (t *Tokenizer) SetAllowComments(b bool) {
+	t.allowComments = b
+}
+
+// SetAllowTrailingCommas specifies whether a comma is allowed after
+// the last element of an array or the last member of an object
+func (t *Tokenizer) SetAllowTrailingCommas(b bool) {
+	t.allowTrailingCommas = b
+}
+
+// SetAllowNonFiniteNumbers specifies whether the non-standard
+// literals `NaN`, `Infinity`, and `-Infinity` are accepted as numbers.
+// They are reported as NumberTokens holding the json.Number values
+// "NaN", "Infinity", and "-Infinity"
+func (t *Tokenizer) SetAllowNonFiniteNumbers(b bool) {
+	t.allowNonFinite = b
+}
+
+// SetJSON5 specifies whether the JSON5 syntax extensions are allowed:
+// unquoted object keys, single-quoted strings, additional string
+// escapes, multi-line strings, and hexadecimal numbers as well as
+// numbers with a leading plus sign or leading or trailing decimal point.
+// Enabling JSON5 also enables comments, trailing commas, and
+// non-finite numbers.
+//
+// Values are reported in the same form as their JSON counterparts:
+// in particular, numbers are normalized, so that `0x1F` is reported
+// as the json.Number "31", and `.5` as "0.5"
+func (t *Tokenizer) SetJSON5(b bool) {
+	t.json5 = b
+	if b {
+		t.allowComments = true
+		t.allowTrailingCommas = true
+		t.allowNonFinite = true
+	}
+}
+
+// SetMaxDepth sets the maximum number of objects and arrays that may
+// be open at the same time. If an object or array would exceed the
+// limit, Next returns an error wrapping ErrLimitExceeded.
+// A value of 0 or less means no limit
+func (t *Tokenizer) SetMaxDepth(n int) {
+	t.maxDepth = n
+}
+
+// SetMaxStringLength sets the maximum number of bytes allowed in a
+// string or an object key, excluding the quotes.
+// A value of 0 or less means no limit
+func (t *Tokenizer) SetMaxStringLength(n int) {
+	t.maxStringLength = n
+}
+
+// SetMaxArrayLength sets the maximum number of elements allowed in
+// an array. A value of 0 or less means no limit
+func (t *Tokenizer) SetMaxArrayLength(n int) {
+	t.maxArrayLength = n
+}
+
+// SetMaxObjectKeys sets the maximum number of keys allowed in an
+// object. A value of 0 or less means no limit
+func (t *Tokenizer) SetMaxObjectKeys(n int) {
+	t.maxObjectKeys = n
+}
+
+func (t *Tokenizer) limitError(format string, args ...interface{}) error {
+	args = append(args, t.offset(), t.line, t.col, ErrLimitExceeded)
+	return fmt.Errorf(format+` at offset %d (line %d, column %d): %w`, args...)
+}
+
+// countElement records that a new element or key has been found in
+// the innermost container, and checks it against the limits
+func (t *Tokenizer) countElement(top byte) error {
+	i := len(t.counts) - 1
+	t.counts[i]++
+	if top == '[' {
+		if t.maxArrayLength > 0 && t.counts[i] > t.maxArrayLength {
+			return t.limitError(`array length exceeds maximum of %d`, t.maxArrayLength)
+		}
+		return nil
+	}
+	if t.maxObjectKeys > 0 && t.counts[i] > t.maxObjectKeys {
+		return t.limitError(`number of object keys exceeds maximum of %d`, t.maxObjectKeys)
+	}
+	return nil
+}
+
+// fill reads more data from the source. It returns false if no more
+// data is available
+func (t *Tokenizer) fill() bool {
+	if t.eof {
+		return false
+	}
+
+	// discard consumed bytes, except for the few that may be needed
+	// for the excerpt of a SyntaxError
+	if t.pos > excerptContext {
+		drop := t.pos - excerptContext
+		n := copy(t.buf, t.buf[drop:])
+		t.base += int64(drop)
+		t.buf = t.buf[:n]
+		t.pos = excerptContext
+	}
+
+	if len(t.buf) == cap(t.buf) {
+		buf := make([]byte, len(t.buf), 2*cap(t.buf))
+		copy(buf, t.buf)
+		t.buf = buf
+	}
+
+	for {
+		n, err := t.src.Read(t.buf[len(t.buf):cap(t.buf)])
+		t.buf = t.buf[:len(t.buf)+n]
+		if err != nil {
+			t.eof = true
+			if err != io.EOF {
+				t.rderr = err
+			}
+			return n > 0
+		}
+		if n > 0 {
+			return true
+		}
+	}
+}
+
+func (t *Tokenizer) peekByte() (byte, bool) {
+	if t.pos >= len(t.buf) && !t.fill() {
+		return 0, false
+	}
+	return t.buf[t.pos], true
+}
+
+// advance consumes the byte at the current position
+func (t *Tokenizer) advance() {
+	if t.buf[t.pos] == '\n' {
+		t.line++
+		t.col = 1
+	} else {
+		t.col++
+	}
+	t.pos++
+}
+
+func (t *Tokenizer) offset() int64 {
+	return t.base + int64(t.pos)
+}
+
+func (t *Tokenizer) syntaxError(format string, args ...interface{}) *SyntaxError {
+	return &SyntaxError{
+		msg:     fmt.Sprintf(format, args...),
+		Offset:  t.offset(),
+		Line:    t.line,
+		Column:  t.col,
+		Excerpt: t.excerpt(),
+	}
+}
+
+// excerpt returns the buffered input surrounding the current position,
+// without crossing line boundaries
+func (t *Tokenizer) excerpt() string {
+	start := t.pos - excerptContext
+	if start < 0 {
+		start = 0
+	}
+	if i := bytes.LastIndexByte(t.buf[start:t.pos], '\n'); i >= 0 {
+		start += i + 1
+	}
+
+	end := t.pos + excerptContext
+	if end > len(t.buf) {
+		end = len(t.buf)
+	}
+	if i := bytes.IndexByte(t.buf[t.pos:end], '\n'); i >= 0 {
+		end = t.pos + i
+	}
+	return string(bytes.TrimRight(t.buf[start:end], "\r"))
+}
+
+func (t *Tokenizer) eofError() error {
+	if t.rderr != nil {
+		return t.rderr
+	}
+	return t.syntaxError(`unexpected end of JSON input`)
+}
+
+func quoteChar(c byte) string {
+	if c == '\'' {
+		return `'\''`
+	}
+	if c == '"' {
+		return `'"'`
+	}
+	s := strconv.Quote(string(c))
+	return "'" + s[1:len(s)-1] + "'"
+}
+
+// skipSpace skips whitespace (and comments, if enabled), and returns
+// the next byte without consuming it. If the end of input is reached,
+// false is returned
+func (t *Tokenizer) skipSpace() (byte, bool, error) {
+	for {
+		c, ok := t.peekByte()
+		if !ok {
+			return 0, false, nil
+		}
+		if c == '/' && t.allowComments {
+			if err := t.skipComment(); err != nil {
+				return 0, false, err
+			}
+			continue
+		}
+		if !isSpace(c) && !(t.json5 && (c == '\v' || c == '\f')) {
+			return c, true, nil
+		}
+		t.advance()
+	}
+}
+
+// skipComment skips a `//` or `/* */` comment. The current byte must be
+// the first slash
+func (t *Tokenizer) skipComment() error {
+	t.advance()
+	c, ok := t.peekByte()
+	if !ok {
+		return t.eofError()
+	}
+
+	switch c {
+	case '/':
+		for {
+			t.advance()
+			c, ok := t.peekByte()
+			if !ok || c == '\n' {
+				return nil
+			}
+		}
+	case '*':
+		t.advance()
+		var star bool
+		for {
+			c, ok := t.peekByte()
+			if !ok {
+				return t.syntaxError(`unexpected end of JSON input in comment`)
+			}
+			t.advance()
+			if star && c == '/' {
+				return nil
+			}
+			star = c == '*'
+		}
+	}
+	return t.syntaxError(`invalid character %s looking for beginning of comment`, quoteChar(c))
+}
+
+// More reports whether there are more tokens available in the current
+// container, or at the top level if no container is open
+func (t *Tokenizer) More() bool {
+	if t.peeked {
+		return t.peekErr == nil && t.peekTok.Kind != ObjectEndToken && t.peekTok.Kind != ArrayEndToken
+	}
+
+	c, ok, err := t.skipSpace()
+	return err == nil && ok && c != ']' && c != '}'
+}
+
+// Buffered returns a reader of the data that has been read from the
+// source, but not yet consumed by the Tokenizer
+func (t *Tokenizer) Buffered() io.Reader {
+	return bytes.NewReader(t.buf[t.pos:])
+}
+
+// ensureEOF checks that nothing but whitespace follows the current
+// position in the input
+func (t *Tokenizer) ensureEOF() error {
+	if t.peeked {
+		if t.peekErr == io.EOF {
+			return nil
+		}
+		if t.peekErr != nil {
+			return t.peekErr
+		}
+		return &SyntaxError{
+			msg:    fmt.Sprintf(`invalid token %s after top-level value`, t.peekTok.Kind),
+			Offset: t.peekTok.Offset,
+			Line:   t.peekTok.Line,
+			Column: t.peekTok.Column,
+		}
+	}
+
+	c, ok, err := t.skipSpace()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return t.rderr
+	}
+	return t.syntaxError(`invalid character %s after top-level value`, quoteChar(c))
+}
+
+// Peek returns the next token without consuming it
+func (t *Tokenizer) Peek() (Token, error) {
+	if !t.peeked {
+		t.peekTok, t.peekErr = t.next()
+		t.peeked = true
+	}
+	return t.peekTok, t.peekErr
+}
+
+// Next returns the next token in the input. When the end of the input
+// is reached after a complete top-level value, io.EOF is returned
+func (t *Tokenizer) Next() (Token, error) {
+	if t.peeked {
+		t.peeked = false
+		return t.peekTok, t.peekErr
+	}
+	return t.next()
+}
+
+func (t *Tokenizer) afterValue() {
+	if len(t.stack) == 0 {
+		t.state = stateValue
+	} else {
+		t.state = stateCommaOrEnd
+	}
+}
+
+func (t *Tokenizer) next() (Token, error) {
+	for {
+		c, ok, err := t.skipSpace()
+		if err != nil {
+			return Token{}, err
+		}
+		if !ok {
+			if len(t.stack) == 0 && t.state == stateValue && t.rderr == nil {
+				return Token{}, io.EOF
+			}
+			return Token{}, t.eofError()
+		}
+
+		switch t.state {
+		case stateCommaOrEnd:
+			top := t.stack[len(t.stack)-1]
+			switch {
+			case c == ',':
+				t.advance()
+				switch {
+				case top == '{' && t.allowTrailingCommas:
+					t.state = stateKeyOrObjectEnd
+				case top == '{':
+					t.state = stateKey
+				case t.allowTrailingCommas:
+					t.state = stateValueOrArrayEnd
+				default:
+					t.state = stateValue
+				}
+				continue
+			case c == '}' && top == '{':
+				return t.endToken(ObjectEndToken), nil
+			case c == ']' && top == '[':
+				return t.endToken(ArrayEndToken), nil
+			}
+			if top == '{' {
+				return Token{}, t.syntaxError(`invalid character %s after object key:value pair`, quoteChar(c))
+			}
+			return Token{}, t.syntaxError(`invalid character %s after array element`, quoteChar(c))
+		case stateColon:
+			if c != ':' {
+				return Token{}, t.syntaxError(`invalid character %s after object key`, quoteChar(c))
+			}
+			t.advance()
+			t.state = stateValue
+			continue
+		case stateKeyOrObjectEnd, stateKey:
+			if c == '}' && t.state == stateKeyOrObjectEnd {
+				return t.endToken(ObjectEndToken), nil
+			}
+			identifier := t.json5 && isIdentifierStart(c)
+			if c != '"' && !(t.json5 && c == '\'') && !identifier {
+				return Token{}, t.syntaxError(`invalid character %s looking for beginning of object key string`, quoteChar(c))
+			}
+			if err := t.countElement('{'); err != nil {
+				return Token{}, err
+			}
+			var tok Token
+			var err error
+			if identifier {
+				tok, err = t.readIdentifier()
+			} else {
+				tok, err = t.readString(KeyToken)
+			}
+			if err != nil {
+				return Token{}, err
+			}
+			t.state = stateColon
+			return tok, nil
+		case stateValueOrArrayEnd:
+			if c == ']' {
+				return t.endToken(ArrayEndToken), nil
+			}
+		}
+
+		if n := len(t.stack); n > 0 && t.stack[n-1] == '[' {
+			if err := t.countElement('['); err != nil {
+				return Token{}, err
+			}
+		}
+		return t.readValue(c)
+	}
+}
+
+func (t *Tokenizer) startToken(kind TokenKind) Token {
+	return Token{Kind: kind, Offset: t.offset(), Line: t.line, Column: t.col}
+}
+
+func (t *Tokenizer) endToken(kind TokenKind) Token {
+	tok := t.startToken(kind)
+	t.advance()
+	tok.End = t.offset()
+	t.stack = t.stack[:len(t.stack)-1]
+	t.counts = t.counts[:len(t.counts)-1]
+	t.afterValue()
+	return tok
+}
+
+func (t *Tokenizer) readValue(c byte) (Token, error) {
+	switch c {
+	case '{', '[':
+		if t.maxDepth > 0 && len(t.stack) >= t.maxDepth {
+			return Token{}, t.limitError(`exceeded maximum nesting depth of %d`, t.maxDepth)
+		}
+		kind := ObjectStartToken
+		t.state = stateKeyOrObjectEnd
+		if c == '[' {
+			kind = ArrayStartToken
+			t.state = stateValueOrArrayEnd
+		}
+		tok := t.startToken(kind)
+		t.advance()
+		tok.End = t.offset()
+		t.stack = append(t.stack, c)
+		t.counts = append(t.counts, 0)
+		return tok, nil
+	case '"', '\'':
+		if c == '\'' && !t.json5 {
+			break
+		}
+		tok, err := t.readString(StringToken)
+		if err != nil {
+			return Token{}, err
+		}
+		t.afterValue()
+		return tok, nil
+	case 't':
+		return t.readLiteral("true", BoolToken, true)
+	case 'f':
+		return t.readLiteral("false", BoolToken, false)
+	case 'n':
+		return t.readLiteral("null", NullToken, nil)
+	case 'N', 'I':
+		if !t.allowNonFinite {
+			break
+		}
+		if c == 'N' {
+			return t.readLiteral("NaN", NumberToken, stdlib.Number("NaN"))
+		}
+		return t.readLiteral("Infinity", NumberToken, stdlib.Number("Infinity"))
+	}
+
+	if t.json5 && (c == '+' || c == '.') || c == '-' || (c >= '0' && c <= '9') {
+		var tok Token
+		var err error
+		if t.json5 {
+			tok, err = t.readNumber5()
+		} else {
+			tok, err = t.readNumber()
+		}
+		if err != nil {
+			return Token{}, err
+		}
+		t.afterValue()
+		return tok, nil
+	}
+	return Token{}, t.syntaxError(`invalid character %s looking for beginning of value`, quoteChar(c))
+}
+
+func (t *Tokenizer) readLiteral(lit string, kind TokenKind, v interface{}) (Token, error) {
+	tok := t.startToken(kind)
+	tok.Value = v
+	if err := t.expectLiteral(lit); err != nil {
+		return Token{}, err
+	}
+	tok.End = t.offset()
+	t.afterValue()
+	return tok, nil
+}
+
+// expectLiteral consumes the bytes of lit, which must appear next
+// in the input
+func (t *Tokenizer) expectLiteral(lit string) error {
+	for i := 0; i < len(lit); i++ {
+		c, ok := t.peekByte()
+		if !ok {
+			return t.eofError()
+		}
+		if c != lit[i] {
+			return t.syntaxError(`invalid character %s in literal %s (expecting %s)`, quoteChar(c), lit, quoteChar(lit[i]))
+		}
+		t.advance()
+	}
+	return nil
+}
+
+// readSignedInfinity reads the `Infinity` following a sign. t.scratch
+// must hold the sign of the number, if it is negative
+func (t *Tokenizer) readSignedInfinity(tok Token) (Token, error) {
+	if err := t.expectLiteral("Infinity"); err != nil {
+		return Token{}, err
+	}
+	tok.End = t.offset()
+	if !t.discard || t.keepNumbers {
+		tok.Value = stdlib.Number(string(t.scratch) + "Infinity")
+	}
+	return tok, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (t *Tokenizer) readNumber() (Token, error) {
+	tok := t.startToken(NumberToken)
+	t.scratch = t.scratch[:0]
+
+	// accept appends the current byte to the number being read
+	accept := func(c byte) {
+		t.scratch = append(t.scratch, c)
+		t.advance()
+	}
+	// digits reads a run of one or more digits
+	digits := func() error {
+		c, ok := t.peekByte()
+		if !ok {
+			return t.eofError()
+		}
+		if !isDigit(c) {
+			return t.syntaxError(`invalid character %s in numeric literal`, quoteChar(c))
+		}
+		for ok && isDigit(c) {
+			accept(c)
+			c, ok = t.peekByte()
+		}
+		return nil
+	}
+
+	c, _ := t.peekByte()
+	if c == '-' {
+		accept(c)
+	}
+
+	c, ok := t.peekByte()
+	if !ok {
+		return Token{}, t.eofError()
+	}
+	if c == 'I' && t.allowNonFinite && len(t.scratch) > 0 {
+		return t.readSignedInfinity(tok)
+	}
+	if c == '0' {
+		accept(c)
+	} else if err := digits(); err != nil {
+		return Token{}, err
+	}
+
+	if c, ok := t.peekByte(); ok && c == '.' {
+		accept(c)
+		if err := digits(); err != nil {
+			return Token{}, err
+		}
+	}
+
+	if c, ok := t.peekByte(); ok && (c == 'e' || c == 'E') {
+		accept(c)
+		if c, ok := t.peekByte(); ok && (c == '+' || c == '-') {
+			accept(c)
+		}
+		if err := digits(); err != nil {
+			return Token{}, err
+		}
+	}
+
+	tok.End = t.offset()
+	switch {
+	case t.discard && !t.keepNumbers:
+	case t.input != nil:
+		tok.Value = stdlib.Number(unsafe.String(&t.input[tok.Offset], len(t.scratch)))
+	default:
+		tok.Value = stdlib.Number(t.string(t.scratch))
+	}
+	return tok, nil
+}
+
+// string returns a string holding a copy of b
+func (t *Tokenizer) string(b []byte) string {
+	if t.arena != nil {
+		return t.arena.string(b)
+	}
+	return string(b)
+}
+
+func hexValue(c byte) rune {
+	switch {
+	case c >= '0' && c <= '9':
+		return rune(c - '0')
+	case c >= 'a' && c <= 'f':
+		return rune(c - 'a' + 10)
+	case c >= 'A' && c <= 'F':
+		return rune(c - 'A' + 10)
+	}
+	return -1
+}
+
+// readHex4 reads the four hex digits following `\u`
+func (t *Tokenizer) readHex4() (rune, error) {
+	var r rune
+	for i := 0; i < 4; i++ {
+		c, ok := t.peekByte()
+		if !ok {
+			return 0, t.eofError()
+		}
+		v := hexValue(c)
+		if v < 0 {
+			return 0, t.syntaxError(`invalid character %s in \u hexadecimal character escape`, quoteChar(c))
+		}
+		r = r*16 + v
+		t.advance()
+	}
+	return r, nil
+}
+
+// readString reads a quoted string. The current byte must be the
+// opening quote, which is either a double quote, or a single quote
+// in JSON5 mode
+func (t *Tokenizer) readString(kind TokenKind) (Token, error) {
+	tok := t.startToken(kind)
+	quote, _ := t.peekByte()
+	t.advance()
+
+	// fast path: the entire string is in the buffer, and it contains
+	// no escape sequences or non-ASCII characters
+	for i := t.pos; i < len(t.buf); i++ {
+		c := t.buf[i]
+		if c == quote {
+			if t.maxStringLength > 0 && i-t.pos > t.maxStringLength {
+				return Token{}, t.limitError(`string length exceeds maximum of %d`, t.maxStringLength)
+			}
+			switch {
+			case t.discard:
+			case kind == KeyToken && (t.keys != nil || t.keyTable != nil):
+				tok.Value = t.key(t.buf[t.pos:i])
+			case t.input != nil && i > t.pos:
+				tok.Value = unsafe.String(&t.input[t.base+int64(t.pos)], i-t.pos)
+			case kind == KeyToken:
+				tok.Value = t.key(t.buf[t.pos:i])
+			default:
+				tok.Value = t.string(t.buf[t.pos:i])
+			}
+			t.col += i + 1 - t.pos
+			t.pos = i + 1
+			tok.End = t.offset()
+			return tok, nil
+		}
+		if c == '\\' || c < 0x20 || c >= utf8.RuneSelf {
+			break
+		}
+	}
+
+	t.scratch = t.scratch[:0]
+	for {
+		if t.maxStringLength > 0 && len(t.scratch) > t.maxStringLength {
+			return Token{}, t.limitError(`string length exceeds maximum of %d`, t.maxStringLength)
+		}
+
+		c, ok := t.peekByte()
+		if !ok {
+			return Token{}, t.eofError()
+		}
+
+		switch {
+		case c == quote:
+			t.advance()
+			tok.End = t.offset()
+			switch {
+			case t.discard:
+			case !utf8.Valid(t.scratch):
+				tok.Value = toValidUTF8(t.scratch)
+			case kind == KeyToken:
+				tok.Value = t.key(t.scratch)
+			default:
+				tok.Value = t.string(t.scratch)
+			}
+			return tok, nil
+		case c < 0x20:
+			return Token{}, t.syntaxError(`invalid character %s in string literal`, quoteChar(c))
+		case c == '\\':
+			t.advance()
+			c, ok = t.peekByte()
+			if !ok {
+				return Token{}, t.eofError()
+			}
+			switch c {
+			case '"', '\\', '/':
+				t.scratch = append(t.scratch, c)
+			case 'b':
+				t.scratch = append(t.scratch, '\b')
+			case 'f':
+				t.scratch = append(t.scratch, '\f')
+			case 'n':
+				t.scratch = append(t.scratch, '\n')
+			case 'r':
+				t.scratch = append(t.scratch, '\r')
+			case 't':
+				t.scratch = append(t.scratch, '\t')
+			case 'u':
+				t.advance()
+				r, err := t.readUnicodeEscape()
+				if err != nil {
+					return Token{}, err
+				}
+				t.scratch = utf8.AppendRune(t.scratch, r)
+				continue
+			default:
+				if !t.json5 {
+					return Token{}, t.syntaxError(`invalid character %s in string escape code`, quoteChar(c))
+				}
+				if err := t.readEscape5(c); err != nil {
+					return Token{}, err
+				}
+				continue
+			}
+			t.advance()
+		default:
+			t.scratch = append(t.scratch, c)
+			t.advance()
+		}
+	}
+}
+
+// readUnicodeEscape reads the hex digits of a `\u` escape, including
+// the second half of a surrogate pair if present
+func (t *Tokenizer) readUnicodeEscape() (rune, error) {
+	r, err := t.readHex4()
+	if err != nil {
+		return 0, err
+	}
+
+	if !utf16.IsSurrogate(r) {
+		return r, nil
+	}
+
+	// a surrogate must be followed by `\u` and the other half
+	// of the pair. Otherwise it's replaced by U+FFFD
+	if c, ok := t.peekByte(); !ok || c != '\\' {
+		return utf8.RuneError, nil
+	}
+	if t.pos+1 >= len(t.buf) && !t.fillKeep() {
+		return utf8.RuneError, nil
+	}
+	if t.buf[t.pos+1] != 'u' {
+		return utf8.RuneError, nil
+	}
+	t.advance()
+	t.advance()
+
+	r2, err := t.readHex4()
+	if err != nil {
+		return 0, err
+	}
+	if dec := utf16.DecodeRune(r, r2); dec != utf8.RuneError {
+		return dec, nil
+	}
+	// the second half is not a surrogate. Emit a replacement for
+	// the first one, and keep the second one as is
+	t.scratch = utf8.AppendRune(t.scratch, utf8.RuneError)
+	return r2, nil
+}
+
+// fillKeep makes sure at least two bytes from the current position are
+// available in the buffer
+func (t *Tokenizer) fillKeep() bool {
+	for t.pos+1 >= len(t.buf) {
+		if !t.fill() {
+			return false
+		}
+	}
+	return true
+}
+
+// toValidUTF8 replaces each invalid byte in b with U+FFFD
+func toValidUTF8(b []byte) string {
+	dst := make([]byte, 0, len(b)+8)
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		dst = utf8.AppendRune(dst, r)
+		b = b[size:]
+	}
+	return string(dst)
+}
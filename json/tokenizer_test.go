@@ -0,0 +1,154 @@
+package json_test
+
+import (
+	stdlib "encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/lestrrat-go/json"
+	"github.com/stretchr/testify/assert"
+)
+
+func collectTokens(r io.Reader) ([]json.Token, error) {
+	var list []json.Token
+	t := json.NewTokenizer(r)
+	for {
+		tok, err := t.Next()
+		if err == io.EOF {
+			return list, nil
+		}
+		if err != nil {
+			return list, err
+		}
+		list = append(list, tok)
+	}
+}
+
+func TestTokenizer(t *testing.T) {
+	t.Run("token stream", func(t *testing.T) {
+		const src = `{"foo": [1, -2.5e3, true],
+ "bar": null, "baz": "q\"uux"}`
+
+		tokens, err := collectTokens(strings.NewReader(src))
+		if !assert.NoError(t, err, `tokenizing should succeed`) {
+			return
+		}
+
+		type simpleToken struct {
+			Kind  json.TokenKind
+			Value interface{}
+		}
+		var simple []simpleToken
+		for _, tok := range tokens {
+			simple = append(simple, simpleToken{Kind: tok.Kind, Value: tok.Value})
+		}
+
+		expected := []simpleToken{
+			{Kind: json.ObjectStartToken},
+			{Kind: json.KeyToken, Value: "foo"},
+			{Kind: json.ArrayStartToken},
+			{Kind: json.NumberToken, Value: stdlib.Number("1")},
+			{Kind: json.NumberToken, Value: stdlib.Number("-2.5e3")},
+			{Kind: json.BoolToken, Value: true},
+			{Kind: json.ArrayEndToken},
+			{Kind: json.KeyToken, Value: "bar"},
+			{Kind: json.NullToken},
+			{Kind: json.KeyToken, Value: "baz"},
+			{Kind: json.StringToken, Value: `q"uux`},
+			{Kind: json.ObjectEndToken},
+		}
+		if !assert.Equal(t, expected, simple, `tokens should match`) {
+			return
+		}
+
+		// "bar" is on the second line
+		bar := tokens[7]
+		if !assert.Equal(t, int64(28), bar.Offset, `offset should match`) {
+			return
+		}
+		if !assert.Equal(t, int64(33), bar.End, `end offset should match`) {
+			return
+		}
+		if !assert.Equal(t, 2, bar.Line, `line should match`) {
+			return
+		}
+		if !assert.Equal(t, 2, bar.Column, `column should match`) {
+			return
+		}
+	})
+	t.Run("multiple top-level values", func(t *testing.T) {
+		tokens, err := collectTokens(strings.NewReader(`1 "two" [3]`))
+		if !assert.NoError(t, err, `tokenizing should succeed`) {
+			return
+		}
+		if !assert.Len(t, tokens, 5, `there should be 5 tokens`) {
+			return
+		}
+	})
+	t.Run("compare with encoding/json", func(t *testing.T) {
+		srcs := []string{
+			`{"a": {"b": [[], {}, [null, false]]}, "c": ""}`,
+			`"é😀 \\ \/ \b\f\n\r\t"`,
+			`"\ud83d unpaired"`,
+			"\"invalid \xff utf8\"",
+			`"日本語"`,
+			`[0, -0, 1.5, 1e10, 1E-2, 123456789012345678901234567890]`,
+		}
+		for _, src := range srcs {
+			var expected interface{}
+			dec := stdlib.NewDecoder(strings.NewReader(src))
+			dec.UseNumber()
+			if !assert.NoError(t, dec.Decode(&expected), `stdlib decode should succeed`) {
+				return
+			}
+
+			// read one byte at a time, to exercise buffer boundaries
+			j, err := json.ParseReader(iotest.OneByteReader(strings.NewReader(src)))
+			if !assert.NoError(t, err, `json.ParseReader should succeed for %s`, src) {
+				return
+			}
+			buf1, _ := stdlib.Marshal(expected)
+			buf2, err := j.MarshalJSON()
+			if !assert.NoError(t, err, `j.MarshalJSON should succeed`) {
+				return
+			}
+			if !assert.Equal(t, string(buf1), string(buf2), `values should match for %s`, src) {
+				return
+			}
+		}
+	})
+	t.Run("syntax errors", func(t *testing.T) {
+		tests := []struct {
+			Src    string
+			Offset int64
+		}{
+			{Src: `{"foo" 1}`, Offset: 7},
+			{Src: `{"foo": 1,}`, Offset: 10},
+			{Src: `[1 2]`, Offset: 3},
+			{Src: `[01]`, Offset: 2},
+			{Src: `{foo: 1}`, Offset: 1},
+			{Src: `[tru]`, Offset: 4},
+			{Src: `["a` + "\n" + `"]`, Offset: 3},
+			{Src: `[1.]`, Offset: 3},
+			{Src: `[-]`, Offset: 2},
+			{Src: `["\x"]`, Offset: 3},
+			{Src: `[1, 2`, Offset: 5},
+			{Src: `}`, Offset: 0},
+		}
+		for _, test := range tests {
+			_, err := collectTokens(strings.NewReader(test.Src))
+			if !assert.Error(t, err, `tokenizing %s should fail`, test.Src) {
+				return
+			}
+			serr, ok := err.(*json.SyntaxError)
+			if !assert.True(t, ok, `error should be a *json.SyntaxError (%T)`, err) {
+				return
+			}
+			if !assert.Equal(t, test.Offset, serr.Offset, `offset should match for %s`, test.Src) {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,929 @@
+package toml
+
+import (
+	"bytes"
+	stdlib "encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lestrrat-go/json"
+)
+
+// tableKind specifies how a table was defined, which determines
+// whether it can be extended later in the document
+type tableKind int
+
+const (
+	// tableImplicit is a table created as the parent of a table header,
+	// which may still be defined by its own header
+	tableImplicit tableKind = iota
+	// tableExplicit is a table defined by a header
+	tableExplicit
+	// tableDotted is a table defined by dotted keys, which may only be
+	// extended by other dotted keys
+	tableDotted
+	// tableInline is an inline table, which cannot be extended
+	tableInline
+)
+
+// table is a TOML table being parsed. values holds either *table,
+// *array, or plain values, and keys holds the keys in document order
+type table struct {
+	kind   tableKind
+	keys   []string
+	values map[string]interface{}
+}
+
+func newTable(kind tableKind) *table {
+	return &table{kind: kind, values: make(map[string]interface{})}
+}
+
+func (t *table) set(key string, v interface{}) {
+	t.keys = append(t.keys, key)
+	t.values[key] = v
+}
+
+// freeze marks t and the tables nested in it as inline tables
+func (t *table) freeze() {
+	t.kind = tableInline
+	for _, v := range t.values {
+		if sub, ok := v.(*table); ok {
+			sub.freeze()
+		}
+	}
+}
+
+// array is a TOML array. tables is true if the array was defined
+// by array of tables headers, in which case it can be extended
+type array struct {
+	tables bool
+	elems  []interface{}
+}
+
+// build sets the contents of t to c, which must point to an empty
+// JSON object. Fields are added one by one so that their order is
+// recorded by the Context
+func (t *table) build(c json.Context) {
+	for _, key := range t.keys {
+		switch v := t.values[key].(type) {
+		case *table:
+			c.SetMapIndex(key, map[string]interface{}{})
+			v.build(c.MapIndex(key))
+		case *array:
+			c.SetMapIndex(key, v.placeholder())
+			v.build(c.MapIndex(key))
+		default:
+			c.SetMapIndex(key, v)
+		}
+	}
+}
+
+// placeholder returns the slice that should be stored for a before
+// calling build. Containers are represented by empty values, so that
+// they can be populated through the Context
+func (a *array) placeholder() []interface{} {
+	l := make([]interface{}, len(a.elems))
+	for i, elem := range a.elems {
+		switch elem := elem.(type) {
+		case *table:
+			l[i] = map[string]interface{}{}
+		case *array:
+			l[i] = elem.placeholder()
+		default:
+			l[i] = elem
+		}
+	}
+	return l
+}
+
+func (a *array) build(c json.Context) {
+	for i, elem := range a.elems {
+		switch elem := elem.(type) {
+		case *table:
+			elem.build(c.Index(i))
+		case *array:
+			elem.build(c.Index(i))
+		}
+	}
+}
+
+// parser reads a TOML document
+type parser struct {
+	data    []byte
+	pos     int
+	root    *table
+	current *table
+}
+
+func newParser(data []byte) *parser {
+	root := newTable(tableExplicit)
+	return &parser{data: data, root: root, current: root}
+}
+
+// errorf creates an error that includes the current position
+func (p *parser) errorf(format string, args ...interface{}) error {
+	line := 1 + bytes.Count(p.data[:p.pos], []byte{'\n'})
+	column := 1 + utf8.RuneCount(p.data[bytes.LastIndexByte(p.data[:p.pos], '\n')+1:p.pos])
+	return fmt.Errorf(`%s at line %d, column %d`, fmt.Sprintf(format, args...), line, column)
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.data[p.pos]
+}
+
+func (p *parser) hasPrefix(s string) bool {
+	return bytes.HasPrefix(p.data[p.pos:], []byte(s))
+}
+
+func (p *parser) skipSpace() {
+	for !p.eof() && (p.data[p.pos] == ' ' || p.data[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// skipComment skips a comment, if one starts at the current position
+func (p *parser) skipComment() error {
+	if p.peek() != '#' {
+		return nil
+	}
+	for !p.eof() && p.data[p.pos] != '\n' {
+		if c := p.data[p.pos]; isControl(c) && c != '\r' {
+			return p.errorf(`invalid control character %q in comment`, c)
+		}
+		p.pos++
+	}
+	return nil
+}
+
+// newline consumes a newline, and reports whether there was one
+func (p *parser) newline() bool {
+	switch {
+	case p.hasPrefix("\n"):
+		p.pos++
+	case p.hasPrefix("\r\n"):
+		p.pos += 2
+	default:
+		return false
+	}
+	return true
+}
+
+// skipBlank skips whitespace, newlines, and comments, as allowed
+// between the elements of arrays
+func (p *parser) skipBlank() error {
+	for {
+		p.skipSpace()
+		if err := p.skipComment(); err != nil {
+			return err
+		}
+		if !p.newline() {
+			return nil
+		}
+	}
+}
+
+// endOfLine consumes the rest of the line after a key/value pair or
+// a table header, which may only contain whitespace and a comment
+func (p *parser) endOfLine() error {
+	p.skipSpace()
+	if err := p.skipComment(); err != nil {
+		return err
+	}
+	if !p.eof() && !p.newline() {
+		return p.errorf(`expected newline, found %q`, p.peek())
+	}
+	return nil
+}
+
+func (p *parser) parse() error {
+	if !utf8.Valid(p.data) {
+		return errors.New(`document is not valid UTF-8`)
+	}
+
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil
+		}
+
+		switch c := p.peek(); {
+		case c == '#':
+			if err := p.endOfLine(); err != nil {
+				return err
+			}
+		case c == '\n' || c == '\r':
+			if !p.newline() {
+				return p.errorf(`invalid carriage return`)
+			}
+		case c == '[':
+			if err := p.header(); err != nil {
+				return err
+			}
+		default:
+			if err := p.keyValue(p.current); err != nil {
+				return err
+			}
+			if err := p.endOfLine(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// header reads a table header or an array of tables header
+func (p *parser) header() error {
+	p.pos++
+	arrayOfTables := p.peek() == '['
+	if arrayOfTables {
+		p.pos++
+	}
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	closing := "]"
+	if arrayOfTables {
+		closing = "]]"
+	}
+	if !p.hasPrefix(closing) {
+		return p.errorf(`expected %q after table name`, closing)
+	}
+	p.pos += len(closing)
+
+	t := p.root
+	for _, key := range keys[:len(keys)-1] {
+		switch v := t.values[key].(type) {
+		case nil:
+			sub := newTable(tableImplicit)
+			t.set(key, sub)
+			t = sub
+		case *table:
+			if v.kind == tableInline {
+				return p.errorf(`cannot extend inline table %q`, key)
+			}
+			t = v
+		case *array:
+			if !v.tables {
+				return p.errorf(`cannot extend array %q`, key)
+			}
+			t = v.elems[len(v.elems)-1].(*table)
+		default:
+			return p.errorf(`key %q is already defined as a value`, key)
+		}
+	}
+
+	name := keys[len(keys)-1]
+	switch v := t.values[name].(type) {
+	case nil:
+		sub := newTable(tableExplicit)
+		if arrayOfTables {
+			t.set(name, &array{tables: true, elems: []interface{}{sub}})
+		} else {
+			t.set(name, sub)
+		}
+		p.current = sub
+	case *table:
+		if arrayOfTables || v.kind != tableImplicit {
+			return p.errorf(`table %q is already defined`, strings.Join(keys, "."))
+		}
+		v.kind = tableExplicit
+		p.current = v
+	case *array:
+		if !arrayOfTables || !v.tables {
+			return p.errorf(`key %q is already defined as an array`, strings.Join(keys, "."))
+		}
+		sub := newTable(tableExplicit)
+		v.elems = append(v.elems, sub)
+		p.current = sub
+	default:
+		return p.errorf(`key %q is already defined as a value`, strings.Join(keys, "."))
+	}
+	return p.endOfLine()
+}
+
+// key reads a possibly dotted key, and the whitespace surrounding it
+func (p *parser) key() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		key, err := p.simpleKey()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		p.skipSpace()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c == '_' || c == '-' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func (p *parser) simpleKey() (string, error) {
+	switch p.peek() {
+	case '"':
+		if p.hasPrefix(`"""`) {
+			return "", p.errorf(`multi-line strings cannot be used as keys`)
+		}
+		return p.basicString()
+	case '\'':
+		if p.hasPrefix(`'''`) {
+			return "", p.errorf(`multi-line strings cannot be used as keys`)
+		}
+		return p.literalString()
+	}
+
+	start := p.pos
+	for !p.eof() && isBareKeyChar(p.data[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		if p.eof() {
+			return "", p.errorf(`expected key, found end of document`)
+		}
+		return "", p.errorf(`invalid character %q in key`, p.peek())
+	}
+	return string(p.data[start:p.pos]), nil
+}
+
+// keyValue reads a key/value pair, and stores it in t
+func (p *parser) keyValue(t *table) error {
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	if p.peek() != '=' {
+		return p.errorf(`expected '=' after key`)
+	}
+	p.pos++
+	p.skipSpace()
+	v, err := p.value()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys[:len(keys)-1] {
+		switch sub := t.values[key].(type) {
+		case nil:
+			next := newTable(tableDotted)
+			t.set(key, next)
+			t = next
+		case *table:
+			if sub.kind != tableDotted {
+				return p.errorf(`cannot extend table %q using dotted keys`, key)
+			}
+			t = sub
+		default:
+			return p.errorf(`key %q is already defined as a value`, key)
+		}
+	}
+
+	name := keys[len(keys)-1]
+	if _, ok := t.values[name]; ok {
+		return p.errorf(`duplicate key %q`, strings.Join(keys, "."))
+	}
+	t.set(name, v)
+	return nil
+}
+
+func (p *parser) value() (interface{}, error) {
+	if p.eof() {
+		return nil, p.errorf(`expected value, found end of document`)
+	}
+
+	switch c := p.peek(); c {
+	case '"':
+		if p.hasPrefix(`"""`) {
+			return p.multiLineBasicString()
+		}
+		return p.basicString()
+	case '\'':
+		if p.hasPrefix(`'''`) {
+			return p.multiLineLiteralString()
+		}
+		return p.literalString()
+	case '[':
+		return p.array()
+	case '{':
+		return p.inlineTable()
+	}
+
+	start := p.pos
+	tok := p.token()
+	switch tok {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "inf", "+inf":
+		return stdlib.Number("Infinity"), nil
+	case "-inf":
+		return stdlib.Number("-Infinity"), nil
+	case "nan", "+nan", "-nan":
+		return stdlib.Number("NaN"), nil
+	case "":
+		return nil, p.errorf(`invalid character %q at the start of a value`, p.peek())
+	}
+
+	v, ok := parseDateTime(tok)
+	if !ok {
+		v, ok = parseNumber(tok)
+	}
+	if !ok {
+		p.pos = start
+		return nil, p.errorf(`invalid value %q`, tok)
+	}
+	return v, nil
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', ',', ']', '}', '#':
+		return true
+	}
+	return false
+}
+
+// token reads a bare value, such as a number or a date-time
+func (p *parser) token() string {
+	start := p.pos
+	for !p.eof() && !isDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	// the date and the time of a date-time may be separated by a space
+	if p.pos-start == 10 && p.data[start+4] == '-' && p.hasPrefix(" ") {
+		rest := p.data[p.pos+1:]
+		if len(rest) >= 3 && isDigit(rest[0]) && isDigit(rest[1]) && rest[2] == ':' {
+			p.pos++
+			for !p.eof() && !isDelimiter(p.data[p.pos]) {
+				p.pos++
+			}
+		}
+	}
+	return string(p.data[start:p.pos])
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// digitValue returns the value of the hexadecimal digit c, or 16 if
+// c is not a digit
+func digitValue(c byte) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'a' <= c && c <= 'f':
+		return int(c-'a') + 10
+	case 'A' <= c && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return 16
+}
+
+func isControl(c byte) bool {
+	return (c < 0x20 && c != '\t') || c == 0x7f
+}
+
+// parseDateTime parses the TOML date-time types. Offset date-times
+// are returned as time.Time, and all others as strings
+func parseDateTime(s string) (interface{}, bool) {
+	isDate := len(s) >= 10 && s[4] == '-' && s[7] == '-'
+	isTime := len(s) >= 8 && s[2] == ':' && s[5] == ':'
+	if !isDate && !isTime {
+		return nil, false
+	}
+
+	if isTime {
+		if _, err := time.Parse("15:04:05", s); err != nil {
+			return nil, false
+		}
+		return s, true
+	}
+
+	if len(s) == 10 {
+		if _, err := time.Parse(time.DateOnly, s); err != nil {
+			return nil, false
+		}
+		return s, true
+	}
+
+	if len(s) < 19 {
+		return nil, false
+	}
+	switch s[10] {
+	case 'T', 't', ' ':
+	default:
+		return nil, false
+	}
+	normalized := []byte(s)
+	normalized[10] = 'T'
+	if last := len(normalized) - 1; normalized[last] == 'z' {
+		normalized[last] = 'Z'
+	}
+	s = string(normalized)
+
+	hasOffset := strings.HasSuffix(s, "Z") || strings.ContainsAny(s[19:], "+-")
+	if hasOffset {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, false
+		}
+		return t, true
+	}
+	if _, err := time.Parse("2006-01-02T15:04:05", s); err != nil {
+		return nil, false
+	}
+	return s, true
+}
+
+// parseNumber parses a TOML integer or float, and returns it as a
+// json.Number
+func parseNumber(s string) (interface{}, bool) {
+	if len(s) > 2 && s[0] == '0' {
+		base := 0
+		switch s[1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+		if base != 0 {
+			digits, ok := stripUnderscores(s[2:], func(c byte) bool {
+				return digitValue(c) < base
+			})
+			if !ok {
+				return nil, false
+			}
+			var n big.Int
+			if _, ok := n.SetString(digits, base); !ok || !n.IsInt64() {
+				return nil, false
+			}
+			return stdlib.Number(n.String()), true
+		}
+	}
+
+	// validate the grammar of decimal numbers, which is stricter than
+	// that of strconv
+	var sb strings.Builder
+	rest := s
+	if rest != "" && (rest[0] == '+' || rest[0] == '-') {
+		if rest[0] == '-' {
+			sb.WriteByte('-')
+		}
+		rest = rest[1:]
+	}
+
+	end := strings.IndexAny(rest, ".eE")
+	if end < 0 {
+		end = len(rest)
+	}
+	intPart, ok := stripUnderscores(rest[:end], isDigit)
+	if !ok || (len(intPart) > 1 && intPart[0] == '0') {
+		return nil, false
+	}
+	sb.WriteString(intPart)
+	rest = rest[end:]
+	isFloat := rest != ""
+
+	if strings.HasPrefix(rest, ".") {
+		end := strings.IndexAny(rest, "eE")
+		if end < 0 {
+			end = len(rest)
+		}
+		frac, ok := stripUnderscores(rest[1:end], isDigit)
+		if !ok {
+			return nil, false
+		}
+		sb.WriteByte('.')
+		sb.WriteString(frac)
+		rest = rest[end:]
+	}
+	if rest != "" {
+		// rest must be an exponent
+		sb.WriteByte(rest[0])
+		rest = rest[1:]
+		if rest != "" && (rest[0] == '+' || rest[0] == '-') {
+			sb.WriteByte(rest[0])
+			rest = rest[1:]
+		}
+		exp, ok := stripUnderscores(rest, isDigit)
+		if !ok {
+			return nil, false
+		}
+		sb.WriteString(exp)
+	}
+
+	n := sb.String()
+	if !isFloat {
+		if _, err := strconv.ParseInt(n, 10, 64); err != nil {
+			return nil, false
+		}
+	}
+	return stdlib.Number(n), true
+}
+
+// stripUnderscores removes the underscores from s, which must consist
+// of at least one digit, with each underscore surrounded by digits
+func stripUnderscores(s string, isDigit func(byte) bool) (string, bool) {
+	if s == "" {
+		return "", false
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' {
+			if i == 0 || i == len(s)-1 || s[i-1] == '_' {
+				return "", false
+			}
+			continue
+		}
+		if !isDigit(c) {
+			return "", false
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String(), true
+}
+
+func (p *parser) array() (interface{}, error) {
+	p.pos++
+	a := &array{elems: []interface{}{}}
+	for {
+		if err := p.skipBlank(); err != nil {
+			return nil, err
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return a, nil
+		}
+
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		a.elems = append(a.elems, v)
+
+		if err := p.skipBlank(); err != nil {
+			return nil, err
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return a, nil
+		default:
+			if p.eof() {
+				return nil, p.errorf(`unterminated array`)
+			}
+			return nil, p.errorf(`expected ',' or ']' in array, found %q`, p.peek())
+		}
+	}
+}
+
+func (p *parser) inlineTable() (interface{}, error) {
+	p.pos++
+	t := newTable(tableDotted)
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+		t.freeze()
+		return t, nil
+	}
+
+	for {
+		if err := p.keyValue(t); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			t.freeze()
+			return t, nil
+		default:
+			if p.eof() {
+				return nil, p.errorf(`unterminated inline table`)
+			}
+			return nil, p.errorf(`expected ',' or '}' in inline table, found %q`, p.peek())
+		}
+	}
+}
+
+func (p *parser) basicString() (string, error) {
+	p.pos++
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf(`unterminated string`)
+		}
+		c := p.data[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return sb.String(), nil
+		case c == '\\':
+			if err := p.escape(&sb); err != nil {
+				return "", err
+			}
+		case isControl(c):
+			return "", p.errorf(`invalid control character %q in string`, c)
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *parser) multiLineBasicString() (string, error) {
+	p.pos += 3
+	p.newline()
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf(`unterminated string`)
+		}
+		if p.hasPrefix(`"""`) {
+			return p.closeMultiLine(&sb, '"')
+		}
+
+		c := p.data[p.pos]
+		switch {
+		case c == '\\':
+			if p.lineEndingBackslash() {
+				continue
+			}
+			if err := p.escape(&sb); err != nil {
+				return "", err
+			}
+		case c == '\n' || c == '\r':
+			if !p.newline() {
+				return "", p.errorf(`invalid carriage return in string`)
+			}
+			sb.WriteByte('\n')
+		case isControl(c):
+			return "", p.errorf(`invalid control character %q in string`, c)
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// closeMultiLine consumes the closing delimiter of a multi-line string.
+// Up to two quotes immediately before it are part of the string
+func (p *parser) closeMultiLine(sb *strings.Builder, quote byte) (string, error) {
+	n := 0
+	for !p.eof() && p.data[p.pos] == quote {
+		n++
+		p.pos++
+	}
+	if n > 5 {
+		return "", p.errorf(`too many quotes at the end of string`)
+	}
+	for i := 3; i < n; i++ {
+		sb.WriteByte(quote)
+	}
+	return sb.String(), nil
+}
+
+// lineEndingBackslash consumes a backslash at the end of a line, and
+// all whitespace and newlines following it
+func (p *parser) lineEndingBackslash() bool {
+	i := p.pos + 1
+	for i < len(p.data) && (p.data[i] == ' ' || p.data[i] == '\t') {
+		i++
+	}
+	if i < len(p.data) && p.data[i] == '\r' {
+		i++
+	}
+	if i >= len(p.data) || p.data[i] != '\n' {
+		return false
+	}
+
+	p.pos = i + 1
+	for !p.eof() {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\n':
+			p.pos++
+		case '\r':
+			if !p.hasPrefix("\r\n") {
+				return true
+			}
+			p.pos++
+		default:
+			return true
+		}
+	}
+	return true
+}
+
+func (p *parser) escape(sb *strings.Builder) error {
+	p.pos++
+	if p.eof() {
+		return p.errorf(`unterminated string`)
+	}
+	c := p.data[p.pos]
+	p.pos++
+	switch c {
+	case 'b':
+		sb.WriteByte('\b')
+	case 't':
+		sb.WriteByte('\t')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'r':
+		sb.WriteByte('\r')
+	case '"', '\\':
+		sb.WriteByte(c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if len(p.data)-p.pos < n {
+			return p.errorf(`invalid unicode escape`)
+		}
+		v, err := strconv.ParseUint(string(p.data[p.pos:p.pos+n]), 16, 32)
+		if err != nil || !utf8.ValidRune(rune(v)) {
+			return p.errorf(`invalid unicode escape`)
+		}
+		sb.WriteRune(rune(v))
+		p.pos += n
+	default:
+		p.pos--
+		return p.errorf(`invalid escape sequence \%c`, c)
+	}
+	return nil
+}
+
+func (p *parser) literalString() (string, error) {
+	p.pos++
+	start := p.pos
+	for {
+		if p.eof() {
+			return "", p.errorf(`unterminated string`)
+		}
+		c := p.data[p.pos]
+		if c == '\'' {
+			s := string(p.data[start:p.pos])
+			p.pos++
+			return s, nil
+		}
+		if isControl(c) {
+			return "", p.errorf(`invalid control character %q in string`, c)
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) multiLineLiteralString() (string, error) {
+	p.pos += 3
+	p.newline()
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf(`unterminated string`)
+		}
+		if p.hasPrefix(`'''`) {
+			return p.closeMultiLine(&sb, '\'')
+		}
+
+		c := p.data[p.pos]
+		switch {
+		case c == '\n' || c == '\r':
+			if !p.newline() {
+				return "", p.errorf(`invalid carriage return in string`)
+			}
+			sb.WriteByte('\n')
+		case isControl(c):
+			return "", p.errorf(`invalid control character %q in string`, c)
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
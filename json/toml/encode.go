@@ -0,0 +1,297 @@
+package toml
+
+import (
+	"bytes"
+	stdlib "encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lestrrat-go/json"
+)
+
+// encoder writes the TOML encoding of Contexts. Containers are visited
+// through Contexts, so that the order of the fields is honored, while
+// the Go values held by them are used to determine their types
+type encoder struct {
+	buf bytes.Buffer
+}
+
+// isArrayOfTables reports whether v should be encoded as an array of
+// tables, which is the case for non-empty arrays of objects
+func isArrayOfTables(v interface{}) bool {
+	l, ok := v.([]interface{})
+	if !ok || len(l) == 0 {
+		return false
+	}
+	for _, elem := range l {
+		if _, ok := elem.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// table writes the fields of the table m, pointed by c, whose name is
+// path. All plain values are written first, followed by the sub-tables
+func (e *encoder) table(path []string, c json.Context, m map[string]interface{}) error {
+	var deferred []string
+	for key, child := range c.Entries() {
+		v := m[key]
+		if _, ok := v.(map[string]interface{}); ok || isArrayOfTables(v) {
+			deferred = append(deferred, key)
+			continue
+		}
+		e.key(key)
+		e.buf.WriteString(` = `)
+		if err := e.value(child, v); err != nil {
+			return fmt.Errorf(`failed to encode key %q: %w`, strings.Join(append(path, key), "."), err)
+		}
+		e.buf.WriteByte('\n')
+	}
+
+	for _, key := range deferred {
+		subpath := append(path[:len(path):len(path)], key)
+		child := c.MapIndex(key)
+		switch v := m[key].(type) {
+		case map[string]interface{}:
+			e.header(`[`, subpath, `]`)
+			if err := e.table(subpath, child, v); err != nil {
+				return err
+			}
+		case []interface{}:
+			for i, elem := range v {
+				e.header(`[[`, subpath, `]]`)
+				if err := e.table(subpath, child.Index(i), elem.(map[string]interface{})); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (e *encoder) header(open string, path []string, end string) {
+	if e.buf.Len() > 0 {
+		e.buf.WriteByte('\n')
+	}
+	e.buf.WriteString(open)
+	for i, key := range path {
+		if i > 0 {
+			e.buf.WriteByte('.')
+		}
+		e.key(key)
+	}
+	e.buf.WriteString(end)
+	e.buf.WriteByte('\n')
+}
+
+// key writes key as a bare key if possible, or as a quoted key
+func (e *encoder) key(key string) {
+	if key != "" && strings.IndexFunc(key, func(r rune) bool {
+		return r >= utf8.RuneSelf || !isBareKeyChar(byte(r))
+	}) < 0 {
+		e.buf.WriteString(key)
+		return
+	}
+	e.string(key)
+}
+
+// value writes v, which is pointed by c, as an inline value
+func (e *encoder) value(c json.Context, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		return errors.New(`null cannot be represented in TOML`)
+	case map[string]interface{}:
+		e.buf.WriteByte('{')
+		var i int
+		for key, child := range c.Entries() {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			i++
+			e.buf.WriteByte(' ')
+			e.key(key)
+			e.buf.WriteString(` = `)
+			if err := e.value(child, v[key]); err != nil {
+				return fmt.Errorf(`failed to encode key %q: %w`, key, err)
+			}
+		}
+		if i > 0 {
+			e.buf.WriteByte(' ')
+		}
+		e.buf.WriteByte('}')
+	case []interface{}:
+		e.buf.WriteByte('[')
+		for i, child := range c.Elements() {
+			if i > 0 {
+				e.buf.WriteString(`, `)
+			}
+			if err := e.value(child, v[i]); err != nil {
+				return fmt.Errorf(`failed to encode element %d: %w`, i, err)
+			}
+		}
+		e.buf.WriteByte(']')
+	case string:
+		e.string(v)
+	case bool:
+		e.buf.WriteString(strconv.FormatBool(v))
+	case stdlib.Number:
+		return e.number(string(v))
+	case float64:
+		e.float(v)
+	case float32:
+		e.float(float64(v))
+	case time.Time:
+		e.buf.WriteString(v.Format(time.RFC3339Nano))
+	case stdlib.Marshaler:
+		// custom number types created via json.WithNumberHook, and
+		// any other value that knows how to represent itself
+		b, err := v.MarshalJSON()
+		if err != nil {
+			return fmt.Errorf(`failed to marshal value of type %T: %w`, v, err)
+		}
+		return e.fromJSON(b)
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			e.buf.WriteString(strconv.FormatInt(rv.Int(), 10))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return e.number(strconv.FormatUint(rv.Uint(), 10))
+		}
+
+		b, err := stdlib.Marshal(v)
+		if err != nil {
+			return fmt.Errorf(`failed to marshal value of type %T: %w`, v, err)
+		}
+		return e.fromJSON(b)
+	}
+	return nil
+}
+
+// fromJSON writes the value encoded as JSON in b, which is used for
+// values that are not part of the document tree
+func (e *encoder) fromJSON(b []byte) error {
+	j, err := json.Parse(b, json.WithPreserveKeyOrder(true), json.WithNonFiniteNumbers(true))
+	if err != nil {
+		return fmt.Errorf(`failed to parse JSON: %w`, err)
+	}
+	var v interface{}
+	switch b := bytes.TrimSpace(b); {
+	case len(b) > 0 && b[0] == '{':
+		var m map[string]interface{}
+		if err := j.Map(&m); err != nil {
+			return err
+		}
+		v = m
+	case len(b) > 0 && b[0] == '[':
+		var l []interface{}
+		if err := j.Slice(&l); err != nil {
+			return err
+		}
+		v = l
+	default:
+		// scalars can be decoded without losing information
+		d := stdlib.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		if err := d.Decode(&v); err != nil {
+			return fmt.Errorf(`failed to decode JSON: %w`, err)
+		}
+	}
+	return e.value(j, v)
+}
+
+// number writes the JSON number n, which is written as a float unless
+// it is an integer that TOML can represent
+func (e *encoder) number(n string) error {
+	switch n {
+	case "NaN":
+		e.buf.WriteString(`nan`)
+		return nil
+	case "Infinity":
+		e.buf.WriteString(`inf`)
+		return nil
+	case "-Infinity":
+		e.buf.WriteString(`-inf`)
+		return nil
+	}
+
+	if !strings.ContainsAny(n, ".eE") {
+		var i big.Int
+		if _, ok := i.SetString(n, 10); !ok {
+			return fmt.Errorf(`invalid number %q`, n)
+		}
+		if i.IsInt64() {
+			e.buf.WriteString(n)
+		} else {
+			// TOML integers are limited to 64 bits
+			e.buf.WriteString(n)
+			e.buf.WriteString(`.0`)
+		}
+		return nil
+	}
+
+	if _, err := strconv.ParseFloat(n, 64); err != nil && !errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf(`invalid number %q`, n)
+	}
+	e.buf.WriteString(n)
+	return nil
+}
+
+func (e *encoder) float(f float64) {
+	switch {
+	case math.IsNaN(f):
+		e.buf.WriteString(`nan`)
+	case math.IsInf(f, 1):
+		e.buf.WriteString(`inf`)
+	case math.IsInf(f, -1):
+		e.buf.WriteString(`-inf`)
+	default:
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		e.buf.WriteString(s)
+		if !strings.ContainsAny(s, ".e") {
+			e.buf.WriteString(`.0`)
+		}
+	}
+}
+
+const hexDigits = "0123456789ABCDEF"
+
+// string writes s as a TOML basic string
+func (e *encoder) string(s string) {
+	e.buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			e.buf.WriteByte('\\')
+			e.buf.WriteRune(r)
+		case '\b':
+			e.buf.WriteString(`\b`)
+		case '\t':
+			e.buf.WriteString(`\t`)
+		case '\n':
+			e.buf.WriteString(`\n`)
+		case '\f':
+			e.buf.WriteString(`\f`)
+		case '\r':
+			e.buf.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				e.buf.WriteString(`\u00`)
+				e.buf.WriteByte(hexDigits[r>>4])
+				e.buf.WriteByte(hexDigits[r&0xF])
+				continue
+			}
+			e.buf.WriteRune(r)
+		}
+	}
+	e.buf.WriteByte('"')
+}
@@ -0,0 +1,59 @@
+// Package toml converts TOML documents to and from json.Context trees,
+// so that configuration written in TOML can be processed using the
+// same tools as JSON.
+//
+// TOML values are mapped onto the JSON model as follows:
+//
+//   - tables and inline tables become JSON objects, whose fields keep
+//     the order in which they appeared in the document
+//   - arrays and arrays of tables become JSON arrays
+//   - integers and floats become json.Number values. `inf` and `nan`
+//     become the non-finite numbers described in json.WithNonFiniteNumbers
+//   - offset date-times become time.Time values
+//   - local date-times, local dates, and local times have no equivalent,
+//     and become strings in their TOML representation, such as "07:32:00"
+//
+// When marshaling, JSON objects become tables, and arrays whose elements
+// are all objects become arrays of tables. Since TOML has no null value,
+// documents containing null cannot be marshaled
+package toml
+
+import (
+	"fmt"
+	"github.com/lestrrat-go/json"
+)
+
+// Parse parses the TOML document in data, and returns a Context
+// pointing to the root table
+func Parse(data []byte) (json.Context, error) {
+	p := newParser(data)
+	if err := p.parse(); err != nil {
+		return nil, fmt.Errorf(`failed to parse TOML: %w`, err)
+	}
+
+	c := json.New(map[string]interface{}{}, json.WithPreserveKeyOrder(true), json.WithNonFiniteNumbers(true))
+	p.root.build(c)
+	return c, nil
+}
+
+// ParseString is like Parse, but parses a string
+func ParseString(s string) (json.Context, error) {
+	return Parse([]byte(s))
+}
+
+// Marshal returns the TOML encoding of the JSON object pointed by c.
+// Fields are emitted in the same order in which the Context iterates
+// over them, except that the fields of each table holding plain values
+// are emitted before those holding tables, as required by TOML
+func Marshal(c json.Context) ([]byte, error) {
+	var m map[string]interface{}
+	if err := c.Map(&m); err != nil {
+		return nil, fmt.Errorf(`TOML documents must be JSON objects: %w`, err)
+	}
+
+	var e encoder
+	if err := e.table(nil, c, m); err != nil {
+		return nil, fmt.Errorf(`failed to marshal TOML: %w`, err)
+	}
+	return e.buf.Bytes(), nil
+}
@@ -0,0 +1,304 @@
+package toml_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/json"
+	"github.com/lestrrat-go/json/toml"
+	"github.com/stretchr/testify/assert"
+)
+
+const example = `# This is a TOML document
+
+title = "TOML Example"
+
+[owner]
+name = "Tom Preston-Werner"
+dob = 1979-05-27T07:32:00-08:00
+
+[database]
+enabled = true
+ports = [ 8000, 8001, 8002 ]
+data = [ ["delta", "phi"], [3.14] ]
+temp_targets = { cpu = 79.5, case = 72.0 }
+
+[servers]
+
+[servers.alpha]
+ip = "10.0.0.1"
+role = "frontend"
+
+[servers.beta]
+ip = "10.0.0.2"
+role = "backend"
+
+[[products]]
+name = "Hammer"
+sku = 738594937
+
+[[products]]  # empty table within the array
+
+[[products]]
+name = "Nail"
+sku = 284758393
+
+color = "gray"
+`
+
+func TestParse(t *testing.T) {
+	t.Run("sanity", func(t *testing.T) {
+		j, err := toml.ParseString(example)
+		if !assert.NoError(t, err, `toml.ParseString should succeed`) {
+			return
+		}
+
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		const expected = `{"title":"TOML Example","owner":{"name":"Tom Preston-Werner","dob":"1979-05-27T07:32:00-08:00"},` +
+			`"database":{"enabled":true,"ports":[8000,8001,8002],"data":[["delta","phi"],[3.14]],"temp_targets":{"cpu":79.5,"case":72.0}},` +
+			`"servers":{"alpha":{"ip":"10.0.0.1","role":"frontend"},"beta":{"ip":"10.0.0.2","role":"backend"}},` +
+			`"products":[{"name":"Hammer","sku":738594937},{},{"name":"Nail","sku":284758393,"color":"gray"}]}`
+		if !assert.Equal(t, expected, string(buf), `output should match`) {
+			return
+		}
+
+		var sku int64
+		if !assert.NoError(t, j.MapIndex("products").Index(2).MapIndex("sku").Int(&sku), `Int should succeed`) {
+			return
+		}
+		if !assert.Equal(t, int64(284758393), sku, `sku should match`) {
+			return
+		}
+	})
+	t.Run("values", func(t *testing.T) {
+		const src = `
+str1 = "I'm a string. \"You can quote me\". Name\tJos\u00E9\nLocation\tSF."
+str2 = """
+Roses are red
+Violets are blue"""
+str3 = """\
+       The quick brown \
+       fox jumps over \
+       the lazy dog.\
+       """
+str4 = """Here are fifteen quotation marks: ""\"""\"""\"""\"""\"."""
+str5 = ''''That,' she said, 'is still pointless.''''
+path = 'C:\Users\nodejs\templates'
+regex = '''I [dw]on't need \d{2} apples'''
+int1 = +99
+int2 = 1_000
+hex = 0xDEAD_BEEF
+oct = 0o755
+bin = 0b11010110
+flt1 = +1.0
+flt2 = -5e+22
+flt3 = 6.626e-34
+flt4 = 224_617.445_991
+inf = -inf
+nan = nan
+odt1 = 1979-05-27T07:32:00Z
+odt2 = 1979-05-27 00:32:00.999999-07:00
+ldt = 1979-05-27T07:32:00
+ld = 1979-05-27
+lt = 00:32:00.999999
+site."google.com" = true
+"quoted key" = 1
+`
+		j, err := toml.ParseString(src)
+		if !assert.NoError(t, err, `toml.ParseString should succeed`) {
+			return
+		}
+
+		strings := map[string]string{
+			"str1":  "I'm a string. \"You can quote me\". Name\tJos\u00E9\nLocation\tSF.",
+			"str2":  "Roses are red\nViolets are blue",
+			"str3":  "The quick brown fox jumps over the lazy dog.",
+			"str4":  `Here are fifteen quotation marks: """"""""""""""".`,
+			"str5":  `'That,' she said, 'is still pointless.'`,
+			"path":  `C:\Users\nodejs\templates`,
+			"regex": `I [dw]on't need \d{2} apples`,
+			"ldt":   "1979-05-27T07:32:00",
+			"ld":    "1979-05-27",
+			"lt":    "00:32:00.999999",
+		}
+		for key, expected := range strings {
+			var s string
+			if !assert.NoError(t, j.MapIndex(key).String(&s), `String should succeed for %s`, key) {
+				return
+			}
+			if !assert.Equal(t, expected, s, `value of %s should match`, key) {
+				return
+			}
+		}
+
+		numbers := map[string]string{
+			"int1": "99",
+			"int2": "1000",
+			"hex":  "3735928559",
+			"oct":  "493",
+			"bin":  "214",
+			"flt1": "1.0",
+			"flt2": "-5e+22",
+			"flt3": "6.626e-34",
+			"flt4": "224617.445991",
+			"inf":  "-Infinity",
+			"nan":  "NaN",
+		}
+		for key, expected := range numbers {
+			buf, err := j.MapIndex(key).MarshalJSON()
+			if !assert.NoError(t, err, `MarshalJSON should succeed for %s`, key) {
+				return
+			}
+			if !assert.Equal(t, expected, string(buf), `value of %s should match`, key) {
+				return
+			}
+		}
+
+		buf, err := j.MapIndex("odt2").MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `"1979-05-27T00:32:00.999999-07:00"`, string(buf), `offset date-times should be time.Time values`) {
+			return
+		}
+
+		var b bool
+		if !assert.NoError(t, j.MapIndex("site").MapIndex("google.com").Bool(&b), `dotted keys should create tables`) {
+			return
+		}
+		if !assert.True(t, b, `value should be true`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		testcases := []struct {
+			Name  string
+			Input string
+		}{
+			{Name: "duplicate key", Input: "a = 1\na = 2"},
+			{Name: "duplicate table", Input: "[a]\n[a]"},
+			{Name: "table defined by dotted keys", Input: "[fruit]\napple.color = 'red'\n[fruit.apple]"},
+			{Name: "dotted keys extending a table", Input: "[a.b]\nc = 1\n[a]\nb.d = 2"},
+			{Name: "inline table extended", Input: "a = {b = 1}\n[a.c]"},
+			{Name: "static array extended", Input: "a = []\n[[a]]"},
+			{Name: "missing value", Input: "a = "},
+			{Name: "invalid number", Input: "a = 01"},
+			{Name: "invalid underscore", Input: "a = 1__0"},
+			{Name: "integer overflow", Input: "a = 9223372036854775808"},
+			{Name: "invalid date", Input: "a = 1979-02-30"},
+			{Name: "unterminated string", Input: `a = "foo`},
+			{Name: "newline in string", Input: "a = \"foo\nbar\""},
+			{Name: "invalid escape", Input: `a = "\q"`},
+			{Name: "trailing garbage", Input: "a = 1 b = 2"},
+			{Name: "trailing comma in inline table", Input: "a = {b = 1,}"},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				_, err := toml.ParseString(tc.Input)
+				if !assert.Error(t, err, `toml.ParseString should fail`) {
+					return
+				}
+			})
+		}
+	})
+}
+
+func TestMarshal(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		j, err := toml.ParseString(example)
+		if !assert.NoError(t, err, `toml.ParseString should succeed`) {
+			return
+		}
+
+		buf, err := toml.Marshal(j)
+		if !assert.NoError(t, err, `toml.Marshal should succeed`) {
+			return
+		}
+		const expected = `title = "TOML Example"
+
+[owner]
+name = "Tom Preston-Werner"
+dob = 1979-05-27T07:32:00-08:00
+
+[database]
+enabled = true
+ports = [8000, 8001, 8002]
+data = [["delta", "phi"], [3.14]]
+
+[database.temp_targets]
+cpu = 79.5
+case = 72.0
+
+[servers]
+
+[servers.alpha]
+ip = "10.0.0.1"
+role = "frontend"
+
+[servers.beta]
+ip = "10.0.0.2"
+role = "backend"
+
+[[products]]
+name = "Hammer"
+sku = 738594937
+
+[[products]]
+
+[[products]]
+name = "Nail"
+sku = 284758393
+color = "gray"
+`
+		if !assert.Equal(t, expected, string(buf), `output should match`) {
+			return
+		}
+
+		j2, err := toml.Parse(buf)
+		if !assert.NoError(t, err, `toml.Parse should succeed`) {
+			return
+		}
+		json1, _ := j.MarshalJSON()
+		json2, _ := j2.MarshalJSON()
+		if !assert.Equal(t, string(json1), string(json2), `documents should be equivalent`) {
+			return
+		}
+	})
+	t.Run("from JSON", func(t *testing.T) {
+		j, err := json.ParseString(`{"z":[1,{"a":[]}],"key with spaces":"\u0001","nested":{"list":[{"x":1.5e300}]},"big":18446744073709551615}`, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		j.SetMapIndex("when", time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC))
+
+		buf, err := toml.Marshal(j)
+		if !assert.NoError(t, err, `toml.Marshal should succeed`) {
+			return
+		}
+		const expected = `z = [1, { a = [] }]
+"key with spaces" = "\u0001"
+big = 18446744073709551615.0
+when = 2024-03-01T12:00:00Z
+
+[nested]
+
+[[nested.list]]
+x = 1.5e300
+`
+		if !assert.Equal(t, expected, string(buf), `output should match`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		if _, err := toml.Marshal(json.New([]interface{}{1})); !assert.Error(t, err, `non-objects should be rejected`) {
+			return
+		}
+		if _, err := toml.Marshal(json.New(map[string]interface{}{"a": []interface{}{nil}})); !assert.Error(t, err, `null should be rejected`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package json
+
+import "log/slog"
+
+func (c *ctx) Trace(logger *slog.Logger) Context {
+	c.tracer = logger
+	return c
+}
+
+// traceNavigation logs the outcome of the navigation operation op,
+// which was applied to c and returned result, found at path
+func (c *ctx) traceNavigation(op, path string, result Context) Context {
+	switch r := result.(type) {
+	case *errCtx:
+		// the Contexts derived from an invalid Context report the
+		// operations that they skip
+		r.tracer = c.tracer
+		c.tracer.Debug(`json: `+op+` failed`, `path`, path, `error`, r.err)
+	case *ctx:
+		c.tracer.Debug(`json: `+op, `path`, path, `kind`, kindOf(r.interfaceValue()).String())
+	}
+	return result
+}
+
+// traceAccess logs the outcome of the accessor op applied to c, which
+// returned *err. It is meant to be deferred by accessors
+func (c *ctx) traceAccess(op string, err *error) {
+	if c.tracer == nil {
+		return
+	}
+	if *err != nil {
+		c.tracer.Debug(`json: `+op+` failed`, `path`, c.location(), `error`, *err)
+		return
+	}
+	c.tracer.Debug(`json: `+op, `path`, c.location(), `kind`, kindOf(c.interfaceValue()).String())
+}
+
+// traceSkipped logs that the operation op was not performed because
+// the Context is invalid
+func (c errCtx) traceSkipped(op string) {
+	if c.tracer == nil {
+		return
+	}
+	c.tracer.Debug(`json: `+op+` skipped on invalid Context`, `error`, c.err)
+}
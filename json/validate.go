@@ -0,0 +1,48 @@
+package json
+
+import (
+	"fmt"
+	"io"
+)
+
+// Valid reports whether data is a single valid JSON value, optionally
+// surrounded by whitespace
+func Valid(data []byte) bool {
+	return Validate(data) == nil
+}
+
+// Validate checks that data is a single valid JSON value, optionally
+// surrounded by whitespace, without building a Context. If data is not
+// valid, the returned error describes the first problem found in the
+// input, including its location (see SyntaxError).
+//
+// ParseOptions that affect the accepted syntax (such as WithComments)
+// or the limits on the input (such as WithMaxDepth) are honored.
+// Trailing data is always rejected, as if WithDisallowTrailingData
+// had been specified
+func Validate(data []byte, options ...ParseOption) error {
+	cfg := newParseConfig(options)
+	if cfg.maxSize >= 0 && int64(len(data)) > cfg.maxSize {
+		return fmt.Errorf(`input exceeds maximum size of %d bytes: %w`, cfg.maxSize, ErrLimitExceeded)
+	}
+
+	r := getReader()
+	defer releaseReader(r)
+	r.Reset(data)
+
+	d := newDecoder(NewTokenizer(r), cfg)
+	tok, err := d.t.Next()
+	if err == nil && (tok.Kind == ObjectStartToken || tok.Kind == ArrayStartToken) {
+		_, err = d.skip(false)
+	}
+	if err == nil {
+		err = d.t.ensureEOF()
+	}
+	if err != nil {
+		if err == io.EOF {
+			err = &SyntaxError{msg: `unexpected end of JSON input`}
+		}
+		return fmt.Errorf(`invalid JSON: %w`, err)
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package json
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Version is an immutable version of a JSON document. Set and Delete
+// leave the Version unchanged, and return a new Version sharing the
+// values that were not modified with the original one: only the
+// objects and arrays holding the modified value are copied. Keeping
+// many versions of similar documents is therefore cheap, and so is
+// taking a snapshot, which is just keeping a reference to a Version.
+//
+// Since a Version is never modified, it can be read by several
+// goroutines without locking, while others derive new versions from
+// it. Versions are implemented by path copying over plain Go maps and
+// slices, rather than with persistent data structures such as hash
+// array mapped tries: the objects and arrays holding the modified
+// value are copied in full, so the cost of Set and Delete grows with
+// the size of the containers on the path of the value, rather than
+// with its depth only.
+//
+// The order of the keys of JSON objects is not preserved: objects are
+// visited in lexical key order, as if WithPreserveKeyOrder had not
+// been specified
+type Version struct {
+	root *ctx
+}
+
+// NewVersion returns a Version holding a copy of the document pointed
+// by c. Values whose decoding was deferred by WithLazy or
+// WithProjection are decoded. An error is returned if c is invalid
+func NewVersion(c Context) (*Version, error) {
+	c, unlock := unwrapSync(c)
+	defer unlock()
+
+	if err := c.Err(); err != nil {
+		return nil, fmt.Errorf(`invalid document: %w`, err)
+	}
+	src, ok := c.(*ctx)
+	if !ok {
+		return nil, fmt.Errorf(`unsupported document (%T)`, c)
+	}
+
+	root, err := copyValue(src)
+	if err != nil {
+		return nil, err
+	}
+	return &Version{root: root}, nil
+}
+
+// copyValue returns a read-only Context pointing to a copy of the value
+// held by c, which does not share objects and arrays with c
+func copyValue(c *ctx) (*ctx, error) {
+	copied := c.rewrite(func(s string, _ *keyOrder) (interface{}, error) {
+		return s, nil
+	})
+	if err := copied.Err(); err != nil {
+		return nil, err
+	}
+	root := copied.(*ctx)
+	root.order = nil
+	root.readOnly = true
+	return root, nil
+}
+
+// Context returns a Context pointing to the document held by v. The
+// document cannot be modified through it: Set and SetMapIndex return an
+// invalid Context wrapping ErrReadOnly. Each call returns a new Context,
+// so that each goroutine can use its own. Accessors such as Map and
+// Slice assign copies of the objects and arrays of the document, which
+// can be modified without affecting v
+func (v *Version) Context() Context {
+	return v.root.detached(v.root.interfaceValue())
+}
+
+// Set returns a new Version in which the value found at path has been
+// replaced by value, which may be a Context. The JSON objects and
+// arrays held by value are copied, so that modifying them afterwards
+// leaves the new Version unchanged. path uses the same notation as
+// Walk, and `$` replaces the whole document. If path points to a field
+// that does not exist, it is added to the object holding it, but the
+// objects and arrays holding that object must exist, and array
+// elements must be within bounds
+func (v *Version) Set(path string, value interface{}) (*Version, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	value, err = unwrapContext(value)
+	if err != nil {
+		return nil, fmt.Errorf(`invalid value: %w`, err)
+	}
+	if err := checkCycle(value, nil); err != nil {
+		return nil, err
+	}
+	copied, err := copyValue(newCtx(value))
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return v.derive(copied.interfaceValue()), nil
+	}
+
+	root, err := updateValue(v.root.interfaceValue(), segments, 0, func(container interface{}, seg pathSegment, path string) (interface{}, error) {
+		if seg.isIndex {
+			l, err := versionArray(container, seg, path)
+			if err != nil {
+				return nil, err
+			}
+			l[seg.index] = copied.interfaceValue()
+			return l, nil
+		}
+
+		m, err := versionObject(container, seg, path)
+		if err != nil {
+			return nil, err
+		}
+		m[seg.key] = copied.interfaceValue()
+		return m, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.derive(root), nil
+}
+
+// Delete returns a new Version from which the value found at path has
+// been removed. Removing an array element shifts the elements that
+// follow it. An error wrapping ErrKeyNotFound or ErrIndexOutOfRange is
+// returned if there is no value at path, and the whole document cannot
+// be removed
+func (v *Version) Delete(path string) (*Version, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf(`cannot delete %s`, rootPath)
+	}
+
+	root, err := updateValue(v.root.interfaceValue(), segments, 0, func(container interface{}, seg pathSegment, path string) (interface{}, error) {
+		if seg.isIndex {
+			l, ok := container.([]interface{})
+			if !ok {
+				return nil, valueTypeError(ArrayValue, path, container, `cannot delete element %d`, seg.index)
+			}
+			if seg.index >= len(l) {
+				return nil, newPathError(path, ErrIndexOutOfRange, `index %d is out of bounds (len=%d)`, seg.index, len(l))
+			}
+			l2 := make([]interface{}, 0, len(l)-1)
+			l2 = append(l2, l[:seg.index]...)
+			return append(l2, l[seg.index+1:]...), nil
+		}
+
+		m, ok := container.(map[string]interface{})
+		if !ok {
+			return nil, valueTypeError(ObjectValue, path, container, `cannot delete field %#v`, seg.key)
+		}
+		if _, ok := m[seg.key]; !ok {
+			return nil, newPathError(path, ErrKeyNotFound, `field %#v not found`, seg.key)
+		}
+		m2 := make(map[string]interface{}, len(m))
+		for key, elem := range m {
+			if key != seg.key {
+				m2[key] = elem
+			}
+		}
+		return m2, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.derive(root), nil
+}
+
+// MarshalJSON returns the JSON encoding of the document held by v
+func (v *Version) MarshalJSON() ([]byte, error) {
+	return v.root.MarshalJSON()
+}
+
+// derive returns a new Version holding root, with the settings of v
+func (v *Version) derive(root interface{}) *Version {
+	return &Version{root: v.root.detached(root)}
+}
+
+// updateFunc returns a copy of container, found at path, in which the
+// value located by seg has been modified
+type updateFunc func(container interface{}, seg pathSegment, path string) (interface{}, error)
+
+// updateValue returns a copy of v, found at the location described by
+// segments[:i], in which the value located by segments[i:] has been
+// modified by fn. Only the containers holding that value are copied
+func updateValue(v interface{}, segments []pathSegment, i int, fn updateFunc) (interface{}, error) {
+	path := formatPath(segments[:i])
+	seg := segments[i]
+	if i == len(segments)-1 {
+		return fn(v, seg, path)
+	}
+
+	if seg.isIndex {
+		l, err := versionArray(v, seg, path)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := updateValue(l[seg.index], segments, i+1, fn)
+		if err != nil {
+			return nil, err
+		}
+		l[seg.index] = elem
+		return l, nil
+	}
+
+	m, err := versionObject(v, seg, path)
+	if err != nil {
+		return nil, err
+	}
+	elem, ok := m[seg.key]
+	if !ok {
+		return nil, newPathError(path, ErrKeyNotFound, `field %#v not found`, seg.key)
+	}
+	elem, err = updateValue(elem, segments, i+1, fn)
+	if err != nil {
+		return nil, err
+	}
+	m[seg.key] = elem
+	return m, nil
+}
+
+// versionObject returns a copy of the JSON object v, found at path, in
+// order to modify its field seg.key
+func versionObject(v interface{}, seg pathSegment, path string) (map[string]interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, valueTypeError(ObjectValue, path, v, `cannot set field %#v`, seg.key)
+	}
+	m2 := make(map[string]interface{}, len(m)+1)
+	for key, elem := range m {
+		m2[key] = elem
+	}
+	return m2, nil
+}
+
+// versionArray returns a copy of the JSON array v, found at path, in
+// order to modify its element seg.index
+func versionArray(v interface{}, seg pathSegment, path string) ([]interface{}, error) {
+	l, ok := v.([]interface{})
+	if !ok {
+		return nil, valueTypeError(ArrayValue, path, v, `cannot set element %d`, seg.index)
+	}
+	if seg.index >= len(l) {
+		return nil, newPathError(path, ErrIndexOutOfRange, `index %d is out of bounds (len=%d)`, seg.index, len(l))
+	}
+	return append([]interface{}(nil), l...), nil
+}
+
+// copyContainers returns a copy of v, a value held by a Version, which
+// does not share maps and slices with it. Other values are shared, as
+// they cannot be modified through the document
+func copyContainers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			m[key] = copyContainers(elem)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, elem := range v {
+			l[i] = copyContainers(elem)
+		}
+		return l
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.IsNil() {
+			return v
+		}
+		m := reflect.MakeMapWithSize(rv.Type(), rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), copyElem(iter.Value(), rv.Type().Elem()))
+		}
+		return m.Interface()
+	case reflect.Slice:
+		if rv.IsNil() {
+			return v
+		}
+		l := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			l.Index(i).Set(copyElem(rv.Index(i), rv.Type().Elem()))
+		}
+		return l.Interface()
+	}
+	return v
+}
+
+// copyElem returns a copy of the element v of a container, whose
+// elements are of type t
+func copyElem(v reflect.Value, t reflect.Type) reflect.Value {
+	if v.Kind() == reflect.Interface && v.IsNil() {
+		return reflect.Zero(t)
+	}
+	return reflect.ValueOf(copyContainers(v.Interface())).Convert(t)
+}
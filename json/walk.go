@@ -0,0 +1,71 @@
+package json
+
+import (
+	"reflect"
+)
+
+// WalkAction is returned by the callback given to Walk to control
+// how the traversal proceeds
+type WalkAction int
+
+const (
+	// WalkContinue continues the traversal normally
+	WalkContinue WalkAction = iota
+	// WalkSkip skips the children of the current value. If the
+	// current value is not a container, this is the same as WalkContinue
+	WalkSkip
+	// WalkStop stops the traversal altogether. Walk returns nil
+	WalkStop
+)
+
+// WalkFunc is the type of the function called by Walk for each value
+// in the document. path is the location of the value in the document,
+// such as `$`, `$.foo`, `$.list[0]`, or `$["not an identifier"]`.
+//
+// If the function returns a non-nil error, the traversal stops and
+// Walk returns that error.
+type WalkFunc func(path string, c Context) (WalkAction, error)
+
+func (c *ctx) Walk(fn WalkFunc) error {
+	_, err := c.walk(rootPath, fn)
+	return err
+}
+
+// walk returns false if the traversal should be stopped
+func (c *ctx) walk(path string, fn WalkFunc) (bool, error) {
+	action, err := fn(path, c)
+	if err != nil {
+		return false, err
+	}
+
+	switch action {
+	case WalkStop:
+		return false, nil
+	case WalkSkip:
+		return true, nil
+	}
+
+	switch c.value.Kind() {
+	case reflect.Map:
+		for _, key := range c.keys() {
+			child, err := c.field(key)
+			if err != nil {
+				return false, err
+			}
+			if ok, err := child.walk(keyPath(path, key), fn); !ok {
+				return false, err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < c.value.Len(); i++ {
+			child, err := c.indexChild(i)
+			if err != nil {
+				return false, err
+			}
+			if ok, err := child.walk(indexPath(path, i), fn); !ok {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,184 @@
+package xml
+
+import (
+	stdlib "encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lestrrat-go/json"
+)
+
+// maxDepth is the maximum nesting depth of elements
+const maxDepth = 1000
+
+// object is a converted element. keys holds the field names in the
+// order in which they first appeared in the element
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// add stores v under key. Repeated keys are collected into an array,
+// which is created upfront if array is true
+func (o *object) add(key string, v interface{}, array bool) {
+	existing, ok := o.values[key]
+	if !ok {
+		o.keys = append(o.keys, key)
+		if array {
+			v = []interface{}{v}
+		}
+		o.values[key] = v
+		return
+	}
+
+	// element values are never arrays, so an existing array was
+	// created for repeated keys
+	if l, ok := existing.([]interface{}); ok {
+		o.values[key] = append(l, v)
+		return
+	}
+	o.values[key] = []interface{}{existing, v}
+}
+
+// build creates a Context for the converted value v
+func build(v interface{}) json.Context {
+	c := json.New(placeholder(v), json.WithPreserveKeyOrder(true))
+	populate(c, v)
+	return c
+}
+
+// placeholder returns the value that should be stored for v before
+// calling populate. Objects are represented by empty maps, so that
+// their keys can be added through the Context in order
+func placeholder(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *object:
+		return map[string]interface{}{}
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, elem := range v {
+			l[i] = placeholder(elem)
+		}
+		return l
+	}
+	return v
+}
+
+// populate adds the contents of v to c, which holds placeholder(v)
+func populate(c json.Context, v interface{}) {
+	switch v := v.(type) {
+	case *object:
+		for _, key := range v.keys {
+			elem := v.values[key]
+			c.SetMapIndex(key, placeholder(elem))
+			populate(c.MapIndex(key), elem)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			populate(c.Index(i), elem)
+		}
+	}
+}
+
+// decoder converts the elements read from an XML token stream
+type decoder struct {
+	config
+	dec   *stdlib.Decoder
+	depth int
+}
+
+func newDecoder(r io.Reader, options []Option) *decoder {
+	return &decoder{
+		config: newConfig(options),
+		dec:    stdlib.NewDecoder(r),
+	}
+}
+
+// document reads the root element, and checks that nothing but
+// comments, processing instructions, and white space surround it
+func (d *decoder) document() (interface{}, error) {
+	var root *object
+	for {
+		tok, err := d.dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case stdlib.StartElement:
+			if root != nil {
+				return nil, fmt.Errorf(`unexpected element <%s> after the root element`, tok.Name.Local)
+			}
+			v, err := d.element(tok)
+			if err != nil {
+				return nil, err
+			}
+			root = &object{values: make(map[string]interface{})}
+			root.add(tok.Name.Local, v, false)
+		case stdlib.CharData:
+			if len(strings.TrimSpace(string(tok))) > 0 {
+				return nil, errors.New(`unexpected text outside of the root element`)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, errors.New(`missing root element`)
+	}
+	return root, nil
+}
+
+// element converts the element started by start, up to and including
+// its end tag
+func (d *decoder) element(start stdlib.StartElement) (interface{}, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxDepth {
+		return nil, fmt.Errorf(`nesting depth exceeds maximum of %d`, maxDepth)
+	}
+
+	o := &object{values: make(map[string]interface{})}
+	for _, attr := range start.Attr {
+		if attr.Name.Space == `xmlns` || (attr.Name.Space == `` && attr.Name.Local == `xmlns`) {
+			continue
+		}
+		o.add(d.attrPrefix+attr.Name.Local, attr.Value, false)
+	}
+
+	var text strings.Builder
+	for {
+		tok, err := d.dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf(`failed to read element <%s>: %w`, start.Name.Local, err)
+		}
+
+		switch tok := tok.(type) {
+		case stdlib.StartElement:
+			v, err := d.element(tok)
+			if err != nil {
+				return nil, err
+			}
+			_, array := d.arrays[tok.Name.Local]
+			o.add(tok.Name.Local, v, array)
+		case stdlib.CharData:
+			text.Write(tok)
+		case stdlib.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(o.keys) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				o.add(d.textKey, s, false)
+			}
+			return o, nil
+		}
+	}
+}
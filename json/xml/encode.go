@@ -0,0 +1,227 @@
+package xml
+
+import (
+	"bytes"
+	stdlib "encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lestrrat-go/json"
+)
+
+// encoder writes the XML encoding of Contexts. Containers are visited
+// through Contexts, so that the order of the fields is honored, while
+// the Go values held by them are used to determine their types
+type encoder struct {
+	config
+	buf bytes.Buffer
+}
+
+func newEncoder(options []Option) *encoder {
+	return &encoder{config: newConfig(options)}
+}
+
+// document writes the root element
+func (e *encoder) document(c json.Context) error {
+	if e.root != "" {
+		v, err := goValue(c)
+		if err != nil {
+			return err
+		}
+		if _, ok := v.([]interface{}); ok {
+			return errors.New(`the root element cannot be an array`)
+		}
+		return e.element(e.root, c, v)
+	}
+
+	var m map[string]interface{}
+	if err := c.Map(&m); err != nil {
+		return fmt.Errorf(`XML documents must be JSON objects: %w`, err)
+	}
+	if len(m) != 1 {
+		return fmt.Errorf(`XML documents must have a single root element, found %d fields`, len(m))
+	}
+	for name, child := range c.Entries() {
+		if _, ok := m[name].([]interface{}); ok {
+			return errors.New(`the root element cannot be an array`)
+		}
+		return e.element(name, child, m[name])
+	}
+	return nil
+}
+
+// goValue returns the container held by c. For other values, which are
+// written using their text, it returns c itself, or nil for null
+func goValue(c json.Context) (interface{}, error) {
+	var m map[string]interface{}
+	if err := c.Map(&m); err == nil {
+		return m, nil
+	}
+	var l []interface{}
+	if err := c.Slice(&l); err == nil {
+		return l, nil
+	}
+	buf, err := c.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	if string(buf) == `null` {
+		return nil, nil
+	}
+	return c, nil
+}
+
+// isName reports whether s is a valid XML name. Names containing colons
+// are rejected, since namespaces are not supported
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// element writes v, which is pointed by c, as the element name. Arrays
+// are written as repeated elements
+func (e *encoder) element(name string, c json.Context, v interface{}) error {
+	if !isName(name) {
+		return fmt.Errorf(`invalid element name %q`, name)
+	}
+
+	switch v := v.(type) {
+	case nil:
+		e.buf.WriteString(`<` + name + `/>`)
+	case []interface{}:
+		for i, child := range c.Elements() {
+			if _, ok := v[i].([]interface{}); ok {
+				return fmt.Errorf(`element %d of %q: nested arrays cannot be represented in XML`, i, name)
+			}
+			if err := e.element(name, child, v[i]); err != nil {
+				return fmt.Errorf(`failed to encode element %d of %q: %w`, i, name, err)
+			}
+		}
+	case map[string]interface{}:
+		return e.object(name, c, v)
+	default:
+		s, err := text(c)
+		if err != nil {
+			return fmt.Errorf(`failed to encode element %q: %w`, name, err)
+		}
+		e.buf.WriteString(`<` + name + `>`)
+		escape(&e.buf, s)
+		e.buf.WriteString(`</` + name + `>`)
+	}
+	return nil
+}
+
+// object writes the JSON object m, pointed by c, as the element name.
+// Attributes are written first, followed by the text and the child
+// elements in their original order
+func (e *encoder) object(name string, c json.Context, m map[string]interface{}) error {
+	e.buf.WriteString(`<` + name)
+	var content bool
+	for key, child := range c.Entries() {
+		if e.attrPrefix == "" || !strings.HasPrefix(key, e.attrPrefix) {
+			content = true
+			continue
+		}
+		attr := strings.TrimPrefix(key, e.attrPrefix)
+		if !isName(attr) {
+			return fmt.Errorf(`invalid attribute name %q`, attr)
+		}
+		switch m[key].(type) {
+		case map[string]interface{}, []interface{}:
+			return fmt.Errorf(`attribute %q must not be an object or an array`, attr)
+		}
+		s, err := text(child)
+		if err != nil {
+			return fmt.Errorf(`failed to encode attribute %q: %w`, attr, err)
+		}
+		e.buf.WriteString(` ` + attr + `="`)
+		escape(&e.buf, s)
+		e.buf.WriteByte('"')
+	}
+	if !content {
+		e.buf.WriteString(`/>`)
+		return nil
+	}
+	e.buf.WriteByte('>')
+
+	for key, child := range c.Entries() {
+		if e.attrPrefix != "" && strings.HasPrefix(key, e.attrPrefix) {
+			continue
+		}
+		if key == e.textKey {
+			s, err := text(child)
+			if err != nil {
+				return fmt.Errorf(`failed to encode text of %q: %w`, name, err)
+			}
+			escape(&e.buf, s)
+			continue
+		}
+		if err := e.element(key, child, m[key]); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteString(`</` + name + `>`)
+	return nil
+}
+
+// text returns the text representation of the scalar pointed by c,
+// which is its JSON representation, except for strings
+func text(c json.Context) (string, error) {
+	buf, err := c.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	if len(buf) > 0 && buf[0] == '"' {
+		var s string
+		if err := c.String(&s); err == nil {
+			return s, nil
+		}
+		// values such as time.Time are not strings, but are
+		// represented as JSON strings
+		j, err := json.Parse(buf)
+		if err != nil {
+			return "", err
+		}
+		if err := j.String(&s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+	return string(buf), nil
+}
+
+// escape writes s with the characters that are special in XML escaped.
+// Characters that are not allowed in XML documents are rejected by
+// encoding/xml as well, so they are replaced with U+FFFD
+func escape(buf *bytes.Buffer, s string) {
+	if strings.IndexFunc(s, func(r rune) bool { return !isChar(r) }) >= 0 {
+		s = strings.Map(func(r rune) rune {
+			if !isChar(r) {
+				return utf8.RuneError
+			}
+			return r
+		}, s)
+	}
+	_ = stdlib.EscapeText(buf, []byte(s))
+}
+
+// isChar reports whether r is allowed in XML documents
+func isChar(r rune) bool {
+	return r == 0x09 || r == 0x0A || r == 0x0D ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
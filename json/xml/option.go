@@ -0,0 +1,59 @@
+package xml
+
+const (
+	optKeyAttributePrefix = `optkey-attribute-prefix`
+	optKeyTextKey         = `optkey-text-key`
+	optKeyArrayElements   = `optkey-array-elements`
+	optKeyRootElement     = `optkey-root-element`
+)
+
+// Option configures how XML is converted. Unless noted otherwise,
+// Options apply to both Parse and Marshal
+type Option interface {
+	Name() string
+	Value() interface{}
+}
+
+type option struct {
+	name  string
+	value interface{}
+}
+
+func (o option) Name() string {
+	return o.name
+}
+
+func (o option) Value() interface{} {
+	return o.value
+}
+
+// WithArrayElements specifies the names of elements whose values should
+// always be JSON arrays when parsing, even if they appear only once, so
+// that the shape of the document does not depend on the number of
+// repetitions
+func WithArrayElements(names ...string) Option {
+	return &option{name: optKeyArrayElements, value: names}
+}
+
+// WithAttributePrefix specifies the prefix of the fields that represent
+// attributes. The default is "-". If prefix is empty, attributes become
+// fields that cannot be distinguished from child elements, and Marshal
+// emits all fields as child elements
+func WithAttributePrefix(prefix string) Option {
+	return &option{name: optKeyAttributePrefix, value: prefix}
+}
+
+// WithRootElement specifies that Marshal should emit the value pointed
+// by the Context as the root element with the given name, instead of
+// expecting a JSON object holding the root element. It is ignored by
+// Parse
+func WithRootElement(name string) Option {
+	return &option{name: optKeyRootElement, value: name}
+}
+
+// WithTextKey specifies the name of the field that represents the text
+// of elements that also have attributes or child elements. The default
+// is "#text"
+func WithTextKey(key string) Option {
+	return &option{name: optKeyTextKey, value: key}
+}
@@ -0,0 +1,93 @@
+// Package xml converts XML documents to and from json.Context trees,
+// so that documents received as XML can be processed using the same
+// tools as JSON.
+//
+// XML elements are mapped onto the JSON model as follows:
+//
+//   - the document becomes a JSON object with a single field, named
+//     after the root element
+//   - elements without attributes or child elements become strings
+//     holding their text. Empty elements become empty strings
+//   - all other elements become JSON objects, whose fields keep the
+//     order in which they appeared in the document. Attributes become
+//     fields whose names are prefixed with "-", child elements become
+//     fields named after them, and text becomes a field named "#text".
+//     See WithAttributePrefix and WithTextKey
+//   - repeated child elements become a JSON array holding all of their
+//     values. See WithArrayElements
+//
+// Element and attribute names are used without their namespace, and
+// namespace declarations are dropped. Text is stripped of leading and
+// trailing white space, and comments and processing instructions are
+// ignored. Since XML has no types, all values are strings.
+//
+// When marshaling, the same mapping is applied in reverse: fields with
+// the attribute prefix become attributes, arrays become repeated
+// elements, and null values and empty objects become empty elements
+package xml
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/lestrrat-go/json"
+)
+
+// Parse parses the XML document in data, and returns a Context pointing
+// to a JSON object holding the root element
+func Parse(data []byte, options ...Option) (json.Context, error) {
+	d := newDecoder(bytes.NewReader(data), options)
+	v, err := d.document()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse XML: %w`, err)
+	}
+	return build(v), nil
+}
+
+// ParseString is like Parse, but parses a string
+func ParseString(s string, options ...Option) (json.Context, error) {
+	return Parse([]byte(s), options...)
+}
+
+// Marshal returns the XML encoding of the JSON object pointed by c,
+// which must have a single field holding the root element, unless
+// WithRootElement is specified. Fields are emitted in the same order in
+// which the Context iterates over them. No XML declaration is emitted
+func Marshal(c json.Context, options ...Option) ([]byte, error) {
+	e := newEncoder(options)
+	if err := e.document(c); err != nil {
+		return nil, fmt.Errorf(`failed to marshal XML: %w`, err)
+	}
+	return e.buf.Bytes(), nil
+}
+
+// config holds the settings shared by the decoder and the encoder
+type config struct {
+	attrPrefix string
+	textKey    string
+	arrays     map[string]struct{}
+	root       string
+}
+
+func newConfig(options []Option) config {
+	cfg := config{
+		attrPrefix: `-`,
+		textKey:    `#text`,
+		arrays:     make(map[string]struct{}),
+	}
+	for _, option := range options {
+		switch option.Name() {
+		case optKeyAttributePrefix:
+			cfg.attrPrefix = option.Value().(string)
+		case optKeyTextKey:
+			cfg.textKey = option.Value().(string)
+		case optKeyArrayElements:
+			for _, name := range option.Value().([]string) {
+				cfg.arrays[name] = struct{}{}
+			}
+		case optKeyRootElement:
+			cfg.root = option.Value().(string)
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,171 @@
+package xml_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/json"
+	"github.com/lestrrat-go/json/xml"
+	"github.com/stretchr/testify/assert"
+)
+
+const example = `<?xml version="1.0" encoding="UTF-8"?>
+<!-- order export -->
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <order id="42" status="open">
+      <customer>Jane &amp; Co.</customer>
+      <item sku="A1">Hammer</item>
+      <item sku="B2">Nail</item>
+      <note><![CDATA[fragile <handle with care>]]></note>
+      <empty/>
+    </order>
+  </soap:Body>
+</soap:Envelope>
+`
+
+func TestParse(t *testing.T) {
+	t.Run("sanity", func(t *testing.T) {
+		j, err := xml.ParseString(example)
+		if !assert.NoError(t, err, `xml.ParseString should succeed`) {
+			return
+		}
+
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		const expected = `{"Envelope":{"Body":{"order":{"-id":"42","-status":"open","customer":"Jane \u0026 Co.",` +
+			`"item":[{"-sku":"A1","#text":"Hammer"},{"-sku":"B2","#text":"Nail"}],` +
+			`"note":"fragile \u003chandle with care\u003e","empty":""}}}}`
+		if !assert.Equal(t, expected, string(buf), `output should match`) {
+			return
+		}
+
+		var sku string
+		if !assert.NoError(t, j.MapIndex("Envelope").MapIndex("Body").MapIndex("order").MapIndex("item").Index(1).MapIndex("-sku").String(&sku), `String should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "B2", sku, `attribute should match`) {
+			return
+		}
+	})
+	t.Run("options", func(t *testing.T) {
+		const src = `<order id="42"><item>Hammer</item><tag>a</tag>mixed<tag>b</tag></order>`
+		j, err := xml.ParseString(src,
+			xml.WithAttributePrefix("@"),
+			xml.WithTextKey("_"),
+			xml.WithArrayElements("item"),
+		)
+		if !assert.NoError(t, err, `xml.ParseString should succeed`) {
+			return
+		}
+
+		buf, err := j.MarshalJSON()
+		if !assert.NoError(t, err, `MarshalJSON should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `{"order":{"@id":"42","item":["Hammer"],"tag":["a","b"],"_":"mixed"}}`, string(buf), `output should match`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		testcases := []struct {
+			Name  string
+			Input string
+		}{
+			{Name: "empty", Input: ""},
+			{Name: "text only", Input: "hello"},
+			{Name: "unterminated element", Input: "<a><b></b>"},
+			{Name: "mismatched end tag", Input: "<a></b>"},
+			{Name: "multiple root elements", Input: "<a/><b/>"},
+			{Name: "trailing text", Input: "<a/>b"},
+			{Name: "undefined entity", Input: "<a>&foo;</a>"},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				_, err := xml.ParseString(tc.Input)
+				if !assert.Error(t, err, `xml.ParseString should fail`) {
+					return
+				}
+			})
+		}
+	})
+}
+
+func TestMarshal(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		j, err := xml.ParseString(example)
+		if !assert.NoError(t, err, `xml.ParseString should succeed`) {
+			return
+		}
+
+		buf, err := xml.Marshal(j)
+		if !assert.NoError(t, err, `xml.Marshal should succeed`) {
+			return
+		}
+		const expected = `<Envelope><Body><order id="42" status="open"><customer>Jane &amp; Co.</customer>` +
+			`<item sku="A1">Hammer</item><item sku="B2">Nail</item>` +
+			`<note>fragile &lt;handle with care&gt;</note><empty></empty></order></Body></Envelope>`
+		if !assert.Equal(t, expected, string(buf), `output should match`) {
+			return
+		}
+
+		j2, err := xml.Parse(buf)
+		if !assert.NoError(t, err, `xml.Parse should succeed`) {
+			return
+		}
+		json1, _ := j.MarshalJSON()
+		json2, _ := j2.MarshalJSON()
+		if !assert.Equal(t, string(json1), string(json2), `documents should be equivalent`) {
+			return
+		}
+	})
+	t.Run("from JSON", func(t *testing.T) {
+		j, err := json.ParseString(`{"z":1.5,"list":[true,{"-a":"x"},null],"nested":{"-id":7,"#text":"t\u0001","k":{}}}`, json.WithPreserveKeyOrder(true))
+		if !assert.NoError(t, err, `json.ParseString should succeed`) {
+			return
+		}
+		j.SetMapIndex("when", time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC))
+
+		buf, err := xml.Marshal(j, xml.WithRootElement("doc"))
+		if !assert.NoError(t, err, `xml.Marshal should succeed`) {
+			return
+		}
+		const expected = `<doc><z>1.5</z><list>true</list><list a="x"/><list/>` +
+			`<nested id="7">t` + "�" + `<k/></nested><when>2024-03-01T12:00:00Z</when></doc>`
+		if !assert.Equal(t, expected, string(buf), `output should match`) {
+			return
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		testcases := []struct {
+			Name    string
+			Input   string
+			Options []xml.Option
+		}{
+			{Name: "no root element", Input: `{"a":1,"b":2}`},
+			{Name: "not an object", Input: `[1]`},
+			{Name: "array as root", Input: `{"a":[1,2]}`},
+			{Name: "nested arrays", Input: `{"a":{"b":[[1]]}}`},
+			{Name: "invalid element name", Input: `{"a":{"b c":1}}`},
+			{Name: "invalid attribute name", Input: `{"a":{"-1":1}}`},
+			{Name: "object as attribute", Input: `{"a":{"-b":{}}}`},
+			{Name: "array root element", Input: `[1]`, Options: []xml.Option{xml.WithRootElement("a")}},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				j, err := json.ParseString(tc.Input)
+				if !assert.NoError(t, err, `json.ParseString should succeed`) {
+					return
+				}
+				_, err = xml.Marshal(j, tc.Options...)
+				if !assert.Error(t, err, `xml.Marshal should fail`) {
+					return
+				}
+			})
+		}
+	})
+}